@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"admin-service/internal/collector"
+	"admin-service/internal/config"
+	"admin-service/internal/handlers"
+	"admin-service/internal/metrics"
+	"admin-service/internal/routes"
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	cfg := config.Load(os.Args[1:])
+	_, err := cfg.ResolveSecrets(context.Background())
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		// Reported before the logger exists, which needs a valid config.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logLevel := logger.DefaultLevel(cfg.Environment)
+	if cfg.LogLevel != "" {
+		// Validate rejected unknown levels.
+		logLevel, _ = logger.ParseLevel(cfg.LogLevel)
+	}
+	log, err := logger.NewZapLogger(logger.Config{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Level:       logLevel,
+		Encoding:    cfg.LogEncoding,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
+	}
+	defer log.Sync()
+
+	log.Info("Starting admin service",
+		logger.String("port", cfg.Port),
+		logger.String("environment", cfg.Environment),
+		logger.String("services", cfg.AdminServices),
+		logger.String("jaeger_endpoint", cfg.JaegerEndpoint))
+
+	settings := sharedconfig.Redact(cfg)
+	log.Info("Effective configuration", logger.Any("config", settings))
+
+	tracingCfg := tracing.Config{
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: "1.0.0",
+		Environment:    cfg.Environment,
+		JaegerEndpoint: cfg.JaegerEndpoint,
+		SampleRatio:    cfg.TraceSampleRatio,
+	}
+
+	if err := tracing.InitTracer(tracingCfg); err != nil {
+		log.Fatal("Failed to initialize tracer",
+			logger.Err(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracing.ShutdownTracer(ctx); err != nil {
+			log.Error("Error shutting down tracer", logger.Err(err))
+		}
+	}()
+
+	log.Info("Tracer initialized successfully")
+
+	sharedmetrics.EnableExemplars(cfg.MetricsExemplars)
+	metricsRegistry := metrics.InitMetrics(sharedmetrics.Config{
+		Service:  cfg.ServiceName,
+		MaxPaths: cfg.MetricsMaxPaths,
+	})
+	log.Info("Metrics initialized successfully")
+
+	// Validate rejected a malformed service list.
+	services, _ := config.ParseServices(cfg.AdminServices)
+	overviewCollector := collector.New(log, services, cfg.AdminAuthToken, cfg.AdminFetchTimeout)
+
+	// The collected services are reported by /health only, so the dashboard
+	// stays live and ready to show them while they are down.
+	prober := health.NewProber(cfg.ServiceName, log, cfg.HealthCheckTimeout)
+	for _, svc := range services {
+		prober.AddDiagnostic(svc.Name, health.HTTP(nil, svc.URL+"/live"))
+	}
+
+	var mw routes.Middleware
+	metricsNetworks, err := sharedmetrics.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatal("Invalid METRICS_ALLOWED_NETWORKS", logger.Err(err))
+	}
+	mw.MetricsAuth = sharedmetrics.AuthMiddleware(sharedmetrics.AuthConfig{
+		BearerToken:     cfg.MetricsAuthToken,
+		Username:        cfg.MetricsAuthUsername,
+		Password:        cfg.MetricsAuthPassword,
+		AllowedNetworks: metricsNetworks,
+	}, log)
+	if mw.MetricsAuth != nil {
+		log.Info("Metrics and overview endpoints protected",
+			logger.Bool("bearer_token", cfg.MetricsAuthToken != ""),
+			logger.Bool("basic_auth", cfg.MetricsAuthUsername != ""),
+			logger.String("allowed_networks", cfg.MetricsAllowedNetworks))
+	}
+	// /internal/config shares the /metrics credentials, but is left out
+	// rather than served openly when there are none.
+	mw.ConfigAuth = mw.MetricsAuth
+	if mw.ConfigAuth == nil {
+		log.Info("GET /internal/config disabled, set METRICS_AUTH_TOKEN, METRICS_AUTH_USERNAME or METRICS_ALLOWED_NETWORKS to enable it")
+	}
+
+	gin.SetMode(cfg.GinMode)
+	router := gin.New()
+
+	routes.SetupRoutes(router, log, cfg.ServiceName, handlers.NewOverviewHandler(log, overviewCollector, cfg.AdminRecentLimit, cfg.AdminRefreshInterval), sharedconfig.Handler(cfg.ServiceName, settings), prober, metricsRegistry, mw)
+
+	log.Info("Routes configured")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	log.Info("Server starting",
+		logger.String("address", addr))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server",
+				logger.Err(err))
+		}
+	}()
+
+	<-sigChan
+	log.Info("Shutdown signal received, initiating graceful shutdown",
+		logger.Duration("timeout", cfg.ShutdownTimeout))
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP server did not drain in time, closing remaining connections", logger.Err(err))
+		server.Close()
+	} else {
+		log.Info("HTTP server stopped")
+	}
+	cancelShutdown()
+
+	log.Info("Service shutdown complete")
+}
@@ -0,0 +1,249 @@
+// Package collector gathers the health reports and operational snapshots of
+// the services into one overview.
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"admin-service/internal/config"
+	"admin-service/internal/metrics"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	"observability-system/shared/ops"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+)
+
+// StatusUnreachable is the status of a service whose health report could
+// not be fetched.
+const StatusUnreachable = "unreachable"
+
+// Endpoints fetched from every service, also the endpoint label of the
+// admin_fetch metrics.
+const (
+	endpointHealth = "health"
+	endpointOps    = "ops"
+)
+
+// maxResponseSize bounds the responses read from the services.
+const maxResponseSize = 4 << 20
+
+// Totals sums up what needs attention, for a service or all of them.
+type Totals struct {
+	Pending                 int64   `json:"pending"`
+	Failed                  int64   `json:"failed"`
+	Quarantined             int64   `json:"quarantined"`
+	OldestPendingAgeSeconds float64 `json:"oldest_pending_age_seconds"`
+	// DeadLetters counts the dead letters listed, at most the limit per
+	// table, so it is a lower bound.
+	DeadLetters int `json:"dead_letters"`
+	// FailingWorkers counts the workers whose last polls or messages
+	// failed.
+	FailingWorkers int `json:"failing_workers"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.Pending += o.Pending
+	t.Failed += o.Failed
+	t.Quarantined += o.Quarantined
+	t.OldestPendingAgeSeconds = max(t.OldestPendingAgeSeconds, o.OldestPendingAgeSeconds)
+	t.DeadLetters += o.DeadLetters
+	t.FailingWorkers += o.FailingWorkers
+}
+
+// needsAttention reports whether anything has failed.
+func (t Totals) needsAttention() bool {
+	return t.Failed > 0 || t.Quarantined > 0 || t.DeadLetters > 0 || t.FailingWorkers > 0
+}
+
+// Service is what the overview shows of one service. Health and Ops are nil
+// when they could not be fetched, with the reason in Errors.
+type Service struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Status is the service's /health status, or unreachable.
+	Status string          `json:"status"`
+	Health *health.Summary `json:"health,omitempty"`
+	Ops    *ops.Snapshot   `json:"ops,omitempty"`
+	Totals Totals          `json:"totals"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// Overview is the state of every collected service.
+type Overview struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Status is down when a service is down or unreachable, degraded when
+	// one is degraded or has failed messages or workers, and up otherwise.
+	Status string `json:"status"`
+	// Limit is how many failed messages per table and records per list
+	// each service was asked for.
+	Limit    int       `json:"limit"`
+	Totals   Totals    `json:"totals"`
+	Services []Service `json:"services"`
+}
+
+// Collector fetches /health and /internal/ops from every service.
+type Collector struct {
+	logger   logger.Logger
+	services []config.Service
+	token    string
+	client   *tracing.TracedHTTPClient
+}
+
+// New returns a collector of services, sending token as the bearer token
+// when it is set. Each request gives up after timeout.
+func New(log logger.Logger, services []config.Service, token string, timeout time.Duration) *Collector {
+	return &Collector{
+		logger:   log,
+		services: services,
+		token:    token,
+		client:   tracing.NewTracedHTTPClient(timeout),
+	}
+}
+
+// Overview collects every service concurrently, asking each for limit
+// failed messages per table and records per list. A service that cannot be
+// reached is reported as such rather than failing the overview.
+func (c *Collector) Overview(ctx context.Context, limit int) Overview {
+	overview := Overview{
+		GeneratedAt: time.Now().UTC(),
+		Status:      health.StatusUp,
+		Limit:       limit,
+		Services:    make([]Service, len(c.services)),
+	}
+
+	var wg sync.WaitGroup
+	for i, svc := range c.services {
+		wg.Add(1)
+		go func(i int, svc config.Service) {
+			defer wg.Done()
+			overview.Services[i] = c.service(ctx, svc, limit)
+		}(i, svc)
+	}
+	wg.Wait()
+
+	for _, svc := range overview.Services {
+		overview.Totals.add(svc.Totals)
+		switch {
+		case svc.Status == health.StatusDown || svc.Status == StatusUnreachable:
+			overview.Status = health.StatusDown
+		case overview.Status == health.StatusUp && (svc.Status != health.StatusUp || svc.Ops == nil || svc.Totals.needsAttention()):
+			overview.Status = health.StatusDegraded
+		}
+	}
+	return overview
+}
+
+func (c *Collector) service(ctx context.Context, svc config.Service, limit int) Service {
+	res := Service{Name: svc.Name, URL: svc.URL, Status: StatusUnreachable}
+
+	var summary health.Summary
+	var snapshot ops.Snapshot
+	var healthErr, opsErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// /health answers 503 with its report when the service is down.
+		healthErr = c.fetch(ctx, svc, endpointHealth, "/health", &summary, http.StatusOK, http.StatusServiceUnavailable)
+	}()
+	go func() {
+		defer wg.Done()
+		opsErr = c.fetch(ctx, svc, endpointOps, "/internal/ops?limit="+strconv.Itoa(limit), &snapshot, http.StatusOK)
+	}()
+	wg.Wait()
+
+	if healthErr != nil {
+		res.Errors = append(res.Errors, "health: "+healthErr.Error())
+	} else {
+		res.Health = &summary
+		res.Status = summary.Status
+	}
+	if opsErr != nil {
+		res.Errors = append(res.Errors, "ops: "+opsErr.Error())
+	} else {
+		res.Ops = &snapshot
+		res.Totals = totals(snapshot)
+	}
+	return res
+}
+
+func totals(snapshot ops.Snapshot) Totals {
+	var t Totals
+	for _, table := range snapshot.Tables {
+		t.Pending += table.Pending
+		t.Failed += table.Failed
+		t.Quarantined += table.Quarantined
+		t.OldestPendingAgeSeconds = max(t.OldestPendingAgeSeconds, table.OldestPendingAgeSeconds)
+		t.DeadLetters += len(table.DeadLetters)
+		for _, w := range table.Workers {
+			if w.ErrorStreak > 0 {
+				t.FailingWorkers++
+			}
+		}
+	}
+	return t
+}
+
+// fetch decodes the response to a GET of path into out. A status other than
+// want fails, with the problem's code and detail when the response is one.
+func (c *Collector) fetch(ctx context.Context, svc config.Service, endpoint, path string, out interface{}, want ...int) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.RecordFetch(ctx, svc.Name, endpoint, err == nil, time.Since(start))
+		if err != nil {
+			c.logger.WarnCtx(ctx, "Failed to collect service",
+				logger.String("target", svc.Name),
+				logger.String("endpoint", endpoint),
+				logger.Err(err))
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, svc.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	expected := false
+	for _, status := range want {
+		expected = expected || resp.StatusCode == status
+	}
+	if !expected {
+		msg := fmt.Sprintf("GET %s answered %d", req.URL.Path, resp.StatusCode)
+		var p problem.Problem
+		if json.Unmarshal(raw, &p) == nil && p.Code != "" {
+			msg += ": " + string(p.Code)
+			if p.Detail != "" {
+				msg += ": " + strings.TrimSpace(p.Detail)
+			}
+		}
+		return errors.New(msg)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"time"
+
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/secrets"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Port        string
+	Environment string
+	// LogLevel overrides the level logged at, debug in development and info
+	// elsewhere.
+	LogLevel string
+	// GinMode, LogEncoding and TraceSampleRatio default to the profile of
+	// Environment, see sharedconfig.Profile.
+	GinMode          string
+	LogEncoding      string
+	TraceSampleRatio float64
+	ServiceName      string
+	JaegerEndpoint   string
+
+	// AdminServices lists the services the dashboard collects, as
+	// comma-separated name=url pairs, e.g.
+	// order-service=http://order-service:8001; see ParseServices.
+	// AdminAuthToken is sent to them as the bearer token, and should match
+	// their METRICS_AUTH_TOKEN when they protect /metrics.
+	AdminServices  string
+	AdminAuthToken string
+	// AdminFetchTimeout bounds each request to a service, so one hanging
+	// service still leaves the others on the dashboard.
+	AdminFetchTimeout time.Duration
+	// AdminRecentLimit is how many failed messages per table and recent
+	// records per list each service reports, unless a request asks for
+	// another limit.
+	AdminRecentLimit int
+	// AdminRefreshInterval is how often the HTML dashboard reloads itself;
+	// zero leaves reloading to the reader.
+	AdminRefreshInterval time.Duration
+
+	// MetricsExemplars attaches trace IDs to the latency histograms and
+	// serves /metrics as OpenMetrics so Prometheus can store them.
+	MetricsExemplars bool
+	// MetricsMaxPaths caps the distinct route labels of the HTTP metrics;
+	// zero leaves them uncapped.
+	MetricsMaxPaths int
+	// MetricsAuthToken and MetricsAuthUsername/Password require a bearer
+	// token or basic auth to scrape /metrics and to open the dashboard;
+	// MetricsAllowedNetworks, a comma-separated list of IPs and CIDR ranges,
+	// limits who may connect. Empty leaves them open.
+	MetricsAuthToken       string
+	MetricsAuthUsername    string
+	MetricsAuthPassword    string
+	MetricsAllowedNetworks string
+
+	// ShutdownTimeout bounds how long in-flight requests may drain on
+	// shutdown before their connections are closed.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout bounds how long a client may take to send the
+	// request headers.
+	ReadHeaderTimeout time.Duration
+
+	// HealthCheckTimeout bounds each check of /health.
+	HealthCheckTimeout time.Duration
+
+	// SecretsCacheTTL is how long secrets behind secret references are
+	// reused before they are fetched again. VaultAddr and VaultToken enable
+	// vault:// references; AWSRegion enables awssm:// ones, fetched from
+	// AWSSecretsManagerEndpoint when set.
+	SecretsCacheTTL           time.Duration
+	VaultAddr                 string
+	VaultToken                string
+	AWSRegion                 string
+	AWSSecretsManagerEndpoint string
+
+	// secretRefs keeps the settings ResolveSecrets resolved, as
+	// configured.
+	secretRefs secrets.Settings
+	// durations keeps the duration settings Load could not parse, for
+	// Validate to report.
+	durations *sharedconfig.DurationReader
+}
+
+// Load reads the configuration with args, the command-line arguments, as
+// the flags. Flags take precedence over environment variables, which take
+// precedence over the config files and then the defaults.
+func Load(args []string) *Config {
+	configFile := sharedconfig.BindFlags(args, false)
+
+	viper.AutomaticEnv()
+
+	viper.SetDefault("PORT", "8005")
+	viper.SetDefault("SERVICE_NAME", "admin-service")
+	viper.SetDefault("ENVIRONMENT", "development")
+	viper.SetDefault("JAEGER_ENDPOINT", "localhost:4318")
+
+	viper.SetDefault("ADMIN_SERVICES", "order-service=http://localhost:8001,warehouse-service=http://localhost:8002,payment-service=http://localhost:8003")
+	viper.SetDefault("ADMIN_FETCH_TIMEOUT", "5s")
+	viper.SetDefault("ADMIN_RECENT_LIMIT", 10)
+	viper.SetDefault("ADMIN_REFRESH_INTERVAL", "30s")
+
+	viper.SetDefault("METRICS_EXEMPLARS", false)
+	viper.SetDefault("METRICS_MAX_PATHS", 200)
+
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("READ_HEADER_TIMEOUT", "10s")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("SECRETS_CACHE_TTL", "5m")
+
+	sharedconfig.ReadConfigFiles(configFile, ".", "./services/admin-service", "../../")
+
+	// The settings that differ between environments default to its profile.
+	profile := sharedconfig.Environment(viper.GetString("ENVIRONMENT")).Profile()
+	viper.SetDefault("GIN_MODE", profile.GinMode)
+	viper.SetDefault("LOG_ENCODING", profile.LogEncoding)
+	viper.SetDefault("TRACE_SAMPLE_RATIO", profile.TraceSampleRatio)
+
+	durations := sharedconfig.NewDurationReader(viper.GetString)
+	cfg := &Config{
+		Port:             viper.GetString("PORT"),
+		Environment:      viper.GetString("ENVIRONMENT"),
+		LogLevel:         viper.GetString("LOG_LEVEL"),
+		GinMode:          viper.GetString("GIN_MODE"),
+		LogEncoding:      viper.GetString("LOG_ENCODING"),
+		TraceSampleRatio: viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		ServiceName:      viper.GetString("SERVICE_NAME"),
+		JaegerEndpoint:   viper.GetString("JAEGER_ENDPOINT"),
+
+		AdminServices:        viper.GetString("ADMIN_SERVICES"),
+		AdminAuthToken:       viper.GetString("ADMIN_AUTH_TOKEN"),
+		AdminFetchTimeout:    durations.Get("ADMIN_FETCH_TIMEOUT"),
+		AdminRecentLimit:     viper.GetInt("ADMIN_RECENT_LIMIT"),
+		AdminRefreshInterval: durations.Get("ADMIN_REFRESH_INTERVAL"),
+
+		MetricsExemplars:       viper.GetBool("METRICS_EXEMPLARS"),
+		MetricsMaxPaths:        viper.GetInt("METRICS_MAX_PATHS"),
+		MetricsAuthToken:       viper.GetString("METRICS_AUTH_TOKEN"),
+		MetricsAuthUsername:    viper.GetString("METRICS_AUTH_USERNAME"),
+		MetricsAuthPassword:    viper.GetString("METRICS_AUTH_PASSWORD"),
+		MetricsAllowedNetworks: viper.GetString("METRICS_ALLOWED_NETWORKS"),
+
+		ShutdownTimeout:   durations.Get("SHUTDOWN_TIMEOUT"),
+		ReadHeaderTimeout: durations.Get("READ_HEADER_TIMEOUT"),
+
+		HealthCheckTimeout: durations.Get("HEALTH_CHECK_TIMEOUT"),
+
+		SecretsCacheTTL:           durations.Get("SECRETS_CACHE_TTL"),
+		VaultAddr:                 viper.GetString("VAULT_ADDR"),
+		VaultToken:                viper.GetString("VAULT_TOKEN"),
+		AWSRegion:                 viper.GetString("AWS_REGION"),
+		AWSSecretsManagerEndpoint: viper.GetString("AWS_ENDPOINT_URL_SECRETS_MANAGER"),
+	}
+	cfg.durations = durations
+	return cfg
+}
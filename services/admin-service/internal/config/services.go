@@ -0,0 +1,40 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Service is a service the dashboard collects.
+type Service struct {
+	Name string
+	URL  string
+}
+
+// ParseServices parses ADMIN_SERVICES: comma-separated name=url pairs, in
+// the order the dashboard lists them. Names must be unique.
+func ParseServices(raw string) ([]Service, error) {
+	var services []Service
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(pair, "=")
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("%q is not a name=url pair", pair)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("service %s is listed twice", name)
+		}
+		seen[name] = true
+		services = append(services, Service{Name: name, URL: strings.TrimSuffix(url, "/")})
+	}
+	if len(services) == 0 {
+		return nil, errors.New("no services listed")
+	}
+	return services, nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/logger"
+	"observability-system/shared/ops"
+)
+
+// Validate checks that the settings the service needs are present, that
+// URLs parse and that numbers are in range. It returns a
+// *sharedconfig.ValidationError listing every problem, or nil.
+func (c *Config) Validate() error {
+	v := sharedconfig.NewValidator(c.durations)
+
+	v.Port("PORT", c.Port)
+	v.Required("SERVICE_NAME", c.ServiceName)
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			v.Addf("LOG_LEVEL: %v", err)
+		}
+	}
+	if _, err := sharedconfig.ParseEnvironment(c.Environment); err != nil {
+		v.Addf("ENVIRONMENT: %v", err)
+	}
+	v.OneOf("GIN_MODE", c.GinMode, "debug", "release", "test")
+	v.OneOf("LOG_ENCODING", c.LogEncoding, "json", "console")
+	v.Fraction("TRACE_SAMPLE_RATIO", c.TraceSampleRatio)
+
+	if v.Required("ADMIN_SERVICES", c.AdminServices) {
+		services, err := ParseServices(c.AdminServices)
+		if err != nil {
+			v.Addf("ADMIN_SERVICES: %v", err)
+		}
+		for _, s := range services {
+			v.URL("ADMIN_SERVICES: "+s.Name, s.URL, "http", "https")
+		}
+	}
+	v.Positive("ADMIN_FETCH_TIMEOUT", c.AdminFetchTimeout)
+	v.AtLeast("ADMIN_RECENT_LIMIT", c.AdminRecentLimit, 1)
+	if c.AdminRecentLimit > ops.MaxLimit {
+		v.Addf("ADMIN_RECENT_LIMIT: must be at most %d, got %d", ops.MaxLimit, c.AdminRecentLimit)
+	}
+	v.NonNegative("ADMIN_REFRESH_INTERVAL", c.AdminRefreshInterval)
+
+	v.AtLeast("METRICS_MAX_PATHS", c.MetricsMaxPaths, 0)
+	if c.MetricsAuthUsername != "" && c.MetricsAuthPassword == "" {
+		v.Addf("METRICS_AUTH_PASSWORD: required with METRICS_AUTH_USERNAME")
+	}
+
+	v.NonNegative("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.Positive("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	v.Positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+
+	return v.Err()
+}
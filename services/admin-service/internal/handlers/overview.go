@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"admin-service/internal/collector"
+	"observability-system/shared/logger"
+	"observability-system/shared/ops"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var overviewTemplate = template.Must(template.New("overview.html").Funcs(template.FuncMap{
+	"age": func(seconds float64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+	"ago": func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return time.Since(*t).Round(time.Second).String() + " ago"
+	},
+	"ts": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	},
+}).ParseFS(templates, "templates/overview.html"))
+
+// OverviewHandler serves the collected overview of the services.
+type OverviewHandler struct {
+	logger    logger.Logger
+	collector *collector.Collector
+	limit     int
+	refresh   time.Duration
+}
+
+// NewOverviewHandler serves the overview with limit failed messages per
+// table and records per list unless a request asks for another limit. The
+// HTML page reloads itself every refresh; zero never reloads it.
+func NewOverviewHandler(log logger.Logger, c *collector.Collector, limit int, refresh time.Duration) *OverviewHandler {
+	return &OverviewHandler{
+		logger:    log,
+		collector: c,
+		limit:     limit,
+		refresh:   refresh,
+	}
+}
+
+// GetOverview returns the overview as JSON.
+func (h *OverviewHandler) GetOverview(c *gin.Context) {
+	limit, ok := h.parseLimit(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.collector.Overview(c.Request.Context(), limit))
+}
+
+// Dashboard renders the overview as an HTML page.
+func (h *OverviewHandler) Dashboard(c *gin.Context) {
+	limit, ok := h.parseLimit(c)
+	if !ok {
+		return
+	}
+	overview := h.collector.Overview(c.Request.Context(), limit)
+
+	var page bytes.Buffer
+	if err := overviewTemplate.Execute(&page, gin.H{
+		"Overview":       overview,
+		"RefreshSeconds": int(h.refresh.Seconds()),
+	}); err != nil {
+		h.logger.ErrorCtx(c.Request.Context(), "Failed to render dashboard", logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to render dashboard"))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+func (h *OverviewHandler) parseLimit(c *gin.Context) (int, bool) {
+	limit := h.limit
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return 0, false
+		}
+		limit = min(v, ops.MaxLimit)
+	}
+	return limit, true
+}
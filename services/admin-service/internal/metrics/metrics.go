@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/secrets"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The admin service's own metrics, registered by InitMetrics.
+var (
+	fetchesTotal  *prometheus.CounterVec
+	fetchDuration prometheus.ObserverVec
+)
+
+// InitMetrics builds the admin service's registry: the shared HTTP metrics
+// of its own endpoints, the requests it sends to the services it collects,
+// and the metrics of Collectors.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...)
+
+	fetchesTotal = registry.Counter("admin_fetches_total",
+		"Total number of requests to the collected services by target service, endpoint and result (success or failure)",
+		"target", "endpoint", "result")
+	fetchDuration = registry.Histogram("admin_fetch_duration_seconds",
+		"Time the requests to the collected services took, by target service and endpoint", prometheus.DefBuckets,
+		"target", "endpoint")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the admin service
+// uses.
+func Collectors() []prometheus.Collector {
+	return secrets.Collectors()
+}
+
+// RecordFetch records a request to a collected service.
+func RecordFetch(ctx context.Context, target, endpoint string, success bool, duration time.Duration) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	fetchesTotal.WithLabelValues(target, endpoint, result).Inc()
+	sharedmetrics.Observe(ctx, fetchDuration.WithLabelValues(target, endpoint), duration.Seconds())
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"admin-service/internal/handlers"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware holds the optional middleware configured in main; nil fields
+// are skipped.
+type Middleware struct {
+	// MetricsAuth guards /metrics and the overview, which shows customer and
+	// order details; nil serves them openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+}
+
+// SetupRoutes serves the admin service's health and metrics, and the
+// overview of the collected services as an HTML dashboard at / and as JSON.
+// /health also reports whether the collected services answer, while /live
+// and /ready concern the admin service alone, so the dashboard stays up to
+// show an outage.
+func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, overviewHandler *handlers.OverviewHandler, configHandler gin.HandlerFunc, prober *health.Prober, registry *metrics.Registry, mw Middleware) {
+
+	router.Use(tracing.GinMiddleware(serviceName))
+
+	router.Use(logger.InjectLogger(log))
+	router.Use(logger.GinMiddleware(log))
+	router.Use(problem.Recovery())
+
+	router.Use(registry.Middleware())
+
+	router.NoRoute(problem.NoRoute)
+
+	router.GET("/health", prober.Health)
+	router.GET("/live", prober.Live)
+	router.GET("/ready", prober.Ready)
+	router.GET("/metrics", chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	if mw.ConfigAuth != nil {
+		router.GET("/internal/config", mw.ConfigAuth, configHandler)
+	}
+
+	router.GET("/", chain(mw.MetricsAuth, overviewHandler.Dashboard)...)
+
+	v1 := router.Group("/api/v1")
+	v1.GET("/overview", chain(mw.MetricsAuth, overviewHandler.GetOverview)...)
+}
+
+// chain drops the middleware that is not configured.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
+	}
+	return chained
+}
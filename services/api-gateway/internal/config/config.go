@@ -0,0 +1,206 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/secrets"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Port        string
+	Environment string
+	// LogLevel overrides the level logged at, debug in development and info
+	// elsewhere.
+	LogLevel string
+	// GinMode, LogEncoding and TraceSampleRatio default to the profile of
+	// Environment, see sharedconfig.Profile.
+	GinMode          string
+	LogEncoding      string
+	TraceSampleRatio float64
+	ServiceName      string
+	JaegerEndpoint   string
+
+	// OrderServiceURL and WarehouseServiceURL are the services requests are
+	// routed to. UpstreamTimeout bounds how long a forwarded request waits
+	// for the upstream to answer; the gateway does not retry, as it cannot
+	// tell which requests are safe to repeat.
+	OrderServiceURL     string
+	WarehouseServiceURL string
+	UpstreamTimeout     time.Duration
+
+	// AuthJWKSURL enables JWT authentication of the routed requests against
+	// the keys published at this URL; empty disables authentication.
+	AuthJWKSURL      string
+	AuthJWKSCacheTTL time.Duration
+	AuthIssuer       string
+	AuthAudience     string
+	// AuthPublicPaths are routed without a token; a trailing "*" matches a
+	// path prefix. The gateway's own endpoints never need one.
+	AuthPublicPaths []string
+
+	// RateLimitRequests limits the routed requests per client as
+	// "rate/burst"; empty disables the limit.
+	RateLimitRequests string
+	// RateLimitRedisAddr shares the limit between instances through Redis;
+	// empty keeps it in memory per instance.
+	RateLimitRedisAddr     string
+	RateLimitRedisPassword string
+	RateLimitRedisDB       int
+	// RateLimitAPIKeyHeader names the header that identifies API clients,
+	// which are limited per key rather than per IP.
+	RateLimitAPIKeyHeader string
+
+	// MetricsExemplars attaches trace IDs to the latency histograms and
+	// serves /metrics as OpenMetrics so Prometheus can store them.
+	MetricsExemplars bool
+	// MetricsMaxPaths caps the distinct route labels of the HTTP metrics;
+	// zero leaves them uncapped.
+	MetricsMaxPaths int
+	// MetricsAuthToken and MetricsAuthUsername/Password require a bearer
+	// token or basic auth to scrape /metrics; MetricsAllowedNetworks, a
+	// comma-separated list of IPs and CIDR ranges, limits who may connect.
+	// Empty leaves /metrics open.
+	MetricsAuthToken       string
+	MetricsAuthUsername    string
+	MetricsAuthPassword    string
+	MetricsAllowedNetworks string
+
+	// ShutdownTimeout bounds how long in-flight requests may drain on
+	// shutdown before their connections are closed.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout bounds how long a client may take to send the
+	// request headers.
+	ReadHeaderTimeout time.Duration
+	// RequestTimeout cancels a request's context after this long; zero
+	// disables it.
+	RequestTimeout time.Duration
+	// MaxBodySize rejects larger request bodies with 413; zero disables it.
+	MaxBodySize int64
+
+	// HealthCheckTimeout bounds each downstream check of /health.
+	HealthCheckTimeout time.Duration
+
+	// SecretsCacheTTL is how long secrets behind secret references are
+	// reused before they are fetched again. VaultAddr and VaultToken enable
+	// vault:// references; AWSRegion enables awssm:// ones, fetched from
+	// AWSSecretsManagerEndpoint when set.
+	SecretsCacheTTL           time.Duration
+	VaultAddr                 string
+	VaultToken                string
+	AWSRegion                 string
+	AWSSecretsManagerEndpoint string
+
+	// secretRefs keeps the settings ResolveSecrets resolved, as
+	// configured.
+	secretRefs secrets.Settings
+	// durations keeps the duration settings Load could not parse, for
+	// Validate to report.
+	durations *sharedconfig.DurationReader
+}
+
+// Load reads the configuration with args, the command-line arguments, as
+// the flags. Flags take precedence over environment variables, which take
+// precedence over the config files and then the defaults.
+func Load(args []string) *Config {
+	configFile := sharedconfig.BindFlags(args, false)
+
+	viper.AutomaticEnv()
+
+	viper.SetDefault("PORT", "8000")
+	viper.SetDefault("SERVICE_NAME", "api-gateway")
+	viper.SetDefault("ENVIRONMENT", "development")
+	viper.SetDefault("JAEGER_ENDPOINT", "localhost:4318")
+
+	viper.SetDefault("ORDER_SERVICE_URL", "http://localhost:8001")
+	viper.SetDefault("WAREHOUSE_SERVICE_URL", "http://localhost:8002")
+	viper.SetDefault("UPSTREAM_TIMEOUT", "30s")
+
+	viper.SetDefault("AUTH_JWKS_CACHE_TTL", "10m")
+
+	viper.SetDefault("RATE_LIMIT_REQUESTS", "50/100")
+	viper.SetDefault("RATE_LIMIT_REDIS_DB", 0)
+	viper.SetDefault("RATE_LIMIT_API_KEY_HEADER", "X-API-Key")
+
+	viper.SetDefault("METRICS_EXEMPLARS", false)
+	viper.SetDefault("METRICS_MAX_PATHS", 200)
+
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("READ_HEADER_TIMEOUT", "10s")
+	viper.SetDefault("REQUEST_TIMEOUT", "30s")
+	viper.SetDefault("MAX_BODY_SIZE", 2<<20)
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("SECRETS_CACHE_TTL", "5m")
+
+	sharedconfig.ReadConfigFiles(configFile, ".", "./services/api-gateway", "../../")
+
+	// The settings that differ between environments default to its profile.
+	profile := sharedconfig.Environment(viper.GetString("ENVIRONMENT")).Profile()
+	viper.SetDefault("GIN_MODE", profile.GinMode)
+	viper.SetDefault("LOG_ENCODING", profile.LogEncoding)
+	viper.SetDefault("TRACE_SAMPLE_RATIO", profile.TraceSampleRatio)
+
+	durations := sharedconfig.NewDurationReader(viper.GetString)
+	cfg := &Config{
+		Port:             viper.GetString("PORT"),
+		Environment:      viper.GetString("ENVIRONMENT"),
+		LogLevel:         viper.GetString("LOG_LEVEL"),
+		GinMode:          viper.GetString("GIN_MODE"),
+		LogEncoding:      viper.GetString("LOG_ENCODING"),
+		TraceSampleRatio: viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		ServiceName:      viper.GetString("SERVICE_NAME"),
+		JaegerEndpoint:   viper.GetString("JAEGER_ENDPOINT"),
+
+		OrderServiceURL:     viper.GetString("ORDER_SERVICE_URL"),
+		WarehouseServiceURL: viper.GetString("WAREHOUSE_SERVICE_URL"),
+		UpstreamTimeout:     durations.Get("UPSTREAM_TIMEOUT"),
+
+		AuthJWKSURL:      viper.GetString("AUTH_JWKS_URL"),
+		AuthJWKSCacheTTL: durations.Get("AUTH_JWKS_CACHE_TTL"),
+		AuthIssuer:       viper.GetString("AUTH_ISSUER"),
+		AuthAudience:     viper.GetString("AUTH_AUDIENCE"),
+		AuthPublicPaths:  splitList(viper.GetString("AUTH_PUBLIC_PATHS")),
+
+		RateLimitRequests:      viper.GetString("RATE_LIMIT_REQUESTS"),
+		RateLimitRedisAddr:     viper.GetString("RATE_LIMIT_REDIS_ADDR"),
+		RateLimitRedisPassword: viper.GetString("RATE_LIMIT_REDIS_PASSWORD"),
+		RateLimitRedisDB:       viper.GetInt("RATE_LIMIT_REDIS_DB"),
+		RateLimitAPIKeyHeader:  viper.GetString("RATE_LIMIT_API_KEY_HEADER"),
+
+		MetricsExemplars:       viper.GetBool("METRICS_EXEMPLARS"),
+		MetricsMaxPaths:        viper.GetInt("METRICS_MAX_PATHS"),
+		MetricsAuthToken:       viper.GetString("METRICS_AUTH_TOKEN"),
+		MetricsAuthUsername:    viper.GetString("METRICS_AUTH_USERNAME"),
+		MetricsAuthPassword:    viper.GetString("METRICS_AUTH_PASSWORD"),
+		MetricsAllowedNetworks: viper.GetString("METRICS_ALLOWED_NETWORKS"),
+
+		ShutdownTimeout:   durations.Get("SHUTDOWN_TIMEOUT"),
+		ReadHeaderTimeout: durations.Get("READ_HEADER_TIMEOUT"),
+		RequestTimeout:    durations.Get("REQUEST_TIMEOUT"),
+		MaxBodySize:       viper.GetInt64("MAX_BODY_SIZE"),
+
+		HealthCheckTimeout: durations.Get("HEALTH_CHECK_TIMEOUT"),
+
+		SecretsCacheTTL:           durations.Get("SECRETS_CACHE_TTL"),
+		VaultAddr:                 viper.GetString("VAULT_ADDR"),
+		VaultToken:                viper.GetString("VAULT_TOKEN"),
+		AWSRegion:                 viper.GetString("AWS_REGION"),
+		AWSSecretsManagerEndpoint: viper.GetString("AWS_ENDPOINT_URL_SECRETS_MANAGER"),
+	}
+	cfg.durations = durations
+	return cfg
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
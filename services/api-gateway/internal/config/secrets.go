@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+
+	"observability-system/shared/secrets"
+)
+
+// secretSettings returns the settings that may be given as secret
+// references, by name.
+func (c *Config) secretSettings() map[string]*string {
+	return map[string]*string{
+		"METRICS_AUTH_TOKEN":        &c.MetricsAuthToken,
+		"METRICS_AUTH_PASSWORD":     &c.MetricsAuthPassword,
+		"RATE_LIMIT_REDIS_PASSWORD": &c.RateLimitRedisPassword,
+	}
+}
+
+// ResolveSecrets replaces the secret references among the settings with
+// the secrets they name, and returns the resolver used. See
+// secrets.Settings.Resolve.
+func (c *Config) ResolveSecrets(ctx context.Context) (*secrets.Resolver, error) {
+	return c.secretRefs.Resolve(ctx, secrets.Config{
+		CacheTTL:    c.SecretsCacheTTL,
+		VaultAddr:   c.VaultAddr,
+		VaultToken:  c.VaultToken,
+		AWSRegion:   c.AWSRegion,
+		AWSEndpoint: c.AWSSecretsManagerEndpoint,
+	}, c.secretSettings())
+}
+
+// Secret returns setting as configured, before ResolveSecrets replaced its
+// secret reference, for resolving it again once the secret rotates. It
+// panics for settings that cannot hold references.
+func (c *Config) Secret(setting string) string {
+	return c.secretRefs.Reference(c.secretSettings(), setting)
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/logger"
+)
+
+// Validate checks that the settings the service needs are present, that
+// URLs parse and that numbers are in range. It returns a
+// *sharedconfig.ValidationError listing every problem, or nil.
+func (c *Config) Validate() error {
+	v := sharedconfig.NewValidator(c.durations)
+
+	v.Port("PORT", c.Port)
+	v.Required("SERVICE_NAME", c.ServiceName)
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			v.Addf("LOG_LEVEL: %v", err)
+		}
+	}
+	if _, err := sharedconfig.ParseEnvironment(c.Environment); err != nil {
+		v.Addf("ENVIRONMENT: %v", err)
+	}
+	v.OneOf("GIN_MODE", c.GinMode, "debug", "release", "test")
+	v.OneOf("LOG_ENCODING", c.LogEncoding, "json", "console")
+	v.Fraction("TRACE_SAMPLE_RATIO", c.TraceSampleRatio)
+
+	v.URL("ORDER_SERVICE_URL", c.OrderServiceURL, "http", "https")
+	v.URL("WAREHOUSE_SERVICE_URL", c.WarehouseServiceURL, "http", "https")
+	v.Positive("UPSTREAM_TIMEOUT", c.UpstreamTimeout)
+	if c.AuthJWKSURL != "" {
+		v.URL("AUTH_JWKS_URL", c.AuthJWKSURL, "http", "https")
+	}
+
+	v.AtLeast("METRICS_MAX_PATHS", c.MetricsMaxPaths, 0)
+	if c.MetricsAuthUsername != "" && c.MetricsAuthPassword == "" {
+		v.Addf("METRICS_AUTH_PASSWORD: required with METRICS_AUTH_USERNAME")
+	}
+	v.AtLeast("RATE_LIMIT_REDIS_DB", c.RateLimitRedisDB, 0)
+
+	v.NonNegative("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.Positive("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	v.NonNegative("REQUEST_TIMEOUT", c.RequestTimeout)
+	if c.MaxBodySize < 0 {
+		v.Addf("MAX_BODY_SIZE: must not be negative, got %d", c.MaxBodySize)
+	}
+	v.Positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+
+	return v.Err()
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ratelimit"
+	"observability-system/shared/secrets"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The gateway's own metrics, registered by InitMetrics.
+var (
+	upstreamRequestsTotal   *prometheus.CounterVec
+	upstreamRequestDuration prometheus.ObserverVec
+)
+
+// InitMetrics builds the gateway's registry: the shared HTTP metrics, which
+// cover requests as clients see them, the upstream metrics, which cover
+// the same requests as the services behind the gateway answered them, and
+// those of Collectors.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...)
+
+	upstreamRequestsTotal = registry.Counter("gateway_upstream_requests_total",
+		"Total number of requests forwarded by upstream, method and status; status is error when the upstream did not answer",
+		"upstream", "method", "status")
+	upstreamRequestDuration = registry.Histogram("gateway_upstream_request_duration_seconds",
+		"Time the upstream took to answer forwarded requests, by upstream and method", nil,
+		"upstream", "method")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the gateway uses.
+func Collectors() []prometheus.Collector {
+	var collectors []prometheus.Collector
+	collectors = append(collectors, ratelimit.Collectors()...)
+	collectors = append(collectors, secrets.Collectors()...)
+	return collectors
+}
+
+// UpstreamError is the status of forwarded requests the upstream did not
+// answer.
+const UpstreamError = "error"
+
+// RecordUpstreamRequest counts a forwarded request by the status the
+// upstream answered with, or UpstreamError, and records how long it took.
+func RecordUpstreamRequest(ctx context.Context, upstream, method, status string, duration time.Duration) {
+	upstreamRequestsTotal.WithLabelValues(upstream, method, status).Inc()
+	sharedmetrics.Observe(ctx, upstreamRequestDuration.WithLabelValues(upstream, method), duration.Seconds())
+}
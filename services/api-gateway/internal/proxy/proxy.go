@@ -0,0 +1,227 @@
+// Package proxy forwards the gateway's requests to the services behind it.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/metrics"
+	"observability-system/shared/httpclient"
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+)
+
+// hopHeaders apply to a single connection, so they are neither forwarded
+// to the upstream nor copied back from its response.
+var hopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// Upstream is a service the gateway forwards requests to.
+type Upstream struct {
+	name    string
+	timeout time.Duration
+	client  *httpclient.Client
+	logger  logger.Logger
+}
+
+// NewUpstream creates the upstream called name at baseURL. Forwarded
+// requests give up when the upstream has not answered within timeout and
+// are never retried, as the gateway cannot tell which of them are safe to
+// repeat. The timeout does not cover the response body, which is streamed
+// to the client for as long as the request's context allows. Redirects are
+// passed on to the client rather than followed.
+func NewUpstream(name, baseURL string, timeout time.Duration, log logger.Logger) *Upstream {
+	cfg := httpclient.DefaultConfig()
+	cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	cfg.Timeout = 0
+	cfg.RetryCount = 0
+	client := httpclient.New(cfg)
+	client.GetRestyClient().SetRedirectPolicy(resty.RedirectPolicyFunc(func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}))
+	if transport, err := client.GetRestyClient().Transport(); err == nil {
+		transport.ResponseHeaderTimeout = timeout
+	}
+
+	return &Upstream{
+		name:    name,
+		timeout: timeout,
+		client:  client,
+		logger:  log,
+	}
+}
+
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// Forward sends the request to the upstream under the same path and query
+// and streams back its response as it arrives. The headers go along, but for hop-by-hop
+// ones, with the request and correlation IDs the gateway assigned, the
+// trace context of the gateway's span and X-Forwarded-* headers naming the
+// client.
+func (u *Upstream) Forward(c *gin.Context) {
+	ctx := c.Request.Context()
+	method := c.Request.Method
+
+	body, err := c.GetRawData()
+	if err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	headers := map[string][]string{}
+	for name, values := range c.Request.Header {
+		if !hopHeaders[name] {
+			headers[name] = values
+		}
+	}
+	headers[logger.RequestIDHeader] = []string{logger.GetRequestIDFromGin(c)}
+	headers[logger.CorrelationIDHeader] = []string{logger.GetCorrelationID(ctx)}
+	headers["X-Forwarded-For"] = []string{forwardedFor(c)}
+	headers["X-Forwarded-Host"] = []string{c.Request.Host}
+	headers["X-Forwarded-Proto"] = []string{forwardedProto(c)}
+
+	target := c.Request.URL.EscapedPath()
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+
+	req := u.client.R(ctx).
+		SetSpanName("HTTP "+method+" "+c.FullPath()).
+		AddSpanAttribute("gateway.upstream", u.name).
+		SetHeaderMultiValues(headers).
+		SetDoNotParseResponse(true)
+	if len(body) > 0 {
+		req.SetBody(body)
+	}
+
+	start := time.Now()
+	resp, err := req.Execute(method, target)
+	elapsed := time.Since(start)
+	if err != nil {
+		metrics.RecordUpstreamRequest(ctx, u.name, method, metrics.UpstreamError, elapsed)
+		u.logger.ErrorCtx(ctx, "Failed to forward request",
+			logger.String("upstream", u.name),
+			logger.String("method", method),
+			logger.String("path", c.Request.URL.Path),
+			logger.Err(err))
+		problem.Write(c, u.failure(err))
+		return
+	}
+	defer resp.RawBody().Close()
+	metrics.RecordUpstreamRequest(ctx, u.name, method, strconv.Itoa(resp.StatusCode()), elapsed)
+
+	for name, values := range resp.Header() {
+		if hopHeaders[name] {
+			continue
+		}
+		for _, v := range values {
+			c.Writer.Header().Add(name, v)
+		}
+	}
+	c.Status(resp.StatusCode())
+	c.Writer.WriteHeaderNow()
+
+	if err := copyFlushing(c.Writer, resp.RawBody()); err != nil {
+		// The status is sent already, so the client only sees the body end
+		// early.
+		u.logger.WarnCtx(ctx, "Failed to stream response",
+			logger.String("upstream", u.name),
+			logger.String("method", method),
+			logger.String("path", c.Request.URL.Path),
+			logger.Err(err))
+	}
+}
+
+// copyFlushing copies body to w, flushing after every read, so responses
+// written in pieces, such as exports and server-sent events, reach the
+// client as the upstream sends them.
+func copyFlushing(w gin.ResponseWriter, body io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			w.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Ping checks that the upstream answers its /health endpoint.
+func (u *Upstream) Ping(ctx context.Context) error {
+	resp, err := u.client.R(ctx).
+		SetSpanName("HTTP GET /health").
+		Get("/health")
+	if err != nil {
+		return fmt.Errorf("%s unreachable: %w", u.name, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("%s health check returned status %d", u.name, resp.StatusCode())
+	}
+	return nil
+}
+
+// failure describes a request the upstream did not answer: 504 when it
+// ran out of time and 502 otherwise.
+func (u *Upstream) failure(err error) *problem.Problem {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return problem.New(http.StatusGatewayTimeout, problem.CodeDependencyUnavailable,
+			fmt.Sprintf("%s did not answer within %s", u.name, u.timeout)).
+			With("upstream", u.name)
+	}
+	return problem.New(http.StatusBadGateway, problem.CodeDependencyUnavailable,
+		fmt.Sprintf("%s is unreachable", u.name)).
+		With("upstream", u.name)
+}
+
+// forwardedFor appends the client's address to the X-Forwarded-For chain
+// the request arrived with.
+func forwardedFor(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	if prior := c.GetHeader("X-Forwarded-For"); prior != "" {
+		return prior + ", " + host
+	}
+	return host
+}
+
+func forwardedProto(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
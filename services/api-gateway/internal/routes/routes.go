@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"strings"
+
+	"api-gateway/internal/proxy"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware holds the optional middleware configured in main; nil fields
+// are skipped.
+type Middleware struct {
+	// Auth and RateLimit apply to every routed request, so the services
+	// behind the gateway need not repeat them.
+	Auth        gin.HandlerFunc
+	RateLimit   gin.HandlerFunc
+	Timeout     gin.HandlerFunc
+	MaxBodySize gin.HandlerFunc
+	// MetricsAuth guards /metrics; nil serves it openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+}
+
+// apiPrefixes are the API versions routed, the deprecated unversioned
+// aliases included; the services themselves decide which they still serve.
+var apiPrefixes = []string{"/api/v1", "/api"}
+
+// warehouseResources are the API resources served by warehouse-service.
+var warehouseResources = []string{"/inventory", "/reservations", "/locations"}
+
+// SetupRoutes routes orders and GraphQL to order-service and inventory,
+// reservations and locations to warehouse-service, under the same paths.
+// /health reports the gateway healthy only when every upstream is, while
+// /live and /ready concern the gateway alone, so an upstream outage does
+// not take the routes to the other one out of rotation.
+func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, orders, warehouse *proxy.Upstream, configHandler gin.HandlerFunc, prober, upstreams *health.Prober, registry *metrics.Registry, mw Middleware) {
+
+	router.Use(tracing.GinMiddleware(serviceName))
+
+	router.Use(logger.InjectLogger(log))
+	router.Use(logger.GinMiddleware(log))
+	router.Use(problem.Recovery())
+
+	router.Use(registry.Middleware())
+	router.Use(chain(mw.Timeout, mw.MaxBodySize)...)
+
+	router.NoRoute(problem.NoRoute)
+
+	router.GET("/health", upstreams.Ready)
+	router.GET("/live", prober.Live)
+	router.GET("/ready", prober.Ready)
+	router.GET("/metrics", chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	if mw.ConfigAuth != nil {
+		router.GET("/internal/config", mw.ConfigAuth, configHandler)
+	}
+
+	routed := router.Group("", chain(mw.Auth, mw.RateLimit)...)
+
+	// An order's reservation is served by warehouse-service.
+	orderRoutes := func(c *gin.Context) {
+		if isReservationPath(c.Param("path")) {
+			warehouse.Forward(c)
+			return
+		}
+		orders.Forward(c)
+	}
+
+	for _, prefix := range apiPrefixes {
+		routed.Any(prefix+"/orders", orders.Forward)
+		routed.Any(prefix+"/orders/*path", orderRoutes)
+		for _, resource := range warehouseResources {
+			routed.Any(prefix+resource, warehouse.Forward)
+			routed.Any(prefix+resource+"/*path", warehouse.Forward)
+		}
+	}
+	routed.Any("/graphql", orders.Forward)
+}
+
+// isReservationPath reports whether path, the part of an order route after
+// /orders, is "/:order_id/reservation".
+func isReservationPath(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	return len(parts) == 2 && parts[0] != "" && parts[1] == "reservation"
+}
+
+// chain drops the middleware that is not configured.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
+	}
+	return chained
+}
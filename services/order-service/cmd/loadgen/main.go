@@ -0,0 +1,331 @@
+// Command loadgen drives a mix of order API traffic against order-service,
+// or the gateway in front of it, to reproduce the latency and error
+// scenarios the dashboards and traces are meant to show. Virtual users
+// follow a ramp profile, each running operations one after another with a
+// think time in between, and every operation starts a trace of its own. For
+// example, ramping to 20 users over a minute, holding them for five minutes
+// and ramping down, with orders mostly created and read:
+//
+//	go run ./cmd/loadgen -stages 1m:20,5m:20,30s:0 -mix create=6,get=3,list=1
+//
+// Combined with chaos mode this shows the faults under load. Interrupting
+// the run stops the users and prints the summary of what ran so far.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// generator runs the users of a load test.
+type generator struct {
+	baseURL     string
+	token       string
+	client      *http.Client
+	tracer      trace.Tracer
+	mix         mix
+	products    []string
+	maxQuantity int
+	customers   int
+	bulkSize    int
+	thinkMin    time.Duration
+	thinkMax    time.Duration
+	orders      *orderPool
+	stats       *stats
+}
+
+func main() {
+	var (
+		baseURL      = flag.String("url", "http://localhost:8001", "base URL of order-service or the API gateway")
+		token        = flag.String("token", os.Getenv("LOADGEN_TOKEN"), "bearer token sent with every request, e.g. for the gateway (default $LOADGEN_TOKEN)")
+		rawStages    = flag.String("stages", "30s:5,2m:5,30s:0", "ramp profile as comma-separated duration:users stages")
+		rawMix       = flag.String("mix", "create=50,get=25,list=10,search=5,bulk=5,cancel=5", "traffic mix as comma-separated operation=weight pairs; operations: "+strings.Join(operations, ", "))
+		thinkMin     = flag.Duration("think-min", 500*time.Millisecond, "shortest pause of a user between operations")
+		thinkMax     = flag.Duration("think-max", 2*time.Second, "longest pause of a user between operations")
+		products     = flag.String("products", "PROD-001,PROD-002,PROD-003,PROD-004,PROD-005", "comma-separated products to order")
+		maxQuantity  = flag.Int("max-quantity", 3, "largest quantity ordered at once")
+		customers    = flag.Int("customers", 50, "number of distinct customers ordering")
+		bulkSize     = flag.Int("bulk-size", 20, "orders fetched by one bulk read")
+		timeout      = flag.Duration("timeout", 10*time.Second, "timeout of each request")
+		otlpEndpoint = flag.String("otlp-endpoint", envOr("JAEGER_ENDPOINT", "localhost:4318"), "OTLP HTTP endpoint the loadgen spans are exported to; empty starts no traces (default $JAEGER_ENDPOINT or localhost:4318)")
+		sampleRatio  = flag.Float64("sample-ratio", 1, "fraction of operations whose trace is sampled, and so recorded by the services")
+		samples      = flag.Int("samples", 10, "slowest and failed requests listed in the summary")
+		jsonOut      = flag.String("json", "", "file to also write the summary to as JSON")
+	)
+	flag.Parse()
+
+	stages, err := parseStages(*rawStages)
+	if err != nil {
+		fail(err)
+	}
+	trafficMix, err := parseMix(*rawMix)
+	if err != nil {
+		fail(err)
+	}
+	productIDs := splitList(*products)
+	switch {
+	case len(productIDs) == 0:
+		fail(errors.New("-products must name at least one product"))
+	case *maxQuantity < 1:
+		fail(errors.New("-max-quantity must be at least 1"))
+	case *customers < 1:
+		fail(errors.New("-customers must be at least 1"))
+	case *bulkSize < 1 || *bulkSize > 500:
+		fail(errors.New("-bulk-size must be between 1 and 500"))
+	case *thinkMin < 0 || *thinkMax < *thinkMin:
+		fail(errors.New("think times must satisfy 0 <= -think-min <= -think-max"))
+	case *sampleRatio < 0 || *sampleRatio > 1:
+		fail(errors.New("-sample-ratio must be between 0 and 1"))
+	}
+
+	if *otlpEndpoint != "" {
+		if err := tracing.InitTracer(tracing.Config{
+			ServiceName:    "loadgen",
+			ServiceVersion: "1.0.0",
+			Environment:    "loadgen",
+			JaegerEndpoint: *otlpEndpoint,
+			SampleRatio:    *sampleRatio,
+		}); err != nil {
+			fail(fmt.Errorf("initialize tracing: %w", err))
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracing.ShutdownTracer(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "loadgen: flush traces: %v\n", err)
+			}
+		}()
+	}
+
+	g := &generator{
+		baseURL:     strings.TrimSuffix(*baseURL, "/"),
+		token:       *token,
+		client:      &http.Client{Timeout: *timeout},
+		tracer:      tracing.GetTracer("loadgen"),
+		mix:         trafficMix,
+		products:    productIDs,
+		maxQuantity: *maxQuantity,
+		customers:   *customers,
+		bulkSize:    *bulkSize,
+		thinkMin:    *thinkMin,
+		thinkMax:    *thinkMax,
+		orders:      newOrderPool(1000),
+		stats:       newStats(*samples),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "loadgen: running against %s with up to %d users\n", g.baseURL, peakUsers(stages))
+	g.run(ctx, stages)
+
+	sum := g.stats.summary()
+	sum.write(os.Stdout)
+	if *jsonOut != "" {
+		body, err := json.MarshalIndent(sum, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*jsonOut, append(body, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "loadgen: write summary: %v\n", err)
+		}
+	}
+}
+
+// run starts and stops users as the stages ramp, until the profile is over
+// or ctx is cancelled, then waits for the users to finish.
+func (g *generator) run(ctx context.Context, stages []stage) {
+	var wg sync.WaitGroup
+	var users []chan struct{}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	progress := time.NewTicker(10 * time.Second)
+	defer progress.Stop()
+
+	start := time.Now()
+	for {
+		want, ok := usersAt(stages, time.Since(start))
+		if !ok || ctx.Err() != nil {
+			want = 0
+		}
+		for len(users) < want {
+			stop := make(chan struct{})
+			users = append(users, stop)
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				g.user(ctx, id, stop)
+			}(len(users))
+		}
+		for len(users) > want {
+			close(users[len(users)-1])
+			users = users[:len(users)-1]
+		}
+		g.stats.setUsers(len(users))
+		if !ok || ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-progress.C:
+			g.stats.progress(os.Stderr)
+		case <-ticker.C:
+		}
+	}
+	wg.Wait()
+}
+
+// user runs operations until stop is closed or ctx is cancelled. A stopped
+// user finishes its current operation first.
+func (g *generator) user(ctx context.Context, id int, stop <-chan struct{}) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(id)))
+	for {
+		g.do(ctx, id, g.newCall(g.mix.pick(r), r))
+
+		think := g.thinkMin
+		if spread := g.thinkMax - g.thinkMin; spread > 0 {
+			think += time.Duration(r.Int63n(int64(spread) + 1))
+		}
+		timer := time.NewTimer(think)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// do sends c in a trace of its own and records the outcome. Requests cut
+// short by an interrupt are not recorded.
+func (g *generator) do(ctx context.Context, user int, c call) {
+	ctx, span := g.tracer.Start(ctx, "loadgen "+c.op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("loadgen.operation", c.op),
+			attribute.Int("loadgen.user", user),
+			attribute.String("http.method", c.method),
+			attribute.String("http.url", g.baseURL+c.path),
+		))
+	defer span.End()
+
+	res := result{Op: c.op}
+	if span.SpanContext().IsValid() {
+		res.TraceID = span.SpanContext().TraceID().String()
+	}
+
+	start := time.Now()
+	status, body, err := g.send(ctx, c)
+	res.Latency = time.Since(start)
+	if ctx.Err() != nil {
+		return
+	}
+
+	res.Status = status
+	switch {
+	case err != nil:
+		res.Detail = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case status >= 400:
+		var p problem.Problem
+		if json.Unmarshal(body, &p) == nil {
+			res.Detail = string(p.Code)
+			if res.TraceID == "" {
+				res.TraceID = p.TraceID
+			}
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+	case c.created != nil:
+		c.created(body)
+	}
+	if status != 0 {
+		span.SetAttributes(attribute.Int("http.status_code", status))
+	}
+	g.stats.record(res)
+}
+
+// send returns the status and body of c's response. A response whose body
+// cannot be read counts as none.
+func (g *generator) send(ctx context.Context, c call) (int, []byte, error) {
+	var body io.Reader
+	if c.body != nil {
+		raw, err := json.Marshal(c.body)
+		if err != nil {
+			return 0, nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.method, g.baseURL+c.path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	tracing.InjectTraceContext(ctx, req)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"order-service/internal/handlers"
+	"order-service/internal/models"
+)
+
+// Operations of the traffic mix.
+const (
+	opCreate = "create"
+	opGet    = "get"
+	opList   = "list"
+	opSearch = "search"
+	opBulk   = "bulk"
+	opCancel = "cancel"
+)
+
+var operations = []string{opCreate, opGet, opList, opSearch, opBulk, opCancel}
+
+// mix picks operations at random in proportion to their weights.
+type mix struct {
+	ops     []string
+	weights []int
+	total   int
+}
+
+// parseMix parses comma-separated "operation=weight" pairs, e.g.
+// "create=6,get=3,list=1".
+func parseMix(s string) (mix, error) {
+	var m mix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op, rawWeight, ok := strings.Cut(part, "=")
+		if !ok {
+			return mix{}, fmt.Errorf("mix entry %q: want operation=weight", part)
+		}
+		if !isOperation(op) {
+			return mix{}, fmt.Errorf("mix entry %q: unknown operation, want one of %s", part, strings.Join(operations, ", "))
+		}
+		weight, err := strconv.Atoi(rawWeight)
+		if err != nil || weight < 0 {
+			return mix{}, fmt.Errorf("mix entry %q: invalid weight", part)
+		}
+		m.ops = append(m.ops, op)
+		m.weights = append(m.weights, weight)
+		m.total += weight
+	}
+	if m.total == 0 {
+		return mix{}, fmt.Errorf("mix %q: at least one operation needs a positive weight", s)
+	}
+	return m, nil
+}
+
+func isOperation(op string) bool {
+	for _, known := range operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (m mix) pick(r *rand.Rand) string {
+	n := r.Intn(m.total)
+	for i, weight := range m.weights {
+		if n < weight {
+			return m.ops[i]
+		}
+		n -= weight
+	}
+	return m.ops[len(m.ops)-1]
+}
+
+// orderPool remembers the most recently created orders, for the operations
+// reading or cancelling existing ones.
+type orderPool struct {
+	mu   sync.Mutex
+	ids  []string
+	next int
+}
+
+func newOrderPool(size int) *orderPool {
+	return &orderPool{ids: make([]string, 0, size)}
+}
+
+func (p *orderPool) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) < cap(p.ids) {
+		p.ids = append(p.ids, id)
+		return
+	}
+	p.ids[p.next] = id
+	p.next = (p.next + 1) % len(p.ids)
+}
+
+// sample returns up to n distinct orders.
+func (p *orderPool) sample(r *rand.Rand, n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n = min(n, len(p.ids))
+	ids := make([]string, 0, n)
+	for _, i := range r.Perm(len(p.ids))[:n] {
+		ids = append(ids, p.ids[i])
+	}
+	return ids
+}
+
+// take removes and returns an order, so it is not cancelled twice.
+func (p *orderPool) take(r *rand.Rand) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return "", false
+	}
+	i := r.Intn(len(p.ids))
+	id := p.ids[i]
+	last := len(p.ids) - 1
+	p.ids[i] = p.ids[last]
+	p.ids = p.ids[:last]
+	p.next = 0
+	return id, true
+}
+
+// call is one request of an operation.
+type call struct {
+	op     string
+	method string
+	path   string
+	body   interface{}
+	// created handles the response body of a successful create.
+	created func(body []byte)
+}
+
+// newCall builds a request for op. Operations on existing orders create one
+// instead while none is known yet.
+func (g *generator) newCall(op string, r *rand.Rand) call {
+	switch op {
+	case opGet:
+		if ids := g.orders.sample(r, 1); len(ids) == 1 {
+			return call{op: op, method: http.MethodGet, path: "/api/v1/orders/" + url.PathEscape(ids[0])}
+		}
+	case opList:
+		query := url.Values{"limit": {"20"}, "sort": {"desc"}}
+		if r.Intn(2) == 0 {
+			query.Set("status", models.OrderStatusConfirmed)
+		}
+		return call{op: op, method: http.MethodGet, path: "/api/v1/orders?" + query.Encode()}
+	case opSearch:
+		query := url.Values{"q": {g.customer(r)}, "limit": {"20"}}
+		return call{op: op, method: http.MethodGet, path: "/api/v1/orders/search?" + query.Encode()}
+	case opBulk:
+		if ids := g.orders.sample(r, g.bulkSize); len(ids) > 0 {
+			query := url.Values{"order_id": {strings.Join(ids, ",")}, "limit": {strconv.Itoa(len(ids))}}
+			return call{op: op, method: http.MethodGet, path: "/api/v1/orders?" + query.Encode()}
+		}
+	case opCancel:
+		if id, ok := g.orders.take(r); ok {
+			return call{op: op, method: http.MethodDelete, path: "/api/v1/orders/" + url.PathEscape(id)}
+		}
+	}
+
+	unitPrice := math.Round((5+r.Float64()*495)*100) / 100
+	return call{
+		op:     opCreate,
+		method: http.MethodPost,
+		path:   "/api/v1/orders",
+		body: handlers.CreateOrderRequest{
+			ProductID:  g.products[r.Intn(len(g.products))],
+			Quantity:   1 + r.Intn(g.maxQuantity),
+			CustomerID: g.customer(r),
+			UnitPrice:  unitPrice,
+		},
+		created: func(body []byte) {
+			var resp struct {
+				Order models.Order `json:"order"`
+			}
+			if json.Unmarshal(body, &resp) == nil && resp.Order.ID != "" {
+				g.orders.add(resp.Order.ID)
+			}
+		},
+	}
+}
+
+func (g *generator) customer(r *rand.Rand) string {
+	return fmt.Sprintf("loadgen-%03d", r.Intn(g.customers))
+}
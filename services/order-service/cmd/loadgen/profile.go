@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stage ramps the number of users linearly to target over duration.
+type stage struct {
+	duration time.Duration
+	target   int
+}
+
+// parseStages parses comma-separated "duration:users" stages, e.g.
+// "30s:10,2m:10,30s:0" ramps to 10 users, holds them for two minutes and
+// ramps down. A stage of 0s jumps to its users at once.
+func parseStages(s string) ([]stage, error) {
+	var stages []stage
+	var total time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rawDuration, rawUsers, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("stage %q: want duration:users", part)
+		}
+		duration, err := time.ParseDuration(rawDuration)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("stage %q: invalid duration", part)
+		}
+		users, err := strconv.Atoi(rawUsers)
+		if err != nil || users < 0 {
+			return nil, fmt.Errorf("stage %q: invalid number of users", part)
+		}
+		stages = append(stages, stage{duration: duration, target: users})
+		total += duration
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("stages %q: the profile must last longer than 0s", s)
+	}
+	return stages, nil
+}
+
+// usersAt returns how many users run elapsed after the start, ramping up
+// from none. ok is false once the last stage is over.
+func usersAt(stages []stage, elapsed time.Duration) (users int, ok bool) {
+	from := 0
+	for _, st := range stages {
+		if elapsed < st.duration {
+			progress := float64(elapsed) / float64(st.duration)
+			return from + int(math.Round(progress*float64(st.target-from))), true
+		}
+		elapsed -= st.duration
+		from = st.target
+	}
+	return from, false
+}
+
+// peakUsers returns the most users any stage ramps to.
+func peakUsers(stages []stage) int {
+	peak := 0
+	for _, st := range stages {
+		peak = max(peak, st.target)
+	}
+	return peak
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// result is the outcome of one request. Status is 0 when no response
+// arrived.
+type result struct {
+	Op      string
+	Status  int
+	Latency time.Duration
+	TraceID string
+	// Detail is the problem code of an error response, or the transport
+	// error.
+	Detail string
+}
+
+// failed reports whether the request counts as an error: no response or a
+// 5xx. Rejections such as 404 or 409 are part of the traffic and only show
+// in the status counts.
+func (r result) failed() bool {
+	return r.Status == 0 || r.Status >= 500
+}
+
+type opStats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    int
+}
+
+// stats collects the results of a run.
+type stats struct {
+	mu       sync.Mutex
+	start    time.Time
+	ops      map[string]*opStats
+	users    int
+	maxUsers int
+	// samples caps the slowest and failed requests kept for the summary.
+	samples  int
+	slowest  []result
+	failures []result
+
+	// The window holds the results since the last progress line.
+	window       []time.Duration
+	windowErrors int
+	windowStart  time.Time
+}
+
+func newStats(samples int) *stats {
+	now := time.Now()
+	return &stats{
+		start:       now,
+		ops:         make(map[string]*opStats),
+		samples:     samples,
+		windowStart: now,
+	}
+}
+
+func (s *stats) setUsers(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = n
+	s.maxUsers = max(s.maxUsers, n)
+}
+
+func (s *stats) record(res result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.ops[res.Op]
+	if !ok {
+		op = &opStats{statuses: make(map[int]int)}
+		s.ops[res.Op] = op
+	}
+	op.latencies = append(op.latencies, res.Latency)
+	op.statuses[res.Status]++
+	s.window = append(s.window, res.Latency)
+	if res.failed() {
+		op.errors++
+		s.windowErrors++
+		if len(s.failures) < s.samples {
+			s.failures = append(s.failures, res)
+		}
+	}
+
+	if s.samples == 0 {
+		return
+	}
+	if len(s.slowest) == s.samples && res.Latency <= s.slowest[len(s.slowest)-1].Latency {
+		return
+	}
+	i := sort.Search(len(s.slowest), func(i int) bool { return s.slowest[i].Latency < res.Latency })
+	s.slowest = append(s.slowest, result{})
+	copy(s.slowest[i+1:], s.slowest[i:])
+	s.slowest[i] = res
+	if len(s.slowest) > s.samples {
+		s.slowest = s.slowest[:s.samples]
+	}
+}
+
+// progress writes one line about the requests since the previous line and
+// starts a new window.
+func (s *stats) progress(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(s.windowStart).Seconds()
+	sort.Slice(s.window, func(i, j int) bool { return s.window[i] < s.window[j] })
+	fmt.Fprintf(w, "[%s] users=%d rate=%.1f/s errors=%d p50=%s p95=%s\n",
+		now.Sub(s.start).Round(time.Second), s.users,
+		float64(len(s.window))/elapsed, s.windowErrors,
+		percentile(s.window, 0.50), percentile(s.window, 0.95))
+
+	s.window = s.window[:0]
+	s.windowErrors = 0
+	s.windowStart = now
+}
+
+// summary is the report of a run, written as a table or as JSON.
+type summary struct {
+	Duration   string      `json:"duration"`
+	MaxUsers   int         `json:"max_users"`
+	Requests   int         `json:"requests"`
+	Errors     int         `json:"errors"`
+	Rate       float64     `json:"requests_per_second"`
+	Operations []opSummary `json:"operations"`
+	Total      opSummary   `json:"total"`
+	Slowest    []sample    `json:"slowest"`
+	Failures   []sample    `json:"failures"`
+}
+
+type opSummary struct {
+	Operation string         `json:"operation"`
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	Rate      float64        `json:"requests_per_second"`
+	P50Ms     float64        `json:"p50_ms"`
+	P90Ms     float64        `json:"p90_ms"`
+	P99Ms     float64        `json:"p99_ms"`
+	MaxMs     float64        `json:"max_ms"`
+	Statuses  map[string]int `json:"statuses"`
+}
+
+type sample struct {
+	Operation string  `json:"operation"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	TraceID   string  `json:"trace_id,omitempty"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+func (s *stats) summary() summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start)
+	sum := summary{
+		Duration: elapsed.Round(time.Millisecond).String(),
+		MaxUsers: s.maxUsers,
+	}
+
+	var all []time.Duration
+	var errors int
+	statuses := make(map[int]int)
+	for _, name := range operations {
+		op, ok := s.ops[name]
+		if !ok {
+			continue
+		}
+		all = append(all, op.latencies...)
+		errors += op.errors
+		for status, n := range op.statuses {
+			statuses[status] += n
+		}
+		sum.Operations = append(sum.Operations, summarize(name, op.latencies, op.errors, op.statuses, elapsed))
+	}
+	sum.Total = summarize("total", all, errors, statuses, elapsed)
+	sum.Requests = sum.Total.Requests
+	sum.Errors = sum.Total.Errors
+	sum.Rate = sum.Total.Rate
+
+	for _, res := range s.slowest {
+		sum.Slowest = append(sum.Slowest, newSample(res))
+	}
+	for _, res := range s.failures {
+		sum.Failures = append(sum.Failures, newSample(res))
+	}
+	return sum
+}
+
+func summarize(name string, latencies []time.Duration, errors int, statuses map[int]int, elapsed time.Duration) opSummary {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	labelled := make(map[string]int, len(statuses))
+	for status, n := range statuses {
+		labelled[statusLabel(status)] = n
+	}
+	return opSummary{
+		Operation: name,
+		Requests:  len(sorted),
+		Errors:    errors,
+		Rate:      float64(len(sorted)) / elapsed.Seconds(),
+		P50Ms:     ms(percentile(sorted, 0.50)),
+		P90Ms:     ms(percentile(sorted, 0.90)),
+		P99Ms:     ms(percentile(sorted, 0.99)),
+		MaxMs:     ms(percentile(sorted, 1)),
+		Statuses:  labelled,
+	}
+}
+
+func newSample(res result) sample {
+	return sample{
+		Operation: res.Op,
+		Status:    res.Status,
+		LatencyMs: ms(res.Latency),
+		TraceID:   res.TraceID,
+		Detail:    res.Detail,
+	}
+}
+
+// write prints the summary as tables of operations and of the slowest and
+// failed requests, whose trace IDs can be looked up in Jaeger.
+func (sum summary) write(w io.Writer) {
+	errorRate := 0.0
+	if sum.Requests > 0 {
+		errorRate = float64(sum.Errors) / float64(sum.Requests) * 100
+	}
+	fmt.Fprintf(w, "\nRan %s with up to %d users: %d requests, %.1f/s, %d errors (%.2f%%)\n\n",
+		sum.Duration, sum.MaxUsers, sum.Requests, sum.Rate, sum.Errors, errorRate)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tREQUESTS\tRATE/S\tERRORS\tP50\tP90\tP99\tMAX\tSTATUSES")
+	for _, op := range append(sum.Operations, sum.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%.0fms\t%.0fms\t%.0fms\t%.0fms\t%s\n",
+			op.Operation, op.Requests, op.Rate, op.Errors, op.P50Ms, op.P90Ms, op.P99Ms, op.MaxMs, formatStatuses(op.Statuses))
+	}
+	tw.Flush()
+
+	for _, list := range []struct {
+		title   string
+		samples []sample
+	}{
+		{"Slowest requests", sum.Slowest},
+		{"Failed requests", sum.Failures},
+	} {
+		if len(list.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", list.title)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, s := range list.samples {
+			fmt.Fprintf(tw, "  %s\t%s\t%.0fms\t%s\t%s\n", s.Operation, statusLabel(s.Status), s.LatencyMs, s.TraceID, s.Detail)
+		}
+		tw.Flush()
+	}
+}
+
+// formatStatuses lists status counts in status order, e.g. "201:95 503:5".
+func formatStatuses(statuses map[string]int) string {
+	labels := make([]string, 0, len(statuses))
+	for label := range statuses {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = label + ":" + strconv.Itoa(statuses[label])
+	}
+	return strings.Join(parts, " ")
+}
+
+// statusLabel names a status, "err" for requests without a response.
+func statusLabel(status int) string {
+	if status == 0 {
+		return "err"
+	}
+	return strconv.Itoa(status)
+}
+
+// percentile returns the p-th percentile of sorted latencies, 0 for none.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
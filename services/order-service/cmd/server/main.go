@@ -2,31 +2,67 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"observability-system/shared/apiversion"
+	"observability-system/shared/auth"
+	"observability-system/shared/cache"
+	"observability-system/shared/chaos"
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/health"
+	"observability-system/shared/httplimit"
+	"observability-system/shared/lock"
 	"observability-system/shared/logger"
 	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/ratelimit"
+	"observability-system/shared/redisclient"
+	"observability-system/shared/secrets"
 	"observability-system/shared/tracing"
 	"order-service/internal/clients"
 	"order-service/internal/config"
 	"order-service/internal/database"
 	"order-service/internal/handlers"
-	"order-service/internal/inbox"
 	"order-service/internal/metrics"
-	"order-service/internal/outbox"
+	"order-service/internal/models"
 	"order-service/internal/routes"
+	"order-service/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg := config.Load(os.Args[1:])
+	secretResolver, err := cfg.ResolveSecrets(context.Background())
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		// Reported before the logger exists, which needs a valid config.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	log, err := logger.NewDefaultLogger(cfg.ServiceName, cfg.Environment)
+	logLevel := logger.DefaultLevel(cfg.Environment)
+	if cfg.LogLevel != "" {
+		// Validate rejected unknown levels.
+		logLevel, _ = logger.ParseLevel(cfg.LogLevel)
+	}
+	log, err := logger.NewZapLogger(logger.Config{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Level:       logLevel,
+		Encoding:    cfg.LogEncoding,
+	})
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
@@ -38,11 +74,15 @@ func main() {
 		logger.String("warehouse_url", cfg.WarehouseServiceURL),
 		logger.String("jaeger_endpoint", cfg.JaegerEndpoint))
 
+	settings := sharedconfig.Redact(cfg)
+	log.Info("Effective configuration", logger.Any("config", settings))
+
 	tracingCfg := tracing.Config{
 		ServiceName:    cfg.ServiceName,
 		ServiceVersion: "1.0.0",
 		Environment:    cfg.Environment,
 		JaegerEndpoint: cfg.JaegerEndpoint,
+		SampleRatio:    cfg.TraceSampleRatio,
 	}
 
 	if err := tracing.InitTracer(tracingCfg); err != nil {
@@ -60,10 +100,67 @@ func main() {
 	log.Info("Tracer initialized successfully")
 
 	// Initialize Prometheus metrics
-	metrics.InitMetrics(cfg.ServiceName)
+	sharedmetrics.EnableExemplars(cfg.MetricsExemplars)
+	metricsBuckets, err := sharedmetrics.ParseBuckets(cfg.MetricsBuckets)
+	if err != nil {
+		log.Fatal("Invalid METRICS_BUCKETS", logger.Err(err))
+	}
+	nativeHistograms, err := sharedmetrics.ParseNativeHistograms(cfg.MetricsNativeHistograms)
+	if err != nil {
+		log.Fatal("Invalid METRICS_NATIVE_HISTOGRAMS", logger.Err(err))
+	}
+	metricsSummaries, err := sharedmetrics.ParseSummaries(cfg.MetricsSummaries)
+	if err != nil {
+		log.Fatal("Invalid METRICS_SUMMARIES", logger.Err(err))
+	}
+	metricsRegistry := metrics.InitMetrics(sharedmetrics.Config{
+		Service:          cfg.ServiceName,
+		Buckets:          metricsBuckets,
+		MaxPaths:         cfg.MetricsMaxPaths,
+		NativeHistograms: nativeHistograms,
+		Summaries:        metricsSummaries,
+	})
 	log.Info("Metrics initialized successfully")
 
-	db, err := database.NewConnection(cfg.DatabaseURL)
+	var metricsExporter *sharedmetrics.OTLPExporter
+	switch cfg.MetricsBackend {
+	case sharedmetrics.BackendPrometheus:
+	case sharedmetrics.BackendOTLP:
+		otlpCfg := sharedmetrics.DefaultOTLPConfig()
+		otlpCfg.Endpoint = cfg.OTLPMetricsEndpoint
+		otlpCfg.ServiceVersion = tracingCfg.ServiceVersion
+		otlpCfg.Environment = cfg.Environment
+		otlpCfg.Interval = cfg.MetricsExportInterval
+		metricsExporter, err = metricsRegistry.NewOTLPExporter(otlpCfg, log)
+		if err != nil {
+			log.Fatal("Failed to create OTLP metrics exporter", logger.Err(err))
+		}
+		go metricsExporter.Start(context.Background())
+	default:
+		log.Fatal("Invalid METRICS_BACKEND", logger.String("backend", cfg.MetricsBackend))
+	}
+
+	// Without the debug endpoints chaos can only be configured at startup,
+	// so with it disabled there is nothing to inject.
+	var chaosInjector *chaos.Injector
+	if cfg.ChaosEnabled || cfg.DebugEndpoints {
+		chaosInjector, err = chaos.New(log, chaos.Uniform(cfg.ChaosEnabled, cfg.ChaosTargets, chaos.Rule{
+			MinLatencyMs: int(cfg.ChaosMinLatency.Milliseconds()),
+			MaxLatencyMs: int(cfg.ChaosMaxLatency.Milliseconds()),
+			ErrorRate:    cfg.ChaosErrorRate,
+			TimeoutRate:  cfg.ChaosTimeoutRate,
+			TimeoutMs:    int(cfg.ChaosTimeout.Milliseconds()),
+			DropRate:     cfg.ChaosDropRate,
+		}))
+		if err != nil {
+			log.Fatal("Invalid chaos configuration", logger.Err(err))
+		}
+		if cfg.ChaosEnabled {
+			log.Warn("Chaos mode enabled", logger.Any("rules", chaosInjector.Config().Rules))
+		}
+	}
+
+	db, err := database.NewConnection(secretResolver.Func(cfg.Secret("DATABASE_URL")), cfg.DBConnMaxLifetime, chaosInjector)
 	if err != nil {
 		log.Fatal("Failed to connect to database",
 			logger.Err(err))
@@ -82,13 +179,15 @@ func main() {
 	var rabbitMQClient *rabbitmq.Client
 	if cfg.EnableBroker {
 		var err error
-		rabbitMQClient, err = rabbitmq.NewClient(cfg.RabbitMQURL)
+		rabbitMQClient, err = rabbitmq.NewClientWithURL(secretResolver.Func(cfg.Secret("RABBITMQ_URL")))
 		if err != nil {
 			log.Fatal("Failed to connect to RabbitMQ",
 				logger.Err(err))
 		}
 		defer rabbitMQClient.Close()
 
+		rabbitMQClient.SetCompressionThreshold(cfg.PayloadCompressThreshold)
+
 		log.Info("Connected to RabbitMQ successfully")
 
 		if err := rabbitmq.SetupExchangesAndQueues(rabbitMQClient); err != nil {
@@ -102,56 +201,413 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	inboxStore := inbox.NewInboxStore(db)
-	outboxStore := outbox.NewOutboxStore(db)
+	inboxCfg := outboxinbox.DefaultInboxConfig()
 
-	warehouseClient := clients.NewWarehouseClient(cfg.WarehouseServiceURL, log)
+	// Existing order-service rows use PROCESSED as the published status.
+	outboxCfg := outboxinbox.DefaultOutboxConfig()
+	outboxCfg.Statuses.Completed = "PROCESSED"
 
-	inboxHandler := handlers.NewInboxHandler(log, inboxStore)
-	orderHandler := handlers.NewOrderHandler(log, warehouseClient, outboxStore)
+	// Order events for the same order must be handled in the order they arrive.
+	inboxCfg.PartitionKeyField = "order_id"
+	outboxCfg.PartitionKeyField = "order_id"
 
-	if cfg.Environment == "production" {
-		gin.SetMode(gin.ReleaseMode)
+	inboxCfg.LockTimeout = cfg.LockTimeout
+	outboxCfg.LockTimeout = cfg.LockTimeout
+
+	inboxCfg.CompressThreshold = cfg.PayloadCompressThreshold
+	outboxCfg.CompressThreshold = cfg.PayloadCompressThreshold
+	inboxCfg.MaxPayloadSize = cfg.MaxPayloadSize
+	outboxCfg.MaxPayloadSize = cfg.MaxPayloadSize
+
+	outboxCfg.PartitionPeriod, err = outboxinbox.ParsePartitionPeriod(cfg.OutboxPartitionPeriod)
+	if err != nil {
+		log.Fatal("Invalid OUTBOX_PARTITION_PERIOD", logger.Err(err))
 	}
-	router := gin.New()
 
-	routes.SetupRoutes(router, log, cfg.ServiceName, inboxHandler, orderHandler)
+	if cfg.RetentionArchive {
+		inboxCfg.ArchiveTableName = "inbox_archive"
+		outboxCfg.ArchiveTableName = "outbox_archive"
+	}
 
-	log.Info("Routes configured")
+	if !cfg.ListenNotify {
+		inboxCfg.NotifyChannel = ""
+		outboxCfg.NotifyChannel = ""
+	}
+
+	inboxStore := outboxinbox.NewInboxStore(db, inboxCfg)
+	outboxStore := outboxinbox.NewOutboxStore(db, outboxCfg)
+
+	if err := inboxStore.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize inbox schema", logger.Err(err))
+	}
+	if err := outboxStore.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize outbox schema", logger.Err(err))
+	}
+
+	warehouseClient := clients.NewWarehouseClient(cfg.WarehouseServiceURL, cfg.WarehouseClientTimeout, log)
+
+	var cacheRedis *redisclient.Client
+	if cfg.StockCacheTTL > 0 {
+		stockCacheCfg := cache.Config{
+			Name:     "warehouse_stock",
+			TTL:      cfg.StockCacheTTL,
+			LocalTTL: cfg.StockCacheLocalTTL,
+		}
+		if cfg.CacheRedisAddr != "" {
+			cacheRedis = redisclient.New(redisclient.Config{
+				Addr:     cfg.CacheRedisAddr,
+				Password: cfg.CacheRedisPassword,
+				DB:       cfg.CacheRedisDB,
+			})
+			stockCacheCfg.Redis = cacheRedis
+		}
+		warehouseClient.SetStockCache(cache.New(stockCacheCfg))
+		log.Info("Warehouse stock cache enabled",
+			logger.String("ttl", cfg.StockCacheTTL.String()),
+			logger.Bool("redis", cacheRedis != nil))
+	}
+
+	warehouseClient.SetChaos(chaosInjector)
+
+	var paymentClient *clients.PaymentClient
+	if cfg.PaymentServiceURL != "" {
+		paymentClient = clients.NewPaymentClient(cfg.PaymentServiceURL, cfg.PaymentClientTimeout, log)
+		paymentClient.SetChaos(chaosInjector)
+	} else {
+		log.Info("PAYMENT_SERVICE_URL not set, orders are created without payment authorization")
+	}
 
 	log.Info("Initializing message handler registry")
-	registry := handlers.NewMessageHandlerRegistry(log)
+	registry := handlers.NewMessageHandlerRegistry(log, cfg.MaxRetries)
 
 	orderEvents := handlers.NewOrderEventHandler(log)
 	registry.Register("order.created", orderEvents.HandleOrderCreated)
 	registry.Register("order.updated", orderEvents.HandleOrderUpdated)
 	registry.Register("order.cancelled", orderEvents.HandleOrderCancelled)
 
+	awaitsInventoryEvents := cfg.AsyncOrderConfirmation || cfg.EventDrivenReservation
+
+	orderService := services.NewOrderService(db, outboxStore)
+	if awaitsInventoryEvents {
+		inventoryEvents := handlers.NewInventoryEventHandler(log, orderService, warehouseClient, paymentClient)
+		registry.Register(models.EventInventoryReserved, inventoryEvents.HandleInventoryReserved)
+		if cfg.EventDrivenReservation {
+			registry.Register(models.EventInventoryRejected, inventoryEvents.HandleInventoryRejected)
+		}
+	}
+
 	log.Info("Message handlers registered",
 		logger.Int("handler_count", len(registry.ListRegisteredHandlers())))
 
 	messageHandler := registry.GetHandler()
 
-	log.Info("Starting inbox workers",
-		logger.Int("count", 3),
-		logger.Int("max_retries", cfg.MaxRetries))
-	inboxWorkers := make([]*inbox.InboxWorker, 3)
-	for i := 0; i < 3; i++ {
-		worker := inbox.NewInboxWorker(inboxStore, messageHandler, log, 3, 5*time.Second, cfg.MaxRetries)
-		inboxWorkers[i] = worker
-		go worker.Start(ctx)
+	inboxHandler := handlers.NewInboxHandler(log, inboxStore)
+	outboxHandler := handlers.NewOutboxHandler(log, outboxStore)
+	orderHandler := handlers.NewOrderHandler(log, warehouseClient, paymentClient, orderService, outboxStore)
+	orderHandler.SetDegradedMode(cfg.DegradedOrderAcceptance)
+	orderHandler.SetAsyncConfirmation(cfg.AsyncOrderConfirmation)
+	orderHandler.SetEventDrivenReservation(cfg.EventDrivenReservation)
+	graphqlHandler := handlers.NewGraphQLHandler(log, warehouseClient, orderService)
+	chaosHandler := chaos.NewHandler(log, chaosInjector)
+	adminHandler := handlers.NewAdminHandler(log, inboxStore, outboxStore,
+		outboxinbox.NewInboxSimulator(inboxStore, messageHandler),
+		outboxinbox.NewOutboxSimulator(outboxStore))
+
+	gin.SetMode(cfg.GinMode)
+	router := gin.New()
+
+	// Each registered event type is consumed from the queue of the same name.
+	if cfg.EnableBroker {
+		bridge := outboxinbox.NewInboxBridge(rabbitMQClient, inboxStore, log, "rabbitmq")
+		if err := bridge.Start(registry.ListRegisteredHandlers()...); err != nil {
+			log.Fatal("Failed to start inbox bridge", logger.Err(err))
+		}
+	}
+
+	retryBackoff := outboxinbox.BackoffPolicy{
+		Base:       cfg.RetryBackoffBase,
+		Multiplier: cfg.RetryBackoffMultiplier,
+		Max:        cfg.RetryBackoffMax,
+		Jitter:     cfg.RetryBackoffJitter,
+	}
 
-		log.Info("Inbox worker started", logger.Int("worker_number", i+1))
+	var inboxNotifier, outboxNotifier *outboxinbox.Notifier
+	if cfg.ListenNotify {
+		inboxNotifier = outboxinbox.NewNotifier(cfg.DatabaseURL, inboxCfg.NotifyChannel, log)
+		if cfg.EnableBroker {
+			outboxNotifier = outboxinbox.NewNotifier(cfg.DatabaseURL, outboxCfg.NotifyChannel, log)
+		}
 	}
 
-	log.Info("Starting outbox workers", logger.Int("count", 3))
-	outboxWorkers := make([]*outbox.OutboxWorker, 3)
-	for i := 0; i < 3; i++ {
-		worker := outbox.NewOutboxWorker(outboxStore, rabbitMQClient, log, 3, 5*time.Second)
-		outboxWorkers[i] = worker
-		go worker.Start(ctx)
+	eventLimits, err := outboxinbox.ParseEventLimits(cfg.EventLimits)
+	if err != nil {
+		log.Fatal("Invalid EVENT_LIMITS", logger.Err(err))
+	}
+	eventLimiter := outboxinbox.NewEventLimiter(eventLimits)
+
+	inboxPool := outboxinbox.NewWorkerPool("inbox", log, cfg.InboxWorkers, func() outboxinbox.Worker {
+		worker := outboxinbox.NewInboxWorker(inboxStore, messageHandler, log, cfg.BatchSize, cfg.PollInterval, cfg.MaxRetries, retryBackoff)
+		worker.SetLimiter(eventLimiter)
+		worker.SetMaxIdleInterval(cfg.MaxIdlePollInterval)
+		if inboxNotifier != nil {
+			worker.SetWakeup(inboxNotifier.Subscribe())
+		}
+		return worker
+	})
+	inboxPool.Start(ctx)
+
+	// Without a broker there is nowhere to publish, so outbox rows stay
+	// PENDING until a broker-enabled instance picks them up.
+	var outboxPool *outboxinbox.WorkerPool
+	var outboxElector *lock.Elector
+	if cfg.EnableBroker {
+		outboxPool = outboxinbox.NewWorkerPool("outbox", log, cfg.OutboxWorkers, func() outboxinbox.Worker {
+			worker := outboxinbox.NewOutboxWorker(outboxStore, rabbitMQClient, log, cfg.BatchSize, cfg.PollInterval, cfg.OutboxMaxRetries, retryBackoff)
+			worker.SetMaxIdleInterval(cfg.MaxIdlePollInterval)
+			if outboxNotifier != nil {
+				worker.SetWakeup(outboxNotifier.Subscribe())
+			}
+			return worker
+		})
+		if cfg.OutboxLeaderElection {
+			// Only the elected replica publishes, so replicas do not race
+			// for the same rows and polling load stays that of one instance.
+			electionLocker := lock.New(db.DB, log)
+			electionLocker.SetRenewInterval(cfg.JobLockRenewInterval)
+			outboxElector = lock.NewElector(electionLocker, outboxinbox.DispatcherElection, log, cfg.OutboxLeaderRetryInterval)
+			go outboxElector.Start(ctx, outboxPool.Run)
+		} else {
+			outboxPool.Start(ctx)
+		}
+	} else {
+		log.Warn("Broker disabled, outbox workers not started")
+	}
+
+	workerHandler := handlers.NewWorkerHandler(log,
+		handlers.WorkerGroup{Pool: inboxPool, Store: inboxStore},
+		handlers.WorkerGroup{Pool: outboxPool, Store: outboxStore},
+	)
+	opsReporter := ops.NewReporter(cfg.ServiceName, log)
+	opsReporter.AddTable(inboxPool, inboxStore)
+	opsReporter.AddTable(outboxPool, outboxStore)
+	opsReporter.AddRecent("orders", handlers.RecentOrders(orderService))
+
+	prober := health.NewProber(cfg.ServiceName, log, cfg.HealthCheckTimeout)
+	prober.SetCacheTTL(cfg.HealthCacheTTL)
+	if cfg.HealthMinFreeDisk > 0 {
+		prober.AddDiagnostic("disk", health.DiskSpace(cfg.HealthDiskPath, cfg.HealthMinFreeDisk))
+	}
+	if cfg.HealthMaxGoroutines > 0 {
+		prober.AddDiagnostic("goroutines", health.Goroutines(cfg.HealthMaxGoroutines))
+	}
+	prober.AddLiveness("inbox_workers", health.Workers(inboxPool, cfg.HealthWorkerStallAfter))
+	prober.AddReadiness("database", health.Database(db))
+	prober.AddReadiness("warehouse", health.Check(warehouseClient.Ping))
+	if cfg.PaymentServiceURL != "" {
+		// Orders are still taken while payments fail, they end up
+		// payment_failed, so the payment service only degrades /health.
+		prober.AddDiagnostic("payment_service", health.HTTP(nil, strings.TrimSuffix(cfg.PaymentServiceURL, "/")+"/ready"))
+	}
+	if cfg.EnableBroker {
+		prober.AddLiveness("outbox_workers", health.Workers(outboxPool, cfg.HealthWorkerStallAfter))
+		prober.AddReadiness("rabbitmq", health.Broker(rabbitMQClient))
+	}
 
-		log.Info("Outbox worker started", logger.Int("worker_number", i+1))
+	var mw routes.Middleware
+	if cfg.AuthJWKSURL != "" {
+		verifier := auth.NewVerifier(auth.Config{
+			JWKSURL:      cfg.AuthJWKSURL,
+			JWKSCacheTTL: cfg.AuthJWKSCacheTTL,
+			Issuer:       cfg.AuthIssuer,
+			Audience:     cfg.AuthAudience,
+		})
+		mw.Auth = auth.Middleware(verifier, log, cfg.AuthPublicPaths)
+		log.Info("JWT authentication enabled",
+			logger.String("jwks_url", cfg.AuthJWKSURL),
+			logger.Any("public_paths", cfg.AuthPublicPaths))
+	} else {
+		log.Warn("AUTH_JWKS_URL not set, API routes are not authenticated")
+	}
+
+	orderLimit, err := ratelimit.ParseLimit(cfg.RateLimitOrders)
+	if err != nil {
+		log.Fatal("Invalid RATE_LIMIT_ORDERS", logger.Err(err))
+	}
+	inboxLimit, err := ratelimit.ParseLimit(cfg.RateLimitInbox)
+	if err != nil {
+		log.Fatal("Invalid RATE_LIMIT_INBOX", logger.Err(err))
+	}
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	var redisStore *ratelimit.RedisStore
+	if cfg.RateLimitRedisAddr != "" {
+		redisStore = ratelimit.NewRedisStore(ratelimit.RedisConfig{
+			Addr:     cfg.RateLimitRedisAddr,
+			Password: cfg.RateLimitRedisPassword,
+			DB:       cfg.RateLimitRedisDB,
+		})
+		rateLimitStore = redisStore
+	}
+	if orderLimit.Enabled() {
+		mw.OrderRateLimit = ratelimit.Middleware("create_order", rateLimitStore, orderLimit, log, cfg.RateLimitAPIKeyHeader)
+	}
+	if inboxLimit.Enabled() {
+		mw.InboxRateLimit = ratelimit.Middleware("create_inbox", rateLimitStore, inboxLimit, log, cfg.RateLimitAPIKeyHeader)
+	}
+	inboxSecrets, err := auth.ParseSecrets(cfg.InboxSenderSecrets)
+	if err != nil {
+		log.Fatal("Invalid INBOX_SENDER_SECRETS", logger.Err(err))
+	}
+	if len(inboxSecrets) > 0 {
+		verifier := auth.NewSignatureVerifier(inboxSecrets, cfg.InboxSignatureWindow)
+		mw.InboxSignature = verifier.Middleware(log)
+		log.Info("Inbox signature verification enabled",
+			logger.Int("senders", len(inboxSecrets)))
+		if ref := cfg.Secret("INBOX_SENDER_SECRETS"); secrets.IsReference(ref) {
+			go secretResolver.Watch(ctx, ref, log, func(raw string) {
+				rotated, err := auth.ParseSecrets(raw)
+				if err != nil || len(rotated) == 0 {
+					log.Error("Ignoring rotated INBOX_SENDER_SECRETS", logger.Err(err))
+					return
+				}
+				verifier.SetSecrets(rotated)
+				log.Info("Inbox sender secrets rotated", logger.Int("senders", len(rotated)))
+			})
+		}
+	} else {
+		log.Warn("INBOX_SENDER_SECRETS not set, POST /api/inbox accepts unsigned messages")
+	}
+
+	log.Info("Rate limiting configured",
+		logger.String("orders", cfg.RateLimitOrders),
+		logger.String("inbox", cfg.RateLimitInbox),
+		logger.Bool("redis", redisStore != nil))
+
+	timeoutRoutes, err := httplimit.ParseRouteTimeouts(cfg.RequestTimeoutRoutes)
+	if err != nil {
+		log.Fatal("Invalid REQUEST_TIMEOUT_ROUTES", logger.Err(err))
+	}
+	bodySizeRoutes, err := httplimit.ParseRouteSizes(cfg.MaxBodySizeRoutes)
+	if err != nil {
+		log.Fatal("Invalid MAX_BODY_SIZE_ROUTES", logger.Err(err))
+	}
+	mw.Timeout = httplimit.Timeout(cfg.RequestTimeout, timeoutRoutes)
+	mw.MaxBodySize = httplimit.MaxBodySize(cfg.MaxBodySize, bodySizeRoutes)
+	metricsNetworks, err := sharedmetrics.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatal("Invalid METRICS_ALLOWED_NETWORKS", logger.Err(err))
+	}
+	mw.MetricsAuth = sharedmetrics.AuthMiddleware(sharedmetrics.AuthConfig{
+		BearerToken:     cfg.MetricsAuthToken,
+		Username:        cfg.MetricsAuthUsername,
+		Password:        cfg.MetricsAuthPassword,
+		AllowedNetworks: metricsNetworks,
+	}, log)
+	if mw.MetricsAuth != nil {
+		log.Info("Metrics endpoint protected",
+			logger.Bool("bearer_token", cfg.MetricsAuthToken != ""),
+			logger.Bool("basic_auth", cfg.MetricsAuthUsername != ""),
+			logger.String("allowed_networks", cfg.MetricsAllowedNetworks))
+	}
+	// /internal/config shares the /metrics credentials, but is left out
+	// rather than served openly when there are none.
+	mw.ConfigAuth = mw.MetricsAuth
+	if mw.ConfigAuth == nil {
+		log.Info("GET /internal/config disabled, set METRICS_AUTH_TOKEN, METRICS_AUTH_USERNAME or METRICS_ALLOWED_NETWORKS to enable it")
+	}
+	if cfg.UnversionedAPI {
+		var sunset time.Time
+		if cfg.UnversionedAPISunset != "" {
+			if sunset, err = time.Parse(time.RFC3339, cfg.UnversionedAPISunset); err != nil {
+				log.Fatal("Invalid UNVERSIONED_API_SUNSET", logger.Err(err))
+			}
+		}
+		mw.Deprecated = apiversion.Deprecated(apiversion.Deprecation{
+			Prefix:    "/api",
+			Successor: "/api/v1",
+			Sunset:    sunset,
+		})
+	}
+	if chaosInjector != nil {
+		mw.Chaos = chaosInjector.Middleware()
+	}
+
+	routes.SetupRoutes(router, log, cfg.ServiceName, inboxHandler, outboxHandler, orderHandler, adminHandler, workerHandler, opsReporter, graphqlHandler, chaosHandler, cfg.DebugEndpoints, sharedconfig.Handler(cfg.ServiceName, settings), prober, metricsRegistry, mw)
+
+	log.Info("Routes configured")
+
+	for _, notifier := range []*outboxinbox.Notifier{inboxNotifier, outboxNotifier} {
+		if notifier == nil {
+			continue
+		}
+		go func(n *outboxinbox.Notifier) {
+			if err := n.Start(ctx); err != nil {
+				log.Error("Message notifier stopped, falling back to polling", logger.Err(err))
+			}
+		}(notifier)
+	}
+
+	// Replicas share the database, so the jobs below that work on it take
+	// an advisory lock per run; a nil locker runs them unguarded.
+	var locker *lock.Locker
+	if cfg.JobLocks {
+		locker = lock.New(db.DB, log)
+		locker.SetRenewInterval(cfg.JobLockRenewInterval)
+	}
+
+	statsCollector := outboxinbox.NewStatsCollector(log, cfg.StatsInterval, inboxStore, outboxStore)
+	go statsCollector.Start(ctx)
+
+	var reaper *outboxinbox.Reaper
+	if cfg.ReaperInterval > 0 {
+		reaper = outboxinbox.NewReaper(log, cfg.ReaperInterval, cfg.LockTimeout, inboxStore, outboxStore)
+		reaper.SetLocker(locker)
+		go reaper.Start(ctx)
+	}
+
+	var expirer *services.ReservationExpirer
+	if cfg.ReservationTTL > 0 || awaitsInventoryEvents {
+		expirer = services.NewReservationExpirer(log, orderService, warehouseClient,
+			cfg.ReservationExpiryInterval, cfg.ReservationTTL, cfg.ReservationExpiryBatchSize)
+		if awaitsInventoryEvents {
+			expirer.SetConfirmationTimeout(cfg.AsyncConfirmationTimeout)
+		}
+		expirer.SetLocker(locker)
+		go expirer.Start(ctx)
+	}
+
+	// The reconciler runs even with degraded acceptance off, so orders
+	// accepted before it was turned off are still resolved.
+	var reconciler *services.StockReconciler
+	if cfg.StockReconcileInterval > 0 {
+		reconciler = services.NewStockReconciler(log, orderService, warehouseClient, paymentClient,
+			cfg.StockReconcileInterval, cfg.StockCheckMaxWait, cfg.StockReconcileBatchSize)
+		reconciler.SetLocker(locker)
+		go reconciler.Start(ctx)
+	}
+
+	var archiver *services.OrderArchiver
+	if cfg.OrderRetentionPeriod > 0 {
+		archiver = services.NewOrderArchiver(log, orderService, cfg.OrderArchiveInterval,
+			cfg.OrderRetentionPeriod, cfg.OrderArchiveBatchSize, cfg.OrderArchive)
+		archiver.SetLocker(locker)
+		go archiver.Start(ctx)
+	}
+
+	// Partitions are created on their own schedule: inserts fail once the
+	// created ones run out, whatever the janitor is doing.
+	var partitionKeeper *outboxinbox.PartitionKeeper
+	if outboxCfg.PartitionPeriod != "" {
+		partitionKeeper = outboxinbox.NewPartitionKeeper(log, outboxinbox.DefaultPartitionInterval, outboxStore)
+		partitionKeeper.SetLocker(locker)
+		go partitionKeeper.Start(ctx)
+	}
+
+	var janitor *outboxinbox.Janitor
+	if cfg.RetentionPeriod > 0 {
+		janitor = outboxinbox.NewJanitor(log, cfg.RetentionInterval, cfg.RetentionPeriod, cfg.RetentionBatchSize, inboxStore, outboxStore)
+		janitor.SetLocker(locker)
+		go janitor.Start(ctx)
 	}
 
 	sigChan := make(chan os.Signal, 1)
@@ -161,31 +617,77 @@ func main() {
 	log.Info("Server starting",
 		logger.String("address", addr))
 
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+
 	go func() {
-		if err := router.Run(addr); err != nil {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal("Failed to start server",
 				logger.Err(err))
 		}
 	}()
 
 	<-sigChan
-	log.Info("Shutdown signal received, initiating graceful shutdown")
+	log.Info("Shutdown signal received, initiating graceful shutdown",
+		logger.Duration("timeout", cfg.ShutdownTimeout))
+
+	// Stop accepting connections and let in-flight requests finish before the
+	// workers and broker they may depend on go away.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP server did not drain in time, closing remaining connections", logger.Err(err))
+		server.Close()
+	} else {
+		log.Info("HTTP server stopped")
+	}
+	cancelShutdown()
 
 	cancel()
 
-	log.Info("Stopping inbox workers")
-	for i, worker := range inboxWorkers {
-		worker.Stop()
-		log.Info("Inbox worker stopped", logger.Int("worker_number", i+1))
+	inboxPool.Stop()
+	if outboxElector != nil {
+		outboxElector.Stop()
+	}
+	if outboxPool != nil {
+		outboxPool.Stop()
 	}
 
-	log.Info("Stopping outbox workers")
-	for i, worker := range outboxWorkers {
-		worker.Stop()
-		log.Info("Outbox worker stopped", logger.Int("worker_number", i+1))
+	statsCollector.Stop()
+
+	if reaper != nil {
+		reaper.Stop()
+	}
+
+	if expirer != nil {
+		expirer.Stop()
+	}
+
+	if reconciler != nil {
+		reconciler.Stop()
+	}
+
+	if archiver != nil {
+		archiver.Stop()
+	}
+
+	if partitionKeeper != nil {
+		partitionKeeper.Stop()
+	}
+
+	if janitor != nil {
+		janitor.Stop()
+		log.Info("Retention janitor stopped")
 	}
 
-	time.Sleep(2 * time.Second)
+	if redisStore != nil {
+		redisStore.Close()
+	}
+	if cacheRedis != nil {
+		cacheRedis.Close()
+	}
 
 	if cfg.EnableBroker {
 		if err := rabbitMQClient.Close(); err != nil {
@@ -195,5 +697,13 @@ func main() {
 		}
 	}
 
+	if metricsExporter != nil {
+		exportCtx, cancelExport := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := metricsExporter.Stop(exportCtx); err != nil {
+			log.Error("Error exporting final metrics", logger.Err(err))
+		}
+		cancelExport()
+	}
+
 	log.Info("Service shutdown complete")
 }
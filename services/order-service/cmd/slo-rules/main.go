@@ -0,0 +1,35 @@
+// Command slo-rules prints the Prometheus recording and burn-rate alert
+// rules for the SLOs of order-service. Regenerate the checked-in rules with:
+//
+//	go run ./cmd/slo-rules -o ../../infrastructure/prometheus-rules/order-service-slo.yml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	sharedmetrics "observability-system/shared/metrics"
+	"order-service/internal/metrics"
+)
+
+func main() {
+	out := flag.String("o", "", "file to write the rules to (default stdout)")
+	flag.Parse()
+
+	rules, err := sharedmetrics.SLORules("order-service", metrics.SLOs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "slo-rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(rules)
+	} else {
+		err = os.WriteFile(*out, rules, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "slo-rules: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,218 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"observability-system/shared/chaos"
+	"observability-system/shared/httpclient"
+	"observability-system/shared/logger"
+	"observability-system/shared/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Chaos targets of the payment calls.
+const (
+	ChaosAuthorizePayment = "payment.authorize"
+	ChaosCapturePayment   = "payment.capture"
+	ChaosRefundPayment    = "payment.refund"
+)
+
+// ErrPaymentDeclined is returned when the payment service refuses to
+// authorize a payment. Retrying the same request will not succeed.
+var ErrPaymentDeclined = errors.New("payment declined")
+
+type PaymentRequest struct {
+	OrderID    string `json:"order_id"`
+	CustomerID string `json:"customer_id,omitempty"`
+	ProductID  string `json:"product_id"`
+	Quantity   int    `json:"quantity"`
+	// Amount is the order total to authorize, in Currency.
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+type PaymentAuthorization struct {
+	PaymentID string `json:"payment_id"`
+	OrderID   string `json:"order_id"`
+	Status    string `json:"status"`
+}
+
+type refundRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type PaymentClient struct {
+	client *httpclient.Client
+	logger logger.Logger
+	chaos  *chaos.Injector
+}
+
+// NewPaymentClient retries failed authorizations, including 5xx responses.
+// Every attempt carries the order ID as its idempotency key, so a retry can
+// never authorize an order twice. Each attempt gives up after timeout.
+func NewPaymentClient(baseURL string, timeout time.Duration, log logger.Logger) *PaymentClient {
+	cfg := httpclient.DefaultConfig()
+	cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	cfg.Timeout = timeout
+	cfg.RetryOnServerError = true
+
+	return &PaymentClient{
+		client: httpclient.New(cfg),
+		logger: log,
+	}
+}
+
+// SetChaos injects the faults injector has configured for the Chaos*
+// payment targets; nil turns injection off.
+func (c *PaymentClient) SetChaos(injector *chaos.Injector) {
+	c.chaos = injector
+}
+
+func (c *PaymentClient) Authorize(ctx context.Context, req PaymentRequest) (*PaymentAuthorization, error) {
+	url := "/api/v1/payments/authorize"
+
+	c.logger.InfoCtx(ctx, "Authorizing payment",
+		logger.String("order_id", req.OrderID),
+		logger.String("product_id", req.ProductID),
+		logger.Int("quantity", req.Quantity),
+		logger.String("amount", fmt.Sprintf("%.2f %s", req.Amount, req.Currency)))
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("payment.operation", "authorize"),
+		attribute.String("order.id", req.OrderID),
+		attribute.Float64("payment.amount", req.Amount),
+		attribute.String("payment.currency", req.Currency),
+	)
+
+	if err := c.chaos.Inject(ctx, ChaosAuthorizePayment); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call payment service",
+			logger.Err(err),
+			logger.String("order_id", req.OrderID))
+		return nil, fmt.Errorf("payment service call failed: %w", err)
+	}
+
+	var auth PaymentAuthorization
+	var failure struct {
+		Error  string `json:"error"`
+		Detail string `json:"detail"`
+		Reason string `json:"reason"`
+	}
+	resp, err := c.client.R(ctx).
+		SetSpanName("HTTP POST /api/v1/payments/authorize").
+		AddSpanAttribute("order.id", req.OrderID).
+		SetHeader("Idempotency-Key", req.OrderID).
+		SetBody(req).
+		SetResult(&auth).
+		SetError(&failure).
+		Post(url)
+
+	if err == nil {
+		err = c.chaos.Drop(ctx, ChaosAuthorizePayment)
+	}
+	if err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call payment service",
+			logger.Err(err),
+			logger.String("order_id", req.OrderID))
+		return nil, fmt.Errorf("payment service call failed: %w", err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusPaymentRequired, http.StatusUnprocessableEntity:
+		reason := failure.Reason
+		if reason == "" {
+			reason = failure.Detail
+		}
+		if reason == "" {
+			reason = failure.Error
+		}
+		c.logger.WarnCtx(ctx, "Payment declined",
+			logger.String("order_id", req.OrderID),
+			logger.String("reason", reason))
+		return nil, fmt.Errorf("%w: %s", ErrPaymentDeclined, reason)
+	default:
+		c.logger.WarnCtx(ctx, "Payment service returned non-OK status",
+			logger.Int("status_code", resp.StatusCode()),
+			logger.String("order_id", req.OrderID))
+		return nil, fmt.Errorf("payment service error: status %d", resp.StatusCode())
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("payment.id", auth.PaymentID),
+		attribute.String("payment.status", auth.Status),
+	)
+
+	c.logger.InfoCtx(ctx, "Payment authorized",
+		logger.String("order_id", req.OrderID),
+		logger.String("payment_id", auth.PaymentID))
+
+	return &auth, nil
+}
+
+// Capture settles the authorized payment of a confirmed order. Capturing a
+// captured payment succeeds again.
+func (c *PaymentClient) Capture(ctx context.Context, paymentID string) (*PaymentAuthorization, error) {
+	return c.settle(ctx, "capture", ChaosCapturePayment, paymentID, nil)
+}
+
+// Refund returns a payment whose order could not be completed. Refunding a
+// refunded payment succeeds again.
+func (c *PaymentClient) Refund(ctx context.Context, paymentID, reason string) (*PaymentAuthorization, error) {
+	return c.settle(ctx, "refund", ChaosRefundPayment, paymentID, refundRequest{Reason: reason})
+}
+
+// settle runs operation, capture or refund, on a payment. Both are
+// idempotent, so failed attempts are retried like authorizations.
+// chaosTarget is the operation's chaos target.
+func (c *PaymentClient) settle(ctx context.Context, operation, chaosTarget, paymentID string, body interface{}) (*PaymentAuthorization, error) {
+	url := "/api/v1/payments/" + paymentID + "/" + operation
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("payment.operation", operation),
+		attribute.String("payment.id", paymentID),
+	)
+
+	if err := c.chaos.Inject(ctx, chaosTarget); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call payment service",
+			logger.Err(err),
+			logger.String("operation", operation),
+			logger.String("payment_id", paymentID))
+		return nil, fmt.Errorf("payment service call failed: %w", err)
+	}
+
+	var payment PaymentAuthorization
+	req := c.client.R(ctx).
+		SetSpanName("HTTP POST /api/v1/payments/:payment_id/"+operation).
+		AddSpanAttribute("payment.id", paymentID).
+		SetResult(&payment)
+	if body != nil {
+		req.SetBody(body)
+	}
+	resp, err := req.Post(url)
+	if err == nil {
+		err = c.chaos.Drop(ctx, chaosTarget)
+	}
+	if err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call payment service",
+			logger.Err(err),
+			logger.String("operation", operation),
+			logger.String("payment_id", paymentID))
+		return nil, fmt.Errorf("payment service call failed: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		c.logger.WarnCtx(ctx, "Payment service returned non-OK status",
+			logger.Int("status_code", resp.StatusCode()),
+			logger.String("operation", operation),
+			logger.String("payment_id", paymentID))
+		return nil, fmt.Errorf("payment %s failed: status %d", operation, resp.StatusCode())
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.String("payment.status", payment.Status))
+	return &payment, nil
+}
@@ -2,47 +2,119 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"observability-system/shared/cache"
+	"observability-system/shared/chaos"
 	"observability-system/shared/httpclient"
 	"observability-system/shared/logger"
+	"observability-system/shared/problem"
 	"observability-system/shared/tracing"
 
 	"go.opentelemetry.io/otel/attribute"
 )
 
+// Chaos targets of the warehouse calls.
+const (
+	ChaosCheckStock      = "warehouse.check_stock"
+	ChaosCheckStockBatch = "warehouse.check_stock_batch"
+	ChaosReserveStock    = "warehouse.reserve_stock"
+	ChaosReleaseStock    = "warehouse.release_stock"
+)
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
 type StockInfo struct {
 	ProductID string `json:"product_id"`
 	Name      string `json:"name"`
 	Quantity  int    `json:"quantity"`
 	Reserved  int    `json:"reserved"`
 	Available int    `json:"available"`
+	// Cached is set when CheckStock served the stock from its cache, in
+	// which case Available may be stale.
+	Cached bool `json:"-"`
+}
+
+// StockCheckResult is one product of a batch stock check. Found is false for
+// products warehouse-service does not know.
+type StockCheckResult struct {
+	StockInfo
+	Found      bool `json:"found"`
+	Requested  int  `json:"requested,omitempty"`
+	Sufficient bool `json:"sufficient"`
 }
 
 type ReservationResult struct {
 	Message          string `json:"message"`
+	ReservationID    string `json:"reservation_id"`
 	ProductID        string `json:"product_id"`
 	ReservedQuantity int    `json:"reserved_quantity"`
 	NewAvailable     int    `json:"new_available"`
+	// EventID is the inventory.reserved event announcing the reservation;
+	// empty unless it was announced.
+	EventID string `json:"event_id,omitempty"`
+}
+
+type ReleaseResult struct {
+	Message          string `json:"message"`
+	ProductID        string `json:"product_id"`
+	ReleasedQuantity int    `json:"released_quantity"`
+	NewAvailable     int    `json:"new_available"`
 }
 
 type WarehouseClient struct {
 	client *httpclient.Client
 	logger logger.Logger
+	chaos  *chaos.Injector
+	stock  *cache.Cache
 }
 
-func NewWarehouseClient(baseURL string, log logger.Logger) *WarehouseClient {
+// NewWarehouseClient creates a client of the warehouse-service at baseURL
+// whose calls give up after timeout per attempt.
+func NewWarehouseClient(baseURL string, timeout time.Duration, log logger.Logger) *WarehouseClient {
 	return &WarehouseClient{
-		client: httpclient.NewWithBaseURL(strings.TrimSuffix(baseURL, "/"), 30*time.Second),
+		client: httpclient.NewWithBaseURL(strings.TrimSuffix(baseURL, "/"), timeout),
 		logger: log,
 	}
 }
 
+// SetChaos injects the faults injector has configured for the Chaos*
+// targets into stock calls; nil turns injection off. Ping is never
+// affected, so chaos does not take the instance out of rotation.
+func (c *WarehouseClient) SetChaos(injector *chaos.Injector) {
+	c.chaos = injector
+}
+
+// SetStockCache serves CheckStock from stock, keyed by product. The entry
+// of a product is dropped whenever this instance reserves or releases its
+// stock; changes made elsewhere only show once the entry expires. Cached
+// results are marked Cached, and callers must not turn an order down on
+// their Available: ReserveStock decides whether stock is left.
+func (c *WarehouseClient) SetStockCache(stock *cache.Cache) {
+	c.stock = stock
+}
+
 func (c *WarehouseClient) CheckStock(ctx context.Context, productID string) (*StockInfo, error) {
-	url := fmt.Sprintf("/api/inventory/%s", productID)
+	loaded := false
+	stockInfo, err := cache.Fetch(ctx, c.stock, productID, func(ctx context.Context) (*StockInfo, error) {
+		loaded = true
+		return c.checkStock(ctx, productID)
+	})
+	if err == nil && !loaded {
+		stockInfo.Cached = true
+	}
+	return stockInfo, err
+}
+
+func (c *WarehouseClient) checkStock(ctx context.Context, productID string) (*StockInfo, error) {
+	url := fmt.Sprintf("/api/v1/inventory/%s", productID)
 
 	c.logger.InfoCtx(ctx, "Checking stock from warehouse service",
 		logger.String("product_id", productID),
@@ -53,13 +125,25 @@ func (c *WarehouseClient) CheckStock(ctx context.Context, productID string) (*St
 		attribute.String("product.id", productID),
 	)
 
+	if err := c.chaos.Inject(ctx, ChaosCheckStock); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service",
+			logger.Err(err),
+			logger.String("product_id", productID))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
+
 	var stockInfo StockInfo
+	var failure problem.Problem
 	resp, err := c.client.R(ctx).
-		SetSpanName("HTTP GET /api/inventory/:product_id").
+		SetSpanName("HTTP GET /api/v1/inventory/:product_id").
 		AddSpanAttribute("product.id", productID).
 		SetResult(&stockInfo).
+		SetError(&failure).
 		Get(url)
 
+	if err == nil {
+		err = c.chaos.Drop(ctx, ChaosCheckStock)
+	}
 	if err != nil {
 		c.logger.ErrorCtx(ctx, "Failed to call warehouse service",
 			logger.Err(err),
@@ -72,10 +156,7 @@ func (c *WarehouseClient) CheckStock(ctx context.Context, productID string) (*St
 			logger.Int("status_code", resp.StatusCode()),
 			logger.String("product_id", productID))
 
-		if resp.StatusCode() == http.StatusNotFound {
-			return nil, fmt.Errorf("product not found: %s", productID)
-		}
-		return nil, fmt.Errorf("warehouse service error: status %d", resp.StatusCode())
+		return nil, warehouseError(resp.StatusCode(), &failure, productID)
 	}
 
 	c.logger.InfoCtx(ctx, "Stock check completed",
@@ -85,8 +166,75 @@ func (c *WarehouseClient) CheckStock(ctx context.Context, productID string) (*St
 	return &stockInfo, nil
 }
 
-func (c *WarehouseClient) ReserveStock(ctx context.Context, productID string, quantity int) (*ReservationResult, error) {
-	url := "/api/inventory/reserve"
+// CheckStockBatch checks the stock of several products in one call. The
+// results are in the order of productIDs.
+func (c *WarehouseClient) CheckStockBatch(ctx context.Context, productIDs []string) ([]StockCheckResult, error) {
+	url := "/api/v1/inventory/check"
+
+	c.logger.InfoCtx(ctx, "Checking stock of several products from warehouse service",
+		logger.Int("count", len(productIDs)),
+		logger.String("url", url))
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("warehouse.operation", "check_stock_batch"),
+		attribute.Int("stock_check.count", len(productIDs)),
+	)
+
+	if err := c.chaos.Inject(ctx, ChaosCheckStockBatch); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service",
+			logger.Err(err))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
+
+	items := make([]map[string]interface{}, len(productIDs))
+	for i, productID := range productIDs {
+		items[i] = map[string]interface{}{"product_id": productID}
+	}
+
+	var result struct {
+		Items []StockCheckResult `json:"items"`
+	}
+	var failure problem.Problem
+	resp, err := c.client.R(ctx).
+		SetSpanName("HTTP POST /api/v1/inventory/check").
+		AddSpanAttribute("stock_check.count", len(productIDs)).
+		SetBody(map[string]interface{}{"items": items}).
+		SetResult(&result).
+		SetError(&failure).
+		Post(url)
+
+	if err == nil {
+		err = c.chaos.Drop(ctx, ChaosCheckStockBatch)
+	}
+	if err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service",
+			logger.Err(err))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		c.logger.WarnCtx(ctx, "Warehouse service returned non-OK status",
+			logger.Int("status_code", resp.StatusCode()))
+
+		return nil, warehouseError(resp.StatusCode(), &failure, "")
+	}
+	if len(result.Items) != len(productIDs) {
+		return nil, fmt.Errorf("warehouse service returned %d results for %d products", len(result.Items), len(productIDs))
+	}
+
+	c.logger.InfoCtx(ctx, "Batch stock check completed",
+		logger.Int("count", len(result.Items)))
+
+	return result.Items, nil
+}
+
+// ReserveStock reserves quantity of productID for orderID. With announce
+// set, warehouse-service also publishes an inventory.reserved event for the
+// reservation.
+func (c *WarehouseClient) ReserveStock(ctx context.Context, orderID, productID string, quantity int, announce bool) (*ReservationResult, error) {
+	defer c.invalidateStock(ctx, productID)
+
+	url := "/api/v1/inventory/reserve"
 
 	c.logger.InfoCtx(ctx, "Reserving stock from warehouse service",
 		logger.String("product_id", productID),
@@ -102,16 +250,34 @@ func (c *WarehouseClient) ReserveStock(ctx context.Context, productID string, qu
 		"product_id": productID,
 		"quantity":   quantity,
 	}
+	if orderID != "" {
+		reqBody["order_id"] = orderID
+	}
+	if announce {
+		reqBody["announce"] = true
+	}
+
+	if err := c.chaos.Inject(ctx, ChaosReserveStock); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service for reservation",
+			logger.Err(err),
+			logger.String("product_id", productID))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
 
 	var result ReservationResult
+	var failure problem.Problem
 	resp, err := c.client.R(ctx).
-		SetSpanName("HTTP POST /api/inventory/reserve").
+		SetSpanName("HTTP POST /api/v1/inventory/reserve").
 		AddSpanAttribute("product.id", productID).
 		AddSpanAttribute("reservation.quantity", quantity).
 		SetBody(reqBody).
 		SetResult(&result).
+		SetError(&failure).
 		Post(url)
 
+	if err == nil {
+		err = c.chaos.Drop(ctx, ChaosReserveStock)
+	}
 	if err != nil {
 		c.logger.ErrorCtx(ctx, "Failed to call warehouse service for reservation",
 			logger.Err(err),
@@ -124,13 +290,7 @@ func (c *WarehouseClient) ReserveStock(ctx context.Context, productID string, qu
 			logger.Int("status_code", resp.StatusCode()),
 			logger.String("product_id", productID))
 
-		if resp.StatusCode() == http.StatusNotFound {
-			return nil, fmt.Errorf("product not found: %s", productID)
-		}
-		if resp.StatusCode() == http.StatusConflict {
-			return nil, fmt.Errorf("insufficient stock for product: %s", productID)
-		}
-		return nil, fmt.Errorf("warehouse service error: status %d", resp.StatusCode())
+		return nil, warehouseError(resp.StatusCode(), &failure, productID)
 	}
 
 	c.logger.InfoCtx(ctx, "Stock reservation completed",
@@ -139,3 +299,112 @@ func (c *WarehouseClient) ReserveStock(ctx context.Context, productID string, qu
 
 	return &result, nil
 }
+
+// ReleaseStock returns the stock ReserveStock reserved for orderID. It is
+// the compensation for a reservation whose order could not be completed; a
+// reservation that already expired in warehouse-service releases nothing.
+func (c *WarehouseClient) ReleaseStock(ctx context.Context, orderID, productID string, quantity int) (*ReleaseResult, error) {
+	defer c.invalidateStock(ctx, productID)
+
+	url := "/api/v1/inventory/release"
+
+	c.logger.InfoCtx(ctx, "Releasing stock in warehouse service",
+		logger.String("product_id", productID),
+		logger.Int("quantity", quantity))
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("warehouse.operation", "release_stock"),
+		attribute.String("product.id", productID),
+		attribute.Int("release.quantity", quantity),
+	)
+
+	reqBody := map[string]interface{}{
+		"product_id": productID,
+		"quantity":   quantity,
+	}
+	if orderID != "" {
+		reqBody["order_id"] = orderID
+	}
+
+	if err := c.chaos.Inject(ctx, ChaosReleaseStock); err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service for release",
+			logger.Err(err),
+			logger.String("product_id", productID))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
+
+	var result ReleaseResult
+	var failure problem.Problem
+	resp, err := c.client.R(ctx).
+		SetSpanName("HTTP POST /api/v1/inventory/release").
+		AddSpanAttribute("product.id", productID).
+		AddSpanAttribute("release.quantity", quantity).
+		SetBody(reqBody).
+		SetResult(&result).
+		SetError(&failure).
+		Post(url)
+
+	if err == nil {
+		err = c.chaos.Drop(ctx, ChaosReleaseStock)
+	}
+	if err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call warehouse service for release",
+			logger.Err(err),
+			logger.String("product_id", productID))
+		return nil, fmt.Errorf("warehouse service call failed: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		c.logger.WarnCtx(ctx, "Warehouse service release failed",
+			logger.Int("status_code", resp.StatusCode()),
+			logger.String("product_id", productID))
+
+		return nil, warehouseError(resp.StatusCode(), &failure, productID)
+	}
+
+	c.logger.InfoCtx(ctx, "Stock release completed",
+		logger.String("product_id", productID),
+		logger.Int("released", result.ReleasedQuantity))
+
+	return &result, nil
+}
+
+// warehouseError maps a failed warehouse-service response to an error,
+// wrapping ErrProductNotFound or ErrInsufficientStock as identified by the
+// problem code, or by the status for responses without a problem body.
+func warehouseError(status int, failure *problem.Problem, productID string) error {
+	switch {
+	case failure.Code == problem.CodeProductNotFound || (failure.Code == "" && status == http.StatusNotFound):
+		return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	case failure.Code == problem.CodeInsufficientStock || (failure.Code == "" && status == http.StatusConflict):
+		return fmt.Errorf("%w for product: %s", ErrInsufficientStock, productID)
+	}
+	if failure.Detail != "" {
+		return fmt.Errorf("warehouse service error: status %d: %s", status, failure.Detail)
+	}
+	return fmt.Errorf("warehouse service error: status %d", status)
+}
+
+// Ping checks that warehouse-service is reachable and reports itself healthy.
+// invalidateStock drops productID's cached stock after a call that may have
+// changed it, whether or not the call succeeded.
+func (c *WarehouseClient) invalidateStock(ctx context.Context, productID string) {
+	if err := c.stock.Delete(ctx, productID); err != nil {
+		c.logger.WarnCtx(ctx, "Failed to invalidate cached stock",
+			logger.Err(err),
+			logger.String("product_id", productID))
+	}
+}
+
+func (c *WarehouseClient) Ping(ctx context.Context) error {
+	resp, err := c.client.R(ctx).
+		SetSpanName("HTTP GET /health").
+		Get("/health")
+	if err != nil {
+		return fmt.Errorf("warehouse service unreachable: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("warehouse service health check returned status %d", resp.StatusCode())
+	}
+	return nil
+}
@@ -1,61 +1,537 @@
 package config
 
 import (
-	"fmt"
-	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/secrets"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Port                string
-	Environment         string
+	Port        string
+	Environment string
+	// LogLevel overrides the level logged at, debug in development and info
+	// elsewhere.
+	LogLevel string
+	// GinMode, LogEncoding and TraceSampleRatio default to the profile of
+	// Environment, see sharedconfig.Profile.
+	GinMode          string
+	LogEncoding      string
+	TraceSampleRatio float64
+	// DebugEndpoints enables the chaos admin routes and /api/test-outbox.
+	DebugEndpoints      bool
 	ServiceName         string
 	DatabaseURL         string
 	RabbitMQURL         string
 	EnableBroker        bool
 	WarehouseServiceURL string
-	JaegerEndpoint      string
-	MaxRetries          int
+	// PaymentServiceURL enables the payment authorization step of order
+	// creation; empty skips it.
+	PaymentServiceURL string
+	// WarehouseClientTimeout and PaymentClientTimeout bound each attempt of
+	// a call to warehouse-service and the payment service.
+	WarehouseClientTimeout time.Duration
+	PaymentClientTimeout   time.Duration
+	// DBConnMaxLifetime recycles pooled database connections, which also
+	// picks up rotated database credentials; zero keeps them open.
+	DBConnMaxLifetime time.Duration
+	JaegerEndpoint    string
+	MaxRetries        int
+	OutboxMaxRetries  int
+	// ListenNotify wakes workers via Postgres LISTEN/NOTIFY; polling remains
+	// as a fallback either way.
+	ListenNotify bool
+
+	InboxWorkers  int
+	OutboxWorkers int
+	BatchSize     int
+	PollInterval  time.Duration
+	// MaxIdlePollInterval caps how far polling slows down while the tables
+	// are empty.
+	MaxIdlePollInterval time.Duration
+	LockTimeout         time.Duration
+	// ReaperInterval controls how often messages locked for longer than
+	// LockTimeout are released; zero disables the reaper.
+	ReaperInterval time.Duration
+	// JobLocks makes each run of the outbox janitor and reaper, stock reconciler, reservation expirer
+	// and order archiver take a Postgres advisory
+	// lock, so only one replica runs each job at a time.
+	JobLocks bool
+	// JobLockRenewInterval is how often a held job lock checks that its
+	// database connection is alive; zero disables the check.
+	JobLockRenewInterval time.Duration
+	// OutboxLeaderElection runs the outbox workers only on the replica
+	// holding the outbox leader lock; the others stand by and take over
+	// within OutboxLeaderRetryInterval once it is released.
+	OutboxLeaderElection      bool
+	OutboxLeaderRetryInterval time.Duration
+	// EventLimits caps inbox processing per event type, e.g.
+	// "order.created=10/50" for at most 10 in flight and 50 per second.
+	EventLimits string
+
+	// PayloadCompressThreshold gzips inbox/outbox payloads and broker
+	// messages of at least this many bytes; zero disables compression.
+	PayloadCompressThreshold int
+	// MaxPayloadSize rejects larger inbox/outbox payloads; zero disables the
+	// limit.
+	MaxPayloadSize int
+
+	RetryBackoffBase       time.Duration
+	RetryBackoffMultiplier float64
+	RetryBackoffMax        time.Duration
+	RetryBackoffJitter     float64
+
+	// RetentionPeriod of zero disables the inbox/outbox retention janitor.
+	RetentionPeriod    time.Duration
+	RetentionInterval  time.Duration
+	RetentionBatchSize int
+	RetentionArchive   bool
+	// OutboxPartitionPeriod partitions the outbox table by "day" or "month";
+	// empty keeps a single table. Expired partitions are dropped by the
+	// retention janitor.
+	OutboxPartitionPeriod string
+
+	// StatsInterval controls how often inbox/outbox row-count gauges refresh.
+	StatsInterval time.Duration
+	// MetricsBackend is prometheus, serving /metrics to be scraped, or
+	// otlp, which also exports the metrics every MetricsExportInterval to
+	// the collector at OTLPMetricsEndpoint, JAEGER_ENDPOINT by default.
+	MetricsBackend        string
+	OTLPMetricsEndpoint   string
+	MetricsExportInterval time.Duration
+	// MetricsExemplars attaches trace IDs to the latency histograms and
+	// serves /metrics as OpenMetrics so Prometheus can store them.
+	MetricsExemplars bool
+	// MetricsBuckets overrides the service's histogram buckets as
+	// semicolon-separated "metric=bound,bound,..." entries.
+	MetricsBuckets string
+	// MetricsNativeHistograms lists histograms that also expose native
+	// buckets, comma-separated.
+	MetricsNativeHistograms string
+	// MetricsSummaries replaces histograms with summaries as
+	// semicolon-separated "metric=quantile,quantile,..." entries.
+	MetricsSummaries string
+	// MetricsMaxPaths caps the distinct route labels of the HTTP metrics;
+	// zero leaves them uncapped.
+	MetricsMaxPaths int
+	// MetricsAuthToken and MetricsAuthUsername/Password require a bearer
+	// token or basic auth to scrape /metrics; MetricsAllowedNetworks, a
+	// comma-separated list of IPs and CIDR ranges, limits who may connect.
+	// Empty leaves /metrics open.
+	MetricsAuthToken       string
+	MetricsAuthUsername    string
+	MetricsAuthPassword    string
+	MetricsAllowedNetworks string
+
+	// ReservationTTL expires orders still pending or holding reserved stock
+	// after this long and releases their stock; zero disables the job.
+	ReservationTTL             time.Duration
+	ReservationExpiryInterval  time.Duration
+	ReservationExpiryBatchSize int
+
+	// OrderRetentionPeriod moves completed orders older than this, and orders
+	// soft-deleted longer ago, out of the orders table; zero disables the
+	// archiver. OrderArchive copies them to orders_archive instead of
+	// purging them.
+	OrderRetentionPeriod  time.Duration
+	OrderArchiveInterval  time.Duration
+	OrderArchiveBatchSize int
+	OrderArchive          bool
+
+	// DegradedOrderAcceptance accepts orders as pending_stock_check while
+	// warehouse-service is unavailable instead of failing them with 503.
+	DegradedOrderAcceptance bool
+	// StockReconcile* control the job completing those orders; orders still
+	// unchecked after StockCheckMaxWait are rejected.
+	StockReconcileInterval  time.Duration
+	StockReconcileBatchSize int
+	StockCheckMaxWait       time.Duration
+
+	// AsyncOrderConfirmation keeps new orders pending until warehouse-service
+	// confirms their reservation with an inventory.reserved event; it needs
+	// the broker. Orders still pending after AsyncConfirmationTimeout expire.
+	AsyncOrderConfirmation   bool
+	AsyncConfirmationTimeout time.Duration
+	// EventDrivenReservation leaves reserving new orders' stock to
+	// warehouse-service, which consumes order.created and answers with
+	// inventory.reserved or inventory.rejected; it needs the broker. Orders
+	// without an answer expire after AsyncConfirmationTimeout.
+	EventDrivenReservation bool
+
+	// Chaos* inject faults into API routes, warehouse and payment calls and
+	// database operations for observability demos. ChaosTargets lists the
+	// affected targets, see chaos.Config; the rules can also be changed at
+	// runtime via PUT /admin/chaos.
+	ChaosEnabled     bool
+	ChaosTargets     []string
+	ChaosMinLatency  time.Duration
+	ChaosMaxLatency  time.Duration
+	ChaosErrorRate   float64
+	ChaosTimeoutRate float64
+	ChaosTimeout     time.Duration
+	ChaosDropRate    float64
+
+	// AuthJWKSURL enables JWT authentication of API routes against the keys
+	// published at this URL; empty disables authentication.
+	AuthJWKSURL      string
+	AuthJWKSCacheTTL time.Duration
+	AuthIssuer       string
+	AuthAudience     string
+	// AuthPublicPaths are served without a token; a trailing "*" matches a
+	// path prefix.
+	AuthPublicPaths []string
+
+	// InboxSenderSecrets requires POST /api/inbox to be HMAC-signed by one of
+	// these senders, as "sender=secret" pairs; empty accepts unsigned
+	// messages. InboxSignatureWindow bounds the accepted clock skew.
+	InboxSenderSecrets   string
+	InboxSignatureWindow time.Duration
+
+	// RateLimitOrders and RateLimitInbox limit POST /api/v1/orders and POST
+	// /api/v1/inbox, and their aliases, per client as "rate/burst"; empty disables the limit.
+	RateLimitOrders string
+	RateLimitInbox  string
+	// RateLimitRedisAddr shares the limits between instances through Redis;
+	// empty keeps them in memory per instance.
+	RateLimitRedisAddr     string
+	RateLimitRedisPassword string
+	RateLimitRedisDB       int
+	// RateLimitAPIKeyHeader names the header that identifies API clients,
+	// which are limited per key rather than per IP.
+	RateLimitAPIKeyHeader string
+
+	// StockCacheTTL caches warehouse stock checks per product for this long;
+	// zero disables the cache. StockCacheLocalTTL caps how long an instance
+	// keeps an entry in memory, which bounds how long it may miss another
+	// instance's invalidation when the cache is shared.
+	StockCacheTTL      time.Duration
+	StockCacheLocalTTL time.Duration
+	// CacheRedisAddr shares cached entries between instances through Redis;
+	// empty keeps them in memory per instance.
+	CacheRedisAddr     string
+	CacheRedisPassword string
+	CacheRedisDB       int
+
+	// ShutdownTimeout bounds how long in-flight requests may drain on
+	// shutdown before their connections are closed.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout bounds how long a client may take to send the
+	// request headers.
+	ReadHeaderTimeout time.Duration
+
+	// UnversionedAPI keeps the deprecated /api aliases of the /api/v1
+	// routes. UnversionedAPISunset, an RFC 3339 time, announces their
+	// removal in the Sunset header.
+	UnversionedAPI       bool
+	UnversionedAPISunset string
+
+	// RequestTimeout cancels a request's context after this long; zero
+	// disables it. RequestTimeoutRoutes overrides it per route as
+	// "METHOD /path=duration" entries.
+	RequestTimeout       time.Duration
+	RequestTimeoutRoutes string
+	// MaxBodySize rejects larger request bodies with 413; zero disables it.
+	// MaxBodySizeRoutes overrides it per route as "METHOD /path=bytes".
+	MaxBodySize       int64
+	MaxBodySizeRoutes string
+
+	// HealthCheckTimeout bounds each dependency check of /live and /ready.
+	HealthCheckTimeout time.Duration
+	// HealthWorkerStallAfter fails /live once a worker has not polled for
+	// this long.
+	HealthWorkerStallAfter time.Duration
+	// HealthCacheTTL reuses check results across probes for this long; zero
+	// runs the checks on every probe.
+	HealthCacheTTL time.Duration
+	// HealthMinFreeDisk and HealthMaxGoroutines are the thresholds of the
+	// diagnostic checks of /health: the fraction of HealthDiskPath's
+	// filesystem that must stay free and the goroutines the process may run.
+	// Zero disables either.
+	HealthDiskPath      string
+	HealthMinFreeDisk   float64
+	HealthMaxGoroutines int
+
+	// SecretsCacheTTL is how long secrets behind secret references are
+	// reused before they are fetched again, which bounds how long a
+	// rotated secret takes to be picked up. VaultAddr and VaultToken enable
+	// vault:// references; AWSRegion enables awssm:// ones, fetched from
+	// AWSSecretsManagerEndpoint when set.
+	SecretsCacheTTL           time.Duration
+	VaultAddr                 string
+	VaultToken                string
+	AWSRegion                 string
+	AWSSecretsManagerEndpoint string
+
+	// secretRefs keeps the settings ResolveSecrets resolved, as
+	// configured.
+	secretRefs secrets.Settings
+	// durations keeps the duration settings Load could not parse, for
+	// Validate to report.
+	durations *sharedconfig.DurationReader
 }
 
-func Load() *Config {
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./services/order-service")
-	viper.AddConfigPath("../../")
-
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Println("No .env file found, using environment variables and defaults")
-		} else {
-			log.Printf("Error reading config file: %v", err)
-		}
-	}
+// Load reads the configuration with args, the command-line arguments, as
+// the flags. Flags take precedence over environment variables, which take
+// precedence over the config file and then the defaults.
+func Load(args []string) *Config {
+	configFile := sharedconfig.BindFlags(args, true)
 
 	viper.AutomaticEnv()
 
 	// Set defaults
+	viper.SetDefault("ENVIRONMENT", "development")
 	viper.SetDefault("MAX_RETRIES", 3)
+	viper.SetDefault("OUTBOX_MAX_RETRIES", 5)
+	viper.SetDefault("LISTEN_NOTIFY", true)
+	viper.SetDefault("INBOX_WORKERS", 3)
+	viper.SetDefault("OUTBOX_WORKERS", 3)
+	viper.SetDefault("BATCH_SIZE", 3)
+	viper.SetDefault("POLL_INTERVAL", "5s")
+	viper.SetDefault("LOCK_TIMEOUT", "5m")
+	viper.SetDefault("RETRY_BACKOFF_BASE", "1s")
+	viper.SetDefault("RETRY_BACKOFF_MULTIPLIER", 2.0)
+	viper.SetDefault("RETRY_BACKOFF_MAX", "5m")
+	viper.SetDefault("RETRY_BACKOFF_JITTER", 0.2)
+	viper.SetDefault("RETENTION_PERIOD", "168h")
+	viper.SetDefault("RETENTION_INTERVAL", "1h")
+	viper.SetDefault("RETENTION_BATCH_SIZE", 1000)
+	viper.SetDefault("RETENTION_ARCHIVE", false)
+	viper.SetDefault("STATS_INTERVAL", "15s")
+	viper.SetDefault("METRICS_BACKEND", "prometheus")
+	viper.SetDefault("METRICS_EXPORT_INTERVAL", "15s")
+	viper.SetDefault("METRICS_EXEMPLARS", false)
+	viper.SetDefault("METRICS_MAX_PATHS", 200)
+	viper.SetDefault("REAPER_INTERVAL", "1m")
+	viper.SetDefault("JOB_LOCKS", true)
+	viper.SetDefault("JOB_LOCK_RENEW_INTERVAL", "30s")
+	viper.SetDefault("OUTBOX_LEADER_ELECTION", true)
+	viper.SetDefault("OUTBOX_LEADER_RETRY_INTERVAL", "5s")
+	viper.SetDefault("MAX_IDLE_POLL_INTERVAL", "30s")
+	viper.SetDefault("RESERVATION_TTL", "15m")
+	viper.SetDefault("RESERVATION_EXPIRY_INTERVAL", "1m")
+	viper.SetDefault("RESERVATION_EXPIRY_BATCH_SIZE", 100)
+	viper.SetDefault("AUTH_JWKS_CACHE_TTL", "10m")
+	viper.SetDefault("AUTH_PUBLIC_PATHS", "/health,/live,/ready,/metrics,/openapi.json,/docs")
+
+	viper.SetDefault("INBOX_SIGNATURE_WINDOW", "5m")
+
+	viper.SetDefault("RATE_LIMIT_ORDERS", "5/10")
+	viper.SetDefault("RATE_LIMIT_INBOX", "50/100")
+	viper.SetDefault("RATE_LIMIT_REDIS_DB", 0)
+
+	viper.SetDefault("STOCK_CACHE_TTL", "2s")
+	viper.SetDefault("STOCK_CACHE_LOCAL_TTL", "1s")
+	viper.SetDefault("CACHE_REDIS_DB", 0)
+	viper.SetDefault("RATE_LIMIT_API_KEY_HEADER", "X-API-Key")
+
+	viper.SetDefault("ORDER_RETENTION_PERIOD", "0")
+	viper.SetDefault("ORDER_ARCHIVE_INTERVAL", "1h")
+	viper.SetDefault("ORDER_ARCHIVE_BATCH_SIZE", 500)
+	viper.SetDefault("ORDER_ARCHIVE", true)
+
+	viper.SetDefault("DEGRADED_ORDER_ACCEPTANCE", false)
+	viper.SetDefault("STOCK_RECONCILE_INTERVAL", "30s")
+	viper.SetDefault("STOCK_RECONCILE_BATCH_SIZE", 50)
+	viper.SetDefault("STOCK_CHECK_MAX_WAIT", "1h")
+
+	viper.SetDefault("ASYNC_ORDER_CONFIRMATION", false)
+	viper.SetDefault("ASYNC_CONFIRMATION_TIMEOUT", "2m")
+	viper.SetDefault("EVENT_DRIVEN_RESERVATION", false)
+
+	viper.SetDefault("CHAOS_ENABLED", false)
+	viper.SetDefault("CHAOS_TARGETS", "*")
+	viper.SetDefault("CHAOS_TIMEOUT", "5s")
+
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("READ_HEADER_TIMEOUT", "10s")
+	viper.SetDefault("WAREHOUSE_CLIENT_TIMEOUT", "30s")
+	viper.SetDefault("PAYMENT_CLIENT_TIMEOUT", "10s")
+	viper.SetDefault("DB_CONN_MAX_LIFETIME", "5m")
+	viper.SetDefault("SECRETS_CACHE_TTL", "5m")
+	viper.SetDefault("UNVERSIONED_API", true)
+	viper.SetDefault("REQUEST_TIMEOUT", "30s")
+	// Exports stream for as long as there are orders to send.
+	viper.SetDefault("REQUEST_TIMEOUT_ROUTES", "GET /api/v1/orders/export=0,GET /api/orders/export=0")
+	// Leaves room for a MAX_PAYLOAD_SIZE payload plus its JSON envelope.
+	viper.SetDefault("MAX_BODY_SIZE", 2<<20)
+
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_WORKER_STALL_AFTER", "5m")
+	viper.SetDefault("HEALTH_CACHE_TTL", "1s")
+	viper.SetDefault("HEALTH_DISK_PATH", "/")
+	viper.SetDefault("HEALTH_MIN_FREE_DISK", 0.05)
+	viper.SetDefault("HEALTH_MAX_GOROUTINES", 10000)
 	viper.SetDefault("JAEGER_ENDPOINT", "localhost:4318")
 
+	sharedconfig.ReadConfigFiles(configFile, ".", "./services/order-service", "../../")
+
+	// The settings that differ between environments default to its profile.
+	profile := sharedconfig.Environment(viper.GetString("ENVIRONMENT")).Profile()
+	viper.SetDefault("GIN_MODE", profile.GinMode)
+	viper.SetDefault("LOG_ENCODING", profile.LogEncoding)
+	viper.SetDefault("TRACE_SAMPLE_RATIO", profile.TraceSampleRatio)
+	viper.SetDefault("DEBUG_ENDPOINTS", profile.DebugEndpoints)
+
 	databaseURL := viper.GetString("DATABASE_URL")
 	if databaseURL == "" {
 		databaseURL = buildDatabaseURL()
 	}
 
-	return &Config{
-		Port:                viper.GetString("PORT"),
-		Environment:         viper.GetString("ENVIRONMENT"),
-		ServiceName:         viper.GetString("SERVICE_NAME"),
-		DatabaseURL:         databaseURL,
-		RabbitMQURL:         viper.GetString("RABBITMQ_URL"),
-		EnableBroker:        viper.GetBool("ENABLE_BROKER"),
-		WarehouseServiceURL: viper.GetString("WAREHOUSE_SERVICE_URL"),
-		JaegerEndpoint:      viper.GetString("JAEGER_ENDPOINT"),
-		MaxRetries:          viper.GetInt("MAX_RETRIES"),
+	otlpMetricsEndpoint := viper.GetString("OTLP_METRICS_ENDPOINT")
+	if otlpMetricsEndpoint == "" {
+		otlpMetricsEndpoint = viper.GetString("JAEGER_ENDPOINT")
 	}
+
+	durations := sharedconfig.NewDurationReader(viper.GetString)
+	cfg := &Config{
+		Port:                   viper.GetString("PORT"),
+		Environment:            viper.GetString("ENVIRONMENT"),
+		LogLevel:               viper.GetString("LOG_LEVEL"),
+		GinMode:                viper.GetString("GIN_MODE"),
+		LogEncoding:            viper.GetString("LOG_ENCODING"),
+		TraceSampleRatio:       viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		DebugEndpoints:         viper.GetBool("DEBUG_ENDPOINTS"),
+		ServiceName:            viper.GetString("SERVICE_NAME"),
+		DatabaseURL:            databaseURL,
+		RabbitMQURL:            viper.GetString("RABBITMQ_URL"),
+		EnableBroker:           viper.GetBool("ENABLE_BROKER"),
+		WarehouseServiceURL:    viper.GetString("WAREHOUSE_SERVICE_URL"),
+		PaymentServiceURL:      viper.GetString("PAYMENT_SERVICE_URL"),
+		WarehouseClientTimeout: durations.Get("WAREHOUSE_CLIENT_TIMEOUT"),
+		PaymentClientTimeout:   durations.Get("PAYMENT_CLIENT_TIMEOUT"),
+		DBConnMaxLifetime:      durations.Get("DB_CONN_MAX_LIFETIME"),
+		JaegerEndpoint:         viper.GetString("JAEGER_ENDPOINT"),
+		MaxRetries:             viper.GetInt("MAX_RETRIES"),
+		OutboxMaxRetries:       viper.GetInt("OUTBOX_MAX_RETRIES"),
+		ListenNotify:           viper.GetBool("LISTEN_NOTIFY"),
+
+		InboxWorkers:  viper.GetInt("INBOX_WORKERS"),
+		OutboxWorkers: viper.GetInt("OUTBOX_WORKERS"),
+		BatchSize:     viper.GetInt("BATCH_SIZE"),
+		PollInterval:  durations.Get("POLL_INTERVAL"),
+
+		MaxIdlePollInterval: durations.Get("MAX_IDLE_POLL_INTERVAL"),
+		LockTimeout:         durations.Get("LOCK_TIMEOUT"),
+
+		ReaperInterval:       durations.Get("REAPER_INTERVAL"),
+		JobLocks:             viper.GetBool("JOB_LOCKS"),
+		JobLockRenewInterval: durations.Get("JOB_LOCK_RENEW_INTERVAL"),
+
+		OutboxLeaderElection:      viper.GetBool("OUTBOX_LEADER_ELECTION"),
+		OutboxLeaderRetryInterval: durations.Get("OUTBOX_LEADER_RETRY_INTERVAL"),
+		EventLimits:               viper.GetString("EVENT_LIMITS"),
+
+		PayloadCompressThreshold: viper.GetInt("PAYLOAD_COMPRESS_THRESHOLD"),
+		MaxPayloadSize:           viper.GetInt("MAX_PAYLOAD_SIZE"),
+
+		RetryBackoffBase:       durations.Get("RETRY_BACKOFF_BASE"),
+		RetryBackoffMultiplier: viper.GetFloat64("RETRY_BACKOFF_MULTIPLIER"),
+		RetryBackoffMax:        durations.Get("RETRY_BACKOFF_MAX"),
+		RetryBackoffJitter:     viper.GetFloat64("RETRY_BACKOFF_JITTER"),
+
+		RetentionPeriod:    durations.Get("RETENTION_PERIOD"),
+		RetentionInterval:  durations.Get("RETENTION_INTERVAL"),
+		RetentionBatchSize: viper.GetInt("RETENTION_BATCH_SIZE"),
+		RetentionArchive:   viper.GetBool("RETENTION_ARCHIVE"),
+
+		OutboxPartitionPeriod: viper.GetString("OUTBOX_PARTITION_PERIOD"),
+
+		StatsInterval: durations.Get("STATS_INTERVAL"),
+
+		MetricsBackend:          viper.GetString("METRICS_BACKEND"),
+		OTLPMetricsEndpoint:     otlpMetricsEndpoint,
+		MetricsExportInterval:   durations.Get("METRICS_EXPORT_INTERVAL"),
+		MetricsExemplars:        viper.GetBool("METRICS_EXEMPLARS"),
+		MetricsBuckets:          viper.GetString("METRICS_BUCKETS"),
+		MetricsNativeHistograms: viper.GetString("METRICS_NATIVE_HISTOGRAMS"),
+		MetricsSummaries:        viper.GetString("METRICS_SUMMARIES"),
+		MetricsMaxPaths:         viper.GetInt("METRICS_MAX_PATHS"),
+
+		MetricsAuthToken:       viper.GetString("METRICS_AUTH_TOKEN"),
+		MetricsAuthUsername:    viper.GetString("METRICS_AUTH_USERNAME"),
+		MetricsAuthPassword:    viper.GetString("METRICS_AUTH_PASSWORD"),
+		MetricsAllowedNetworks: viper.GetString("METRICS_ALLOWED_NETWORKS"),
+
+		ReservationTTL:             durations.Get("RESERVATION_TTL"),
+		ReservationExpiryInterval:  durations.Get("RESERVATION_EXPIRY_INTERVAL"),
+		ReservationExpiryBatchSize: viper.GetInt("RESERVATION_EXPIRY_BATCH_SIZE"),
+
+		OrderRetentionPeriod:  durations.Get("ORDER_RETENTION_PERIOD"),
+		OrderArchiveInterval:  durations.Get("ORDER_ARCHIVE_INTERVAL"),
+		OrderArchiveBatchSize: viper.GetInt("ORDER_ARCHIVE_BATCH_SIZE"),
+		OrderArchive:          viper.GetBool("ORDER_ARCHIVE"),
+
+		DegradedOrderAcceptance: viper.GetBool("DEGRADED_ORDER_ACCEPTANCE"),
+		StockReconcileInterval:  durations.Get("STOCK_RECONCILE_INTERVAL"),
+		StockReconcileBatchSize: viper.GetInt("STOCK_RECONCILE_BATCH_SIZE"),
+		StockCheckMaxWait:       durations.Get("STOCK_CHECK_MAX_WAIT"),
+
+		AsyncOrderConfirmation:   viper.GetBool("ASYNC_ORDER_CONFIRMATION"),
+		AsyncConfirmationTimeout: durations.Get("ASYNC_CONFIRMATION_TIMEOUT"),
+		EventDrivenReservation:   viper.GetBool("EVENT_DRIVEN_RESERVATION"),
+
+		ChaosEnabled:     viper.GetBool("CHAOS_ENABLED"),
+		ChaosTargets:     splitList(viper.GetString("CHAOS_TARGETS")),
+		ChaosMinLatency:  durations.Get("CHAOS_MIN_LATENCY"),
+		ChaosMaxLatency:  durations.Get("CHAOS_MAX_LATENCY"),
+		ChaosErrorRate:   viper.GetFloat64("CHAOS_ERROR_RATE"),
+		ChaosTimeoutRate: viper.GetFloat64("CHAOS_TIMEOUT_RATE"),
+		ChaosTimeout:     durations.Get("CHAOS_TIMEOUT"),
+		ChaosDropRate:    viper.GetFloat64("CHAOS_DROP_RATE"),
+
+		AuthJWKSURL:      viper.GetString("AUTH_JWKS_URL"),
+		AuthJWKSCacheTTL: durations.Get("AUTH_JWKS_CACHE_TTL"),
+		AuthIssuer:       viper.GetString("AUTH_ISSUER"),
+		AuthAudience:     viper.GetString("AUTH_AUDIENCE"),
+		AuthPublicPaths:  splitList(viper.GetString("AUTH_PUBLIC_PATHS")),
+
+		InboxSenderSecrets:   viper.GetString("INBOX_SENDER_SECRETS"),
+		InboxSignatureWindow: durations.Get("INBOX_SIGNATURE_WINDOW"),
+
+		RateLimitOrders:        viper.GetString("RATE_LIMIT_ORDERS"),
+		RateLimitInbox:         viper.GetString("RATE_LIMIT_INBOX"),
+		RateLimitRedisAddr:     viper.GetString("RATE_LIMIT_REDIS_ADDR"),
+		RateLimitRedisPassword: viper.GetString("RATE_LIMIT_REDIS_PASSWORD"),
+		RateLimitRedisDB:       viper.GetInt("RATE_LIMIT_REDIS_DB"),
+		RateLimitAPIKeyHeader:  viper.GetString("RATE_LIMIT_API_KEY_HEADER"),
+
+		StockCacheTTL:      durations.Get("STOCK_CACHE_TTL"),
+		StockCacheLocalTTL: durations.Get("STOCK_CACHE_LOCAL_TTL"),
+		CacheRedisAddr:     viper.GetString("CACHE_REDIS_ADDR"),
+		CacheRedisPassword: viper.GetString("CACHE_REDIS_PASSWORD"),
+		CacheRedisDB:       viper.GetInt("CACHE_REDIS_DB"),
+
+		ShutdownTimeout:   durations.Get("SHUTDOWN_TIMEOUT"),
+		ReadHeaderTimeout: durations.Get("READ_HEADER_TIMEOUT"),
+
+		UnversionedAPI:       viper.GetBool("UNVERSIONED_API"),
+		UnversionedAPISunset: viper.GetString("UNVERSIONED_API_SUNSET"),
+
+		RequestTimeout:       durations.Get("REQUEST_TIMEOUT"),
+		RequestTimeoutRoutes: viper.GetString("REQUEST_TIMEOUT_ROUTES"),
+		MaxBodySize:          viper.GetInt64("MAX_BODY_SIZE"),
+		MaxBodySizeRoutes:    viper.GetString("MAX_BODY_SIZE_ROUTES"),
+
+		HealthCheckTimeout:     durations.Get("HEALTH_CHECK_TIMEOUT"),
+		HealthWorkerStallAfter: durations.Get("HEALTH_WORKER_STALL_AFTER"),
+		HealthCacheTTL:         durations.Get("HEALTH_CACHE_TTL"),
+		HealthDiskPath:         viper.GetString("HEALTH_DISK_PATH"),
+		HealthMinFreeDisk:      viper.GetFloat64("HEALTH_MIN_FREE_DISK"),
+		HealthMaxGoroutines:    viper.GetInt("HEALTH_MAX_GOROUTINES"),
+
+		SecretsCacheTTL:           durations.Get("SECRETS_CACHE_TTL"),
+		VaultAddr:                 viper.GetString("VAULT_ADDR"),
+		VaultToken:                viper.GetString("VAULT_TOKEN"),
+		AWSRegion:                 viper.GetString("AWS_REGION"),
+		AWSSecretsManagerEndpoint: viper.GetString("AWS_ENDPOINT_URL_SECRETS_MANAGER"),
+	}
+	cfg.durations = durations
+	return cfg
 }
 
 func buildDatabaseURL() string {
@@ -66,6 +542,24 @@ func buildDatabaseURL() string {
 	dbname := viper.GetString("DB_NAME")
 	sslmode := viper.GetString("DB_SSLMODE")
 
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		user, password, host, port, dbname, sslmode)
+	// Escaping keeps a secret reference given as DB_PASSWORD intact.
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + dbname,
+		RawQuery: "sslmode=" + url.QueryEscape(sslmode),
+	}
+	return u.String()
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
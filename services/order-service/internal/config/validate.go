@@ -0,0 +1,158 @@
+package config
+
+import (
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+)
+
+// Validate checks that the settings the service needs are present, that
+// URLs parse and that numbers are in range. It returns a
+// *sharedconfig.ValidationError listing every problem, or nil.
+func (c *Config) Validate() error {
+	v := sharedconfig.NewValidator(c.durations)
+
+	v.Port("PORT", c.Port)
+	v.Required("SERVICE_NAME", c.ServiceName)
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			v.Addf("LOG_LEVEL: %v", err)
+		}
+	}
+	if _, err := sharedconfig.ParseEnvironment(c.Environment); err != nil {
+		v.Addf("ENVIRONMENT: %v", err)
+	}
+	v.OneOf("GIN_MODE", c.GinMode, "debug", "release", "test")
+	v.OneOf("LOG_ENCODING", c.LogEncoding, "json", "console")
+	v.Fraction("TRACE_SAMPLE_RATIO", c.TraceSampleRatio)
+	v.URL("DATABASE_URL", c.DatabaseURL, "postgres", "postgresql")
+	v.URL("WAREHOUSE_SERVICE_URL", c.WarehouseServiceURL, "http", "https")
+	if c.EnableBroker {
+		v.URL("RABBITMQ_URL", c.RabbitMQURL, "amqp", "amqps")
+	}
+	if c.PaymentServiceURL != "" {
+		v.URL("PAYMENT_SERVICE_URL", c.PaymentServiceURL, "http", "https")
+	}
+	if c.AuthJWKSURL != "" {
+		v.URL("AUTH_JWKS_URL", c.AuthJWKSURL, "http", "https")
+	}
+
+	if c.AsyncOrderConfirmation && !c.EnableBroker {
+		v.Addf("ASYNC_ORDER_CONFIRMATION: requires ENABLE_BROKER, as orders are confirmed by broker events")
+	}
+	if c.EventDrivenReservation && !c.EnableBroker {
+		v.Addf("EVENT_DRIVEN_RESERVATION: requires ENABLE_BROKER, as stock is reserved from broker events")
+	}
+
+	v.AtLeast("MAX_RETRIES", c.MaxRetries, 1)
+	v.AtLeast("INBOX_WORKERS", c.InboxWorkers, 1)
+	if c.EnableBroker {
+		v.AtLeast("OUTBOX_MAX_RETRIES", c.OutboxMaxRetries, 1)
+		v.AtLeast("OUTBOX_WORKERS", c.OutboxWorkers, 1)
+	}
+	v.AtLeast("BATCH_SIZE", c.BatchSize, 1)
+	v.Positive("POLL_INTERVAL", c.PollInterval)
+	v.Positive("MAX_IDLE_POLL_INTERVAL", c.MaxIdlePollInterval)
+	v.Positive("LOCK_TIMEOUT", c.LockTimeout)
+	v.NonNegative("REAPER_INTERVAL", c.ReaperInterval)
+	v.NonNegative("JOB_LOCK_RENEW_INTERVAL", c.JobLockRenewInterval)
+	if c.OutboxLeaderElection {
+		v.Positive("OUTBOX_LEADER_RETRY_INTERVAL", c.OutboxLeaderRetryInterval)
+	}
+	v.AtLeast("PAYLOAD_COMPRESS_THRESHOLD", c.PayloadCompressThreshold, 0)
+	v.AtLeast("MAX_PAYLOAD_SIZE", c.MaxPayloadSize, 0)
+
+	v.Positive("RETRY_BACKOFF_BASE", c.RetryBackoffBase)
+	if c.RetryBackoffMultiplier < 1 {
+		v.Addf("RETRY_BACKOFF_MULTIPLIER: must be at least 1, got %g", c.RetryBackoffMultiplier)
+	}
+	if c.RetryBackoffMax < c.RetryBackoffBase {
+		v.Addf("RETRY_BACKOFF_MAX: must be at least RETRY_BACKOFF_BASE (%s), got %s", c.RetryBackoffBase, c.RetryBackoffMax)
+	}
+	v.Fraction("RETRY_BACKOFF_JITTER", c.RetryBackoffJitter)
+
+	v.NonNegative("RETENTION_PERIOD", c.RetentionPeriod)
+	if c.RetentionPeriod > 0 {
+		v.Positive("RETENTION_INTERVAL", c.RetentionInterval)
+		v.AtLeast("RETENTION_BATCH_SIZE", c.RetentionBatchSize, 1)
+	}
+	// Only retention drops old partitions, and a partition it has not yet
+	// reached cannot be dropped, so it must run well within the lookahead.
+	if period, err := outboxinbox.ParsePartitionPeriod(c.OutboxPartitionPeriod); err != nil {
+		v.Addf("OUTBOX_PARTITION_PERIOD: %v", err)
+	} else if period != "" {
+		if c.RetentionPeriod <= 0 {
+			v.Addf("OUTBOX_PARTITION_PERIOD: requires RETENTION_PERIOD, or partitions are never dropped")
+		} else if c.RetentionInterval >= period.Lookahead() {
+			v.Addf("RETENTION_INTERVAL: must be less than %s with OUTBOX_PARTITION_PERIOD=%s, got %s",
+				period.Lookahead(), period, c.RetentionInterval)
+		}
+	}
+	v.Positive("STATS_INTERVAL", c.StatsInterval)
+
+	switch c.MetricsBackend {
+	case "prometheus":
+	case "otlp":
+		v.Required("OTLP_METRICS_ENDPOINT", c.OTLPMetricsEndpoint)
+		v.Positive("METRICS_EXPORT_INTERVAL", c.MetricsExportInterval)
+	default:
+		v.Addf("METRICS_BACKEND: must be prometheus or otlp, got %q", c.MetricsBackend)
+	}
+	v.AtLeast("METRICS_MAX_PATHS", c.MetricsMaxPaths, 0)
+	if c.MetricsAuthUsername != "" && c.MetricsAuthPassword == "" {
+		v.Addf("METRICS_AUTH_PASSWORD: required with METRICS_AUTH_USERNAME")
+	}
+
+	v.NonNegative("RESERVATION_TTL", c.ReservationTTL)
+	if c.ReservationTTL > 0 || c.AsyncOrderConfirmation || c.EventDrivenReservation {
+		v.Positive("RESERVATION_EXPIRY_INTERVAL", c.ReservationExpiryInterval)
+		v.AtLeast("RESERVATION_EXPIRY_BATCH_SIZE", c.ReservationExpiryBatchSize, 1)
+	}
+	if c.AsyncOrderConfirmation || c.EventDrivenReservation {
+		v.Positive("ASYNC_CONFIRMATION_TIMEOUT", c.AsyncConfirmationTimeout)
+	}
+	v.NonNegative("ORDER_RETENTION_PERIOD", c.OrderRetentionPeriod)
+	if c.OrderRetentionPeriod > 0 {
+		v.Positive("ORDER_ARCHIVE_INTERVAL", c.OrderArchiveInterval)
+		v.AtLeast("ORDER_ARCHIVE_BATCH_SIZE", c.OrderArchiveBatchSize, 1)
+	}
+	v.NonNegative("STOCK_RECONCILE_INTERVAL", c.StockReconcileInterval)
+	if c.StockReconcileInterval > 0 {
+		v.AtLeast("STOCK_RECONCILE_BATCH_SIZE", c.StockReconcileBatchSize, 1)
+		v.Positive("STOCK_CHECK_MAX_WAIT", c.StockCheckMaxWait)
+	}
+
+	v.NonNegative("CHAOS_MIN_LATENCY", c.ChaosMinLatency)
+	if c.ChaosMaxLatency < c.ChaosMinLatency {
+		v.Addf("CHAOS_MAX_LATENCY: must be at least CHAOS_MIN_LATENCY (%s), got %s", c.ChaosMinLatency, c.ChaosMaxLatency)
+	}
+	v.Fraction("CHAOS_ERROR_RATE", c.ChaosErrorRate)
+	v.Fraction("CHAOS_TIMEOUT_RATE", c.ChaosTimeoutRate)
+	v.Fraction("CHAOS_DROP_RATE", c.ChaosDropRate)
+
+	v.AtLeast("RATE_LIMIT_REDIS_DB", c.RateLimitRedisDB, 0)
+	v.NonNegative("STOCK_CACHE_TTL", c.StockCacheTTL)
+	v.NonNegative("STOCK_CACHE_LOCAL_TTL", c.StockCacheLocalTTL)
+	v.AtLeast("CACHE_REDIS_DB", c.CacheRedisDB, 0)
+	v.Positive("WAREHOUSE_CLIENT_TIMEOUT", c.WarehouseClientTimeout)
+	if c.PaymentServiceURL != "" {
+		v.Positive("PAYMENT_CLIENT_TIMEOUT", c.PaymentClientTimeout)
+	}
+	v.NonNegative("DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime)
+	v.NonNegative("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.Positive("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	v.NonNegative("REQUEST_TIMEOUT", c.RequestTimeout)
+	if c.MaxBodySize < 0 {
+		v.Addf("MAX_BODY_SIZE: must not be negative, got %d", c.MaxBodySize)
+	}
+	v.Positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	v.NonNegative("HEALTH_WORKER_STALL_AFTER", c.HealthWorkerStallAfter)
+	v.NonNegative("HEALTH_CACHE_TTL", c.HealthCacheTTL)
+	v.Fraction("HEALTH_MIN_FREE_DISK", c.HealthMinFreeDisk)
+	if c.HealthMinFreeDisk > 0 {
+		v.Required("HEALTH_DISK_PATH", c.HealthDiskPath)
+	}
+	v.AtLeast("HEALTH_MAX_GOROUTINES", c.HealthMaxGoroutines, 0)
+
+	return v.Err()
+}
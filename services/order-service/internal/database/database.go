@@ -1,24 +1,29 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"time"
 
+	"observability-system/shared/chaos"
+
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// NewConnection creates a new database connection using sqlx
-func NewConnection(databaseURL string) (*sqlx.DB, error) {
-	db, err := sqlx.Connect("postgres", databaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
+// NewConnection creates a new database connection using sqlx. dsn is asked
+// for the database URL whenever a connection is opened, so the pool picks
+// up rotated credentials as connMaxLifetime recycles its connections.
+// injector injects chaos faults into its queries; nil injects none.
+func NewConnection(dsn func(context.Context) (string, error), connMaxLifetime time.Duration, injector *chaos.Injector) (*sqlx.DB, error) {
+	db := sqlx.NewDb(sql.OpenDB(chaos.Connector(dsnConnector{dsn: dsn}, injector)), "postgres")
 
 	// Set connection pool settings
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetConnMaxLifetime(connMaxLifetime)
 
 	// Verify connection
 	if err := db.Ping(); err != nil {
@@ -28,56 +33,106 @@ func NewConnection(databaseURL string) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// dsnConnector opens connections with the DSN dsn returns at that moment,
+// so connections opened after a credential rotation use the new secret.
+type dsnConnector struct {
+	dsn func(context.Context) (string, error)
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database URL: %w", err)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (dsnConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// InitSchema creates the service's domain tables. The inbox and outbox tables
+// are owned by the shared outboxinbox stores.
 func InitSchema(db *sqlx.DB) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS orders (
 		id SERIAL PRIMARY KEY,
-		customer_id VARCHAR(255) NOT NULL,
+		order_id VARCHAR(255) UNIQUE NOT NULL,
+		customer_id VARCHAR(255),
+		product_id VARCHAR(255) NOT NULL,
+		product_name VARCHAR(255) NOT NULL DEFAULT '',
+		quantity INT NOT NULL,
 		status VARCHAR(50) NOT NULL DEFAULT 'pending',
-		items JSONB NOT NULL,
-		total_amount DECIMAL(10, 2) NOT NULL,
+		items JSONB NOT NULL DEFAULT '[]',
+		unit_price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+		total_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+		currency CHAR(3) NOT NULL DEFAULT 'USD',
+		stock_reserved BOOLEAN NOT NULL DEFAULT FALSE,
+		payment_id VARCHAR(255),
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP
 	);
 
-	CREATE TABLE IF NOT EXISTS outbox (
-		id SERIAL PRIMARY KEY,
-		message_id VARCHAR(255) UNIQUE NOT NULL,
-		event_type VARCHAR(255) NOT NULL,
-		payload JSONB NOT NULL,
-		status VARCHAR(50) DEFAULT 'PENDING',
-		retry_count INT DEFAULT 0,
-		exchange VARCHAR(255) DEFAULT 'orders',
-		routing_key VARCHAR(255),
-		error TEXT,
-		locked_at TIMESTAMP,
-		locked_by VARCHAR(255),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	-- Migration for the original customer/items orders table
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS order_id VARCHAR(255);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS product_id VARCHAR(255);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS product_name VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS quantity INT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS stock_reserved BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_id VARCHAR(255);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS unit_price DECIMAL(10, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+	ALTER TABLE orders ALTER COLUMN customer_id DROP NOT NULL;
+	ALTER TABLE orders ALTER COLUMN items SET DEFAULT '[]';
+	ALTER TABLE orders ALTER COLUMN total_amount SET DEFAULT 0;
+	-- Rows of the original table get an order id derived from their row id,
+	-- and the product and quantity of their first item, so the columns can
+	-- be NOT NULL like those of a new table.
+	UPDATE orders SET order_id = 'ORD-LEGACY-' || id WHERE order_id IS NULL;
+	UPDATE orders SET
+		product_id = COALESCE(product_id, CASE WHEN jsonb_typeof(items) = 'array'
+			THEN COALESCE(items->0->>'product_id', items->0->>'sku') END, ''),
+		quantity = COALESCE(quantity, CASE WHEN jsonb_typeof(items) = 'array' AND items->0->>'quantity' ~ '^[0-9]{1,9}$'
+			THEN (items->0->>'quantity')::INT END, 0)
+	WHERE product_id IS NULL OR quantity IS NULL;
+	ALTER TABLE orders ALTER COLUMN order_id SET NOT NULL;
+	ALTER TABLE orders ALTER COLUMN product_id SET NOT NULL;
+	ALTER TABLE orders ALTER COLUMN quantity SET NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_order_id ON orders(order_id);
+	CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
+	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status, id);
+	CREATE INDEX IF NOT EXISTS idx_orders_product_id ON orders(product_id, id);
+	CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id, id) WHERE customer_id IS NOT NULL;
+
+	CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders(deleted_at) WHERE deleted_at IS NOT NULL;
+
+	-- Completed orders moved out by the order archiver; row_data keeps the
+	-- whole row, so the archive survives changes to the orders table.
+	CREATE TABLE IF NOT EXISTS orders_archive (
+		id BIGSERIAL PRIMARY KEY,
+		source_id BIGINT NOT NULL,
+		order_id VARCHAR(255) NOT NULL,
+		status VARCHAR(50) NOT NULL,
+		row_data JSONB NOT NULL,
+		created_at TIMESTAMP,
+		deleted_at TIMESTAMP,
+		archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
-	CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status);
-	CREATE INDEX IF NOT EXISTS idx_outbox_locked_at ON outbox(locked_at);
-	CREATE INDEX IF NOT EXISTS idx_outbox_message_id ON outbox(message_id);
+	CREATE INDEX IF NOT EXISTS idx_orders_archive_order_id ON orders_archive(order_id);
+	CREATE INDEX IF NOT EXISTS idx_orders_archive_archived_at ON orders_archive(archived_at);
 
-	CREATE TABLE IF NOT EXISTS inbox (
-		id SERIAL PRIMARY KEY,
-		sender_id VARCHAR(255) NOT NULL,
-		message_id VARCHAR(255) UNIQUE NOT NULL,
-		event_type VARCHAR(255) NOT NULL,
-		payload JSONB NOT NULL,
-		status VARCHAR(50) DEFAULT 'PENDING',
-		retry_count INT DEFAULT 0,
-		exchange VARCHAR(255) DEFAULT 'orders',
-		routing_key VARCHAR(255),
-		error TEXT,
-		locked_at TIMESTAMP,
-		locked_by VARCHAR(255),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	-- Trigram index behind GET /api/orders/search; the expression must match
+	-- orderSearchDocument in the order service.
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_orders_search ON orders USING GIN (
+		(order_id || ' ' || COALESCE(customer_id, '') || ' ' || product_name || ' ' || COALESCE(payment_id, '')) gin_trgm_ops
 	);
-	CREATE INDEX IF NOT EXISTS idx_inbox_status ON inbox(status);
-	CREATE INDEX IF NOT EXISTS idx_inbox_message_id ON inbox(message_id);
-	CREATE INDEX IF NOT EXISTS idx_inbox_locked_at ON inbox(locked_at);
 	`
 
 	_, err := db.Exec(schema)
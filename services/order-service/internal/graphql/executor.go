@@ -0,0 +1,275 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Schema describes the queryable types. Only the Query root is supported.
+type Schema struct {
+	Query *Object
+}
+
+// Object is an object type and its fields.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field resolves one field of an object. Type is nil for scalar fields, whose
+// resolved value is serialized as JSON. Object-typed fields may resolve to a
+// slice, in which case every element is executed against Type concurrently,
+// so loaders can batch the lookups of a list's items.
+type Field struct {
+	Type    *Object
+	Resolve func(p ResolveParams) (interface{}, error)
+}
+
+type ResolveParams struct {
+	Context context.Context
+	// Source is the value the parent field resolved to; nil for root fields.
+	Source interface{}
+	Args   map[string]interface{}
+}
+
+// Request is a GraphQL request as posted by clients.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute runs req against schema. Parse and validation failures return a
+// result without data; resolver failures null the failing field and are
+// reported alongside the rest of the data.
+func Execute(ctx context.Context, schema *Schema, req Request) *Result {
+	ops, err := Parse(req.Query)
+	if err != nil {
+		return &Result{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := selectOperation(ops, req.OperationName)
+	if err != nil {
+		return &Result{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	if errs := validate(schema.Query, op.Selection, nil); len(errs) > 0 {
+		return &Result{Errors: errs}
+	}
+
+	variables := map[string]interface{}{}
+	for _, def := range op.Variables {
+		if v, ok := req.Variables[def.Name]; ok {
+			variables[def.Name] = v
+		} else {
+			variables[def.Name] = resolveValue(def.Default, nil)
+		}
+	}
+
+	e := &executor{variables: variables}
+	data := e.executeObject(ctx, schema.Query, nil, op.Selection, nil)
+	return &Result{Data: data, Errors: e.errors}
+}
+
+func selectOperation(ops []*Operation, name string) (*Operation, error) {
+	if name == "" {
+		if len(ops) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// validate checks every selected field exists and that object fields, and
+// only object fields, have a sub-selection.
+func validate(obj *Object, selection []*Selection, path []interface{}) []Error {
+	var errs []Error
+	for _, sel := range selection {
+		fieldPath := appendPath(path, sel.ResponseKey())
+		if sel.Name == "__typename" {
+			continue
+		}
+
+		field, ok := obj.Fields[sel.Name]
+		switch {
+		case !ok:
+			errs = append(errs, Error{Message: fmt.Sprintf("Cannot query field %q on type %q", sel.Name, obj.Name), Path: fieldPath})
+		case field.Type == nil && len(sel.Selection) > 0:
+			errs = append(errs, Error{Message: fmt.Sprintf("Field %q of type %q must not have a selection", sel.Name, obj.Name), Path: fieldPath})
+		case field.Type != nil && len(sel.Selection) == 0:
+			errs = append(errs, Error{Message: fmt.Sprintf("Field %q of type %q must have a selection of subfields", sel.Name, obj.Name), Path: fieldPath})
+		case field.Type != nil:
+			errs = append(errs, validate(field.Type, sel.Selection, fieldPath)...)
+		}
+	}
+	return errs
+}
+
+type executor struct {
+	variables map[string]interface{}
+
+	mu     sync.Mutex
+	errors []Error
+}
+
+func (e *executor) addError(err error, path []interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: path})
+}
+
+func (e *executor) executeObject(ctx context.Context, obj *Object, source interface{}, selection []*Selection, path []interface{}) *orderedMap {
+	result := &orderedMap{}
+	for _, sel := range selection {
+		key := sel.ResponseKey()
+		fieldPath := appendPath(path, key)
+
+		if sel.Name == "__typename" {
+			result.set(key, obj.Name)
+			continue
+		}
+
+		field := obj.Fields[sel.Name]
+		args := map[string]interface{}{}
+		for name, v := range sel.Arguments {
+			args[name] = resolveValue(v, e.variables)
+		}
+
+		value, err := field.Resolve(ResolveParams{Context: ctx, Source: source, Args: args})
+		if err != nil {
+			e.addError(err, fieldPath)
+			result.set(key, nil)
+			continue
+		}
+		result.set(key, e.completeValue(ctx, field, value, sel.Selection, fieldPath))
+	}
+	return result
+}
+
+func (e *executor) completeValue(ctx context.Context, field *Field, value interface{}, selection []*Selection, path []interface{}) interface{} {
+	if field.Type == nil || isNil(value) {
+		return value
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return e.executeObject(ctx, field.Type, value, selection, path)
+	}
+
+	items := make([]interface{}, rv.Len())
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item := rv.Index(i).Interface()
+			if isNil(item) {
+				return
+			}
+			items[i] = e.executeObject(ctx, field.Type, item, selection, appendPath(path, i))
+		}(i)
+	}
+	wg.Wait()
+	return items
+}
+
+// resolveValue substitutes variables in an argument value.
+func resolveValue(v interface{}, variables map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case Variable:
+		return variables[string(v)]
+	case enumValue:
+		return string(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = resolveValue(item, variables)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = resolveValue(item, variables)
+		}
+		return out
+	}
+	return v
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	out := make([]interface{}, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
+
+// orderedMap serializes its entries in insertion order, as GraphQL responses
+// follow the order of the query's fields.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = map[string]interface{}{}
+	}
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
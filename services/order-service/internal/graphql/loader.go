@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultLoaderWait is how long a Loader collects keys before fetching them.
+const defaultLoaderWait = 2 * time.Millisecond
+
+// BatchFunc fetches the values of keys. Both returned slices are aligned with
+// keys; a nil error slice means every key succeeded.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]V, []error)
+
+// Loader coalesces the lookups of one request: keys requested within a short
+// window are fetched in a single batch, and every key is fetched at most once.
+// Create one Loader per request, as results are cached for its lifetime.
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[K]*loaderEntry[V]
+	pending []K
+	timer   *time.Timer
+}
+
+type loaderEntry[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewLoader creates a loader. A maxBatch of zero leaves batches unbounded.
+func NewLoader[K comparable, V any](fetch BatchFunc[K, V], maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     defaultLoaderWait,
+		maxBatch: maxBatch,
+		cache:    map[K]*loaderEntry[V]{},
+	}
+}
+
+// Load returns the value of key, joining the current batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	entry, ok := l.cache[key]
+	if !ok {
+		entry = &loaderEntry[V]{done: make(chan struct{})}
+		l.cache[key] = entry
+		l.pending = append(l.pending, key)
+
+		if l.maxBatch > 0 && len(l.pending) >= l.maxBatch {
+			l.dispatchLocked(ctx)
+		} else if l.timer == nil {
+			l.timer = time.AfterFunc(l.wait, func() {
+				l.mu.Lock()
+				l.dispatchLocked(ctx)
+				l.mu.Unlock()
+			})
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-entry.done:
+		return entry.value, entry.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// dispatchLocked fetches the pending keys in the background. The caller
+// holds l.mu.
+func (l *Loader[K, V]) dispatchLocked(ctx context.Context) {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if len(l.pending) == 0 {
+		return
+	}
+
+	keys := l.pending
+	l.pending = nil
+	entries := make([]*loaderEntry[V], len(keys))
+	for i, key := range keys {
+		entries[i] = l.cache[key]
+	}
+
+	go func() {
+		values, errs := l.fetch(ctx, keys)
+		for i, entry := range entries {
+			if i < len(values) {
+				entry.value = values[i]
+			}
+			if i < len(errs) {
+				entry.err = errs[i]
+			}
+			close(entry.done)
+		}
+	}()
+}
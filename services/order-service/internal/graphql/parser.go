@@ -0,0 +1,547 @@
+// Package graphql implements the subset of GraphQL the order-service query
+// endpoint needs: query operations with variables, aliases, arguments and
+// nested selections. Fragments, directives, mutations and subscriptions are
+// rejected, and queries nesting selections or values too deeply fail to
+// parse.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Operation is a parsed query operation.
+type Operation struct {
+	Name      string
+	Variables []VariableDefinition
+	Selection []*Selection
+}
+
+type VariableDefinition struct {
+	Name    string
+	Default interface{}
+}
+
+// Selection is a field selected from an object, with its sub-selection for
+// object-typed fields.
+type Selection struct {
+	Alias     string
+	Name      string
+	Arguments map[string]interface{}
+	Selection []*Selection
+}
+
+// ResponseKey is the key the field's value is returned under.
+func (s *Selection) ResponseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// Variable references a request variable in an argument value.
+type Variable string
+
+// enumValue is an unquoted name used as a value; it resolves to its name.
+type enumValue string
+
+// Parse parses a document and returns its operations.
+func Parse(query string) ([]*Operation, error) {
+	p := &parser{lex: lexer{src: query}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var ops []*Operation
+	for p.tok.kind != tokEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("document contains no operations")
+	}
+	return ops, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return l.lexToken()
+		}
+	}
+	return token{kind: tokEOF, pos: l.pos}, nil
+}
+
+func (l *lexer) lexToken() (token, error) {
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, value: "...", pos: start}, nil
+	case strings.ContainsRune("{}()[]:$!=@", rune(c)):
+		l.pos++
+		return token{kind: tokPunct, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.lexNumber()
+	case c == '"':
+		return l.lexString()
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, fmt.Errorf("unexpected character %q at offset %d", r, start)
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isDigit(c):
+		case c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && kind == tokFloat):
+			kind = tokFloat
+		default:
+			return token{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+		}
+		l.pos++
+	}
+	return token{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) lexString() (token, error) {
+	start := l.pos
+	l.pos++
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokString, value: b.String(), pos: start}, nil
+		case '\n':
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			esc := l.src[l.pos+1]
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return token{}, fmt.Errorf("invalid unicode escape at offset %d", l.pos)
+				}
+				code, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return token{}, fmt.Errorf("invalid unicode escape at offset %d", l.pos)
+				}
+				b.WriteRune(rune(code))
+				l.pos += 4
+			default:
+				return token{}, fmt.Errorf("invalid escape \\%c at offset %d", esc, l.pos)
+			}
+			l.pos += 2
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// maxSelectionDepth bounds how deeply selection sets nest, and maxValueDepth
+// how deeply list and object values and list types nest, so a query cannot
+// recurse the parser, validation and execution arbitrarily deep.
+const (
+	maxSelectionDepth = 16
+	maxValueDepth     = 32
+)
+
+type parser struct {
+	lex lexer
+	tok token
+
+	selectionDepth int
+	valueDepth     int
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q", punct)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected name")
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+// nest enters a nested selection set or value, failing once depth reaches
+// limit. The returned func leaves it again.
+func (p *parser) nest(depth *int, limit int, what string) (func(), error) {
+	if *depth >= limit {
+		return nil, p.errorf("%s nested deeper than %d levels", what, limit)
+	}
+	*depth++
+	return func() { *depth-- }, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	found := p.tok.value
+	if p.tok.kind == tokEOF {
+		found = "end of document"
+	}
+	return fmt.Errorf("%s at offset %d, found %q", fmt.Sprintf(format, args...), p.tok.pos, found)
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{}
+
+	if p.tok.kind == tokName {
+		switch p.tok.value {
+		case "query":
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("%s operations are not supported", p.tok.value)
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("expected operation")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName {
+			op.Name = p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("(") {
+			vars, err := p.parseVariableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = vars
+		}
+	}
+
+	selection, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selection = selection
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]VariableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var defs []VariableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+
+		def := VariableDefinition{Name: name}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.Default, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+// skipType consumes a type reference. Variables are not type-checked; the
+// resolvers validate the values they receive.
+func (p *parser) skipType() error {
+	if p.peek("[") {
+		leave, err := p.nest(&p.valueDepth, maxValueDepth, "list types")
+		if err != nil {
+			return err
+		}
+		defer leave()
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+
+	if p.peek("!") {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Selection, error) {
+	leave, err := p.nest(&p.selectionDepth, maxSelectionDepth, "selections")
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*Selection
+	for !p.peek("}") {
+		if p.peek("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		sel, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("expected at least one field")
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) parseField() (*Selection, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &Selection{Name: name}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		sel.Alias = name
+		if sel.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if sel.Arguments, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	if p.peek("{") {
+		if sel.Selection, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{}
+	for !p.peek(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.advance()
+}
+
+// parseValue parses an input value. Constant values, as used for variable
+// defaults, may not reference variables.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+
+	switch tok.kind {
+	case tokInt:
+		v, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer")
+		}
+		return v, p.advance()
+	case tokFloat:
+		v, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float")
+		}
+		return v, p.advance()
+	case tokString:
+		return tok.value, p.advance()
+	case tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.advance()
+	}
+
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		return Variable(name), err
+	case p.peek("["):
+		leave, err := p.nest(&p.valueDepth, maxValueDepth, "values")
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case p.peek("{"):
+		leave, err := p.nest(&p.valueDepth, maxValueDepth, "values")
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+	}
+	return nil, p.errorf("expected value")
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultPageSize        = 50
+	maxPageSize            = 500
+	defaultBulkReplayLimit = 1000
+	adminActorHeader       = "X-Admin-User"
+)
+
+// MessageAdminStore is the subset of an inbox/outbox store used by the admin
+// endpoints.
+type MessageAdminStore interface {
+	outboxinbox.DeadLetterStore
+	outboxinbox.Replayer
+	outboxinbox.QuarantineStore
+}
+
+// ReplayRequest optionally replaces the payload of a replayed or dry-run
+// message.
+type ReplayRequest struct {
+	Payload json.RawMessage `json:"payload"`
+}
+
+type BulkReplayRequest struct {
+	Status        string     `json:"status"`
+	EventType     string     `json:"event_type"`
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedBefore *time.Time `json:"created_before"`
+	Limit         int        `json:"limit" binding:"omitempty,gt=0"`
+}
+
+// AdminHandler exposes operational endpoints for inspecting and recovering
+// inbox/outbox messages.
+type AdminHandler struct {
+	logger          logger.Logger
+	inbox           MessageAdminStore
+	outbox          MessageAdminStore
+	inboxSimulator  outboxinbox.Simulator
+	outboxSimulator outboxinbox.Simulator
+}
+
+func NewAdminHandler(
+	log logger.Logger,
+	inbox, outbox MessageAdminStore,
+	inboxSimulator, outboxSimulator outboxinbox.Simulator,
+) *AdminHandler {
+	return &AdminHandler{
+		logger:          log,
+		inbox:           inbox,
+		outbox:          outbox,
+		inboxSimulator:  inboxSimulator,
+		outboxSimulator: outboxSimulator,
+	}
+}
+
+func (h *AdminHandler) ListInboxDeadLetters(c *gin.Context) {
+	h.listDeadLetters(c, h.inbox)
+}
+
+func (h *AdminHandler) GetInboxDeadLetter(c *gin.Context) {
+	h.getDeadLetter(c, h.inbox)
+}
+
+func (h *AdminHandler) RequeueInboxDeadLetter(c *gin.Context) {
+	h.requeueDeadLetter(c, h.inbox)
+}
+
+func (h *AdminHandler) ListOutboxDeadLetters(c *gin.Context) {
+	h.listDeadLetters(c, h.outbox)
+}
+
+func (h *AdminHandler) GetOutboxDeadLetter(c *gin.Context) {
+	h.getDeadLetter(c, h.outbox)
+}
+
+func (h *AdminHandler) RequeueOutboxDeadLetter(c *gin.Context) {
+	h.requeueDeadLetter(c, h.outbox)
+}
+
+func (h *AdminHandler) ReplayInboxMessage(c *gin.Context) {
+	h.replayMessage(c, h.inbox)
+}
+
+func (h *AdminHandler) ReplayOutboxMessage(c *gin.Context) {
+	h.replayMessage(c, h.outbox)
+}
+
+func (h *AdminHandler) DryRunInboxMessage(c *gin.Context) {
+	h.dryRun(c, h.inbox.Config().TableName, h.inboxSimulator)
+}
+
+func (h *AdminHandler) DryRunOutboxMessage(c *gin.Context) {
+	h.dryRun(c, h.outbox.Config().TableName, h.outboxSimulator)
+}
+
+func (h *AdminHandler) BulkReplayInbox(c *gin.Context) {
+	h.bulkReplay(c, h.inbox)
+}
+
+func (h *AdminHandler) BulkReplayOutbox(c *gin.Context) {
+	h.bulkReplay(c, h.outbox)
+}
+
+func (h *AdminHandler) ListInboxQuarantined(c *gin.Context) {
+	h.listQuarantined(c, h.inbox)
+}
+
+func (h *AdminHandler) GetInboxQuarantined(c *gin.Context) {
+	h.getQuarantined(c, h.inbox)
+}
+
+func (h *AdminHandler) ListOutboxQuarantined(c *gin.Context) {
+	h.listQuarantined(c, h.outbox)
+}
+
+func (h *AdminHandler) GetOutboxQuarantined(c *gin.Context) {
+	h.getQuarantined(c, h.outbox)
+}
+
+func (h *AdminHandler) listDeadLetters(c *gin.Context, store outboxinbox.DeadLetterStore) {
+	ctx := c.Request.Context()
+	table := store.Config().DeadLetterTableName
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "list_dead_letters"),
+		attribute.String("dead_letter.table", table),
+	)
+
+	messages, err := store.ListDeadLetters(ctx, limit, offset)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to list dead letters",
+			logger.Err(err),
+			logger.String("table", table))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to list dead letters"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(messages),
+		"limit":    limit,
+		"offset":   offset,
+		"messages": messages,
+	})
+}
+
+func (h *AdminHandler) getDeadLetter(c *gin.Context, store outboxinbox.DeadLetterStore) {
+	ctx := c.Request.Context()
+	table := store.Config().DeadLetterTableName
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "get_dead_letter"),
+		attribute.String("dead_letter.table", table),
+		attribute.Int64("dead_letter.id", id),
+	)
+
+	msg, err := store.GetDeadLetter(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeMessageNotFound, "Dead letter not found").
+			With("id", id))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to fetch dead letter",
+			logger.Err(err),
+			logger.String("table", table),
+			logger.Int64("id", id))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch dead letter"))
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h *AdminHandler) requeueDeadLetter(c *gin.Context, store outboxinbox.DeadLetterStore) {
+	ctx := c.Request.Context()
+	table := store.Config().DeadLetterTableName
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "requeue_dead_letter"),
+		attribute.String("dead_letter.table", table),
+		attribute.Int64("dead_letter.id", id),
+	)
+
+	messageID, err := store.RequeueDeadLetter(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeMessageNotFound, "Dead letter not found").
+			With("id", id))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to requeue dead letter",
+			logger.Err(err),
+			logger.String("table", table),
+			logger.Int64("id", id))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to requeue dead letter"))
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Dead letter requeued",
+		logger.String("table", table),
+		logger.Int64("id", id),
+		logger.String("message_id", messageID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Dead letter requeued",
+		"message_id": messageID,
+		"request_id": logger.GetRequestIDFromGin(c),
+	})
+}
+
+func (h *AdminHandler) listQuarantined(c *gin.Context, store outboxinbox.QuarantineStore) {
+	ctx := c.Request.Context()
+	table := store.Config().TableName
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "list_quarantined"),
+		attribute.String("message.table", table),
+	)
+
+	messages, err := store.ListQuarantined(ctx, limit, offset)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to list quarantined messages",
+			logger.Err(err),
+			logger.String("table", table))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to list quarantined messages"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(messages),
+		"limit":    limit,
+		"offset":   offset,
+		"messages": messages,
+	})
+}
+
+// getQuarantined returns a quarantined message with its raw payload, parse
+// error and headers. Corrected payloads are resubmitted via the replay
+// endpoint.
+func (h *AdminHandler) getQuarantined(c *gin.Context, store outboxinbox.QuarantineStore) {
+	ctx := c.Request.Context()
+	table := store.Config().TableName
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "get_quarantined"),
+		attribute.String("message.table", table),
+		attribute.Int64("message.id", id),
+	)
+
+	msg, err := store.GetQuarantined(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeMessageNotFound, "Quarantined message not found").
+			With("id", id))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to fetch quarantined message",
+			logger.Err(err),
+			logger.String("table", table),
+			logger.Int64("id", id))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch quarantined message"))
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// replayMessage resets a single message to PENDING. An optional JSON body
+// {"payload": {...}} replaces the stored payload before the replay.
+func (h *AdminHandler) replayMessage(c *gin.Context, store MessageAdminStore) {
+	ctx := c.Request.Context()
+	table := store.Config().TableName
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Write(c, problem.ValidationFailed(err))
+			return
+		}
+	}
+
+	actor := adminActor(c)
+	payloadRewritten := len(req.Payload) > 0
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "replay_message"),
+		attribute.String("message.table", table),
+		attribute.Int64("message.id", id),
+		attribute.Bool("replay.payload_rewritten", payloadRewritten),
+	)
+
+	messageID, err := store.Replay(ctx, id, req.Payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeMessageNotFound, "Message not found or currently being processed").
+			With("id", id))
+		return
+	}
+	if errors.Is(err, outboxinbox.ErrPayloadTooLarge) {
+		problem.Write(c, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, err.Error()))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to replay message",
+			logger.Err(err),
+			logger.String("table", table),
+			logger.Int64("id", id))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to replay message"))
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Audit: message replayed",
+		logger.String("audit_action", "replay"),
+		logger.String("actor", actor),
+		logger.String("table", table),
+		logger.Int64("id", id),
+		logger.String("message_id", messageID),
+		logger.Bool("payload_rewritten", payloadRewritten))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Message queued for replay",
+		"message_id":        messageID,
+		"payload_rewritten": payloadRewritten,
+		"request_id":        logger.GetRequestIDFromGin(c),
+	})
+}
+
+// dryRun runs a message through its handler without lasting effects and
+// reports the outcome. An optional JSON body {"payload": {...}} is simulated
+// in place of the stored payload, which is left untouched.
+func (h *AdminHandler) dryRun(c *gin.Context, table string, simulator outboxinbox.Simulator) {
+	ctx := c.Request.Context()
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Write(c, problem.ValidationFailed(err))
+			return
+		}
+	}
+
+	payloadRewritten := len(req.Payload) > 0
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "dry_run_message"),
+		attribute.String("message.table", table),
+		attribute.Int64("message.id", id),
+		attribute.Bool("replay.payload_rewritten", payloadRewritten),
+	)
+
+	result, err := simulator.Simulate(ctx, id, req.Payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeMessageNotFound, "Message not found").
+			With("id", id))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to simulate message",
+			logger.Err(err),
+			logger.String("table", table),
+			logger.Int64("id", id))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to simulate message"))
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Audit: message replay simulated",
+		logger.String("audit_action", "dry_run"),
+		logger.String("actor", adminActor(c)),
+		logger.String("table", table),
+		logger.Int64("id", id),
+		logger.String("outcome", result.Outcome),
+		logger.Bool("payload_rewritten", payloadRewritten))
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":           true,
+		"payload_rewritten": payloadRewritten,
+		"result":            result,
+		"request_id":        logger.GetRequestIDFromGin(c),
+	})
+}
+
+func (h *AdminHandler) bulkReplay(c *gin.Context, store MessageAdminStore) {
+	ctx := c.Request.Context()
+	table := store.Config().TableName
+
+	var req BulkReplayRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultBulkReplayLimit
+	}
+
+	actor := adminActor(c)
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "bulk_replay"),
+		attribute.String("message.table", table),
+		attribute.String("replay.status", req.Status),
+		attribute.String("replay.event_type", req.EventType),
+		attribute.Int("replay.limit", req.Limit),
+	)
+
+	count, err := store.ReplayByFilter(ctx, outboxinbox.ReplayFilter{
+		Status:        outboxinbox.Status(req.Status),
+		EventType:     req.EventType,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Limit:         req.Limit,
+	})
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to replay messages",
+			logger.Err(err),
+			logger.String("table", table))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to replay messages"))
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Audit: messages replayed in bulk",
+		logger.String("audit_action", "bulk_replay"),
+		logger.String("actor", actor),
+		logger.String("table", table),
+		logger.String("status", req.Status),
+		logger.String("event_type", req.EventType),
+		logger.Int("limit", req.Limit),
+		logger.Int64("count", count))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Messages queued for replay",
+		"count":      count,
+		"request_id": logger.GetRequestIDFromGin(c),
+	})
+}
+
+// adminActor identifies who triggered an admin action for audit logs: the
+// authenticated user when available, then the X-Admin-User header, then the
+// client IP.
+func adminActor(c *gin.Context) string {
+	if userID := logger.GetUserID(c.Request.Context()); userID != "" {
+		return userID
+	}
+	if actor := c.GetHeader(adminActorHeader); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit = defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return 0, 0, false
+		}
+		limit = v
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if raw := c.Query("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "offset must be a non-negative integer"))
+			return 0, 0, false
+		}
+		offset = v
+	}
+
+	return limit, offset, true
+}
+
+func parseIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "id must be an integer").
+			With("id", c.Param("id")))
+		return 0, false
+	}
+	return id, true
+}
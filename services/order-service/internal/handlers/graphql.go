@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"order-service/internal/clients"
+	"order-service/internal/graphql"
+	"order-service/internal/models"
+	"order-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxStockBatch caps the products fetched from warehouse-service at once.
+const maxStockBatch = 50
+
+type stockLoaderKey struct{}
+
+// GraphQLHandler serves orders together with live stock information, so a
+// frontend gets an order and the availability of its products in one round
+// trip. Stock lookups of one request go through a loader, so every product is
+// fetched from warehouse-service at most once and concurrently with the
+// others.
+type GraphQLHandler struct {
+	logger          logger.Logger
+	warehouseClient *clients.WarehouseClient
+	schema          *graphql.Schema
+}
+
+func NewGraphQLHandler(log logger.Logger, warehouseClient *clients.WarehouseClient, orderService *services.OrderService) *GraphQLHandler {
+	h := &GraphQLHandler{
+		logger:          log,
+		warehouseClient: warehouseClient,
+	}
+	h.schema = newOrderSchema(orderService)
+	return h
+}
+
+// Query executes a GraphQL query sent as a JSON body, or for GET requests
+// as the query, operationName and variables query parameters.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if raw := c.Query("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Invalid variables: "+err.Error()))
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	if req.Query == "" {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "query is required"))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "graphql"),
+		attribute.String("graphql.operation_name", req.OperationName),
+	)
+
+	loader := graphql.NewLoader(h.fetchStock, maxStockBatch)
+	result := graphql.Execute(context.WithValue(ctx, stockLoaderKey{}, loader), h.schema, req)
+
+	if len(result.Errors) > 0 {
+		tracing.AddSpanAttributes(ctx, attribute.Int("graphql.errors", len(result.Errors)))
+		h.logger.WarnCtx(ctx, "GraphQL query returned errors",
+			logger.String("operation_name", req.OperationName),
+			logger.Int("errors", len(result.Errors)),
+			logger.String("first_error", result.Errors[0].Message))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// fetchStock is the loader's batch function. The products of a batch are
+// checked with a single warehouse-service call.
+func (h *GraphQLHandler) fetchStock(ctx context.Context, productIDs []string) ([]*clients.StockInfo, []error) {
+	tracing.AddSpanAttributes(ctx, attribute.Int("graphql.stock_batch_size", len(productIDs)))
+
+	stocks := make([]*clients.StockInfo, len(productIDs))
+	errs := make([]error, len(productIDs))
+
+	results, err := h.warehouseClient.CheckStockBatch(ctx, productIDs)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return stocks, errs
+	}
+
+	for i, result := range results {
+		if !result.Found {
+			errs[i] = fmt.Errorf("%w: %s", clients.ErrProductNotFound, productIDs[i])
+			continue
+		}
+		stock := result.StockInfo
+		stocks[i] = &stock
+	}
+	return stocks, errs
+}
+
+func loadStock(ctx context.Context, productID string) (*clients.StockInfo, error) {
+	loader, ok := ctx.Value(stockLoaderKey{}).(*graphql.Loader[string, *clients.StockInfo])
+	if !ok {
+		return nil, errors.New("stock loader missing from context")
+	}
+	return loader.Load(ctx, productID)
+}
+
+func newOrderSchema(orderService *services.OrderService) *graphql.Schema {
+	stockType := &graphql.Object{
+		Name: "Stock",
+		Fields: map[string]*graphql.Field{
+			"productId": stockField(func(s *clients.StockInfo) interface{} { return s.ProductID }),
+			"name":      stockField(func(s *clients.StockInfo) interface{} { return s.Name }),
+			"quantity":  stockField(func(s *clients.StockInfo) interface{} { return s.Quantity }),
+			"reserved":  stockField(func(s *clients.StockInfo) interface{} { return s.Reserved }),
+			"available": stockField(func(s *clients.StockInfo) interface{} { return s.Available }),
+		},
+	}
+
+	orderType := &graphql.Object{
+		Name: "Order",
+		Fields: map[string]*graphql.Field{
+			"id":            orderField(func(o *models.Order) interface{} { return o.ID }),
+			"customerId":    orderField(func(o *models.Order) interface{} { return nullIfEmpty(o.CustomerID) }),
+			"productId":     orderField(func(o *models.Order) interface{} { return o.ProductID }),
+			"productName":   orderField(func(o *models.Order) interface{} { return o.ProductName }),
+			"quantity":      orderField(func(o *models.Order) interface{} { return o.Quantity }),
+			"unitPrice":     orderField(func(o *models.Order) interface{} { return o.UnitPrice }),
+			"totalAmount":   orderField(func(o *models.Order) interface{} { return o.TotalAmount }),
+			"currency":      orderField(func(o *models.Order) interface{} { return o.Currency }),
+			"status":        orderField(func(o *models.Order) interface{} { return o.Status }),
+			"createdAt":     orderField(func(o *models.Order) interface{} { return o.CreatedAt.Format(time.RFC3339) }),
+			"stockReserved": orderField(func(o *models.Order) interface{} { return o.StockReserved }),
+			"paymentId":     orderField(func(o *models.Order) interface{} { return nullIfEmpty(o.PaymentID) }),
+			"stock": {
+				Type: stockType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadStock(p.Context, p.Source.(*models.Order).ProductID)
+				},
+			},
+		},
+	}
+
+	queryType := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"order": {
+				Type: orderType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := stringArg(p.Args, "id", true)
+					if err != nil {
+						return nil, err
+					}
+					order, err := orderService.Get(p.Context, id)
+					if errors.Is(err, sql.ErrNoRows) {
+						return nil, nil
+					}
+					return order, err
+				},
+			},
+			"orders": {
+				Type: orderType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filter := services.OrderFilter{Limit: defaultPageSize}
+					var err error
+					if filter.Status, err = stringArg(p.Args, "status", false); err != nil {
+						return nil, err
+					}
+					if filter.ProductID, err = stringArg(p.Args, "productId", false); err != nil {
+						return nil, err
+					}
+					if filter.CustomerID, err = stringArg(p.Args, "customerId", false); err != nil {
+						return nil, err
+					}
+					if filter.Limit, err = intArg(p.Args, "limit", defaultPageSize); err != nil {
+						return nil, err
+					}
+					if filter.Limit <= 0 || filter.Limit > maxPageSize {
+						return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+					}
+
+					orders, _, err := orderService.List(p.Context, filter)
+					if err != nil {
+						return nil, err
+					}
+					list := make([]*models.Order, len(orders))
+					for i := range orders {
+						list[i] = &orders[i]
+					}
+					return list, nil
+				},
+			},
+			"stock": {
+				Type: stockType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					productID, err := stringArg(p.Args, "productId", true)
+					if err != nil {
+						return nil, err
+					}
+					return loadStock(p.Context, productID)
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{Query: queryType}
+}
+
+func orderField(get func(*models.Order) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*models.Order)), nil
+		},
+	}
+}
+
+func stockField(get func(*clients.StockInfo) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*clients.StockInfo)), nil
+		},
+	}
+}
+
+func stringArg(args map[string]interface{}, name string, required bool) (string, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		if required {
+			return "", fmt.Errorf("argument %q is required", name)
+		}
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return s, nil
+}
+
+// intArg accepts integer literals and JSON numbers from variables.
+func intArg(args map[string]interface{}, name string, def int) (int, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return def, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
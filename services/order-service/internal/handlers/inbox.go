@@ -1,67 +1,99 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"observability-system/shared/auth"
 	"observability-system/shared/logger"
-	"order-service/internal/inbox"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+type CreateInboxMessageRequest struct {
+	SenderID  string                 `json:"sender_id"`
+	MessageID string                 `json:"message_id"`
+	EventType string                 `json:"event_type" binding:"required"`
+	Payload   map[string]interface{} `json:"payload" binding:"required"`
+}
+
 type InboxHandler struct {
 	logger     logger.Logger
-	inboxStore *inbox.InboxStore
+	inboxStore outboxinbox.InboxStore
 }
 
-func NewInboxHandler(log logger.Logger, inboxStore *inbox.InboxStore) *InboxHandler {
+func NewInboxHandler(log logger.Logger, inboxStore outboxinbox.InboxStore) *InboxHandler {
 	return &InboxHandler{
 		logger:     log,
 		inboxStore: inboxStore,
 	}
 }
 
-func (h *InboxHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "OK",
-		"service": "order-service",
-	})
-}
-
 func (h *InboxHandler) CreateInboxMessage(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	var req struct {
-		EventType string                 `json:"event_type" binding:"required"`
-		Payload   map[string]interface{} `json:"payload" binding:"required"`
-	}
+	var req CreateInboxMessageRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.ErrorCtx(ctx, "Invalid request body",
 			logger.Err(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		problem.Write(c, problem.ValidationFailed(err))
 		return
 	}
 
-	messageID := uuid.New().String()
+	// A signed request is recorded under its verified sender, which the body
+	// must not contradict.
+	if sender := auth.GetSender(c); sender != "" {
+		if req.SenderID != "" && req.SenderID != sender {
+			h.logger.WarnCtx(ctx, "Rejected inbox message for another sender",
+				logger.String("sender_id", sender),
+				logger.String("claimed_sender_id", req.SenderID))
+			problem.Write(c, problem.New(http.StatusForbidden, problem.CodeForbidden, "Sender "+sender+" may not submit messages as "+req.SenderID).
+				With("sender_id", sender))
+			return
+		}
+		req.SenderID = sender
+	}
+
+	// Senders may supply their own message_id so redeliveries are deduplicated.
+	messageID := req.MessageID
+	if messageID == "" {
+		messageID = uuid.New().String()
+	}
 
 	h.logger.InfoCtx(ctx, "Creating inbox message",
 		logger.String("message_id", messageID),
+		logger.String("sender_id", req.SenderID),
 		logger.String("event_type", req.EventType))
 
-	err := h.inboxStore.Save(ctx, messageID, req.EventType, req.Payload)
+	err := h.inboxStore.Save(ctx, req.SenderID, messageID, req.EventType, req.Payload)
+	if errors.Is(err, outboxinbox.ErrDuplicateMessage) {
+		h.logger.InfoCtx(ctx, "Inbox message already received",
+			logger.String("message_id", messageID))
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Inbox message already received",
+			"message_id": messageID,
+			"event_type": req.EventType,
+			"duplicate":  true,
+			"request_id": logger.GetRequestIDFromGin(c),
+		})
+		return
+	}
+	if errors.Is(err, outboxinbox.ErrPayloadTooLarge) {
+		problem.Write(c, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, err.Error()))
+		return
+	}
 	if err != nil {
 		h.logger.ErrorCtx(ctx, "Failed to save inbox message",
 			logger.Err(err),
 			logger.String("message_id", messageID))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to save message",
-			"details": err.Error(),
-		})
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save message"))
 		return
 	}
 
@@ -79,20 +111,93 @@ func (h *InboxHandler) CreateInboxMessage(c *gin.Context) {
 func (h *InboxHandler) GetInboxMessages(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	h.logger.InfoCtx(ctx, "Fetching inbox messages")
+	filter, ok := parseListFilter(c)
+	if !ok {
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Fetching inbox messages",
+		logger.String("status", string(filter.Status)),
+		logger.String("event_type", filter.EventType),
+		logger.Int64("cursor", filter.Cursor),
+		logger.Int("limit", filter.Limit))
 
-	messages, err := h.inboxStore.GetAll(ctx)
+	messages, err := h.inboxStore.List(ctx, filter)
 	if err != nil {
 		h.logger.ErrorCtx(ctx, "Failed to fetch inbox messages",
 			logger.Err(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch messages",
-		})
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch messages"))
 		return
 	}
 
+	var nextCursor *int64
+	if len(messages) == filter.Limit {
+		nextCursor = &messages[len(messages)-1].ID
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"count":    len(messages),
-		"messages": messages,
+		"count":       len(messages),
+		"messages":    messages,
+		"next_cursor": nextCursor,
 	})
 }
+
+// parseListFilter reads the status, event_type, message_id, correlation_id,
+// created_after, created_before, cursor, limit and sort query parameters
+// shared by the inbox and outbox listing endpoints.
+func parseListFilter(c *gin.Context) (outboxinbox.ListFilter, bool) {
+	filter := outboxinbox.ListFilter{
+		Status:        outboxinbox.Status(c.Query("status")),
+		EventType:     c.Query("event_type"),
+		MessageID:     c.Query("message_id"),
+		CorrelationID: c.Query("correlation_id"),
+		Limit:         defaultPageSize,
+	}
+
+	badRequest := func(msg string) (outboxinbox.ListFilter, bool) {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, msg))
+		return outboxinbox.ListFilter{}, false
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return badRequest("limit must be a positive integer")
+		}
+		filter.Limit = v
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			return badRequest("cursor must be a positive integer")
+		}
+		filter.Cursor = v
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if raw := c.Query(param); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return badRequest(param + " must be an RFC3339 timestamp")
+			}
+			*dst = &t
+		}
+	}
+
+	switch c.DefaultQuery("sort", "desc") {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		return badRequest("sort must be asc or desc")
+	}
+
+	return filter, true
+}
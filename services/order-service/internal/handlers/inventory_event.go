@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/tracing"
+	"order-service/internal/clients"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/services"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// InventoryEventHandler consumes the events warehouse-service publishes about
+// stock reservations.
+type InventoryEventHandler struct {
+	log           logger.Logger
+	orderService  *services.OrderService
+	warehouse     *clients.WarehouseClient
+	paymentClient *clients.PaymentClient
+}
+
+// NewInventoryEventHandler skips the payment step when paymentClient is nil.
+func NewInventoryEventHandler(log logger.Logger, orderService *services.OrderService, warehouse *clients.WarehouseClient, paymentClient *clients.PaymentClient) *InventoryEventHandler {
+	return &InventoryEventHandler{
+		log:           log,
+		orderService:  orderService,
+		warehouse:     warehouse,
+		paymentClient: paymentClient,
+	}
+}
+
+// HandleInventoryReserved confirms the pending order an inventory.reserved
+// event was published for, running the payment step first. Unannounced
+// reservations only report a stock change and are acknowledged as is. Events for
+// orders that have already left pending, because they expired or the event
+// was redelivered, are acknowledged without changes. An expired order's
+// stock is released by the ReservationExpirer, or here when the order never
+// held it because warehouse-service reserved it after the order expired.
+// An unknown order is retried, since the event can overtake the commit of
+// the order it belongs to. A dry run only looks the order up and reports
+// the outcome, without any of these side effects.
+func (h *InventoryEventHandler) HandleInventoryReserved(ctx context.Context, msg outboxinbox.InboxMessage) error {
+	var payload models.InventoryReservedEvent
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal inventory.reserved payload: %w", err))
+	}
+	if payload.Unannounced {
+		return nil
+	}
+	if payload.OrderID == "" {
+		return outboxinbox.Poison(errors.New("inventory.reserved payload is missing order_id"))
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", payload.OrderID),
+		attribute.String("product.id", payload.ProductID),
+		attribute.String("operation", "confirm_reserved_order"),
+	)
+
+	h.log.InfoCtx(ctx, "Processing inventory reserved event",
+		logger.String("message_id", msg.MessageID),
+		logger.String("order_id", payload.OrderID),
+		logger.String("product_id", payload.ProductID),
+		logger.Int("quantity", payload.Quantity))
+
+	order, err := h.pendingOrder(ctx, payload.OrderID, models.EventInventoryReserved)
+	if errors.Is(err, services.ErrOrderNotPending) {
+		return h.ignoreReserved(ctx, order, payload)
+	}
+	if err != nil {
+		return err
+	}
+	if order.ProductID != payload.ProductID || order.Quantity != payload.Quantity {
+		return outboxinbox.Poison(fmt.Errorf("%s for %d of %s does not match order %s for %d of %s",
+			models.EventInventoryReserved, payload.Quantity, payload.ProductID, order.ID, order.Quantity, order.ProductID))
+	}
+	if outboxinbox.IsDryRun(ctx) {
+		return nil
+	}
+
+	order.Status = models.OrderStatusConfirmed
+	order.StockReserved = true
+	if order.ProductName == "" {
+		order.ProductName = payload.ProductName
+	}
+
+	// The payment is authorized before the order is locked, so no remote call
+	// runs inside the transaction. Authorizations are idempotent by order ID:
+	// a redelivery after a failed commit gets the same payment back. A failed
+	// payment releases the reservation and marks the order payment_failed;
+	// either way the order leaves pending.
+	services.AuthorizePayment(ctx, h.log, h.paymentClient, h.warehouse, order)
+
+	events := []models.Event{{Type: models.EventOrderUpdated, Payload: models.NewOrderEvent(order)}}
+	decided := order
+	order, err = h.orderService.ConfirmReservation(ctx, payload.OrderID, func(order *models.Order) ([]models.Event, error) {
+		order.Status = decided.Status
+		order.StockReserved = decided.StockReserved
+		order.ProductName = decided.ProductName
+		order.PaymentID = decided.PaymentID
+		return events, nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("order %s of inventory.reserved not found yet: %w", payload.OrderID, err)
+	}
+	if errors.Is(err, services.ErrOrderNotPending) {
+		// The order left pending while the payment was authorized.
+		services.RefundUnsaved(ctx, h.log, h.paymentClient, decided, order)
+		return h.ignoreReserved(ctx, order, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	metrics.RecordOrderStatus(order.Status)
+	tracing.AddSpanAttributes(ctx, attribute.String("order.status", order.Status))
+	services.CapturePayment(ctx, h.log, h.paymentClient, order)
+
+	h.log.InfoCtx(ctx, "Order confirmed by inventory reserved event",
+		logger.String("order_id", order.ID),
+		logger.String("status", order.Status),
+		logger.String("waited", time.Since(order.CreatedAt).Round(time.Millisecond).String()))
+
+	return nil
+}
+
+// HandleInventoryRejected rejects the pending order warehouse-service could
+// not reserve stock for. Like HandleInventoryReserved it acknowledges events
+// for orders that have left pending and retries unknown orders.
+func (h *InventoryEventHandler) HandleInventoryRejected(ctx context.Context, msg outboxinbox.InboxMessage) error {
+	var payload models.InventoryRejectedEvent
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal inventory.rejected payload: %w", err))
+	}
+	if payload.OrderID == "" {
+		return outboxinbox.Poison(errors.New("inventory.rejected payload is missing order_id"))
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", payload.OrderID),
+		attribute.String("product.id", payload.ProductID),
+		attribute.String("rejection_reason", payload.Code),
+		attribute.String("operation", "reject_order"),
+	)
+
+	h.log.InfoCtx(ctx, "Processing inventory rejected event",
+		logger.String("message_id", msg.MessageID),
+		logger.String("order_id", payload.OrderID),
+		logger.String("code", payload.Code),
+		logger.String("reason", payload.Reason))
+
+	if outboxinbox.IsDryRun(ctx) {
+		_, err := h.pendingOrder(ctx, payload.OrderID, models.EventInventoryRejected)
+		if errors.Is(err, services.ErrOrderNotPending) {
+			return nil
+		}
+		return err
+	}
+
+	order, err := h.orderService.ConfirmReservation(ctx, payload.OrderID, func(order *models.Order) ([]models.Event, error) {
+		order.Status = models.OrderStatusRejected
+
+		event := models.NewOrderEvent(order)
+		event.Reason = payload.Reason
+		return []models.Event{{Type: models.EventOrderRejected, Payload: event}}, nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("order %s of inventory.rejected not found yet: %w", payload.OrderID, err)
+	}
+	if errors.Is(err, services.ErrOrderNotPending) {
+		h.log.WarnCtx(ctx, "Ignoring inventory rejected event for order that is no longer pending",
+			logger.String("order_id", order.ID),
+			logger.String("status", order.Status))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	metrics.RecordOrderStatus(order.Status)
+	tracing.AddSpanAttributes(ctx, attribute.String("order.status", order.Status))
+
+	h.log.WarnCtx(ctx, "Order rejected by inventory rejected event",
+		logger.String("order_id", order.ID),
+		logger.String("reason", payload.Reason))
+
+	return nil
+}
+
+// ignoreReserved acknowledges an inventory.reserved event for an order that
+// has left pending. The reservation is released unless the order holds it,
+// or its release is left to the ReservationExpirer.
+func (h *InventoryEventHandler) ignoreReserved(ctx context.Context, order *models.Order, payload models.InventoryReservedEvent) error {
+	h.log.WarnCtx(ctx, "Ignoring inventory reserved event for order that is no longer pending",
+		logger.String("order_id", order.ID),
+		logger.String("status", order.Status))
+	if order.StockReserved || order.Status == models.OrderStatusConfirmed || outboxinbox.IsDryRun(ctx) {
+		return nil
+	}
+	// Releasing by order ID is a no-op for reservations that were already
+	// released.
+	if _, err := h.warehouse.ReleaseStock(ctx, order.ID, payload.ProductID, payload.Quantity); err != nil {
+		return fmt.Errorf("failed to release stock reserved for %s order %s: %w", order.Status, order.ID, err)
+	}
+	return nil
+}
+
+// pendingOrder looks up the order an event of eventType was published for
+// without locking it. Like the handlers it returns a retryable error for an
+// unknown order and ErrOrderNotPending, with the order, once it has left
+// pending.
+func (h *InventoryEventHandler) pendingOrder(ctx context.Context, orderID, eventType string) (*models.Order, error) {
+	order, err := h.orderService.Get(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order %s of %s not found yet: %w", orderID, eventType, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up order %s of %s: %w", orderID, eventType, err)
+	}
+	if order.Status != models.OrderStatusPending {
+		return order, services.ErrOrderNotPending
+	}
+	return order, nil
+}
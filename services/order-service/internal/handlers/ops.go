@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"observability-system/shared/ops"
+	"order-service/internal/services"
+)
+
+// RecentOrders lists the newest orders for the operational snapshot served
+// at /internal/ops.
+func RecentOrders(orderService *services.OrderService) ops.RecentFunc {
+	return func(ctx context.Context, limit int) ([]ops.Record, error) {
+		orders, _, err := orderService.List(ctx, services.OrderFilter{Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		records := make([]ops.Record, 0, len(orders))
+		for _, o := range orders {
+			records = append(records, ops.Record{
+				ID:        o.ID,
+				Status:    o.Status,
+				Summary:   fmt.Sprintf("%d x %s for %s, %.2f %s", o.Quantity, o.ProductID, o.CustomerID, o.TotalAmount, o.Currency),
+				CreatedAt: o.CreatedAt,
+			})
+		}
+		return records, nil
+	}
+}
@@ -3,10 +3,11 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"observability-system/shared/logger"
-	"order-service/internal/inbox"
+	"observability-system/shared/outboxinbox"
 )
 
 type OrderEventHandler struct {
@@ -19,7 +20,7 @@ func NewOrderEventHandler(log logger.Logger) *OrderEventHandler {
 	}
 }
 
-func (h *OrderEventHandler) HandleOrderCreated(ctx context.Context, msg inbox.InboxMessage) error {
+func (h *OrderEventHandler) HandleOrderCreated(ctx context.Context, msg outboxinbox.InboxMessage) error {
 	var payload struct {
 		OrderID    string  `json:"order_id"`
 		CustomerID string  `json:"customer_id"`
@@ -32,7 +33,10 @@ func (h *OrderEventHandler) HandleOrderCreated(ctx context.Context, msg inbox.In
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return fmt.Errorf("failed to unmarshal order.created payload: %w", err)
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal order.created payload: %w", err))
+	}
+	if payload.OrderID == "" {
+		return outboxinbox.Poison(errors.New("order.created payload is missing order_id"))
 	}
 
 	h.log.Info("Processing order created event",
@@ -49,7 +53,7 @@ func (h *OrderEventHandler) HandleOrderCreated(ctx context.Context, msg inbox.In
 	return nil
 }
 
-func (h *OrderEventHandler) HandleOrderUpdated(ctx context.Context, msg inbox.InboxMessage) error {
+func (h *OrderEventHandler) HandleOrderUpdated(ctx context.Context, msg outboxinbox.InboxMessage) error {
 	var payload struct {
 		OrderID   string            `json:"order_id"`
 		Status    string            `json:"status"`
@@ -58,7 +62,10 @@ func (h *OrderEventHandler) HandleOrderUpdated(ctx context.Context, msg inbox.In
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return fmt.Errorf("failed to unmarshal order.updated payload: %w", err)
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal order.updated payload: %w", err))
+	}
+	if payload.OrderID == "" {
+		return outboxinbox.Poison(errors.New("order.updated payload is missing order_id"))
 	}
 
 	h.log.Info("Processing order updated event",
@@ -74,7 +81,7 @@ func (h *OrderEventHandler) HandleOrderUpdated(ctx context.Context, msg inbox.In
 	return nil
 }
 
-func (h *OrderEventHandler) HandleOrderCancelled(ctx context.Context, msg inbox.InboxMessage) error {
+func (h *OrderEventHandler) HandleOrderCancelled(ctx context.Context, msg outboxinbox.InboxMessage) error {
 	var payload struct {
 		OrderID      string  `json:"order_id"`
 		Reason       string  `json:"reason"`
@@ -83,7 +90,10 @@ func (h *OrderEventHandler) HandleOrderCancelled(ctx context.Context, msg inbox.
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return fmt.Errorf("failed to unmarshal order.cancelled payload: %w", err)
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal order.cancelled payload: %w", err))
+	}
+	if payload.OrderID == "" {
+		return outboxinbox.Poison(errors.New("order.cancelled payload is missing order_id"))
 	}
 
 	h.log.Info("Processing order cancelled event",
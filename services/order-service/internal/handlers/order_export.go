@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"order-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Export formats accepted by the format query parameter.
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+var orderCSVHeader = []string{
+	"id", "customer_id", "product_id", "product_name", "quantity", "unit_price",
+	"total_amount", "currency", "status", "stock_reserved", "payment_id", "created_at",
+}
+
+// ExportOrders streams every order matching the listing filters as CSV or
+// NDJSON. Orders are read and flushed to the client a chunk at a time, so an
+// export of any size runs in constant memory. Once streaming has started,
+// errors can no longer change the status code; they end the response early
+// and are logged.
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	var contentType string
+	switch format {
+	case ExportFormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	case ExportFormatNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "format must be csv or ndjson"))
+		return
+	}
+
+	filter, ok := parseOrderFilter(c)
+	if !ok {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "export_orders"),
+		attribute.String("export.format", format),
+	)
+
+	filename := fmt.Sprintf("orders-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	started := false
+	start := func() {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+		started = true
+	}
+
+	csvWriter := csv.NewWriter(c.Writer)
+	encoder := json.NewEncoder(c.Writer)
+	exported := 0
+
+	err := h.orderService.Export(ctx, filter, func(orders []models.Order) error {
+		if !started {
+			start()
+			if format == ExportFormatCSV {
+				if err := csvWriter.Write(orderCSVHeader); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range orders {
+			var err error
+			if format == ExportFormatCSV {
+				err = csvWriter.Write(orderCSVRecord(&orders[i]))
+			} else {
+				err = encoder.Encode(&orders[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if format == ExportFormatCSV {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+
+		exported += len(orders)
+		return nil
+	})
+
+	tracing.AddSpanAttributes(ctx, attribute.Int("export.orders", exported))
+
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to export orders",
+			logger.Err(err),
+			logger.Int("exported", exported))
+		if !started {
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to export orders"))
+		}
+		return
+	}
+
+	if !started {
+		// Nothing matched; still send a well-formed, empty export.
+		start()
+		if format == ExportFormatCSV {
+			csvWriter.Write(orderCSVHeader)
+			csvWriter.Flush()
+		}
+	}
+
+	h.logger.InfoCtx(ctx, "Orders exported",
+		logger.String("format", format),
+		logger.Int("exported", exported))
+}
+
+func orderCSVRecord(o *models.Order) []string {
+	return []string{
+		o.ID,
+		o.CustomerID,
+		o.ProductID,
+		o.ProductName,
+		strconv.Itoa(o.Quantity),
+		strconv.FormatFloat(o.UnitPrice, 'f', 2, 64),
+		strconv.FormatFloat(o.TotalAmount, 'f', 2, 64),
+		o.Currency,
+		o.Status,
+		strconv.FormatBool(o.StockReserved),
+		o.PaymentID,
+		o.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
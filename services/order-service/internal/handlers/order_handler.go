@@ -1,65 +1,124 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
 	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
 	"observability-system/shared/tracing"
 	"order-service/internal/clients"
-	"order-service/internal/outbox"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/attribute"
 )
 
-var (
-	ordersMu sync.RWMutex
-	orders   = make(map[string]*Order)
-)
+type CreateOrderRequest struct {
+	ProductID  string  `json:"product_id" binding:"required"`
+	Quantity   int     `json:"quantity" binding:"required,gt=0"`
+	CustomerID string  `json:"customer_id"`
+	UnitPrice  float64 `json:"unit_price" binding:"gte=0"`
+	// Currency is an ISO 4217 code, models.DefaultCurrency when empty.
+	Currency string `json:"currency"`
+}
 
-type Order struct {
-	ID             string    `json:"id"`
-	ProductID      string    `json:"product_id"`
-	ProductName    string    `json:"product_name"`
-	Quantity       int       `json:"quantity"`
-	Status         string    `json:"status"`
-	CreatedAt      time.Time `json:"created_at"`
-	StockReserved  bool      `json:"stock_reserved"`
-	AvailableStock int       `json:"available_stock,omitempty"`
+type TestOutboxRequest struct {
+	EventType    string                 `json:"event_type" binding:"required"`
+	Exchange     string                 `json:"exchange"`
+	RoutingKey   string                 `json:"routing_key"`
+	Headers      map[string]string      `json:"headers"`
+	PartitionKey string                 `json:"partition_key"`
+	DeliverAfter time.Time              `json:"deliver_after"`
+	Priority     int                    `json:"priority"`
+	Payload      map[string]interface{} `json:"payload" binding:"required"`
 }
 
 type OrderHandler struct {
 	logger          logger.Logger
 	warehouseClient *clients.WarehouseClient
-	outboxStore     *outbox.OutboxStore
+	paymentClient   *clients.PaymentClient
+	orderService    *services.OrderService
+	outboxStore     outboxinbox.OutboxStore
+	// acceptDegraded accepts orders as pending_stock_check instead of
+	// failing them while warehouse-service is unavailable.
+	acceptDegraded bool
+	// asyncConfirmation leaves new orders pending until warehouse-service
+	// confirms their reservation with an inventory.reserved event.
+	asyncConfirmation bool
+	// eventDrivenReservation leaves reserving new orders' stock to
+	// warehouse-service, which consumes their order.created event.
+	eventDrivenReservation bool
 }
 
-func NewOrderHandler(log logger.Logger, warehouseClient *clients.WarehouseClient, outboxStore *outbox.OutboxStore) *OrderHandler {
+// NewOrderHandler skips the payment step when paymentClient is nil.
+func NewOrderHandler(log logger.Logger, warehouseClient *clients.WarehouseClient, paymentClient *clients.PaymentClient, orderService *services.OrderService, outboxStore outboxinbox.OutboxStore) *OrderHandler {
 	return &OrderHandler{
 		logger:          log,
 		warehouseClient: warehouseClient,
+		paymentClient:   paymentClient,
+		orderService:    orderService,
 		outboxStore:     outboxStore,
 	}
 }
 
+// SetDegradedMode makes CreateOrder accept orders whose stock check fails
+// because warehouse-service is unavailable. They are stored as
+// pending_stock_check and answered with 202; the StockReconciler confirms or
+// rejects them later.
+func (h *OrderHandler) SetDegradedMode(enabled bool) {
+	h.acceptDegraded = enabled
+}
+
+// SetAsyncConfirmation makes CreateOrder store orders as pending once their
+// stock is reserved and answer 202; the payment step and the confirmation run
+// when the reservation's inventory.reserved event arrives through the inbox.
+// Orders whose event does not arrive in time are expired.
+func (h *OrderHandler) SetAsyncConfirmation(enabled bool) {
+	h.asyncConfirmation = enabled
+}
+
+// SetEventDrivenReservation makes CreateOrder store orders as pending without
+// calling warehouse-service and answer 202. warehouse-service reserves their
+// stock from the order.created event and answers with inventory.reserved or
+// inventory.rejected, which confirm or reject the order through the inbox.
+func (h *OrderHandler) SetEventDrivenReservation(enabled bool) {
+	h.eventDrivenReservation = enabled
+}
+
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	var req struct {
-		ProductID string `json:"product_id" binding:"required"`
-		Quantity  int    `json:"quantity" binding:"required,gt=0"`
-	}
+	var req CreateOrderRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.ErrorCtx(ctx, "Invalid request body",
 			logger.Err(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	currency, ok := models.NormalizeCurrency(req.Currency)
+	if !ok {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "currency must be a supported ISO 4217 code").
+			With("currency", req.Currency))
+		return
+	}
+	req.Currency = currency
+
+	totalAmount := models.OrderTotal(req.UnitPrice, req.Quantity)
+	if totalAmount > models.MaxOrderAmount {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			fmt.Sprintf("order total must not exceed %.2f", models.MaxOrderAmount)).
+			With("total_amount", totalAmount))
 		return
 	}
 
@@ -69,13 +128,22 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		attribute.String("order.id", orderID),
 		attribute.String("product.id", req.ProductID),
 		attribute.Int("order.quantity", req.Quantity),
+		attribute.Float64("order.unit_price", req.UnitPrice),
+		attribute.Float64("order.total_amount", totalAmount),
+		attribute.String("order.currency", req.Currency),
 		attribute.String("operation", "create_order"),
 	)
 
 	h.logger.InfoCtx(ctx, "Creating order",
 		logger.String("order_id", orderID),
 		logger.String("product_id", req.ProductID),
-		logger.Int("quantity", req.Quantity))
+		logger.Int("quantity", req.Quantity),
+		logger.String("total_amount", fmt.Sprintf("%.2f %s", totalAmount, req.Currency)))
+
+	if h.eventDrivenReservation {
+		h.acceptPendingReservation(c, orderID, req)
+		return
+	}
 
 	h.logger.InfoCtx(ctx, "Checking stock availability",
 		logger.String("order_id", orderID))
@@ -91,11 +159,14 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 			logger.Err(err),
 			logger.String("order_id", orderID))
 
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error":    "Failed to check stock availability",
-			"order_id": orderID,
-			"details":  err.Error(),
-		})
+		if h.acceptDegraded && !errors.Is(err, clients.ErrProductNotFound) {
+			h.acceptPendingStockCheck(c, orderID, req)
+			return
+		}
+
+		problem.Write(c, stockProblem(err, "Failed to check stock availability").
+			With("order_id", orderID).
+			With("product_id", req.ProductID))
 		return
 	}
 
@@ -104,7 +175,9 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		attribute.Int("stock.available", stockInfo.Available),
 	)
 
-	if stockInfo.Available < req.Quantity {
+	// A cached count may be stale, so only a fresh one turns the order
+	// down here; otherwise the reservation below decides.
+	if !stockInfo.Cached && stockInfo.Available < req.Quantity {
 		h.logger.WarnCtx(ctx, "Insufficient stock for order",
 			logger.String("order_id", orderID),
 			logger.Int("requested", req.Quantity),
@@ -115,19 +188,18 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 			attribute.String("rejection_reason", "insufficient_stock"),
 		)
 
-		c.JSON(http.StatusConflict, gin.H{
-			"error":     "Insufficient stock",
-			"order_id":  orderID,
-			"available": stockInfo.Available,
-			"requested": req.Quantity,
-		})
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeInsufficientStock, fmt.Sprintf("Requested %d, only %d available", req.Quantity, stockInfo.Available)).
+			With("order_id", orderID).
+			With("product_id", req.ProductID).
+			With("available", stockInfo.Available).
+			With("requested", req.Quantity))
 		return
 	}
 
 	h.logger.InfoCtx(ctx, "Reserving stock",
 		logger.String("order_id", orderID))
 
-	reservation, err := h.warehouseClient.ReserveStock(ctx, req.ProductID, req.Quantity)
+	reservation, err := h.warehouseClient.ReserveStock(ctx, orderID, req.ProductID, req.Quantity, h.asyncConfirmation)
 	if err != nil {
 		tracing.AddSpanAttributes(ctx,
 			attribute.Bool("stock_reservation.success", false),
@@ -138,11 +210,9 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 			logger.Err(err),
 			logger.String("order_id", orderID))
 
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error":    "Failed to reserve stock",
-			"order_id": orderID,
-			"details":  err.Error(),
-		})
+		problem.Write(c, stockProblem(err, "Failed to reserve stock").
+			With("order_id", orderID).
+			With("product_id", req.ProductID))
 		return
 	}
 
@@ -151,25 +221,71 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		attribute.Int("stock.reserved", reservation.ReservedQuantity),
 	)
 
-	order := &Order{
+	order := &models.Order{
 		ID:             orderID,
+		CustomerID:     req.CustomerID,
 		ProductID:      req.ProductID,
 		ProductName:    stockInfo.Name,
 		Quantity:       req.Quantity,
-		Status:         "confirmed",
+		UnitPrice:      req.UnitPrice,
+		TotalAmount:    totalAmount,
+		Currency:       req.Currency,
+		Status:         models.OrderStatusConfirmed,
 		CreatedAt:      time.Now(),
 		StockReserved:  true,
 		AvailableStock: reservation.NewAvailable,
 	}
 
-	ordersMu.Lock()
-	orders[orderID] = order
-	ordersMu.Unlock()
+	if h.asyncConfirmation {
+		if reservation.EventID != "" {
+			h.acceptPendingConfirmation(c, order, reservation)
+			return
+		}
+		h.logger.WarnCtx(ctx, "Warehouse did not announce the reservation, confirming order synchronously",
+			logger.String("order_id", orderID))
+	}
+
+	paymentErr := services.AuthorizePayment(ctx, h.logger, h.paymentClient, h.warehouseClient, order)
+
+	if err := h.orderService.Create(ctx, order); err != nil {
+		tracing.AddSpanAttributes(ctx,
+			attribute.Bool("order.created", false),
+			attribute.String("error", err.Error()),
+		)
+
+		h.logger.ErrorCtx(ctx, "Failed to save order",
+			logger.Err(err),
+			logger.String("order_id", orderID))
+
+		// The order does not exist, so neither its payment nor its stock may
+		// stay held. A failed authorization already released the stock.
+		services.RefundPayment(ctx, h.logger, h.paymentClient, order, "order could not be saved")
+		if order.StockReserved {
+			if _, releaseErr := h.warehouseClient.ReleaseStock(ctx, orderID, req.ProductID, req.Quantity); releaseErr != nil {
+				h.logger.ErrorCtx(ctx, "Failed to release stock of unsaved order",
+					logger.Err(releaseErr),
+					logger.String("order_id", orderID))
+			}
+		}
+
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save order").
+			With("order_id", orderID))
+		return
+	}
 
 	tracing.AddSpanAttributes(ctx,
 		attribute.Bool("order.created", true),
 		attribute.String("order.status", order.Status),
 	)
+	metrics.RecordOrderCreated(order.Status)
+	services.CapturePayment(ctx, h.logger, h.paymentClient, order)
+
+	if paymentErr != nil {
+		problem.Write(c, paymentProblem(paymentErr).
+			With("order_id", orderID).
+			With("order", order))
+		return
+	}
 
 	h.logger.InfoCtx(ctx, "Order created successfully",
 		logger.String("order_id", orderID),
@@ -183,6 +299,166 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	})
 }
 
+// acceptPendingConfirmation stores an order whose stock is reserved as
+// pending, to be confirmed by the reservation's inventory.reserved event, and
+// answers 202 Accepted.
+func (h *OrderHandler) acceptPendingConfirmation(c *gin.Context, order *models.Order, reservation *clients.ReservationResult) {
+	ctx := c.Request.Context()
+
+	order.Status = models.OrderStatusPending
+
+	if err := h.orderService.Create(ctx, order); err != nil {
+		tracing.AddSpanAttributes(ctx,
+			attribute.Bool("order.created", false),
+			attribute.String("error", err.Error()),
+		)
+
+		h.logger.ErrorCtx(ctx, "Failed to save order",
+			logger.Err(err),
+			logger.String("order_id", order.ID))
+
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save order").
+			With("order_id", order.ID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("order.created", true),
+		attribute.String("order.status", order.Status),
+		attribute.String("reservation.event_id", reservation.EventID),
+	)
+	metrics.RecordOrderCreated(order.Status)
+
+	h.logger.InfoCtx(ctx, "Order accepted, awaiting reservation confirmation",
+		logger.String("order_id", order.ID),
+		logger.String("event_id", reservation.EventID))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":        "Order accepted, it is confirmed once the stock reservation event arrives",
+		"order":          order,
+		"stock_reserved": reservation.ReservedQuantity,
+		"request_id":     logger.GetRequestIDFromGin(c),
+	})
+}
+
+// acceptPendingReservation stores an order as pending without reserving its
+// stock. Its order.created event, saved in the same transaction, asks
+// warehouse-service for the reservation. It answers 202 Accepted.
+func (h *OrderHandler) acceptPendingReservation(c *gin.Context, orderID string, req CreateOrderRequest) {
+	ctx := c.Request.Context()
+
+	order := &models.Order{
+		ID:          orderID,
+		CustomerID:  req.CustomerID,
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		UnitPrice:   req.UnitPrice,
+		TotalAmount: models.OrderTotal(req.UnitPrice, req.Quantity),
+		Currency:    req.Currency,
+		Status:      models.OrderStatusPending,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := h.orderService.Create(ctx, order); err != nil {
+		tracing.AddSpanAttributes(ctx,
+			attribute.Bool("order.created", false),
+			attribute.String("error", err.Error()),
+		)
+
+		h.logger.ErrorCtx(ctx, "Failed to save order",
+			logger.Err(err),
+			logger.String("order_id", orderID))
+
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save order").
+			With("order_id", orderID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("order.created", true),
+		attribute.Bool("order.event_driven_reservation", true),
+		attribute.String("order.status", order.Status),
+	)
+	metrics.RecordOrderCreated(order.Status)
+
+	h.logger.InfoCtx(ctx, "Order accepted, awaiting stock reservation by warehouse",
+		logger.String("order_id", orderID))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Order accepted, it is confirmed or rejected once the warehouse reserves its stock",
+		"order":      order,
+		"request_id": logger.GetRequestIDFromGin(c),
+	})
+}
+
+// acceptPendingStockCheck stores an order whose stock could not be checked
+// for the StockReconciler to complete, and answers 202 Accepted.
+func (h *OrderHandler) acceptPendingStockCheck(c *gin.Context, orderID string, req CreateOrderRequest) {
+	ctx := c.Request.Context()
+
+	order := &models.Order{
+		ID:          orderID,
+		CustomerID:  req.CustomerID,
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		UnitPrice:   req.UnitPrice,
+		TotalAmount: models.OrderTotal(req.UnitPrice, req.Quantity),
+		Currency:    req.Currency,
+		Status:      models.OrderStatusPendingStockCheck,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := h.orderService.Create(ctx, order); err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to save order pending stock check",
+			logger.Err(err),
+			logger.String("order_id", orderID))
+
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save order").
+			With("order_id", orderID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("order.created", true),
+		attribute.Bool("order.degraded", true),
+		attribute.String("order.status", order.Status),
+	)
+	metrics.RecordOrderCreated(order.Status)
+
+	h.logger.WarnCtx(ctx, "Order accepted pending stock check, warehouse unavailable",
+		logger.String("order_id", orderID))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Order accepted, stock will be checked once the warehouse is available",
+		"order":      order,
+		"request_id": logger.GetRequestIDFromGin(c),
+	})
+}
+
+// stockProblem maps a warehouse-service failure to the problem returned to
+// the client: unknown products and stock taken by a concurrent order keep
+// their codes, anything else means the warehouse is unavailable. The
+// callers log the error, whose text is not returned.
+func stockProblem(err error, detail string) *problem.Problem {
+	switch {
+	case errors.Is(err, clients.ErrProductNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeProductNotFound, err.Error())
+	case errors.Is(err, clients.ErrInsufficientStock):
+		return problem.New(http.StatusConflict, problem.CodeInsufficientStock, err.Error())
+	}
+	return problem.New(http.StatusServiceUnavailable, problem.CodeDependencyUnavailable, detail)
+}
+
+// paymentProblem maps a failed authorization to the problem returned to the
+// client: only a decline is a payment failure, anything else means the
+// payment service is unavailable. The error text is not returned.
+func paymentProblem(err error) *problem.Problem {
+	if errors.Is(err, clients.ErrPaymentDeclined) {
+		return problem.New(http.StatusPaymentRequired, problem.CodePaymentFailed, "Payment was declined")
+	}
+	return problem.New(http.StatusServiceUnavailable, problem.CodeDependencyUnavailable, "Failed to authorize payment")
+}
+
 func (h *OrderHandler) GetOrder(c *gin.Context) {
 	ctx := c.Request.Context()
 	orderID := c.Param("order_id")
@@ -195,17 +471,19 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	h.logger.InfoCtx(ctx, "Fetching order",
 		logger.String("order_id", orderID))
 
-	ordersMu.RLock()
-	order, exists := orders[orderID]
-	ordersMu.RUnlock()
-
-	if !exists {
+	order, err := h.orderService.Get(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
 		tracing.AddSpanAttributes(ctx, attribute.Bool("order.found", false))
 
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":    "Order not found",
-			"order_id": orderID,
-		})
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeOrderNotFound, "Order "+orderID+" does not exist").
+			With("order_id", orderID))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to fetch order",
+			logger.Err(err),
+			logger.String("order_id", orderID))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch order"))
 		return
 	}
 
@@ -217,40 +495,128 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
+// DeleteOrder soft-deletes a completed order. It disappears from every
+// endpoint right away and is archived or purged by the order archiver later.
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+	orderID := c.Param("order_id")
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", orderID),
+		attribute.String("operation", "delete_order"),
+	)
+
+	order, err := h.orderService.Delete(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeOrderNotFound, "Order "+orderID+" does not exist").
+			With("order_id", orderID))
+		return
+	}
+	if errors.Is(err, services.ErrOrderNotCompleted) {
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeConflict, "Order "+orderID+" is "+order.Status+" and cannot be deleted until it is completed").
+			With("order_id", orderID).
+			With("status", order.Status))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to delete order",
+			logger.Err(err),
+			logger.String("order_id", orderID))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to delete order"))
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Order deleted",
+		logger.String("order_id", orderID),
+		logger.String("status", order.Status))
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	tracing.AddSpanAttributes(ctx, attribute.String("operation", "get_all_orders"))
 
-	h.logger.InfoCtx(ctx, "Fetching all orders")
+	filter, ok := parseOrderFilter(c)
+	if !ok {
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Fetching orders",
+		logger.String("status", filter.Status),
+		logger.String("product_id", filter.ProductID),
+		logger.Int64("cursor", filter.Cursor),
+		logger.Int("limit", filter.Limit))
 
-	ordersMu.RLock()
-	orderList := make([]*Order, 0, len(orders))
-	for _, order := range orders {
-		orderList = append(orderList, order)
+	orderList, total, err := h.orderService.List(ctx, filter)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to fetch orders",
+			logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch orders"))
+		return
 	}
-	ordersMu.RUnlock()
 
 	tracing.AddSpanAttributes(ctx, attribute.Int("orders.count", len(orderList)))
 
+	var nextCursor *int64
+	if len(orderList) == filter.Limit {
+		nextCursor = &orderList[len(orderList)-1].Seq
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"count":  len(orderList),
-		"orders": orderList,
+		"count":       len(orderList),
+		"total":       total,
+		"orders":      orderList,
+		"next_cursor": nextCursor,
 	})
 }
 
+// parseOrderFilter reads the product_id, customer_id and order_id filters,
+// the latter repeated or comma-separated for up to a page of orders; status,
+// created_after, created_before, cursor, limit and sort are parsed as for
+// the inbox and outbox listings.
+func parseOrderFilter(c *gin.Context) (services.OrderFilter, bool) {
+	page, ok := parseListFilter(c)
+	if !ok {
+		return services.OrderFilter{}, false
+	}
+
+	var orderIDs []string
+	for _, raw := range c.QueryArray("order_id") {
+		for _, id := range strings.Split(raw, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				orderIDs = append(orderIDs, id)
+			}
+		}
+	}
+	if len(orderIDs) > maxPageSize {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			fmt.Sprintf("order_id accepts at most %d orders", maxPageSize)).
+			With("max_order_ids", maxPageSize))
+		return services.OrderFilter{}, false
+	}
+
+	return services.OrderFilter{
+		Status:        string(page.Status),
+		ProductID:     c.Query("product_id"),
+		CustomerID:    c.Query("customer_id"),
+		OrderIDs:      orderIDs,
+		CreatedAfter:  page.CreatedAfter,
+		CreatedBefore: page.CreatedBefore,
+		Cursor:        page.Cursor,
+		Limit:         page.Limit,
+		Ascending:     page.Ascending,
+	}, true
+}
+
 func (h *OrderHandler) TestOutbox(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	var req struct {
-		EventType  string                 `json:"event_type" binding:"required"`
-		Exchange   string                 `json:"exchange"`
-		RoutingKey string                 `json:"routing_key"`
-		Payload    map[string]interface{} `json:"payload" binding:"required"`
-	}
+	var req TestOutboxRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(c, problem.ValidationFailed(err))
 		return
 	}
 
@@ -266,11 +632,22 @@ func (h *OrderHandler) TestOutbox(c *gin.Context) {
 		logger.String("exchange", req.Exchange),
 		logger.String("routing_key", req.RoutingKey))
 
-	messageID, err := h.outboxStore.Save(ctx, req.EventType, req.Payload, req.Exchange, req.RoutingKey)
+	messageID, err := h.outboxStore.SaveWithOptions(ctx, req.EventType, req.Payload, outboxinbox.SaveOptions{
+		Exchange:     req.Exchange,
+		RoutingKey:   req.RoutingKey,
+		Headers:      req.Headers,
+		PartitionKey: req.PartitionKey,
+		DeliverAfter: req.DeliverAfter,
+		Priority:     req.Priority,
+	})
+	if errors.Is(err, outboxinbox.ErrPayloadTooLarge) {
+		problem.Write(c, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, err.Error()))
+		return
+	}
 	if err != nil {
 		h.logger.ErrorCtx(ctx, "Failed to save test message",
 			logger.Err(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save outbox message"})
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to save outbox message"))
 		return
 	}
 
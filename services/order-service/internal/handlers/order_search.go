@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// minSearchQueryLength is the shortest q the trigram index can serve; shorter
+// terms would scan the whole table.
+const minSearchQueryLength = 3
+
+// SearchOrders finds orders for support by the listing filters plus q, a
+// reference or free-text term matched against the order ID, customer ID,
+// product name and payment ID. At least one criterion is required, so a
+// search never pages through every order.
+func (h *OrderHandler) SearchOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter, ok := parseOrderFilter(c)
+	if !ok {
+		return
+	}
+	filter.Query = strings.TrimSpace(c.Query("q"))
+
+	if filter.Query != "" && utf8.RuneCountInString(filter.Query) < minSearchQueryLength {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "q must be at least 3 characters").
+			With("min_length", minSearchQueryLength))
+		return
+	}
+	if filter.Query == "" && filter.ProductID == "" && filter.CustomerID == "" && len(filter.OrderIDs) == 0 && filter.Status == "" &&
+		filter.CreatedAfter == nil && filter.CreatedBefore == nil {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			"at least one of q, product_id, customer_id, status, created_after or created_before is required"))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "search_orders"),
+		attribute.Bool("search.text", filter.Query != ""),
+	)
+
+	h.logger.InfoCtx(ctx, "Searching orders",
+		logger.String("q", filter.Query),
+		logger.String("status", filter.Status),
+		logger.String("product_id", filter.ProductID),
+		logger.String("customer_id", filter.CustomerID),
+		logger.Int64("cursor", filter.Cursor),
+		logger.Int("limit", filter.Limit))
+
+	orderList, total, err := h.orderService.List(ctx, filter)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to search orders",
+			logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to search orders"))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.Int("orders.count", len(orderList)))
+
+	var nextCursor *int64
+	if len(orderList) == filter.Limit {
+		nextCursor = &orderList[len(orderList)-1].Seq
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":       len(orderList),
+		"total":       total,
+		"orders":      orderList,
+		"next_cursor": nextCursor,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OutboxHandler struct {
+	logger      logger.Logger
+	outboxStore outboxinbox.OutboxStore
+}
+
+func NewOutboxHandler(log logger.Logger, outboxStore outboxinbox.OutboxStore) *OutboxHandler {
+	return &OutboxHandler{
+		logger:      log,
+		outboxStore: outboxStore,
+	}
+}
+
+func (h *OutboxHandler) GetOutboxMessages(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter, ok := parseListFilter(c)
+	if !ok {
+		return
+	}
+
+	h.logger.InfoCtx(ctx, "Fetching outbox messages",
+		logger.String("status", string(filter.Status)),
+		logger.String("event_type", filter.EventType),
+		logger.Int64("cursor", filter.Cursor),
+		logger.Int("limit", filter.Limit))
+
+	messages, err := h.outboxStore.List(ctx, filter)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to fetch outbox messages",
+			logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to fetch messages"))
+		return
+	}
+
+	var nextCursor *int64
+	if len(messages) == filter.Limit {
+		nextCursor = &messages[len(messages)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":       len(messages),
+		"messages":    messages,
+		"next_cursor": nextCursor,
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerGroup pairs a worker pool with the table it drains. Pool is nil when
+// the workers are disabled, in which case only the backlog is reported.
+type WorkerGroup struct {
+	Pool  *outboxinbox.WorkerPool
+	Store outboxinbox.StatsReader
+}
+
+// WorkerHandler reports the health of the inbox/outbox workers.
+type WorkerHandler struct {
+	logger logger.Logger
+	groups []WorkerGroup
+}
+
+func NewWorkerHandler(log logger.Logger, groups ...WorkerGroup) *WorkerHandler {
+	return &WorkerHandler{
+		logger: log,
+		groups: groups,
+	}
+}
+
+// GetWorkers returns each table's backlog along with the last poll, batch
+// size and error streak of every worker draining it.
+func (h *WorkerHandler) GetWorkers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tables := make([]gin.H, 0, len(h.groups))
+	for _, g := range h.groups {
+		cfg := g.Store.Config()
+
+		table := gin.H{
+			"table":   cfg.TableName,
+			"running": g.Pool != nil,
+			"workers": []outboxinbox.WorkerStatus{},
+		}
+		if g.Pool != nil {
+			table["pool"] = g.Pool.Name()
+			table["workers"] = g.Pool.Status()
+		}
+
+		stats, err := g.Store.Stats(ctx)
+		if err != nil {
+			h.logger.ErrorCtx(ctx, "Failed to read worker backlog",
+				logger.Err(err),
+				logger.String("table", cfg.TableName))
+			table["backlog_error"] = "Failed to read backlog"
+		} else {
+			table["backlog"] = gin.H{
+				"pending":                    stats.Counts[cfg.Statuses.Pending],
+				"processing":                 stats.Counts[cfg.Statuses.Processing],
+				"oldest_pending_age_seconds": stats.OldestPendingAge.Seconds(),
+			}
+		}
+
+		tables = append(tables, table)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tables": tables,
+	})
+}
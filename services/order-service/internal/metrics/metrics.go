@@ -1,62 +1,147 @@
 package metrics
 
 import (
-	"sync"
+	"context"
+	"time"
+
+	"observability-system/shared/apiversion"
+	"observability-system/shared/cache"
+	"observability-system/shared/chaos"
+	"observability-system/shared/lock"
+	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/ratelimit"
+	"observability-system/shared/secrets"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// The service's business metrics, registered by InitMetrics.
 var (
-	once              sync.Once
-	HTTPRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"service", "method", "path", "status"},
-	)
-
-	HTTPRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"service", "method", "path"},
-	)
-
-	HTTPResponseSize = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_response_size_bytes",
-			Help:    "HTTP response size in bytes",
-			Buckets: prometheus.ExponentialBuckets(100, 10, 8),
-		},
-		[]string{"service", "method", "path"},
-	)
-
-	OrdersCreatedTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "orders_created_total",
-			Help: "Total number of orders created",
-		},
-		[]string{"service"},
-	)
-
-	OrdersByStatusTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "orders_by_status_total",
-			Help: "Total number of orders by status",
-		},
-		[]string{"service", "status"},
-	)
+	ordersCreatedTotal          *prometheus.CounterVec
+	ordersByStatusTotal         *prometheus.CounterVec
+	ordersArchivedTotal         *prometheus.CounterVec
+	inboxHandlerExecutionsTotal *prometheus.CounterVec
+	inboxHandlerDuration        prometheus.ObserverVec
+)
+
+// DefaultBuckets tunes the shared histograms to order-service, whose
+// requests range from millisecond reads to order creation waiting on
+// warehouse calls that time out after tens of seconds.
+var DefaultBuckets = map[string][]float64{
+	sharedmetrics.HTTPRequestDuration: {.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}
+
+// SLOs are order-service's service level objectives. cmd/slo-rules turns
+// them into the burn-rate alerts in infrastructure/prometheus-rules.
+var SLOs = []sharedmetrics.SLO{
+	{
+		Name:      "create-order-availability",
+		Method:    "POST",
+		Routes:    []string{"/api/v1/orders", "/api/orders"},
+		Objective: 0.999,
+	},
+	{
+		// Order creation waits on warehouse-service, so it gets the most
+		// room of the latency objectives.
+		Name:      "create-order-latency",
+		Method:    "POST",
+		Routes:    []string{"/api/v1/orders", "/api/orders"},
+		Objective: 0.99,
+		Latency:   time.Second,
+	},
+	{
+		Name:      "get-order-availability",
+		Method:    "GET",
+		Routes:    []string{"/api/v1/orders/:order_id", "/api/orders/:order_id"},
+		Objective: 0.999,
+	},
+	{
+		Name:      "get-order-latency",
+		Method:    "GET",
+		Routes:    []string{"/api/v1/orders/:order_id", "/api/orders/:order_id"},
+		Objective: 0.99,
+		Latency:   100 * time.Millisecond,
+	},
+}
+
+// InitMetrics builds the service's registry: the shared HTTP metrics, the
+// service's business metrics and those of Collectors, tracking SLOs.
+// cfg.Buckets overrides DefaultBuckets per metric.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	buckets := make(map[string][]float64, len(DefaultBuckets)+len(cfg.Buckets))
+	for name, b := range DefaultBuckets {
+		buckets[name] = b
+	}
+	for name, b := range cfg.Buckets {
+		buckets[name] = b
+	}
+	cfg.Buckets = buckets
+
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...).
+		MustAddSLOs(SLOs...)
+
+	ordersCreatedTotal = registry.Counter("orders_created_total",
+		"Total number of orders created")
+	ordersByStatusTotal = registry.Counter("orders_by_status_total",
+		"Total number of orders by status", "status")
+	ordersArchivedTotal = registry.Counter("orders_archived_total",
+		"Total number of orders moved out of the orders table by the archiver", "mode")
+
+	inboxHandlerExecutionsTotal = registry.Counter("inbox_handler_executions_total",
+		"Total number of inbox handler executions by event type and outcome", "event_type", "outcome")
+	inboxHandlerDuration = registry.Histogram("inbox_handler_duration_seconds",
+		"Time inbox handlers took by event type and outcome", nil, "event_type", "outcome")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the service uses.
+func Collectors() []prometheus.Collector {
+	var collectors []prometheus.Collector
+	collectors = append(collectors, outboxinbox.Collectors()...)
+	collectors = append(collectors, ratelimit.Collectors()...)
+	collectors = append(collectors, apiversion.Collectors()...)
+	collectors = append(collectors, rabbitmq.Collectors()...)
+	collectors = append(collectors, secrets.Collectors()...)
+	collectors = append(collectors, lock.Collectors()...)
+	collectors = append(collectors, cache.Collectors()...)
+	collectors = append(collectors, chaos.Collectors()...)
+	return collectors
+}
+
+// RecordOrderCreated counts a newly stored order and the status it was
+// stored with.
+func RecordOrderCreated(status string) {
+	ordersCreatedTotal.WithLabelValues().Inc()
+	RecordOrderStatus(status)
+}
+
+// RecordOrderStatus counts an order entering status, so the by-status
+// counter reflects transitions rather than how often orders are read.
+func RecordOrderStatus(status string) {
+	ordersByStatusTotal.WithLabelValues(status).Inc()
+}
+
+// RecordOrdersArchived counts orders the archiver archived or purged, as
+// given by mode.
+func RecordOrdersArchived(mode string, count int64) {
+	ordersArchivedTotal.WithLabelValues(mode).Add(float64(count))
+}
+
+// Inbox handler outcomes recorded by RecordEventHandled.
+const (
+	EventSuccess          = "success"
+	EventRetry            = "retry"
+	EventFailed           = "failed"
+	EventSkippedNoHandler = "skipped_no_handler"
 )
 
-func InitMetrics(serviceName string) {
-	once.Do(func() {
-		prometheus.MustRegister(HTTPRequestsTotal)
-		prometheus.MustRegister(HTTPRequestDuration)
-		prometheus.MustRegister(HTTPResponseSize)
-		prometheus.MustRegister(OrdersCreatedTotal)
-		prometheus.MustRegister(OrdersByStatusTotal)
-	})
+// RecordEventHandled counts an inbox handler execution by event type and
+// outcome and records how long it took.
+func RecordEventHandled(ctx context.Context, eventType, outcome string, duration time.Duration) {
+	inboxHandlerExecutionsTotal.WithLabelValues(eventType, outcome).Inc()
+	sharedmetrics.Observe(ctx, inboxHandlerDuration.WithLabelValues(eventType, outcome), duration.Seconds())
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"math"
+	"strings"
+)
+
+// DefaultCurrency is used for orders created without a currency.
+const DefaultCurrency = "USD"
+
+// MaxOrderAmount is the largest amount the orders table's DECIMAL(10, 2)
+// columns can hold.
+const MaxOrderAmount = 99999999.99
+
+// currencies are the active ISO 4217 codes orders can be priced in.
+var currencies = map[string]bool{
+	"AED": true, "ARS": true, "AUD": true, "BGN": true, "BRL": true, "CAD": true,
+	"CHF": true, "CLP": true, "CNY": true, "COP": true, "CZK": true, "DKK": true,
+	"EGP": true, "EUR": true, "GBP": true, "HKD": true, "HUF": true, "IDR": true,
+	"ILS": true, "INR": true, "ISK": true, "JPY": true, "KRW": true, "MXN": true,
+	"MYR": true, "NGN": true, "NOK": true, "NZD": true, "PEN": true, "PHP": true,
+	"PKR": true, "PLN": true, "RON": true, "SAR": true, "SEK": true, "SGD": true,
+	"THB": true, "TRY": true, "TWD": true, "UAH": true, "USD": true, "VND": true,
+	"ZAR": true,
+}
+
+// NormalizeCurrency upper-cases code and reports whether it is a supported
+// ISO 4217 currency. An empty code falls back to DefaultCurrency.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, true
+	}
+	return code, currencies[code]
+}
+
+// OrderTotal is quantity units at unitPrice, rounded to cents like the
+// stored amounts.
+func OrderTotal(unitPrice float64, quantity int) float64 {
+	return math.Round(unitPrice*float64(quantity)*100) / 100
+}
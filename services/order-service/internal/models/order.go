@@ -1 +1,154 @@
 package models
+
+import "time"
+
+// Order lifecycle events written to the outbox alongside the order row.
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderUpdated   = "order.updated"
+	EventOrderCancelled = "order.cancelled"
+	EventOrderExpired   = "order.expired"
+	EventOrderRejected  = "order.rejected"
+	EventOrderDeleted   = "order.deleted"
+)
+
+// Events published by warehouse-service about the stock of an order.
+// inventory.reserved confirms orders created asynchronously; with
+// event-driven reservation, inventory.rejected rejects those whose stock
+// could not be reserved.
+const (
+	EventInventoryReserved = "inventory.reserved"
+	EventInventoryRejected = "inventory.rejected"
+)
+
+const (
+	OrderStatusPending       = "pending"
+	OrderStatusStockReserved = "stock_reserved"
+	OrderStatusConfirmed     = "confirmed"
+	OrderStatusPaymentFailed = "payment_failed"
+	OrderStatusExpired       = "expired"
+	// OrderStatusPendingStockCheck marks an order accepted while
+	// warehouse-service was unavailable; the stock reconciler confirms or
+	// rejects it once the warehouse is back.
+	OrderStatusPendingStockCheck = "pending_stock_check"
+	OrderStatusRejected          = "rejected"
+)
+
+// OrderStatuses lists every order status.
+var OrderStatuses = []string{
+	OrderStatusPending,
+	OrderStatusStockReserved,
+	OrderStatusConfirmed,
+	OrderStatusPaymentFailed,
+	OrderStatusExpired,
+	OrderStatusPendingStockCheck,
+	OrderStatusRejected,
+}
+
+// CompletedOrderStatuses are the final statuses. Only completed orders can be
+// deleted or archived; the others are still being worked on.
+var CompletedOrderStatuses = []string{
+	OrderStatusConfirmed,
+	OrderStatusPaymentFailed,
+	OrderStatusExpired,
+	OrderStatusRejected,
+}
+
+// Event is an outbox event written in the same transaction as an order
+// change.
+type Event struct {
+	Type    string
+	Payload interface{}
+}
+
+type Order struct {
+	Seq            int64     `db:"id" json:"-"`
+	ID             string    `db:"order_id" json:"id"`
+	CustomerID     string    `db:"customer_id" json:"customer_id,omitempty"`
+	ProductID      string    `db:"product_id" json:"product_id"`
+	ProductName    string    `db:"product_name" json:"product_name"`
+	Quantity       int       `db:"quantity" json:"quantity"`
+	UnitPrice      float64   `db:"unit_price" json:"unit_price"`
+	TotalAmount    float64   `db:"total_amount" json:"total_amount"`
+	Currency       string    `db:"currency" json:"currency"`
+	Status         string    `db:"status" json:"status"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	StockReserved  bool      `db:"stock_reserved" json:"stock_reserved"`
+	PaymentID      string    `db:"payment_id" json:"payment_id,omitempty"`
+	AvailableStock int       `db:"-" json:"available_stock,omitempty"`
+}
+
+// OrderItem is a line of an order event's items.
+type OrderItem struct {
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+// OrderEvent is the payload of the order lifecycle events.
+type OrderEvent struct {
+	OrderID     string  `json:"order_id"`
+	CustomerID  string  `json:"customer_id,omitempty"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	TotalAmount float64 `json:"total_amount"`
+	Currency    string  `json:"currency"`
+	Status      string  `json:"status"`
+	// StockReserved tells warehouse-service whether the order's stock is
+	// already reserved; pending orders without it are reserved from the
+	// order.created event.
+	StockReserved bool `json:"stock_reserved"`
+	// PaymentID is the authorized payment, whose own events payment-service
+	// publishes.
+	PaymentID string      `json:"payment_id,omitempty"`
+	Items     []OrderItem `json:"items"`
+	CreatedAt time.Time   `json:"created_at"`
+	// Reason explains an order.rejected event.
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewOrderEvent builds the event payload describing order's current state.
+func NewOrderEvent(order *Order) OrderEvent {
+	return OrderEvent{
+		OrderID:       order.ID,
+		CustomerID:    order.CustomerID,
+		ProductID:     order.ProductID,
+		ProductName:   order.ProductName,
+		Quantity:      order.Quantity,
+		UnitPrice:     order.UnitPrice,
+		TotalAmount:   order.TotalAmount,
+		Currency:      order.Currency,
+		Status:        order.Status,
+		StockReserved: order.StockReserved,
+		PaymentID:     order.PaymentID,
+		Items:         []OrderItem{{SKU: order.ProductID, Quantity: order.Quantity, Price: order.UnitPrice}},
+		CreatedAt:     order.CreatedAt,
+	}
+}
+
+// InventoryReservedEvent is the payload of inventory.reserved.
+type InventoryReservedEvent struct {
+	ReservationID string    `json:"reservation_id"`
+	OrderID       string    `json:"order_id"`
+	ProductID     string    `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Quantity      int       `json:"quantity"`
+	NewAvailable  int       `json:"new_available"`
+	ReservedAt    time.Time `json:"reserved_at"`
+	// Unannounced marks reservations that were not made to be confirmed by
+	// their event, e.g. those of synchronously confirmed orders.
+	Unannounced bool `json:"unannounced"`
+}
+
+// InventoryRejectedEvent is the payload of inventory.rejected.
+type InventoryRejectedEvent struct {
+	OrderID    string    `json:"order_id"`
+	ProductID  string    `json:"product_id"`
+	Quantity   int       `json:"quantity"`
+	Code       string    `json:"code"`
+	Reason     string    `json:"reason"`
+	Available  int       `json:"available"`
+	RejectedAt time.Time `json:"rejected_at"`
+}
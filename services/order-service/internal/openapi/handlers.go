@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIVersion pins the Swagger UI assets loaded from the CDN.
+const swaggerUIVersion = "5.17.14"
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%[1]s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: %[3]q, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+// DocumentHandler serves the OpenAPI document. It is built on the first
+// request, after every route has been registered.
+func (r *Registry) DocumentHandler() gin.HandlerFunc {
+	var once sync.Once
+	var doc map[string]interface{}
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = r.Document()
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// SwaggerUIHandler serves a Swagger UI page rendering the document at
+// specURL.
+func (r *Registry) SwaggerUIHandler(specURL string) gin.HandlerFunc {
+	page := fmt.Sprintf(swaggerUIPage, html.EscapeString(r.title), swaggerUIVersion, specURL)
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
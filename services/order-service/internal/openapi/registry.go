@@ -0,0 +1,216 @@
+// Package openapi registers gin routes together with a description of their
+// parameters, request body and responses, and builds an OpenAPI 3 document
+// from them, so the served spec cannot drift from the routes that exist.
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openAPIVersion = "3.0.3"
+
+// Operation documents a route. Request and response bodies are given as
+// values of the Go types the handler binds and writes; their schemas are
+// derived from the types' json and binding tags.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Query       []Param
+	// Request is a value of the request body type, nil for routes without
+	// a body.
+	Request   interface{}
+	Responses []Response
+	// Deprecated marks routes kept only for existing clients.
+	Deprecated bool
+}
+
+// Param is a query parameter. Type is a JSON schema type such as "string"
+// or "integer"; Format optionally refines it, e.g. "date-time".
+type Param struct {
+	Name        string
+	Type        string
+	Format      string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Response documents one status code. Body is a value of the response type,
+// or nil for bodies that are not JSON. ContentType defaults to JSON.
+type Response struct {
+	Status      int
+	Description string
+	Body        interface{}
+	ContentType string
+}
+
+type route struct {
+	method string
+	path   string
+	op     Operation
+}
+
+// Registry records every route registered through it.
+type Registry struct {
+	title   string
+	version string
+	routes  []route
+}
+
+func NewRegistry(title, version string) *Registry {
+	return &Registry{
+		title:   title,
+		version: version,
+	}
+}
+
+// Handle registers handlers on group and documents the route as op.
+func (r *Registry) Handle(group *gin.RouterGroup, method, path string, op Operation, handlers ...gin.HandlerFunc) {
+	group.Handle(method, path, handlers...)
+
+	full := strings.TrimSuffix(group.BasePath(), "/") + "/" + strings.TrimPrefix(path, "/")
+	r.routes = append(r.routes, route{method: method, path: full, op: op})
+}
+
+// Document builds the OpenAPI document of the registered routes.
+func (r *Registry) Document() map[string]interface{} {
+	schemas := newSchemaRegistry()
+	paths := map[string]map[string]interface{}{}
+
+	for _, rt := range r.routes {
+		path, pathParams := openAPIPath(rt.path)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(rt.method)] = r.operation(rt, pathParams, schemas)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   r.title,
+			"version": r.version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+		},
+	}
+}
+
+func (r *Registry) operation(rt route, pathParams []string, schemas *schemaRegistry) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(rt.method, rt.path),
+	}
+	if rt.op.Summary != "" {
+		op["summary"] = rt.op.Summary
+	}
+	if rt.op.Deprecated {
+		op["deprecated"] = true
+	}
+	if rt.op.Description != "" {
+		op["description"] = rt.op.Description
+	}
+	if len(rt.op.Tags) > 0 {
+		op["tags"] = rt.op.Tags
+	}
+
+	var params []interface{}
+	for _, name := range pathParams {
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range rt.op.Query {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Format != "" {
+			schema["format"] = p.Format
+		}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+		param := map[string]interface{}{
+			"name":   p.Name,
+			"in":     "query",
+			"schema": schema,
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if rt.op.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": schemas.schemaOf(rt.op.Request),
+				},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for _, resp := range rt.op.Responses {
+		description := resp.Description
+		if description == "" {
+			description = http.StatusText(resp.Status)
+		}
+		entry := map[string]interface{}{"description": description}
+		if resp.Body != nil || resp.ContentType != "" {
+			contentType := resp.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			media := map[string]interface{}{}
+			if resp.Body != nil {
+				media["schema"] = schemas.schemaOf(resp.Body)
+			}
+			entry["content"] = map[string]interface{}{contentType: media}
+		}
+		responses[strconv.Itoa(resp.Status)] = entry
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "Response"}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// openAPIPath converts gin's :param segments to {param} and returns the
+// parameter names.
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable id such as "post_api_orders" or
+// "get_api_orders_order_id".
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_", "{", "", "}", "")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+	"order-service/internal/models"
+	"order-service/internal/openapi"
+)
+
+// The types below only describe response bodies for the OpenAPI document;
+// the handlers write the same fields as gin.H maps.
+
+// problemResponse documents an RFC 7807 error response.
+func problemResponse(status int, description string) openapi.Response {
+	return openapi.Response{
+		Status:      status,
+		Description: description,
+		Body:        problem.Problem{},
+		ContentType: problem.ContentType,
+	}
+}
+
+type workerBacklog struct {
+	Pending                 int     `json:"pending"`
+	Processing              int     `json:"processing"`
+	OldestPendingAgeSeconds float64 `json:"oldest_pending_age_seconds"`
+}
+
+type workerTable struct {
+	Table        string                     `json:"table"`
+	Running      bool                       `json:"running"`
+	Pool         string                     `json:"pool,omitempty"`
+	Workers      []outboxinbox.WorkerStatus `json:"workers"`
+	Backlog      *workerBacklog             `json:"backlog,omitempty"`
+	BacklogError string                     `json:"backlog_error,omitempty"`
+}
+
+type workersResponse struct {
+	Tables []workerTable `json:"tables"`
+}
+
+type configResponse struct {
+	Service string                 `json:"service"`
+	Config  map[string]interface{} `json:"config"`
+}
+
+type inboxPage struct {
+	Count      int                        `json:"count"`
+	Messages   []outboxinbox.InboxMessage `json:"messages"`
+	NextCursor *int64                     `json:"next_cursor"`
+}
+
+type outboxPage struct {
+	Count      int                         `json:"count"`
+	Messages   []outboxinbox.OutboxMessage `json:"messages"`
+	NextCursor *int64                      `json:"next_cursor"`
+}
+
+type createInboxResponse struct {
+	Message   string `json:"message"`
+	MessageID string `json:"message_id"`
+	EventType string `json:"event_type"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+type orderPage struct {
+	Count      int            `json:"count"`
+	Total      int64          `json:"total"`
+	Orders     []models.Order `json:"orders"`
+	NextCursor *int64         `json:"next_cursor"`
+}
+
+type createOrderResponse struct {
+	Message       string       `json:"message"`
+	Order         models.Order `json:"order"`
+	StockReserved int          `json:"stock_reserved"`
+	RequestID     string       `json:"request_id"`
+}
+
+type acceptedOrderResponse struct {
+	Message string       `json:"message"`
+	Order   models.Order `json:"order"`
+	// StockReserved is only set for orders awaiting reservation
+	// confirmation.
+	StockReserved int    `json:"stock_reserved,omitempty"`
+	RequestID     string `json:"request_id"`
+}
+
+type testOutboxResponse struct {
+	Message   string `json:"message"`
+	MessageID string `json:"message_id"`
+}
+
+type deadLetterPage struct {
+	Count    int                             `json:"count"`
+	Limit    int                             `json:"limit"`
+	Offset   int                             `json:"offset"`
+	Messages []outboxinbox.DeadLetterMessage `json:"messages"`
+}
+
+type quarantinePage struct {
+	Count    int                              `json:"count"`
+	Limit    int                              `json:"limit"`
+	Offset   int                              `json:"offset"`
+	Messages []outboxinbox.QuarantinedMessage `json:"messages"`
+}
+
+type requeueResponse struct {
+	Message   string `json:"message"`
+	MessageID string `json:"message_id"`
+	RequestID string `json:"request_id"`
+}
+
+type replayResponse struct {
+	Message          string `json:"message"`
+	MessageID        string `json:"message_id"`
+	PayloadRewritten bool   `json:"payload_rewritten"`
+	RequestID        string `json:"request_id"`
+}
+
+type dryRunResponse struct {
+	DryRun           bool                         `json:"dry_run"`
+	PayloadRewritten bool                         `json:"payload_rewritten"`
+	Result           outboxinbox.SimulationResult `json:"result"`
+	RequestID        string                       `json:"request_id"`
+}
+
+type bulkReplayResponse struct {
+	Message   string `json:"message"`
+	Count     int64  `json:"count"`
+	RequestID string `json:"request_id"`
+}
@@ -1,43 +1,515 @@
 package routes
 
 import (
+	"net/http"
+
+	"observability-system/shared/apiversion"
+	"observability-system/shared/chaos"
+	"observability-system/shared/health"
 	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
 	"observability-system/shared/tracing"
+	"order-service/internal/graphql"
 	"order-service/internal/handlers"
-	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/openapi"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Middleware holds the optional per-route middleware configured in main; nil
+// fields are skipped.
+type Middleware struct {
+	Auth           gin.HandlerFunc
+	OrderRateLimit gin.HandlerFunc
+	InboxRateLimit gin.HandlerFunc
+	// InboxSignature verifies the HMAC signature of POST /api/inbox.
+	InboxSignature gin.HandlerFunc
+	// Timeout and MaxBodySize apply to every route, with per-route limits
+	// resolved by the middleware itself.
+	Timeout     gin.HandlerFunc
+	MaxBodySize gin.HandlerFunc
+	// MetricsAuth guards /metrics and /internal/ops; nil serves them openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+	// Deprecated marks the unversioned /api aliases of the /api/v1 routes;
+	// nil removes the aliases.
+	Deprecated gin.HandlerFunc
+	// Chaos injects faults into the API and GraphQL routes; probes,
+	// /metrics and the admin routes are left alone.
+	Chaos gin.HandlerFunc
+}
+
+// versionedGroup registers each API route under /api/v1 and, while the
+// unversioned aliases are kept, under /api as a deprecated alias.
+type versionedGroup struct {
+	reg    *openapi.Registry
+	v1     *gin.RouterGroup
+	legacy *gin.RouterGroup
+}
+
+func (g versionedGroup) Handle(method, path string, op openapi.Operation, handlers ...gin.HandlerFunc) {
+	g.reg.Handle(g.v1, method, path, op, handlers...)
+	if g.legacy != nil {
+		op.Deprecated = true
+		g.reg.Handle(g.legacy, method, path, op, handlers...)
+	}
+}
+
+// Every documented route is registered through the openapi registry, which
+// serves the resulting spec at /openapi.json and Swagger UI at /docs.
+// debugEndpoints adds the routes that inject faults or write arbitrary
+// messages: /admin/chaos and /api/test-outbox.
 func SetupRoutes(
 	router *gin.Engine,
 	log logger.Logger,
 	serviceName string,
 	inboxHandler *handlers.InboxHandler,
+	outboxHandler *handlers.OutboxHandler,
 	orderHandler *handlers.OrderHandler,
+	adminHandler *handlers.AdminHandler,
+	workerHandler *handlers.WorkerHandler,
+	opsReporter *ops.Reporter,
+	graphqlHandler *handlers.GraphQLHandler,
+	chaosHandler *chaos.Handler,
+	debugEndpoints bool,
+	configHandler gin.HandlerFunc,
+	prober *health.Prober,
+	registry *metrics.Registry,
+	mw Middleware,
 ) {
 
 	router.Use(tracing.GinMiddleware(serviceName))
 
 	router.Use(logger.InjectLogger(log))
 	router.Use(logger.GinMiddleware(log))
-	router.Use(gin.Recovery())
+	router.Use(problem.Recovery())
+
+	// The metrics middleware runs before authentication so rejected
+	// requests are still counted.
+	router.Use(registry.Middleware())
+
+	// Authentication runs after the logger so rejected requests are logged
+	// with their request id. A nil middleware leaves every route public.
+	if mw.Auth != nil {
+		router.Use(mw.Auth)
+	}
+
+	router.Use(chain(mw.Timeout, mw.MaxBodySize)...)
+
+	router.NoRoute(problem.NoRoute)
+
+	reg := openapi.NewRegistry("order-service API", "1.0.0")
+	root := &router.RouterGroup
+
+	router.GET("/openapi.json", reg.DocumentHandler())
+	router.GET("/docs", reg.SwaggerUIHandler("/openapi.json"))
+
+	reg.Handle(root, http.MethodGet, "/health", openapi.Operation{
+		Summary:     "Health report",
+		Description: "Runs the liveness, readiness and diagnostic checks. Fails only with a liveness or readiness check; failed diagnostics, e.g. low disk space or an unreachable payment service, report degraded.",
+		Tags:        []string{"system"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: health.Summary{}},
+			{Status: http.StatusServiceUnavailable, Description: "A liveness or readiness check failed", Body: health.Summary{}},
+		},
+	}, prober.Health)
+	reg.Handle(root, http.MethodGet, "/live", openapi.Operation{
+		Summary:     "Liveness probe",
+		Description: "Fails when a worker has stopped polling; restarting the instance may help.",
+		Tags:        []string{"system"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: health.Report{}},
+			{Status: http.StatusServiceUnavailable, Description: "A check failed", Body: health.Report{}},
+		},
+	}, prober.Live)
+	reg.Handle(root, http.MethodGet, "/ready", openapi.Operation{
+		Summary:     "Readiness probe",
+		Description: "Checks the database, RabbitMQ and warehouse-service; failing instances should receive no traffic.",
+		Tags:        []string{"system"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: health.Report{}},
+			{Status: http.StatusServiceUnavailable, Description: "A check failed", Body: health.Report{}},
+		},
+	}, prober.Ready)
+	reg.Handle(root, http.MethodGet, "/metrics", openapi.Operation{
+		Summary:     "Prometheus metrics",
+		Description: "Requires the configured bearer token or basic-auth credentials, and an allowed address, when /metrics is protected.",
+		Tags:        []string{"system"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, ContentType: "text/plain"},
+			problemResponse(http.StatusUnauthorized, "unauthorized: missing or invalid credentials"),
+			problemResponse(http.StatusForbidden, "forbidden: the client's address is not allowed"),
+		},
+	}, chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	reg.Handle(root, http.MethodGet, "/internal/workers", openapi.Operation{
+		Summary:   "Inbox and outbox worker status and backlog",
+		Tags:      []string{"system"},
+		Responses: []openapi.Response{{Status: http.StatusOK, Body: workersResponse{}}},
+	}, workerHandler.GetWorkers)
+	reg.Handle(root, http.MethodGet, "/internal/ops", openapi.Operation{
+		Summary:     "Operational snapshot for the admin service",
+		Description: "The backlog, latest dead-lettered and quarantined messages and workers of the inbox and outbox, and the newest orders. Guarded like /metrics.",
+		Tags:        []string{"system"},
+		Query: []openapi.Param{
+			{Name: "limit", Type: "integer", Description: "Failed messages per table and orders listed, capped at 100"},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: ops.Snapshot{}},
+			problemResponse(http.StatusBadRequest, ""),
+			problemResponse(http.StatusUnauthorized, "unauthorized: missing or invalid credentials"),
+			problemResponse(http.StatusForbidden, "forbidden: the client's address is not allowed"),
+		},
+	}, chain(mw.MetricsAuth, opsReporter.Handler)...)
+	if mw.ConfigAuth != nil {
+		reg.Handle(root, http.MethodGet, "/internal/config", openapi.Operation{
+			Summary:     "Effective configuration with secrets masked",
+			Description: "Requires the /metrics credentials; the route exists only when they are configured.",
+			Tags:        []string{"system"},
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: configResponse{}},
+				problemResponse(http.StatusUnauthorized, "unauthorized: missing or invalid credentials"),
+				problemResponse(http.StatusForbidden, "forbidden: the client's address is not allowed"),
+			},
+		}, mw.ConfigAuth, configHandler)
+	}
+
+	graphQLResponses := []openapi.Response{
+		{Status: http.StatusOK, Description: "Query result; resolver failures are reported in errors", Body: graphql.Result{}},
+		problemResponse(http.StatusBadRequest, ""),
+	}
+	reg.Handle(root, http.MethodGet, "/graphql", openapi.Operation{
+		Summary: "Execute a GraphQL query",
+		Tags:    []string{"graphql"},
+		Query: []openapi.Param{
+			{Name: "query", Type: "string", Required: true},
+			{Name: "operationName", Type: "string"},
+			{Name: "variables", Type: "string", Description: "JSON-encoded variables object"},
+		},
+		Responses: graphQLResponses,
+	}, chain(mw.Chaos, graphqlHandler.Query)...)
+	reg.Handle(root, http.MethodPost, "/graphql", openapi.Operation{
+		Summary:   "Execute a GraphQL query",
+		Tags:      []string{"graphql"},
+		Request:   graphql.Request{},
+		Responses: graphQLResponses,
+	}, chain(mw.Chaos, graphqlHandler.Query)...)
 
-	router.Use(metrics.PrometheusMiddleware(serviceName))
+	api := versionedGroup{
+		reg: reg,
+		v1:  router.Group("/api/v1", chain(apiversion.Version(apiversion.V1), mw.Chaos)...),
+	}
+	if mw.Deprecated != nil {
+		api.legacy = router.Group("/api", chain(apiversion.Version(apiversion.V1), mw.Deprecated, mw.Chaos)...)
+	}
+	{
+		api.Handle(http.MethodPost, "/inbox", openapi.Operation{
+			Summary:     "Receive an inbox message",
+			Description: "Redelivered messages with a known message_id are acknowledged with 200 and duplicate set. When sender secrets are configured, requests must be signed: X-Sender-ID names the sender, X-Signature-Timestamp is the Unix time in seconds and X-Signature is \"sha256=\" followed by the hex HMAC-SHA256 of the timestamp, a dot and the raw body, keyed with the sender's secret.",
+			Tags:        []string{"inbox"},
+			Request:     handlers.CreateInboxMessageRequest{},
+			Responses: []openapi.Response{
+				{Status: http.StatusCreated, Body: createInboxResponse{}},
+				{Status: http.StatusOK, Description: "Duplicate message", Body: createInboxResponse{}},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusUnauthorized, "unauthorized: the signature is missing, invalid, outside the replay window or replayed; the reason member says which"),
+				problemResponse(http.StatusForbidden, "forbidden: the body's sender_id is not the signing sender"),
+				problemResponse(http.StatusRequestEntityTooLarge, ""),
+				rateLimitedResponse,
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, chain(mw.InboxRateLimit, mw.InboxSignature, inboxHandler.CreateInboxMessage)...)
+		api.Handle(http.MethodGet, "/inbox", openapi.Operation{
+			Summary: "List inbox messages",
+			Tags:    []string{"inbox"},
+			Query:   messageListParams(),
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: inboxPage{}},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, inboxHandler.GetInboxMessages)
+		api.Handle(http.MethodGet, "/outbox", openapi.Operation{
+			Summary: "List outbox messages",
+			Tags:    []string{"outbox"},
+			Query:   messageListParams(),
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: outboxPage{}},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, outboxHandler.GetOutboxMessages)
 
-	router.GET("/health", inboxHandler.HealthCheck)
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+		api.Handle(http.MethodPost, "/orders", openapi.Operation{
+			Summary:     "Create an order",
+			Description: "Checks and reserves stock with warehouse-service, then authorizes the payment when a payment service is configured.",
+			Tags:        []string{"orders"},
+			Request:     handlers.CreateOrderRequest{},
+			Responses: []openapi.Response{
+				{Status: http.StatusCreated, Body: createOrderResponse{}},
+				{Status: http.StatusAccepted, Description: "Degraded mode: warehouse-service was unavailable, the order was stored as pending_stock_check and will be confirmed or rejected later. Async confirmation: the stock is reserved and the order stays pending until its inventory.reserved event arrives", Body: acceptedOrderResponse{}},
+				problemResponse(http.StatusBadRequest, "validation_failed, including unsupported currency codes and totals above 99999999.99"),
+				problemResponse(http.StatusPaymentRequired, "payment_failed: the payment was declined and the reserved stock released; the order member holds the stored order"),
+				problemResponse(http.StatusConflict, "insufficient_stock, with the available and requested quantities"),
+				rateLimitedResponse,
+				problemResponse(http.StatusInternalServerError, ""),
+				problemResponse(http.StatusServiceUnavailable, "dependency_unavailable: warehouse-service could not be reached and degraded mode is off, or the payment service failed; after a payment failure the reserved stock is released and the order member holds the stored order"),
+			},
+		}, chain(mw.OrderRateLimit, orderHandler.CreateOrder)...)
+		api.Handle(http.MethodGet, "/orders", openapi.Operation{
+			Summary: "List orders",
+			Tags:    []string{"orders"},
+			Query: append(listParams(models.OrderStatuses...),
+				openapi.Param{Name: "product_id", Type: "string"},
+				openapi.Param{Name: "customer_id", Type: "string"},
+				openapi.Param{Name: "order_id", Type: "string", Description: "Order IDs, repeated or comma-separated, at most 500"},
+			),
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: orderPage{}},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.GetAllOrders)
+		api.Handle(http.MethodGet, "/orders/export", openapi.Operation{
+			Summary:     "Export orders as CSV or NDJSON",
+			Description: "Streams every order matching the filters as a file download; limit is ignored and cursor sets where the export starts. format=ndjson returns application/x-ndjson with one order object per line.",
+			Tags:        []string{"orders"},
+			Query: append(listParams(models.OrderStatuses...),
+				openapi.Param{Name: "product_id", Type: "string"},
+				openapi.Param{Name: "customer_id", Type: "string"},
+				openapi.Param{Name: "order_id", Type: "string", Description: "Order IDs, repeated or comma-separated, at most 500"},
+				openapi.Param{Name: "format", Type: "string", Enum: []string{handlers.ExportFormatCSV, handlers.ExportFormatNDJSON}},
+			),
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Description: "CSV with a header row, or NDJSON", ContentType: "text/csv"},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.ExportOrders)
+		api.Handle(http.MethodGet, "/orders/search", openapi.Operation{
+			Summary:     "Search orders",
+			Description: "Combines the listing filters with q, a case-insensitive substring matched against the order ID, customer ID, product name and payment ID. At least one criterion is required.",
+			Tags:        []string{"orders"},
+			Query: append(listParams(models.OrderStatuses...),
+				openapi.Param{Name: "q", Type: "string", Description: "Reference or free text, at least 3 characters"},
+				openapi.Param{Name: "product_id", Type: "string"},
+				openapi.Param{Name: "customer_id", Type: "string"},
+				openapi.Param{Name: "order_id", Type: "string", Description: "Order IDs, repeated or comma-separated, at most 500"},
+			),
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: orderPage{}},
+				problemResponse(http.StatusBadRequest, "validation_failed: no criterion given, or q is shorter than 3 characters"),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.SearchOrders)
+		api.Handle(http.MethodGet, "/orders/:order_id", openapi.Operation{
+			Summary: "Get an order",
+			Tags:    []string{"orders"},
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Body: models.Order{}},
+				problemResponse(http.StatusNotFound, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.GetOrder)
+		api.Handle(http.MethodDelete, "/orders/:order_id", openapi.Operation{
+			Summary:     "Delete a completed order",
+			Description: "Soft-deletes the order and emits order.deleted. It is hidden from every endpoint at once and archived or purged after ORDER_RETENTION_PERIOD.",
+			Tags:        []string{"orders"},
+			Responses: []openapi.Response{
+				{Status: http.StatusNoContent, Description: "Deleted"},
+				problemResponse(http.StatusNotFound, ""),
+				problemResponse(http.StatusConflict, "conflict: the order is still in progress, see the status member"),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.DeleteOrder)
+	}
 
-	api := router.Group("/api")
+	if debugEndpoints {
+		api.Handle(http.MethodPost, "/test-outbox", openapi.Operation{
+			Summary: "Write an arbitrary outbox message",
+			Tags:    []string{"outbox"},
+			Request: handlers.TestOutboxRequest{},
+			Responses: []openapi.Response{
+				{Status: http.StatusCreated, Body: testOutboxResponse{}},
+				problemResponse(http.StatusBadRequest, ""),
+				problemResponse(http.StatusRequestEntityTooLarge, ""),
+				problemResponse(http.StatusInternalServerError, ""),
+			},
+		}, orderHandler.TestOutbox)
+	}
+
+	admin := router.Group("/admin")
 	{
-		api.POST("/inbox", inboxHandler.CreateInboxMessage)
-		api.GET("/inbox", inboxHandler.GetInboxMessages)
+		if debugEndpoints {
+			reg.Handle(admin, http.MethodGet, "/chaos", openapi.Operation{
+				Summary:   "Show the fault injection configuration",
+				Tags:      []string{"admin-chaos"},
+				Responses: []openapi.Response{{Status: http.StatusOK, Body: chaos.Config{}}},
+			}, chaosHandler.Get)
+			reg.Handle(admin, http.MethodPut, "/chaos", openapi.Operation{
+				Summary:     "Replace the fault injection configuration",
+				Description: "Rules are keyed by target: a route as \"METHOD /path\" (e.g. \"POST /api/v1/orders\"), a warehouse or payment call (e.g. warehouse.reserve_stock, payment.authorize) or a database operation (db.query, db.exec, db.begin). A key ending in * applies to the targets it prefixes, and * alone to all targets without a more specific rule.",
+				Tags:        []string{"admin-chaos"},
+				Request:     chaos.Config{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: chaos.Config{}},
+					problemResponse(http.StatusBadRequest, ""),
+				},
+			}, chaosHandler.Set)
+		}
+
+		for _, t := range []struct {
+			name        string
+			listDead    gin.HandlerFunc
+			getDead     gin.HandlerFunc
+			requeueDead gin.HandlerFunc
+			listQuar    gin.HandlerFunc
+			getQuar     gin.HandlerFunc
+			bulkReplay  gin.HandlerFunc
+			replay      gin.HandlerFunc
+			dryRun      gin.HandlerFunc
+		}{
+			{"inbox", adminHandler.ListInboxDeadLetters, adminHandler.GetInboxDeadLetter, adminHandler.RequeueInboxDeadLetter,
+				adminHandler.ListInboxQuarantined, adminHandler.GetInboxQuarantined,
+				adminHandler.BulkReplayInbox, adminHandler.ReplayInboxMessage, adminHandler.DryRunInboxMessage},
+			{"outbox", adminHandler.ListOutboxDeadLetters, adminHandler.GetOutboxDeadLetter, adminHandler.RequeueOutboxDeadLetter,
+				adminHandler.ListOutboxQuarantined, adminHandler.GetOutboxQuarantined,
+				adminHandler.BulkReplayOutbox, adminHandler.ReplayOutboxMessage, adminHandler.DryRunOutboxMessage},
+		} {
+			tags := []string{"admin-" + t.name}
+			prefix := "/" + t.name
+
+			reg.Handle(admin, http.MethodGet, prefix+"/dead-letters", openapi.Operation{
+				Summary: "List " + t.name + " dead letters",
+				Tags:    tags,
+				Query:   paginationParams,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: deadLetterPage{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.listDead)
+			reg.Handle(admin, http.MethodGet, prefix+"/dead-letters/:id", openapi.Operation{
+				Summary: "Get an " + t.name + " dead letter",
+				Tags:    tags,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: outboxinbox.DeadLetterMessage{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusNotFound, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.getDead)
+			reg.Handle(admin, http.MethodPost, prefix+"/dead-letters/:id/requeue", openapi.Operation{
+				Summary: "Requeue an " + t.name + " dead letter",
+				Tags:    tags,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: requeueResponse{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusNotFound, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.requeueDead)
+			reg.Handle(admin, http.MethodGet, prefix+"/quarantine", openapi.Operation{
+				Summary: "List quarantined " + t.name + " messages",
+				Tags:    tags,
+				Query:   paginationParams,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: quarantinePage{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.listQuar)
+			reg.Handle(admin, http.MethodGet, prefix+"/quarantine/:id", openapi.Operation{
+				Summary: "Get a quarantined " + t.name + " message",
+				Tags:    tags,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: outboxinbox.QuarantinedMessage{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusNotFound, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.getQuar)
+			reg.Handle(admin, http.MethodPost, prefix+"/replay", openapi.Operation{
+				Summary: "Replay " + t.name + " messages matching a filter",
+				Tags:    tags,
+				Request: handlers.BulkReplayRequest{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: bulkReplayResponse{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.bulkReplay)
+			reg.Handle(admin, http.MethodPost, prefix+"/:id/replay", openapi.Operation{
+				Summary:     "Replay an " + t.name + " message",
+				Description: "An optional payload replaces the stored payload before the replay.",
+				Tags:        tags,
+				Request:     handlers.ReplayRequest{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: replayResponse{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusNotFound, ""),
+					problemResponse(http.StatusRequestEntityTooLarge, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.replay)
+			reg.Handle(admin, http.MethodPost, prefix+"/:id/dry-run", openapi.Operation{
+				Summary:     "Simulate processing an " + t.name + " message",
+				Description: "An optional payload is simulated instead of the stored one. Nothing is written.",
+				Tags:        tags,
+				Request:     handlers.ReplayRequest{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Body: dryRunResponse{}},
+					problemResponse(http.StatusBadRequest, ""),
+					problemResponse(http.StatusNotFound, ""),
+					problemResponse(http.StatusInternalServerError, ""),
+				},
+			}, t.dryRun)
+		}
+	}
+}
 
-		api.POST("/orders", orderHandler.CreateOrder)
-		api.GET("/orders", orderHandler.GetAllOrders)
-		api.GET("/orders/:order_id", orderHandler.GetOrder)
+var rateLimitedResponse = problemResponse(http.StatusTooManyRequests,
+	"rate_limited: the client exceeded its request rate; retry after the Retry-After header's seconds")
 
-		api.POST("/test-outbox", orderHandler.TestOutbox)
+// chain drops nil handlers, so optional middleware can be listed inline.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
 	}
+	return chained
+}
+
+var paginationParams = []openapi.Param{
+	{Name: "limit", Type: "integer", Description: "Page size, capped at 500"},
+	{Name: "offset", Type: "integer"},
+}
+
+// listParams documents the filter and keyset pagination parameters shared by
+// the inbox, outbox and order listings. Statuses, when given, restrict the
+// status parameter.
+func listParams(statuses ...string) []openapi.Param {
+	return []openapi.Param{
+		{Name: "status", Type: "string", Enum: statuses},
+		{Name: "created_after", Type: "string", Format: "date-time"},
+		{Name: "created_before", Type: "string", Format: "date-time"},
+		{Name: "cursor", Type: "integer", Description: "next_cursor of the previous page"},
+		{Name: "limit", Type: "integer", Description: "Page size, capped at 500"},
+		{Name: "sort", Type: "string", Enum: []string{"asc", "desc"}},
+	}
+}
+
+func messageListParams() []openapi.Param {
+	return append(listParams(),
+		openapi.Param{Name: "event_type", Type: "string"},
+		openapi.Param{Name: "message_id", Type: "string"},
+		openapi.Param{Name: "correlation_id", Type: "string"},
+	)
 }
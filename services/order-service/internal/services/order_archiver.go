@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+	"order-service/internal/metrics"
+)
+
+// OrderArchiver keeps the orders table small by periodically moving
+// completed orders older than the retention period, and orders soft-deleted
+// before it, into orders_archive, or purging them when archiving is off.
+type OrderArchiver struct {
+	orders    *OrderService
+	logger    logger.Logger
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+	archive   bool
+	locker    *lock.Locker
+	stopCh    chan struct{}
+}
+
+func NewOrderArchiver(
+	log logger.Logger,
+	orders *OrderService,
+	interval time.Duration,
+	retention time.Duration,
+	batchSize int,
+	archive bool,
+) *OrderArchiver {
+	return &OrderArchiver{
+		orders:    orders,
+		logger:    log,
+		interval:  interval,
+		retention: retention,
+		batchSize: batchSize,
+		archive:   archive,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetLocker makes each run take the order archiver's advisory lock first, so
+// only one replica archives at a time. Without a locker every replica runs.
+func (a *OrderArchiver) SetLocker(locker *lock.Locker) {
+	a.locker = locker
+}
+
+func (a *OrderArchiver) Start(ctx context.Context) {
+	a.logger.Info("Starting order archiver",
+		logger.String("interval", a.interval.String()),
+		logger.String("retention", a.retention.String()),
+		logger.Int("batch_size", a.batchSize),
+		logger.Bool("archive", a.archive))
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Info("Stopping order archiver due to context cancellation")
+			return
+		case <-a.stopCh:
+			a.logger.Info("Order archiver stopped")
+			return
+		case <-ticker.C:
+			a.run(ctx)
+		}
+	}
+}
+
+func (a *OrderArchiver) Stop() {
+	close(a.stopCh)
+}
+
+func (a *OrderArchiver) run(ctx context.Context) {
+	ran, err := a.locker.RunExclusive(ctx, "order-service.order_archiver", a.RunOnce)
+	if err != nil {
+		a.logger.Error("Failed to run order archiver", logger.Err(err))
+	} else if !ran {
+		a.logger.Debug("Order archiver is running on another replica")
+	}
+}
+
+// RunOnce moves orders out in batches until none past the retention period
+// remain.
+func (a *OrderArchiver) RunOnce(ctx context.Context) {
+	mode := "purged"
+	if a.archive {
+		mode = "archived"
+	}
+
+	var total int64
+	for {
+		count, err := a.orders.Archive(ctx, a.retention, a.batchSize, a.archive)
+		if err != nil {
+			a.logger.Error("Failed to archive orders", logger.Err(err))
+			break
+		}
+
+		total += count
+		metrics.RecordOrdersArchived(mode, count)
+
+		if count < int64(a.batchSize) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if total > 0 {
+		a.logger.Info("Archived completed orders",
+			logger.String("mode", mode),
+			logger.Int64("count", total))
+	}
+}
@@ -0,0 +1,493 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"observability-system/shared/outboxinbox"
+	"order-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const orderColumns = `id, order_id, COALESCE(customer_id, '') AS customer_id, product_id, product_name,
+	quantity, unit_price, total_amount, currency, status, created_at, stock_reserved, COALESCE(payment_id, '') AS payment_id`
+
+// ErrOrderNotPending is returned by ConfirmReservation and ResolveStockCheck
+// for orders that are no longer awaiting confirmation.
+var ErrOrderNotPending = errors.New("order is not pending")
+
+// ErrOrderNotCompleted is returned by Delete for orders that are still being
+// worked on.
+var ErrOrderNotCompleted = errors.New("order is not completed")
+
+// OrderFilter selects a page of orders. Orders are sorted by creation order,
+// newest first unless Ascending; Cursor is the Seq of the last order of the
+// previous page and zero starts from the first.
+type OrderFilter struct {
+	Status     string
+	ProductID  string
+	CustomerID string
+	// OrderIDs narrows the orders to these IDs.
+	OrderIDs      []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Query matches orders whose ID, customer ID, product name or payment
+	// ID contains it, case-insensitively.
+	Query     string
+	Cursor    int64
+	Limit     int
+	Ascending bool
+}
+
+// orderSearchDocument is the text Query is matched against. It must stay
+// identical to the expression of the idx_orders_search trigram index, or
+// searches fall back to a sequential scan.
+const orderSearchDocument = `(order_id || ' ' || COALESCE(customer_id, '') || ' ' || product_name || ' ' || COALESCE(payment_id, ''))`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// OrderService persists orders. Every state change writes its lifecycle
+// event to the outbox in the same transaction as the order row, so an event
+// is published if and only if the change commits.
+type OrderService struct {
+	db     *sqlx.DB
+	outbox outboxinbox.OutboxStore
+}
+
+func NewOrderService(db *sqlx.DB, outbox outboxinbox.OutboxStore) *OrderService {
+	return &OrderService{
+		db:     db,
+		outbox: outbox,
+	}
+}
+
+// Create stores a new order and emits order.created followed by events.
+func (s *OrderService) Create(ctx context.Context, order *models.Order, events ...models.Event) (err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	items, err := json.Marshal([]models.OrderItem{{SKU: order.ProductID, Quantity: order.Quantity, Price: order.UnitPrice}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal order items: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO orders (order_id, customer_id, product_id, product_name, quantity, unit_price, total_amount, currency,
+			status, items, stock_reserved, payment_id, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13)
+	`, order.ID, order.CustomerID, order.ProductID, order.ProductName, order.Quantity, order.UnitPrice, order.TotalAmount, order.Currency,
+		order.Status, items, order.StockReserved, order.PaymentID, order.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+
+	events = append([]models.Event{{Type: models.EventOrderCreated, Payload: models.NewOrderEvent(order)}}, events...)
+	for _, event := range events {
+		if _, err = s.outbox.SaveTx(ctx, tx, event.Type, event.Payload, outboxinbox.SaveOptions{}); err != nil {
+			return fmt.Errorf("failed to save %s event: %w", event.Type, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Get returns the order with the given order ID, or sql.ErrNoRows if it does
+// not exist or was deleted.
+func (s *OrderService) Get(ctx context.Context, orderID string) (*models.Order, error) {
+	var order models.Order
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE order_id = $1 AND deleted_at IS NULL`
+	if err := s.db.GetContext(ctx, &order, query, orderID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List returns a page of orders matching filter together with the number of
+// orders matching it across all pages.
+func (s *OrderService) List(ctx context.Context, filter OrderFilter) ([]models.Order, int64, error) {
+	conditions, args := orderConditions(filter)
+
+	var total int64
+	countQuery := `SELECT COUNT(*) FROM orders WHERE ` + strings.Join(conditions, " AND ")
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	orders, err := s.page(ctx, conditions, args, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// Export streams every order matching filter to fn, one page of
+// exportChunkSize orders at a time, so memory use does not depend on how many
+// orders match. filter's Limit is ignored. fn's error stops the export.
+func (s *OrderService) Export(ctx context.Context, filter OrderFilter, fn func([]models.Order) error) error {
+	conditions, args := orderConditions(filter)
+	filter.Limit = exportChunkSize
+
+	for {
+		orders, err := s.page(ctx, conditions, args, filter)
+		if err != nil {
+			return err
+		}
+		if len(orders) > 0 {
+			if err := fn(orders); err != nil {
+				return err
+			}
+		}
+		if len(orders) < filter.Limit {
+			return nil
+		}
+		filter.Cursor = orders[len(orders)-1].Seq
+	}
+}
+
+// exportChunkSize is how many orders Export reads per query.
+const exportChunkSize = 1000
+
+// orderConditions translates filter's field filters into WHERE conditions
+// and their arguments; the cursor is applied by page.
+func orderConditions(filter OrderFilter) ([]string, []interface{}) {
+	conditions := []string{"order_id IS NOT NULL", "deleted_at IS NULL"}
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.ProductID != "" {
+		add("product_id = $%d", filter.ProductID)
+	}
+	if filter.CustomerID != "" {
+		add("customer_id = $%d", filter.CustomerID)
+	}
+	if len(filter.OrderIDs) > 0 {
+		add("order_id = ANY($%d)", pq.Array(filter.OrderIDs))
+	}
+	if filter.CreatedAfter != nil {
+		add("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add("created_at < $%d", *filter.CreatedBefore)
+	}
+	if filter.Query != "" {
+		add(orderSearchDocument+" ILIKE $%d", "%"+likeEscaper.Replace(filter.Query)+"%")
+	}
+	return conditions, args
+}
+
+// page returns the orders matching conditions after filter's cursor.
+func (s *OrderService) page(ctx context.Context, conditions []string, args []interface{}, filter OrderFilter) ([]models.Order, error) {
+	// Copy so the caller's slices can be reused for the next page.
+	conditions = append([]string(nil), conditions...)
+	args = append([]interface{}(nil), args...)
+
+	order := "DESC"
+	if filter.Ascending {
+		order = "ASC"
+		if filter.Cursor > 0 {
+			args = append(args, filter.Cursor)
+			conditions = append(conditions, fmt.Sprintf("id > $%d", len(args)))
+		}
+	} else if filter.Cursor > 0 {
+		args = append(args, filter.Cursor)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`SELECT %s FROM orders WHERE %s ORDER BY id %s LIMIT $%d`,
+		orderColumns, strings.Join(conditions, " AND "), order, len(args))
+
+	orders := []models.Order{}
+	if err := s.db.SelectContext(ctx, &orders, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return orders, nil
+}
+
+// Delete soft-deletes a completed order and emits order.deleted. Deleted
+// orders disappear from every query but stay in the table until the order
+// archiver moves them out. It returns sql.ErrNoRows for unknown or already
+// deleted orders and ErrOrderNotCompleted, with the order, for orders still
+// in progress.
+func (s *OrderService) Delete(ctx context.Context, orderID string) (deleted *models.Order, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	err = tx.GetContext(ctx, &order, `SELECT `+orderColumns+` FROM orders WHERE order_id = $1 AND deleted_at IS NULL FOR UPDATE`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompleted(order.Status) {
+		return &order, ErrOrderNotCompleted
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE orders SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1`, order.Seq); err != nil {
+		return nil, fmt.Errorf("failed to delete order: %w", err)
+	}
+	if _, err = s.outbox.SaveTx(ctx, tx, models.EventOrderDeleted, models.NewOrderEvent(&order), outboxinbox.SaveOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to save %s event: %w", models.EventOrderDeleted, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &order, nil
+}
+
+func isCompleted(status string) bool {
+	for _, completed := range models.CompletedOrderStatuses {
+		if status == completed {
+			return true
+		}
+	}
+	return false
+}
+
+// Archive removes up to limit completed orders created before olderThan, and
+// soft-deleted orders deleted before it, from the orders table. With archive
+// set they are copied into orders_archive in the same statement; otherwise
+// they are purged. Orders whose stock still awaits release are kept until the
+// ReservationExpirer has released it. It returns the number of orders
+// removed.
+func (s *OrderService) Archive(ctx context.Context, olderThan time.Duration, limit int, archive bool) (int64, error) {
+	selectIDs := `
+		SELECT id FROM orders
+		WHERE ((status = ANY($1) AND created_at < NOW() - INTERVAL '1 second' * $2)
+		       OR deleted_at < NOW() - INTERVAL '1 second' * $2)
+		  AND NOT (stock_reserved AND status = ANY($4))
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`
+
+	query := `DELETE FROM orders WHERE id IN (` + selectIDs + `)`
+	if archive {
+		query = `
+			WITH archived AS (
+				DELETE FROM orders WHERE id IN (` + selectIDs + `)
+				RETURNING *
+			)
+			INSERT INTO orders_archive (source_id, order_id, status, row_data, created_at, deleted_at)
+			SELECT id, order_id, status, to_jsonb(archived), created_at, deleted_at FROM archived
+		`
+	}
+
+	result, err := s.db.ExecContext(ctx, query, pq.Array(models.CompletedOrderStatuses), olderThan.Seconds(), limit,
+		pq.Array(stockReleaseStatuses))
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive orders: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
+
+// ExpireStale marks up to limit orders that are still in one of statuses
+// after ttl as expired and emits order.expired for each. Their stock stays
+// reserved until ClaimStockRelease picks them up.
+func (s *OrderService) ExpireStale(ctx context.Context, statuses []string, ttl time.Duration, limit int) (expired []models.Order, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	expired = []models.Order{}
+	err = tx.SelectContext(ctx, &expired, `
+		UPDATE orders
+		SET status = $1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status = ANY($2)
+			  AND created_at < NOW() - INTERVAL '1 second' * $3
+			ORDER BY id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+orderColumns,
+		models.OrderStatusExpired,
+		pq.Array(statuses),
+		ttl.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire orders: %w", err)
+	}
+
+	for i := range expired {
+		if _, err = s.outbox.SaveTx(ctx, tx, models.EventOrderExpired, models.NewOrderEvent(&expired[i]), outboxinbox.SaveOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to save %s event: %w", models.EventOrderExpired, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return expired, nil
+}
+
+// OldestPendingStockCheck returns the oldest order awaiting a deferred stock
+// check without locking it, or nil if none is pending. Its outcome is decided
+// by calling other services, which must not happen while the order is
+// locked, and then saved with ResolveStockCheck.
+func (s *OrderService) OldestPendingStockCheck(ctx context.Context) (*models.Order, error) {
+	var order models.Order
+	err := s.db.GetContext(ctx, &order, `
+		SELECT `+orderColumns+` FROM orders
+		WHERE status = $1
+		ORDER BY id
+		LIMIT 1`,
+		models.OrderStatusPendingStockCheck)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select order pending stock check: %w", err)
+	}
+	return &order, nil
+}
+
+// ResolveStockCheck locks the order with the given ID, which must still be
+// pending its stock check, and passes it to resolve like ConfirmReservation
+// does.
+func (s *OrderService) ResolveStockCheck(ctx context.Context, orderID string, resolve func(order *models.Order) ([]models.Event, error)) (*models.Order, error) {
+	return s.resolveLocked(ctx, orderID, models.OrderStatusPendingStockCheck, resolve)
+}
+
+// ConfirmReservation locks the order with the given ID, which must still be
+// pending, and passes it to resolve, which updates its status and fields and
+// returns the events to emit. The changes and events are committed together;
+// if resolve fails nothing is written and the order stays pending. resolve
+// runs while the order is locked, so it only applies an outcome decided
+// beforehand and never calls other services. It returns sql.ErrNoRows for an
+// unknown order and ErrOrderNotPending, with the order, once the order has
+// left pending, e.g. because it already expired or the event was delivered
+// twice.
+func (s *OrderService) ConfirmReservation(ctx context.Context, orderID string, resolve func(order *models.Order) ([]models.Event, error)) (*models.Order, error) {
+	return s.resolveLocked(ctx, orderID, models.OrderStatusPending, resolve)
+}
+
+func (s *OrderService) resolveLocked(ctx context.Context, orderID, status string, resolve func(order *models.Order) ([]models.Event, error)) (resolved *models.Order, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	err = tx.GetContext(ctx, &order, `SELECT `+orderColumns+` FROM orders WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != status {
+		return &order, ErrOrderNotPending
+	}
+
+	if err = s.resolve(ctx, tx, &order, resolve); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// resolve runs resolve on the locked order, then writes its changes and
+// events and commits tx.
+func (s *OrderService) resolve(ctx context.Context, tx *sqlx.Tx, order *models.Order, resolve func(order *models.Order) ([]models.Event, error)) error {
+	events, err := resolve(order)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status = $1, product_name = $2, stock_reserved = $3, payment_id = NULLIF($4, ''), updated_at = NOW()
+		WHERE id = $5
+	`, order.Status, order.ProductName, order.StockReserved, order.PaymentID, order.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	for _, event := range events {
+		if _, err = s.outbox.SaveTx(ctx, tx, event.Type, event.Payload, outboxinbox.SaveOptions{}); err != nil {
+			return fmt.Errorf("failed to save %s event: %w", event.Type, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// stockReleaseStatuses are the statuses whose reserved stock is given back.
+var stockReleaseStatuses = []string{models.OrderStatusExpired, models.OrderStatusPaymentFailed}
+
+// ClaimStockRelease clears the stock_reserved flag of up to limit expired or
+// payment_failed orders and returns them, so exactly one caller releases
+// their stock. A caller whose release fails must call RestoreStockReserved.
+func (s *OrderService) ClaimStockRelease(ctx context.Context, limit int) ([]models.Order, error) {
+	claimed := []models.Order{}
+	err := s.db.SelectContext(ctx, &claimed, `
+		UPDATE orders
+		SET stock_reserved = FALSE, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status = ANY($1)
+			  AND stock_reserved
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+orderColumns,
+		pq.Array(stockReleaseStatuses),
+		limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim orders for stock release: %w", err)
+	}
+	return claimed, nil
+}
+
+// RestoreStockReserved hands an order claimed by ClaimStockRelease back for a
+// later attempt.
+func (s *OrderService) RestoreStockReserved(ctx context.Context, orderID string) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE orders SET stock_reserved = TRUE, updated_at = NOW() WHERE order_id = $1`, orderID); err != nil {
+		return fmt.Errorf("failed to restore stock reservation of order %s: %w", orderID, err)
+	}
+	return nil
+}
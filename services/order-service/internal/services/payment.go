@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/tracing"
+	"order-service/internal/clients"
+	"order-service/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AuthorizePayment runs the payment step for an order whose stock is already
+// reserved and sets the order's payment ID. A failed authorization is
+// compensated by releasing the reservation; the order is then stored as
+// payment_failed and the failure returned. The payment events are published
+// by payment-service, so none are stored with the order. Without a payment
+// client it does nothing.
+func AuthorizePayment(
+	ctx context.Context,
+	log logger.Logger,
+	payments *clients.PaymentClient,
+	warehouse *clients.WarehouseClient,
+	order *models.Order,
+) error {
+	if payments == nil {
+		return nil
+	}
+
+	log.InfoCtx(ctx, "Authorizing payment",
+		logger.String("order_id", order.ID))
+
+	auth, err := payments.Authorize(ctx, clients.PaymentRequest{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		ProductID:  order.ProductID,
+		Quantity:   order.Quantity,
+		Amount:     order.TotalAmount,
+		Currency:   order.Currency,
+	})
+	if err == nil {
+		order.PaymentID = auth.PaymentID
+
+		tracing.AddSpanAttributes(ctx,
+			attribute.Bool("payment.authorized", true),
+			attribute.String("payment.id", auth.PaymentID),
+		)
+		return nil
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("payment.authorized", false),
+		attribute.String("error", err.Error()),
+	)
+
+	log.WarnCtx(ctx, "Payment authorization failed, releasing reserved stock",
+		logger.Err(err),
+		logger.String("order_id", order.ID))
+
+	order.Status = models.OrderStatusPaymentFailed
+
+	if _, releaseErr := warehouse.ReleaseStock(ctx, order.ID, order.ProductID, order.Quantity); releaseErr != nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("compensation.stock_released", false))
+		log.ErrorCtx(ctx, "Failed to release stock after payment failure",
+			logger.Err(releaseErr),
+			logger.String("order_id", order.ID))
+	} else {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("compensation.stock_released", true))
+		order.StockReserved = false
+	}
+
+	return err
+}
+
+// CapturePayment settles the payment of an order once it is stored as
+// confirmed. Orders without an authorized payment are skipped. A failed
+// capture is only logged: the payment stays authorized and the order stays
+// confirmed, so it can be captured later.
+func CapturePayment(ctx context.Context, log logger.Logger, payments *clients.PaymentClient, order *models.Order) {
+	if payments == nil || order.PaymentID == "" || order.Status != models.OrderStatusConfirmed {
+		return
+	}
+
+	if _, err := payments.Capture(ctx, order.PaymentID); err != nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("payment.captured", false))
+		log.ErrorCtx(ctx, "Failed to capture payment of confirmed order",
+			logger.Err(err),
+			logger.String("order_id", order.ID),
+			logger.String("payment_id", order.PaymentID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.Bool("payment.captured", true))
+	log.InfoCtx(ctx, "Payment captured",
+		logger.String("order_id", order.ID),
+		logger.String("payment_id", order.PaymentID))
+}
+
+// RefundPayment compensates the authorized payment of an order that could
+// not be completed. Orders without a payment are skipped. A failed refund
+// is logged and leaves the payment to be refunded by hand.
+func RefundPayment(ctx context.Context, log logger.Logger, payments *clients.PaymentClient, order *models.Order, reason string) {
+	if payments == nil || order.PaymentID == "" {
+		return
+	}
+
+	log.WarnCtx(ctx, "Refunding payment",
+		logger.String("order_id", order.ID),
+		logger.String("payment_id", order.PaymentID),
+		logger.String("reason", reason))
+
+	if _, err := payments.Refund(ctx, order.PaymentID, reason); err != nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("compensation.payment_refunded", false))
+		log.ErrorCtx(ctx, "Failed to refund payment",
+			logger.Err(err),
+			logger.String("order_id", order.ID),
+			logger.String("payment_id", order.PaymentID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.Bool("compensation.payment_refunded", true))
+}
+
+// RefundUnsaved refunds the payment authorized for decided, an order whose
+// outcome was decided before it was locked, when saving that outcome found
+// the order already resolved. current is the order as stored: a payment it
+// holds itself, as after a concurrent confirmation that got the same
+// idempotent authorization back, is kept.
+func RefundUnsaved(ctx context.Context, log logger.Logger, payments *clients.PaymentClient, decided, current *models.Order) {
+	if decided.PaymentID == "" || current.PaymentID == decided.PaymentID {
+		return
+	}
+	RefundPayment(ctx, log, payments, decided, "order is already "+current.Status)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+	"order-service/internal/clients"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+)
+
+// ReservationExpirer periodically expires orders that stayed pending or
+// stock_reserved for longer than the reservation TTL and releases the stock
+// they hold, so abandoned checkouts stop consuming inventory. It also retries
+// releases whose compensation failed earlier, e.g. after a payment failure.
+type ReservationExpirer struct {
+	orders    *OrderService
+	warehouse *clients.WarehouseClient
+	logger    logger.Logger
+	interval  time.Duration
+	ttl       time.Duration
+	// confirmationTimeout expires pending orders whose inventory.reserved
+	// event has not arrived in time; zero leaves them to ttl.
+	confirmationTimeout time.Duration
+	batchSize           int
+	locker              *lock.Locker
+	stopCh              chan struct{}
+}
+
+func NewReservationExpirer(
+	log logger.Logger,
+	orders *OrderService,
+	warehouse *clients.WarehouseClient,
+	interval time.Duration,
+	ttl time.Duration,
+	batchSize int,
+) *ReservationExpirer {
+	return &ReservationExpirer{
+		orders:    orders,
+		warehouse: warehouse,
+		logger:    log,
+		interval:  interval,
+		ttl:       ttl,
+		batchSize: batchSize,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetConfirmationTimeout expires orders still pending after timeout, which
+// is meant to be shorter than the reservation TTL: orders awaiting their
+// inventory.reserved event should fail fast when the event is lost.
+func (e *ReservationExpirer) SetConfirmationTimeout(timeout time.Duration) {
+	e.confirmationTimeout = timeout
+}
+
+// SetLocker guards each run with an advisory lock, so only one replica
+// expires orders and releases their stock at a time.
+func (e *ReservationExpirer) SetLocker(locker *lock.Locker) {
+	e.locker = locker
+}
+
+func (e *ReservationExpirer) Start(ctx context.Context) {
+	e.logger.Info("Starting reservation expirer",
+		logger.String("interval", e.interval.String()),
+		logger.String("ttl", e.ttl.String()),
+		logger.String("confirmation_timeout", e.confirmationTimeout.String()),
+		logger.Int("batch_size", e.batchSize))
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("Stopping reservation expirer due to context cancellation")
+			return
+		case <-e.stopCh:
+			e.logger.Info("Reservation expirer stopped")
+			return
+		case <-ticker.C:
+			e.run(ctx)
+		}
+	}
+}
+
+func (e *ReservationExpirer) Stop() {
+	close(e.stopCh)
+}
+
+func (e *ReservationExpirer) run(ctx context.Context) {
+	ran, err := e.locker.RunExclusive(ctx, "order-service.reservation_expirer", e.RunOnce)
+	if err != nil {
+		e.logger.Error("Failed to run reservation expirer", logger.Err(err))
+	} else if !ran {
+		e.logger.Debug("Reservation expirer is running on another replica")
+	}
+}
+
+// RunOnce expires stale orders and then releases the stock of every expired
+// order still holding a reservation.
+func (e *ReservationExpirer) RunOnce(ctx context.Context) {
+	if e.confirmationTimeout > 0 {
+		e.expire(ctx, "Pending order was not confirmed in time",
+			[]string{models.OrderStatusPending}, e.confirmationTimeout)
+	}
+	if e.ttl > 0 {
+		e.expire(ctx, "Expired stale order",
+			[]string{models.OrderStatusPending, models.OrderStatusStockReserved}, e.ttl)
+	}
+
+	claimed, err := e.orders.ClaimStockRelease(ctx, e.batchSize)
+	if err != nil {
+		e.logger.Error("Failed to claim orders for stock release", logger.Err(err))
+		return
+	}
+
+	for _, order := range claimed {
+		if _, err := e.warehouse.ReleaseStock(ctx, order.ID, order.ProductID, order.Quantity); err != nil {
+			e.logger.Error("Failed to release stock of order, will retry",
+				logger.Err(err),
+				logger.String("order_id", order.ID),
+				logger.String("status", order.Status))
+			if err := e.orders.RestoreStockReserved(ctx, order.ID); err != nil {
+				e.logger.Error("Failed to restore stock reservation flag", logger.Err(err))
+			}
+			continue
+		}
+
+		e.logger.Info("Released stock of order",
+			logger.String("order_id", order.ID),
+			logger.String("status", order.Status),
+			logger.String("product_id", order.ProductID),
+			logger.Int("quantity", order.Quantity))
+	}
+}
+
+func (e *ReservationExpirer) expire(ctx context.Context, msg string, statuses []string, ttl time.Duration) {
+	expired, err := e.orders.ExpireStale(ctx, statuses, ttl, e.batchSize)
+	if err != nil {
+		e.logger.Error("Failed to expire stale orders", logger.Err(err))
+		return
+	}
+	for _, order := range expired {
+		metrics.RecordOrderStatus(models.OrderStatusExpired)
+		e.logger.Warn(msg,
+			logger.String("order_id", order.ID),
+			logger.String("product_id", order.ProductID),
+			logger.String("age", time.Since(order.CreatedAt).Round(time.Second).String()))
+	}
+}
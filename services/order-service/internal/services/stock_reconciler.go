@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+	"order-service/internal/clients"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+)
+
+// StockReconciler completes orders accepted as pending_stock_check while
+// warehouse-service was unavailable. Once the warehouse answers again it
+// checks and reserves their stock and runs the payment step, confirming the
+// order, or rejects it when the product is unknown, the stock is short or
+// the order waited longer than maxWait.
+type StockReconciler struct {
+	orders    *OrderService
+	warehouse *clients.WarehouseClient
+	payments  *clients.PaymentClient
+	logger    logger.Logger
+	interval  time.Duration
+	maxWait   time.Duration
+	batchSize int
+	locker    *lock.Locker
+	stopCh    chan struct{}
+}
+
+// NewStockReconciler skips the payment step when payments is nil.
+func NewStockReconciler(
+	log logger.Logger,
+	orders *OrderService,
+	warehouse *clients.WarehouseClient,
+	payments *clients.PaymentClient,
+	interval time.Duration,
+	maxWait time.Duration,
+	batchSize int,
+) *StockReconciler {
+	return &StockReconciler{
+		orders:    orders,
+		warehouse: warehouse,
+		payments:  payments,
+		logger:    log,
+		interval:  interval,
+		maxWait:   maxWait,
+		batchSize: batchSize,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetLocker guards each run with an advisory lock, so replicas take turns
+// instead of racing for the same pending orders. Without a locker every
+// replica runs.
+func (r *StockReconciler) SetLocker(locker *lock.Locker) {
+	r.locker = locker
+}
+
+func (r *StockReconciler) Start(ctx context.Context) {
+	r.logger.Info("Starting stock reconciler",
+		logger.String("interval", r.interval.String()),
+		logger.String("max_wait", r.maxWait.String()),
+		logger.Int("batch_size", r.batchSize))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Stopping stock reconciler due to context cancellation")
+			return
+		case <-r.stopCh:
+			r.logger.Info("Stock reconciler stopped")
+			return
+		case <-ticker.C:
+			r.run(ctx)
+		}
+	}
+}
+
+func (r *StockReconciler) Stop() {
+	close(r.stopCh)
+}
+
+func (r *StockReconciler) run(ctx context.Context) {
+	ran, err := r.locker.RunExclusive(ctx, "order-service.stock_reconciler", r.RunOnce)
+	if err != nil {
+		r.logger.Error("Failed to run stock reconciler", logger.Err(err))
+	} else if !ran {
+		r.logger.Debug("Stock reconciler is running on another replica")
+	}
+}
+
+// RunOnce resolves up to batchSize pending orders, oldest first. It stops at
+// the first order that cannot be resolved, since the warehouse is most likely
+// still unavailable for the rest too.
+func (r *StockReconciler) RunOnce(ctx context.Context) {
+	for i := 0; i < r.batchSize; i++ {
+		order, err := r.orders.OldestPendingStockCheck(ctx)
+		if err != nil {
+			r.logger.Warn("Failed to resolve order pending stock check, will retry",
+				logger.Err(err))
+			return
+		}
+		if order == nil {
+			return
+		}
+
+		// The outcome is decided before the order is locked, so the stock
+		// and payment calls never run inside the transaction. Reservations
+		// and authorizations are idempotent by order ID, so an order that is
+		// decided again after a failed save gets the same ones back.
+		events, err := r.resolve(ctx, order)
+		if err == nil {
+			decided := order
+			order, err = r.orders.ResolveStockCheck(ctx, decided.ID, func(order *models.Order) ([]models.Event, error) {
+				order.Status = decided.Status
+				order.StockReserved = decided.StockReserved
+				order.ProductName = decided.ProductName
+				order.PaymentID = decided.PaymentID
+				return events, nil
+			})
+			if errors.Is(err, ErrOrderNotPending) {
+				// Another replica resolved the order first, with the same
+				// reservation and payment unless it decided otherwise.
+				r.undo(ctx, decided, order)
+				continue
+			}
+		}
+		if err != nil {
+			r.logger.Warn("Failed to resolve order pending stock check, will retry",
+				logger.Err(err))
+			return
+		}
+
+		metrics.RecordOrderStatus(order.Status)
+		CapturePayment(ctx, r.logger, r.payments, order)
+		r.logger.Info("Resolved order pending stock check",
+			logger.String("order_id", order.ID),
+			logger.String("status", order.Status),
+			logger.String("waited", time.Since(order.CreatedAt).Round(time.Second).String()))
+	}
+}
+
+// undo gives back the reservation and payment made for decided when the
+// order was resolved otherwise in the meantime.
+func (r *StockReconciler) undo(ctx context.Context, decided, current *models.Order) {
+	r.logger.Warn("Order pending stock check was resolved concurrently",
+		logger.String("order_id", current.ID),
+		logger.String("status", current.Status))
+
+	RefundUnsaved(ctx, r.logger, r.payments, decided, current)
+	if !decided.StockReserved || current.StockReserved || current.Status == models.OrderStatusConfirmed {
+		return
+	}
+	if _, err := r.warehouse.ReleaseStock(ctx, decided.ID, decided.ProductID, decided.Quantity); err != nil {
+		r.logger.Error("Failed to release stock of concurrently resolved order",
+			logger.Err(err),
+			logger.String("order_id", decided.ID))
+	}
+}
+
+// resolve decides a pending order's outcome. Errors mean the outcome could
+// not be decided yet and leave the order pending.
+func (r *StockReconciler) resolve(ctx context.Context, order *models.Order) ([]models.Event, error) {
+	if r.maxWait > 0 && time.Since(order.CreatedAt) > r.maxWait {
+		return reject(order, fmt.Sprintf("stock could not be checked within %s", r.maxWait)), nil
+	}
+
+	stock, err := r.warehouse.CheckStock(ctx, order.ProductID)
+	if errors.Is(err, clients.ErrProductNotFound) {
+		return reject(order, err.Error()), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stock check of order %s failed: %w", order.ID, err)
+	}
+	order.ProductName = stock.Name
+
+	// A cached count may be stale; the reservation decides then.
+	if !stock.Cached && stock.Available < order.Quantity {
+		return reject(order, fmt.Sprintf("insufficient stock: requested %d, only %d available", order.Quantity, stock.Available)), nil
+	}
+
+	if _, err := r.warehouse.ReserveStock(ctx, order.ID, order.ProductID, order.Quantity, false); err != nil {
+		if errors.Is(err, clients.ErrInsufficientStock) || errors.Is(err, clients.ErrProductNotFound) {
+			return reject(order, err.Error()), nil
+		}
+		return nil, fmt.Errorf("stock reservation of order %s failed: %w", order.ID, err)
+	}
+	order.StockReserved = true
+	order.Status = models.OrderStatusConfirmed
+
+	// A failed payment releases the reservation and marks the order
+	// payment_failed; either way the order is resolved.
+	AuthorizePayment(ctx, r.logger, r.payments, r.warehouse, order)
+
+	return []models.Event{{Type: models.EventOrderUpdated, Payload: models.NewOrderEvent(order)}}, nil
+}
+
+func reject(order *models.Order, reason string) []models.Event {
+	order.Status = models.OrderStatusRejected
+
+	event := models.NewOrderEvent(order)
+	event.Reason = reason
+	return []models.Event{{Type: models.EventOrderRejected, Payload: event}}
+}
@@ -0,0 +1,407 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"order-service/internal/graphql"
+)
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty document", "", "document contains no operations"},
+		{"mutation", `mutation { cancel }`, "mutation operations are not supported"},
+		{"subscription", `subscription { orders }`, "subscription operations are not supported"},
+		{"fragment definition", `fragment f on Order { id }`, "fragments are not supported"},
+		{"fragment spread", `{ orders { ...f } }`, "fragments are not supported"},
+		{"directive", `{ orders @include(if: true) { id } }`, "directives are not supported"},
+		{"empty selection", `{ }`, "expected at least one field"},
+		{"unclosed selection", `{ orders { id }`, `expected name at offset 15, found "end of document"`},
+		{"missing argument value", `{ order(id: ) { id } }`, "expected value"},
+		{"variable in default", `query ($a: Int = $b) { orders { id } }`, "expected value"},
+		{"unknown keyword", `order { id }`, "expected operation"},
+		{"unterminated string", `{ order(id: "ORD-1) { id } }`, "unterminated string at offset 12"},
+		{"invalid escape", `{ order(id: "\q") { id } }`, `invalid escape \q`},
+		{"unexpected character", `{ order(id: %) { id } }`, `unexpected character '%' at offset 12`},
+		{"invalid integer", `{ orders(limit: 99999999999999999999) { id } }`, "invalid integer"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := graphql.Parse(tc.query)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Parse(%q) = %v, want an error containing %q", tc.query, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  *graphql.Operation
+	}{
+		{
+			name:  "shorthand query",
+			query: `{ orders { id } }`,
+			want: &graphql.Operation{Selection: []*graphql.Selection{
+				{Name: "orders", Selection: []*graphql.Selection{{Name: "id"}}},
+			}},
+		},
+		{
+			name:  "aliases",
+			query: `{ first: order(id: "ORD-1") { id } second: order(id: "ORD-2") { orderId: id } }`,
+			want: &graphql.Operation{Selection: []*graphql.Selection{
+				{Alias: "first", Name: "order", Arguments: map[string]interface{}{"id": "ORD-1"},
+					Selection: []*graphql.Selection{{Name: "id"}}},
+				{Alias: "second", Name: "order", Arguments: map[string]interface{}{"id": "ORD-2"},
+					Selection: []*graphql.Selection{{Alias: "orderId", Name: "id"}}},
+			}},
+		},
+		{
+			name:  "variables with defaults",
+			query: `query Orders($status: String!, $limit: Int = 10, $ids: [ID!]) { orders(status: $status, limit: $limit) { id } }`,
+			want: &graphql.Operation{
+				Name: "Orders",
+				Variables: []graphql.VariableDefinition{
+					{Name: "status"},
+					{Name: "limit", Default: int64(10)},
+					{Name: "ids"},
+				},
+				Selection: []*graphql.Selection{{
+					Name:      "orders",
+					Arguments: map[string]interface{}{"status": graphql.Variable("status"), "limit": graphql.Variable("limit")},
+					Selection: []*graphql.Selection{{Name: "id"}},
+				}},
+			},
+		},
+		{
+			name:  "argument values",
+			query: `{ f(i: -3, f: 1.5e2, s: "a\"é", t: true, n: null, l: [1, $v], o: {k: "v"}) }`,
+			want: &graphql.Operation{Selection: []*graphql.Selection{{
+				Name: "f",
+				Arguments: map[string]interface{}{
+					"i": int64(-3),
+					"f": 150.0,
+					"s": `a"é`,
+					"t": true,
+					"n": nil,
+					"l": []interface{}{int64(1), graphql.Variable("v")},
+					"o": map[string]interface{}{"k": "v"},
+				},
+			}}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ops, err := graphql.Parse(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ops) != 1 || !reflect.DeepEqual(ops[0], tc.want) {
+				t.Errorf("Parse(%q) = %s, want %s", tc.query, dump(ops), dump(tc.want))
+			}
+		})
+	}
+}
+
+func TestParseNestingLimit(t *testing.T) {
+	nestedSelections := func(depth int) string {
+		return strings.Repeat("{ a ", depth-1) + "{ a" + strings.Repeat(" }", depth)
+	}
+	nestedLists := func(depth int) string {
+		return "{ a(v: " + strings.Repeat("[", depth) + strings.Repeat("]", depth) + ") }"
+	}
+	nestedObjects := func(depth int) string {
+		return "{ a(v: " + strings.Repeat("{k: ", depth-1) + "{}" + strings.Repeat("}", depth-1) + ") }"
+	}
+	nestedTypes := func(depth int) string {
+		return "query ($v: " + strings.Repeat("[", depth) + "Int" + strings.Repeat("]", depth) + ") { a }"
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"selections at the limit", nestedSelections(16), ""},
+		{"selections past the limit", nestedSelections(17), "selections nested deeper than 16 levels"},
+		{"lists at the limit", nestedLists(32), ""},
+		{"lists past the limit", nestedLists(33), "values nested deeper than 32 levels"},
+		{"objects past the limit", nestedObjects(33), "values nested deeper than 32 levels"},
+		{"list types past the limit", nestedTypes(33), "list types nested deeper than 32 levels"},
+		{"deep document", nestedSelections(100000), "selections nested deeper than 16 levels"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := graphql.Parse(tc.query)
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("Parse failed: %v", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("Parse = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+
+	// Execute reports the limit as a GraphQL error rather than failing.
+	result := graphql.Execute(context.Background(), testSchema(nil), graphql.Request{Query: nestedSelections(17)})
+	if result.Data != nil || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "nested deeper") {
+		t.Errorf("Execute = %s, want one nesting error and no data", dump(result))
+	}
+}
+
+type testOrder struct {
+	ID        string
+	ProductID string
+}
+
+// testSchema serves orders ORD-1 to ORD-3 of PROD-1 and PROD-2; the stock
+// of their products is looked up through loader.
+func testSchema(loader *graphql.Loader[string, int]) *graphql.Schema {
+	orders := []*testOrder{{"ORD-1", "PROD-1"}, {"ORD-2", "PROD-2"}, {"ORD-3", "PROD-1"}}
+
+	orderType := &graphql.Object{
+		Name: "Order",
+		Fields: map[string]*graphql.Field{
+			"id": {Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*testOrder).ID, nil
+			}},
+			"stock": {Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loader.Load(p.Context, p.Source.(*testOrder).ProductID)
+			}},
+		},
+	}
+	queryType := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"order": {
+				Type: orderType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					for _, o := range orders {
+						if o.ID == p.Args["id"] {
+							return o, nil
+						}
+					}
+					if p.Args["id"] == "ORD-FAIL" {
+						return nil, errors.New("order store unavailable")
+					}
+					return nil, nil
+				},
+			},
+			"orders": {
+				Type: orderType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit, ok := p.Args["limit"].(int64)
+					if !ok || int(limit) > len(orders) {
+						limit = int64(len(orders))
+					}
+					return orders[:limit], nil
+				},
+			},
+			"echo": {Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Args["value"], nil
+			}},
+		},
+	}
+	return &graphql.Schema{Query: queryType}
+}
+
+func TestExecute(t *testing.T) {
+	cases := []struct {
+		name string
+		req  graphql.Request
+		want string
+	}{
+		{
+			name: "fields in query order",
+			req:  graphql.Request{Query: `{ orders(limit: 2) { id __typename } }`},
+			want: `{"data":{"orders":[{"id":"ORD-1","__typename":"Order"},{"id":"ORD-2","__typename":"Order"}]}}`,
+		},
+		{
+			name: "aliases",
+			req:  graphql.Request{Query: `{ a: order(id: "ORD-2") { key: id } b: order(id: "ORD-3") { id } }`},
+			want: `{"data":{"a":{"key":"ORD-2"},"b":{"id":"ORD-3"}}}`,
+		},
+		{
+			name: "variables and defaults",
+			req: graphql.Request{
+				Query:     `query ($id: ID!, $limit: Int = 1) { order(id: $id) { id } orders(limit: $limit) { id } }`,
+				Variables: map[string]interface{}{"id": "ORD-3"},
+			},
+			want: `{"data":{"order":{"id":"ORD-3"},"orders":[{"id":"ORD-1"}]}}`,
+		},
+		{
+			name: "variables in nested values and enums",
+			req: graphql.Request{
+				Query:     `query ($id: ID) { echo(value: {ids: [$id, "ORD-2"], sort: DESC}) }`,
+				Variables: map[string]interface{}{"id": "ORD-1"},
+			},
+			want: `{"data":{"echo":{"ids":["ORD-1","ORD-2"],"sort":"DESC"}}}`,
+		},
+		{
+			name: "named operation",
+			req:  graphql.Request{Query: `query A { order(id: "ORD-1") { id } } query B { order(id: "ORD-2") { id } }`, OperationName: "B"},
+			want: `{"data":{"order":{"id":"ORD-2"}}}`,
+		},
+		{
+			name: "missing object",
+			req:  graphql.Request{Query: `{ order(id: "ORD-404") { id } }`},
+			want: `{"data":{"order":null}}`,
+		},
+		{
+			name: "resolver error nulls the field",
+			req:  graphql.Request{Query: `{ failed: order(id: "ORD-FAIL") { id } order(id: "ORD-1") { id } }`},
+			want: `{"data":{"failed":null,"order":{"id":"ORD-1"}},"errors":[{"message":"order store unavailable","path":["failed"]}]}`,
+		},
+		{
+			name: "unknown field",
+			req:  graphql.Request{Query: `{ orders { id total } }`},
+			want: `{"data":null,"errors":[{"message":"Cannot query field \"total\" on type \"Order\"","path":["orders","total"]}]}`,
+		},
+		{
+			name: "object field without selection",
+			req:  graphql.Request{Query: `{ orders }`},
+			want: `{"data":null,"errors":[{"message":"Field \"orders\" of type \"Query\" must have a selection of subfields","path":["orders"]}]}`,
+		},
+		{
+			name: "scalar field with selection",
+			req:  graphql.Request{Query: `{ orders { id { value } } }`},
+			want: `{"data":null,"errors":[{"message":"Field \"id\" of type \"Order\" must not have a selection","path":["orders","id"]}]}`,
+		},
+		{
+			name: "operation name required",
+			req:  graphql.Request{Query: `query A { orders { id } } query B { orders { id } }`},
+			want: `{"data":null,"errors":[{"message":"operationName is required for documents with several operations"}]}`,
+		},
+		{
+			name: "unknown operation",
+			req:  graphql.Request{Query: `query A { orders { id } }`, OperationName: "C"},
+			want: `{"data":null,"errors":[{"message":"unknown operation \"C\""}]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := graphql.Execute(context.Background(), testSchema(nil), tc.req)
+			if got := dump(result); got != tc.want {
+				t.Errorf("Execute = %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteBatchesListItems(t *testing.T) {
+	var batches [][]string
+	var mu sync.Mutex
+	loader := graphql.NewLoader(func(ctx context.Context, keys []string) ([]int, []error) {
+		mu.Lock()
+		batches = append(batches, keys)
+		mu.Unlock()
+		values := make([]int, len(keys))
+		for i, key := range keys {
+			values[i] = len(key) * 10
+		}
+		return values, nil
+	}, 0)
+
+	result := graphql.Execute(context.Background(), testSchema(loader), graphql.Request{Query: `{ orders { id stock } }`})
+	want := `{"data":{"orders":[{"id":"ORD-1","stock":60},{"id":"ORD-2","stock":60},{"id":"ORD-3","stock":60}]}}`
+	if got := dump(result); got != want {
+		t.Errorf("Execute = %s\nwant %s", got, want)
+	}
+	// The three orders' stock lookups are fetched together, PROD-1 once.
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("fetched batches %v, want one batch of PROD-1 and PROD-2", batches)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	cases := []struct {
+		name        string
+		maxBatch    int
+		keys        []string
+		wantFetches int32
+		wantKeys    int32
+	}{
+		{"coalesces a window", 0, []string{"a", "b", "c", "d"}, 1, 4},
+		{"fetches duplicates once", 0, []string{"a", "a", "b", "a"}, 1, 2},
+		{"splits at the batch size", 2, []string{"a", "b", "c", "d", "e"}, 3, 5},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fetches, fetched int32
+			loader := graphql.NewLoader(func(ctx context.Context, keys []string) ([]string, []error) {
+				atomic.AddInt32(&fetches, 1)
+				atomic.AddInt32(&fetched, int32(len(keys)))
+				values := make([]string, len(keys))
+				errs := make([]error, len(keys))
+				for i, key := range keys {
+					if key == "e" {
+						errs[i] = fmt.Errorf("no value for %s", key)
+						continue
+					}
+					values[i] = strings.ToUpper(key)
+				}
+				return values, errs
+			}, tc.maxBatch)
+
+			var wg sync.WaitGroup
+			for _, key := range tc.keys {
+				wg.Add(1)
+				go func(key string) {
+					defer wg.Done()
+					value, err := loader.Load(context.Background(), key)
+					switch {
+					case key == "e" && err == nil:
+						t.Errorf("Load(e) succeeded, want its batch error")
+					case key != "e" && (err != nil || value != strings.ToUpper(key)):
+						t.Errorf("Load(%s) = %q, %v", key, value, err)
+					}
+				}(key)
+			}
+			wg.Wait()
+
+			if fetches != tc.wantFetches || fetched != tc.wantKeys {
+				t.Errorf("fetched %d keys in %d batches, want %d in %d", fetched, fetches, tc.wantKeys, tc.wantFetches)
+			}
+		})
+	}
+
+	t.Run("cancelled context", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		loader := graphql.NewLoader(func(ctx context.Context, keys []string) ([]string, []error) {
+			<-block
+			return nil, nil
+		}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := loader.Load(ctx, "a"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Load = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func dump(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
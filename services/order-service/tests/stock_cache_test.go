@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"observability-system/shared/cache"
+	"observability-system/shared/logger"
+	"order-service/internal/clients"
+)
+
+// TestCheckStockMarksCachedReplies checks that stock served from the cache
+// is marked, so callers do not turn orders down on a possibly stale count.
+func TestCheckStockMarksCachedReplies(t *testing.T) {
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "order-service", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(clients.StockInfo{ProductID: "PROD-001", Name: "Laptop", Quantity: 5, Available: 5})
+	}))
+	defer server.Close()
+
+	client := clients.NewWarehouseClient(server.URL, 5*time.Second, log)
+	client.SetStockCache(cache.New(cache.Config{Name: "stock-test", TTL: time.Minute}))
+	ctx := context.Background()
+
+	for i, wantCached := range []bool{false, true} {
+		stock, err := client.CheckStock(ctx, "PROD-001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stock.Available != 5 || stock.Cached != wantCached {
+			t.Errorf("check %d got %+v, want 5 available with Cached %v", i, stock, wantCached)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("warehouse called %d times, want 1", n)
+	}
+}
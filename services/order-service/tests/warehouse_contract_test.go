@@ -0,0 +1,308 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"observability-system/shared/contract"
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"order-service/internal/clients"
+)
+
+var update = flag.Bool("update", false, "rewrite the contract files from the interactions of the consumer tests")
+
+// warehouseContract is recorded here and verified by warehouse-service's
+// tests, which read the same file.
+const warehouseContract = "../../../contracts/order-service-warehouse-service.json"
+
+// TestWarehouseClientContract records what WarehouseClient sends to
+// warehouse-service and relies on in return. The client runs against a mock
+// answering with each interaction's response, and the interactions must
+// match the committed contract. After changing them, run
+//
+//	go test ./tests -run TestWarehouseClientContract -update
+//
+// and commit the contract, which warehouse-service's tests then verify.
+func TestWarehouseClientContract(t *testing.T) {
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "order-service", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := contract.NewMock("order-service", "warehouse-service")
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	client := clients.NewWarehouseClient(server.URL, 5*time.Second, log)
+	ctx := context.Background()
+
+	cases := []struct {
+		interaction contract.Interaction
+		call        func(t *testing.T)
+	}{
+		{
+			interaction: contract.Interaction{
+				Description: "a stock check of a stocked product",
+				State:       "PROD-001 has 50 units available",
+				Request:     contract.Request{Method: http.MethodGet, Path: "/api/v1/inventory/PROD-001"},
+				Response: contract.Response{
+					Status:      http.StatusOK,
+					ContentType: "application/json",
+					Body: contract.Body(map[string]interface{}{
+						"product_id": "PROD-001",
+						"name":       "Laptop",
+						"quantity":   50,
+						"reserved":   0,
+						"available":  50,
+					}),
+					Exact: []string{"product_id", "available"},
+				},
+			},
+			call: func(t *testing.T) {
+				stock, err := client.CheckStock(ctx, "PROD-001")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stock.ProductID != "PROD-001" || stock.Name != "Laptop" || stock.Available != 50 {
+					t.Errorf("got stock %+v", stock)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a stock check of an unknown product",
+				State:       "PROD-404 does not exist",
+				Request:     contract.Request{Method: http.MethodGet, Path: "/api/v1/inventory/PROD-404"},
+				Response:    notFound("PROD-404"),
+			},
+			call: func(t *testing.T) {
+				if _, err := client.CheckStock(ctx, "PROD-404"); !errors.Is(err, clients.ErrProductNotFound) {
+					t.Errorf("got error %v, want ErrProductNotFound", err)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a batch stock check of a stocked and an unknown product",
+				State:       "PROD-001 has 50 units available",
+				Request: contract.Request{
+					Method: http.MethodPost,
+					Path:   "/api/v1/inventory/check",
+					Body: contract.Body(map[string]interface{}{
+						"items": []map[string]interface{}{
+							{"product_id": "PROD-001"},
+							{"product_id": "PROD-404"},
+						},
+					}),
+				},
+				Response: contract.Response{
+					Status:      http.StatusOK,
+					ContentType: "application/json",
+					Body: contract.Body(map[string]interface{}{
+						"items": []map[string]interface{}{
+							{"product_id": "PROD-001", "found": true, "name": "Laptop", "quantity": 50, "reserved": 0, "available": 50, "sufficient": true},
+							{"product_id": "PROD-404", "found": false, "quantity": 0, "reserved": 0, "available": 0, "sufficient": false},
+						},
+					}),
+					Exact: []string{"items.product_id", "items.found", "items.available"},
+				},
+			},
+			call: func(t *testing.T) {
+				results, err := client.CheckStockBatch(ctx, []string{"PROD-001", "PROD-404"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !results[0].Found || results[0].Available != 50 || results[1].Found {
+					t.Errorf("got results %+v", results)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "an announced reservation for an order",
+				State:       "PROD-001 has 50 units available",
+				Request: contract.Request{
+					Method: http.MethodPost,
+					Path:   "/api/v1/inventory/reserve",
+					Body: contract.Body(map[string]interface{}{
+						"product_id": "PROD-001",
+						"quantity":   2,
+						"order_id":   "ORD-CONTRACT-1",
+						"announce":   true,
+					}),
+				},
+				Response: contract.Response{
+					Status:      http.StatusOK,
+					ContentType: "application/json",
+					Body: contract.Body(map[string]interface{}{
+						"message":           "Stock reserved successfully",
+						"reservation_id":    "RSV-1",
+						"product_id":        "PROD-001",
+						"reserved_quantity": 2,
+						"new_available":     48,
+						"event_id":          "1",
+					}),
+					Exact: []string{"product_id", "reserved_quantity", "new_available"},
+				},
+			},
+			call: func(t *testing.T) {
+				reservation, err := client.ReserveStock(ctx, "ORD-CONTRACT-1", "PROD-001", 2, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reservation.ReservationID == "" || reservation.EventID == "" || reservation.ReservedQuantity != 2 || reservation.NewAvailable != 48 {
+					t.Errorf("got reservation %+v", reservation)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a reservation of more than is available",
+				State:       "PROD-001 has 50 units available",
+				Request: contract.Request{
+					Method: http.MethodPost,
+					Path:   "/api/v1/inventory/reserve",
+					Body: contract.Body(map[string]interface{}{
+						"product_id": "PROD-001",
+						"quantity":   1000,
+						"order_id":   "ORD-CONTRACT-2",
+					}),
+				},
+				Response: contract.Response{
+					Status:      http.StatusConflict,
+					ContentType: problem.ContentType,
+					Body: contract.Body(map[string]interface{}{
+						"code":   problem.CodeInsufficientStock,
+						"detail": "Requested 1000, only 50 available",
+					}),
+					Exact: []string{"code"},
+				},
+			},
+			call: func(t *testing.T) {
+				if _, err := client.ReserveStock(ctx, "ORD-CONTRACT-2", "PROD-001", 1000, false); !errors.Is(err, clients.ErrInsufficientStock) {
+					t.Errorf("got error %v, want ErrInsufficientStock", err)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a reservation of an unknown product",
+				State:       "PROD-404 does not exist",
+				Request: contract.Request{
+					Method: http.MethodPost,
+					Path:   "/api/v1/inventory/reserve",
+					Body: contract.Body(map[string]interface{}{
+						"product_id": "PROD-404",
+						"quantity":   1,
+						"order_id":   "ORD-CONTRACT-3",
+					}),
+				},
+				Response: notFound("PROD-404"),
+			},
+			call: func(t *testing.T) {
+				if _, err := client.ReserveStock(ctx, "ORD-CONTRACT-3", "PROD-404", 1, false); !errors.Is(err, clients.ErrProductNotFound) {
+					t.Errorf("got error %v, want ErrProductNotFound", err)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a release of an order's reservation",
+				State:       "ORD-CONTRACT-1 holds 2 units of PROD-001",
+				Request: contract.Request{
+					Method: http.MethodPost,
+					Path:   "/api/v1/inventory/release",
+					Body: contract.Body(map[string]interface{}{
+						"product_id": "PROD-001",
+						"quantity":   2,
+						"order_id":   "ORD-CONTRACT-1",
+					}),
+				},
+				Response: contract.Response{
+					Status:      http.StatusOK,
+					ContentType: "application/json",
+					Body: contract.Body(map[string]interface{}{
+						"message":           "Stock released successfully",
+						"product_id":        "PROD-001",
+						"released_quantity": 2,
+						"new_available":     50,
+					}),
+					Exact: []string{"product_id", "released_quantity", "new_available"},
+				},
+			},
+			call: func(t *testing.T) {
+				release, err := client.ReleaseStock(ctx, "ORD-CONTRACT-1", "PROD-001", 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if release.ReleasedQuantity != 2 || release.NewAvailable != 50 {
+					t.Errorf("got release %+v", release)
+				}
+			},
+		},
+		{
+			interaction: contract.Interaction{
+				Description: "a health check",
+				Request:     contract.Request{Method: http.MethodGet, Path: "/health"},
+				Response:    contract.Response{Status: http.StatusOK},
+			},
+			call: func(t *testing.T) {
+				if err := client.Ping(ctx); err != nil {
+					t.Error(err)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.interaction.Description, func(t *testing.T) {
+			mock.Expect(tc.interaction)
+			tc.call(t)
+			if err := mock.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	recorded, err := mock.Contract().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(warehouseContract), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(warehouseContract, recorded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	committed, err := os.ReadFile(warehouseContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, recorded) {
+		t.Errorf("%s does not match the interactions; run go test ./tests -run TestWarehouseClientContract -update and commit it", warehouseContract)
+	}
+}
+
+// notFound is warehouse-service's answer for an unknown product.
+func notFound(productID string) contract.Response {
+	return contract.Response{
+		Status:      http.StatusNotFound,
+		ContentType: problem.ContentType,
+		Body: contract.Body(map[string]interface{}{
+			"code":   problem.CodeProductNotFound,
+			"detail": "Product " + productID + " does not exist",
+		}),
+		Exact: []string{"code"},
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"observability-system/shared/chaos"
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/health"
+	"observability-system/shared/httplimit"
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/tracing"
+	"payment-service/internal/config"
+	"payment-service/internal/database"
+	"payment-service/internal/handlers"
+	"payment-service/internal/metrics"
+	"payment-service/internal/routes"
+	"payment-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	cfg := config.Load(os.Args[1:])
+	secretResolver, err := cfg.ResolveSecrets(context.Background())
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		// Reported before the logger exists, which needs a valid config.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logLevel := logger.DefaultLevel(cfg.Environment)
+	if cfg.LogLevel != "" {
+		// Validate rejected unknown levels.
+		logLevel, _ = logger.ParseLevel(cfg.LogLevel)
+	}
+	log, err := logger.NewZapLogger(logger.Config{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Level:       logLevel,
+		Encoding:    cfg.LogEncoding,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
+	}
+	defer log.Sync()
+
+	log.Info("Starting payment service",
+		logger.String("port", cfg.Port),
+		logger.String("environment", cfg.Environment),
+		logger.String("jaeger_endpoint", cfg.JaegerEndpoint))
+
+	settings := sharedconfig.Redact(cfg)
+	log.Info("Effective configuration", logger.Any("config", settings))
+
+	if err := tracing.InitTracer(tracing.Config{
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: "1.0.0",
+		Environment:    cfg.Environment,
+		JaegerEndpoint: cfg.JaegerEndpoint,
+		SampleRatio:    cfg.TraceSampleRatio,
+	}); err != nil {
+		log.Fatal("Failed to initialize tracer",
+			logger.Err(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracing.ShutdownTracer(ctx); err != nil {
+			log.Error("Error shutting down tracer", logger.Err(err))
+		}
+	}()
+
+	log.Info("Tracer initialized successfully")
+
+	sharedmetrics.EnableExemplars(cfg.MetricsExemplars)
+	metricsRegistry := metrics.InitMetrics(sharedmetrics.Config{
+		Service:  cfg.ServiceName,
+		MaxPaths: cfg.MetricsMaxPaths,
+	})
+	log.Info("Metrics initialized successfully")
+
+	// Without the debug endpoints chaos can only be configured at startup,
+	// so with it disabled there is nothing to inject.
+	var chaosInjector *chaos.Injector
+	if cfg.ChaosEnabled || cfg.DebugEndpoints {
+		chaosInjector, err = chaos.New(log, chaos.Uniform(cfg.ChaosEnabled, cfg.ChaosTargets, chaos.Rule{
+			MinLatencyMs: int(cfg.ChaosMinLatency.Milliseconds()),
+			MaxLatencyMs: int(cfg.ChaosMaxLatency.Milliseconds()),
+			ErrorRate:    cfg.ChaosErrorRate,
+			TimeoutRate:  cfg.ChaosTimeoutRate,
+			TimeoutMs:    int(cfg.ChaosTimeout.Milliseconds()),
+			DropRate:     cfg.ChaosDropRate,
+		}))
+		if err != nil {
+			log.Fatal("Invalid chaos configuration", logger.Err(err))
+		}
+		if cfg.ChaosEnabled {
+			log.Warn("Chaos mode enabled", logger.Any("rules", chaosInjector.Config().Rules))
+		}
+	}
+
+	db, err := database.NewConnection(secretResolver.Func(cfg.Secret("DATABASE_URL")), cfg.DBConnMaxLifetime, chaosInjector)
+	if err != nil {
+		log.Fatal("Failed to connect to database",
+			logger.Err(err))
+	}
+	defer db.Close()
+
+	log.Info("Connected to database successfully")
+
+	if err := database.InitSchema(db); err != nil {
+		log.Fatal("Failed to initialize database schema",
+			logger.Err(err))
+	}
+
+	var rabbitMQClient *rabbitmq.Client
+	if cfg.EnableBroker {
+		rabbitMQClient, err = rabbitmq.NewClientWithURL(secretResolver.Func(cfg.Secret("RABBITMQ_URL")))
+		if err != nil {
+			log.Fatal("Failed to connect to RabbitMQ", logger.Err(err))
+		}
+		defer rabbitMQClient.Close()
+		log.Info("Connected to RabbitMQ successfully")
+
+		if err := rabbitmq.SetupExchangesAndQueues(rabbitMQClient); err != nil {
+			log.Fatal("Failed to setup RabbitMQ exchanges and queues", logger.Err(err))
+		}
+		log.Info("RabbitMQ exchanges and queues configured")
+	}
+
+	gin.SetMode(cfg.GinMode)
+	router := gin.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	outboxCfg := outboxinbox.DefaultOutboxConfig()
+	outboxCfg.DefaultExchange = "payments"
+	outboxCfg.LockTimeout = cfg.LockTimeout
+	if cfg.RetentionArchive {
+		outboxCfg.ArchiveTableName = "outbox_archive"
+	}
+	if !cfg.ListenNotify {
+		outboxCfg.NotifyChannel = ""
+	}
+
+	outboxStore := outboxinbox.NewOutboxStore(db, outboxCfg)
+	if err := outboxStore.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize outbox schema", logger.Err(err))
+	}
+
+	log.Info("Database schema initialized")
+
+	simulator, err := services.NewSimulator(log, services.FailureConfig{
+		MinLatencyMs: int(cfg.PaymentMinLatency.Milliseconds()),
+		MaxLatencyMs: int(cfg.PaymentMaxLatency.Milliseconds()),
+		ErrorRate:    cfg.PaymentErrorRate,
+		TimeoutRate:  cfg.PaymentTimeoutRate,
+		TimeoutMs:    int(cfg.PaymentTimeout.Milliseconds()),
+		DeclineRate:  cfg.PaymentDeclineRate,
+		MaxAmount:    cfg.PaymentMaxAmount,
+	})
+	if err != nil {
+		log.Fatal("Invalid payment failure configuration", logger.Err(err))
+	}
+	log.Info("Payment provider simulation configured", logger.Any("failures", simulator.Config()))
+
+	paymentService := services.NewPaymentService(db, outboxStore)
+	paymentHandler := handlers.NewPaymentHandler(log, paymentService, simulator)
+	failuresHandler := handlers.NewFailuresHandler(log, simulator)
+
+	retryBackoff := outboxinbox.BackoffPolicy{
+		Base:       cfg.RetryBackoffBase,
+		Multiplier: cfg.RetryBackoffMultiplier,
+		Max:        cfg.RetryBackoffMax,
+		Jitter:     cfg.RetryBackoffJitter,
+	}
+
+	var outboxNotifier *outboxinbox.Notifier
+	var outboxPool *outboxinbox.WorkerPool
+	var outboxElector *lock.Elector
+	if cfg.EnableBroker {
+		if cfg.ListenNotify {
+			outboxNotifier = outboxinbox.NewNotifier(cfg.DatabaseURL, outboxCfg.NotifyChannel, log)
+			go func() {
+				if err := outboxNotifier.Start(ctx); err != nil {
+					log.Error("Message notifier stopped, falling back to polling", logger.Err(err))
+				}
+			}()
+		}
+
+		outboxPool = outboxinbox.NewWorkerPool("outbox", log, cfg.OutboxWorkers, func() outboxinbox.Worker {
+			worker := outboxinbox.NewOutboxWorker(outboxStore, rabbitMQClient, log, cfg.BatchSize, cfg.PollInterval, cfg.OutboxMaxRetries, retryBackoff)
+			worker.SetMaxIdleInterval(cfg.MaxIdlePollInterval)
+			if outboxNotifier != nil {
+				worker.SetWakeup(outboxNotifier.Subscribe())
+			}
+			return worker
+		})
+		if cfg.OutboxLeaderElection {
+			electionLocker := lock.New(db.DB, log)
+			electionLocker.SetRenewInterval(cfg.JobLockRenewInterval)
+			outboxElector = lock.NewElector(electionLocker, outboxinbox.DispatcherElection, log, cfg.OutboxLeaderRetryInterval)
+			go outboxElector.Start(ctx, outboxPool.Run)
+		} else {
+			outboxPool.Start(ctx)
+		}
+	} else {
+		// Without a broker, outbox rows stay PENDING until a broker-enabled
+		// instance picks them up.
+		log.Warn("Broker disabled, outbox workers not started")
+	}
+
+	// The reaper and janitor take an advisory lock per run, so replicas
+	// sharing payment_db do not run them concurrently.
+	var locker *lock.Locker
+	if cfg.JobLocks {
+		locker = lock.New(db.DB, log)
+		locker.SetRenewInterval(cfg.JobLockRenewInterval)
+	}
+
+	statsCollector := outboxinbox.NewStatsCollector(log, cfg.StatsInterval, outboxStore)
+	go statsCollector.Start(ctx)
+
+	var reaper *outboxinbox.Reaper
+	if cfg.ReaperInterval > 0 {
+		reaper = outboxinbox.NewReaper(log, cfg.ReaperInterval, cfg.LockTimeout, outboxStore)
+		reaper.SetLocker(locker)
+		go reaper.Start(ctx)
+	}
+
+	var janitor *outboxinbox.Janitor
+	if cfg.RetentionPeriod > 0 {
+		janitor = outboxinbox.NewJanitor(log, cfg.RetentionInterval, cfg.RetentionPeriod, cfg.RetentionBatchSize, outboxStore)
+		janitor.SetLocker(locker)
+		go janitor.Start(ctx)
+	}
+
+	opsReporter := ops.NewReporter(cfg.ServiceName, log)
+	opsReporter.AddTable(outboxPool, outboxStore)
+	opsReporter.AddRecent("payments", handlers.RecentPayments(paymentService))
+
+	prober := health.NewProber(cfg.ServiceName, log, cfg.HealthCheckTimeout)
+	prober.SetCacheTTL(cfg.HealthCacheTTL)
+	if cfg.HealthMinFreeDisk > 0 {
+		prober.AddDiagnostic("disk", health.DiskSpace(cfg.HealthDiskPath, cfg.HealthMinFreeDisk))
+	}
+	if cfg.HealthMaxGoroutines > 0 {
+		prober.AddDiagnostic("goroutines", health.Goroutines(cfg.HealthMaxGoroutines))
+	}
+	prober.AddReadiness("database", health.Database(db))
+	prober.AddReadiness("schema", health.Schema(db, append([]string{outboxCfg.TableName}, database.Tables...)...))
+	if cfg.EnableBroker {
+		prober.AddLiveness("outbox_workers", health.Workers(outboxPool, cfg.HealthWorkerStallAfter))
+		prober.AddReadiness("rabbitmq", health.Broker(rabbitMQClient))
+		if outboxElector == nil {
+			// Standby replicas never start their outbox workers.
+			prober.AddReadiness("outbox_workers", health.WorkersStarted(outboxPool))
+		}
+	}
+
+	mw := routes.Middleware{
+		Timeout:     httplimit.Timeout(cfg.RequestTimeout, nil),
+		MaxBodySize: httplimit.MaxBodySize(cfg.MaxBodySize, nil),
+	}
+
+	metricsNetworks, err := sharedmetrics.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatal("Invalid METRICS_ALLOWED_NETWORKS", logger.Err(err))
+	}
+	mw.MetricsAuth = sharedmetrics.AuthMiddleware(sharedmetrics.AuthConfig{
+		BearerToken:     cfg.MetricsAuthToken,
+		Username:        cfg.MetricsAuthUsername,
+		Password:        cfg.MetricsAuthPassword,
+		AllowedNetworks: metricsNetworks,
+	}, log)
+	if mw.MetricsAuth != nil {
+		log.Info("Metrics endpoint protected",
+			logger.Bool("bearer_token", cfg.MetricsAuthToken != ""),
+			logger.Bool("basic_auth", cfg.MetricsAuthUsername != ""),
+			logger.String("allowed_networks", cfg.MetricsAllowedNetworks))
+	}
+	// /internal/config shares the /metrics credentials, but is left out
+	// rather than served openly when there are none.
+	mw.ConfigAuth = mw.MetricsAuth
+	if mw.ConfigAuth == nil {
+		log.Info("GET /internal/config disabled, set METRICS_AUTH_TOKEN, METRICS_AUTH_USERNAME or METRICS_ALLOWED_NETWORKS to enable it")
+	}
+
+	if chaosInjector != nil {
+		mw.Chaos = chaosInjector.Middleware()
+	}
+
+	routes.SetupRoutes(router, log, cfg.ServiceName, paymentHandler, failuresHandler, chaos.NewHandler(log, chaosInjector), cfg.DebugEndpoints, sharedconfig.Handler(cfg.ServiceName, settings), opsReporter, prober, metricsRegistry, mw)
+
+	log.Info("Routes configured")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	log.Info("Server starting",
+		logger.String("address", addr))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server",
+				logger.Err(err))
+		}
+	}()
+
+	<-sigChan
+	log.Info("Shutdown signal received, initiating graceful shutdown",
+		logger.Duration("timeout", cfg.ShutdownTimeout))
+
+	// Stop accepting connections and let in-flight requests finish before the
+	// workers and broker they may depend on go away.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP server did not drain in time, closing remaining connections", logger.Err(err))
+		server.Close()
+	} else {
+		log.Info("HTTP server stopped")
+	}
+	cancelShutdown()
+
+	cancel()
+
+	if outboxElector != nil {
+		outboxElector.Stop()
+	}
+	if outboxPool != nil {
+		outboxPool.Stop()
+	}
+
+	statsCollector.Stop()
+
+	if reaper != nil {
+		reaper.Stop()
+	}
+
+	if janitor != nil {
+		janitor.Stop()
+		log.Info("Retention janitor stopped")
+	}
+
+	log.Info("Service shutdown complete")
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"observability-system/shared/chaos"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// NewConnection creates a new database connection using sqlx. dsn is asked
+// for the database URL whenever a connection is opened, so the pool picks
+// up rotated credentials as connMaxLifetime recycles its connections.
+// injector injects chaos faults into its queries; nil injects none.
+func NewConnection(dsn func(context.Context) (string, error), connMaxLifetime time.Duration, injector *chaos.Injector) (*sqlx.DB, error) {
+	db := sqlx.NewDb(sql.OpenDB(chaos.Connector(dsnConnector{dsn: dsn}, injector)), "postgres")
+
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(connMaxLifetime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+
+	return db, nil
+}
+
+// Tables lists the tables InitSchema creates; readiness waits for them.
+var Tables = []string{"payments"}
+
+// dsnConnector opens connections with the DSN dsn returns at that moment,
+// so connections opened after a credential rotation use the new secret.
+type dsnConnector struct {
+	dsn func(context.Context) (string, error)
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database URL: %w", err)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (dsnConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// InitSchema creates the service's domain tables. The inbox and outbox tables
+// are owned by the shared outboxinbox stores.
+func InitSchema(db *sqlx.DB) error {
+	schema := `
+	-- One payment per order: authorizations are idempotent by order ID.
+	CREATE TABLE IF NOT EXISTS payments (
+		id BIGSERIAL PRIMARY KEY,
+		payment_id VARCHAR(255) NOT NULL UNIQUE,
+		order_id VARCHAR(255) NOT NULL UNIQUE,
+		customer_id VARCHAR(255),
+		amount DECIMAL(10, 2) NOT NULL,
+		currency VARCHAR(3) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		decline_reason VARCHAR(255),
+		refund_reason VARCHAR(255),
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status, id);
+	`
+
+	_, err := db.Exec(schema)
+	if err != nil {
+		return fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"payment-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminActorHeader names who made an admin change when the request is not
+// authenticated.
+const adminActorHeader = "X-Admin-User"
+
+// FailuresHandler inspects and reconfigures the simulated payment provider
+// at runtime.
+type FailuresHandler struct {
+	logger    logger.Logger
+	simulator *services.Simulator
+}
+
+func NewFailuresHandler(log logger.Logger, simulator *services.Simulator) *FailuresHandler {
+	return &FailuresHandler{
+		logger:    log,
+		simulator: simulator,
+	}
+}
+
+func (h *FailuresHandler) GetFailures(c *gin.Context) {
+	c.JSON(http.StatusOK, h.simulator.Config())
+}
+
+// SetFailures replaces the failure configuration; it applies to operations
+// started from then on.
+func (h *FailuresHandler) SetFailures(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var cfg services.FailureConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+	if err := h.simulator.SetConfig(cfg); err != nil {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, err.Error()))
+		return
+	}
+
+	h.logger.WarnCtx(ctx, "Payment failure simulation changed",
+		logger.Any("failures", cfg),
+		logger.String("actor", adminActor(c)))
+
+	c.JSON(http.StatusOK, cfg)
+}
+
+// adminActor identifies who triggered an admin action for audit logs: the
+// authenticated user when available, then the X-Admin-User header, then the
+// client IP.
+func adminActor(c *gin.Context) string {
+	if userID := logger.GetUserID(c.Request.Context()); userID != "" {
+		return userID
+	}
+	if actor := c.GetHeader(adminActorHeader); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
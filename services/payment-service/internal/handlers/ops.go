@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"observability-system/shared/ops"
+	"payment-service/internal/services"
+)
+
+// RecentPayments lists the newest payments for the operational snapshot
+// served at /internal/ops.
+func RecentPayments(paymentService *services.PaymentService) ops.RecentFunc {
+	return func(ctx context.Context, limit int) ([]ops.Record, error) {
+		payments, err := paymentService.List(ctx, services.PaymentFilter{Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		records := make([]ops.Record, 0, len(payments))
+		for _, p := range payments {
+			summary := fmt.Sprintf("%.2f %s for order %s", p.Amount, p.Currency, p.OrderID)
+			if p.DeclineReason != "" {
+				summary += ", declined: " + p.DeclineReason
+			}
+			updatedAt := p.UpdatedAt
+			records = append(records, ops.Record{
+				ID:        p.ID,
+				Status:    p.Status,
+				Summary:   summary,
+				CreatedAt: p.CreatedAt,
+				UpdatedAt: &updatedAt,
+			})
+		}
+		return records, nil
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"payment-service/internal/metrics"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// IdempotencyKeyHeader carries the order ID on authorizations. When sent it
+// must match the body's order_id, since authorizations are idempotent per
+// order.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type AuthorizeRequest struct {
+	OrderID    string  `json:"order_id" binding:"required"`
+	CustomerID string  `json:"customer_id"`
+	ProductID  string  `json:"product_id"`
+	Quantity   int     `json:"quantity"`
+	Amount     float64 `json:"amount" binding:"gt=0"`
+	Currency   string  `json:"currency" binding:"required,len=3"`
+}
+
+type RefundRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PaymentHandler struct {
+	logger    logger.Logger
+	payments  *services.PaymentService
+	simulator *services.Simulator
+}
+
+func NewPaymentHandler(log logger.Logger, payments *services.PaymentService, simulator *services.Simulator) *PaymentHandler {
+	return &PaymentHandler{
+		logger:    log,
+		payments:  payments,
+		simulator: simulator,
+	}
+}
+
+// Authorize authorizes an order's amount, answering 201 with the payment,
+// or 402 with the reason when the provider declines it. An order has one
+// payment, so authorizing an order again answers with its payment as it
+// stands, 200 or 402, without asking the provider. Provider failures answer
+// 503 or 504 and leave nothing behind, so the call can be retried.
+func (h *PaymentHandler) Authorize(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+	if key := c.GetHeader(IdempotencyKeyHeader); key != "" && key != req.OrderID {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			IdempotencyKeyHeader+" must be the order_id").
+			With("order_id", req.OrderID))
+		return
+	}
+	if req.Amount > models.MaxPaymentAmount {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			fmt.Sprintf("amount must not exceed %.2f", models.MaxPaymentAmount)))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("payment.operation", services.OpAuthorize),
+		attribute.String("order.id", req.OrderID),
+		attribute.Float64("payment.amount", req.Amount),
+		attribute.String("payment.currency", req.Currency),
+	)
+
+	existing, err := h.payments.GetByOrder(ctx, req.OrderID)
+	if err == nil {
+		h.logger.InfoCtx(ctx, "Order already has a payment, returning it",
+			logger.String("order_id", req.OrderID),
+			logger.String("payment_id", existing.ID),
+			logger.String("status", existing.Status))
+		h.writeAuthorization(c, existing, http.StatusOK)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		h.internalError(c, services.OpAuthorize, "Failed to look up payment", err, logger.String("order_id", req.OrderID))
+		return
+	}
+
+	if err := h.simulator.Call(ctx, services.OpAuthorize); err != nil {
+		h.providerFailure(c, services.OpAuthorize, err)
+		return
+	}
+
+	payment := &models.Payment{
+		ID:         uuid.New().String(),
+		OrderID:    req.OrderID,
+		CustomerID: req.CustomerID,
+		Amount:     req.Amount,
+		Currency:   strings.ToUpper(req.Currency),
+		Status:     models.PaymentStatusAuthorized,
+	}
+	if reason := h.simulator.Decline(ctx, req.Amount); reason != "" {
+		payment.Status = models.PaymentStatusDeclined
+		payment.DeclineReason = reason
+	}
+
+	stored, created, err := h.payments.Create(ctx, payment)
+	if err != nil {
+		h.internalError(c, services.OpAuthorize, "Failed to store payment", err, logger.String("order_id", req.OrderID))
+		return
+	}
+	if !created {
+		// A concurrent authorization of the same order won.
+		h.writeAuthorization(c, stored, http.StatusOK)
+		return
+	}
+
+	outcome := metrics.OutcomeSuccess
+	if stored.Status == models.PaymentStatusDeclined {
+		outcome = metrics.OutcomeDeclined
+	}
+	metrics.RecordOperation(services.OpAuthorize, outcome)
+	metrics.RecordAmount(stored.Status, stored.Currency, stored.Amount)
+
+	h.logger.InfoCtx(ctx, "Payment "+stored.Status,
+		logger.String("order_id", stored.OrderID),
+		logger.String("payment_id", stored.ID),
+		logger.String("amount", fmt.Sprintf("%.2f %s", stored.Amount, stored.Currency)),
+		logger.String("reason", stored.DeclineReason))
+
+	h.writeAuthorization(c, stored, http.StatusCreated)
+}
+
+// writeAuthorization answers with payment, or with a 402 problem carrying
+// the reason when it was declined.
+func (h *PaymentHandler) writeAuthorization(c *gin.Context, payment *models.Payment, status int) {
+	tracing.AddSpanAttributes(c.Request.Context(),
+		attribute.String("payment.id", payment.ID),
+		attribute.String("payment.status", payment.Status),
+	)
+	if payment.Status == models.PaymentStatusDeclined {
+		problem.Write(c, problem.New(http.StatusPaymentRequired, problem.CodePaymentFailed, "Payment declined: "+payment.DeclineReason).
+			With("payment_id", payment.ID).
+			With("order_id", payment.OrderID).
+			With("reason", payment.DeclineReason))
+		return
+	}
+	c.JSON(status, payment)
+}
+
+// Capture settles an authorized payment. Capturing it again answers with
+// the captured payment; declined and refunded payments answer 409.
+func (h *PaymentHandler) Capture(c *gin.Context) {
+	h.transition(c, services.OpCapture, func(ctx context.Context, paymentID string) (*models.Payment, bool, error) {
+		return h.payments.Capture(ctx, paymentID)
+	})
+}
+
+// Refund returns an authorized or captured payment, e.g. to compensate an
+// order that failed after it was paid. Refunding it again answers with the
+// refunded payment; declined payments answer 409.
+func (h *PaymentHandler) Refund(c *gin.Context) {
+	var req RefundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Write(c, problem.ValidationFailed(err))
+			return
+		}
+	}
+	h.transition(c, services.OpRefund, func(ctx context.Context, paymentID string) (*models.Payment, bool, error) {
+		return h.payments.Refund(ctx, paymentID, req.Reason)
+	})
+}
+
+func (h *PaymentHandler) transition(c *gin.Context, op string, apply func(ctx context.Context, paymentID string) (*models.Payment, bool, error)) {
+	ctx := c.Request.Context()
+	paymentID := c.Param("payment_id")
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("payment.operation", op),
+		attribute.String("payment.id", paymentID),
+	)
+
+	if err := h.simulator.Call(ctx, op); err != nil {
+		h.providerFailure(c, op, err)
+		return
+	}
+
+	payment, changed, err := apply(ctx, paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodePaymentNotFound, "Payment "+paymentID+" does not exist").
+			With("payment_id", paymentID))
+		return
+	}
+	if errors.Is(err, services.ErrInvalidTransition) {
+		metrics.RecordOperation(op, metrics.OutcomeConflict)
+		h.logger.WarnCtx(ctx, "Payment status does not allow "+op,
+			logger.String("payment_id", paymentID),
+			logger.String("status", payment.Status))
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeConflict, err.Error()).
+			With("payment_id", paymentID).
+			With("status", payment.Status))
+		return
+	}
+	if err != nil {
+		h.internalError(c, op, "Failed to "+op+" payment", err, logger.String("payment_id", paymentID))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", payment.OrderID),
+		attribute.String("payment.status", payment.Status),
+	)
+	if changed {
+		metrics.RecordOperation(op, metrics.OutcomeSuccess)
+		metrics.RecordAmount(payment.Status, payment.Currency, payment.Amount)
+		h.logger.InfoCtx(ctx, "Payment "+payment.Status,
+			logger.String("order_id", payment.OrderID),
+			logger.String("payment_id", payment.ID),
+			logger.String("amount", fmt.Sprintf("%.2f %s", payment.Amount, payment.Currency)),
+			logger.String("reason", payment.RefundReason))
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+	paymentID := c.Param("payment_id")
+
+	payment, err := h.payments.Get(ctx, paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodePaymentNotFound, "Payment "+paymentID+" does not exist").
+			With("payment_id", paymentID))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to get payment",
+			logger.Err(err),
+			logger.String("payment_id", paymentID))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to get payment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// ListPayments lists payments newest first, filtered by the order_id and
+// status query parameters.
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	status := c.Query("status")
+	if status != "" && !isPaymentStatus(status) {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Unknown payment status "+status).
+			With("status", status))
+		return
+	}
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	payments, err := h.payments.List(ctx, services.PaymentFilter{
+		OrderID: c.Query("order_id"),
+		Status:  status,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to list payments", logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to list payments"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(payments),
+		"limit":    limit,
+		"offset":   offset,
+		"payments": payments,
+	})
+}
+
+// providerFailure answers a simulated provider failure with 504 for
+// timeouts and 503 otherwise.
+func (h *PaymentHandler) providerFailure(c *gin.Context, op string, err error) {
+	ctx := c.Request.Context()
+	metrics.RecordOperation(op, metrics.OutcomeError)
+
+	tracing.AddSpanAttributes(ctx, attribute.String("error", err.Error()))
+	h.logger.WarnCtx(ctx, "Payment provider failed",
+		logger.String("operation", op),
+		logger.Err(err))
+
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	problem.Write(c, problem.New(status, problem.CodeDependencyUnavailable, err.Error()).
+		With("operation", op))
+}
+
+func (h *PaymentHandler) internalError(c *gin.Context, op, message string, err error, fields ...logger.Field) {
+	metrics.RecordOperation(op, metrics.OutcomeError)
+	h.logger.ErrorCtx(c.Request.Context(), message, append(fields, logger.Err(err))...)
+	problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, message))
+}
+
+func isPaymentStatus(status string) bool {
+	for _, s := range models.PaymentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit = defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return 0, 0, false
+		}
+		limit = v
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if raw := c.Query("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "offset must be a non-negative integer"))
+			return 0, 0, false
+		}
+		offset = v
+	}
+
+	return limit, offset, true
+}
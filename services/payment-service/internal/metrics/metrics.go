@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"observability-system/shared/chaos"
+	"observability-system/shared/lock"
+	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/secrets"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The service's business metrics, registered by InitMetrics.
+var (
+	paymentOperationsTotal *prometheus.CounterVec
+	paymentAmountTotal     *prometheus.CounterVec
+	simulatedFailuresTotal *prometheus.CounterVec
+)
+
+// InitMetrics builds the service's registry: the shared HTTP metrics, the
+// service's business metrics and those of Collectors.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...)
+
+	paymentOperationsTotal = registry.Counter("payment_operations_total",
+		"Total number of payment operations by operation and outcome", "operation", "outcome")
+	paymentAmountTotal = registry.Counter("payment_amount_total",
+		"Total amount of payments by the status they reached and currency", "status", "currency")
+	simulatedFailuresTotal = registry.Counter("payment_simulated_failures_total",
+		"Total number of payment provider failures simulated by operation and fault", "operation", "fault")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the service uses.
+func Collectors() []prometheus.Collector {
+	var collectors []prometheus.Collector
+	collectors = append(collectors, outboxinbox.Collectors()...)
+	collectors = append(collectors, rabbitmq.Collectors()...)
+	collectors = append(collectors, secrets.Collectors()...)
+	collectors = append(collectors, lock.Collectors()...)
+	collectors = append(collectors, chaos.Collectors()...)
+	return collectors
+}
+
+// Operation outcomes recorded by RecordOperation.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeDeclined = "declined"
+	OutcomeConflict = "conflict"
+	OutcomeError    = "error"
+)
+
+// RecordOperation counts an authorize, capture or refund by its outcome.
+func RecordOperation(operation, outcome string) {
+	paymentOperationsTotal.WithLabelValues(operation, outcome).Inc()
+}
+
+// RecordAmount adds the amount of a payment that reached status.
+func RecordAmount(status, currency string, amount float64) {
+	paymentAmountTotal.WithLabelValues(status, currency).Add(amount)
+}
+
+// RecordSimulatedFailure counts a provider failure the simulator injected.
+func RecordSimulatedFailure(operation, fault string) {
+	simulatedFailuresTotal.WithLabelValues(operation, fault).Inc()
+}
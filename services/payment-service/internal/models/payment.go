@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Payment events written to the outbox alongside the payment row.
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentDeclined   = "payment.declined"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentRefunded   = "payment.refunded"
+)
+
+// A payment is authorized or declined when it is created. An authorized
+// payment is captured when its order is fulfilled, or refunded when the
+// order is compensated; a captured payment can still be refunded.
+const (
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusDeclined   = "declined"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusRefunded   = "refunded"
+)
+
+// PaymentStatuses lists every payment status.
+var PaymentStatuses = []string{
+	PaymentStatusAuthorized,
+	PaymentStatusDeclined,
+	PaymentStatusCaptured,
+	PaymentStatusRefunded,
+}
+
+// MaxPaymentAmount is the largest amount the payments table's DECIMAL(10, 2)
+// column can hold.
+const MaxPaymentAmount = 99999999.99
+
+type Payment struct {
+	Seq           int64     `db:"id" json:"-"`
+	ID            string    `db:"payment_id" json:"payment_id"`
+	OrderID       string    `db:"order_id" json:"order_id"`
+	CustomerID    string    `db:"customer_id" json:"customer_id,omitempty"`
+	Amount        float64   `db:"amount" json:"amount"`
+	Currency      string    `db:"currency" json:"currency"`
+	Status        string    `db:"status" json:"status"`
+	DeclineReason string    `db:"decline_reason" json:"decline_reason,omitempty"`
+	RefundReason  string    `db:"refund_reason" json:"refund_reason,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// PaymentEvent is the payload of the payment events.
+type PaymentEvent struct {
+	PaymentID  string    `json:"payment_id"`
+	OrderID    string    `json:"order_id"`
+	CustomerID string    `json:"customer_id,omitempty"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewPaymentEvent builds the event payload describing payment's current
+// state; reason explains declines and refunds.
+func NewPaymentEvent(payment *Payment, reason string) PaymentEvent {
+	return PaymentEvent{
+		PaymentID:  payment.ID,
+		OrderID:    payment.OrderID,
+		CustomerID: payment.CustomerID,
+		Amount:     payment.Amount,
+		Currency:   payment.Currency,
+		Status:     payment.Status,
+		Reason:     reason,
+		OccurredAt: payment.UpdatedAt,
+	}
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"observability-system/shared/apiversion"
+	"observability-system/shared/chaos"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"payment-service/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware holds the optional middleware configured in main; nil fields
+// are skipped.
+type Middleware struct {
+	Timeout     gin.HandlerFunc
+	MaxBodySize gin.HandlerFunc
+	// MetricsAuth guards /metrics and /internal/ops; nil serves them openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+	// Chaos injects faults into the API routes.
+	Chaos gin.HandlerFunc
+}
+
+// SetupRoutes registers the payment API under /api/v1. debugEndpoints adds
+// /admin/failures, which reconfigures the simulated payment provider, and
+// /admin/chaos, which reconfigures fault injection.
+func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, handler *handlers.PaymentHandler, failuresHandler *handlers.FailuresHandler, chaosHandler *chaos.Handler, debugEndpoints bool, configHandler gin.HandlerFunc, opsReporter *ops.Reporter, prober *health.Prober, registry *metrics.Registry, mw Middleware) {
+
+	router.Use(tracing.GinMiddleware(serviceName))
+
+	router.Use(logger.InjectLogger(log))
+	router.Use(logger.GinMiddleware(log))
+	router.Use(problem.Recovery())
+
+	router.Use(registry.Middleware())
+	for _, h := range []gin.HandlerFunc{mw.Timeout, mw.MaxBodySize} {
+		if h != nil {
+			router.Use(h)
+		}
+	}
+
+	router.NoRoute(problem.NoRoute)
+
+	router.GET("/health", prober.Health)
+	router.GET("/live", prober.Live)
+	router.GET("/ready", prober.Ready)
+	router.GET("/metrics", chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	router.GET("/internal/ops", chain(mw.MetricsAuth, opsReporter.Handler)...)
+	if mw.ConfigAuth != nil {
+		router.GET("/internal/config", mw.ConfigAuth, configHandler)
+	}
+
+	api := router.Group("/api/v1", chain(apiversion.Version(apiversion.V1), mw.Chaos)...)
+	api.GET("/payments", handler.ListPayments)
+	api.POST("/payments/authorize", handler.Authorize)
+	api.GET("/payments/:payment_id", handler.GetPayment)
+	api.POST("/payments/:payment_id/capture", handler.Capture)
+	api.POST("/payments/:payment_id/refund", handler.Refund)
+
+	if debugEndpoints {
+		admin := router.Group("/admin")
+		admin.GET("/failures", failuresHandler.GetFailures)
+		admin.PUT("/failures", failuresHandler.SetFailures)
+		admin.GET("/chaos", chaosHandler.Get)
+		admin.PUT("/chaos", chaosHandler.Set)
+	}
+}
+
+// chain drops the middleware that is not configured.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
+	}
+	return chained
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"observability-system/shared/outboxinbox"
+	"payment-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const paymentColumns = `id, payment_id, order_id, COALESCE(customer_id, '') AS customer_id, amount, currency, status,
+	COALESCE(decline_reason, '') AS decline_reason, COALESCE(refund_reason, '') AS refund_reason, created_at, updated_at`
+
+// ErrInvalidTransition is returned when a payment's status does not allow
+// the operation, e.g. capturing a declined or refunded payment.
+var ErrInvalidTransition = errors.New("payment status does not allow this operation")
+
+// statusEvents maps a payment status to the event announcing it.
+var statusEvents = map[string]string{
+	models.PaymentStatusAuthorized: models.EventPaymentAuthorized,
+	models.PaymentStatusDeclined:   models.EventPaymentDeclined,
+	models.PaymentStatusCaptured:   models.EventPaymentCaptured,
+	models.PaymentStatusRefunded:   models.EventPaymentRefunded,
+}
+
+// PaymentFilter narrows List; zero fields match every payment.
+type PaymentFilter struct {
+	OrderID string
+	Status  string
+	Limit   int
+	Offset  int
+}
+
+type PaymentService struct {
+	db     *sqlx.DB
+	outbox outboxinbox.OutboxStore
+}
+
+func NewPaymentService(db *sqlx.DB, outbox outboxinbox.OutboxStore) *PaymentService {
+	return &PaymentService{
+		db:     db,
+		outbox: outbox,
+	}
+}
+
+// Create stores a new payment, authorized or declined, and emits its event
+// in the same transaction. An order has at most one payment: when it has
+// one already, nothing is written and that payment is returned with created
+// false, so a retried authorization never charges twice.
+func (s *PaymentService) Create(ctx context.Context, payment *models.Payment) (stored *models.Payment, created bool, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !created {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO payments (payment_id, order_id, customer_id, amount, currency, status, decline_reason)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''))
+		ON CONFLICT (order_id) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, payment.ID, payment.OrderID, payment.CustomerID, payment.Amount, payment.Currency, payment.Status, payment.DeclineReason).
+		Scan(&payment.Seq, &payment.CreatedAt, &payment.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := s.GetByOrder(ctx, payment.OrderID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to load payment of order %s: %w", payment.OrderID, err)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to insert payment: %w", err)
+	}
+
+	if err = s.saveEvent(ctx, tx, payment, payment.DeclineReason); err != nil {
+		return nil, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return payment, true, nil
+}
+
+// Capture settles an authorized payment. Capturing a captured payment
+// changes nothing and returns changed false; other statuses fail with
+// ErrInvalidTransition.
+func (s *PaymentService) Capture(ctx context.Context, paymentID string) (*models.Payment, bool, error) {
+	return s.transition(ctx, paymentID, models.PaymentStatusCaptured, "", models.PaymentStatusAuthorized)
+}
+
+// Refund returns an authorized or captured payment to the customer for
+// reason. Refunding a refunded payment changes nothing and returns changed
+// false; declined payments fail with ErrInvalidTransition.
+func (s *PaymentService) Refund(ctx context.Context, paymentID, reason string) (*models.Payment, bool, error) {
+	return s.transition(ctx, paymentID, models.PaymentStatusRefunded, reason, models.PaymentStatusAuthorized, models.PaymentStatusCaptured)
+}
+
+// transition moves a payment in one of the from statuses to status and
+// emits its event in the same transaction.
+func (s *PaymentService) transition(ctx context.Context, paymentID, status, reason string, from ...string) (payment *models.Payment, changed bool, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !changed {
+			tx.Rollback()
+		}
+	}()
+
+	var current models.Payment
+	err = tx.GetContext(ctx, &current, `SELECT `+paymentColumns+` FROM payments WHERE payment_id = $1 FOR UPDATE`, paymentID)
+	if err != nil {
+		return nil, false, err
+	}
+	if current.Status == status {
+		return &current, false, nil
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || current.Status == f
+	}
+	if !allowed {
+		return &current, false, fmt.Errorf("%w: payment %s is %s", ErrInvalidTransition, paymentID, current.Status)
+	}
+
+	current.Status = status
+	if status == models.PaymentStatusRefunded {
+		current.RefundReason = reason
+	}
+	err = tx.QueryRowxContext(ctx, `
+		UPDATE payments SET status = $1, refund_reason = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`, current.Status, current.RefundReason, current.Seq).Scan(&current.UpdatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	if err = s.saveEvent(ctx, tx, &current, reason); err != nil {
+		return nil, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &current, true, nil
+}
+
+// saveEvent writes the event announcing payment's status, partitioned by
+// order so an order's payment events are published in order.
+func (s *PaymentService) saveEvent(ctx context.Context, tx *sqlx.Tx, payment *models.Payment, reason string) error {
+	eventType := statusEvents[payment.Status]
+	opts := outboxinbox.SaveOptions{PartitionKey: payment.OrderID}
+	if payment.Status == models.PaymentStatusRefunded {
+		// Refunds compensate failed orders.
+		opts.Priority = outboxinbox.PriorityHigh
+	}
+	if _, err := s.outbox.SaveTx(ctx, tx, eventType, models.NewPaymentEvent(payment, reason), opts); err != nil {
+		return fmt.Errorf("failed to save %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Get returns the payment with the given payment ID, or sql.ErrNoRows if it
+// does not exist.
+func (s *PaymentService) Get(ctx context.Context, paymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := s.db.GetContext(ctx, &payment, `SELECT `+paymentColumns+` FROM payments WHERE payment_id = $1`, paymentID); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByOrder returns the payment of the given order, or sql.ErrNoRows if
+// it has none.
+func (s *PaymentService) GetByOrder(ctx context.Context, orderID string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := s.db.GetContext(ctx, &payment, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1`, orderID); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// List returns a page of payments matching filter, newest first.
+func (s *PaymentService) List(ctx context.Context, filter PaymentFilter) ([]models.Payment, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	if filter.OrderID != "" {
+		args = append(args, filter.OrderID)
+		conditions = append(conditions, fmt.Sprintf("order_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT `+paymentColumns+` FROM payments WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	payments := []models.Payment{}
+	if err := s.db.SelectContext(ctx, &payments, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+	return payments, nil
+}
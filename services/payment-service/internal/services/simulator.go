@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/tracing"
+	"payment-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Payment operations failures can be simulated in.
+const (
+	OpAuthorize = "authorize"
+	OpCapture   = "capture"
+	OpRefund    = "refund"
+)
+
+// ErrProviderUnavailable is returned for simulated provider errors. The
+// payment was not changed, so the call can be retried.
+var ErrProviderUnavailable = errors.New("payment provider unavailable")
+
+// FailureConfig describes the simulated behaviour of the payment provider.
+// Latency is drawn uniformly between the minimum and maximum and added to
+// every operation; errors and timeouts are then injected with the given
+// probabilities. Authorizations above MaxAmount, unless it is zero, are
+// declined, and the others with probability DeclineRate.
+type FailureConfig struct {
+	MinLatencyMs int     `json:"min_latency_ms"`
+	MaxLatencyMs int     `json:"max_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	TimeoutRate  float64 `json:"timeout_rate"`
+	// TimeoutMs is how long a simulated timeout hangs before failing.
+	TimeoutMs   int     `json:"timeout_ms"`
+	DeclineRate float64 `json:"decline_rate"`
+	MaxAmount   float64 `json:"max_amount"`
+}
+
+// Validate rejects configurations whose latency range is inverted or whose
+// rates are not probabilities.
+func (cfg FailureConfig) Validate() error {
+	if cfg.MinLatencyMs < 0 || cfg.MaxLatencyMs < cfg.MinLatencyMs {
+		return errors.New("latency range must satisfy 0 <= min <= max")
+	}
+	for name, rate := range map[string]float64{"error_rate": cfg.ErrorRate, "timeout_rate": cfg.TimeoutRate, "decline_rate": cfg.DeclineRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if cfg.TimeoutMs < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if cfg.MaxAmount < 0 {
+		return errors.New("max_amount must not be negative")
+	}
+	return nil
+}
+
+// Simulator makes the payment provider behind the service slow, unreliable
+// and picky, so the order saga's retries and compensations can be observed.
+// It is safe for concurrent use and can be reconfigured at runtime.
+type Simulator struct {
+	logger logger.Logger
+
+	mu   sync.Mutex
+	cfg  FailureConfig
+	rand *rand.Rand
+}
+
+func NewSimulator(log logger.Logger, cfg FailureConfig) (*Simulator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Simulator{
+		logger: log,
+		cfg:    cfg,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Config returns the current configuration.
+func (s *Simulator) Config() FailureConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// SetConfig replaces the configuration.
+func (s *Simulator) SetConfig(cfg FailureConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	return nil
+}
+
+// Call simulates the provider's side of op: its latency, then an error or
+// a timeout. A non-nil error means the provider did not handle the call.
+func (s *Simulator) Call(ctx context.Context, op string) error {
+	s.mu.Lock()
+	cfg := s.cfg
+	latency := time.Duration(cfg.MinLatencyMs) * time.Millisecond
+	if spread := cfg.MaxLatencyMs - cfg.MinLatencyMs; spread > 0 {
+		latency += time.Duration(s.rand.Intn(spread+1)) * time.Millisecond
+	}
+	hang := s.rand.Float64() < cfg.TimeoutRate
+	fail := !hang && s.rand.Float64() < cfg.ErrorRate
+	s.mu.Unlock()
+
+	if latency > 0 {
+		if err := sleep(ctx, latency); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case hang:
+		timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+		s.record(ctx, op, "timeout", logger.Duration("timeout", timeout))
+		if err := sleep(ctx, timeout); err != nil {
+			return err
+		}
+		return fmt.Errorf("payment provider did not answer within %s: %w", timeout, context.DeadlineExceeded)
+	case fail:
+		s.record(ctx, op, "error")
+		return ErrProviderUnavailable
+	}
+	return nil
+}
+
+// Decline decides whether the provider declines authorizing amount and
+// returns the reason, or "" when it is authorized.
+func (s *Simulator) Decline(ctx context.Context, amount float64) string {
+	s.mu.Lock()
+	cfg := s.cfg
+	declined := s.rand.Float64() < cfg.DeclineRate
+	s.mu.Unlock()
+
+	switch {
+	case cfg.MaxAmount > 0 && amount > cfg.MaxAmount:
+		s.record(ctx, OpAuthorize, "decline", logger.String("reason", "amount_limit_exceeded"))
+		return "amount_limit_exceeded"
+	case declined:
+		s.record(ctx, OpAuthorize, "decline", logger.String("reason", "insufficient_funds"))
+		return "insufficient_funds"
+	}
+	return ""
+}
+
+func (s *Simulator) record(ctx context.Context, op, fault string, fields ...logger.Field) {
+	metrics.RecordSimulatedFailure(op, fault)
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("payment.simulated_failure", true),
+		attribute.String("payment.fault", fault),
+	)
+	s.logger.WarnCtx(ctx, "Simulating payment provider failure",
+		append([]logger.Field{
+			logger.String("operation", op),
+			logger.String("fault", fault),
+		}, fields...)...)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
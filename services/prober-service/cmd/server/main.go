@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/tracing"
+	"prober-service/internal/config"
+	"prober-service/internal/handlers"
+	"prober-service/internal/metrics"
+	"prober-service/internal/probe"
+	"prober-service/internal/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	cfg := config.Load(os.Args[1:])
+	_, err := cfg.ResolveSecrets(context.Background())
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		// Reported before the logger exists, which needs a valid config.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logLevel := logger.DefaultLevel(cfg.Environment)
+	if cfg.LogLevel != "" {
+		// Validate rejected unknown levels.
+		logLevel, _ = logger.ParseLevel(cfg.LogLevel)
+	}
+	log, err := logger.NewZapLogger(logger.Config{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Level:       logLevel,
+		Encoding:    cfg.LogEncoding,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
+	}
+	defer log.Sync()
+
+	log.Info("Starting prober service",
+		logger.String("port", cfg.Port),
+		logger.String("environment", cfg.Environment),
+		logger.String("order_service_url", cfg.OrderServiceURL),
+		logger.String("warehouse_service_url", cfg.WarehouseServiceURL),
+		logger.String("jaeger_endpoint", cfg.JaegerEndpoint))
+
+	settings := sharedconfig.Redact(cfg)
+	log.Info("Effective configuration", logger.Any("config", settings))
+
+	tracingCfg := tracing.Config{
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: "1.0.0",
+		Environment:    cfg.Environment,
+		JaegerEndpoint: cfg.JaegerEndpoint,
+		SampleRatio:    cfg.TraceSampleRatio,
+	}
+
+	if err := tracing.InitTracer(tracingCfg); err != nil {
+		log.Fatal("Failed to initialize tracer",
+			logger.Err(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracing.ShutdownTracer(ctx); err != nil {
+			log.Error("Error shutting down tracer", logger.Err(err))
+		}
+	}()
+
+	log.Info("Tracer initialized successfully")
+
+	sharedmetrics.EnableExemplars(cfg.MetricsExemplars)
+	metricsRegistry := metrics.InitMetrics(sharedmetrics.Config{
+		Service:  cfg.ServiceName,
+		MaxPaths: cfg.MetricsMaxPaths,
+	})
+	log.Info("Metrics initialized successfully")
+
+	canary := probe.NewCanary(log, probe.Config{
+		OrderServiceURL:     strings.TrimSuffix(cfg.OrderServiceURL, "/"),
+		WarehouseServiceURL: strings.TrimSuffix(cfg.WarehouseServiceURL, "/"),
+		AuthToken:           cfg.ProbeAuthToken,
+		ProductID:           cfg.ProbeProductID,
+		Quantity:            cfg.ProbeQuantity,
+		UnitPrice:           cfg.ProbeUnitPrice,
+		Timeout:             cfg.ProbeTimeout,
+		PollInterval:        cfg.ProbePollInterval,
+	})
+	runner := probe.NewRunner(log, canary, cfg.ProbeInterval, cfg.ProbeHistory)
+
+	// The probed services are reported by /health only: the prober stays
+	// live and ready while they are down, so it can keep reporting it.
+	prober := health.NewProber(cfg.ServiceName, log, cfg.HealthCheckTimeout)
+	prober.AddLiveness("canary_runs", runner)
+	prober.AddDiagnostic("order-service", health.HTTP(nil, strings.TrimSuffix(cfg.OrderServiceURL, "/")+"/live"))
+	prober.AddDiagnostic("warehouse-service", health.HTTP(nil, strings.TrimSuffix(cfg.WarehouseServiceURL, "/")+"/live"))
+
+	var mw routes.Middleware
+	metricsNetworks, err := sharedmetrics.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatal("Invalid METRICS_ALLOWED_NETWORKS", logger.Err(err))
+	}
+	mw.MetricsAuth = sharedmetrics.AuthMiddleware(sharedmetrics.AuthConfig{
+		BearerToken:     cfg.MetricsAuthToken,
+		Username:        cfg.MetricsAuthUsername,
+		Password:        cfg.MetricsAuthPassword,
+		AllowedNetworks: metricsNetworks,
+	}, log)
+	if mw.MetricsAuth != nil {
+		log.Info("Metrics and probe endpoints protected",
+			logger.Bool("bearer_token", cfg.MetricsAuthToken != ""),
+			logger.Bool("basic_auth", cfg.MetricsAuthUsername != ""),
+			logger.String("allowed_networks", cfg.MetricsAllowedNetworks))
+	}
+	// /internal/config shares the /metrics credentials, but is left out
+	// rather than served openly when there are none.
+	mw.ConfigAuth = mw.MetricsAuth
+	if mw.ConfigAuth == nil {
+		log.Info("GET /internal/config disabled, set METRICS_AUTH_TOKEN, METRICS_AUTH_USERNAME or METRICS_ALLOWED_NETWORKS to enable it")
+	}
+
+	gin.SetMode(cfg.GinMode)
+	router := gin.New()
+
+	routes.SetupRoutes(router, log, cfg.ServiceName, handlers.NewProbeHandler(runner), sharedconfig.Handler(cfg.ServiceName, settings), prober, metricsRegistry, mw)
+
+	log.Info("Routes configured")
+
+	runnerCtx, stopRunner := context.WithCancel(context.Background())
+	runnerDone := make(chan struct{})
+	go func() {
+		defer close(runnerDone)
+		runner.Start(runnerCtx)
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	log.Info("Server starting",
+		logger.String("address", addr))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server",
+				logger.Err(err))
+		}
+	}()
+
+	<-sigChan
+	log.Info("Shutdown signal received, initiating graceful shutdown",
+		logger.Duration("timeout", cfg.ShutdownTimeout))
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP server did not drain in time, closing remaining connections", logger.Err(err))
+		server.Close()
+	} else {
+		log.Info("HTTP server stopped")
+	}
+
+	// A scheduled run in progress finishes, its order cleaned up, unless
+	// the shutdown timeout runs out first.
+	stopRunner()
+	select {
+	case <-runnerDone:
+	case <-shutdownCtx.Done():
+		log.Warn("Canary run did not finish before the shutdown timeout")
+	}
+	cancelShutdown()
+
+	log.Info("Service shutdown complete")
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"time"
+
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/secrets"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Port        string
+	Environment string
+	// LogLevel overrides the level logged at, debug in development and info
+	// elsewhere.
+	LogLevel string
+	// GinMode, LogEncoding and TraceSampleRatio default to the profile of
+	// Environment, see sharedconfig.Profile.
+	GinMode          string
+	LogEncoding      string
+	TraceSampleRatio float64
+	ServiceName      string
+	JaegerEndpoint   string
+
+	// OrderServiceURL and WarehouseServiceURL are where the canary
+	// transaction is sent. Pointing both at the API gateway probes the path
+	// clients take, with ProbeAuthToken as the bearer token when the gateway
+	// requires one.
+	OrderServiceURL     string
+	WarehouseServiceURL string
+	ProbeAuthToken      string
+
+	// ProbeInterval is how often the canary transaction runs in the
+	// background, and ProbeTimeout how long one may take before it fails;
+	// cleaning up after a failure gets another ProbeTimeout.
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+	// ProbePollInterval is how often an order accepted with 202 is checked
+	// until it is confirmed.
+	ProbePollInterval time.Duration
+	// ProbeProductID is the product the canary orders. It should be kept for
+	// probing, so real stock and reports are left alone.
+	ProbeProductID string
+	ProbeQuantity  int
+	ProbeUnitPrice float64
+	// ProbeHistory is how many results GET /api/v1/results keeps.
+	ProbeHistory int
+
+	// MetricsExemplars attaches trace IDs to the latency histograms and
+	// serves /metrics as OpenMetrics so Prometheus can store them.
+	MetricsExemplars bool
+	// MetricsMaxPaths caps the distinct route labels of the HTTP metrics;
+	// zero leaves them uncapped.
+	MetricsMaxPaths int
+	// MetricsAuthToken and MetricsAuthUsername/Password require a bearer
+	// token or basic auth to scrape /metrics and /probe;
+	// MetricsAllowedNetworks, a comma-separated list of IPs and CIDR ranges,
+	// limits who may connect. Empty leaves them open.
+	MetricsAuthToken       string
+	MetricsAuthUsername    string
+	MetricsAuthPassword    string
+	MetricsAllowedNetworks string
+
+	// ShutdownTimeout bounds how long in-flight requests, on-demand probes
+	// included, may drain on shutdown before their connections are closed.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout bounds how long a client may take to send the
+	// request headers.
+	ReadHeaderTimeout time.Duration
+
+	// HealthCheckTimeout bounds each check of /health.
+	HealthCheckTimeout time.Duration
+
+	// SecretsCacheTTL is how long secrets behind secret references are
+	// reused before they are fetched again. VaultAddr and VaultToken enable
+	// vault:// references; AWSRegion enables awssm:// ones, fetched from
+	// AWSSecretsManagerEndpoint when set.
+	SecretsCacheTTL           time.Duration
+	VaultAddr                 string
+	VaultToken                string
+	AWSRegion                 string
+	AWSSecretsManagerEndpoint string
+
+	// secretRefs keeps the settings ResolveSecrets resolved, as
+	// configured.
+	secretRefs secrets.Settings
+	// durations keeps the duration settings Load could not parse, for
+	// Validate to report.
+	durations *sharedconfig.DurationReader
+}
+
+// Load reads the configuration with args, the command-line arguments, as
+// the flags. Flags take precedence over environment variables, which take
+// precedence over the config files and then the defaults.
+func Load(args []string) *Config {
+	configFile := sharedconfig.BindFlags(args, false)
+
+	viper.AutomaticEnv()
+
+	viper.SetDefault("PORT", "8004")
+	viper.SetDefault("SERVICE_NAME", "prober-service")
+	viper.SetDefault("ENVIRONMENT", "development")
+	viper.SetDefault("JAEGER_ENDPOINT", "localhost:4318")
+
+	viper.SetDefault("ORDER_SERVICE_URL", "http://localhost:8001")
+	viper.SetDefault("WAREHOUSE_SERVICE_URL", "http://localhost:8002")
+
+	viper.SetDefault("PROBE_INTERVAL", "30s")
+	viper.SetDefault("PROBE_TIMEOUT", "20s")
+	viper.SetDefault("PROBE_POLL_INTERVAL", "250ms")
+	viper.SetDefault("PROBE_PRODUCT_ID", "PROD-CANARY")
+	viper.SetDefault("PROBE_QUANTITY", 1)
+	viper.SetDefault("PROBE_UNIT_PRICE", 1.00)
+	viper.SetDefault("PROBE_HISTORY", 100)
+
+	viper.SetDefault("METRICS_EXEMPLARS", false)
+	viper.SetDefault("METRICS_MAX_PATHS", 200)
+
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("READ_HEADER_TIMEOUT", "10s")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("SECRETS_CACHE_TTL", "5m")
+
+	sharedconfig.ReadConfigFiles(configFile, ".", "./services/prober-service", "../../")
+
+	// The settings that differ between environments default to its profile.
+	profile := sharedconfig.Environment(viper.GetString("ENVIRONMENT")).Profile()
+	viper.SetDefault("GIN_MODE", profile.GinMode)
+	viper.SetDefault("LOG_ENCODING", profile.LogEncoding)
+	viper.SetDefault("TRACE_SAMPLE_RATIO", profile.TraceSampleRatio)
+
+	durations := sharedconfig.NewDurationReader(viper.GetString)
+	cfg := &Config{
+		Port:             viper.GetString("PORT"),
+		Environment:      viper.GetString("ENVIRONMENT"),
+		LogLevel:         viper.GetString("LOG_LEVEL"),
+		GinMode:          viper.GetString("GIN_MODE"),
+		LogEncoding:      viper.GetString("LOG_ENCODING"),
+		TraceSampleRatio: viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		ServiceName:      viper.GetString("SERVICE_NAME"),
+		JaegerEndpoint:   viper.GetString("JAEGER_ENDPOINT"),
+
+		OrderServiceURL:     viper.GetString("ORDER_SERVICE_URL"),
+		WarehouseServiceURL: viper.GetString("WAREHOUSE_SERVICE_URL"),
+		ProbeAuthToken:      viper.GetString("PROBE_AUTH_TOKEN"),
+
+		ProbeInterval:     durations.Get("PROBE_INTERVAL"),
+		ProbeTimeout:      durations.Get("PROBE_TIMEOUT"),
+		ProbePollInterval: durations.Get("PROBE_POLL_INTERVAL"),
+		ProbeProductID:    viper.GetString("PROBE_PRODUCT_ID"),
+		ProbeQuantity:     viper.GetInt("PROBE_QUANTITY"),
+		ProbeUnitPrice:    viper.GetFloat64("PROBE_UNIT_PRICE"),
+		ProbeHistory:      viper.GetInt("PROBE_HISTORY"),
+
+		MetricsExemplars:       viper.GetBool("METRICS_EXEMPLARS"),
+		MetricsMaxPaths:        viper.GetInt("METRICS_MAX_PATHS"),
+		MetricsAuthToken:       viper.GetString("METRICS_AUTH_TOKEN"),
+		MetricsAuthUsername:    viper.GetString("METRICS_AUTH_USERNAME"),
+		MetricsAuthPassword:    viper.GetString("METRICS_AUTH_PASSWORD"),
+		MetricsAllowedNetworks: viper.GetString("METRICS_ALLOWED_NETWORKS"),
+
+		ShutdownTimeout:   durations.Get("SHUTDOWN_TIMEOUT"),
+		ReadHeaderTimeout: durations.Get("READ_HEADER_TIMEOUT"),
+
+		HealthCheckTimeout: durations.Get("HEALTH_CHECK_TIMEOUT"),
+
+		SecretsCacheTTL:           durations.Get("SECRETS_CACHE_TTL"),
+		VaultAddr:                 viper.GetString("VAULT_ADDR"),
+		VaultToken:                viper.GetString("VAULT_TOKEN"),
+		AWSRegion:                 viper.GetString("AWS_REGION"),
+		AWSSecretsManagerEndpoint: viper.GetString("AWS_ENDPOINT_URL_SECRETS_MANAGER"),
+	}
+	cfg.durations = durations
+	return cfg
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/logger"
+)
+
+// Validate checks that the settings the service needs are present, that
+// URLs parse and that numbers are in range. It returns a
+// *sharedconfig.ValidationError listing every problem, or nil.
+func (c *Config) Validate() error {
+	v := sharedconfig.NewValidator(c.durations)
+
+	v.Port("PORT", c.Port)
+	v.Required("SERVICE_NAME", c.ServiceName)
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			v.Addf("LOG_LEVEL: %v", err)
+		}
+	}
+	if _, err := sharedconfig.ParseEnvironment(c.Environment); err != nil {
+		v.Addf("ENVIRONMENT: %v", err)
+	}
+	v.OneOf("GIN_MODE", c.GinMode, "debug", "release", "test")
+	v.OneOf("LOG_ENCODING", c.LogEncoding, "json", "console")
+	v.Fraction("TRACE_SAMPLE_RATIO", c.TraceSampleRatio)
+
+	v.URL("ORDER_SERVICE_URL", c.OrderServiceURL, "http", "https")
+	v.URL("WAREHOUSE_SERVICE_URL", c.WarehouseServiceURL, "http", "https")
+
+	v.Positive("PROBE_INTERVAL", c.ProbeInterval)
+	v.Positive("PROBE_TIMEOUT", c.ProbeTimeout)
+	v.Positive("PROBE_POLL_INTERVAL", c.ProbePollInterval)
+	v.Required("PROBE_PRODUCT_ID", c.ProbeProductID)
+	v.AtLeast("PROBE_QUANTITY", c.ProbeQuantity, 1)
+	if c.ProbeUnitPrice < 0 {
+		v.Addf("PROBE_UNIT_PRICE: must not be negative, got %g", c.ProbeUnitPrice)
+	}
+	v.AtLeast("PROBE_HISTORY", c.ProbeHistory, 1)
+
+	v.AtLeast("METRICS_MAX_PATHS", c.MetricsMaxPaths, 0)
+	if c.MetricsAuthUsername != "" && c.MetricsAuthPassword == "" {
+		v.Addf("METRICS_AUTH_PASSWORD: required with METRICS_AUTH_USERNAME")
+	}
+
+	v.NonNegative("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.Positive("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	v.Positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+
+	return v.Err()
+}
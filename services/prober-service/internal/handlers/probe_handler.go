@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"observability-system/shared/problem"
+	"prober-service/internal/probe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultResultsLimit is how many results GET /api/v1/results returns
+// without a limit; at most PROBE_HISTORY are kept.
+const defaultResultsLimit = 20
+
+// ProbeHandler serves the canary results.
+type ProbeHandler struct {
+	runner *probe.Runner
+}
+
+func NewProbeHandler(runner *probe.Runner) *ProbeHandler {
+	return &ProbeHandler{runner: runner}
+}
+
+// Results returns the latest scheduled results, newest first, limited by
+// the limit query parameter.
+func (h *ProbeHandler) Results(c *gin.Context) {
+	limit := defaultResultsLimit
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return
+		}
+		limit = v
+	}
+
+	results := h.runner.Results(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"check":   probe.CheckCanaryOrder,
+		"count":   len(results),
+		"results": results,
+	})
+}
+
+// Probe runs the canary once and answers in the Prometheus exposition
+// format, like the blackbox exporter: Prometheus scrapes it with a scrape
+// timeout above twice PROBE_TIMEOUT, and the probe_* series describe this
+// run alone. A failed transaction is still a successful scrape; only
+// probe_success tells them apart.
+func (h *ProbeHandler) Probe(c *gin.Context) {
+	res := h.runner.RunOnce(c.Request.Context())
+
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the canary transaction succeeded (1) or failed (0)",
+	})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Time the canary transaction took",
+	})
+	stepSuccess := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_step_success",
+		Help: "Whether each step of the canary transaction succeeded (1) or failed (0)",
+	}, []string{"step"})
+	stepDuration := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_step_duration_seconds",
+		Help: "Time each step of the canary transaction took",
+	}, []string{"step"})
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(success, duration, stepSuccess, stepDuration)
+
+	success.Set(boolValue(res.Success))
+	duration.Set(res.Duration().Seconds())
+	for _, step := range res.Steps {
+		stepSuccess.WithLabelValues(step.Step).Set(boolValue(step.Success))
+		stepDuration.WithLabelValues(step.Step).Set(step.Duration().Seconds())
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
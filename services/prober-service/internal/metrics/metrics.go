@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/secrets"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The prober's own metrics, registered by InitMetrics.
+var (
+	probeRunsTotal         *prometheus.CounterVec
+	probeDuration          prometheus.ObserverVec
+	probeStepDuration      prometheus.ObserverVec
+	probeStepFailuresTotal *prometheus.CounterVec
+	probeSuccess           *prometheus.GaugeVec
+	probeLastSuccess       *prometheus.GaugeVec
+)
+
+// probeBuckets cover a canary transaction, from a quick synchronous order to
+// one waiting out an asynchronous confirmation.
+var probeBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30}
+
+// InitMetrics builds the prober's registry: the shared HTTP metrics of its
+// own endpoints, the results of the scheduled canary transactions, and the
+// metrics of Collectors.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...)
+
+	probeRunsTotal = registry.Counter("prober_runs_total",
+		"Total number of scheduled canary transactions by check and result (success or failure)",
+		"check", "result")
+	probeDuration = registry.Histogram("prober_run_duration_seconds",
+		"Time the scheduled canary transactions took, by check and result", probeBuckets,
+		"check", "result")
+	probeStepDuration = registry.Histogram("prober_step_duration_seconds",
+		"Time each step of the scheduled canary transactions took, by check and step", probeBuckets,
+		"check", "step")
+	probeStepFailuresTotal = registry.Counter("prober_step_failures_total",
+		"Total number of failed canary transaction steps by check and step",
+		"check", "step")
+	probeSuccess = registry.Gauge("prober_success",
+		"Whether the last scheduled canary transaction of the check succeeded (1) or failed (0)",
+		"check")
+	probeLastSuccess = registry.Gauge("prober_last_success_timestamp_seconds",
+		"Unix time the check last succeeded",
+		"check")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the prober uses.
+func Collectors() []prometheus.Collector {
+	return secrets.Collectors()
+}
+
+// RecordRun records the outcome and duration of a scheduled canary
+// transaction.
+func RecordRun(ctx context.Context, check string, success bool, duration time.Duration) {
+	result, value := "failure", 0.0
+	if success {
+		result, value = "success", 1.0
+		probeLastSuccess.WithLabelValues(check).SetToCurrentTime()
+	}
+	probeRunsTotal.WithLabelValues(check, result).Inc()
+	sharedmetrics.Observe(ctx, probeDuration.WithLabelValues(check, result), duration.Seconds())
+	probeSuccess.WithLabelValues(check).Set(value)
+}
+
+// RecordStep records how long a step of a scheduled canary transaction took
+// and counts it when it failed.
+func RecordStep(ctx context.Context, check, step string, success bool, duration time.Duration) {
+	sharedmetrics.Observe(ctx, probeStepDuration.WithLabelValues(check, step), duration.Seconds())
+	if !success {
+		probeStepFailuresTotal.WithLabelValues(check, step).Inc()
+	}
+}
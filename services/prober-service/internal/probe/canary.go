@@ -0,0 +1,364 @@
+// Package probe runs canary transactions through the order flow the way a
+// client would, and reports whether and how fast each step succeeded.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CheckCanaryOrder is the check run by Canary.
+const CheckCanaryOrder = "canary_order"
+
+// Steps of the canary transaction, in order.
+const (
+	StepCreateOrder       = "create_order"
+	StepConfirmOrder      = "confirm_order"
+	StepVerifyReservation = "verify_reservation"
+	StepCancelOrder       = "cancel_order"
+)
+
+// orderStatusConfirmed is the status the canary waits for, and
+// failedOrderStatuses the ones it gives up on.
+const orderStatusConfirmed = "confirmed"
+
+var failedOrderStatuses = []string{"payment_failed", "expired", "rejected"}
+
+// customerID marks the canary's orders in the order listings and logs.
+const customerID = "synthetic-prober"
+
+// Config configures a Canary.
+type Config struct {
+	OrderServiceURL     string
+	WarehouseServiceURL string
+	// AuthToken is sent as the bearer token of every request; empty sends
+	// none.
+	AuthToken string
+	ProductID string
+	Quantity  int
+	UnitPrice float64
+	// Timeout bounds a transaction; cleaning up after a failure gets
+	// another Timeout.
+	Timeout time.Duration
+	// PollInterval is how often an order accepted with 202 is checked until
+	// it is confirmed.
+	PollInterval time.Duration
+}
+
+// StepResult is the outcome of one step.
+type StepResult struct {
+	Step       string  `json:"step"`
+	Success    bool    `json:"success"`
+	DurationMs float64 `json:"duration_ms"`
+	// Status is the HTTP status of the step's last response.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	duration time.Duration
+}
+
+// Duration returns how long the step took.
+func (s StepResult) Duration() time.Duration { return s.duration }
+
+// Result is the outcome of a canary transaction.
+type Result struct {
+	Check      string       `json:"check"`
+	Success    bool         `json:"success"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMs float64      `json:"duration_ms"`
+	OrderID    string       `json:"order_id,omitempty"`
+	TraceID    string       `json:"trace_id,omitempty"`
+	FailedStep string       `json:"failed_step,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Steps      []StepResult `json:"steps"`
+
+	duration time.Duration
+}
+
+// Duration returns how long the transaction took.
+func (r Result) Duration() time.Duration { return r.duration }
+
+// Canary runs the canary transaction: it orders the probe product, waits
+// for the order to be confirmed, checks that warehouse-service holds its
+// stock, then releases the stock and deletes the order again. The cleanup
+// also runs after a failed step, so failed transactions do not keep stock
+// reserved.
+type Canary struct {
+	cfg    Config
+	logger logger.Logger
+	client *tracing.TracedHTTPClient
+	tracer trace.Tracer
+}
+
+func NewCanary(log logger.Logger, cfg Config) *Canary {
+	return &Canary{
+		cfg:    cfg,
+		logger: log,
+		client: tracing.NewTracedHTTPClient(cfg.Timeout),
+		tracer: tracing.GetTracer("probe"),
+	}
+}
+
+// Run runs one transaction in a trace of its own.
+func (c *Canary) Run(ctx context.Context) Result {
+	ctx, span := c.tracer.Start(ctx, "probe "+CheckCanaryOrder,
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("probe.check", CheckCanaryOrder),
+			attribute.String("product.id", c.cfg.ProductID),
+		))
+	defer span.End()
+
+	res := Result{Check: CheckCanaryOrder, StartedAt: time.Now()}
+	if sc := span.SpanContext(); sc.IsValid() {
+		res.TraceID = sc.TraceID().String()
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var order orderState
+	steps := []struct {
+		name string
+		run  func(ctx context.Context) (int, error)
+	}{
+		{StepCreateOrder, func(ctx context.Context) (int, error) { return c.createOrder(ctx, &order) }},
+		{StepConfirmOrder, func(ctx context.Context) (int, error) { return c.confirmOrder(ctx, &order) }},
+		{StepVerifyReservation, func(ctx context.Context) (int, error) { return c.verifyReservation(ctx, order.ID) }},
+	}
+	for _, step := range steps {
+		if !c.step(runCtx, &res, step.name, step.run) {
+			break
+		}
+	}
+	res.OrderID = order.ID
+
+	if order.ID != "" {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cfg.Timeout)
+		c.step(cleanupCtx, &res, StepCancelOrder, func(ctx context.Context) (int, error) {
+			return c.cancelOrder(ctx, order.ID)
+		})
+		cancel()
+	}
+
+	res.duration = time.Since(res.StartedAt)
+	res.DurationMs = float64(res.duration) / float64(time.Millisecond)
+	res.Success = res.FailedStep == ""
+	span.SetAttributes(
+		attribute.String("order.id", res.OrderID),
+		attribute.Bool("probe.success", res.Success),
+	)
+	if !res.Success {
+		span.SetAttributes(attribute.String("probe.failed_step", res.FailedStep))
+		span.SetStatus(codes.Error, res.Error)
+		c.logger.WarnCtx(ctx, "Canary transaction failed",
+			logger.String("failed_step", res.FailedStep),
+			logger.String("error", res.Error),
+			logger.String("order_id", res.OrderID),
+			logger.Duration("duration", res.duration))
+	} else {
+		c.logger.DebugCtx(ctx, "Canary transaction succeeded",
+			logger.String("order_id", res.OrderID),
+			logger.Duration("duration", res.duration))
+	}
+	return res
+}
+
+// step runs one step in a span of its own and appends its result. The
+// first failed step fails the transaction.
+func (c *Canary) step(ctx context.Context, res *Result, name string, run func(ctx context.Context) (int, error)) bool {
+	ctx, span := c.tracer.Start(ctx, "probe."+name)
+	defer span.End()
+
+	start := time.Now()
+	status, err := run(ctx)
+	step := StepResult{Step: name, Success: err == nil, Status: status, duration: time.Since(start)}
+	step.DurationMs = float64(step.duration) / float64(time.Millisecond)
+	if status != 0 {
+		span.SetAttributes(attribute.Int("http.status_code", status))
+	}
+	if err != nil {
+		step.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if res.FailedStep == "" {
+			res.FailedStep, res.Error = name, err.Error()
+		}
+	}
+	res.Steps = append(res.Steps, step)
+	return err == nil
+}
+
+// orderState is what the transaction learned about its order.
+type orderState struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func (c *Canary) createOrder(ctx context.Context, order *orderState) (int, error) {
+	var resp struct {
+		Order orderState `json:"order"`
+	}
+	status, err := c.do(ctx, http.MethodPost, c.cfg.OrderServiceURL+"/api/v1/orders", map[string]interface{}{
+		"product_id":  c.cfg.ProductID,
+		"quantity":    c.cfg.Quantity,
+		"customer_id": customerID,
+		"unit_price":  c.cfg.UnitPrice,
+	}, &resp, http.StatusCreated, http.StatusAccepted)
+	if err != nil {
+		return status, err
+	}
+	if resp.Order.ID == "" {
+		return status, errors.New("the response names no order")
+	}
+	*order = resp.Order
+	return status, nil
+}
+
+// confirmOrder waits until the order is confirmed. Orders created with 201
+// usually are already; ones accepted with 202 are confirmed asynchronously.
+func (c *Canary) confirmOrder(ctx context.Context, order *orderState) (int, error) {
+	var status int
+	for {
+		if order.Status == orderStatusConfirmed {
+			return status, nil
+		}
+		for _, failed := range failedOrderStatuses {
+			if order.Status == failed {
+				return status, fmt.Errorf("order %s became %s", order.ID, order.Status)
+			}
+		}
+
+		timer := time.NewTimer(c.cfg.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, fmt.Errorf("order %s still %s: %w", order.ID, order.Status, ctx.Err())
+		case <-timer.C:
+		}
+
+		var err error
+		status, err = c.do(ctx, http.MethodGet, c.cfg.OrderServiceURL+"/api/v1/orders/"+url.PathEscape(order.ID), nil, order, http.StatusOK)
+		if err != nil {
+			return status, err
+		}
+	}
+}
+
+// verifyReservation checks that warehouse-service holds the ordered stock
+// for the order.
+func (c *Canary) verifyReservation(ctx context.Context, orderID string) (int, error) {
+	var resp struct {
+		Held         int `json:"held"`
+		Reservations []struct {
+			ProductID string `json:"product_id"`
+		} `json:"reservations"`
+	}
+	status, err := c.do(ctx, http.MethodGet, c.cfg.WarehouseServiceURL+"/api/v1/orders/"+url.PathEscape(orderID)+"/reservation", nil, &resp, http.StatusOK)
+	if err != nil {
+		return status, err
+	}
+	if resp.Held != c.cfg.Quantity {
+		return status, fmt.Errorf("warehouse holds %d units for the order, want %d", resp.Held, c.cfg.Quantity)
+	}
+	for _, r := range resp.Reservations {
+		if r.ProductID != c.cfg.ProductID {
+			return status, fmt.Errorf("the order reserved %s, want %s", r.ProductID, c.cfg.ProductID)
+		}
+	}
+	return status, nil
+}
+
+// cancelOrder returns the order's stock and deletes the order, so the probe
+// product's stock stays level and the canary's orders are hidden.
+func (c *Canary) cancelOrder(ctx context.Context, orderID string) (int, error) {
+	status, err := c.do(ctx, http.MethodPost, c.cfg.WarehouseServiceURL+"/api/v1/inventory/release", map[string]interface{}{
+		"product_id": c.cfg.ProductID,
+		"quantity":   c.cfg.Quantity,
+		"order_id":   orderID,
+	}, nil, http.StatusOK)
+	if err != nil {
+		return status, fmt.Errorf("release stock: %w", err)
+	}
+	status, err = c.do(ctx, http.MethodDelete, c.cfg.OrderServiceURL+"/api/v1/orders/"+url.PathEscape(orderID), nil, nil, http.StatusNoContent)
+	if err != nil {
+		return status, fmt.Errorf("delete order: %w", err)
+	}
+	return status, nil
+}
+
+// do sends a request with body encoded as JSON, and decodes the response
+// into out unless it is nil. A status other than want fails, with the
+// problem's code and detail when the response is one.
+func (c *Canary) do(ctx context.Context, method, target string, body, out interface{}, want ...int) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.AuthToken)
+	}
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("read response: %w", err)
+	}
+
+	expected := false
+	for _, status := range want {
+		expected = expected || resp.StatusCode == status
+	}
+	if !expected {
+		return resp.StatusCode, unexpectedStatus(method, req.URL.Path, resp.StatusCode, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func unexpectedStatus(method, path string, status int, body []byte) error {
+	msg := fmt.Sprintf("%s %s answered %d", method, path, status)
+	var p problem.Problem
+	if json.Unmarshal(body, &p) == nil && p.Code != "" {
+		msg += ": " + string(p.Code)
+		if p.Detail != "" {
+			msg += ": " + strings.TrimSpace(p.Detail)
+		}
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,102 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+	"prober-service/internal/metrics"
+)
+
+// Runner runs the canary on a schedule, records each result in the
+// prober's metrics and keeps the latest results.
+type Runner struct {
+	logger   logger.Logger
+	canary   *Canary
+	interval time.Duration
+
+	mu      sync.Mutex
+	history []Result
+	size    int
+	lastRun time.Time
+}
+
+func NewRunner(log logger.Logger, canary *Canary, interval time.Duration, history int) *Runner {
+	return &Runner{
+		logger:   log,
+		canary:   canary,
+		interval: interval,
+		size:     history,
+	}
+}
+
+// Start runs the canary at once and then every interval until ctx is
+// cancelled. A transaction still running then is finished first.
+func (r *Runner) Start(ctx context.Context) {
+	r.logger.Info("Starting canary runs",
+		logger.String("check", CheckCanaryOrder),
+		logger.Duration("interval", r.interval))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.runScheduled(context.WithoutCancel(ctx))
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Canary runs stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) runScheduled(ctx context.Context) {
+	res := r.canary.Run(ctx)
+	metrics.RecordRun(ctx, res.Check, res.Success, res.Duration())
+	for _, step := range res.Steps {
+		metrics.RecordStep(ctx, res.Check, step.Step, step.Success, step.Duration())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastRun = time.Now()
+	r.history = append([]Result{res}, r.history...)
+	if len(r.history) > r.size {
+		r.history = r.history[:r.size]
+	}
+}
+
+// RunOnce runs the canary outside the schedule, e.g. for a blackbox-style
+// probe. Its result is neither recorded in the metrics nor kept.
+func (r *Runner) RunOnce(ctx context.Context) Result {
+	return r.canary.Run(ctx)
+}
+
+// Results returns up to limit of the latest scheduled results, newest first.
+func (r *Runner) Results(limit int) []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit <= 0 || limit > len(r.history) {
+		limit = len(r.history)
+	}
+	return append([]Result(nil), r.history[:limit]...)
+}
+
+// Check fails when no scheduled run has finished for longer than a run can
+// take, so a stuck schedule fails the liveness probe. It passes until the
+// first run finishes.
+func (r *Runner) Check(ctx context.Context) error {
+	r.mu.Lock()
+	lastRun := r.lastRun
+	r.mu.Unlock()
+	if lastRun.IsZero() {
+		return nil
+	}
+	// A run takes at most two timeouts: the transaction and its cleanup.
+	if since := time.Since(lastRun); since > r.interval+2*r.canary.cfg.Timeout {
+		return fmt.Errorf("no canary run has finished for %s", since.Round(time.Second))
+	}
+	return nil
+}
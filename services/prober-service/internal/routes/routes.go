@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"prober-service/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware holds the optional middleware configured in main; nil fields
+// are skipped.
+type Middleware struct {
+	// MetricsAuth guards /metrics and /probe; nil serves them openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+}
+
+// SetupRoutes serves the prober's health and metrics, the blackbox-style
+// /probe running the canary on demand, and the latest scheduled results.
+// /health also reports whether the probed services answer, while /live and
+// /ready concern the prober alone, so it keeps probing during an outage.
+func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, probeHandler *handlers.ProbeHandler, configHandler gin.HandlerFunc, prober *health.Prober, registry *metrics.Registry, mw Middleware) {
+
+	router.Use(tracing.GinMiddleware(serviceName))
+
+	router.Use(logger.InjectLogger(log))
+	router.Use(logger.GinMiddleware(log))
+	router.Use(problem.Recovery())
+
+	router.Use(registry.Middleware())
+
+	router.NoRoute(problem.NoRoute)
+
+	router.GET("/health", prober.Health)
+	router.GET("/live", prober.Live)
+	router.GET("/ready", prober.Ready)
+	router.GET("/metrics", chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	router.GET("/probe", chain(mw.MetricsAuth, probeHandler.Probe)...)
+	if mw.ConfigAuth != nil {
+		router.GET("/internal/config", mw.ConfigAuth, configHandler)
+	}
+
+	v1 := router.Group("/api/v1")
+	v1.GET("/results", probeHandler.Results)
+}
+
+// chain drops the middleware that is not configured.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
+	}
+	return chained
+}
@@ -2,31 +2,62 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"observability-system/shared/apiversion"
+	"observability-system/shared/cache"
+	"observability-system/shared/chaos"
+	sharedconfig "observability-system/shared/config"
+	"observability-system/shared/health"
+	"observability-system/shared/httplimit"
+	"observability-system/shared/lock"
 	"observability-system/shared/logger"
-	"observability-system/shared/messaging"
 	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/ratelimit"
 	"observability-system/shared/tracing"
+	"warehouse-service/internal/clients"
 	"warehouse-service/internal/config"
 	"warehouse-service/internal/database"
 	"warehouse-service/internal/handlers"
-	"warehouse-service/internal/inbox"
 	"warehouse-service/internal/metrics"
 	"warehouse-service/internal/routes"
+	"warehouse-service/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg := config.Load(os.Args[1:])
+	secretResolver, err := cfg.ResolveSecrets(context.Background())
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		// Reported before the logger exists, which needs a valid config.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	log, err := logger.NewDefaultLogger(cfg.ServiceName, cfg.Environment)
+	logLevel := logger.DefaultLevel(cfg.Environment)
+	if cfg.LogLevel != "" {
+		// Validate rejected unknown levels.
+		logLevel, _ = logger.ParseLevel(cfg.LogLevel)
+	}
+	log, err := logger.NewZapLogger(logger.Config{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Level:       logLevel,
+		Encoding:    cfg.LogEncoding,
+	})
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
@@ -37,29 +68,17 @@ func main() {
 		logger.String("environment", cfg.Environment),
 		logger.String("jaeger_endpoint", cfg.JaegerEndpoint))
 
+	settings := sharedconfig.Redact(cfg)
+	log.Info("Effective configuration", logger.Any("config", settings))
+
 	tracingCfg := tracing.Config{
 		ServiceName:    cfg.ServiceName,
 		ServiceVersion: "1.0.0",
 		Environment:    cfg.Environment,
 		JaegerEndpoint: cfg.JaegerEndpoint,
+		SampleRatio:    cfg.TraceSampleRatio,
 	}
 
-	db, err := database.NewConnection(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatal("Failed to connect to database",
-			logger.Err(err))
-	}
-	defer db.Close()
-
-	log.Info("Connected to database successfully")
-
-	if err := database.InitSchema(db); err != nil {
-		log.Fatal("Failed to initialize database schema",
-			logger.Err(err))
-	}
-
-	log.Info("Database schema initialized")
-
 	if err := tracing.InitTracer(tracingCfg); err != nil {
 		log.Fatal("Failed to initialize tracer",
 			logger.Err(err))
@@ -74,12 +93,83 @@ func main() {
 
 	log.Info("Tracer initialized successfully")
 
-	metrics.InitMetrics(cfg.ServiceName)
+	sharedmetrics.EnableExemplars(cfg.MetricsExemplars)
+	metricsBuckets, err := sharedmetrics.ParseBuckets(cfg.MetricsBuckets)
+	if err != nil {
+		log.Fatal("Invalid METRICS_BUCKETS", logger.Err(err))
+	}
+	nativeHistograms, err := sharedmetrics.ParseNativeHistograms(cfg.MetricsNativeHistograms)
+	if err != nil {
+		log.Fatal("Invalid METRICS_NATIVE_HISTOGRAMS", logger.Err(err))
+	}
+	metricsSummaries, err := sharedmetrics.ParseSummaries(cfg.MetricsSummaries)
+	if err != nil {
+		log.Fatal("Invalid METRICS_SUMMARIES", logger.Err(err))
+	}
+	metricsRegistry := metrics.InitMetrics(sharedmetrics.Config{
+		Service:          cfg.ServiceName,
+		Buckets:          metricsBuckets,
+		MaxPaths:         cfg.MetricsMaxPaths,
+		NativeHistograms: nativeHistograms,
+		Summaries:        metricsSummaries,
+	})
 	log.Info("Metrics initialized successfully")
 
+	var metricsExporter *sharedmetrics.OTLPExporter
+	switch cfg.MetricsBackend {
+	case sharedmetrics.BackendPrometheus:
+	case sharedmetrics.BackendOTLP:
+		otlpCfg := sharedmetrics.DefaultOTLPConfig()
+		otlpCfg.Endpoint = cfg.OTLPMetricsEndpoint
+		otlpCfg.ServiceVersion = tracingCfg.ServiceVersion
+		otlpCfg.Environment = cfg.Environment
+		otlpCfg.Interval = cfg.MetricsExportInterval
+		metricsExporter, err = metricsRegistry.NewOTLPExporter(otlpCfg, log)
+		if err != nil {
+			log.Fatal("Failed to create OTLP metrics exporter", logger.Err(err))
+		}
+		go metricsExporter.Start(context.Background())
+	default:
+		log.Fatal("Invalid METRICS_BACKEND", logger.String("backend", cfg.MetricsBackend))
+	}
+
+	// Without the debug endpoints chaos can only be configured at startup,
+	// so with it disabled there is nothing to inject.
+	var chaosInjector *chaos.Injector
+	if cfg.ChaosEnabled || cfg.DebugEndpoints {
+		chaosInjector, err = chaos.New(log, chaos.Uniform(cfg.ChaosEnabled, cfg.ChaosTargets, chaos.Rule{
+			MinLatencyMs: int(cfg.ChaosMinLatency.Milliseconds()),
+			MaxLatencyMs: int(cfg.ChaosMaxLatency.Milliseconds()),
+			ErrorRate:    cfg.ChaosErrorRate,
+			TimeoutRate:  cfg.ChaosTimeoutRate,
+			TimeoutMs:    int(cfg.ChaosTimeout.Milliseconds()),
+			DropRate:     cfg.ChaosDropRate,
+		}))
+		if err != nil {
+			log.Fatal("Invalid chaos configuration", logger.Err(err))
+		}
+		if cfg.ChaosEnabled {
+			log.Warn("Chaos mode enabled", logger.Any("rules", chaosInjector.Config().Rules))
+		}
+	}
+
+	db, err := database.NewConnection(secretResolver.Func(cfg.Secret("DATABASE_URL")), cfg.DBConnMaxLifetime, chaosInjector)
+	if err != nil {
+		log.Fatal("Failed to connect to database",
+			logger.Err(err))
+	}
+	defer db.Close()
+
+	log.Info("Connected to database successfully")
+
+	if err := database.InitSchema(db); err != nil {
+		log.Fatal("Failed to initialize database schema",
+			logger.Err(err))
+	}
+
 	var rabbitMQClient *rabbitmq.Client
 	if cfg.EnableBroker {
-		rabbitMQClient, err = rabbitmq.NewClient(cfg.RabbitMQURL)
+		rabbitMQClient, err = rabbitmq.NewClientWithURL(secretResolver.Func(cfg.Secret("RABBITMQ_URL")))
 		if err != nil {
 			log.Fatal("Failed to connect to RabbitMQ", logger.Err(err))
 		}
@@ -92,37 +182,328 @@ func main() {
 		log.Info("RabbitMQ exchanges and queues configured")
 	}
 
-	if cfg.Environment == "production" {
-		gin.SetMode(gin.ReleaseMode)
-	}
+	gin.SetMode(cfg.GinMode)
 	router := gin.New()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inboxCfg := outboxinbox.DefaultInboxConfig()
+	inboxCfg.DefaultExchange = "inventory"
+	outboxCfg := outboxinbox.DefaultOutboxConfig()
+	outboxCfg.DefaultExchange = "inventory"
+
+	inboxCfg.LockTimeout = cfg.LockTimeout
+	outboxCfg.LockTimeout = cfg.LockTimeout
+
+	if cfg.RetentionArchive {
+		inboxCfg.ArchiveTableName = "inbox_archive"
+		outboxCfg.ArchiveTableName = "outbox_archive"
+	}
+
+	if !cfg.ListenNotify {
+		inboxCfg.NotifyChannel = ""
+		outboxCfg.NotifyChannel = ""
+	}
+
+	inboxStore := outboxinbox.NewInboxStore(db, inboxCfg)
+	outboxStore := outboxinbox.NewOutboxStore(db, outboxCfg)
+
+	// InitSchema also migrates rows left by the old lowercase-status stores.
+	if err := inboxStore.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize inbox schema", logger.Err(err))
+	}
+	if err := outboxStore.InitSchema(ctx); err != nil {
+		log.Fatal("Failed to initialize outbox schema", logger.Err(err))
+	}
+
+	log.Info("Database schema initialized")
+
+	inventoryHandler := handlers.NewInventoryHandler(log)
+	inventoryHandler.SetOutbox(outboxStore)
+	inventoryHandler.SetMovementStore(services.NewMovementService(db))
+	inventoryHandler.SetReservationStore(services.NewReservationService(db, cfg.ReplicaID))
+	inventoryHandler.SetReservationPolicy(cfg.ReservationTTL, cfg.ReservationRetention)
+	if cfg.ProductCacheTTL > 0 {
+		// No Redis tier: the inventory lives in each instance's memory.
+		inventoryHandler.SetProductCache(cache.New(cache.Config{
+			Name: "warehouse_products",
+			TTL:  cfg.ProductCacheTTL,
+		}))
+	}
+
+	var orderSource handlers.OrderSource
+	if cfg.OrderServiceURL != "" {
+		orderSource = clients.NewOrderClient(cfg.OrderServiceURL, cfg.OrderClientTimeout, log)
+	}
+	inventoryHandler.SetReconciliation(orderSource, cfg.InventoryReconcileGrace, cfg.InventoryReconcileAutoCorrect)
+
+	if cfg.InventoryFixture != handlers.DefaultFixture {
+		fixture, err := handlers.Fixture(cfg.InventoryFixture)
+		if err != nil {
+			log.Fatal("Invalid INVENTORY_FIXTURE", logger.Err(err))
+		}
+		result, err := inventoryHandler.ImportSnapshot(ctx, fixture, handlers.ActorStartup, handlers.ImportOptions{})
+		if err != nil {
+			log.Fatal("Failed to import inventory fixture", logger.Err(err))
+		}
+		log.Info("Inventory fixture imported",
+			logger.String("fixture", cfg.InventoryFixture),
+			logger.Int("products", result.Products))
+	}
+	if cfg.InventorySnapshotFile != "" {
+		snapshot, err := handlers.LoadSnapshotFile(cfg.InventorySnapshotFile)
+		if err != nil {
+			log.Fatal("Invalid INVENTORY_SNAPSHOT_FILE", logger.Err(err))
+		}
+		result, err := inventoryHandler.ImportSnapshot(ctx, snapshot, handlers.ActorStartup, handlers.ImportOptions{Mode: cfg.InventorySnapshotMode})
+		if err != nil {
+			log.Fatal("Failed to import inventory snapshot", logger.Err(err))
+		}
+		log.Info("Inventory snapshot imported",
+			logger.String("file", cfg.InventorySnapshotFile),
+			logger.String("mode", result.Mode),
+			logger.Int("products", result.Products),
+			logger.Int("added", len(result.Added)),
+			logger.Int("updated", len(result.Updated)),
+			logger.Int("removed", len(result.Removed)))
+	}
+
+	// Reservations are loaded onto the imported inventory, so a restart
+	// keeps the stock they hold.
+	loaded, err := inventoryHandler.LoadReservations(ctx)
+	if err != nil {
+		log.Fatal("Failed to load reservations", logger.Err(err))
+	}
+	log.Info("Reservations loaded",
+		logger.String("replica", cfg.ReplicaID),
+		logger.Int("reservations", loaded))
+
+	lowStockThresholds, err := handlers.ParseLowStockThresholds(cfg.LowStockThresholds)
+	if err != nil {
+		log.Fatal("Invalid LOW_STOCK_THRESHOLDS", logger.Err(err))
+	}
+	if err := inventoryHandler.SetLowStockThresholds(lowStockThresholds); err != nil {
+		log.Fatal("Invalid LOW_STOCK_THRESHOLDS", logger.Err(err))
+	}
+	if err := inventoryHandler.SetAllocationStrategy(cfg.AllocationStrategy); err != nil {
+		log.Fatal("Invalid ALLOCATION_STRATEGY", logger.Err(err))
+	}
+	inventoryHandler.RecordStockLevels()
+
+	registry := handlers.NewMessageHandlerRegistry(log, cfg.MaxRetries)
+	registry.Register("warehouse.test", func(ctx context.Context, msg outboxinbox.InboxMessage) error {
+		log.InfoCtx(ctx, "Received warehouse test message",
+			logger.String("message_id", msg.MessageID),
+			logger.String("payload", string(msg.Payload)))
+		return nil
+	})
+	registry.Register(handlers.EventOrderCreated, inventoryHandler.HandleOrderCreated)
+
+	retryBackoff := outboxinbox.BackoffPolicy{
+		Base:       cfg.RetryBackoffBase,
+		Multiplier: cfg.RetryBackoffMultiplier,
+		Max:        cfg.RetryBackoffMax,
+		Jitter:     cfg.RetryBackoffJitter,
+	}
+
+	var inboxNotifier, outboxNotifier *outboxinbox.Notifier
+	if cfg.ListenNotify {
+		inboxNotifier = outboxinbox.NewNotifier(cfg.DatabaseURL, inboxCfg.NotifyChannel, log)
+		if cfg.EnableBroker {
+			outboxNotifier = outboxinbox.NewNotifier(cfg.DatabaseURL, outboxCfg.NotifyChannel, log)
+		}
+	}
+
+	inboxPool := outboxinbox.NewWorkerPool("inbox", log, cfg.InboxWorkers, func() outboxinbox.Worker {
+		worker := outboxinbox.NewInboxWorker(inboxStore, registry.GetHandler(), log, cfg.BatchSize, cfg.PollInterval, cfg.MaxRetries, retryBackoff)
+		worker.SetMaxIdleInterval(cfg.MaxIdlePollInterval)
+		if inboxNotifier != nil {
+			worker.SetWakeup(inboxNotifier.Subscribe())
+		}
+		return worker
+	})
+	inboxPool.Start(ctx)
+
+	var outboxPool *outboxinbox.WorkerPool
+	var outboxElector *lock.Elector
 	if cfg.EnableBroker {
+		// warehouse.order.created is the warehouse's own queue for
+		// order.created, bound next to order-service's.
+		bridge := outboxinbox.NewInboxBridge(rabbitMQClient, inboxStore, log, "rabbitmq")
+		if err := bridge.Start("warehouse.test", "warehouse.order.created"); err != nil {
+			log.Fatal("Failed to start inbox bridge", logger.Err(err))
+		}
 
-		inboxStore := inbox.NewInboxStore(db)
+		outboxPool = outboxinbox.NewWorkerPool("outbox", log, cfg.OutboxWorkers, func() outboxinbox.Worker {
+			worker := outboxinbox.NewOutboxWorker(outboxStore, rabbitMQClient, log, cfg.BatchSize, cfg.PollInterval, cfg.OutboxMaxRetries, retryBackoff)
+			worker.SetMaxIdleInterval(cfg.MaxIdlePollInterval)
+			if outboxNotifier != nil {
+				worker.SetWakeup(outboxNotifier.Subscribe())
+			}
+			return worker
+		})
+		if cfg.OutboxLeaderElection {
+			electionLocker := lock.New(db.DB, log)
+			electionLocker.SetRenewInterval(cfg.JobLockRenewInterval)
+			outboxElector = lock.NewElector(electionLocker, outboxinbox.DispatcherElection, log, cfg.OutboxLeaderRetryInterval)
+			go outboxElector.Start(ctx, outboxPool.Run)
+		} else {
+			outboxPool.Start(ctx)
+		}
+	} else {
+		// Without a broker, outbox rows stay PENDING until a broker-enabled
+		// instance picks them up.
+		log.Warn("Broker disabled, outbox workers not started")
+	}
+
+	for _, notifier := range []*outboxinbox.Notifier{inboxNotifier, outboxNotifier} {
+		if notifier == nil {
+			continue
+		}
+		go func(n *outboxinbox.Notifier) {
+			if err := n.Start(ctx); err != nil {
+				log.Error("Message notifier stopped, falling back to polling", logger.Err(err))
+			}
+		}(notifier)
+	}
+
+	// Only the reaper and janitor are guarded: the reservation expirer and
+	// stock reconciler work on this replica's in-memory inventory, so every
+	// replica has to run its own.
+	var locker *lock.Locker
+	if cfg.JobLocks {
+		locker = lock.New(db.DB, log)
+		locker.SetRenewInterval(cfg.JobLockRenewInterval)
+	}
+
+	statsCollector := outboxinbox.NewStatsCollector(log, cfg.StatsInterval, inboxStore, outboxStore)
+	go statsCollector.Start(ctx)
+
+	var reaper *outboxinbox.Reaper
+	if cfg.ReaperInterval > 0 {
+		reaper = outboxinbox.NewReaper(log, cfg.ReaperInterval, cfg.LockTimeout, inboxStore, outboxStore)
+		reaper.SetLocker(locker)
+		go reaper.Start(ctx)
+	}
+
+	var janitor *outboxinbox.Janitor
+	if cfg.RetentionPeriod > 0 {
+		janitor = outboxinbox.NewJanitor(log, cfg.RetentionInterval, cfg.RetentionPeriod, cfg.RetentionBatchSize, inboxStore, outboxStore)
+		janitor.SetLocker(locker)
+		go janitor.Start(ctx)
+	}
+
+	reservationExpirer := services.NewReservationExpirer(log, inventoryHandler, cfg.ReservationExpiryInterval)
+	go reservationExpirer.Start(ctx)
+
+	var stockReconciler *services.StockReconciler
+	if cfg.InventoryReconcileInterval > 0 {
+		stockReconciler = services.NewStockReconciler(log, inventoryHandler, cfg.InventoryReconcileInterval)
+		go stockReconciler.Start(ctx)
+		log.Info("Stock reconciliation configured",
+			logger.String("order_service_url", cfg.OrderServiceURL),
+			logger.String("grace", cfg.InventoryReconcileGrace.String()),
+			logger.Bool("auto_correct", cfg.InventoryReconcileAutoCorrect))
+	}
 
-		testHandler := func(msg messaging.Message) error {
-			bytes, _ := json.Marshal(msg.Payload)
-			payloadStr := string(bytes)
+	opsReporter := ops.NewReporter(cfg.ServiceName, log)
+	opsReporter.AddTable(inboxPool, inboxStore)
+	opsReporter.AddTable(outboxPool, outboxStore)
+	opsReporter.AddRecent("reservations", handlers.RecentReservations)
 
-			log.Info("Received warehouse test message",
-				logger.String("message_id", msg.ID),
-				logger.String("payload", payloadStr))
-			return nil
+	prober := health.NewProber(cfg.ServiceName, log, cfg.HealthCheckTimeout)
+	prober.SetCacheTTL(cfg.HealthCacheTTL)
+	if cfg.HealthMinFreeDisk > 0 {
+		prober.AddDiagnostic("disk", health.DiskSpace(cfg.HealthDiskPath, cfg.HealthMinFreeDisk))
+	}
+	if cfg.HealthMaxGoroutines > 0 {
+		prober.AddDiagnostic("goroutines", health.Goroutines(cfg.HealthMaxGoroutines))
+	}
+	prober.AddLiveness("inbox_workers", health.Workers(inboxPool, cfg.HealthWorkerStallAfter))
+	prober.AddReadiness("database", health.Database(db))
+	prober.AddReadiness("schema", health.Schema(db, append([]string{inboxCfg.TableName, outboxCfg.TableName}, database.Tables...)...))
+	prober.AddReadiness("inbox_workers", health.WorkersStarted(inboxPool))
+	if cfg.EnableBroker {
+		prober.AddLiveness("outbox_workers", health.Workers(outboxPool, cfg.HealthWorkerStallAfter))
+		prober.AddReadiness("rabbitmq", health.Broker(rabbitMQClient))
+		if outboxElector == nil {
+			// Standby replicas never start their outbox workers.
+			prober.AddReadiness("outbox_workers", health.WorkersStarted(outboxPool))
 		}
+	}
 
-		msgHandler := inbox.InboxHandler(inboxStore, testHandler)
+	timeoutRoutes, err := httplimit.ParseRouteTimeouts(cfg.RequestTimeoutRoutes)
+	if err != nil {
+		log.Fatal("Invalid REQUEST_TIMEOUT_ROUTES", logger.Err(err))
+	}
+	bodySizeRoutes, err := httplimit.ParseRouteSizes(cfg.MaxBodySizeRoutes)
+	if err != nil {
+		log.Fatal("Invalid MAX_BODY_SIZE_ROUTES", logger.Err(err))
+	}
 
-		err = rabbitMQClient.Subscribe("warehouse.test", msgHandler)
-		if err != nil {
-			log.Fatal("Failed to subscribe to warehouse.test", logger.Err(err))
+	mw := routes.Middleware{
+		Timeout:     httplimit.Timeout(cfg.RequestTimeout, timeoutRoutes),
+		MaxBodySize: httplimit.MaxBodySize(cfg.MaxBodySize, bodySizeRoutes),
+	}
+
+	metricsNetworks, err := sharedmetrics.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatal("Invalid METRICS_ALLOWED_NETWORKS", logger.Err(err))
+	}
+	mw.MetricsAuth = sharedmetrics.AuthMiddleware(sharedmetrics.AuthConfig{
+		BearerToken:     cfg.MetricsAuthToken,
+		Username:        cfg.MetricsAuthUsername,
+		Password:        cfg.MetricsAuthPassword,
+		AllowedNetworks: metricsNetworks,
+	}, log)
+	if mw.MetricsAuth != nil {
+		log.Info("Metrics endpoint protected",
+			logger.Bool("bearer_token", cfg.MetricsAuthToken != ""),
+			logger.Bool("basic_auth", cfg.MetricsAuthUsername != ""),
+			logger.String("allowed_networks", cfg.MetricsAllowedNetworks))
+	}
+	// /internal/config shares the /metrics credentials, but is left out
+	// rather than served openly when there are none.
+	mw.ConfigAuth = mw.MetricsAuth
+	if mw.ConfigAuth == nil {
+		log.Info("GET /internal/config disabled, set METRICS_AUTH_TOKEN, METRICS_AUTH_USERNAME or METRICS_ALLOWED_NETWORKS to enable it")
+	}
+
+	reservationLimit, err := ratelimit.ParseLimit(cfg.RateLimitReservations)
+	if err != nil {
+		log.Fatal("Invalid RATE_LIMIT_RESERVATIONS", logger.Err(err))
+	}
+	if reservationLimit.Enabled() {
+		mw.ReservationRateLimit = ratelimit.Middleware("reserve_stock", ratelimit.NewMemoryStore(), reservationLimit, log, cfg.RateLimitAPIKeyHeader)
+	}
+	if cfg.ReservationConcurrency > 0 {
+		mw.ReservationConcurrency = ratelimit.Concurrency("reserve_stock", cfg.ReservationConcurrency, cfg.ReservationQueueTimeout, log)
+	}
+
+	log.Info("Reservation limits configured",
+		logger.String("rate_limit", cfg.RateLimitReservations),
+		logger.Int("concurrency", cfg.ReservationConcurrency),
+		logger.String("queue_timeout", cfg.ReservationQueueTimeout.String()))
+	if cfg.UnversionedAPI {
+		var sunset time.Time
+		if cfg.UnversionedAPISunset != "" {
+			if sunset, err = time.Parse(time.RFC3339, cfg.UnversionedAPISunset); err != nil {
+				log.Fatal("Invalid UNVERSIONED_API_SUNSET", logger.Err(err))
+			}
 		}
-		log.Info("Subscribed to warehouse.test queue")
+		mw.Deprecated = apiversion.Deprecated(apiversion.Deprecation{
+			Prefix:    "/api",
+			Successor: "/api/v1",
+			Sunset:    sunset,
+		})
 	}
 
-	inventoryHandler := handlers.NewInventoryHandler(log)
+	if chaosInjector != nil {
+		mw.Chaos = chaosInjector.Middleware()
+	}
 
-	routes.SetupRoutes(router, log, cfg.ServiceName, inventoryHandler)
+	routes.SetupRoutes(router, log, cfg.ServiceName, inventoryHandler, chaos.NewHandler(log, chaosInjector), cfg.DebugEndpoints, sharedconfig.Handler(cfg.ServiceName, settings), opsReporter, prober, metricsRegistry, mw)
 
 	log.Info("Routes configured")
 
@@ -133,17 +514,68 @@ func main() {
 	log.Info("Server starting",
 		logger.String("address", addr))
 
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+
 	go func() {
-		if err := router.Run(addr); err != nil {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal("Failed to start server",
 				logger.Err(err))
 		}
 	}()
 
 	<-sigChan
-	log.Info("Shutdown signal received, initiating graceful shutdown")
+	log.Info("Shutdown signal received, initiating graceful shutdown",
+		logger.Duration("timeout", cfg.ShutdownTimeout))
+
+	// Stop accepting connections and let in-flight requests finish before the
+	// workers and broker they may depend on go away.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP server did not drain in time, closing remaining connections", logger.Err(err))
+		server.Close()
+	} else {
+		log.Info("HTTP server stopped")
+	}
+	cancelShutdown()
+
+	cancel()
+
+	inboxPool.Stop()
+	if outboxElector != nil {
+		outboxElector.Stop()
+	}
+	if outboxPool != nil {
+		outboxPool.Stop()
+	}
+
+	statsCollector.Stop()
 
-	time.Sleep(2 * time.Second)
+	if reaper != nil {
+		reaper.Stop()
+	}
+
+	reservationExpirer.Stop()
+
+	if stockReconciler != nil {
+		stockReconciler.Stop()
+	}
+
+	if janitor != nil {
+		janitor.Stop()
+		log.Info("Retention janitor stopped")
+	}
+
+	if metricsExporter != nil {
+		exportCtx, cancelExport := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := metricsExporter.Stop(exportCtx); err != nil {
+			log.Error("Error exporting final metrics", logger.Err(err))
+		}
+		cancelExport()
+	}
 
 	log.Info("Service shutdown complete")
 }
@@ -0,0 +1,110 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"observability-system/shared/httpclient"
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"warehouse-service/internal/models"
+)
+
+const (
+	// orderLookupBatch is how many orders are looked up per request, keeping
+	// the URL short.
+	orderLookupBatch = 100
+	// orderPageSize is order-service's largest page.
+	orderPageSize = 500
+)
+
+type orderPage struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor *int64         `json:"next_cursor"`
+}
+
+// OrderClient reads orders from order-service.
+type OrderClient struct {
+	client *httpclient.Client
+	logger logger.Logger
+}
+
+// NewOrderClient creates a client of the order-service at baseURL whose
+// calls give up after timeout per attempt.
+func NewOrderClient(baseURL string, timeout time.Duration, log logger.Logger) *OrderClient {
+	return &OrderClient{
+		client: httpclient.NewWithBaseURL(strings.TrimSuffix(baseURL, "/"), timeout),
+		logger: log,
+	}
+}
+
+// Orders returns the orders among ids that order-service knows. Deleted
+// orders are not returned.
+func (c *OrderClient) Orders(ctx context.Context, ids []string) ([]models.Order, error) {
+	var orders []models.Order
+	for start := 0; start < len(ids); start += orderLookupBatch {
+		end := min(start+orderLookupBatch, len(ids))
+		page, err := c.list(ctx, map[string]string{
+			"order_id": strings.Join(ids[start:end], ","),
+			"limit":    strconv.Itoa(orderPageSize),
+		})
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+	}
+	return orders, nil
+}
+
+// OrdersByStatus returns every order in status created before
+// createdBefore, reading as many pages as it takes.
+func (c *OrderClient) OrdersByStatus(ctx context.Context, status string, createdBefore time.Time) ([]models.Order, error) {
+	params := map[string]string{
+		"status":         status,
+		"created_before": createdBefore.UTC().Format(time.RFC3339),
+		"limit":          strconv.Itoa(orderPageSize),
+		"sort":           "asc",
+	}
+	var orders []models.Order
+	for {
+		page, err := c.list(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+		if page.NextCursor == nil {
+			return orders, nil
+		}
+		params["cursor"] = strconv.FormatInt(*page.NextCursor, 10)
+	}
+}
+
+func (c *OrderClient) list(ctx context.Context, params map[string]string) (*orderPage, error) {
+	var page orderPage
+	var failure problem.Problem
+	resp, err := c.client.R(ctx).
+		SetSpanName("HTTP GET /api/v1/orders").
+		SetQueryParams(params).
+		SetResult(&page).
+		SetError(&failure).
+		Get("/api/v1/orders")
+	if err != nil {
+		c.logger.ErrorCtx(ctx, "Failed to call order service",
+			logger.Err(err))
+		return nil, fmt.Errorf("order service call failed: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		c.logger.WarnCtx(ctx, "Order service listing failed",
+			logger.Int("status_code", resp.StatusCode()),
+			logger.String("detail", failure.Detail))
+		if failure.Detail != "" {
+			return nil, fmt.Errorf("order service error: status %d: %s", resp.StatusCode(), failure.Detail)
+		}
+		return nil, fmt.Errorf("order service error: status %d", resp.StatusCode())
+	}
+	return &page, nil
+}
@@ -1,86 +1,103 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
-)
+	"observability-system/shared/chaos"
 
-func NewConnection(url string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database connection: %w", err)
-	}
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
+// NewConnection creates a new database connection using sqlx. dsn is asked
+// for the database URL whenever a connection is opened, so the pool picks
+// up rotated credentials as connMaxLifetime recycles its connections.
+// injector injects chaos faults into its queries; nil injects none.
+func NewConnection(dsn func(context.Context) (string, error), connMaxLifetime time.Duration, injector *chaos.Injector) (*sqlx.DB, error) {
+	db := sqlx.NewDb(sql.OpenDB(chaos.Connector(dsnConnector{dsn: dsn}, injector)), "postgres")
 
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetConnMaxLifetime(connMaxLifetime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
 
 	return db, nil
 }
 
-func InitSchema(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS outbox (
-		id SERIAL PRIMARY KEY,
-		message_id VARCHAR(255) UNIQUE NOT NULL,
-		event_type VARCHAR(255) NOT NULL,
-		payload JSONB NOT NULL,
-		status VARCHAR(50) DEFAULT 'PENDING',
-		retry_count INT DEFAULT 0,
-		exchange VARCHAR(255) DEFAULT 'inventory',
-		routing_key VARCHAR(255),
-		error TEXT,
-		locked_at TIMESTAMP,
-		locked_by VARCHAR(255),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status);
-	CREATE INDEX IF NOT EXISTS idx_outbox_locked_at ON outbox(locked_at);
-	CREATE INDEX IF NOT EXISTS idx_outbox_message_id ON outbox(message_id);
+// Tables lists the tables InitSchema creates; readiness waits for them.
+var Tables = []string{"inventory_movements", "reservations"}
+
+// dsnConnector opens connections with the DSN dsn returns at that moment,
+// so connections opened after a credential rotation use the new secret.
+type dsnConnector struct {
+	dsn func(context.Context) (string, error)
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database URL: %w", err)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
 
-	-- Migration for existing tables (safe to run if columns exist)
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS message_id VARCHAR(255);
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS exchange VARCHAR(255) DEFAULT 'inventory';
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS routing_key VARCHAR(255);
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS error TEXT;
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP;
-	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS locked_by VARCHAR(255);
+func (dsnConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
 
-		CREATE TABLE IF NOT EXISTS inbox (
-		id SERIAL PRIMARY KEY,
-		sender_id VARCHAR(255) NOT NULL,
-		message_id VARCHAR(255) UNIQUE NOT NULL,
-		event_type VARCHAR(255) NOT NULL,
-		payload JSONB NOT NULL,
-		status VARCHAR(50) DEFAULT 'PENDING',
-		retry_count INT DEFAULT 0,
-		exchange VARCHAR(255) DEFAULT 'inventory',
-		routing_key VARCHAR(255),
-		error TEXT,
-		locked_at TIMESTAMP,
-		locked_by VARCHAR(255),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+// InitSchema creates the service's domain tables. The inbox and outbox tables
+// are owned by the shared outboxinbox stores.
+func InitSchema(db *sqlx.DB) error {
+	schema := `
+	-- Audit log of every change to a product's stock.
+	CREATE TABLE IF NOT EXISTS inventory_movements (
+		id BIGSERIAL PRIMARY KEY,
+		product_id VARCHAR(255) NOT NULL,
+		movement_type VARCHAR(20) NOT NULL,
+		quantity_delta INT NOT NULL DEFAULT 0,
+		reserved_delta INT NOT NULL DEFAULT 0,
+		quantity_after INT NOT NULL,
+		reserved_after INT NOT NULL,
+		location VARCHAR(255),
+		reservation_id VARCHAR(255),
+		order_id VARCHAR(255),
+		reason VARCHAR(255),
+		actor VARCHAR(255) NOT NULL,
+		request_id VARCHAR(255),
+		trace_id VARCHAR(32),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
-	CREATE INDEX IF NOT EXISTS idx_inbox_status ON inbox(status);
-	CREATE INDEX IF NOT EXISTS idx_inbox_message_id ON inbox(message_id);
-	CREATE INDEX IF NOT EXISTS idx_inbox_locked_at ON inbox(locked_at);
+	CREATE INDEX IF NOT EXISTS idx_inventory_movements_product_id ON inventory_movements(product_id, id);
+	CREATE INDEX IF NOT EXISTS idx_inventory_movements_order_id ON inventory_movements(order_id) WHERE order_id IS NOT NULL;
 
-	-- Migration for existing tables
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS sender_id VARCHAR(255) DEFAULT 'unknown';
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS exchange VARCHAR(255) DEFAULT 'inventory';
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS routing_key VARCHAR(255);
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS error TEXT;
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP;
-	ALTER TABLE inbox ADD COLUMN IF NOT EXISTS locked_by VARCHAR(255);
+	-- Reservations of each replica's in-memory inventory, loaded again when
+	-- the replica restarts.
+	CREATE TABLE IF NOT EXISTS reservations (
+		id VARCHAR(255) PRIMARY KEY,
+		replica VARCHAR(255) NOT NULL,
+		order_id VARCHAR(255),
+		product_id VARCHAR(255) NOT NULL,
+		quantity INT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		allocations JSONB NOT NULL DEFAULT '[]',
+		expires_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_reservations_replica ON reservations(replica, created_at);
+	CREATE INDEX IF NOT EXISTS idx_reservations_order_id ON reservations(order_id) WHERE order_id IS NOT NULL;
 	`
 
 	_, err := db.Exec(schema)
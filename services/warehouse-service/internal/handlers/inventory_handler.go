@@ -1,25 +1,31 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
 	"observability-system/shared/tracing"
+	"warehouse-service/internal/metrics"
+	"warehouse-service/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/attribute"
 )
 
+// inventoryMu guards which products exist and is only locked for writing by
+// imports and startup configuration. Each item's own lock guards its stock
+// and reservations, so requests for different products never wait for each
+// other. Item locks are taken while holding inventoryMu for reading, and
+// never two at a time.
 var (
 	inventoryMu sync.RWMutex
-	inventory   = map[string]*InventoryItem{
-		"PROD-001": {ProductID: "PROD-001", Name: "Laptop", Quantity: 100, Reserved: 0},
-		"PROD-002": {ProductID: "PROD-002", Name: "Monitor", Quantity: 50, Reserved: 0},
-		"PROD-003": {ProductID: "PROD-003", Name: "Keyboard", Quantity: 200, Reserved: 0},
-		"PROD-004": {ProductID: "PROD-004", Name: "Mouse", Quantity: 150, Reserved: 0},
-		"PROD-005": {ProductID: "PROD-005", Name: "Headphones", Quantity: 75, Reserved: 0},
-	}
+	// inventory is seeded from the default fixture and replaced by imports.
+	inventory map[string]*InventoryItem
 )
 
 type InventoryItem struct {
@@ -28,40 +34,211 @@ type InventoryItem struct {
 	Quantity  int    `json:"quantity"`
 	Reserved  int    `json:"reserved"`
 	Available int    `json:"available"`
+	// LowStockThreshold raises a low-stock alert once available stock drops
+	// to it; zero disables the alert.
+	LowStockThreshold int `json:"low_stock_threshold"`
+	// Locations holds the stock per location; Quantity and Reserved are
+	// their sums.
+	Locations map[string]*LocationStock `json:"-"`
+
+	mu                sync.RWMutex
+	reservations      map[string]*models.Reservation
+	orderReservations map[string][]*models.Reservation
+}
+
+// lockItem locks productID's item for a change, returning nil if it does not
+// exist. The returned function releases it.
+func lockItem(productID string) (*InventoryItem, func()) {
+	inventoryMu.RLock()
+	item, exists := inventory[productID]
+	if !exists {
+		inventoryMu.RUnlock()
+		return nil, func() {}
+	}
+	item.mu.Lock()
+	return item, func() {
+		invalidateProducts(productID)
+		item.mu.Unlock()
+		inventoryMu.RUnlock()
+	}
+}
+
+// readItem is lockItem for reading productID's item.
+func readItem(productID string) (*InventoryItem, func()) {
+	inventoryMu.RLock()
+	item, exists := inventory[productID]
+	if !exists {
+		inventoryMu.RUnlock()
+		return nil, func() {}
+	}
+	item.mu.RLock()
+	return item, func() {
+		item.mu.RUnlock()
+		inventoryMu.RUnlock()
+	}
+}
+
+// EventInventoryReserved announces a reservation made for an order.
+const EventInventoryReserved = "inventory.reserved"
+
+// InventoryReservedEvent is the payload of inventory.reserved.
+type InventoryReservedEvent struct {
+	ReservationID string    `json:"reservation_id"`
+	OrderID       string    `json:"order_id"`
+	ProductID     string    `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Quantity      int       `json:"quantity"`
+	NewAvailable  int       `json:"new_available"`
+	ReservedAt    time.Time `json:"reserved_at"`
+	// Allocations are the locations the stock is held at.
+	Allocations []models.Allocation `json:"allocations"`
+	// Unannounced marks reservations made without announce. They only report
+	// the stock change; order-service does not confirm orders by them.
+	Unannounced bool `json:"unannounced,omitempty"`
+}
+
+// EventInventoryUpdated announces a manual adjustment of a product's stock.
+const EventInventoryUpdated = "inventory.updated"
+
+// Reasons an adjustment can be made for.
+const (
+	AdjustmentRestock    = "restock"
+	AdjustmentDamage     = "damage"
+	AdjustmentCorrection = "correction"
+	AdjustmentAudit      = "audit"
+)
+
+// ReasonCommit is the reason of the inventory.updated events announcing
+// stock consumed by a shipped order.
+const ReasonCommit = "commit"
+
+var adjustmentReasons = map[string]bool{
+	AdjustmentRestock:    true,
+	AdjustmentDamage:     true,
+	AdjustmentCorrection: true,
+	AdjustmentAudit:      true,
+}
+
+// InventoryUpdatedEvent is the payload of inventory.updated. Location is
+// empty for commits of stock held at several locations.
+type InventoryUpdatedEvent struct {
+	ProductID        string    `json:"product_id"`
+	OrderID          string    `json:"order_id,omitempty"`
+	Location         string    `json:"location"`
+	Delta            int       `json:"delta"`
+	Reason           string    `json:"reason"`
+	Note             string    `json:"note,omitempty"`
+	PreviousQuantity int       `json:"previous_quantity"`
+	Quantity         int       `json:"quantity"`
+	Reserved         int       `json:"reserved"`
+	Available        int       `json:"available"`
+	AdjustedAt       time.Time `json:"adjusted_at"`
 }
 
 type InventoryHandler struct {
-	logger logger.Logger
+	logger               logger.Logger
+	outbox               outboxinbox.OutboxStore
+	reservationTTL       time.Duration
+	reservationRetention time.Duration
+	allocationStrategy   string
+	movements            MovementStore
+	reservationStore     ReservationStore
+	orders               OrderSource
+	reconcileGrace       time.Duration
+	reconcileAutoCorrect bool
 }
 
 func NewInventoryHandler(log logger.Logger) *InventoryHandler {
 	return &InventoryHandler{
-		logger: log,
+		logger:               log,
+		reservationTTL:       DefaultReservationTTL,
+		reservationRetention: DefaultReservationRetention,
+		allocationStrategy:   DefaultAllocationStrategy,
 	}
 }
 
-func (h *InventoryHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "OK",
-		"service": "warehouse-service",
-	})
+// SetOutbox announces every change to the stock through outbox:
+// reservations with inventory.reserved, releases and expiries with
+// inventory.released, and commits and adjustments with inventory.updated.
+func (h *InventoryHandler) SetOutbox(outbox outboxinbox.OutboxStore) {
+	h.outbox = outbox
 }
 
+// SetReservationPolicy sets how long new reservations hold stock and how
+// long settled ones remain listed.
+func (h *InventoryHandler) SetReservationPolicy(ttl, retention time.Duration) {
+	h.reservationTTL = ttl
+	h.reservationRetention = retention
+}
+
+// RecordStockLevels sets the stock gauges of every product. The handlers
+// keep them current from then on.
+func (h *InventoryHandler) RecordStockLevels() {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+
+	for _, item := range inventory {
+		item.mu.RLock()
+		recordStockLevels(item)
+		item.mu.RUnlock()
+	}
+}
+
+// recordStockLevels updates item's stock gauges after a change. The caller
+// holds item's lock.
+func recordStockLevels(item *InventoryItem) {
+	metrics.RecordStockLevels(item.ProductID, item.Quantity, item.Reserved)
+}
+
+// CheckStock returns a product's stock with its breakdown per location, or
+// only the stock at the location query parameter.
 func (h *InventoryHandler) CheckStock(c *gin.Context) {
 	ctx := c.Request.Context()
 	productID := c.Param("product_id")
+	location := c.Query("location")
 
 	tracing.AddSpanAttributes(ctx,
 		attribute.String("product.id", productID),
+		attribute.String("location", location),
 		attribute.String("operation", "check_stock"),
 	)
 
+	metrics.RecordInventoryCheck()
+
 	h.logger.InfoCtx(ctx, "Checking stock",
-		logger.String("product_id", productID))
+		logger.String("product_id", productID),
+		logger.String("location", location))
 
-	inventoryMu.RLock()
-	item, exists := inventory[productID]
-	inventoryMu.RUnlock()
+	if !lookupLocation(c, location) {
+		return
+	}
+
+	var response interface{}
+	var quantity, reserved int
+	exists := true
+	if location == "" {
+		view, err := productStock(ctx, productID)
+		if exists = err == nil; exists {
+			response = view
+			quantity, reserved = view.Quantity, view.Reserved
+		}
+	} else {
+		item, unlock := readItem(productID)
+		if exists = item != nil; exists {
+			quantity, reserved = stockAt(item, location)
+			response = gin.H{
+				"product_id": item.ProductID,
+				"name":       item.Name,
+				"quantity":   quantity,
+				"reserved":   reserved,
+				"available":  quantity - reserved,
+				"location":   location,
+
+				"low_stock_threshold": item.LowStockThreshold,
+			}
+		}
+		unlock()
+	}
 
 	if !exists {
 		h.logger.WarnCtx(ctx, "Product not found",
@@ -69,19 +246,17 @@ func (h *InventoryHandler) CheckStock(c *gin.Context) {
 
 		tracing.AddSpanAttributes(ctx, attribute.Bool("product.found", false))
 
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":      "Product not found",
-			"product_id": productID,
-		})
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+productID+" does not exist").
+			With("product_id", productID))
 		return
 	}
 
-	available := item.Quantity - item.Reserved
+	available := quantity - reserved
 
 	tracing.AddSpanAttributes(ctx,
 		attribute.Bool("product.found", true),
-		attribute.Int("stock.quantity", item.Quantity),
-		attribute.Int("stock.reserved", item.Reserved),
+		attribute.Int("stock.quantity", quantity),
+		attribute.Int("stock.reserved", reserved),
 		attribute.Int("stock.available", available),
 	)
 
@@ -89,128 +264,852 @@ func (h *InventoryHandler) CheckStock(c *gin.Context) {
 		logger.String("product_id", productID),
 		logger.Int("available", available))
 
-	c.JSON(http.StatusOK, gin.H{
-		"product_id": item.ProductID,
-		"name":       item.Name,
-		"quantity":   item.Quantity,
-		"reserved":   item.Reserved,
-		"available":  available,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
-func (h *InventoryHandler) ReserveStock(c *gin.Context) {
+// maxStockCheckBatch caps the products checked by one CheckStockBatch call.
+const maxStockCheckBatch = 100
+
+// StockCheckResult is the availability of one product in a batch check.
+// Requested and Sufficient are only meaningful when a quantity was given.
+type StockCheckResult struct {
+	ProductID  string `json:"product_id"`
+	Found      bool   `json:"found"`
+	Name       string `json:"name,omitempty"`
+	Quantity   int    `json:"quantity"`
+	Reserved   int    `json:"reserved"`
+	Available  int    `json:"available"`
+	Requested  int    `json:"requested,omitempty"`
+	Sufficient bool   `json:"sufficient"`
+
+	LowStockThreshold int `json:"low_stock_threshold"`
+}
+
+// CheckStockBatch reports the availability of several products in one call,
+// optionally at a single location. Unknown products are reported with found
+// set to false instead of failing the batch. all_available is set when every product exists and has its
+// requested quantity, or at least one unit when none was given.
+func (h *InventoryHandler) CheckStockBatch(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	var req struct {
-		ProductID string `json:"product_id" binding:"required"`
-		Quantity  int    `json:"quantity" binding:"required,gt=0"`
+		Items []struct {
+			ProductID string `json:"product_id" binding:"required"`
+			Quantity  int    `json:"quantity" binding:"gte=0"`
+		} `json:"items" binding:"required,min=1,dive"`
+		Location string `json:"location"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.ErrorCtx(ctx, "Invalid request body",
+			logger.Err(err))
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+	if len(req.Items) > maxStockCheckBatch {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			fmt.Sprintf("At most %d products can be checked at once", maxStockCheckBatch)).
+			With("count", len(req.Items)))
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.ProductID] {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Product "+item.ProductID+" is listed more than once").
+				With("product_id", item.ProductID))
+			return
+		}
+		seen[item.ProductID] = true
+	}
+	if !lookupLocation(c, req.Location) {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Int("stock_check.count", len(req.Items)),
+		attribute.String("location", req.Location),
+		attribute.String("operation", "check_stock_batch"),
+	)
+
+	metrics.RecordInventoryCheck()
+
+	results := make([]StockCheckResult, 0, len(req.Items))
+	allAvailable := true
+	notFound := 0
+
+	for _, requested := range req.Items {
+		result := StockCheckResult{ProductID: requested.ProductID, Requested: requested.Quantity}
+		if item, unlock := readItem(requested.ProductID); item != nil {
+			result.Found = true
+			result.Name = item.Name
+			result.Quantity, result.Reserved = stockAt(item, req.Location)
+			result.Available = result.Quantity - result.Reserved
+			result.LowStockThreshold = item.LowStockThreshold
+
+			needed := requested.Quantity
+			if needed == 0 {
+				needed = 1
+			}
+			result.Sufficient = result.Available >= needed
+			unlock()
+		} else {
+			notFound++
+		}
+		allAvailable = allAvailable && result.Sufficient
+		results = append(results, result)
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Int("stock_check.not_found", notFound),
+		attribute.Bool("stock_check.all_available", allAvailable),
+	)
+
+	h.logger.InfoCtx(ctx, "Batch stock check completed",
+		logger.Int("count", len(results)),
+		logger.Int("not_found", notFound),
+		logger.Bool("all_available", allAvailable))
+
+	response := gin.H{
+		"count":         len(results),
+		"all_available": allAvailable,
+		"items":         results,
 	}
+	if req.Location != "" {
+		response["location"] = req.Location
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type reserveRequest struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+	OrderID   string `json:"order_id"`
+	Announce  bool   `json:"announce"`
+	Location  string `json:"location"`
+	Region    string `json:"region"`
+}
+
+// ReserveStock records a reservation for an order that holds the stock
+// until it is committed, released or expires. The stock is taken from the
+// given location, or from the locations the allocation strategy picks,
+// preferring region with the nearest strategy. With announce set, the
+// reservation is also announced with an inventory.reserved event, which
+// order-service waits for before confirming asynchronously created orders.
+// Reserving again for an order that holds a reservation is idempotent.
+func (h *InventoryHandler) ReserveStock(c *gin.Context) {
+	ctx := c.Request.Context()
 
+	var req reserveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.ErrorCtx(ctx, "Invalid request body",
 			logger.Err(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+	if req.Announce && req.OrderID == "" {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "announce requires an order_id"))
+		return
+	}
+	if !lookupLocation(c, req.Location) {
 		return
 	}
 
 	tracing.AddSpanAttributes(ctx,
 		attribute.String("product.id", req.ProductID),
 		attribute.Int("reservation.quantity", req.Quantity),
+		attribute.String("order.id", req.OrderID),
+		attribute.String("reservation.location", req.Location),
+		attribute.String("reservation.region", req.Region),
 		attribute.String("operation", "reserve_stock"),
 	)
 
 	h.logger.InfoCtx(ctx, "Reserving stock",
 		logger.String("product_id", req.ProductID),
+		logger.String("order_id", req.OrderID),
 		logger.Int("quantity", req.Quantity))
 
-	inventoryMu.Lock()
-	defer inventoryMu.Unlock()
-
-	item, exists := inventory[req.ProductID]
-	if !exists {
+	item, unlock := lockItem(req.ProductID)
+	if item == nil {
 		tracing.AddSpanAttributes(ctx, attribute.Bool("product.found", false))
 		h.logger.WarnCtx(ctx, "Product not found for reservation",
 			logger.String("product_id", req.ProductID))
+		metrics.RecordStockReservation(metrics.ReservationNotFound)
 
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":      "Product not found",
-			"product_id": req.ProductID,
-		})
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+req.ProductID+" does not exist").
+			With("product_id", req.ProductID))
 		return
 	}
 
+	// A retried reservation, e.g. by order-service's stock reconciler after
+	// it failed to save the order, answers with the reservation the order
+	// already holds instead of holding the stock twice.
+	if req.OrderID != "" {
+		if held := activeReservations(item, req.OrderID, ""); len(held) > 0 {
+			h.answerHeld(c, item, req, held)
+			unlock()
+			return
+		}
+	}
+
 	available := item.Quantity - item.Reserved
 
-	if available < req.Quantity {
+	allocations := h.allocate(item, req.Quantity, req.Location, req.Region)
+	if allocations == nil {
+		availableHere := availableAt(item, req.Location)
+		unlock()
+
 		tracing.AddSpanAttributes(ctx,
 			attribute.Bool("reservation.success", false),
 			attribute.String("reservation.failure_reason", "insufficient_stock"),
-			attribute.Int("stock.available", available),
+			attribute.Int("stock.available", availableHere),
 		)
 
 		h.logger.WarnCtx(ctx, "Insufficient stock for reservation",
 			logger.String("product_id", req.ProductID),
+			logger.String("location", req.Location),
 			logger.Int("requested", req.Quantity),
-			logger.Int("available", available))
+			logger.Int("available", availableHere))
 
-		c.JSON(http.StatusConflict, gin.H{
-			"error":     "Insufficient stock",
-			"available": available,
-			"requested": req.Quantity,
-		})
+		failure := problem.New(http.StatusConflict, problem.CodeInsufficientStock, fmt.Sprintf("Requested %d, only %d available", req.Quantity, availableHere)).
+			With("product_id", req.ProductID).
+			With("available", availableHere).
+			With("requested", req.Quantity)
+		if req.Location != "" {
+			failure = failure.With("location", req.Location)
+		}
+		metrics.RecordStockReservation(metrics.ReservationConflict)
+		problem.Write(c, failure)
 		return
 	}
 
-	item.Reserved += req.Quantity
+	reservation, err := h.reserve(ctx, item, req.OrderID, allocations)
+	if err != nil {
+		unlock()
+		h.logger.ErrorCtx(ctx, "Failed to store reservation",
+			logger.Err(err),
+			logger.String("product_id", req.ProductID),
+			logger.String("order_id", req.OrderID))
+
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to record reservation").
+			With("product_id", req.ProductID).
+			With("order_id", req.OrderID))
+		return
+	}
 	newAvailable := item.Quantity - item.Reserved
+	newReserved := item.Reserved
+	productName := item.Name
+	alert := lowStockAlert(item, available)
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementReserve,
+		ReservedDelta: req.Quantity,
+		Location:      singleLocation(allocations),
+		ReservationID: reservation.ID,
+		OrderID:       req.OrderID,
+		Actor:         requestActor(c),
+	})
+	// Settling the reservation changes its allocations in place, so the
+	// event and response describe a copy taken before the lock is released.
+	allocations = append([]models.Allocation(nil), allocations...)
+	unlock()
+
+	// The event is stored without holding the product's lock, so reservations
+	// of a hot product do not queue behind the outbox. The inventory lives in
+	// memory, so the event cannot share a transaction with the reservation;
+	// undo the reservation instead when the event cannot be stored, so no
+	// order waits for an event that never comes and no consumer misses the
+	// change.
+	var eventID string
+	if h.outbox != nil {
+		eventID, err = h.outbox.SaveWithOptions(ctx, EventInventoryReserved, InventoryReservedEvent{
+			ReservationID: reservation.ID,
+			OrderID:       req.OrderID,
+			ProductID:     req.ProductID,
+			ProductName:   productName,
+			Quantity:      req.Quantity,
+			NewAvailable:  newAvailable,
+			ReservedAt:    time.Now().UTC(),
+			Allocations:   allocations,
+			Unannounced:   !req.Announce,
+		}, outboxinbox.SaveOptions{PartitionKey: partitionKey(req.OrderID, req.ProductID)})
+		if err != nil {
+			h.undoReservation(ctx, req.ProductID, reservation)
+
+			h.logger.ErrorCtx(ctx, "Failed to save inventory.reserved event, reservation undone",
+				logger.Err(err),
+				logger.String("product_id", req.ProductID),
+				logger.String("order_id", req.OrderID))
+
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to record reservation").
+				With("product_id", req.ProductID).
+				With("order_id", req.OrderID))
+			return
+		}
+	}
+
+	h.raiseLowStock(ctx, alert)
+	metrics.RecordStockReservation(metrics.ReservationSuccess)
+	h.recordMovement(ctx, movement)
 
 	tracing.AddSpanAttributes(ctx,
 		attribute.Bool("reservation.success", true),
-		attribute.Int("stock.new_reserved", item.Reserved),
+		attribute.String("reservation.id", reservation.ID),
+		attribute.Int("reservation.locations", len(allocations)),
+		attribute.Int("stock.new_reserved", newReserved),
 		attribute.Int("stock.new_available", newAvailable),
+		attribute.String("reservation.event_id", eventID),
 	)
 
 	h.logger.InfoCtx(ctx, "Stock reserved successfully",
+		logger.String("reservation_id", reservation.ID),
 		logger.String("product_id", req.ProductID),
+		logger.String("order_id", req.OrderID),
 		logger.Int("reserved_quantity", req.Quantity),
-		logger.Int("new_available", newAvailable))
+		logger.Int("new_available", newAvailable),
+		logger.String("event_id", eventID))
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message":           "Stock reserved successfully",
+		"reservation_id":    reservation.ID,
 		"product_id":        req.ProductID,
 		"reserved_quantity": req.Quantity,
 		"new_available":     newAvailable,
+		"expires_at":        reservation.ExpiresAt,
+		"allocations":       allocations,
+	}
+	if req.OrderID != "" {
+		response["order_id"] = req.OrderID
+	}
+	if req.Announce && eventID != "" {
+		response["event_id"] = eventID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// answerHeld answers a reservation request for an order that already holds
+// held, as long as it asks for the quantity held. No event is announced
+// again. The caller holds item's lock.
+func (h *InventoryHandler) answerHeld(c *gin.Context, item *InventoryItem, req reserveRequest, held []*models.Reservation) {
+	reserved := reservedQuantity(held)
+	h.logger.WarnCtx(c.Request.Context(), "Order already holds a reservation",
+		logger.String("order_id", req.OrderID),
+		logger.String("reservation_id", held[0].ID),
+		logger.Int("reserved", reserved))
+
+	if reserved != req.Quantity {
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeConflict,
+			fmt.Sprintf("Order %s already holds %d of %s", req.OrderID, reserved, req.ProductID)).
+			With("order_id", req.OrderID).
+			With("reserved", reserved).
+			With("requested", req.Quantity))
+		return
+	}
+
+	var allocations []models.Allocation
+	for _, r := range held {
+		allocations = append(allocations, r.Allocations...)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Stock already reserved for order",
+		"reservation_id":    held[0].ID,
+		"product_id":        req.ProductID,
+		"order_id":          req.OrderID,
+		"reserved_quantity": reserved,
+		"new_available":     item.Quantity - item.Reserved,
+		"expires_at":        held[0].ExpiresAt,
+		"allocations":       allocations,
+	})
+}
+
+// ReleaseStock returns previously reserved stock, e.g. when a later step of
+// the order flow fails. With an order_id or reservation_id only those
+// reservations are released, so releasing one that already expired is a
+// no-op; otherwise the product's oldest reservations are. Releasing more
+// than is reserved clamps to what is reserved.
+func (h *InventoryHandler) ReleaseStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req struct {
+		ProductID     string `json:"product_id" binding:"required"`
+		Quantity      int    `json:"quantity" binding:"required,gt=0"`
+		OrderID       string `json:"order_id"`
+		ReservationID string `json:"reservation_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.ErrorCtx(ctx, "Invalid request body",
+			logger.Err(err))
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("product.id", req.ProductID),
+		attribute.Int("release.quantity", req.Quantity),
+		attribute.String("order.id", req.OrderID),
+		attribute.String("reservation.id", req.ReservationID),
+		attribute.String("operation", "release_stock"),
+	)
+
+	h.logger.InfoCtx(ctx, "Releasing stock",
+		logger.String("product_id", req.ProductID),
+		logger.String("order_id", req.OrderID),
+		logger.String("reservation_id", req.ReservationID),
+		logger.Int("quantity", req.Quantity))
+
+	item, unlock := lockItem(req.ProductID)
+	if item == nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("product.found", false))
+		h.logger.WarnCtx(ctx, "Product not found for release",
+			logger.String("product_id", req.ProductID))
+
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+req.ProductID+" does not exist").
+			With("product_id", req.ProductID))
+		return
+	}
+
+	held := activeReservations(item, req.OrderID, req.ReservationID)
+	if reserved := reservedQuantity(held); req.Quantity > reserved {
+		h.logger.WarnCtx(ctx, "Releasing more stock than is reserved",
+			logger.String("product_id", req.ProductID),
+			logger.String("order_id", req.OrderID),
+			logger.Int("requested", req.Quantity),
+			logger.Int("reserved", reserved))
+	}
+
+	// Like adjustments, the event is stored before the change and under the
+	// product's lock: a release cannot be undone, so it is only applied once
+	// it is sure to be announced.
+	taken := settling(held, req.Quantity)
+	if toRelease := min(req.Quantity, reservedQuantity(held)); toRelease > 0 && h.outbox != nil {
+		reservationID := req.ReservationID
+		if len(taken) == 1 {
+			reservationID = taken[0].ID
+		}
+		_, err := h.outbox.SaveWithOptions(ctx, EventInventoryReleased, InventoryReleasedEvent{
+			ReservationID: reservationID,
+			OrderID:       req.OrderID,
+			ProductID:     req.ProductID,
+			Quantity:      toRelease,
+			Reason:        models.ReservationStatusReleased,
+			NewAvailable:  item.Quantity - item.Reserved + toRelease,
+			ReleasedAt:    time.Now().UTC(),
+		}, outboxinbox.SaveOptions{PartitionKey: partitionKey(req.OrderID, req.ProductID)})
+		if err != nil {
+			unlock()
+			h.logger.ErrorCtx(ctx, "Failed to save inventory.released event, release not applied",
+				logger.Err(err),
+				logger.String("product_id", req.ProductID),
+				logger.String("order_id", req.OrderID))
+
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to record release").
+				With("product_id", req.ProductID).
+				With("order_id", req.OrderID))
+			return
+		}
+	}
+
+	released := h.settle(ctx, item, held, req.Quantity, models.ReservationStatusReleased)
+	newAvailable := item.Quantity - item.Reserved
+	newReserved := item.Reserved
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementRelease,
+		ReservedDelta: -released,
+		ReservationID: req.ReservationID,
+		OrderID:       req.OrderID,
+		Actor:         requestActor(c),
+	})
+	unlock()
+
+	if released > 0 {
+		metrics.RecordStockRelease(models.ReservationStatusReleased)
+		h.recordMovement(ctx, movement)
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Int("stock.released", released),
+		attribute.Int("stock.new_reserved", newReserved),
+		attribute.Int("stock.new_available", newAvailable),
+	)
+
+	h.logger.InfoCtx(ctx, "Stock released successfully",
+		logger.String("product_id", req.ProductID),
+		logger.Int("released_quantity", released),
+		logger.Int("new_available", newAvailable))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Stock released successfully",
+		"product_id":        req.ProductID,
+		"released_quantity": released,
+		"new_available":     newAvailable,
+	})
+}
+
+// CommitStock turns reserved stock into consumed stock once an order ships,
+// decrementing both the quantity on hand and the reservation. Like release
+// it takes the order's or the given reservation, or else the product's
+// oldest ones. Unlike release, committing more than is reserved is refused
+// with 409, since the goods were never set aside.
+func (h *InventoryHandler) CommitStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req struct {
+		ProductID     string `json:"product_id" binding:"required"`
+		Quantity      int    `json:"quantity" binding:"required,gt=0"`
+		OrderID       string `json:"order_id"`
+		ReservationID string `json:"reservation_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.ErrorCtx(ctx, "Invalid request body",
+			logger.Err(err))
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("product.id", req.ProductID),
+		attribute.Int("commit.quantity", req.Quantity),
+		attribute.String("order.id", req.OrderID),
+		attribute.String("reservation.id", req.ReservationID),
+		attribute.String("operation", "commit_stock"),
+	)
+
+	h.logger.InfoCtx(ctx, "Committing stock",
+		logger.String("product_id", req.ProductID),
+		logger.String("order_id", req.OrderID),
+		logger.Int("quantity", req.Quantity))
+
+	item, unlock := lockItem(req.ProductID)
+	if item == nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("product.found", false))
+		h.logger.WarnCtx(ctx, "Product not found for commit",
+			logger.String("product_id", req.ProductID))
+
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+req.ProductID+" does not exist").
+			With("product_id", req.ProductID))
+		return
+	}
+
+	held := activeReservations(item, req.OrderID, req.ReservationID)
+	if reserved := reservedQuantity(held); req.Quantity > reserved {
+		unlock()
+		tracing.AddSpanAttributes(ctx,
+			attribute.Bool("commit.success", false),
+			attribute.Int("stock.reserved", reserved),
+		)
+
+		h.logger.WarnCtx(ctx, "Committing more stock than is reserved",
+			logger.String("product_id", req.ProductID),
+			logger.String("order_id", req.OrderID),
+			logger.Int("requested", req.Quantity),
+			logger.Int("reserved", reserved))
+
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeConflict, fmt.Sprintf("Requested %d, only %d reserved", req.Quantity, reserved)).
+			With("product_id", req.ProductID).
+			With("reserved", reserved).
+			With("requested", req.Quantity))
+		return
+	}
+
+	if h.outbox != nil {
+		_, err := h.outbox.SaveWithOptions(ctx, EventInventoryUpdated, InventoryUpdatedEvent{
+			ProductID:        req.ProductID,
+			OrderID:          req.OrderID,
+			Location:         heldAtOne(settling(held, req.Quantity)),
+			Delta:            -req.Quantity,
+			Reason:           ReasonCommit,
+			PreviousQuantity: item.Quantity,
+			Quantity:         item.Quantity - req.Quantity,
+			Reserved:         item.Reserved - req.Quantity,
+			Available:        item.Quantity - item.Reserved,
+			AdjustedAt:       time.Now().UTC(),
+		}, outboxinbox.SaveOptions{PartitionKey: partitionKey(req.OrderID, req.ProductID)})
+		if err != nil {
+			unlock()
+			h.logger.ErrorCtx(ctx, "Failed to save inventory.updated event, commit not applied",
+				logger.Err(err),
+				logger.String("product_id", req.ProductID),
+				logger.String("order_id", req.OrderID))
+
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to record commit").
+				With("product_id", req.ProductID).
+				With("order_id", req.OrderID))
+			return
+		}
+	}
+
+	h.settle(ctx, item, held, req.Quantity, models.ReservationStatusCommitted)
+	newQuantity := item.Quantity
+	newReserved := item.Reserved
+	newAvailable := newQuantity - newReserved
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementCommit,
+		QuantityDelta: -req.Quantity,
+		ReservedDelta: -req.Quantity,
+		ReservationID: req.ReservationID,
+		OrderID:       req.OrderID,
+		Actor:         requestActor(c),
+	})
+	unlock()
+
+	h.recordMovement(ctx, movement)
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("commit.success", true),
+		attribute.Int("stock.new_quantity", newQuantity),
+		attribute.Int("stock.new_reserved", newReserved),
+		attribute.Int("stock.new_available", newAvailable),
+	)
+
+	h.logger.InfoCtx(ctx, "Stock committed successfully",
+		logger.String("product_id", req.ProductID),
+		logger.String("order_id", req.OrderID),
+		logger.Int("committed_quantity", req.Quantity),
+		logger.Int("new_quantity", newQuantity),
+		logger.Int("new_reserved", newReserved))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Stock committed successfully",
+		"product_id":         req.ProductID,
+		"committed_quantity": req.Quantity,
+		"new_quantity":       newQuantity,
+		"new_reserved":       newReserved,
+		"new_available":      newAvailable,
 	})
 }
 
+// AdjustStock changes the quantity on hand of a product at one location by
+// delta, e.g. to restock it or write off damaged goods. The location may be
+// omitted for products stocked at a single location; restocking a location
+// that does not hold the product yet starts stocking it there. The reason
+// is recorded in the audit log and in the inventory.updated event
+// announcing the change. Restocks must add and damage must remove stock,
+// and no adjustment may leave less on hand than is reserved.
+func (h *InventoryHandler) AdjustStock(c *gin.Context) {
+	ctx := c.Request.Context()
+	productID := c.Param("product_id")
+
+	var req struct {
+		Delta    int    `json:"delta" binding:"required"`
+		Reason   string `json:"reason" binding:"required"`
+		Note     string `json:"note" binding:"max=500"`
+		Location string `json:"location"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.ErrorCtx(ctx, "Invalid request body",
+			logger.Err(err))
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+
+	switch {
+	case !adjustmentReasons[req.Reason]:
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			"reason must be one of restock, damage, correction or audit").
+			With("reason", req.Reason))
+		return
+	case req.Reason == AdjustmentRestock && req.Delta < 0,
+		req.Reason == AdjustmentDamage && req.Delta > 0:
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+			"delta must be positive for restock and negative for damage").
+			With("reason", req.Reason).
+			With("delta", req.Delta))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("product.id", productID),
+		attribute.Int("adjustment.delta", req.Delta),
+		attribute.String("adjustment.reason", req.Reason),
+		attribute.String("location", req.Location),
+		attribute.String("operation", "adjust_stock"),
+	)
+
+	if !lookupLocation(c, req.Location) {
+		return
+	}
+
+	// Unlike reservations, the whole adjustment holds the product's lock:
+	// its event is stored before the change and must describe it exactly.
+	item, unlock := lockItem(productID)
+	defer unlock()
+
+	if item == nil {
+		tracing.AddSpanAttributes(ctx, attribute.Bool("product.found", false))
+		h.logger.WarnCtx(ctx, "Product not found for adjustment",
+			logger.String("product_id", productID))
+
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+productID+" does not exist").
+			With("product_id", productID))
+		return
+	}
+
+	location := req.Location
+	if location == "" {
+		if len(item.Locations) != 1 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+				"location is required for products stocked at several locations").
+				With("product_id", productID))
+			return
+		}
+		for id := range item.Locations {
+			location = id
+		}
+	}
+
+	locationQuantity, locationReserved := stockAt(item, location)
+	if locationQuantity+req.Delta < locationReserved {
+		h.logger.WarnCtx(ctx, "Adjustment would leave less stock than is reserved",
+			logger.String("product_id", productID),
+			logger.String("location", location),
+			logger.Int("delta", req.Delta),
+			logger.Int("quantity", locationQuantity),
+			logger.Int("reserved", locationReserved))
+
+		problem.Write(c, problem.New(http.StatusConflict, problem.CodeConflict,
+			fmt.Sprintf("Adjusting %d at %s by %d would leave less than the %d reserved", locationQuantity, location, req.Delta, locationReserved)).
+			With("product_id", productID).
+			With("location", location).
+			With("quantity", locationQuantity).
+			With("reserved", locationReserved).
+			With("delta", req.Delta))
+		return
+	}
+
+	newQuantity := item.Quantity + req.Delta
+	event := InventoryUpdatedEvent{
+		ProductID:        productID,
+		Location:         location,
+		Delta:            req.Delta,
+		Reason:           req.Reason,
+		Note:             req.Note,
+		PreviousQuantity: item.Quantity,
+		Quantity:         newQuantity,
+		Reserved:         item.Reserved,
+		Available:        newQuantity - item.Reserved,
+		AdjustedAt:       time.Now().UTC(),
+	}
+
+	// The event is stored before the in-memory change, so an adjustment is
+	// never applied without being announced.
+	var eventID string
+	if h.outbox != nil {
+		var err error
+		eventID, err = h.outbox.SaveWithOptions(ctx, EventInventoryUpdated, event, outboxinbox.SaveOptions{PartitionKey: productID})
+		if err != nil {
+			h.logger.ErrorCtx(ctx, "Failed to save inventory.updated event, adjustment not applied",
+				logger.Err(err),
+				logger.String("product_id", productID))
+
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to record adjustment").
+				With("product_id", productID))
+			return
+		}
+	}
+
+	previousAvailable := item.Quantity - item.Reserved
+	stock, exists := item.Locations[location]
+	if !exists {
+		stock = &LocationStock{}
+		item.Locations[location] = stock
+	}
+	stock.Quantity += req.Delta
+	item.Quantity = newQuantity
+	recordStockLevels(item)
+	h.recordMovement(ctx, stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementAdjust,
+		QuantityDelta: req.Delta,
+		Location:      location,
+		Reason:        req.Reason,
+		Actor:         requestActor(c),
+	}))
+	h.raiseLowStock(ctx, lowStockAlert(item, previousAvailable))
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Int("stock.new_quantity", event.Quantity),
+		attribute.Int("stock.new_available", event.Available),
+	)
+
+	h.logger.InfoCtx(ctx, "Inventory adjusted",
+		logger.String("product_id", productID),
+		logger.String("location", location),
+		logger.String("reason", req.Reason),
+		logger.String("note", req.Note),
+		logger.Int("delta", req.Delta),
+		logger.Int("previous_quantity", event.PreviousQuantity),
+		logger.Int("new_quantity", event.Quantity),
+		logger.Int("new_available", event.Available),
+		logger.String("client_ip", c.ClientIP()),
+		logger.String("event_id", eventID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Stock adjusted successfully",
+		"product_id":        productID,
+		"location":          location,
+		"delta":             req.Delta,
+		"reason":            req.Reason,
+		"previous_quantity": event.PreviousQuantity,
+		"new_quantity":      event.Quantity,
+		"new_available":     event.Available,
+		"event_id":          eventID,
+	})
+}
+
+// GetAllInventory lists every product with its breakdown per location, or
+// the products stocked at the location query parameter with their stock
+// there.
 func (h *InventoryHandler) GetAllInventory(c *gin.Context) {
 	ctx := c.Request.Context()
+	location := c.Query("location")
 
-	tracing.AddSpanAttributes(ctx, attribute.String("operation", "get_all_inventory"))
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("location", location),
+		attribute.String("operation", "get_all_inventory"),
+	)
 
-	h.logger.InfoCtx(ctx, "Fetching all inventory")
+	h.logger.InfoCtx(ctx, "Fetching all inventory",
+		logger.String("location", location))
+
+	if !lookupLocation(c, location) {
+		return
+	}
 
 	inventoryMu.RLock()
 	items := make([]gin.H, 0, len(inventory))
 	for _, item := range inventory {
-		available := item.Quantity - item.Reserved
-		items = append(items, gin.H{
+		item.mu.RLock()
+		if _, stocked := item.Locations[location]; location != "" && !stocked {
+			item.mu.RUnlock()
+			continue
+		}
+		quantity, reserved := stockAt(item, location)
+		entry := gin.H{
 			"product_id": item.ProductID,
 			"name":       item.Name,
-			"quantity":   item.Quantity,
-			"reserved":   item.Reserved,
-			"available":  available,
-		})
+			"quantity":   quantity,
+			"reserved":   reserved,
+			"available":  quantity - reserved,
+
+			"low_stock_threshold": item.LowStockThreshold,
+		}
+		if location == "" {
+			entry["locations"] = locationBreakdown(item)
+		}
+		item.mu.RUnlock()
+		items = append(items, entry)
 	}
 	inventoryMu.RUnlock()
 
 	tracing.AddSpanAttributes(ctx, attribute.Int("inventory.count", len(items)))
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"count":     len(items),
 		"inventory": items,
-	})
+	}
+	if location != "" {
+		response["location"] = location
+	}
+	c.JSON(http.StatusOK, response)
 }
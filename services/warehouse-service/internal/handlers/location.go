@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Allocation strategies choose the locations a reservation draws its stock
+// from when the caller does not pin one.
+const (
+	// AllocationMostStock draws from the location with the most available
+	// stock first, which keeps reservations from being split.
+	AllocationMostStock = "most_stock"
+	// AllocationNearest draws from locations in the requested region first
+	// and falls back to the others by available stock.
+	AllocationNearest = "nearest"
+)
+
+// DefaultAllocationStrategy is used unless SetAllocationStrategy picks another.
+const DefaultAllocationStrategy = AllocationMostStock
+
+// Location is a warehouse site that holds stock.
+type Location struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Region string `json:"region"`
+}
+
+// LocationStock is a product's stock at one location. The item's Quantity
+// and Reserved are the sums over its locations.
+type LocationStock struct {
+	Quantity int `json:"quantity"`
+	Reserved int `json:"reserved"`
+}
+
+var locations = map[string]*Location{
+	"WH-WEST": {ID: "WH-WEST", Name: "West Distribution Center", Region: "west"},
+	"WH-EAST": {ID: "WH-EAST", Name: "East Distribution Center", Region: "east"},
+}
+
+// SetAllocationStrategy sets how reservations that do not pin a location
+// are spread over the locations holding the product.
+func (h *InventoryHandler) SetAllocationStrategy(strategy string) error {
+	switch strategy {
+	case AllocationMostStock, AllocationNearest:
+		h.allocationStrategy = strategy
+		return nil
+	}
+	return fmt.Errorf("unknown allocation strategy %q, expected %s or %s", strategy, AllocationMostStock, AllocationNearest)
+}
+
+// stockAt returns item's quantity on hand and reserved at location, or
+// across all locations when location is empty. The caller holds item's
+// lock.
+func stockAt(item *InventoryItem, location string) (quantity, reserved int) {
+	if location == "" {
+		return item.Quantity, item.Reserved
+	}
+	if stock, exists := item.Locations[location]; exists {
+		return stock.Quantity, stock.Reserved
+	}
+	return 0, 0
+}
+
+// availableAt is like stockAt for the available stock.
+func availableAt(item *InventoryItem, location string) int {
+	quantity, reserved := stockAt(item, location)
+	return quantity - reserved
+}
+
+// allocate picks where quantity units of item are reserved: only at
+// location when it is given, otherwise according to the allocation
+// strategy, preferring region for AllocationNearest. A reservation that no
+// single location can fill is split over several. It returns nil when the
+// stock is short. The caller holds item's lock.
+func (h *InventoryHandler) allocate(item *InventoryItem, quantity int, location, region string) []models.Allocation {
+	if location != "" {
+		if availableAt(item, location) < quantity {
+			return nil
+		}
+		return []models.Allocation{{Location: location, Quantity: quantity}}
+	}
+
+	candidates := make([]string, 0, len(item.Locations))
+	for id := range item.Locations {
+		if availableAt(item, id) > 0 {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if h.allocationStrategy == AllocationNearest && region != "" {
+			aNear, bNear := locations[a].Region == region, locations[b].Region == region
+			if aNear != bNear {
+				return aNear
+			}
+		}
+		if availableAt(item, a) != availableAt(item, b) {
+			return availableAt(item, a) > availableAt(item, b)
+		}
+		return a < b
+	})
+
+	var allocations []models.Allocation
+	remaining := quantity
+	for _, id := range candidates {
+		if remaining == 0 {
+			break
+		}
+		n := availableAt(item, id)
+		if n > remaining {
+			n = remaining
+		}
+		allocations = append(allocations, models.Allocation{Location: id, Quantity: n})
+		remaining -= n
+	}
+	if remaining > 0 {
+		return nil
+	}
+	return allocations
+}
+
+// lookupLocation writes a 404 and returns false when location is set but
+// unknown.
+func lookupLocation(c *gin.Context, location string) bool {
+	if location == "" || locations[location] != nil {
+		return true
+	}
+	problem.Write(c, problem.New(http.StatusNotFound, problem.CodeNotFound, "Location "+location+" does not exist").
+		With("location", location))
+	return false
+}
+
+// locationBreakdown lists item's stock per location, ordered by location.
+// The caller holds item's lock.
+func locationBreakdown(item *InventoryItem) []gin.H {
+	ids := make([]string, 0, len(item.Locations))
+	for id := range item.Locations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	breakdown := make([]gin.H, 0, len(ids))
+	for _, id := range ids {
+		stock := item.Locations[id]
+		breakdown = append(breakdown, gin.H{
+			"location":  id,
+			"quantity":  stock.Quantity,
+			"reserved":  stock.Reserved,
+			"available": stock.Quantity - stock.Reserved,
+		})
+	}
+	return breakdown
+}
+
+// GetLocations lists the warehouse locations with the stock they hold.
+func (h *InventoryHandler) GetLocations(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tracing.AddSpanAttributes(ctx, attribute.String("operation", "get_locations"))
+
+	type totals struct{ products, quantity, reserved int }
+	held := make(map[string]*totals, len(locations))
+	for id := range locations {
+		held[id] = &totals{}
+	}
+
+	inventoryMu.RLock()
+	for _, item := range inventory {
+		item.mu.RLock()
+		for id, stock := range item.Locations {
+			if t, exists := held[id]; exists {
+				t.products++
+				t.quantity += stock.Quantity
+				t.reserved += stock.Reserved
+			}
+		}
+		item.mu.RUnlock()
+	}
+	inventoryMu.RUnlock()
+
+	list := make([]gin.H, 0, len(locations))
+	for _, location := range locations {
+		t := held[location.ID]
+		list = append(list, gin.H{
+			"id":        location.ID,
+			"name":      location.Name,
+			"region":    location.Region,
+			"products":  t.products,
+			"quantity":  t.quantity,
+			"reserved":  t.reserved,
+			"available": t.quantity - t.reserved,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i]["id"].(string) < list[j]["id"].(string) })
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":     len(list),
+		"locations": list,
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EventInventoryLowStock announces that a product's available stock dropped
+// to its low-stock threshold.
+const EventInventoryLowStock = "inventory.low_stock"
+
+// InventoryLowStockEvent is the payload of inventory.low_stock.
+type InventoryLowStockEvent struct {
+	ProductID  string    `json:"product_id"`
+	Name       string    `json:"name"`
+	Threshold  int       `json:"threshold"`
+	Available  int       `json:"available"`
+	Quantity   int       `json:"quantity"`
+	Reserved   int       `json:"reserved"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ParseLowStockThresholds parses "product=threshold" pairs separated by
+// commas.
+func ParseLowStockThresholds(raw string) (map[string]int, error) {
+	thresholds := map[string]int{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		productID, value, ok := strings.Cut(entry, "=")
+		threshold, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || strings.TrimSpace(productID) == "" || err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid low-stock threshold %q, expected product=threshold", entry)
+		}
+		thresholds[strings.TrimSpace(productID)] = threshold
+	}
+	return thresholds, nil
+}
+
+// SetLowStockThresholds overrides the low-stock thresholds of the given
+// products.
+func (h *InventoryHandler) SetLowStockThresholds(thresholds map[string]int) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+
+	for productID := range thresholds {
+		if _, exists := inventory[productID]; !exists {
+			return fmt.Errorf("unknown product %s", productID)
+		}
+	}
+	for productID, threshold := range thresholds {
+		inventory[productID].LowStockThreshold = threshold
+		invalidateProducts(productID)
+	}
+	return nil
+}
+
+// lowStockAlert returns the low-stock alert to raise when item's available
+// stock has just crossed its threshold, coming from previousAvailable, and
+// nil otherwise. Stock that was already low does not alert again until it
+// is replenished above the threshold. The caller holds item's lock.
+func lowStockAlert(item *InventoryItem, previousAvailable int) *InventoryLowStockEvent {
+	available := item.Quantity - item.Reserved
+	if item.LowStockThreshold <= 0 || previousAvailable <= item.LowStockThreshold || available > item.LowStockThreshold {
+		return nil
+	}
+	return &InventoryLowStockEvent{
+		ProductID:  item.ProductID,
+		Name:       item.Name,
+		Threshold:  item.LowStockThreshold,
+		Available:  available,
+		Quantity:   item.Quantity,
+		Reserved:   item.Reserved,
+		DetectedAt: time.Now().UTC(),
+	}
+}
+
+// raiseLowStock announces an alert from lowStockAlert, if any. The alert is
+// best effort: a failure to store the event is logged but does not undo the
+// change that caused it.
+func (h *InventoryHandler) raiseLowStock(ctx context.Context, alert *InventoryLowStockEvent) {
+	if alert == nil {
+		return
+	}
+
+	metrics.RecordLowStockAlert(alert.ProductID)
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("stock.low", true),
+		attribute.Int("stock.low_threshold", alert.Threshold),
+	)
+
+	h.logger.WarnCtx(ctx, "Product stock is low",
+		logger.String("product_id", alert.ProductID),
+		logger.Int("available", alert.Available),
+		logger.Int("threshold", alert.Threshold))
+
+	if h.outbox == nil {
+		return
+	}
+	_, err := h.outbox.SaveWithOptions(ctx, EventInventoryLowStock, *alert, outboxinbox.SaveOptions{PartitionKey: alert.ProductID})
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to save inventory.low_stock event",
+			logger.Err(err),
+			logger.String("product_id", alert.ProductID))
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"warehouse-service/internal/metrics"
+)
+
+type HandlerFunc func(ctx context.Context, msg outboxinbox.InboxMessage) error
+
+type MessageHandlerRegistry struct {
+	log        logger.Logger
+	handlers   map[string]HandlerFunc
+	maxRetries int
+	mu         sync.RWMutex
+}
+
+// NewMessageHandlerRegistry routes inbox messages by event type. maxRetries
+// is the inbox workers' limit, which tells a failed execution that will be
+// retried from one that marks the message failed.
+func NewMessageHandlerRegistry(log logger.Logger, maxRetries int) *MessageHandlerRegistry {
+	return &MessageHandlerRegistry{
+		log:        log,
+		handlers:   make(map[string]HandlerFunc),
+		maxRetries: maxRetries,
+	}
+}
+
+func (r *MessageHandlerRegistry) Register(eventType string, handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[eventType] = handler
+	r.log.Info("Registered message handler",
+		logger.String("event_type", eventType))
+}
+
+func (r *MessageHandlerRegistry) HandleMessage(ctx context.Context, msg outboxinbox.InboxMessage) error {
+	r.mu.RLock()
+	handler, exists := r.handlers[msg.EventType]
+	r.mu.RUnlock()
+
+	if !exists {
+		r.log.Warn("No handler registered for event type",
+			logger.String("event_type", msg.EventType),
+			logger.String("message_id", msg.MessageID))
+		r.record(ctx, msg.EventType, metrics.EventSkippedNoHandler, 0)
+		return nil
+	}
+
+	r.log.Debug("Routing message to handler",
+		logger.String("event_type", msg.EventType),
+		logger.String("message_id", msg.MessageID),
+		logger.Bool("dry_run", outboxinbox.IsDryRun(ctx)))
+
+	start := time.Now()
+	err := handler(ctx, msg)
+	r.record(ctx, msg.EventType, r.outcome(msg, err), time.Since(start))
+	return err
+}
+
+// outcome classifies a handler execution the way the inbox worker will act
+// on it.
+func (r *MessageHandlerRegistry) outcome(msg outboxinbox.InboxMessage, err error) string {
+	switch {
+	case err == nil:
+		return metrics.EventSuccess
+	case errors.Is(err, outboxinbox.ErrPoisonMessage), msg.RetryCount+1 >= r.maxRetries:
+		return metrics.EventFailed
+	default:
+		return metrics.EventRetry
+	}
+}
+
+// record counts the execution, leaving out dry-run replays, which do not
+// reflect how the service handles live events.
+func (r *MessageHandlerRegistry) record(ctx context.Context, eventType, outcome string, duration time.Duration) {
+	if outboxinbox.IsDryRun(ctx) {
+		return
+	}
+	metrics.RecordEventHandled(ctx, eventType, outcome, duration)
+}
+
+func (r *MessageHandlerRegistry) GetHandler() outboxinbox.MessageHandler {
+	return r.HandleMessage
+}
+
+func (r *MessageHandlerRegistry) ListRegisteredHandlers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handlers := make([]string, 0, len(r.handlers))
+	for eventType := range r.handlers {
+		handlers = append(handlers, eventType)
+	}
+	return handlers
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/models"
+	"warehouse-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// actorHeader names the user or system behind a stock change when the
+	// request does not carry an authenticated user.
+	actorHeader = "X-Actor"
+	// actorExpirer is the actor of reservations expired by the warehouse.
+	actorExpirer = "reservation-expirer"
+	// actorReconciler is the actor of corrections made by stock
+	// reconciliation.
+	actorReconciler = "stock-reconciler"
+)
+
+// MovementStore persists the inventory audit log.
+type MovementStore interface {
+	Record(ctx context.Context, m *models.InventoryMovement) error
+	List(ctx context.Context, filter services.MovementFilter) ([]models.InventoryMovement, int64, error)
+}
+
+// SetMovementStore records every stock change in store and enables the
+// movements endpoint.
+func (h *InventoryHandler) SetMovementStore(store MovementStore) {
+	h.movements = store
+}
+
+// stockMovement completes m as a change just applied to item with the totals
+// it left. The caller holds item's lock.
+func stockMovement(item *InventoryItem, m models.InventoryMovement) models.InventoryMovement {
+	m.ProductID = item.ProductID
+	m.QuantityAfter = item.Quantity
+	m.ReservedAfter = item.Reserved
+	return m
+}
+
+// recordMovement logs a stock change, filling in the request and trace it
+// was made by. Like the low-stock alert it is best effort: the stock lives in
+// memory and has already changed, so a failure to store the movement is
+// logged rather than undoing it. Callers need not hold the item's lock, and
+// should not while the movement is written.
+func (h *InventoryHandler) recordMovement(ctx context.Context, m models.InventoryMovement) {
+	if h.movements == nil {
+		return
+	}
+
+	m.RequestID = logger.GetRequestID(ctx)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		m.TraceID = sc.TraceID().String()
+	}
+
+	if err := h.movements.Record(ctx, &m); err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to record inventory movement",
+			logger.Err(err),
+			logger.String("product_id", m.ProductID),
+			logger.String("movement_type", m.MovementType),
+			logger.Int("quantity_delta", m.QuantityDelta),
+			logger.Int("reserved_delta", m.ReservedDelta))
+	}
+}
+
+// requestActor identifies who made a stock change: the authenticated user
+// when available, then the X-Actor header, then the client IP.
+func requestActor(c *gin.Context) string {
+	if userID := logger.GetUserID(c.Request.Context()); userID != "" {
+		return userID
+	}
+	if actor := c.GetHeader(actorHeader); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+// singleLocation returns the location of allocations when they are all at
+// one, and "" otherwise.
+func singleLocation(allocations []models.Allocation) string {
+	if len(allocations) != 1 {
+		return ""
+	}
+	return allocations[0].Location
+}
+
+// GetMovements lists a product's stock movements, newest first, optionally
+// filtered by type and order_id.
+func (h *InventoryHandler) GetMovements(c *gin.Context) {
+	ctx := c.Request.Context()
+	productID := c.Param("product_id")
+	movementType := c.Query("type")
+	orderID := c.Query("order_id")
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("product.id", productID),
+		attribute.String("operation", "get_movements"),
+	)
+
+	if h.movements == nil {
+		problem.Write(c, problem.New(http.StatusServiceUnavailable, problem.CodeDependencyUnavailable, "The inventory audit log is not configured"))
+		return
+	}
+
+	if movementType != "" && !isMovementType(movementType) {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Unknown movement type "+movementType).
+			With("type", movementType))
+		return
+	}
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	inventoryMu.RLock()
+	_, exists := inventory[productID]
+	inventoryMu.RUnlock()
+	if !exists {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeProductNotFound, "Product "+productID+" does not exist").
+			With("product_id", productID))
+		return
+	}
+
+	movements, total, err := h.movements.List(ctx, services.MovementFilter{
+		ProductID:    productID,
+		MovementType: movementType,
+		OrderID:      orderID,
+		Limit:        limit,
+		Offset:       offset,
+	})
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Failed to list inventory movements",
+			logger.Err(err),
+			logger.String("product_id", productID))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Failed to list inventory movements"))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.Int("movements.count", len(movements)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": productID,
+		"count":      len(movements),
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+		"movements":  movements,
+	})
+}
+
+func isMovementType(movementType string) bool {
+	for _, t := range models.MovementTypes {
+		if t == movementType {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit = defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return 0, 0, false
+		}
+		limit = v
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if raw := c.Query("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "offset must be a non-negative integer"))
+			return 0, 0, false
+		}
+		offset = v
+	}
+
+	return limit, offset, true
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"observability-system/shared/ops"
+	"warehouse-service/internal/models"
+)
+
+// RecentReservations lists the newest reservations for the operational
+// snapshot served at /internal/ops. Reservations live in this instance's
+// memory, so the list is of this replica only.
+func RecentReservations(ctx context.Context, limit int) ([]ops.Record, error) {
+	list := listReservations(func(*models.Reservation) bool { return true })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	records := make([]ops.Record, 0, len(list))
+	for _, r := range list {
+		summary := fmt.Sprintf("%d x %s for order %s", r.Quantity, r.ProductID, r.OrderID)
+		if len(r.Allocations) > 0 {
+			locations := make([]string, 0, len(r.Allocations))
+			for _, a := range r.Allocations {
+				locations = append(locations, a.Location)
+			}
+			summary += " at " + strings.Join(locations, ", ")
+		}
+		updatedAt := r.UpdatedAt
+		records = append(records, ops.Record{
+			ID:        r.ID,
+			Status:    r.Status,
+			Summary:   summary,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: &updatedAt,
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/metrics"
+	"warehouse-service/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EventOrderCreated is published by order-service for every new order.
+const EventOrderCreated = "order.created"
+
+// EventInventoryRejected answers an order.created event whose stock could
+// not be reserved.
+const EventInventoryRejected = "inventory.rejected"
+
+// orderStatusPending is the status of orders waiting for their stock to be
+// reserved.
+const orderStatusPending = "pending"
+
+// InventoryRejectedEvent is the payload of inventory.rejected.
+type InventoryRejectedEvent struct {
+	OrderID    string       `json:"order_id"`
+	ProductID  string       `json:"product_id"`
+	Quantity   int          `json:"quantity"`
+	Code       problem.Code `json:"code"`
+	Reason     string       `json:"reason"`
+	Available  int          `json:"available"`
+	RejectedAt time.Time    `json:"rejected_at"`
+}
+
+// orderCreatedEvent is the part of order-service's order.created payload
+// the warehouse needs.
+type orderCreatedEvent struct {
+	OrderID       string `json:"order_id"`
+	ProductID     string `json:"product_id"`
+	Quantity      int    `json:"quantity"`
+	Status        string `json:"status"`
+	StockReserved bool   `json:"stock_reserved"`
+}
+
+// HandleOrderCreated reserves the stock of orders created without a
+// reservation and answers with inventory.reserved, or with
+// inventory.rejected when the product is unknown or the stock is short.
+// Orders whose stock order-service already reserved are acknowledged
+// without changes. Reservations are keyed by order ID, so an order that
+// already has one, e.g. because the event was published twice, is not
+// reserved again.
+func (h *InventoryHandler) HandleOrderCreated(ctx context.Context, msg outboxinbox.InboxMessage) error {
+	var payload orderCreatedEvent
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return outboxinbox.Poison(fmt.Errorf("failed to unmarshal order.created payload: %w", err))
+	}
+	if payload.OrderID == "" || payload.ProductID == "" || payload.Quantity <= 0 {
+		return outboxinbox.Poison(errors.New("order.created payload is missing order_id, product_id or quantity"))
+	}
+
+	if payload.Status != orderStatusPending || payload.StockReserved {
+		h.logger.DebugCtx(ctx, "Ignoring order created event, stock is handled by order-service",
+			logger.String("order_id", payload.OrderID),
+			logger.String("status", payload.Status))
+		return nil
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", payload.OrderID),
+		attribute.String("product.id", payload.ProductID),
+		attribute.Int("reservation.quantity", payload.Quantity),
+		attribute.String("operation", "reserve_stock_for_order"),
+	)
+
+	h.logger.InfoCtx(ctx, "Reserving stock for order created event",
+		logger.String("message_id", msg.MessageID),
+		logger.String("order_id", payload.OrderID),
+		logger.String("product_id", payload.ProductID),
+		logger.Int("quantity", payload.Quantity))
+
+	if h.outbox == nil {
+		return errors.New("no outbox configured to answer order.created")
+	}
+
+	item, unlock := lockItem(payload.ProductID)
+	if item == nil {
+		metrics.RecordStockReservation(metrics.ReservationNotFound)
+		return h.rejectOrder(ctx, payload, problem.CodeProductNotFound, "Product "+payload.ProductID+" does not exist", 0)
+	}
+
+	// An order reserves a single product, so only its reservations can
+	// already hold the order.
+	if held := item.orderReservations[payload.OrderID]; len(held) > 0 {
+		reservationID, status := held[0].ID, held[0].Status
+		unlock()
+		h.logger.WarnCtx(ctx, "Order already has a reservation, skipping",
+			logger.String("order_id", payload.OrderID),
+			logger.String("reservation_id", reservationID),
+			logger.String("status", status))
+		return nil
+	}
+
+	available := item.Quantity - item.Reserved
+	allocations := h.allocate(item, payload.Quantity, "", "")
+	if allocations == nil {
+		unlock()
+		metrics.RecordStockReservation(metrics.ReservationConflict)
+		return h.rejectOrder(ctx, payload, problem.CodeInsufficientStock,
+			fmt.Sprintf("Requested %d, only %d available", payload.Quantity, available), available)
+	}
+
+	reservation, err := h.reserve(ctx, item, payload.OrderID, allocations)
+	if err != nil {
+		unlock()
+		return err
+	}
+	newAvailable := item.Quantity - item.Reserved
+	event := InventoryReservedEvent{
+		ReservationID: reservation.ID,
+		OrderID:       payload.OrderID,
+		ProductID:     payload.ProductID,
+		ProductName:   item.Name,
+		Quantity:      payload.Quantity,
+		NewAvailable:  newAvailable,
+		ReservedAt:    reservation.CreatedAt,
+		Allocations:   append([]models.Allocation(nil), allocations...),
+	}
+	alert := lowStockAlert(item, available)
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementReserve,
+		ReservedDelta: payload.Quantity,
+		Location:      singleLocation(allocations),
+		ReservationID: reservation.ID,
+		OrderID:       payload.OrderID,
+		Actor:         msg.SenderID,
+	})
+	unlock()
+
+	eventID, err := h.outbox.SaveWithOptions(ctx, EventInventoryReserved, event, outboxinbox.SaveOptions{PartitionKey: payload.OrderID})
+	if err != nil {
+		// Undo the reservation so the retried event starts over.
+		h.undoReservation(ctx, payload.ProductID, reservation)
+		return fmt.Errorf("failed to save %s event: %w", EventInventoryReserved, err)
+	}
+
+	h.raiseLowStock(ctx, alert)
+	metrics.RecordStockReservation(metrics.ReservationSuccess)
+	h.recordMovement(ctx, movement)
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("reservation.success", true),
+		attribute.String("reservation.id", reservation.ID),
+	)
+
+	h.logger.InfoCtx(ctx, "Stock reserved for order",
+		logger.String("order_id", payload.OrderID),
+		logger.String("reservation_id", reservation.ID),
+		logger.Int("new_available", newAvailable),
+		logger.String("event_id", eventID))
+
+	return nil
+}
+
+// rejectOrder answers an order whose stock cannot be reserved.
+func (h *InventoryHandler) rejectOrder(ctx context.Context, payload orderCreatedEvent, code problem.Code, reason string, available int) error {
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("reservation.success", false),
+		attribute.String("reservation.failure_reason", string(code)),
+	)
+
+	eventID, err := h.outbox.SaveWithOptions(ctx, EventInventoryRejected, InventoryRejectedEvent{
+		OrderID:    payload.OrderID,
+		ProductID:  payload.ProductID,
+		Quantity:   payload.Quantity,
+		Code:       code,
+		Reason:     reason,
+		Available:  available,
+		RejectedAt: time.Now().UTC(),
+	}, outboxinbox.SaveOptions{PartitionKey: payload.OrderID})
+	if err != nil {
+		return fmt.Errorf("failed to save %s event: %w", EventInventoryRejected, err)
+	}
+
+	h.logger.WarnCtx(ctx, "Rejected order created event",
+		logger.String("order_id", payload.OrderID),
+		logger.String("product_id", payload.ProductID),
+		logger.String("code", string(code)),
+		logger.String("reason", reason),
+		logger.String("event_id", eventID))
+
+	return nil
+}
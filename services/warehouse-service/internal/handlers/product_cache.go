@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"observability-system/shared/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productCache holds the CheckStock responses of whole products, so reads
+// of hot products do not queue behind reservations for their item lock;
+// nil disables it. Every change to an item drops the item's entry.
+var productCache *cache.Cache
+
+var errProductMissing = errors.New("product does not exist")
+
+// stockView is the CheckStock response of a product across all locations.
+type stockView struct {
+	ProductID         string  `json:"product_id"`
+	Name              string  `json:"name"`
+	Quantity          int     `json:"quantity"`
+	Reserved          int     `json:"reserved"`
+	Available         int     `json:"available"`
+	LowStockThreshold int     `json:"low_stock_threshold"`
+	Locations         []gin.H `json:"locations"`
+}
+
+// SetProductCache caches product reads in c. The inventory lives in this
+// instance's memory, so c must not share entries with other instances
+// through Redis.
+func (h *InventoryHandler) SetProductCache(c *cache.Cache) {
+	productCache = c
+}
+
+// productStock returns productID's stock across all locations, from the
+// cache when it holds the product.
+func productStock(ctx context.Context, productID string) (*stockView, error) {
+	return cache.Fetch(ctx, productCache, productID, func(context.Context) (*stockView, error) {
+		item, unlock := readItem(productID)
+		defer unlock()
+		if item == nil {
+			return nil, errProductMissing
+		}
+		return &stockView{
+			ProductID:         item.ProductID,
+			Name:              item.Name,
+			Quantity:          item.Quantity,
+			Reserved:          item.Reserved,
+			Available:         item.Quantity - item.Reserved,
+			LowStockThreshold: item.LowStockThreshold,
+			Locations:         locationBreakdown(item),
+		}, nil
+	})
+}
+
+// invalidateProducts drops the cached stock of productIDs after a change.
+// The cache is in memory only, so dropping entries cannot fail.
+func invalidateProducts(productIDs ...string) {
+	_ = productCache.Delete(context.Background(), productIDs...)
+}
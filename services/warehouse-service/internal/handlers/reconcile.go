@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/metrics"
+	"warehouse-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EventInventoryDiscrepancy announces a discrepancy found by stock
+// reconciliation.
+const EventInventoryDiscrepancy = "inventory.discrepancy"
+
+// Kinds of discrepancy stock reconciliation looks for.
+const (
+	// DiscrepancyReservedDrift is a reserved quantity that differs from what
+	// the active reservations hold.
+	DiscrepancyReservedDrift = "reserved_drift"
+	// DiscrepancyOrphanedReservation is stock held for an order that
+	// order-service does not know or no longer holds stock for.
+	DiscrepancyOrphanedReservation = "orphaned_reservation"
+	// DiscrepancyQuantityMismatch is an order holding another quantity than
+	// order-service expects.
+	DiscrepancyQuantityMismatch = "quantity_mismatch"
+	// DiscrepancyMissingReservation is an order order-service holds stock
+	// for without an active reservation.
+	DiscrepancyMissingReservation = "missing_reservation"
+)
+
+// DiscrepancyKinds lists every kind of discrepancy.
+var DiscrepancyKinds = []string{
+	DiscrepancyReservedDrift,
+	DiscrepancyOrphanedReservation,
+	DiscrepancyQuantityMismatch,
+	DiscrepancyMissingReservation,
+}
+
+// ReasonReconciled is the reason of releases of orphaned reservations.
+const ReasonReconciled = "reconciled"
+
+// Statuses of order-service orders still in progress. Their stock may be
+// reserved before order-service records it, so they are never orphaned,
+// and those with stock_reserved set must have an active reservation.
+// Confirmed orders are left out, as their reservations expire unless
+// committed.
+var inProgressOrderStatuses = []string{"pending", "pending_stock_check", "stock_reserved"}
+
+// OrderSource reads order-service's view of the orders holding stock.
+type OrderSource interface {
+	// Orders returns the orders among ids that order-service knows.
+	Orders(ctx context.Context, ids []string) ([]models.Order, error)
+	// OrdersByStatus returns the orders in status created before
+	// createdBefore.
+	OrdersByStatus(ctx context.Context, status string, createdBefore time.Time) ([]models.Order, error)
+}
+
+// InventoryDiscrepancyEvent is the payload of inventory.discrepancy.
+// Expected is what the reservations or order-service account for and
+// Actual what warehouse-service holds: the reserved quantity at Location,
+// or at every location when it is empty, for reserved_drift, and the stock
+// the order holds otherwise.
+type InventoryDiscrepancyEvent struct {
+	Kind        string    `json:"kind"`
+	ProductID   string    `json:"product_id"`
+	Location    string    `json:"location,omitempty"`
+	OrderID     string    `json:"order_id,omitempty"`
+	OrderStatus string    `json:"order_status,omitempty"`
+	Expected    int       `json:"expected"`
+	Actual      int       `json:"actual"`
+	Corrected   bool      `json:"corrected"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// ReconcileReport is the outcome of a reconciliation. OrdersChecked is
+// false when no order source is configured.
+type ReconcileReport struct {
+	Products      int                         `json:"products"`
+	Orders        int                         `json:"orders"`
+	OrdersChecked bool                        `json:"orders_checked"`
+	Corrected     int                         `json:"corrected"`
+	Discrepancies []InventoryDiscrepancyEvent `json:"discrepancies"`
+}
+
+// orderHold identifies the stock an order holds of a product.
+type orderHold struct {
+	orderID   string
+	productID string
+}
+
+// SetReconciliation configures stock reconciliation. Reservations are
+// cross-checked with orders, unless it is nil. Reservations and orders
+// younger than grace are skipped, as they may still be in flight. With
+// autoCorrect, drifted reserved quantities are reset to what the active
+// reservations hold and orphaned reservations are released; the other
+// discrepancies are only reported.
+func (h *InventoryHandler) SetReconciliation(orders OrderSource, grace time.Duration, autoCorrect bool) {
+	h.orders = orders
+	h.reconcileGrace = grace
+	h.reconcileAutoCorrect = autoCorrect
+}
+
+// ReconcileStock runs a reconciliation and returns how many discrepancies
+// it found and corrected.
+func (h *InventoryHandler) ReconcileStock(ctx context.Context) (int, int, error) {
+	report, err := h.reconcile(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(report.Discrepancies), report.Corrected, nil
+}
+
+// reconcile checks every product's reserved quantities against its active
+// reservations, then the orders those reservations are held for against
+// order-service. Every discrepancy is counted, announced with
+// inventory.discrepancy and logged.
+func (h *InventoryHandler) reconcile(ctx context.Context) (*ReconcileReport, error) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-h.reconcileGrace)
+	report := &ReconcileReport{Discrepancies: []InventoryDiscrepancyEvent{}}
+
+	holds, drifts, movements := h.checkReserved(now, cutoff, report)
+	for _, m := range movements {
+		h.recordMovement(ctx, m)
+	}
+	report.Discrepancies = append(report.Discrepancies, drifts...)
+
+	if h.orders != nil {
+		found, err := h.checkOrders(ctx, holds, now, cutoff, report)
+		if err != nil {
+			metrics.RecordReconciliation(metrics.ReconciliationFailed, DiscrepancyKinds, nil)
+			return nil, err
+		}
+		report.Discrepancies = append(report.Discrepancies, found...)
+	}
+
+	counts := map[string]int{}
+	for _, d := range report.Discrepancies {
+		counts[d.Kind]++
+		metrics.RecordDiscrepancy(d.Kind, d.Corrected)
+		if d.Corrected {
+			report.Corrected++
+		}
+		h.logger.WarnCtx(ctx, "Stock discrepancy found",
+			logger.String("kind", d.Kind),
+			logger.String("product_id", d.ProductID),
+			logger.String("location", d.Location),
+			logger.String("order_id", d.OrderID),
+			logger.String("order_status", d.OrderStatus),
+			logger.Int("expected", d.Expected),
+			logger.Int("actual", d.Actual),
+			logger.Bool("corrected", d.Corrected))
+	}
+
+	if h.outbox != nil {
+		for _, d := range report.Discrepancies {
+			_, err := h.outbox.SaveWithOptions(ctx, EventInventoryDiscrepancy, d,
+				outboxinbox.SaveOptions{PartitionKey: partitionKey(d.OrderID, d.ProductID)})
+			if err != nil {
+				metrics.RecordReconciliation(metrics.ReconciliationFailed, DiscrepancyKinds, nil)
+				return nil, fmt.Errorf("failed to save %s event of product %s: %w", EventInventoryDiscrepancy, d.ProductID, err)
+			}
+		}
+	}
+	metrics.RecordReconciliation(metrics.ReconciliationCompleted, DiscrepancyKinds, counts)
+	return report, nil
+}
+
+// checkReserved checks the reserved quantities of every product, correcting
+// them when auto-correction is on, and returns what the orders' reservations
+// made before cutoff hold, the drifts found and the movements correcting
+// them.
+func (h *InventoryHandler) checkReserved(now, cutoff time.Time, report *ReconcileReport) (map[orderHold]int, []InventoryDiscrepancyEvent, []models.InventoryMovement) {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+
+	holds := map[orderHold]int{}
+	var drifts []InventoryDiscrepancyEvent
+	var movements []models.InventoryMovement
+	for _, item := range inventory {
+		found, movement := h.checkItemReserved(item, now, cutoff, holds)
+		drifts = append(drifts, found...)
+		if movement != nil {
+			movements = append(movements, *movement)
+		}
+	}
+	report.Products = len(inventory)
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].ProductID != drifts[j].ProductID {
+			return drifts[i].ProductID < drifts[j].ProductID
+		}
+		return drifts[i].Location < drifts[j].Location
+	})
+	return holds, drifts, movements
+}
+
+// checkItemReserved is checkReserved for one item, which it locks.
+func (h *InventoryHandler) checkItemReserved(item *InventoryItem, now, cutoff time.Time, holds map[orderHold]int) ([]InventoryDiscrepancyEvent, *models.InventoryMovement) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	defer invalidateProducts(item.ProductID)
+
+	held := make(map[string]int, len(item.Locations))
+	for _, r := range item.reservations {
+		if r.Status != models.ReservationStatusActive {
+			continue
+		}
+		for _, a := range r.Allocations {
+			held[a.Location] += a.Quantity
+		}
+		if r.OrderID != "" && r.CreatedAt.Before(cutoff) {
+			holds[orderHold{orderID: r.OrderID, productID: item.ProductID}] += r.Quantity
+		}
+	}
+
+	drift := func(location string, expected, actual int) InventoryDiscrepancyEvent {
+		return InventoryDiscrepancyEvent{
+			Kind:       DiscrepancyReservedDrift,
+			ProductID:  item.ProductID,
+			Location:   location,
+			Expected:   expected,
+			Actual:     actual,
+			Corrected:  h.reconcileAutoCorrect,
+			DetectedAt: now,
+		}
+	}
+
+	var found []InventoryDiscrepancyEvent
+	total := 0
+	for id, stock := range item.Locations {
+		total += held[id]
+		if stock.Reserved != held[id] {
+			found = append(found, drift(id, held[id], stock.Reserved))
+		}
+	}
+	if item.Reserved != total {
+		found = append(found, drift("", total, item.Reserved))
+	}
+	if len(found) == 0 || !h.reconcileAutoCorrect {
+		return found, nil
+	}
+
+	delta := total - item.Reserved
+	for id, stock := range item.Locations {
+		stock.Reserved = held[id]
+	}
+	item.Reserved = total
+	recordStockLevels(item)
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementReconcile,
+		ReservedDelta: delta,
+		Reason:        DiscrepancyReservedDrift,
+		Actor:         actorReconciler,
+	})
+	return found, &movement
+}
+
+// checkOrders compares the stock held for orders with what order-service
+// expects, releasing orphaned reservations when auto-correction is on.
+func (h *InventoryHandler) checkOrders(ctx context.Context, holds map[orderHold]int, now, cutoff time.Time, report *ReconcileReport) ([]InventoryDiscrepancyEvent, error) {
+	sorted := make([]orderHold, 0, len(holds))
+	seen := map[string]bool{}
+	var ids []string
+	for hold := range holds {
+		sorted = append(sorted, hold)
+		if !seen[hold.orderID] {
+			seen[hold.orderID] = true
+			ids = append(ids, hold.orderID)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].orderID != sorted[j].orderID {
+			return sorted[i].orderID < sorted[j].orderID
+		}
+		return sorted[i].productID < sorted[j].productID
+	})
+	sort.Strings(ids)
+
+	orders, err := h.orders.Orders(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up orders holding stock: %w", err)
+	}
+	byID := make(map[string]models.Order, len(orders))
+	for _, o := range orders {
+		byID[o.ID] = o
+	}
+
+	var found []InventoryDiscrepancyEvent
+	for _, hold := range sorted {
+		quantity := holds[hold]
+		order, known := byID[hold.orderID]
+		d := InventoryDiscrepancyEvent{
+			ProductID:   hold.productID,
+			OrderID:     hold.orderID,
+			OrderStatus: order.Status,
+			Actual:      quantity,
+			DetectedAt:  now,
+		}
+		switch {
+		case !known || order.ProductID != hold.productID || (!order.StockReserved && !inProgress(order.Status)):
+			d.Kind = DiscrepancyOrphanedReservation
+			if h.reconcileAutoCorrect {
+				released, err := h.releaseOrphaned(ctx, hold, now, cutoff)
+				if err != nil {
+					return nil, err
+				}
+				d.Corrected = released > 0
+			}
+		case order.StockReserved && order.Quantity != quantity:
+			d.Kind = DiscrepancyQuantityMismatch
+			d.Expected = order.Quantity
+		default:
+			continue
+		}
+		found = append(found, d)
+	}
+
+	checked := len(ids)
+	for _, status := range inProgressOrderStatuses {
+		orders, err := h.orders.OrdersByStatus(ctx, status, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s orders: %w", status, err)
+		}
+		for _, o := range orders {
+			if seen[o.ID] {
+				continue
+			}
+			checked++
+			// The order may have been reserved since the reservations
+			// were read.
+			if !o.StockReserved || heldFor(o.ID, o.ProductID) > 0 {
+				continue
+			}
+			found = append(found, InventoryDiscrepancyEvent{
+				Kind:        DiscrepancyMissingReservation,
+				ProductID:   o.ProductID,
+				OrderID:     o.ID,
+				OrderStatus: o.Status,
+				Expected:    o.Quantity,
+				DetectedAt:  now,
+			})
+		}
+	}
+	report.Orders = checked
+	report.OrdersChecked = true
+	return found, nil
+}
+
+func inProgress(status string) bool {
+	for _, s := range inProgressOrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// heldFor returns the stock orderID's active reservations hold of
+// productID.
+func heldFor(orderID, productID string) int {
+	item, unlock := readItem(productID)
+	defer unlock()
+
+	if item == nil {
+		return 0
+	}
+	return reservedQuantity(activeReservations(item, orderID, ""))
+}
+
+// releaseOrphaned releases the reservations an order made before cutoff,
+// announcing the release with inventory.released first, and returns how
+// much stock it returned.
+func (h *InventoryHandler) releaseOrphaned(ctx context.Context, hold orderHold, now, cutoff time.Time) (int, error) {
+	item, unlock := lockItem(hold.productID)
+	if item == nil {
+		unlock()
+		return 0, nil
+	}
+
+	var held []*models.Reservation
+	for _, r := range activeReservations(item, hold.orderID, "") {
+		if r.CreatedAt.Before(cutoff) {
+			held = append(held, r)
+		}
+	}
+	quantity := reservedQuantity(held)
+	if quantity == 0 {
+		unlock()
+		return 0, nil
+	}
+
+	if h.outbox != nil {
+		reservationID := ""
+		if len(held) == 1 {
+			reservationID = held[0].ID
+		}
+		_, err := h.outbox.SaveWithOptions(ctx, EventInventoryReleased, InventoryReleasedEvent{
+			ReservationID: reservationID,
+			OrderID:       hold.orderID,
+			ProductID:     hold.productID,
+			Quantity:      quantity,
+			Reason:        ReasonReconciled,
+			NewAvailable:  item.Quantity - item.Reserved + quantity,
+			ReleasedAt:    now,
+		}, outboxinbox.SaveOptions{PartitionKey: partitionKey(hold.orderID, hold.productID)})
+		if err != nil {
+			unlock()
+			return 0, fmt.Errorf("failed to save %s event of order %s: %w", EventInventoryReleased, hold.orderID, err)
+		}
+	}
+
+	released := h.settle(ctx, item, held, quantity, models.ReservationStatusReleased)
+	movement := stockMovement(item, models.InventoryMovement{
+		MovementType:  models.MovementRelease,
+		ReservedDelta: -released,
+		OrderID:       hold.orderID,
+		Reason:        ReasonReconciled,
+		Actor:         actorReconciler,
+	})
+	unlock()
+
+	metrics.RecordStockRelease(ReasonReconciled)
+	h.recordMovement(ctx, movement)
+	return released, nil
+}
+
+// ReconcileInventory runs a stock reconciliation and returns its report.
+func (h *InventoryHandler) ReconcileInventory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("operation", "reconcile_inventory"),
+		attribute.Bool("reconcile.auto_correct", h.reconcileAutoCorrect),
+	)
+
+	report, err := h.reconcile(ctx)
+	if err != nil {
+		h.logger.ErrorCtx(ctx, "Stock reconciliation failed",
+			logger.Err(err))
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Stock reconciliation failed"))
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.Int("reconcile.discrepancies", len(report.Discrepancies)),
+		attribute.Int("reconcile.corrected", report.Corrected),
+	)
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+	"warehouse-service/internal/metrics"
+	"warehouse-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EventInventoryReleased announces that an expired reservation returned its
+// stock.
+const EventInventoryReleased = "inventory.released"
+
+const (
+	// DefaultReservationTTL is how long a reservation holds stock before it
+	// expires unless it is committed or released; zero never expires them.
+	DefaultReservationTTL = 24 * time.Hour
+	// DefaultReservationRetention is how long settled reservations remain
+	// listed.
+	DefaultReservationRetention = 24 * time.Hour
+)
+
+// InventoryReleasedEvent is the payload of inventory.released.
+type InventoryReleasedEvent struct {
+	ReservationID string    `json:"reservation_id"`
+	OrderID       string    `json:"order_id,omitempty"`
+	ProductID     string    `json:"product_id"`
+	Quantity      int       `json:"quantity"`
+	Reason        string    `json:"reason"`
+	NewAvailable  int       `json:"new_available"`
+	ReleasedAt    time.Time `json:"released_at"`
+}
+
+// ReservationStore persists reservations, so a restart keeps the stock they
+// hold and the orders they were made for.
+type ReservationStore interface {
+	// Save inserts r or updates its quantity, status and allocations.
+	Save(ctx context.Context, r *models.Reservation) error
+	Delete(ctx context.Context, id string) error
+	// DeleteSettled deletes the reservations settled before cutoff.
+	DeleteSettled(ctx context.Context, cutoff time.Time) (int64, error)
+	List(ctx context.Context) ([]models.Reservation, error)
+}
+
+// SetReservationStore stores every reservation and its changes in store.
+// Without one, reservations are lost on restart.
+func (h *InventoryHandler) SetReservationStore(store ReservationStore) {
+	h.reservationStore = store
+}
+
+// reserve records a reservation of item's stock at the allocated locations.
+// It is stored before it is applied, so a reservation that cannot be stored
+// holds no stock. The caller holds item's lock, which orders the writes of
+// a reservation's changes.
+func (h *InventoryHandler) reserve(ctx context.Context, item *InventoryItem, orderID string, allocations []models.Allocation) (*models.Reservation, error) {
+	now := time.Now().UTC()
+	r := &models.Reservation{
+		ID:          uuid.New().String(),
+		OrderID:     orderID,
+		ProductID:   item.ProductID,
+		Status:      models.ReservationStatusActive,
+		Allocations: allocations,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if h.reservationTTL > 0 {
+		expiresAt := now.Add(h.reservationTTL)
+		r.ExpiresAt = &expiresAt
+	}
+	for _, a := range allocations {
+		r.Quantity += a.Quantity
+	}
+	if h.reservationStore != nil {
+		if err := h.reservationStore.Save(ctx, r); err != nil {
+			return nil, fmt.Errorf("failed to store reservation: %w", err)
+		}
+	}
+	addReservation(item, r)
+	recordStockLevels(item)
+	return r, nil
+}
+
+// addReservation adds r to item's reservations and, while it is active, its quantity
+// to item's Reserved quantity. The caller holds item's lock.
+func addReservation(item *InventoryItem, r *models.Reservation) {
+	item.reservations[r.ID] = r
+	if r.OrderID != "" {
+		item.orderReservations[r.OrderID] = append(item.orderReservations[r.OrderID], r)
+	}
+	if r.Status != models.ReservationStatusActive {
+		return
+	}
+	for _, a := range r.Allocations {
+		item.Locations[a.Location].Reserved += a.Quantity
+	}
+	item.Reserved += r.Quantity
+}
+
+// storeReservations stores the changes made to reservations. Like
+// recordMovement it is best effort, since the stock has already changed: a
+// reservation whose change is lost is loaded as it was after a restart and
+// expires again. The caller holds item's lock.
+func (h *InventoryHandler) storeReservations(ctx context.Context, reservations []*models.Reservation) {
+	if h.reservationStore == nil {
+		return
+	}
+	for _, r := range reservations {
+		if err := h.reservationStore.Save(ctx, r); err != nil {
+			h.logger.ErrorCtx(ctx, "Failed to store reservation change",
+				logger.Err(err),
+				logger.String("reservation_id", r.ID),
+				logger.String("status", r.Status))
+		}
+	}
+}
+
+// LoadReservations restores the stored reservations of the products in the
+// inventory, returning how many were loaded. It runs once the inventory is
+// imported on startup, before requests are served. Reservations of products
+// the inventory no longer has are skipped.
+func (h *InventoryHandler) LoadReservations(ctx context.Context) (int, error) {
+	if h.reservationStore == nil {
+		return 0, nil
+	}
+	stored, err := h.reservationStore.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load reservations: %w", err)
+	}
+
+	loaded := 0
+	for i := range stored {
+		r := &stored[i]
+		item, unlock := lockItem(r.ProductID)
+		if item == nil {
+			h.logger.WarnCtx(ctx, "Skipping stored reservation of unknown product",
+				logger.String("reservation_id", r.ID),
+				logger.String("product_id", r.ProductID))
+			continue
+		}
+		if _, exists := item.reservations[r.ID]; !exists && heldAtKnownLocations(item, r) {
+			addReservation(item, r)
+			recordStockLevels(item)
+			loaded++
+		} else if !exists {
+			h.logger.WarnCtx(ctx, "Skipping stored reservation held at unknown location",
+				logger.String("reservation_id", r.ID),
+				logger.String("product_id", r.ProductID))
+		}
+		unlock()
+	}
+	return loaded, nil
+}
+
+func heldAtKnownLocations(item *InventoryItem, r *models.Reservation) bool {
+	for _, a := range r.Allocations {
+		if item.Locations[a.Location] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// forget drops r from item's reservations. The caller holds item's lock.
+func forget(item *InventoryItem, r *models.Reservation) {
+	delete(item.reservations, r.ID)
+	if r.OrderID == "" {
+		return
+	}
+	held := item.orderReservations[r.OrderID]
+	for i, other := range held {
+		if other == r {
+			held = append(held[:i:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(item.orderReservations, r.OrderID)
+	} else {
+		item.orderReservations[r.OrderID] = held
+	}
+}
+
+// undoReservation takes back a reservation whose event could not be stored.
+// The item's lock was released while the event was saved, so the
+// reservation is only undone if it is still active; what a concurrent
+// release or commit already settled stays settled.
+func (h *InventoryHandler) undoReservation(ctx context.Context, productID string, r *models.Reservation) {
+	item, unlock := lockItem(productID)
+	defer unlock()
+
+	if item == nil || item.reservations[r.ID] != r || r.Status != models.ReservationStatusActive {
+		return
+	}
+	if h.reservationStore != nil {
+		if err := h.reservationStore.Delete(ctx, r.ID); err != nil {
+			// The stored reservation holds its stock again after a restart,
+			// until it expires.
+			h.logger.ErrorCtx(ctx, "Failed to delete undone reservation",
+				logger.Err(err),
+				logger.String("reservation_id", r.ID))
+		}
+	}
+	for _, a := range r.Allocations {
+		item.Locations[a.Location].Reserved -= a.Quantity
+	}
+	item.Reserved -= r.Quantity
+	forget(item, r)
+	recordStockLevels(item)
+}
+
+// activeReservations returns the active reservations of item, oldest first,
+// narrowed to orderID or reservationID when given. Those are looked up
+// directly rather than scanning every reservation a hot product holds. The
+// caller holds item's lock.
+func activeReservations(item *InventoryItem, orderID, reservationID string) []*models.Reservation {
+	candidates := item.reservations
+	switch {
+	case reservationID != "":
+		candidates = map[string]*models.Reservation{}
+		if r, exists := item.reservations[reservationID]; exists {
+			candidates[r.ID] = r
+		}
+	case orderID != "":
+		candidates = map[string]*models.Reservation{}
+		for _, r := range item.orderReservations[orderID] {
+			candidates[r.ID] = r
+		}
+	}
+
+	var matched []*models.Reservation
+	for _, r := range candidates {
+		if r.Status != models.ReservationStatusActive {
+			continue
+		}
+		if (orderID != "" && r.OrderID != orderID) || (reservationID != "" && r.ID != reservationID) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.Before(matched[j].CreatedAt) })
+	return matched
+}
+
+// settle takes up to quantity units out of reservations, oldest first, and
+// removes them from item's Reserved quantity at the locations they were
+// held at. Committed units also leave those locations' quantity on hand.
+// Reservations taken in full move to status; a partially taken one stays
+// active with the remainder. The changed reservations are stored. The
+// caller holds item's lock.
+func (h *InventoryHandler) settle(ctx context.Context, item *InventoryItem, reservations []*models.Reservation, quantity int, status string) int {
+	now := time.Now().UTC()
+	taken := 0
+	for i, r := range reservations {
+		if taken == quantity {
+			reservations = reservations[:i]
+			break
+		}
+		n := quantity - taken
+		if n >= r.Quantity {
+			n = r.Quantity
+			r.Status = status
+		}
+		takeAllocations(item, r, n, status == models.ReservationStatusCommitted)
+		r.UpdatedAt = now
+		taken += n
+	}
+	item.Reserved -= taken
+	if status == models.ReservationStatusCommitted {
+		item.Quantity -= taken
+	}
+	recordStockLevels(item)
+	h.storeReservations(ctx, reservations)
+	return taken
+}
+
+// takeAllocations takes n units out of r's allocations, last first. A fully
+// settled reservation keeps its allocations as a record of where it was
+// held. The caller holds item's lock.
+func takeAllocations(item *InventoryItem, r *models.Reservation, n int, consume bool) {
+	settled := r.Status != models.ReservationStatusActive
+	if !settled {
+		r.Quantity -= n
+	}
+	for i := len(r.Allocations) - 1; i >= 0 && n > 0; i-- {
+		a := &r.Allocations[i]
+		m := n
+		if m > a.Quantity {
+			m = a.Quantity
+		}
+		stock := item.Locations[a.Location]
+		stock.Reserved -= m
+		if consume {
+			stock.Quantity -= m
+		}
+		if !settled {
+			a.Quantity -= m
+		}
+		n -= m
+	}
+	if !settled {
+		kept := r.Allocations[:0]
+		for _, a := range r.Allocations {
+			if a.Quantity > 0 {
+				kept = append(kept, a)
+			}
+		}
+		r.Allocations = kept
+	}
+}
+
+// settling returns the reservations settle would take quantity units out
+// of, oldest first, without changing them.
+func settling(reservations []*models.Reservation, quantity int) []*models.Reservation {
+	taken := 0
+	for i, r := range reservations {
+		if taken >= quantity {
+			return reservations[:i]
+		}
+		taken += r.Quantity
+	}
+	return reservations
+}
+
+// heldAtOne returns the location reservations are all held at, or "" when
+// they span several.
+func heldAtOne(reservations []*models.Reservation) string {
+	location := ""
+	for _, r := range reservations {
+		for _, a := range r.Allocations {
+			if location != "" && a.Location != location {
+				return ""
+			}
+			location = a.Location
+		}
+	}
+	return location
+}
+
+// partitionKey orders an event with the other events of its order, or of
+// its product when it belongs to none.
+func partitionKey(orderID, productID string) string {
+	if orderID != "" {
+		return orderID
+	}
+	return productID
+}
+
+func reservedQuantity(reservations []*models.Reservation) int {
+	total := 0
+	for _, r := range reservations {
+		total += r.Quantity
+	}
+	return total
+}
+
+// ExpireReservations returns the stock of active reservations past their
+// expiry and announces each with inventory.released. A reservation whose
+// event cannot be stored stays active and is retried on the next run.
+// Settled reservations older than the retention period are forgotten and
+// deleted from the store. Products are handled one at a time, so requests for the others proceed.
+func (h *InventoryHandler) ExpireReservations(ctx context.Context) (int, error) {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+
+	now := time.Now().UTC()
+	expired := 0
+	for _, item := range inventory {
+		n, err := h.expireItemReservations(ctx, item, now)
+		expired += n
+		if err != nil {
+			return expired, err
+		}
+	}
+	if h.reservationStore != nil {
+		if _, err := h.reservationStore.DeleteSettled(ctx, now.Add(-h.reservationRetention)); err != nil {
+			return expired, fmt.Errorf("failed to delete settled reservations: %w", err)
+		}
+	}
+	return expired, nil
+}
+
+// expireItemReservations expires item's reservations for
+// ExpireReservations, which holds inventoryMu.
+func (h *InventoryHandler) expireItemReservations(ctx context.Context, item *InventoryItem, now time.Time) (int, error) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	defer invalidateProducts(item.ProductID)
+
+	expired := 0
+	for _, r := range item.reservations {
+		if r.Status != models.ReservationStatusActive {
+			if now.Sub(r.UpdatedAt) > h.reservationRetention {
+				forget(item, r)
+			}
+			continue
+		}
+		if r.ExpiresAt == nil || now.Before(*r.ExpiresAt) {
+			continue
+		}
+
+		newAvailable := item.Quantity - item.Reserved + r.Quantity
+
+		if h.outbox != nil {
+			_, err := h.outbox.SaveWithOptions(ctx, EventInventoryReleased, InventoryReleasedEvent{
+				ReservationID: r.ID,
+				OrderID:       r.OrderID,
+				ProductID:     r.ProductID,
+				Quantity:      r.Quantity,
+				Reason:        models.ReservationStatusExpired,
+				NewAvailable:  newAvailable,
+				ReleasedAt:    now,
+			}, outboxinbox.SaveOptions{PartitionKey: partitionKey(r.OrderID, r.ProductID)})
+			if err != nil {
+				return expired, fmt.Errorf("failed to save %s event of reservation %s: %w", EventInventoryReleased, r.ID, err)
+			}
+		}
+
+		h.settle(ctx, item, []*models.Reservation{r}, r.Quantity, models.ReservationStatusExpired)
+		metrics.RecordStockRelease(models.ReservationStatusExpired)
+		h.recordMovement(ctx, stockMovement(item, models.InventoryMovement{
+			MovementType:  models.MovementExpire,
+			ReservedDelta: -r.Quantity,
+			ReservationID: r.ID,
+			OrderID:       r.OrderID,
+			Actor:         actorExpirer,
+		}))
+		expired++
+
+		h.logger.WarnCtx(ctx, "Reservation expired, stock returned",
+			logger.String("reservation_id", r.ID),
+			logger.String("order_id", r.OrderID),
+			logger.String("product_id", r.ProductID),
+			logger.Int("quantity", r.Quantity),
+			logger.Int("new_available", newAvailable))
+	}
+	return expired, nil
+}
+
+// GetReservations lists reservations, newest first, optionally filtered by
+// status, product_id, order_id, location and expiring_before, an RFC 3339
+// time that selects the active reservations expiring before it.
+func (h *InventoryHandler) GetReservations(c *gin.Context) {
+	ctx := c.Request.Context()
+	status := c.Query("status")
+	productID := c.Query("product_id")
+	orderID := c.Query("order_id")
+	location := c.Query("location")
+
+	var expiringBefore time.Time
+	if raw := c.Query("expiring_before"); raw != "" {
+		var err error
+		expiringBefore, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "expiring_before must be an RFC 3339 time").
+				With("expiring_before", raw))
+			return
+		}
+	}
+
+	switch status {
+	case "", models.ReservationStatusActive, models.ReservationStatusCommitted, models.ReservationStatusReleased, models.ReservationStatusExpired:
+	default:
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Unknown reservation status "+status).
+			With("status", status))
+		return
+	}
+	if !lookupLocation(c, location) {
+		return
+	}
+
+	tracing.AddSpanAttributes(ctx, attribute.String("operation", "get_reservations"))
+
+	list := listReservations(func(r *models.Reservation) bool {
+		return (status == "" || r.Status == status) &&
+			(productID == "" || r.ProductID == productID) &&
+			(orderID == "" || r.OrderID == orderID) &&
+			(location == "" || heldAt(r, location)) &&
+			(expiringBefore.IsZero() || expiresBefore(r, expiringBefore))
+	})
+
+	tracing.AddSpanAttributes(ctx, attribute.Int("reservations.count", len(list)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":        len(list),
+		"reservations": list,
+	})
+}
+
+// GetOrderReservations returns every reservation made for an order and how
+// much stock its active ones still hold.
+func (h *InventoryHandler) GetOrderReservations(c *gin.Context) {
+	ctx := c.Request.Context()
+	orderID := c.Param("order_id")
+
+	tracing.AddSpanAttributes(ctx,
+		attribute.String("order.id", orderID),
+		attribute.String("operation", "get_order_reservations"),
+	)
+
+	list := listReservations(func(r *models.Reservation) bool { return r.OrderID == orderID })
+	if len(list) == 0 {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeNotFound, "No reservations for order "+orderID).
+			With("order_id", orderID))
+		return
+	}
+
+	held := 0
+	for _, r := range list {
+		if r.Status == models.ReservationStatusActive {
+			held += r.Quantity
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":     orderID,
+		"count":        len(list),
+		"held":         held,
+		"reservations": list,
+	})
+}
+
+// expiresBefore reports whether r is active and expires before t.
+func expiresBefore(r *models.Reservation, t time.Time) bool {
+	return r.Status == models.ReservationStatusActive && r.ExpiresAt != nil && r.ExpiresAt.Before(t)
+}
+
+func heldAt(r *models.Reservation, location string) bool {
+	for _, a := range r.Allocations {
+		if a.Location == location {
+			return true
+		}
+	}
+	return false
+}
+
+// listReservations copies the reservations matching keep, newest first.
+func listReservations(keep func(*models.Reservation) bool) []models.Reservation {
+	inventoryMu.RLock()
+	list := make([]models.Reservation, 0)
+	for _, item := range inventory {
+		item.mu.RLock()
+		for _, r := range item.reservations {
+			if keep(r) {
+				copied := *r
+				copied.Allocations = append([]models.Allocation(nil), r.Allocations...)
+				list = append(list, copied)
+			}
+		}
+		item.mu.RUnlock()
+	}
+	inventoryMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
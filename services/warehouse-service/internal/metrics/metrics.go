@@ -1,62 +1,222 @@
 package metrics
 
 import (
-	"sync"
+	"context"
+	"time"
+
+	"observability-system/shared/apiversion"
+	"observability-system/shared/cache"
+	"observability-system/shared/chaos"
+	"observability-system/shared/lock"
+	"observability-system/shared/messaging/rabbitmq"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/ratelimit"
+	"observability-system/shared/secrets"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// The service's business metrics, registered by InitMetrics.
 var (
-	once              sync.Once
-	HTTPRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"service", "method", "path", "status"},
-	)
-
-	HTTPRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"service", "method", "path"},
-	)
-
-	HTTPResponseSize = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_response_size_bytes",
-			Help:    "HTTP response size in bytes",
-			Buckets: prometheus.ExponentialBuckets(100, 10, 8),
-		},
-		[]string{"service", "method", "path"},
-	)
-
-	InventoryChecksTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "inventory_checks_total",
-			Help: "Total number of inventory check requests",
-		},
-		[]string{"service"},
-	)
-
-	StockReservationsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "stock_reservations_total",
-			Help: "Total number of stock reservation requests",
-		},
-		[]string{"service", "status"},
-	)
+	inventoryChecksTotal                 *prometheus.CounterVec
+	stockReservationsTotal               *prometheus.CounterVec
+	stockReleasesTotal                   *prometheus.CounterVec
+	inventoryQuantity                    *prometheus.GaugeVec
+	inventoryReserved                    *prometheus.GaugeVec
+	inventoryAvailable                   *prometheus.GaugeVec
+	lowStockAlertsTotal                  *prometheus.CounterVec
+	inventoryDiscrepancies               *prometheus.GaugeVec
+	inventoryDiscrepanciesTotal          *prometheus.CounterVec
+	inventoryDiscrepanciesCorrectedTotal *prometheus.CounterVec
+	inventoryReconciliationsTotal        *prometheus.CounterVec
+	inboxHandlerExecutionsTotal          *prometheus.CounterVec
+	inboxHandlerDuration                 prometheus.ObserverVec
+)
+
+// DefaultBuckets tunes the shared histograms to warehouse-service, whose
+// inventory is served from memory in a millisecond or two, while imports,
+// exports and reconciliation can take seconds.
+var DefaultBuckets = map[string][]float64{
+	sharedmetrics.HTTPRequestDuration: {.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+}
+
+// SLOs are warehouse-service's service level objectives. cmd/slo-rules
+// turns them into the burn-rate alerts in infrastructure/prometheus-rules.
+var SLOs = []sharedmetrics.SLO{
+	{
+		Name:      "reserve-stock-availability",
+		Method:    "POST",
+		Routes:    []string{"/api/v1/inventory/reserve", "/api/inventory/reserve"},
+		Objective: 0.999,
+	},
+	{
+		Name:      "reserve-stock-latency",
+		Method:    "POST",
+		Routes:    []string{"/api/v1/inventory/reserve", "/api/inventory/reserve"},
+		Objective: 0.99,
+		Latency:   250 * time.Millisecond,
+	},
+	{
+		Name:      "check-stock-availability",
+		Method:    "GET",
+		Routes:    []string{"/api/v1/inventory/:product_id", "/api/inventory/:product_id"},
+		Objective: 0.999,
+	},
+	{
+		Name:      "check-stock-latency",
+		Method:    "GET",
+		Routes:    []string{"/api/v1/inventory/:product_id", "/api/inventory/:product_id"},
+		Objective: 0.99,
+		Latency:   50 * time.Millisecond,
+	},
+}
+
+// InitMetrics builds the service's registry: the shared HTTP metrics, the
+// service's business metrics and those of Collectors, tracking SLOs.
+// cfg.Buckets overrides DefaultBuckets per metric.
+func InitMetrics(cfg sharedmetrics.Config) *sharedmetrics.Registry {
+	buckets := make(map[string][]float64, len(DefaultBuckets)+len(cfg.Buckets))
+	for name, b := range DefaultBuckets {
+		buckets[name] = b
+	}
+	for name, b := range cfg.Buckets {
+		buckets[name] = b
+	}
+	cfg.Buckets = buckets
+
+	registry := sharedmetrics.NewRegistry(cfg).
+		MustRegister(Collectors()...).
+		MustAddSLOs(SLOs...)
+
+	inventoryChecksTotal = registry.Counter("inventory_checks_total",
+		"Total number of inventory check requests")
+	stockReservationsTotal = registry.Counter("stock_reservations_total",
+		"Total number of stock reservation requests", "status")
+	stockReleasesTotal = registry.Counter("stock_releases_total",
+		"Total number of stock releases, by whether the reservation was released or expired", "reason")
+	inventoryQuantity = registry.Gauge("inventory_quantity",
+		"Units of a product on hand", "product_id")
+	inventoryReserved = registry.Gauge("inventory_reserved",
+		"Units of a product held by active reservations", "product_id")
+	inventoryAvailable = registry.Gauge("inventory_available",
+		"Units of a product on hand and not reserved", "product_id")
+	lowStockAlertsTotal = registry.Counter("inventory_low_stock_alerts_total",
+		"Total number of times a product's available stock dropped to its low-stock threshold", "product_id")
+	inventoryDiscrepancies = registry.Gauge("inventory_discrepancies",
+		"Discrepancies found by the last stock reconciliation, by kind", "kind")
+	inventoryDiscrepanciesTotal = registry.Counter("inventory_discrepancies_total",
+		"Total number of discrepancies found by stock reconciliation, by kind", "kind")
+	inventoryDiscrepanciesCorrectedTotal = registry.Counter("inventory_discrepancies_corrected_total",
+		"Total number of discrepancies stock reconciliation corrected, by kind", "kind")
+	inventoryReconciliationsTotal = registry.Counter("inventory_reconciliations_total",
+		"Total number of stock reconciliation runs, by whether they completed", "outcome")
+
+	inboxHandlerExecutionsTotal = registry.Counter("inbox_handler_executions_total",
+		"Total number of inbox handler executions by event type and outcome", "event_type", "outcome")
+	inboxHandlerDuration = registry.Histogram("inbox_handler_duration_seconds",
+		"Time inbox handlers took by event type and outcome", nil, "event_type", "outcome")
+
+	return registry
+}
+
+// Collectors returns the metrics of the shared packages the service uses.
+func Collectors() []prometheus.Collector {
+	var collectors []prometheus.Collector
+	collectors = append(collectors, outboxinbox.Collectors()...)
+	collectors = append(collectors, ratelimit.Collectors()...)
+	collectors = append(collectors, apiversion.Collectors()...)
+	collectors = append(collectors, rabbitmq.Collectors()...)
+	collectors = append(collectors, secrets.Collectors()...)
+	collectors = append(collectors, lock.Collectors()...)
+	collectors = append(collectors, cache.Collectors()...)
+	collectors = append(collectors, chaos.Collectors()...)
+	return collectors
+}
+
+// Reservation outcomes recorded by RecordStockReservation.
+const (
+	ReservationSuccess  = "success"
+	ReservationConflict = "conflict"
+	ReservationNotFound = "not_found"
+)
+
+// RecordInventoryCheck counts a stock check request; a batch check counts
+// once.
+func RecordInventoryCheck() {
+	inventoryChecksTotal.WithLabelValues().Inc()
+}
+
+// RecordStockReservation counts a reservation attempt by its outcome.
+func RecordStockReservation(status string) {
+	stockReservationsTotal.WithLabelValues(status).Inc()
+}
+
+// RecordStockRelease counts reserved stock returned, reason being released
+// or expired.
+func RecordStockRelease(reason string) {
+	stockReleasesTotal.WithLabelValues(reason).Inc()
+}
+
+// RecordStockLevels sets a product's stock gauges.
+func RecordStockLevels(productID string, quantity, reserved int) {
+	inventoryQuantity.WithLabelValues(productID).Set(float64(quantity))
+	inventoryReserved.WithLabelValues(productID).Set(float64(reserved))
+	inventoryAvailable.WithLabelValues(productID).Set(float64(quantity - reserved))
+}
+
+// DeleteStockLevels removes the stock gauges of a product that no longer
+// exists.
+func DeleteStockLevels(productID string) {
+	inventoryQuantity.DeleteLabelValues(productID)
+	inventoryReserved.DeleteLabelValues(productID)
+	inventoryAvailable.DeleteLabelValues(productID)
+}
+
+// RecordLowStockAlert counts a product crossing its low-stock threshold.
+func RecordLowStockAlert(productID string) {
+	lowStockAlertsTotal.WithLabelValues(productID).Inc()
+}
+
+// Reconciliation outcomes recorded by RecordReconciliation.
+const (
+	ReconciliationCompleted = "completed"
+	ReconciliationFailed    = "failed"
+)
+
+// RecordReconciliation counts a reconciliation run by its outcome and, for
+// completed runs, sets the discrepancies found per kind. kinds lists every
+// kind, so those no longer found drop to zero.
+func RecordReconciliation(outcome string, kinds []string, found map[string]int) {
+	inventoryReconciliationsTotal.WithLabelValues(outcome).Inc()
+	if outcome != ReconciliationCompleted {
+		return
+	}
+	for _, kind := range kinds {
+		inventoryDiscrepancies.WithLabelValues(kind).Set(float64(found[kind]))
+	}
+}
+
+// RecordDiscrepancy counts a discrepancy found by reconciliation and
+// whether it was corrected.
+func RecordDiscrepancy(kind string, corrected bool) {
+	inventoryDiscrepanciesTotal.WithLabelValues(kind).Inc()
+	if corrected {
+		inventoryDiscrepanciesCorrectedTotal.WithLabelValues(kind).Inc()
+	}
+}
+
+// Inbox handler outcomes recorded by RecordEventHandled.
+const (
+	EventSuccess          = "success"
+	EventRetry            = "retry"
+	EventFailed           = "failed"
+	EventSkippedNoHandler = "skipped_no_handler"
 )
 
-func InitMetrics(serviceName string) {
-	once.Do(func() {
-		prometheus.MustRegister(HTTPRequestsTotal)
-		prometheus.MustRegister(HTTPRequestDuration)
-		prometheus.MustRegister(HTTPResponseSize)
-		prometheus.MustRegister(InventoryChecksTotal)
-		prometheus.MustRegister(StockReservationsTotal)
-	})
+// RecordEventHandled counts an inbox handler execution by event type and
+// outcome and records how long it took.
+func RecordEventHandled(ctx context.Context, eventType, outcome string, duration time.Duration) {
+	inboxHandlerExecutionsTotal.WithLabelValues(eventType, outcome).Inc()
+	sharedmetrics.Observe(ctx, inboxHandlerDuration.WithLabelValues(eventType, outcome), duration.Seconds())
 }
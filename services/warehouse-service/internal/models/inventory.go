@@ -1 +1,49 @@
 package models
+
+import "time"
+
+// Kinds of stock movement recorded in the inventory audit log.
+const (
+	MovementReserve = "reserve"
+	MovementRelease = "release"
+	MovementExpire  = "expire"
+	MovementCommit  = "commit"
+	MovementAdjust  = "adjust"
+	MovementImport  = "import"
+	// MovementReconcile corrects a reserved quantity that drifted from the
+	// active reservations.
+	MovementReconcile = "reconcile"
+)
+
+// MovementTypes lists every kind of stock movement.
+var MovementTypes = []string{
+	MovementReserve,
+	MovementRelease,
+	MovementExpire,
+	MovementCommit,
+	MovementAdjust,
+	MovementImport,
+	MovementReconcile,
+}
+
+// InventoryMovement is one change to a product's stock. QuantityDelta and
+// ReservedDelta are the changes to the quantity on hand and the reserved
+// quantity; QuantityAfter and ReservedAfter are the product's totals once it
+// was applied, so replaying the deltas of a product must reproduce them.
+type InventoryMovement struct {
+	ID            int64     `db:"id" json:"id"`
+	ProductID     string    `db:"product_id" json:"product_id"`
+	MovementType  string    `db:"movement_type" json:"movement_type"`
+	QuantityDelta int       `db:"quantity_delta" json:"quantity_delta"`
+	ReservedDelta int       `db:"reserved_delta" json:"reserved_delta"`
+	QuantityAfter int       `db:"quantity_after" json:"quantity_after"`
+	ReservedAfter int       `db:"reserved_after" json:"reserved_after"`
+	Location      string    `db:"location" json:"location,omitempty"`
+	ReservationID string    `db:"reservation_id" json:"reservation_id,omitempty"`
+	OrderID       string    `db:"order_id" json:"order_id,omitempty"`
+	Reason        string    `db:"reason" json:"reason,omitempty"`
+	Actor         string    `db:"actor" json:"actor"`
+	RequestID     string    `db:"request_id" json:"request_id,omitempty"`
+	TraceID       string    `db:"trace_id" json:"trace_id,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
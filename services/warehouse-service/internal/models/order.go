@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Order is order-service's view of an order and the stock it holds.
+// StockReserved is true while order-service expects warehouse-service to
+// hold Quantity units of ProductID for it.
+type Order struct {
+	ID            string    `json:"id"`
+	ProductID     string    `json:"product_id"`
+	Quantity      int       `json:"quantity"`
+	Status        string    `json:"status"`
+	StockReserved bool      `json:"stock_reserved"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Reservation lifecycle. Only active reservations count towards an item's
+// Reserved quantity.
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+// Reservation is stock set aside for one order. The handlers keep active
+// and recently settled reservations in memory with the item they hold stock
+// of, guarded by its lock, and store every change in the reservations
+// table, from which they are loaded again on startup.
+type Reservation struct {
+	ID        string     `db:"id" json:"id"`
+	OrderID   string     `db:"order_id" json:"order_id,omitempty"`
+	ProductID string     `db:"product_id" json:"product_id"`
+	Quantity  int        `db:"quantity" json:"quantity"`
+	Status    string     `db:"status" json:"status"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+	// Allocations record the locations the quantity is held at.
+	Allocations Allocations `db:"allocations" json:"allocations"`
+}
+
+// Allocation is the part of a reservation held at one location.
+type Allocation struct {
+	Location string `json:"location"`
+	Quantity int    `json:"quantity"`
+}
+
+// Allocations are stored as a JSON array.
+type Allocations []Allocation
+
+func (a Allocations) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *Allocations) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case nil:
+		*a = nil
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into allocations", src)
+}
@@ -1,32 +1,104 @@
 package routes
 
 import (
+	"observability-system/shared/apiversion"
+	"observability-system/shared/chaos"
+	"observability-system/shared/health"
 	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"observability-system/shared/problem"
 	"observability-system/shared/tracing"
 	"warehouse-service/internal/handlers"
-	"warehouse-service/internal/metrics"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, handler *handlers.InventoryHandler) {
+// Middleware holds the optional middleware configured in main; nil fields
+// are skipped.
+type Middleware struct {
+	Timeout     gin.HandlerFunc
+	MaxBodySize gin.HandlerFunc
+	// MetricsAuth guards /metrics and /internal/ops; nil serves them openly.
+	MetricsAuth gin.HandlerFunc
+	// ConfigAuth guards /internal/config; nil leaves the route out, so the
+	// configuration is never served openly.
+	ConfigAuth gin.HandlerFunc
+	// ReservationRateLimit and ReservationConcurrency guard the reservation
+	// endpoint, per client and across all clients.
+	ReservationRateLimit   gin.HandlerFunc
+	ReservationConcurrency gin.HandlerFunc
+	// Deprecated marks the unversioned /api aliases of the /api/v1 routes;
+	// nil removes the aliases.
+	Deprecated gin.HandlerFunc
+	// Chaos injects faults into the API routes.
+	Chaos gin.HandlerFunc
+}
+
+// SetupRoutes registers the inventory API under /api/v1. debugEndpoints adds
+// /admin/chaos, which reconfigures fault injection.
+func SetupRoutes(router *gin.Engine, log logger.Logger, serviceName string, handler *handlers.InventoryHandler, chaosHandler *chaos.Handler, debugEndpoints bool, configHandler gin.HandlerFunc, opsReporter *ops.Reporter, prober *health.Prober, registry *metrics.Registry, mw Middleware) {
 
 	router.Use(tracing.GinMiddleware(serviceName))
 
 	router.Use(logger.InjectLogger(log))
 	router.Use(logger.GinMiddleware(log))
-	router.Use(gin.Recovery())
+	router.Use(problem.Recovery())
+
+	router.Use(registry.Middleware())
+	for _, h := range []gin.HandlerFunc{mw.Timeout, mw.MaxBodySize} {
+		if h != nil {
+			router.Use(h)
+		}
+	}
 
-	router.Use(metrics.PrometheusMiddleware(serviceName))
+	router.NoRoute(problem.NoRoute)
 
-	router.GET("/health", handler.HealthCheck)
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/health", prober.Health)
+	router.GET("/live", prober.Live)
+	router.GET("/ready", prober.Ready)
+	router.GET("/metrics", chain(mw.MetricsAuth, gin.WrapH(registry.Handler()))...)
+	router.GET("/internal/ops", chain(mw.MetricsAuth, opsReporter.Handler)...)
+	if mw.ConfigAuth != nil {
+		router.GET("/internal/config", mw.ConfigAuth, configHandler)
+	}
 
-	api := router.Group("/api")
-	{
+	groups := []*gin.RouterGroup{router.Group("/api/v1", chain(apiversion.Version(apiversion.V1), mw.Chaos)...)}
+	if mw.Deprecated != nil {
+		groups = append(groups, router.Group("/api", chain(apiversion.Version(apiversion.V1), mw.Deprecated, mw.Chaos)...))
+	}
+	for _, api := range groups {
 		api.GET("/inventory", handler.GetAllInventory)
+		api.GET("/inventory/export", handler.ExportInventory)
 		api.GET("/inventory/:product_id", handler.CheckStock)
-		api.POST("/inventory/reserve", handler.ReserveStock)
+		api.POST("/inventory/check", handler.CheckStockBatch)
+		api.POST("/inventory/reserve", chain(mw.ReservationRateLimit, mw.ReservationConcurrency, handler.ReserveStock)...)
+		api.POST("/inventory/release", handler.ReleaseStock)
+		api.POST("/inventory/commit", handler.CommitStock)
+		api.POST("/inventory/:product_id/adjust", handler.AdjustStock)
+		api.GET("/inventory/:product_id/movements", handler.GetMovements)
+		api.GET("/locations", handler.GetLocations)
+		api.GET("/reservations", handler.GetReservations)
+		api.GET("/reservations/order/:order_id", handler.GetOrderReservations)
+		api.GET("/orders/:order_id/reservation", handler.GetOrderReservations)
+	}
+
+	admin := router.Group("/admin")
+	admin.POST("/inventory/import", handler.ImportInventory)
+	admin.POST("/inventory/reconcile", handler.ReconcileInventory)
+	if debugEndpoints {
+		admin.GET("/chaos", chaosHandler.Get)
+		admin.PUT("/chaos", chaosHandler.Set)
+	}
+}
+
+// chain drops the middleware that is not configured.
+func chain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chained := make([]gin.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chained = append(chained, h)
+		}
 	}
+	return chained
 }
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"warehouse-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const movementColumns = `id, product_id, movement_type, quantity_delta, reserved_delta, quantity_after, reserved_after,
+	COALESCE(location, '') AS location, COALESCE(reservation_id, '') AS reservation_id, COALESCE(order_id, '') AS order_id,
+	COALESCE(reason, '') AS reason, actor, COALESCE(request_id, '') AS request_id, COALESCE(trace_id, '') AS trace_id, created_at`
+
+// MovementFilter selects a page of a product's movements, newest first.
+type MovementFilter struct {
+	ProductID    string
+	MovementType string
+	OrderID      string
+	Limit        int
+	Offset       int
+}
+
+// MovementService persists the inventory audit log.
+type MovementService struct {
+	db *sqlx.DB
+}
+
+func NewMovementService(db *sqlx.DB) *MovementService {
+	return &MovementService{db: db}
+}
+
+// Record appends a movement and sets its ID and creation time.
+func (s *MovementService) Record(ctx context.Context, m *models.InventoryMovement) error {
+	err := s.db.QueryRowxContext(ctx, `
+		INSERT INTO inventory_movements (product_id, movement_type, quantity_delta, reserved_delta,
+			quantity_after, reserved_after, location, reservation_id, order_id, reason, actor, request_id, trace_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11,
+			NULLIF($12, ''), NULLIF($13, ''))
+		RETURNING id, created_at`,
+		m.ProductID, m.MovementType, m.QuantityDelta, m.ReservedDelta, m.QuantityAfter, m.ReservedAfter,
+		m.Location, m.ReservationID, m.OrderID, m.Reason, m.Actor, m.RequestID, m.TraceID,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory movement: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the movements matching filter, newest first, and
+// how many match in total.
+func (s *MovementService) List(ctx context.Context, filter MovementFilter) ([]models.InventoryMovement, int64, error) {
+	conditions := []string{"product_id = $1"}
+	args := []interface{}{filter.ProductID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.MovementType != "" {
+		add("movement_type = $%d", filter.MovementType)
+	}
+	if filter.OrderID != "" {
+		add("order_id = $%d", filter.OrderID)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM inventory_movements WHERE `+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count inventory movements: %w", err)
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM inventory_movements WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		movementColumns, where, len(args)-1, len(args))
+
+	movements := []models.InventoryMovement{}
+	if err := s.db.SelectContext(ctx, &movements, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to list inventory movements: %w", err)
+	}
+	return movements, total, nil
+}
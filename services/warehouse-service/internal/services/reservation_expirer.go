@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// ReservationStore expires reservations whose hold on the stock ran out.
+type ReservationStore interface {
+	ExpireReservations(ctx context.Context) (int, error)
+}
+
+// ReservationExpirer periodically returns the stock of reservations that
+// were neither committed nor released before they expired, so stock held
+// for abandoned orders does not stay reserved forever.
+type ReservationExpirer struct {
+	store    ReservationStore
+	logger   logger.Logger
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+func NewReservationExpirer(log logger.Logger, store ReservationStore, interval time.Duration) *ReservationExpirer {
+	return &ReservationExpirer{
+		store:    store,
+		logger:   log,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (e *ReservationExpirer) Start(ctx context.Context) {
+	e.logger.Info("Starting reservation expirer",
+		logger.String("interval", e.interval.String()))
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("Stopping reservation expirer due to context cancellation")
+			return
+		case <-e.stopCh:
+			e.logger.Info("Reservation expirer stopped")
+			return
+		case <-ticker.C:
+			e.RunOnce(ctx)
+		}
+	}
+}
+
+func (e *ReservationExpirer) Stop() {
+	close(e.stopCh)
+}
+
+func (e *ReservationExpirer) RunOnce(ctx context.Context) {
+	expired, err := e.store.ExpireReservations(ctx)
+	if err != nil {
+		e.logger.Error("Failed to expire reservations, will retry",
+			logger.Err(err),
+			logger.Int("expired", expired))
+		return
+	}
+	if expired > 0 {
+		e.logger.Info("Expired stale reservations",
+			logger.Int("count", expired))
+	}
+}
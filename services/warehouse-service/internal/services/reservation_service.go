@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"warehouse-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const reservationColumns = `id, COALESCE(order_id, '') AS order_id, product_id, quantity, status, allocations,
+	expires_at, created_at, updated_at`
+
+// ReservationService persists one replica's reservations. Every replica
+// holds its own inventory, so the rows of the shared table are scoped to
+// the replica that made them.
+type ReservationService struct {
+	db      *sqlx.DB
+	replica string
+}
+
+func NewReservationService(db *sqlx.DB, replica string) *ReservationService {
+	return &ReservationService{db: db, replica: replica}
+}
+
+// Save inserts r or updates its quantity, status and allocations.
+func (s *ReservationService) Save(ctx context.Context, r *models.Reservation) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO reservations (id, replica, order_id, product_id, quantity, status, allocations,
+			expires_at, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE
+		SET quantity = EXCLUDED.quantity, status = EXCLUDED.status, allocations = EXCLUDED.allocations,
+			updated_at = EXCLUDED.updated_at`,
+		r.ID, s.replica, r.OrderID, r.ProductID, r.Quantity, r.Status, r.Allocations,
+		r.ExpiresAt, r.CreatedAt, r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save reservation %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Delete removes a reservation that was undone.
+func (s *ReservationService) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM reservations WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete reservation %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteSettled deletes the replica's reservations settled before cutoff and
+// returns how many it deleted.
+func (s *ReservationService) DeleteSettled(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM reservations
+		WHERE replica = $1 AND status <> $2 AND updated_at < $3`,
+		s.replica, models.ReservationStatusActive, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete settled reservations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// List returns the replica's reservations, oldest first.
+func (s *ReservationService) List(ctx context.Context) ([]models.Reservation, error) {
+	reservations := []models.Reservation{}
+	err := s.db.SelectContext(ctx, &reservations,
+		`SELECT `+reservationColumns+` FROM reservations WHERE replica = $1 ORDER BY created_at`, s.replica)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+	return reservations, nil
+}
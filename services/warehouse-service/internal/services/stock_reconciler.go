@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// ReconcileStore cross-checks reserved stock and corrects what it can.
+type ReconcileStore interface {
+	ReconcileStock(ctx context.Context) (found, corrected int, err error)
+}
+
+// StockReconciler periodically checks the reserved quantities against the
+// active reservations and the orders they are held for, since a failure
+// between the two services leaves their views of the stock diverged.
+type StockReconciler struct {
+	store    ReconcileStore
+	logger   logger.Logger
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+func NewStockReconciler(log logger.Logger, store ReconcileStore, interval time.Duration) *StockReconciler {
+	return &StockReconciler{
+		store:    store,
+		logger:   log,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (r *StockReconciler) Start(ctx context.Context) {
+	r.logger.Info("Starting stock reconciler",
+		logger.String("interval", r.interval.String()))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Stopping stock reconciler due to context cancellation")
+			return
+		case <-r.stopCh:
+			r.logger.Info("Stock reconciler stopped")
+			return
+		case <-ticker.C:
+			r.RunOnce(ctx)
+		}
+	}
+}
+
+func (r *StockReconciler) Stop() {
+	close(r.stopCh)
+}
+
+func (r *StockReconciler) RunOnce(ctx context.Context) {
+	found, corrected, err := r.store.ReconcileStock(ctx)
+	if err != nil {
+		r.logger.Error("Failed to reconcile stock, will retry",
+			logger.Err(err))
+		return
+	}
+	if found > 0 {
+		r.logger.Warn("Stock reconciliation found discrepancies",
+			logger.Int("found", found),
+			logger.Int("corrected", corrected))
+	}
+}
@@ -0,0 +1,15 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	sharedmetrics "observability-system/shared/metrics"
+	"warehouse-service/internal/metrics"
+)
+
+// TestMain registers the metrics the handlers record, as main does.
+func TestMain(m *testing.M) {
+	metrics.InitMetrics(sharedmetrics.Config{Service: "warehouse-service"})
+	os.Exit(m.Run())
+}
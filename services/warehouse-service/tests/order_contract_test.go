@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"observability-system/shared/contract"
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"warehouse-service/internal/handlers"
+	"warehouse-service/internal/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderContract is recorded by order-service's WarehouseClient tests.
+const orderContract = "../../../contracts/order-service-warehouse-service.json"
+
+// TestOrderServiceContract verifies that warehouse-service still answers
+// what order-service's WarehouseClient relies on. Every interaction of the
+// contract is sent through the service's routes, after setting up the
+// provider state it names.
+func TestOrderServiceContract(t *testing.T) {
+	c, err := contract.Load(orderContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "warehouse-service", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewInventoryHandler(log)
+	// Announced reservations answer with the ID of their event.
+	h.SetOutbox(&slowOutbox{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, log, "warehouse-service", h, nil, false, nil,
+		ops.NewReporter("warehouse-service", log),
+		health.NewProber("warehouse-service", log, time.Second),
+		sharedmetrics.NewRegistry(sharedmetrics.Config{Service: "warehouse-service"}),
+		routes.Middleware{})
+
+	// stockLaptops leaves PROD-001 as the only product, with 50 units
+	// available and nothing reserved.
+	stockLaptops := func(t *testing.T) {
+		// The import keeps reservations, so release those of earlier
+		// interactions first; the product may not exist yet.
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/inventory/release",
+			strings.NewReader(`{"product_id":"PROD-001","quantity":1073741824}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		snapshot := &handlers.InventorySnapshot{Products: []handlers.SnapshotProduct{{
+			ProductID: "PROD-001",
+			Name:      "Laptop",
+			Locations: []handlers.SnapshotLocation{{Location: "WH-WEST", Quantity: 50}},
+		}}}
+		if _, err := h.ImportSnapshot(context.Background(), snapshot, "contract-test", handlers.ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states := map[string]func(t *testing.T){
+		"":                                stockLaptops,
+		"PROD-001 has 50 units available": stockLaptops,
+		"PROD-404 does not exist":         stockLaptops,
+		"ORD-CONTRACT-1 holds 2 units of PROD-001": func(t *testing.T) {
+			stockLaptops(t)
+			serve(t, router, http.MethodPost, "/api/v1/inventory/reserve",
+				`{"product_id":"PROD-001","quantity":2,"order_id":"ORD-CONTRACT-1"}`)
+		},
+	}
+
+	for _, interaction := range c.Interactions {
+		t.Run(interaction.Description, func(t *testing.T) {
+			setUp, ok := states[interaction.State]
+			if !ok {
+				t.Fatalf("no set-up for provider state %q", interaction.State)
+			}
+			setUp(t)
+			if err := interaction.Verify(router); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
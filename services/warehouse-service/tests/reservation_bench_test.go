@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"warehouse-service/internal/handlers"
+	"warehouse-service/internal/models"
+	"warehouse-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowOutbox stands in for the Postgres outbox, taking latency per event.
+type slowOutbox struct {
+	outboxinbox.OutboxStore
+	latency time.Duration
+	saved   atomic.Int64
+}
+
+func (o *slowOutbox) SaveWithOptions(ctx context.Context, eventType string, payload interface{}, opts outboxinbox.SaveOptions) (string, error) {
+	time.Sleep(o.latency)
+	return fmt.Sprintf("%d", o.saved.Add(1)), nil
+}
+
+// slowMovements stands in for the inventory audit log, taking latency per
+// movement.
+type slowMovements struct {
+	latency time.Duration
+}
+
+func (m *slowMovements) Record(ctx context.Context, movement *models.InventoryMovement) error {
+	time.Sleep(m.latency)
+	return nil
+}
+
+func (m *slowMovements) List(ctx context.Context, filter services.MovementFilter) ([]models.InventoryMovement, int64, error) {
+	return nil, 0, nil
+}
+
+// newReservationRouter serves the inventory endpoints over products
+// BENCH-000 onwards, stocked deep enough never to run out, with outbox and
+// audit log writes taking latency.
+func newReservationRouter(b *testing.B, products int, latency time.Duration) *gin.Engine {
+	b.Helper()
+
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "warehouse-service", Environment: "benchmark", Level: logger.FatalLevel})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	h := handlers.NewInventoryHandler(log)
+	h.SetOutbox(&slowOutbox{latency: latency})
+	h.SetMovementStore(&slowMovements{latency: latency})
+
+	snapshot := &handlers.InventorySnapshot{}
+	for i := 0; i < products; i++ {
+		snapshot.Products = append(snapshot.Products, handlers.SnapshotProduct{
+			ProductID: fmt.Sprintf("BENCH-%03d", i),
+			Name:      "Benchmark product",
+			Locations: []handlers.SnapshotLocation{
+				{Location: "WH-WEST", Quantity: 1 << 30},
+				{Location: "WH-EAST", Quantity: 1 << 30},
+			},
+		})
+	}
+	if _, err := h.ImportSnapshot(context.Background(), snapshot, "benchmark", handlers.ImportOptions{}); err != nil {
+		b.Fatal(err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/api/inventory/:product_id", h.CheckStock)
+	router.POST("/api/inventory/reserve", h.ReserveStock)
+	router.POST("/api/inventory/release", h.ReleaseStock)
+
+	// The inventory is shared by the whole package, and the next benchmark's
+	// import refuses to drop products with stock still reserved.
+	b.Cleanup(func() {
+		for _, p := range snapshot.Products {
+			serve(b, router, http.MethodPost, "/api/inventory/release",
+				fmt.Sprintf(`{"product_id":%q,"quantity":%d}`, p.ProductID, 1<<31))
+		}
+	})
+	return router
+}
+
+func serve(tb testing.TB, router http.Handler, method, path, body string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		tb.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+	}
+}
+
+// BenchmarkReserveStock measures reservation throughput under contention:
+// every goroutine reserving one hot product, or spreading over many, with
+// the outbox and audit log answering instantly or after a millisecond as a
+// database would. Compare ns/op across the cases; on a hot product the
+// cost should stay close to the in-memory work alone, since the storage
+// latency is paid outside the product's lock.
+func BenchmarkReserveStock(b *testing.B) {
+	for _, latency := range []time.Duration{0, time.Millisecond} {
+		for _, products := range []int{1, 64} {
+			name := fmt.Sprintf("products=%d/latency=%s", products, latency)
+			b.Run(name, func(b *testing.B) {
+				router := newReservationRouter(b, products, latency)
+				var orders atomic.Int64
+
+				b.SetParallelism(16)
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						n := orders.Add(1)
+						body := fmt.Sprintf(`{"product_id":"BENCH-%03d","quantity":1,"order_id":"ORD-%d","announce":true}`, n%int64(products), n)
+						serve(b, router, http.MethodPost, "/api/inventory/reserve", body)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkReserveReleaseWithReads mixes reservations, their release and
+// stock checks of a single hot product, as a flash sale would.
+func BenchmarkReserveReleaseWithReads(b *testing.B) {
+	router := newReservationRouter(b, 1, 0)
+	var orders atomic.Int64
+
+	b.SetParallelism(16)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := orders.Add(1)
+			switch n % 4 {
+			case 0, 1:
+				serve(b, router, http.MethodGet, "/api/inventory/BENCH-000", "")
+			case 2:
+				serve(b, router, http.MethodPost, "/api/inventory/reserve",
+					fmt.Sprintf(`{"product_id":"BENCH-000","quantity":1,"order_id":"ORD-%d"}`, n))
+			case 3:
+				serve(b, router, http.MethodPost, "/api/inventory/release",
+					fmt.Sprintf(`{"product_id":"BENCH-000","quantity":1,"order_id":"ORD-%d"}`, n-1))
+			}
+		}
+	})
+}
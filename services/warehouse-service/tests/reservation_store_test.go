@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"observability-system/shared/health"
+	"observability-system/shared/logger"
+	sharedmetrics "observability-system/shared/metrics"
+	"observability-system/shared/ops"
+	"warehouse-service/internal/handlers"
+	"warehouse-service/internal/models"
+	"warehouse-service/internal/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryReservations stands in for the reservations table.
+type memoryReservations struct {
+	mu   sync.Mutex
+	rows map[string]models.Reservation
+}
+
+func (s *memoryReservations) Save(ctx context.Context, r *models.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := *r
+	row.Allocations = append(models.Allocations(nil), r.Allocations...)
+	s.rows[r.ID] = row
+	return nil
+}
+
+func (s *memoryReservations) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rows, id)
+	return nil
+}
+
+func (s *memoryReservations) DeleteSettled(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for id, r := range s.rows {
+		if r.Status != models.ReservationStatusActive && r.UpdatedAt.Before(cutoff) {
+			delete(s.rows, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryReservations) List(ctx context.Context) ([]models.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]models.Reservation, 0, len(s.rows))
+	for _, r := range s.rows {
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+func (s *memoryReservations) row(t *testing.T, orderID string) models.Reservation {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rows {
+		if r.OrderID == orderID {
+			return r
+		}
+	}
+	t.Fatalf("no stored reservation for order %s", orderID)
+	return models.Reservation{}
+}
+
+// TestReservationsSurviveRestart stores reservations through the handler
+// and loads them onto a freshly imported product, as a restarted replica
+// does.
+func TestReservationsSurviveRestart(t *testing.T) {
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "warehouse-service", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryReservations{rows: map[string]models.Reservation{}}
+	h := handlers.NewInventoryHandler(log)
+	h.SetReservationStore(store)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, log, "warehouse-service", h, nil, false, nil,
+		ops.NewReporter("warehouse-service", log),
+		health.NewProber("warehouse-service", log, time.Second),
+		sharedmetrics.NewRegistry(sharedmetrics.Config{Service: "warehouse-service"}),
+		routes.Middleware{})
+
+	ctx := context.Background()
+	stock := func(productID string) {
+		snapshot := &handlers.InventorySnapshot{Products: []handlers.SnapshotProduct{{
+			ProductID: productID,
+			Name:      "Monitor",
+			Locations: []handlers.SnapshotLocation{{Location: "WH-WEST", Quantity: 10}},
+		}}}
+		if _, err := h.ImportSnapshot(ctx, snapshot, "test", handlers.ImportOptions{Mode: handlers.ImportUpsert}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stock("STORE-001")
+	// Other tests replace the inventory, which products holding stock would
+	// refuse.
+	t.Cleanup(func() {
+		serve(t, router, http.MethodPost, "/api/v1/inventory/release",
+			`{"product_id":"STORE-001","quantity":1073741824}`)
+	})
+
+	serve(t, router, http.MethodPost, "/api/v1/inventory/reserve",
+		`{"product_id":"STORE-001","quantity":3,"order_id":"ORD-STORE-1"}`)
+	serve(t, router, http.MethodPost, "/api/v1/inventory/reserve",
+		`{"product_id":"STORE-001","quantity":2,"order_id":"ORD-STORE-2"}`)
+	if r := store.row(t, "ORD-STORE-1"); r.Status != models.ReservationStatusActive || r.Quantity != 3 || len(r.Allocations) != 1 {
+		t.Fatalf("stored reservation %+v, want 3 active units at one location", r)
+	}
+
+	serve(t, router, http.MethodPost, "/api/v1/inventory/release",
+		`{"product_id":"STORE-001","quantity":2,"order_id":"ORD-STORE-2"}`)
+	if r := store.row(t, "ORD-STORE-2"); r.Status != models.ReservationStatusReleased {
+		t.Fatalf("released reservation stored as %s", r.Status)
+	}
+
+	// The restarted replica imports its inventory anew, here under another
+	// product ID, and loads the stored reservations onto it.
+	store.mu.Lock()
+	for id, r := range store.rows {
+		r.ProductID = "STORE-002"
+		store.rows[id] = r
+	}
+	store.mu.Unlock()
+	stock("STORE-002")
+
+	loaded, err := h.LoadReservations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 {
+		t.Errorf("loaded %d reservations, want 2", loaded)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/inventory/STORE-002", nil))
+	var product struct {
+		Reserved  int `json:"reserved"`
+		Available int `json:"available"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &product); err != nil {
+		t.Fatal(err)
+	}
+	if product.Reserved != 3 || product.Available != 7 {
+		t.Errorf("got reserved %d, available %d, want only the active reservation's 3 held", product.Reserved, product.Available)
+	}
+
+	// The order's reservation is found again, so a release by order ID
+	// returns its stock.
+	serve(t, router, http.MethodPost, "/api/v1/inventory/release",
+		`{"product_id":"STORE-002","quantity":3,"order_id":"ORD-STORE-1"}`)
+	if r := store.row(t, "ORD-STORE-1"); r.Status != models.ReservationStatusReleased {
+		t.Errorf("reservation of ORD-STORE-1 stored as %s after its release", r.Status)
+	}
+}
+
+// TestReserveIsIdempotentByOrder retries a reservation for an order, as
+// order-service's stock reconciler does after failing to save the order.
+func TestReserveIsIdempotentByOrder(t *testing.T) {
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "warehouse-service", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewInventoryHandler(log)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, log, "warehouse-service", h, nil, false, nil,
+		ops.NewReporter("warehouse-service", log),
+		health.NewProber("warehouse-service", log, time.Second),
+		sharedmetrics.NewRegistry(sharedmetrics.Config{Service: "warehouse-service"}),
+		routes.Middleware{})
+
+	snapshot := &handlers.InventorySnapshot{Products: []handlers.SnapshotProduct{{
+		ProductID: "RETRY-001",
+		Name:      "Keyboard",
+		Locations: []handlers.SnapshotLocation{{Location: "WH-EAST", Quantity: 10}},
+	}}}
+	if _, err := h.ImportSnapshot(context.Background(), snapshot, "test", handlers.ImportOptions{Mode: handlers.ImportUpsert}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		serve(t, router, http.MethodPost, "/api/v1/inventory/release",
+			`{"product_id":"RETRY-001","quantity":1073741824}`)
+	})
+
+	reserve := func(quantity string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/inventory/reserve",
+			strings.NewReader(`{"product_id":"RETRY-001","quantity":`+quantity+`,"order_id":"ORD-RETRY-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	_, first := reserve("4")
+	code, retried := reserve("4")
+	if code != http.StatusOK || retried["reservation_id"] != first["reservation_id"] || retried["new_available"] != float64(6) {
+		t.Errorf("retry answered %d %v, want the first reservation %v with 6 available", code, retried, first["reservation_id"])
+	}
+	if code, _ := reserve("5"); code != http.StatusConflict {
+		t.Errorf("retry for another quantity answered %d, want 409", code)
+	}
+}
@@ -0,0 +1,86 @@
+// Package apiversion tags responses of versioned API groups with their
+// version and marks the unversioned aliases kept for existing clients as
+// deprecated, so response shapes can evolve behind a new version without
+// breaking those clients.
+package apiversion
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	V1 = "v1"
+
+	// HeaderVersion carries the API version that served a response.
+	HeaderVersion = "API-Version"
+
+	contextKey = "api_version"
+)
+
+var DeprecatedRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "api_deprecated_requests_total",
+		Help: "Total number of requests served through deprecated unversioned routes",
+	},
+	[]string{"method", "route"},
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{DeprecatedRequestsTotal}
+}
+
+// Version records version as the request's API version and returns it in
+// the API-Version response header.
+func Version(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, version)
+		c.Header(HeaderVersion, version)
+		c.Next()
+	}
+}
+
+// FromContext returns the API version serving the request, or "" outside a
+// versioned group.
+func FromContext(c *gin.Context) string {
+	return c.GetString(contextKey)
+}
+
+// Deprecation describes the unversioned aliases of a versioned group.
+type Deprecation struct {
+	// Prefix is the unversioned path prefix, e.g. "/api", and Successor the
+	// versioned one replacing it, e.g. "/api/v1".
+	Prefix    string
+	Successor string
+	// Sunset is when the aliases will be removed; zero leaves it open.
+	Sunset time.Time
+}
+
+// Deprecated marks responses of the aliases with the Deprecation and Sunset
+// (RFC 8594) headers and links the successor route. Requests are
+// counted per route, so the aliases can be removed once nobody calls them.
+func Deprecated(d Deprecation) gin.HandlerFunc {
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		if path := c.Request.URL.Path; strings.HasPrefix(path, d.Prefix) {
+			c.Header("Link", "<"+d.Successor+strings.TrimPrefix(path, d.Prefix)+`>; rel="successor-version"`)
+		}
+
+		DeprecatedRequestsTotal.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+		c.Next()
+	}
+}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAKey *rsa.PrivateKey
+	testECKey  *ecdsa.PrivateKey
+	testNow    = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func init() {
+	var err error
+	if testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testECKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+// keyServer serves a JWKS whose keys can be changed while it runs, and
+// counts how often it was fetched.
+type keyServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu     sync.Mutex
+	keys   []map[string]string
+	status int
+}
+
+func newKeyServer(t *testing.T) *keyServer {
+	s := &keyServer{status: http.StatusOK}
+	s.setKeys(rsaJWK("rsa-1", &testRSAKey.PublicKey), ecJWK("ec-1", &testECKey.PublicKey))
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *keyServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *keyServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kid": kid,
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kid": kid,
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// signToken signs claims with key, which is an *rsa.PrivateKey for RS256,
+// an *ecdsa.PrivateKey for ES256 and ignored for any other algorithm.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest.Sum(nil)); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// validClaims are accepted by testVerifier at testNow.
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://id.example.com",
+		"aud": "orders",
+		"iat": testNow.Add(-time.Minute).Unix(),
+		"nbf": testNow.Add(-time.Minute).Unix(),
+		"exp": testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func testVerifier(url string) *Verifier {
+	v := NewVerifier(Config{
+		JWKSURL:  url,
+		Issuer:   "https://id.example.com",
+		Audience: "orders",
+		Leeway:   30 * time.Second,
+	})
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	server := newKeyServer(t)
+	verifier := testVerifier(server.URL)
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	cases := []struct {
+		name  string
+		token func(t *testing.T) string
+		want  string
+	}{
+		{
+			name:  "RS256",
+			token: func(t *testing.T) string { return signToken(t, "RS256", "rsa-1", testRSAKey, validClaims()) },
+		},
+		{
+			name:  "ES256",
+			token: func(t *testing.T) string { return signToken(t, "ES256", "ec-1", testECKey, validClaims()) },
+		},
+		{
+			name: "audience list",
+			token: func(t *testing.T) string {
+				return signToken(t, "RS256", "rsa-1", testRSAKey, with("aud", []string{"billing", "orders"}))
+			},
+		},
+		{
+			name: "expired within leeway",
+			token: func(t *testing.T) string {
+				return signToken(t, "RS256", "rsa-1", testRSAKey, with("exp", testNow.Add(-10*time.Second).Unix()))
+			},
+		},
+		{
+			name: "RS256 with an EC key",
+			token: func(t *testing.T) string {
+				token := signToken(t, "ES256", "ec-1", testECKey, validClaims())
+				return retarget(t, token, "RS256", "ec-1")
+			},
+			want: "key type does not match algorithm",
+		},
+		{
+			name: "ES256 with an RSA key",
+			token: func(t *testing.T) string {
+				token := signToken(t, "RS256", "rsa-1", testRSAKey, validClaims())
+				return retarget(t, token, "ES256", "rsa-1")
+			},
+			want: "key type does not match algorithm",
+		},
+		{
+			name:  "alg none",
+			token: func(t *testing.T) string { return signToken(t, "none", "rsa-1", nil, validClaims()) },
+			want:  `unsupported algorithm "none"`,
+		},
+		{
+			name:  "HS256",
+			token: func(t *testing.T) string { return signToken(t, "HS256", "rsa-1", nil, validClaims()) },
+			want:  `unsupported algorithm "HS256"`,
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T) string {
+				token := strings.Split(signToken(t, "RS256", "rsa-1", testRSAKey, validClaims()), ".")
+				forged := strings.Split(signToken(t, "RS256", "rsa-1", testRSAKey, with("sub", "admin")), ".")
+				return token[0] + "." + forged[1] + "." + token[2]
+			},
+			want: "verification error",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return signToken(t, "RS256", "rsa-1", testRSAKey, with("exp", testNow.Add(-time.Minute).Unix()))
+			},
+			want: "token expired",
+		},
+		{
+			name:  "missing exp",
+			token: func(t *testing.T) string { return signToken(t, "RS256", "rsa-1", testRSAKey, with("exp", nil)) },
+			want:  "missing exp claim",
+		},
+		{
+			name: "not valid yet",
+			token: func(t *testing.T) string {
+				return signToken(t, "ES256", "ec-1", testECKey, with("nbf", testNow.Add(time.Minute).Unix()))
+			},
+			want: "token not valid yet",
+		},
+		{
+			name: "wrong issuer",
+			token: func(t *testing.T) string {
+				return signToken(t, "RS256", "rsa-1", testRSAKey, with("iss", "https://evil.example.com"))
+			},
+			want: "unexpected issuer",
+		},
+		{
+			name:  "wrong audience",
+			token: func(t *testing.T) string { return signToken(t, "RS256", "rsa-1", testRSAKey, with("aud", "billing")) },
+			want:  "unexpected audience",
+		},
+		{
+			name:  "missing subject",
+			token: func(t *testing.T) string { return signToken(t, "RS256", "rsa-1", testRSAKey, with("sub", nil)) },
+			want:  "missing sub claim",
+		},
+		{
+			name:  "unknown kid",
+			token: func(t *testing.T) string { return signToken(t, "RS256", "rsa-9", testRSAKey, validClaims()) },
+			want:  `unknown signing key: "rsa-9"`,
+		},
+		{
+			name:  "malformed",
+			token: func(t *testing.T) string { return "not-a-token" },
+			want:  "malformed token",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tc.token(t))
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("Verify failed: %v", err)
+				}
+				if claims.UserID != "user-1" || claims.Raw["iss"] != "https://id.example.com" {
+					t.Errorf("Verify = %+v, want the claims of user-1", claims)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Verify = %v, want an invalid token error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// retarget replaces the algorithm and key id in token's header and keeps
+// its claims and signature.
+func retarget(t *testing.T, token, alg, kid string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	return base64.RawURLEncoding.EncodeToString(b) + "." + parts[1] + "." + parts[2]
+}
+
+func TestJWKSRefreshesUnknownKeysAtMostEveryInterval(t *testing.T) {
+	server := newKeyServer(t)
+	keys := NewJWKS(server.URL, time.Hour)
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "rsa-1"); err != nil {
+		t.Fatal(err)
+	}
+	// Made-up key ids do not refetch the set within the refresh interval.
+	for i := 0; i < 5; i++ {
+		if _, err := keys.Key(ctx, "made-up"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Key(made-up) = %v, want ErrUnknownKey", err)
+		}
+	}
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want once", n)
+	}
+
+	// A rotated key is picked up once the interval has passed.
+	server.setKeys(rsaJWK("rsa-2", &testRSAKey.PublicKey))
+	if _, err := keys.Key(ctx, "rsa-2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Key(rsa-2) within the interval = %v, want ErrUnknownKey", err)
+	}
+	keys.lastAttempt = time.Now().Add(-minJWKSRefresh)
+	if _, err := keys.Key(ctx, "rsa-2"); err != nil {
+		t.Fatalf("Key(rsa-2) after the interval = %v", err)
+	}
+	if n := server.fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want twice", n)
+	}
+}
+
+func TestJWKSServesStaleKeysWhileProviderIsDown(t *testing.T) {
+	server := newKeyServer(t)
+	keys := NewJWKS(server.URL, time.Minute)
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "ec-1"); err != nil {
+		t.Fatal(err)
+	}
+	server.setStatus(http.StatusBadGateway)
+	keys.fetchedAt = time.Now().Add(-2 * time.Minute)
+
+	if _, err := keys.Key(ctx, "ec-1"); err != nil {
+		t.Errorf("Key(ec-1) with an expired set = %v, want the stale key", err)
+	}
+	if n := server.fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want twice", n)
+	}
+}
+
+func TestVerifyWithoutKeySet(t *testing.T) {
+	server := newKeyServer(t)
+	server.setStatus(http.StatusInternalServerError)
+	verifier := testVerifier(server.URL)
+
+	// The token may be fine, so a missing key set is not an invalid token.
+	_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", testRSAKey, validClaims()))
+	if err == nil || errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("Verify = %v, want the JWKS fetch error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+	"observability-system/shared/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultPublicPaths are served without a token.
+var DefaultPublicPaths = []string{"/health", "/live", "/ready", "/metrics", "/openapi.json", "/docs"}
+
+const claimsKey = "auth_claims"
+
+// Middleware requires a valid bearer token on every request except those for
+// publicPaths. A public path matches exactly, or as a prefix when it ends in
+// "*", e.g. "/internal/*". The token's user id is added to the request
+// context, where logger.GetUserID and the context-aware log methods pick it
+// up.
+func Middleware(verifier *Verifier, log logger.Logger, publicPaths []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublic(c.Request.URL.Path, publicPaths) {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Missing bearer token"))
+			return
+		}
+
+		claims, err := verifier.Verify(ctx, token)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidToken) {
+				// The key set could not be fetched; the token may be fine.
+				log.ErrorCtx(ctx, "Failed to verify token", logger.Err(err))
+				problem.Abort(c, problem.New(http.StatusServiceUnavailable, problem.CodeDependencyUnavailable, "Token verification is unavailable"))
+				return
+			}
+			log.WarnCtx(ctx, "Rejected request with invalid token", logger.Err(err))
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, err.Error()))
+			return
+		}
+
+		ctx = logger.WithUserID(ctx, claims.UserID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("user_id", claims.UserID)
+		c.Set(claimsKey, claims)
+
+		tracing.AddSpanAttributes(ctx, attribute.String("enduser.id", claims.UserID))
+
+		c.Next()
+	}
+}
+
+// GetClaims returns the claims of the authenticated request, or nil for
+// public paths.
+func GetClaims(c *gin.Context) *Claims {
+	if v, ok := c.Get(claimsKey); ok {
+		if claims, ok := v.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+func isPublic(path string, publicPaths []string) bool {
+	for _, p := range publicPaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
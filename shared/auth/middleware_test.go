@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"observability-system/shared/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := newKeyServer(t)
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "auth-test", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRouter := func(verifier *Verifier) *gin.Engine {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(Middleware(verifier, log, append([]string{"/internal/*"}, DefaultPublicPaths...)))
+		handler := func(c *gin.Context) {
+			user := ""
+			if claims := GetClaims(c); claims != nil {
+				user = claims.UserID + " " + logger.GetUserID(c.Request.Context())
+			}
+			c.String(http.StatusOK, user)
+		}
+		router.GET("/health", handler)
+		router.GET("/internal/stats", handler)
+		router.GET("/internalx", handler)
+		router.GET("/api/v1/orders", handler)
+		return router
+	}
+	router := newRouter(testVerifier(server.URL))
+	token := signToken(t, "RS256", "rsa-1", testRSAKey, validClaims())
+
+	cases := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantBody      string
+		wantChallenge string
+	}{
+		{name: "public path", path: "/health", wantStatus: http.StatusOK},
+		{name: "public prefix", path: "/internal/stats", wantStatus: http.StatusOK},
+		{name: "public prefix needs its slash", path: "/internalx", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "missing token", path: "/api/v1/orders", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "other scheme", path: "/api/v1/orders", authorization: "Basic dXNlcjpwdw==", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "valid token", path: "/api/v1/orders", authorization: "Bearer " + token, wantStatus: http.StatusOK, wantBody: "user-1 user-1"},
+		{name: "lowercase scheme", path: "/api/v1/orders", authorization: "bearer " + token, wantStatus: http.StatusOK, wantBody: "user-1 user-1"},
+		{name: "token on a public path", path: "/health", authorization: "Bearer " + token, wantStatus: http.StatusOK},
+		{
+			name:          "invalid token",
+			path:          "/api/v1/orders",
+			authorization: "Bearer " + signToken(t, "none", "rsa-1", nil, validClaims()),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer error="invalid_token"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tc.wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tc.wantChallenge)
+			}
+			if tc.wantStatus == http.StatusOK && w.Body.String() != tc.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tc.wantBody)
+			}
+		})
+	}
+
+	t.Run("key set unavailable", func(t *testing.T) {
+		down := newKeyServer(t)
+		down.setStatus(http.StatusServiceUnavailable)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		newRouter(testVerifier(down.URL)).ServeHTTP(w, req)
+
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "Token verification is unavailable") {
+			t.Errorf("got %d %s, want 503", w.Code, w.Body)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"observability-system/shared/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	log, err := logger.NewZapLogger(logger.Config{ServiceName: "auth-test", Environment: "test", Level: logger.FatalLevel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const window = 5 * time.Minute
+	body := `{"event":"shipment.delivered"}`
+
+	type request struct {
+		sender    string
+		secret    string
+		timestamp time.Time
+		body      string
+	}
+	signed := func(r request) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(r.body))
+		req.Header.Set(HeaderSenderID, r.sender)
+		req.Header.Set(HeaderSignatureTimestamp, strconv.FormatInt(r.timestamp.Unix(), 10))
+		req.Header.Set(HeaderSignature, Sign(r.secret, r.timestamp.Unix(), []byte(r.body)))
+		return req
+	}
+
+	cases := []struct {
+		name string
+		// requests are sent in order; all but the last must be accepted.
+		requests   []request
+		wantReason string
+	}{
+		{
+			name:     "valid signature",
+			requests: []request{{"carrier", "s3cret", testNow, body}},
+		},
+		{
+			name:     "timestamp within the window",
+			requests: []request{{"carrier", "s3cret", testNow.Add(-window + time.Second), body}},
+		},
+		{
+			name:       "wrong secret",
+			requests:   []request{{"carrier", "guessed", testNow, body}},
+			wantReason: "invalid_signature",
+		},
+		{
+			name:       "unknown sender",
+			requests:   []request{{"stranger", "s3cret", testNow, body}},
+			wantReason: "unknown_sender",
+		},
+		{
+			name:       "stale timestamp",
+			requests:   []request{{"carrier", "s3cret", testNow.Add(-window - time.Second), body}},
+			wantReason: "stale_timestamp",
+		},
+		{
+			name:       "future timestamp",
+			requests:   []request{{"carrier", "s3cret", testNow.Add(window + time.Second), body}},
+			wantReason: "stale_timestamp",
+		},
+		{
+			name: "replay inside the window",
+			requests: []request{
+				{"carrier", "s3cret", testNow, body},
+				{"carrier", "s3cret", testNow, body},
+			},
+			wantReason: "replayed",
+		},
+		{
+			name: "same timestamp, other body",
+			requests: []request{
+				{"carrier", "s3cret", testNow, body},
+				{"carrier", "s3cret", testNow, `{"event":"shipment.lost"}`},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier := NewSignatureVerifier(map[string]string{"carrier": "s3cret"}, window)
+			verifier.now = func() time.Time { return testNow }
+			router := signedRouter(verifier, log)
+
+			for i, r := range tc.requests {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, signed(r))
+
+				if i < len(tc.requests)-1 || tc.wantReason == "" {
+					if w.Code != http.StatusOK || w.Body.String() != "carrier "+r.body {
+						t.Fatalf("request %d answered %d %s, want it accepted", i, w.Code, w.Body)
+					}
+					continue
+				}
+				if w.Code != http.StatusUnauthorized || rejectReason(t, w) != tc.wantReason {
+					t.Errorf("request %d answered %d %s, want 401 %s", i, w.Code, w.Body, tc.wantReason)
+				}
+			}
+		})
+	}
+
+	t.Run("missing headers", func(t *testing.T) {
+		verifier := NewSignatureVerifier(map[string]string{"carrier": "s3cret"}, window)
+		w := httptest.NewRecorder()
+		signedRouter(verifier, log).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+		if w.Code != http.StatusUnauthorized || rejectReason(t, w) != "missing_signature" || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("answered %d %s, want 401 missing_signature with a challenge", w.Code, w.Body)
+		}
+	})
+
+	t.Run("expired cache entry", func(t *testing.T) {
+		verifier := NewSignatureVerifier(map[string]string{"carrier": "s3cret"}, window)
+		now := testNow
+		verifier.now = func() time.Time { return now }
+		router := signedRouter(verifier, log)
+
+		for _, at := range []time.Time{testNow, testNow.Add(3 * window)} {
+			now = at
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, signed(request{"carrier", "s3cret", at, body}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("request at %s answered %d %s", at, w.Code, w.Body)
+			}
+		}
+		// The first signature can no longer pass the window check, so it
+		// was dropped when the second one was recorded.
+		if len(verifier.seen) != 1 {
+			t.Errorf("%d signatures remembered, want 1", len(verifier.seen))
+		}
+	})
+}
+
+func signedRouter(verifier *SignatureVerifier, log logger.Logger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhooks", verifier.Middleware(log), func(c *gin.Context) {
+		// Handlers still read the body the middleware verified.
+		b, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, GetSender(c)+" "+string(b))
+	})
+	return router
+}
+
+func rejectReason(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var p struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem %s: %v", w.Body, err)
+	}
+	return p.Reason
+}
@@ -0,0 +1,249 @@
+// Package cache caches values in two tiers: a bounded in-memory tier in
+// each instance, in front of an optional Redis tier shared by all of them.
+// Concurrent misses of the same key share one load.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"observability-system/shared/redisclient"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// Tiers a lookup can be served from.
+const (
+	TierLocal = "local"
+	TierRedis = "redis"
+)
+
+// Config configures a Cache.
+type Config struct {
+	// Name labels the cache's metrics and spans, and prefixes its Redis
+	// keys.
+	Name string
+	// TTL is how long entries live; defaults to one minute.
+	TTL time.Duration
+	// LocalTTL caps how long the in-memory tier keeps an entry. Deletes do
+	// not reach the memory of other instances, so this bounds how long they
+	// may still serve an invalidated entry; defaults to TTL.
+	LocalTTL time.Duration
+	// MaxLocalEntries bounds the in-memory tier; defaults to 10000.
+	MaxLocalEntries int
+	// Redis adds the shared tier; nil keeps the cache in memory only.
+	Redis *redisclient.Client
+}
+
+// Cache is a two-tier cache of byte values. A nil *Cache caches nothing:
+// every lookup misses and GetOrLoad always loads.
+type Cache struct {
+	cfg    Config
+	local  *localTier
+	group  singleflight.Group
+	tracer trace.Tracer
+}
+
+func New(cfg Config) *Cache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.LocalTTL <= 0 || cfg.LocalTTL > cfg.TTL {
+		cfg.LocalTTL = cfg.TTL
+	}
+	if cfg.MaxLocalEntries <= 0 {
+		cfg.MaxLocalEntries = 10000
+	}
+	return &Cache{
+		cfg:    cfg,
+		local:  newLocalTier(cfg.MaxLocalEntries),
+		tracer: otel.Tracer("cache"),
+	}
+}
+
+// Get returns the value cached for key. Redis failures count as misses.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.get", key)
+	defer span.End()
+
+	value, tier := c.get(ctx, key)
+	recordLookup(span, c.cfg.Name, tier)
+	return value, tier != ""
+}
+
+// Set caches value for key in every tier.
+func (c *Cache) Set(ctx context.Context, key string, value []byte) {
+	if c == nil {
+		return
+	}
+	c.set(ctx, key, value)
+}
+
+// Delete invalidates keys in every tier. Loads of those keys already in
+// flight are not cached, since they may have read the old value.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if c == nil || len(keys) == 0 {
+		return nil
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.delete", keys[0])
+	defer span.End()
+	span.SetAttributes(attribute.Int("cache.keys", len(keys)))
+
+	for _, key := range keys {
+		c.local.delete(key)
+	}
+	InvalidationsTotal.WithLabelValues(c.cfg.Name).Add(float64(len(keys)))
+	LocalEntries.WithLabelValues(c.cfg.Name).Set(float64(c.local.len()))
+
+	if c.cfg.Redis == nil {
+		return nil
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, c.redisKey(key))
+	}
+	if _, err := c.cfg.Redis.Do(ctx, args...); err != nil {
+		ErrorsTotal.WithLabelValues(c.cfg.Name, "delete").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetOrLoad returns the value cached for key, or loads, caches and returns
+// it on a miss. Concurrent misses of key share one call to load, which
+// therefore runs without the caller's cancellation. Load errors are not
+// cached.
+func (c *Cache) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return load(ctx)
+	}
+
+	ctx, span := c.startSpan(ctx, "cache.get_or_load", key)
+	defer span.End()
+
+	if value, tier := c.get(ctx, key); tier != "" {
+		recordLookup(span, c.cfg.Name, tier)
+		return value, nil
+	}
+	recordLookup(span, c.cfg.Name, "")
+
+	value, err, shared := c.group.Do(key, func() (interface{}, error) {
+		loading := c.local.startLoad(key)
+		defer c.local.endLoad(key)
+
+		ctx := context.WithoutCancel(ctx)
+		start := time.Now()
+		value, err := load(ctx)
+		LoadDuration.WithLabelValues(c.cfg.Name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			ErrorsTotal.WithLabelValues(c.cfg.Name, "load").Inc()
+			return nil, err
+		}
+		if !loading.invalidated() {
+			c.set(ctx, key, value)
+		}
+		return value, nil
+	})
+	span.SetAttributes(attribute.Bool("cache.shared_load", shared))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+// Fetch is GetOrLoad for values stored as JSON.
+func Fetch[T any](ctx context.Context, c *Cache, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	raw, err := c.GetOrLoad(ctx, key, func(ctx context.Context) ([]byte, error) {
+		loaded, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(loaded)
+	})
+	if err != nil {
+		return value, err
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// An entry written by an older version of T; drop it and load.
+		c.Delete(ctx, key)
+		return load(ctx)
+	}
+	return value, nil
+}
+
+// get looks key up tier by tier, returning the tier that served it or ""
+// on a miss. Redis hits are copied into the in-memory tier.
+func (c *Cache) get(ctx context.Context, key string) ([]byte, string) {
+	if value, ok := c.local.get(key); ok {
+		return value, TierLocal
+	}
+	if c.cfg.Redis == nil {
+		return nil, ""
+	}
+
+	reply, err := c.cfg.Redis.Do(ctx, "GET", c.redisKey(key))
+	if err != nil {
+		ErrorsTotal.WithLabelValues(c.cfg.Name, "get").Inc()
+		trace.SpanFromContext(ctx).RecordError(err)
+		return nil, ""
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return nil, ""
+	}
+	value := []byte(s)
+	c.local.set(key, value, c.cfg.LocalTTL)
+	return value, TierRedis
+}
+
+func (c *Cache) set(ctx context.Context, key string, value []byte) {
+	c.local.set(key, value, c.cfg.LocalTTL)
+	LocalEntries.WithLabelValues(c.cfg.Name).Set(float64(c.local.len()))
+
+	if c.cfg.Redis == nil {
+		return
+	}
+	ttl := strconv.FormatInt(c.cfg.TTL.Milliseconds(), 10)
+	if _, err := c.cfg.Redis.Do(ctx, "SET", c.redisKey(key), string(value), "PX", ttl); err != nil {
+		ErrorsTotal.WithLabelValues(c.cfg.Name, "set").Inc()
+		trace.SpanFromContext(ctx).RecordError(err)
+	}
+}
+
+func (c *Cache) redisKey(key string) string {
+	return "cache:" + c.cfg.Name + ":" + key
+}
+
+func (c *Cache) startSpan(ctx context.Context, name, key string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("cache.name", c.cfg.Name),
+		attribute.String("cache.key", key),
+	))
+}
+
+// recordLookup counts a lookup served by tier, or a miss for "".
+func recordLookup(span trace.Span, name, tier string) {
+	result := "miss"
+	if tier != "" {
+		result = tier + "_hit"
+		span.SetAttributes(attribute.String("cache.tier", tier))
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", tier != ""))
+	LookupsTotal.WithLabelValues(name, result).Inc()
+}
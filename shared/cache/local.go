@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type localEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// load tracks a load in flight, so a delete meanwhile keeps its possibly
+// stale result out of the cache.
+type load struct {
+	mu    sync.Mutex
+	stale bool
+}
+
+func (l *load) invalidated() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stale
+}
+
+// localTier is the in-memory tier. Once full, expired entries are swept
+// and, if that frees nothing, an arbitrary entry is evicted.
+type localTier struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]localEntry
+	loads   map[string]*load
+}
+
+func newLocalTier(max int) *localTier {
+	return &localTier{
+		max:     max,
+		entries: make(map[string]localEntry),
+		loads:   make(map[string]*load),
+	}
+}
+
+func (t *localTier) get(key string) ([]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(t.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (t *localTier) set(key string, value []byte, ttl time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := t.entries[key]; !exists && len(t.entries) >= t.max {
+		for k, entry := range t.entries {
+			if now.After(entry.expires) {
+				delete(t.entries, k)
+			}
+		}
+		if len(t.entries) >= t.max {
+			for k := range t.entries {
+				delete(t.entries, k)
+				break
+			}
+		}
+	}
+	t.entries[key] = localEntry{value: value, expires: now.Add(ttl)}
+}
+
+func (t *localTier) delete(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+	if l, ok := t.loads[key]; ok {
+		l.mu.Lock()
+		l.stale = true
+		l.mu.Unlock()
+	}
+}
+
+func (t *localTier) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// startLoad registers a load of key. Loads of one key never overlap, since
+// they run inside the cache's singleflight group.
+func (t *localTier) startLoad(key string) *load {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l := &load{}
+	t.loads[key] = l
+	return l
+}
+
+func (t *localTier) endLoad(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.loads, key)
+}
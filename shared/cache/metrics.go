@@ -0,0 +1,58 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	LookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Total number of cache lookups, by cache and result (local_hit, redis_hit or miss)",
+		},
+		[]string{"cache", "result"},
+	)
+
+	ErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_errors_total",
+			Help: "Total number of failed cache operations, by cache and operation (get, set, delete or load)",
+		},
+		[]string{"cache", "operation"},
+	)
+
+	InvalidationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_invalidations_total",
+			Help: "Total number of keys invalidated, by cache",
+		},
+		[]string{"cache"},
+	)
+
+	LoadDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cache_load_duration_seconds",
+			Help:    "Duration of loading values missing from the cache, by cache",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"cache"},
+	)
+
+	LocalEntries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_local_entries",
+			Help: "Number of entries in the in-memory tier, by cache",
+		},
+		[]string{"cache"},
+	)
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		LookupsTotal,
+		ErrorsTotal,
+		InvalidationsTotal,
+		LoadDuration,
+		LocalEntries,
+	}
+}
@@ -0,0 +1,296 @@
+// Package chaos injects faults into a service so traces, metrics and logs
+// can be shown under failure: latency, errors, timeouts and dropped
+// responses, on HTTP routes (Middleware), outgoing calls (Inject and Drop)
+// and database operations (Connector). Rules are keyed by target and can be
+// changed at runtime through Handler.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AllTargets is the rule key applying to targets without a more specific
+// rule. A key ending in "*" applies to the targets it prefixes, e.g.
+// "db.*" or "POST /api/v1/*".
+const AllTargets = "*"
+
+// Faults, as counted in chaos_injections_total.
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultTimeout = "timeout"
+	FaultDrop    = "drop"
+)
+
+var (
+	// ErrInjected is returned for injected failures.
+	ErrInjected = errors.New("chaos: injected failure")
+	// ErrDropped is returned in place of the response of an operation that
+	// completed, but whose response was dropped.
+	ErrDropped = errors.New("chaos: response dropped")
+)
+
+// Rule describes the faults injected into a target. Latency is drawn
+// uniformly between the minimum and maximum and added to every operation;
+// errors and timeouts are then injected with the given probabilities.
+type Rule struct {
+	MinLatencyMs int     `json:"min_latency_ms"`
+	MaxLatencyMs int     `json:"max_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	TimeoutRate  float64 `json:"timeout_rate"`
+	// TimeoutMs is how long a simulated timeout hangs before failing.
+	TimeoutMs int `json:"timeout_ms"`
+	// DropRate is the probability that an operation takes effect but its
+	// response is lost: the connection of an HTTP request is closed
+	// unanswered, and calls and queries fail with ErrDropped.
+	DropRate float64 `json:"drop_rate"`
+	// StatusCode is the status of injected HTTP errors, from 500 to 599;
+	// defaults to 500.
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+// Config switches fault injection on and maps targets to rules.
+type Config struct {
+	Enabled bool            `json:"enabled"`
+	Rules   map[string]Rule `json:"rules"`
+}
+
+// Uniform applies rule to each of targets.
+func Uniform(enabled bool, targets []string, rule Rule) Config {
+	cfg := Config{Enabled: enabled, Rules: make(map[string]Rule, len(targets))}
+	for _, target := range targets {
+		cfg.Rules[target] = rule
+	}
+	return cfg
+}
+
+// Validate rejects rules whose latency range is inverted, whose rates are
+// not probabilities or whose key has a "*" other than at its end.
+func (cfg Config) Validate() error {
+	for key, rule := range cfg.Rules {
+		if key == "" || strings.Contains(strings.TrimSuffix(key, "*"), "*") {
+			return fmt.Errorf("invalid target %q: \"*\" may only end a target", key)
+		}
+		if rule.MinLatencyMs < 0 || rule.MaxLatencyMs < rule.MinLatencyMs {
+			return fmt.Errorf("%s: latency range must satisfy 0 <= min <= max", key)
+		}
+		for _, rate := range []float64{rule.ErrorRate, rule.TimeoutRate, rule.DropRate} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("%s: rates must be between 0 and 1", key)
+			}
+		}
+		if rule.TimeoutMs < 0 {
+			return fmt.Errorf("%s: timeout must not be negative", key)
+		}
+		if rule.StatusCode != 0 && (rule.StatusCode < 500 || rule.StatusCode > 599) {
+			return fmt.Errorf("%s: status code must be between 500 and 599", key)
+		}
+	}
+	return nil
+}
+
+// match returns the rule of target: its own, else the one of the longest
+// matching prefix key, else the AllTargets rule.
+func (cfg Config) match(target string) (Rule, bool) {
+	if rule, ok := cfg.Rules[target]; ok {
+		return rule, true
+	}
+	var best string
+	var found bool
+	var rule Rule
+	for key, r := range cfg.Rules {
+		prefix, ok := strings.CutSuffix(key, "*")
+		if !ok || !strings.HasPrefix(target, prefix) {
+			continue
+		}
+		if !found || len(key) > len(best) {
+			best, rule, found = key, r, true
+		}
+	}
+	return rule, found
+}
+
+// Injector injects the faults configured for each target. It is safe for
+// concurrent use and can be reconfigured at runtime. A nil *Injector
+// injects nothing.
+type Injector struct {
+	logger logger.Logger
+
+	// enabled mirrors cfg.Enabled, so operations skip mu while chaos is off.
+	enabled atomic.Bool
+	mu      sync.Mutex
+	cfg     Config
+	rand    *rand.Rand
+}
+
+func New(log logger.Logger, cfg Config) (*Injector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	in := &Injector{
+		logger: log,
+		cfg:    cfg,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	in.enabled.Store(cfg.Enabled)
+	return in, nil
+}
+
+// Config returns the current configuration.
+func (in *Injector) Config() Config {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.cfg
+}
+
+// SetConfig replaces the configuration; it applies to operations started
+// from then on.
+func (in *Injector) SetConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.cfg = cfg
+	in.enabled.Store(cfg.Enabled)
+	return nil
+}
+
+// Fault is a failure injected by Inject. It matches ErrInjected and, for
+// simulated timeouts, context.DeadlineExceeded.
+type Fault struct {
+	Kind string
+	// StatusCode is the status an HTTP handler responds with.
+	StatusCode int
+	// Timeout is how long a simulated timeout hung.
+	Timeout time.Duration
+}
+
+func (f *Fault) Error() string {
+	if f.Kind == FaultTimeout {
+		return fmt.Sprintf("chaos: simulated timeout after %s", f.Timeout)
+	}
+	return ErrInjected.Error()
+}
+
+func (f *Fault) Is(target error) bool {
+	return target == ErrInjected || (f.Kind == FaultTimeout && target == context.DeadlineExceeded)
+}
+
+// Inject applies target's rule ahead of an operation: it waits out the
+// injected latency, and returns a *Fault when the operation must fail
+// without running. It returns ctx's error if ctx is done while waiting.
+func (in *Injector) Inject(ctx context.Context, target string) error {
+	if in == nil || !in.enabled.Load() {
+		return nil
+	}
+
+	in.mu.Lock()
+	rule, ok := in.rule(target)
+	var latency time.Duration
+	var fail, hang bool
+	if ok {
+		latency = time.Duration(rule.MinLatencyMs) * time.Millisecond
+		if spread := rule.MaxLatencyMs - rule.MinLatencyMs; spread > 0 {
+			latency += time.Duration(in.rand.Intn(spread+1)) * time.Millisecond
+		}
+		hang = in.rand.Float64() < rule.TimeoutRate
+		fail = !hang && in.rand.Float64() < rule.ErrorRate
+	}
+	in.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if latency > 0 {
+		in.record(ctx, target, FaultLatency, logger.Duration("latency", latency))
+		if err := sleep(ctx, latency); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case hang:
+		timeout := time.Duration(rule.TimeoutMs) * time.Millisecond
+		in.record(ctx, target, FaultTimeout, logger.Duration("timeout", timeout))
+		if err := sleep(ctx, timeout); err != nil {
+			return err
+		}
+		return &Fault{Kind: FaultTimeout, StatusCode: http.StatusGatewayTimeout, Timeout: timeout}
+	case fail:
+		in.record(ctx, target, FaultError)
+		status := rule.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return &Fault{Kind: FaultError, StatusCode: status}
+	}
+	return nil
+}
+
+// Drop decides, after an operation on target completed, whether its
+// response is lost. It then returns ErrDropped, which the caller returns in
+// place of the response.
+func (in *Injector) Drop(ctx context.Context, target string) error {
+	if in == nil || !in.enabled.Load() {
+		return nil
+	}
+
+	in.mu.Lock()
+	rule, ok := in.rule(target)
+	drop := ok && in.rand.Float64() < rule.DropRate
+	in.mu.Unlock()
+	if !drop {
+		return nil
+	}
+
+	in.record(ctx, target, FaultDrop)
+	return ErrDropped
+}
+
+// rule returns target's rule while injection is enabled. The caller holds
+// mu.
+func (in *Injector) rule(target string) (Rule, bool) {
+	if !in.cfg.Enabled {
+		return Rule{}, false
+	}
+	return in.cfg.match(target)
+}
+
+func (in *Injector) record(ctx context.Context, target, fault string, fields ...logger.Field) {
+	InjectionsTotal.WithLabelValues(target, fault).Inc()
+	tracing.AddSpanAttributes(ctx,
+		attribute.Bool("chaos.injected", true),
+		attribute.String("chaos.target", target),
+		attribute.String("chaos.fault", fault),
+	)
+	in.logger.WarnCtx(ctx, "Injecting chaos",
+		append([]logger.Field{
+			logger.String("target", target),
+			logger.String("fault", fault),
+		}, fields...)...)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
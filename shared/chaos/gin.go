@@ -0,0 +1,120 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware injects faults into the routes it guards, targeted as
+// "METHOD /path" with the path as registered, e.g. "GET /api/v1/orders/:order_id".
+// Injected errors and timeouts answer with a problem instead of running the
+// handler; a dropped response runs the handler, then closes the connection
+// without answering. Register it on the API routes only, so probes,
+// /metrics and the chaos routes keep working whatever the rules.
+func (in *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if in == nil || c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		target := c.Request.Method + " " + c.FullPath()
+
+		if err := in.Inject(ctx, target); err != nil {
+			var fault *Fault
+			if !errors.As(err, &fault) {
+				// The client gave up while the latency was injected.
+				c.Abort()
+				return
+			}
+			code := problem.CodeInternal
+			if fault.Kind == FaultTimeout {
+				code = problem.CodeDependencyUnavailable
+			}
+			problem.Abort(c, problem.New(fault.StatusCode, code, "Injected by chaos mode").
+				With("chaos_fault", fault.Kind))
+			return
+		}
+
+		if in.Drop(ctx, target) == nil {
+			c.Next()
+			return
+		}
+
+		w := c.Writer
+		c.Writer = &discardWriter{ResponseWriter: w, status: http.StatusOK}
+		c.Next()
+		c.Writer = w
+
+		// Nothing has been sent yet, so hijacking hands over a clean
+		// connection to close. Without a hijackable connection, e.g. over
+		// HTTP/2, the discarded response leaves an empty one.
+		if conn, _, err := w.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// discardWriter swallows the response of a request whose response is
+// dropped; the handler still sees the status it set.
+type discardWriter struct {
+	gin.ResponseWriter
+	status int
+}
+
+func (w *discardWriter) WriteHeader(code int)              { w.status = code }
+func (w *discardWriter) WriteHeaderNow()                   {}
+func (w *discardWriter) Write(b []byte) (int, error)       { return len(b), nil }
+func (w *discardWriter) WriteString(s string) (int, error) { return len(s), nil }
+func (w *discardWriter) Status() int                       { return w.status }
+func (w *discardWriter) Flush()                            {}
+
+// Handler inspects and reconfigures an Injector at runtime.
+type Handler struct {
+	logger   logger.Logger
+	injector *Injector
+}
+
+func NewHandler(log logger.Logger, injector *Injector) *Handler {
+	return &Handler{
+		logger:   log,
+		injector: injector,
+	}
+}
+
+// Get responds with the current configuration.
+func (h *Handler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, h.injector.Config())
+}
+
+// Set replaces the configuration; it applies to operations started from
+// then on.
+func (h *Handler) Set(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var cfg Config
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		problem.Write(c, problem.ValidationFailed(err))
+		return
+	}
+	if err := h.injector.SetConfig(cfg); err != nil {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, err.Error()))
+		return
+	}
+
+	actor := logger.GetUserID(ctx)
+	if actor == "" {
+		actor = c.ClientIP()
+	}
+	h.logger.WarnCtx(ctx, "Chaos configuration changed",
+		logger.Bool("enabled", cfg.Enabled),
+		logger.Any("rules", cfg.Rules),
+		logger.String("actor", actor))
+
+	c.JSON(http.StatusOK, cfg)
+}
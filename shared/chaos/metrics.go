@@ -0,0 +1,17 @@
+package chaos
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var InjectionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "chaos_injections_total",
+		Help: "Total number of faults injected by chaos mode, by target and fault (latency, error, timeout or drop)",
+	},
+	[]string{"target", "fault"},
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{InjectionsTotal}
+}
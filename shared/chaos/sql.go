@@ -0,0 +1,120 @@
+package chaos
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Targets of database operations.
+const (
+	TargetDBQuery = "db.query"
+	TargetDBExec  = "db.exec"
+	TargetDBBegin = "db.begin"
+)
+
+// Connector wraps connector so the connections it opens inject the faults
+// of TargetDBQuery, TargetDBExec and TargetDBBegin, e.g. slow queries. A
+// dropped query or exec has run, possibly changing data, before it fails.
+// Pings are never affected, so readiness checks keep reporting the
+// database itself. With a nil injector connector is returned as is.
+func Connector(connector driver.Connector, injector *Injector) driver.Connector {
+	if injector == nil {
+		return connector
+	}
+	return &chaosConnector{Connector: connector, injector: injector}
+}
+
+type chaosConnector struct {
+	driver.Connector
+	injector *Injector
+}
+
+func (c *chaosConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosConn{Conn: conn, injector: c.injector}, nil
+}
+
+// chaosConn forwards to the driver's connection, skipping the optional
+// interfaces it lacks so database/sql falls back as it would without chaos.
+type chaosConn struct {
+	driver.Conn
+	injector *Injector
+}
+
+func (c *chaosConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.injector.Inject(ctx, TargetDBQuery); err != nil {
+		return nil, err
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.injector.Drop(ctx, TargetDBQuery); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (c *chaosConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.injector.Inject(ctx, TargetDBExec); err != nil {
+		return nil, err
+	}
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.injector.Drop(ctx, TargetDBExec); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *chaosConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.injector.Inject(ctx, TargetDBBegin); err != nil {
+		return nil, err
+	}
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *chaosConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *chaosConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *chaosConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *chaosConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
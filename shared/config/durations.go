@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DurationReader reads duration settings, which need a unit: "500ms", "5s",
+// "2m" or "1h30m". A bare number other than 0 is reported rather than read
+// as nanoseconds, so "SHUTDOWN_TIMEOUT=30" fails instead of allowing 30ns.
+type DurationReader struct {
+	lookup   func(setting string) string
+	problems []string
+	invalid  map[string]bool
+}
+
+// NewDurationReader creates a reader taking the raw value of each setting
+// from lookup, e.g. viper.GetString.
+func NewDurationReader(lookup func(setting string) string) *DurationReader {
+	return &DurationReader{lookup: lookup, invalid: map[string]bool{}}
+}
+
+// Get returns the duration of setting, or 0 when it is unset or cannot be
+// parsed; the latter is recorded as a problem.
+func (r *DurationReader) Get(setting string) time.Duration {
+	raw := strings.TrimSpace(r.lookup(setting))
+	if raw == "" || raw == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: must be a duration with a unit, such as 5s or 2m, got %q", setting, raw))
+		r.invalid[setting] = true
+	}
+	return d
+}
+
+// Problems describes every setting Get could not parse.
+func (r *DurationReader) Problems() []string {
+	return r.problems
+}
+
+// Invalid holds the settings Get could not parse, so validation does not
+// report their zero values a second time.
+func (r *DurationReader) Invalid() map[string]bool {
+	return r.invalid
+}
@@ -0,0 +1,69 @@
+package config
+
+import "fmt"
+
+// Environment is the deployment environment a service runs in. Its Profile
+// holds the defaults of every setting that differs between environments,
+// so all subsystems treat a given environment the same way.
+type Environment string
+
+const (
+	Development Environment = "development"
+	Staging     Environment = "staging"
+	Production  Environment = "production"
+)
+
+// Environments lists the known environments.
+var Environments = []Environment{Development, Staging, Production}
+
+// ParseEnvironment returns the environment named s.
+func ParseEnvironment(s string) (Environment, error) {
+	for _, e := range Environments {
+		if s == string(e) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown environment %q, expected development, staging or production", s)
+}
+
+// IsDevelopment reports whether e is a developer's machine, where output
+// is read by people rather than collected.
+func (e Environment) IsDevelopment() bool {
+	return e == Development
+}
+
+// IsProduction reports whether e serves real traffic.
+func (e Environment) IsProduction() bool {
+	return e == Production
+}
+
+// Profile holds the defaults of the settings that differ between
+// environments. Each can still be overridden by its own setting.
+type Profile struct {
+	// GinMode is the Gin mode: debug in development, which logs every
+	// route at startup, and release elsewhere.
+	GinMode string
+	// LogEncoding is console in development and json elsewhere, where logs
+	// are collected.
+	LogEncoding string
+	// TraceSampleRatio is the fraction of new traces sampled: all of them
+	// but in production, where one in ten keeps the tracing cost down.
+	TraceSampleRatio float64
+	// DebugEndpoints enables the routes that inject faults or write
+	// arbitrary messages, everywhere but in production.
+	DebugEndpoints bool
+}
+
+// Profile returns the defaults of e. An unknown environment gets those of
+// staging: collected output, but not production's restrictions.
+func (e Environment) Profile() Profile {
+	if e.IsDevelopment() {
+		return Profile{GinMode: "debug", LogEncoding: "console", TraceSampleRatio: 1, DebugEndpoints: true}
+	}
+	p := Profile{GinMode: "release", LogEncoding: "json", TraceSampleRatio: 1, DebugEndpoints: true}
+	if e.IsProduction() {
+		p.TraceSampleRatio = 0.1
+		p.DebugEndpoints = false
+	}
+	return p
+}
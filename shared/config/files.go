@@ -0,0 +1,91 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ReadConfigFiles merges the config files into viper in layers, each
+// overriding the ones before it:
+//
+//	.env.base            settings shared by every environment
+//	.env.<ENVIRONMENT>   the overlay of one environment, e.g. .env.production
+//	.env                 local overrides, or the file named by --config
+//
+// Every layer is optional except an explicit --config file. Without one the
+// files are read from the first of dirs holding any of them. ENVIRONMENT,
+// which picks the overlay, may come from a flag, the environment or
+// .env.base. Environment variables and flags override all files.
+func ReadConfigFiles(configFile string, dirs ...string) {
+	var dir, local string
+	if configFile != "" {
+		dir, local = filepath.Dir(configFile), configFile
+	} else {
+		dir = findConfigDir(dirs)
+		local = filepath.Join(dir, ".env")
+	}
+
+	var loaded []string
+	if mergeConfigFile(filepath.Join(dir, ".env.base"), false) {
+		loaded = append(loaded, ".env.base")
+	}
+	if env := viper.GetString("ENVIRONMENT"); env != "" {
+		if strings.ContainsAny(env, `/\`) || strings.HasPrefix(env, ".") {
+			log.Fatalf("ENVIRONMENT %q cannot name a config file", env)
+		}
+		if mergeConfigFile(filepath.Join(dir, ".env."+env), false) {
+			loaded = append(loaded, ".env."+env)
+		}
+	}
+	if mergeConfigFile(local, configFile != "") {
+		loaded = append(loaded, filepath.Base(local))
+	}
+
+	if len(loaded) == 0 {
+		log.Println("No .env file found, using environment variables and defaults")
+		return
+	}
+	log.Printf("Loaded config files %s from %s", strings.Join(loaded, ", "), dir)
+}
+
+// findConfigDir returns the first of dirs holding a config file, or the
+// current directory.
+func findConfigDir(dirs []string) string {
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, ".env*"))
+		for _, m := range matches {
+			if filepath.Base(m) != ".env.example" {
+				return dir
+			}
+		}
+	}
+	return "."
+}
+
+// mergeConfigFile merges path over the settings read so far and reports
+// whether it exists. A missing file is fatal only when required; a file
+// that does not parse always is, rather than starting half-configured.
+func mergeConfigFile(path string, required bool) bool {
+	if _, err := os.Stat(path); err != nil {
+		if required {
+			log.Fatalf("Error reading config file %s: %v", path, err)
+		}
+		return false
+	}
+
+	viper.SetConfigFile(path)
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml", ".json", ".toml":
+		viper.SetConfigType(strings.TrimPrefix(ext, "."))
+	default:
+		viper.SetConfigType("env")
+	}
+	if err := viper.MergeInConfig(); err != nil {
+		log.Fatalf("Error reading config file %s: %v", path, err)
+	}
+	return true
+}
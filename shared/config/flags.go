@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// BindFlags parses the command-line flags in args and binds them to their
+// settings. A flag given on the command line overrides the environment and
+// the config file; flags left out do not override anything. withBroker adds
+// --enable-broker, for services that use RabbitMQ. It returns the config
+// file named by --config, or "" when none was given.
+func BindFlags(args []string, withBroker bool) string {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	flags.String("port", "", "port to listen on (PORT)")
+	flags.String("environment", "", "deployment environment: development, staging or production (ENVIRONMENT)")
+	if withBroker {
+		flags.Bool("enable-broker", false, "publish and consume events through RabbitMQ (ENABLE_BROKER)")
+	}
+	flags.String("log-level", "", "debug, info, warn, error or fatal; defaults to debug in development and info elsewhere (LOG_LEVEL)")
+	configFile := flags.String("config", "", "config file to read instead of .env, over .env.base and .env.<environment>; .yaml, .json and .toml files are read in their format, others as an env file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flags.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nEvery setting can also be given as an environment variable or in the config file.")
+	}
+	// ExitOnError exits on invalid flags and on --help.
+	flags.Parse(args)
+
+	settings := map[string]string{
+		"PORT":        "port",
+		"ENVIRONMENT": "environment",
+		"LOG_LEVEL":   "log-level",
+	}
+	if withBroker {
+		settings["ENABLE_BROKER"] = "enable-broker"
+	}
+	for setting, flag := range settings {
+		viper.BindPFlag(setting, flags.Lookup(flag))
+	}
+	return *configFile
+}
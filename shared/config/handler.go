@@ -0,0 +1,19 @@
+package config
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the configuration of service, as returned by Redact, as
+// JSON. It must only be mounted behind authentication: masked secrets aside,
+// the configuration reveals hosts, users and enabled features.
+func Handler(service string, settings map[string]interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"service": service,
+			"config":  settings,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Mask replaces secret values in a redacted configuration.
+const Mask = "********"
+
+// secretWords mark fields holding secrets by their name.
+var secretWords = []string{"Password", "Secret", "Token"}
+
+// Redact returns the exported fields of cfg, a configuration struct or a
+// pointer to one, by field name for logging and inspection. Fields named
+// like secrets are masked unless empty, so an unset secret stays visible,
+// and so are the passwords of URLs. Durations are given as strings.
+func Redact(cfg interface{}) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	t := v.Type()
+
+	settings := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		settings[field.Name] = redactValue(field.Name, v.Field(i).Interface())
+	}
+	return settings
+}
+
+func redactValue(name string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v != "" && isSecret(name) {
+			return Mask
+		}
+		return redactURL(v)
+	case time.Duration:
+		return v.String()
+	default:
+		return value
+	}
+}
+
+func isSecret(name string) bool {
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL replaces the password of s with "xxxxx" when s is a URL with
+// one.
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem a service's Validate found, so a
+// misconfigured service reports them all at once rather than failing on
+// the first.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Validator collects the problems of one Validate run. Each check reports
+// the setting by name and leaves credentials out of the report.
+type Validator struct {
+	problems []string
+	// skip holds settings already reported as unparseable, whose zero
+	// values are not checked again.
+	skip map[string]bool
+}
+
+// NewValidator creates a validator starting with the problems durations
+// ran into, if any.
+func NewValidator(durations *DurationReader) *Validator {
+	v := &Validator{}
+	if durations != nil {
+		v.problems = append(v.problems, durations.Problems()...)
+		v.skip = durations.Invalid()
+	}
+	return v
+}
+
+// Err returns a *ValidationError listing every problem, or nil.
+func (v *Validator) Err() error {
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *Validator) Addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *Validator) Required(setting, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Addf("%s: required", setting)
+		return false
+	}
+	return true
+}
+
+func (v *Validator) Port(setting, value string) {
+	if !v.Required(setting, value) {
+		return
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.Addf("%s: must be a port number between 1 and 65535, got %q", setting, value)
+	}
+}
+
+// URL checks that value is an absolute URL with a host and one of schemes.
+// Credentials are left out of the report.
+func (v *Validator) URL(setting, value string, schemes ...string) {
+	if !v.Required(setting, value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.Addf("%s: not a valid URL", setting)
+		return
+	}
+	valid := false
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) {
+			valid = true
+		}
+	}
+	if !valid {
+		v.Addf("%s: must be a %s:// URL, got scheme %q", setting, strings.Join(schemes, ":// or "), u.Scheme)
+		return
+	}
+	if u.Hostname() == "" {
+		v.Addf("%s: must include a host", setting)
+	}
+}
+
+func (v *Validator) OneOf(setting, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	last := len(allowed) - 1
+	v.Addf("%s: must be %s or %s, got %q", setting, strings.Join(allowed[:last], ", "), allowed[last], value)
+}
+
+func (v *Validator) AtLeast(setting string, value, min int) {
+	if value < min {
+		v.Addf("%s: must be at least %d, got %d", setting, min, value)
+	}
+}
+
+func (v *Validator) Positive(setting string, d time.Duration) {
+	if d <= 0 && !v.skip[setting] {
+		v.Addf("%s: must be a positive duration, got %s", setting, d)
+	}
+}
+
+func (v *Validator) NonNegative(setting string, d time.Duration) {
+	if d < 0 {
+		v.Addf("%s: must not be negative, got %s", setting, d)
+	}
+}
+
+func (v *Validator) Fraction(setting string, f float64) {
+	if f < 0 || f > 1 {
+		v.Addf("%s: must be between 0 and 1, got %g", setting, f)
+	}
+}
@@ -0,0 +1,332 @@
+// Package contract tests the HTTP API a consumer service expects of a
+// provider service against the provider itself, without running either.
+//
+// The consumer's tests call its client against a Mock, which answers each
+// request with the response of the interaction the test expects and checks
+// that the client sent the expected request. The interactions are committed
+// as a golden contract file. The provider's tests Load that file and Verify
+// every interaction against the provider's routes, so a change to either
+// side that breaks the other fails a test before it is deployed.
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Contract is the API a consumer expects of a provider.
+type Contract struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request the consumer sends and the response it relies on.
+type Interaction struct {
+	Description string `json:"description"`
+	// State names the provider state the interaction needs, e.g. "PROD-001
+	// has 50 units available". The provider's tests set it up before the
+	// interaction is verified.
+	State    string   `json:"state,omitempty"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is sent as is, with a JSON body when Body is set.
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is what the consumer relies on, not everything the provider
+// sends: the provider may add fields, but must keep those in Body with the
+// same JSON types. Arrays must have at least as many elements, matched by
+// index.
+type Response struct {
+	Status int `json:"status"`
+	// ContentType is compared without its parameters, e.g. a charset.
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	// Exact lists the fields of Body whose values, not only types, must
+	// match, as dot-separated paths such as "code" or "items.found".
+	Exact []string `json:"exact,omitempty"`
+}
+
+// Load reads the contract file at path.
+func Load(path string) (*Contract, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Contract
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("parse contract %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Marshal returns the contract as the indented JSON of its file.
+func (c *Contract) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Body marshals v for a Request or Response body. It panics if v cannot be
+// marshalled, as the interactions are literals in tests.
+func Body(v interface{}) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("contract: marshal body: %v", err))
+	}
+	return raw
+}
+
+// Mock stands in for the provider in the consumer's tests, one expected
+// interaction at a time.
+type Mock struct {
+	mu       sync.Mutex
+	contract Contract
+	current  *Interaction
+	received int
+	problems []string
+}
+
+// NewMock returns a mock of provider recording the contract of consumer.
+func NewMock(consumer, provider string) *Mock {
+	return &Mock{contract: Contract{
+		Consumer:     consumer,
+		Provider:     provider,
+		Interactions: []Interaction{},
+	}}
+}
+
+// Expect makes i the interaction the next request must match and adds it
+// to the contract.
+func (m *Mock) Expect(i Interaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contract.Interactions = append(m.contract.Interactions, i)
+	m.current = &m.contract.Interactions[len(m.contract.Interactions)-1]
+	m.received = 0
+	m.problems = nil
+}
+
+// ServeHTTP answers with the expected interaction's response, recording
+// where the request differs from the expected one.
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		m.problems = append(m.problems, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.RequestURI()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m.received++
+	want := m.current.Request
+	if r.Method != want.Method || r.URL.RequestURI() != want.Path {
+		m.problems = append(m.problems, fmt.Sprintf("got request %s %s, want %s %s", r.Method, r.URL.RequestURI(), want.Method, want.Path))
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		m.problems = append(m.problems, fmt.Sprintf("read request body: %v", err))
+	} else if problem := compareBodies(want.Body, body); problem != "" {
+		m.problems = append(m.problems, problem)
+	}
+
+	resp := m.current.Response
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// Verify reports whether the mock received exactly one request since
+// Expect, and that it matched.
+func (m *Mock) Verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	problems := m.problems
+	if m.current != nil && m.received != 1 {
+		problems = append(problems, fmt.Sprintf("got %d requests, want 1", m.received))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	description := "no interaction"
+	if m.current != nil {
+		description = m.current.Description
+	}
+	return fmt.Errorf("%s: %s", description, strings.Join(problems, "; "))
+}
+
+// Contract returns the interactions expected so far.
+func (m *Mock) Contract() *Contract {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.contract
+	c.Interactions = append([]Interaction(nil), m.contract.Interactions...)
+	return &c
+}
+
+// Verify sends the interaction's request to handler, the provider's routes,
+// and checks that the response keeps what the consumer relies on. The
+// interaction's state must already be set up.
+func (i Interaction) Verify(handler http.Handler) error {
+	var body io.Reader
+	if len(i.Request.Body) > 0 {
+		body = bytes.NewReader(i.Request.Body)
+	}
+	req := httptest.NewRequest(i.Request.Method, i.Request.Path, body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var problems []string
+	if w.Code != i.Response.Status {
+		problems = append(problems, fmt.Sprintf("got status %d, want %d", w.Code, i.Response.Status))
+	}
+	if i.Response.ContentType != "" {
+		got, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		want, _, _ := mime.ParseMediaType(i.Response.ContentType)
+		if got != want {
+			problems = append(problems, fmt.Sprintf("got content type %q, want %q", got, want))
+		}
+	}
+	if len(i.Response.Body) > 0 {
+		var expected, actual interface{}
+		if err := json.Unmarshal(i.Response.Body, &expected); err != nil {
+			return fmt.Errorf("%s: parse contract body: %w", i.Description, err)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			problems = append(problems, fmt.Sprintf("response body is not JSON: %q", w.Body.String()))
+		} else {
+			exact := make(map[string]bool, len(i.Response.Exact))
+			for _, path := range i.Response.Exact {
+				exact[path] = true
+			}
+			problems = append(problems, match("", expected, actual, exact)...)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", i.Description, strings.Join(problems, "; "))
+}
+
+// match lists where actual drops a field of expected or changes its type,
+// or, for the paths in exact, its value.
+func match(path string, expected, actual interface{}, exact map[string]bool) []string {
+	at := path
+	if at == "" {
+		at = "body"
+	}
+	if exact[path] {
+		if !reflect.DeepEqual(expected, actual) {
+			return []string{fmt.Sprintf("%s is %s, want %s", at, jsonText(actual), jsonText(expected))}
+		}
+		return nil
+	}
+	if expected == nil {
+		return nil
+	}
+	if kind(actual) != kind(expected) {
+		return []string{fmt.Sprintf("%s is %s, want %s", at, kind(actual), kind(expected))}
+	}
+
+	var problems []string
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a := actual.(map[string]interface{})
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			v, ok := a[k]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s is missing", field))
+				continue
+			}
+			problems = append(problems, match(field, e[k], v, exact)...)
+		}
+	case []interface{}:
+		a := actual.([]interface{})
+		if len(a) < len(e) {
+			return []string{fmt.Sprintf("%s has %d elements, want at least %d", at, len(a), len(e))}
+		}
+		for i := range e {
+			problems = append(problems, match(path, e[i], a[i], exact)...)
+		}
+	}
+	return problems
+}
+
+func kind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonText(v interface{}) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// compareBodies describes how a request body differs from the expected one,
+// comparing JSON values rather than bytes.
+func compareBodies(expected json.RawMessage, actual []byte) string {
+	if len(expected) == 0 {
+		if len(bytes.TrimSpace(actual)) > 0 {
+			return fmt.Sprintf("got request body %s, want none", actual)
+		}
+		return ""
+	}
+	var e, a interface{}
+	if err := json.Unmarshal(expected, &e); err != nil {
+		return fmt.Sprintf("parse contract request body: %v", err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil || !reflect.DeepEqual(e, a) {
+		return fmt.Sprintf("got request body %s, want %s", actual, expected)
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Database checks that a connection to the database can be used.
+func Database(db interface {
+	PingContext(ctx context.Context) error
+}) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Schema checks that the given tables exist, so an instance whose schema
+// has not been created or was dropped does not take traffic.
+func Schema(db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, tables ...string) Check {
+	return func(ctx context.Context) error {
+		var missing []string
+		for _, table := range tables {
+			var exists bool
+			if err := db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up table %s: %w", table, err)
+			}
+			if !exists {
+				missing = append(missing, table)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+// Broker checks that the message broker connection is open. The client
+// reconnects on its own, so the check fails only until it is back.
+func Broker(client interface{ IsClosed() bool }) Check {
+	return func(ctx context.Context) error {
+		if client.IsClosed() {
+			return errors.New("broker connection is closed")
+		}
+		return nil
+	}
+}
+
+// HTTP checks that a GET of url answers with a 2xx status, e.g. another
+// service's /live. A nil client uses http.DefaultClient; the prober's
+// timeout applies either way.
+func HTTP(client *http.Client, url string) Check {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s unreachable: %w", url, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// DiskSpace checks that at least minFree, a fraction between 0 and 1, of
+// the filesystem holding path is available.
+func DiskSpace(path string, minFree float64) Check {
+	return func(ctx context.Context) error {
+		free, total, err := diskUsage(path)
+		if err != nil {
+			return fmt.Errorf("failed to read disk usage of %s: %w", path, err)
+		}
+		if total == 0 {
+			return nil
+		}
+		if ratio := float64(free) / float64(total); ratio < minFree {
+			return fmt.Errorf("%s has %.1f%% free (%d of %d bytes), below %.1f%%",
+				path, ratio*100, free, total, minFree*100)
+		}
+		return nil
+	}
+}
+
+// Goroutines checks that the process runs at most max goroutines; a count
+// that keeps growing past it usually means goroutines are leaking.
+func Goroutines(max int) Check {
+	return func(ctx context.Context) error {
+		if n := runtime.NumGoroutine(); n > max {
+			return fmt.Errorf("%d goroutines running, above %d", n, max)
+		}
+		return nil
+	}
+}
+
+// StallReporter is implemented by outboxinbox.WorkerPool.
+type StallReporter interface {
+	Name() string
+	Stalled(after time.Duration) []string
+}
+
+// StartReporter is implemented by outboxinbox.WorkerPool.
+type StartReporter interface {
+	Name() string
+	Unstarted() []string
+}
+
+// WorkersStarted checks that every worker of the pool has polled at least
+// once, so traffic is only sent once its messages will be processed.
+func WorkersStarted(pool StartReporter) Check {
+	return func(ctx context.Context) error {
+		if unstarted := pool.Unstarted(); len(unstarted) > 0 {
+			return fmt.Errorf("%s workers have not started polling: %s",
+				pool.Name(), strings.Join(unstarted, ", "))
+		}
+		return nil
+	}
+}
+
+// Workers checks that no worker of the pool has gone longer than after
+// without polling, which means it is stuck or has exited.
+func Workers(pool StallReporter, after time.Duration) Check {
+	return func(ctx context.Context) error {
+		if stalled := pool.Stalled(after); len(stalled) > 0 {
+			return fmt.Errorf("%s workers have not polled for %s: %s",
+				pool.Name(), after, strings.Join(stalled, ", "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,13 @@
+package health
+
+import "syscall"
+
+// diskUsage returns the bytes available to unprivileged users and the size
+// of the filesystem holding path.
+func diskUsage(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package health
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func diskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, fmt.Errorf("not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,283 @@
+// Package health serves Kubernetes liveness and readiness probes backed by
+// per-dependency checks, and a /health report of all of them.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp = "up"
+	// StatusDegraded is reported by /health when only diagnostic checks
+	// fail: the instance still serves, but needs attention.
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+
+	// DefaultTimeout bounds each check, so one hanging dependency cannot make
+	// the probe itself time out.
+	DefaultTimeout = 2 * time.Second
+)
+
+// Checker reports a dependency as healthy by returning nil.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Check adapts a function to a Checker, e.g. health.Check(client.Ping).
+type Check func(ctx context.Context) error
+
+func (f Check) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the body of a probe response.
+type Report struct {
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// Summary is the body of a /health response: every check, grouped by
+// the probe it belongs to.
+type Summary struct {
+	Status        string                 `json:"status"`
+	Service       string                 `json:"service"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Liveness      map[string]CheckResult `json:"liveness"`
+	Readiness     map[string]CheckResult `json:"readiness"`
+	Diagnostics   map[string]CheckResult `json:"diagnostics"`
+}
+
+type namedCheck struct {
+	name    string
+	checker Checker
+
+	// mu serializes runs of the check while results are cached, so
+	// concurrent probes share one run instead of each starting their own.
+	mu     sync.Mutex
+	result CheckResult
+}
+
+// Prober runs the registered checks for the liveness and readiness probes.
+// Liveness checks should only fail when restarting the process would help,
+// e.g. stalled workers; a database outage belongs in readiness, where it
+// takes the instance out of rotation without restarting it. Diagnostic
+// checks, e.g. disk space, take part in neither and only show in /health.
+type Prober struct {
+	service     string
+	logger      logger.Logger
+	timeout     time.Duration
+	cacheTTL    time.Duration
+	started     time.Time
+	liveness    []*namedCheck
+	readiness   []*namedCheck
+	diagnostics []*namedCheck
+}
+
+func NewProber(service string, log logger.Logger, timeout time.Duration) *Prober {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Prober{
+		service: service,
+		logger:  log,
+		timeout: timeout,
+		started: time.Now(),
+	}
+}
+
+// SetCacheTTL reuses each check's result for ttl, so frequent probes from
+// Kubernetes, load balancers and the gateway do not each hit the
+// dependencies. Zero, the default, runs the checks on every probe.
+func (p *Prober) SetCacheTTL(ttl time.Duration) {
+	p.cacheTTL = ttl
+}
+
+// AddLiveness registers a check for /live.
+func (p *Prober) AddLiveness(name string, checker Checker) {
+	p.liveness = append(p.liveness, &namedCheck{name: name, checker: checker})
+}
+
+// AddReadiness registers a check for /ready.
+func (p *Prober) AddReadiness(name string, checker Checker) {
+	p.readiness = append(p.readiness, &namedCheck{name: name, checker: checker})
+}
+
+// AddDiagnostic registers a check reported only by /health, where failing
+// it degrades the status without failing the request.
+func (p *Prober) AddDiagnostic(name string, checker Checker) {
+	p.diagnostics = append(p.diagnostics, &namedCheck{name: name, checker: checker})
+}
+
+// Live responds 200 when every liveness check passes and 503 otherwise.
+func (p *Prober) Live(c *gin.Context) {
+	p.respond(c, "liveness", p.liveness)
+}
+
+// Ready responds 200 when every readiness check passes and 503 otherwise.
+func (p *Prober) Ready(c *gin.Context) {
+	p.respond(c, "readiness", p.readiness)
+}
+
+// Health reports every check. It responds 503 with status down when a
+// liveness or readiness check fails, and 200 otherwise, with status
+// degraded when a diagnostic check fails.
+func (p *Prober) Health(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	report := Summary{
+		Status:        StatusUp,
+		Service:       p.service,
+		UptimeSeconds: int64(time.Since(p.started).Seconds()),
+	}
+
+	var wg sync.WaitGroup
+	for _, group := range []struct {
+		checks []*namedCheck
+		report *map[string]CheckResult
+	}{
+		{p.liveness, &report.Liveness},
+		{p.readiness, &report.Readiness},
+		{p.diagnostics, &report.Diagnostics},
+	} {
+		wg.Add(1)
+		go func(checks []*namedCheck, results *map[string]CheckResult) {
+			defer wg.Done()
+			*results = p.run(ctx, checks).Checks
+		}(group.checks, group.report)
+	}
+	wg.Wait()
+
+	for _, results := range []map[string]CheckResult{report.Liveness, report.Readiness} {
+		for _, result := range results {
+			if result.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}
+	}
+	if report.Status == StatusUp {
+		for _, result := range report.Diagnostics {
+			if result.Status == StatusDown {
+				report.Status = StatusDegraded
+			}
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	if report.Status != StatusUp {
+		for probe, results := range map[string]map[string]CheckResult{
+			"liveness":    report.Liveness,
+			"readiness":   report.Readiness,
+			"diagnostics": report.Diagnostics,
+		} {
+			p.logFailures(ctx, probe, results)
+		}
+	}
+	c.JSON(status, report)
+}
+
+func (p *Prober) respond(c *gin.Context, probe string, checks []*namedCheck) {
+	ctx := c.Request.Context()
+
+	report := p.run(ctx, checks)
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+		p.logFailures(ctx, probe, report.Checks)
+	}
+	c.JSON(status, report)
+}
+
+func (p *Prober) logFailures(ctx context.Context, probe string, results map[string]CheckResult) {
+	for name, result := range results {
+		if result.Status == StatusDown {
+			p.logger.WarnCtx(ctx, "Health check failed",
+				logger.String("probe", probe),
+				logger.String("check", name),
+				logger.String("error", result.Error))
+		}
+	}
+}
+
+// run executes the checks concurrently, each bounded by the prober's timeout.
+func (p *Prober) run(ctx context.Context, checks []*namedCheck) Report {
+	report := Report{
+		Status:  StatusUp,
+		Service: p.service,
+		Checks:  make(map[string]CheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc *namedCheck) {
+			defer wg.Done()
+
+			result := p.check(ctx, nc)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if result.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}(nc)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// check runs nc, or returns its last result while that is younger than the
+// cache TTL.
+func (p *Prober) check(ctx context.Context, nc *namedCheck) CheckResult {
+	if p.cacheTTL > 0 {
+		nc.mu.Lock()
+		defer nc.mu.Unlock()
+		if !nc.result.CheckedAt.IsZero() && time.Since(nc.result.CheckedAt) < p.cacheTTL {
+			return nc.result
+		}
+		// The result is shared with other probes, so it must not fail
+		// because this probe's client went away.
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := nc.checker.Check(checkCtx)
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	if p.cacheTTL > 0 {
+		nc.result = result
+	}
+	return result
+}
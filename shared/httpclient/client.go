@@ -23,6 +23,9 @@ type Config struct {
 	RetryCount       int
 	RetryWaitTime    time.Duration
 	RetryMaxWaitTime time.Duration
+	// RetryOnServerError also retries 5xx responses, not only transport
+	// errors. Only enable it for idempotent requests.
+	RetryOnServerError bool
 }
 
 func DefaultConfig() Config {
@@ -45,6 +48,12 @@ func New(cfg Config) *Client {
 		client.SetBaseURL(cfg.BaseURL)
 	}
 
+	if cfg.RetryOnServerError {
+		client.AddRetryCondition(func(resp *resty.Response, err error) bool {
+			return resp != nil && resp.StatusCode() >= 500
+		})
+	}
+
 	return &Client{
 		resty:      client,
 		tracer:     otel.Tracer("httpclient"),
@@ -77,6 +86,7 @@ type TracedRequest struct {
 	ctx       context.Context
 	spanName  string
 	spanAttrs []attribute.KeyValue
+	rawBody   bool
 }
 
 func (r *TracedRequest) SetHeader(key, value string) *TracedRequest {
@@ -89,11 +99,26 @@ func (r *TracedRequest) SetHeaders(headers map[string]string) *TracedRequest {
 	return r
 }
 
+// SetHeaderMultiValues sets headers with every one of their values, e.g.
+// those of a request being forwarded.
+func (r *TracedRequest) SetHeaderMultiValues(headers map[string][]string) *TracedRequest {
+	r.request.SetHeaderMultiValues(headers)
+	return r
+}
+
 func (r *TracedRequest) SetBody(body interface{}) *TracedRequest {
 	r.request.SetBody(body)
 	return r
 }
 
+// SetDoNotParseResponse leaves the response body unread, so the caller can
+// stream it from resp.RawBody(), which it must then close.
+func (r *TracedRequest) SetDoNotParseResponse(notParse bool) *TracedRequest {
+	r.request.SetDoNotParseResponse(notParse)
+	r.rawBody = notParse
+	return r
+}
+
 func (r *TracedRequest) SetResult(result interface{}) *TracedRequest {
 	r.request.SetResult(result)
 	return r
@@ -165,6 +190,12 @@ func (r *TracedRequest) Patch(url string) (*resty.Response, error) {
 	return r.execute("PATCH", url)
 }
 
+// Execute sends the request with method, which may be any HTTP method,
+// e.g. that of a request being forwarded.
+func (r *TracedRequest) Execute(method, url string) (*resty.Response, error) {
+	return r.execute(method, url)
+}
+
 func (r *TracedRequest) execute(method, url string) (*resty.Response, error) {
 
 	spanName := r.spanName
@@ -210,6 +241,8 @@ func (r *TracedRequest) execute(method, url string) (*resty.Response, error) {
 		resp, err = r.request.Delete(url)
 	case "PATCH":
 		resp, err = r.request.Patch(url)
+	default:
+		resp, err = r.request.Execute(method, url)
 	}
 
 	if err != nil {
@@ -218,10 +251,13 @@ func (r *TracedRequest) execute(method, url string) (*resty.Response, error) {
 		return nil, err
 	}
 
-	span.SetAttributes(
-		attribute.Int("http.status_code", resp.StatusCode()),
-		attribute.Int64("http.response_size", int64(len(resp.Body()))),
-	)
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
+	if !r.rawBody {
+		span.SetAttributes(attribute.Int64("http.response_size", int64(len(resp.Body()))))
+	} else if size := resp.RawResponse.ContentLength; size >= 0 {
+		// An unread body's size is only known when the server declared it.
+		span.SetAttributes(attribute.Int64("http.response_size", size))
+	}
 
 	return resp, nil
 }
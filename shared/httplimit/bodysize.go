@@ -0,0 +1,39 @@
+package httplimit
+
+import (
+	"fmt"
+	"net/http"
+
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySize limits request bodies to max bytes, or to the route's entry in
+// routes when it has one; zero disables the limit. Requests declaring a
+// larger Content-Length are rejected with 413 before the body is read.
+// Otherwise the body is wrapped in http.MaxBytesReader, so a handler reading
+// past the limit gets an *http.MaxBytesError, which problem.ValidationFailed
+// reports as 413 too.
+func MaxBodySize(max int64, routes map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := max
+		if v, ok := routes[routeKey(c)]; ok {
+			limit = v
+		}
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			problem.Abort(c, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+				fmt.Sprintf("Request body exceeds %d bytes", limit)).
+				With("max_bytes", limit))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,78 @@
+// Package httplimit bounds how long a request may run and how large its body
+// may be, with per-route overrides of the service-wide defaults.
+package httplimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeKey identifies a route as "METHOD /path", using the path as
+// registered, e.g. "POST /api/orders" or "GET /api/orders/:order_id".
+func routeKey(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
+
+// ParseRouteTimeouts parses comma-separated "METHOD /path=duration" entries,
+// e.g. "POST /api/orders=15s,GET /api/orders/export=0". A zero duration
+// disables the timeout for that route.
+func ParseRouteTimeouts(s string) (map[string]time.Duration, error) {
+	entries, err := splitRoutes(s)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := make(map[string]time.Duration, len(entries))
+	for route, value := range entries {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid timeout %q for %s: must be a non-negative duration", value, route)
+		}
+		timeouts[route] = d
+	}
+	return timeouts, nil
+}
+
+// ParseRouteSizes parses comma-separated "METHOD /path=bytes" entries, e.g.
+// "POST /api/inbox=4194304". Zero disables the limit for that route.
+func ParseRouteSizes(s string) (map[string]int64, error) {
+	entries, err := splitRoutes(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(entries))
+	for route, value := range entries {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid body size %q for %s: must be a non-negative number of bytes", value, route)
+		}
+		sizes[route] = n
+	}
+	return sizes, nil
+}
+
+// splitRoutes splits "METHOD /path=value" entries into a map keyed like
+// routeKey.
+func splitRoutes(s string) (map[string]string, error) {
+	entries := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !hasPath || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid route limit %q: expected \"METHOD /path=value\"", entry)
+		}
+		entries[strings.ToUpper(method)+" "+path] = strings.TrimSpace(value)
+	}
+	return entries, nil
+}
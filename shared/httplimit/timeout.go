@@ -0,0 +1,89 @@
+package httplimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout cancels the request context after d, or after the route's entry in
+// routes when it has one; zero disables the timeout. Handlers stop when the
+// database or downstream calls they pass the context to give up. If the
+// handler then responds with a server error, that response is replaced by a
+// 408 request_timeout problem; a handler that still completed in time for a
+// successful or client error response is left alone.
+func Timeout(d time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := d
+		if v, ok := routes[routeKey(c)]; ok {
+			timeout = v
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = tw
+		c.Next()
+		c.Writer = tw.ResponseWriter
+
+		if tw.timedOut {
+			// Drop the discarded response's content type.
+			c.Writer.Header().Del("Content-Type")
+			problem.Write(c, problem.New(http.StatusRequestTimeout, problem.CodeRequestTimeout,
+				fmt.Sprintf("Request did not complete within %s", timeout)).
+				With("timeout_seconds", timeout.Seconds()))
+		}
+	}
+}
+
+// timeoutWriter discards a server error response written after the deadline
+// so Timeout can send a 408 in its place.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if !w.ResponseWriter.Written() && code >= http.StatusInternalServerError &&
+		errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		return
+	}
+	if w.timedOut {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.timedOut {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.timedOut {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,129 @@
+package lock
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// DefaultRetryInterval is how often a standby instance tries to become leader.
+const DefaultRetryInterval = 5 * time.Second
+
+// Elector elects one leader among the instances sharing a database: the
+// instance holding the session lock name leads, the others stand by and
+// retry every interval, taking over once the leader releases the lock or
+// its connection drops.
+type Elector struct {
+	locker        *Locker
+	name          string
+	logger        logger.Logger
+	retryInterval time.Duration
+	leader        atomic.Bool
+	stopCh        chan struct{}
+}
+
+// NewElector creates an elector campaigning for name. With a nil locker the
+// instance always leads, for single-instance setups.
+func NewElector(locker *Locker, name string, log logger.Logger, retryInterval time.Duration) *Elector {
+	if retryInterval <= 0 {
+		retryInterval = DefaultRetryInterval
+	}
+	return &Elector{
+		locker:        locker,
+		name:          name,
+		logger:        log,
+		retryInterval: retryInterval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// IsLeader reports whether the instance currently leads.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start campaigns until ctx is done or Stop is called, calling lead each
+// time the instance becomes leader. lead must return once its context is
+// done, which also happens when leadership is lost.
+func (e *Elector) Start(ctx context.Context, lead func(ctx context.Context)) {
+	e.logger.Info("Starting leader election",
+		logger.String("election", e.name),
+		logger.String("retry_interval", e.retryInterval.String()))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("Stopping leader election due to context cancellation",
+				logger.String("election", e.name))
+			return
+		case <-e.stopCh:
+			e.logger.Info("Leader election stopped",
+				logger.String("election", e.name))
+			return
+		case <-timer.C:
+			e.campaign(ctx, lead)
+			timer.Reset(e.retryInterval)
+		}
+	}
+}
+
+func (e *Elector) Stop() {
+	close(e.stopCh)
+}
+
+// campaign tries once to become leader and, on success, leads until the
+// lock is lost, ctx is done or the elector stops.
+func (e *Elector) campaign(ctx context.Context, lead func(ctx context.Context)) {
+	var lost <-chan struct{}
+	if e.locker != nil {
+		lk, acquired, err := e.locker.TryLock(ctx, e.name)
+		if err != nil {
+			if ctx.Err() == nil {
+				e.logger.Warn("Failed to campaign for leadership, will retry",
+					logger.Err(err),
+					logger.String("election", e.name))
+			}
+			return
+		}
+		if !acquired {
+			return
+		}
+		// Unlock even when ctx is done, so a standby takes over without
+		// waiting for this instance's connection to close.
+		defer func() {
+			if err := lk.Unlock(context.WithoutCancel(ctx)); err != nil {
+				e.logger.Warn("Failed to release leadership",
+					logger.Err(err),
+					logger.String("election", e.name))
+			}
+		}()
+		lost = lk.Lost()
+	}
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lost:
+		case <-e.stopCh:
+		case <-leadCtx.Done():
+		}
+		cancel()
+	}()
+
+	e.leader.Store(true)
+	Leader.WithLabelValues(e.name).Set(1)
+	LeaderTransitionsTotal.WithLabelValues(e.name).Inc()
+	e.logger.Info("Elected leader", logger.String("election", e.name))
+
+	lead(leadCtx)
+
+	e.leader.Store(false)
+	Leader.WithLabelValues(e.name).Set(0)
+	e.logger.Info("Stepped down as leader", logger.String("election", e.name))
+}
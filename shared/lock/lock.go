@@ -0,0 +1,254 @@
+// Package lock provides distributed locks backed by Postgres advisory locks,
+// so that singleton jobs run on one replica at a time.
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// DefaultRenewInterval is how often a held session lock checks that its
+// connection, and with it the lock, is still alive.
+const DefaultRenewInterval = 30 * time.Second
+
+// Key maps a lock name to the 64-bit key of its advisory lock.
+func Key(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// Locker takes advisory locks on a database. A nil *Locker is valid:
+// RunExclusive then runs every job unguarded, for single-replica setups.
+type Locker struct {
+	db            *sql.DB
+	logger        logger.Logger
+	renewInterval time.Duration
+}
+
+// New creates a locker on db, e.g. the DB field of a *sqlx.DB.
+func New(db *sql.DB, log logger.Logger) *Locker {
+	return &Locker{
+		db:            db,
+		logger:        log,
+		renewInterval: DefaultRenewInterval,
+	}
+}
+
+// SetRenewInterval changes how often held locks are renewed; zero disables
+// renewal, so a lost connection goes unnoticed until Unlock.
+func (l *Locker) SetRenewInterval(interval time.Duration) {
+	l.renewInterval = interval
+}
+
+// TryLock takes the session lock name if no one else holds it, and reports
+// whether it did. The lock holds a pooled connection until Unlock.
+func (l *Locker) TryLock(ctx context.Context, name string) (*Lock, bool, error) {
+	return l.acquire(ctx, name, "SELECT pg_try_advisory_lock($1)")
+}
+
+// Lock waits until it takes the session lock name, or ctx is done.
+func (l *Locker) Lock(ctx context.Context, name string) (*Lock, error) {
+	lk, _, err := l.acquire(ctx, name, "SELECT true FROM pg_advisory_lock($1)")
+	return lk, err
+}
+
+func (l *Locker) acquire(ctx context.Context, name, query string) (*Lock, bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		AcquisitionsTotal.WithLabelValues(name, "error").Inc()
+		return nil, false, fmt.Errorf("failed to get a connection for lock %s: %w", name, err)
+	}
+
+	key := Key(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, query, key).Scan(&acquired); err != nil {
+		// A cancelled pg_advisory_lock may still have been granted, so the
+		// connection must not go back to the pool.
+		discard(conn)
+		AcquisitionsTotal.WithLabelValues(name, "error").Inc()
+		return nil, false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		AcquisitionsTotal.WithLabelValues(name, "busy").Inc()
+		return nil, false, nil
+	}
+	AcquisitionsTotal.WithLabelValues(name, "acquired").Inc()
+
+	lk := &Lock{
+		name:   name,
+		key:    key,
+		conn:   conn,
+		logger: l.logger,
+		lost:   make(chan struct{}),
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if l.renewInterval > 0 {
+		go lk.renew(l.renewInterval)
+	} else {
+		close(lk.done)
+	}
+	HeldLocks.WithLabelValues(name).Inc()
+	return lk, true, nil
+}
+
+// RunExclusive runs fn while holding the lock name and reports whether it
+// ran; it does not run when another replica holds the lock. fn's context is
+// cancelled if the lock is lost while it runs. On a nil Locker fn always runs.
+func (l *Locker) RunExclusive(ctx context.Context, name string, fn func(ctx context.Context)) (bool, error) {
+	if l == nil {
+		fn(ctx)
+		return true, nil
+	}
+
+	lk, acquired, err := l.TryLock(ctx, name)
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lk.Lost():
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	fn(runCtx)
+
+	// Unlock even when ctx is done, e.g. on shutdown, so the next replica
+	// does not wait for the connection to time out.
+	if err := lk.Unlock(context.WithoutCancel(ctx)); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Lock is a held session lock.
+type Lock struct {
+	name   string
+	key    int64
+	conn   *sql.Conn
+	logger logger.Logger
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+	unlock   sync.Once
+}
+
+// Name returns the name the lock was taken with.
+func (lk *Lock) Name() string {
+	return lk.name
+}
+
+// Lost is closed when renewal finds the lock's connection gone; Postgres
+// has released the lock by then, so the holder should stop its work.
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Unlock releases the lock and returns its connection to the pool. Calling
+// it again does nothing.
+func (lk *Lock) Unlock(ctx context.Context) error {
+	var err error
+	lk.unlock.Do(func() {
+		close(lk.stopCh)
+		<-lk.done
+		HeldLocks.WithLabelValues(lk.name).Dec()
+
+		var released bool
+		if err = lk.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", lk.key).Scan(&released); err != nil {
+			// Closing the connection ends the session, which releases
+			// the lock anyway.
+			discard(lk.conn)
+			err = fmt.Errorf("failed to release lock %s: %w", lk.name, err)
+			return
+		}
+		if !released {
+			lk.logger.Warn("Advisory lock was no longer held on release",
+				logger.String("lock", lk.name))
+		}
+		lk.conn.Close()
+	})
+	return err
+}
+
+// renew pings the lock's connection every interval until Unlock, marking
+// the lock lost once the connection fails.
+func (lk *Lock) renew(interval time.Duration) {
+	defer close(lk.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lk.stopCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			_, err := lk.conn.ExecContext(ctx, "SELECT 1")
+			cancel()
+			if err != nil {
+				lk.logger.Error("Lost advisory lock",
+					logger.Err(err),
+					logger.String("lock", lk.name))
+				LostTotal.WithLabelValues(lk.name).Inc()
+				lk.lostOnce.Do(func() { close(lk.lost) })
+				return
+			}
+		}
+	}
+}
+
+// Tx is the part of *sql.Tx, and *sqlx.Tx, that transaction locks use.
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TryLockTx takes the transaction lock name if no one else holds it, and
+// reports whether it did. Postgres releases it when tx ends.
+func TryLockTx(ctx context.Context, tx Tx, name string) (bool, error) {
+	var acquired bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", Key(name)).Scan(&acquired); err != nil {
+		AcquisitionsTotal.WithLabelValues(name, "error").Inc()
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if acquired {
+		AcquisitionsTotal.WithLabelValues(name, "acquired").Inc()
+	} else {
+		AcquisitionsTotal.WithLabelValues(name, "busy").Inc()
+	}
+	return acquired, nil
+}
+
+// LockTx waits until it takes the transaction lock name, or ctx is done.
+// Postgres releases it when tx ends.
+func LockTx(ctx context.Context, tx Tx, name string) error {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", Key(name)); err != nil {
+		AcquisitionsTotal.WithLabelValues(name, "error").Inc()
+		return fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	AcquisitionsTotal.WithLabelValues(name, "acquired").Inc()
+	return nil
+}
+
+// discard closes conn instead of returning it to the pool, ending its
+// session along with any advisory locks it still holds.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+}
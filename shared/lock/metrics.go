@@ -0,0 +1,57 @@
+package lock
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	AcquisitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "advisory_lock_acquisitions_total",
+			Help: "Total number of advisory lock attempts, by lock and result (acquired, busy or error)",
+		},
+		[]string{"lock", "result"},
+	)
+
+	HeldLocks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "advisory_locks_held",
+			Help: "Number of session advisory locks currently held by this instance, by lock",
+		},
+		[]string{"lock"},
+	)
+
+	LostTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "advisory_lock_lost_total",
+			Help: "Total number of held session advisory locks lost to a failed connection, by lock",
+		},
+		[]string{"lock"},
+	)
+
+	Leader = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "leader_election_is_leader",
+			Help: "Whether this instance currently leads the election (1) or stands by (0), by election",
+		},
+		[]string{"election"},
+	)
+
+	LeaderTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "leader_election_transitions_total",
+			Help: "Total number of times this instance became leader, by election",
+		},
+		[]string{"election"},
+	)
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		AcquisitionsTotal,
+		HeldLocks,
+		LostTotal,
+		Leader,
+		LeaderTransitionsTotal,
+	}
+}
@@ -11,6 +11,9 @@ type contextKey string
 const (
 	requestIDKey contextKey = "request_id"
 	userIDKey    contextKey = "user_id"
+
+	correlationIDKey contextKey = "correlation_id"
+	causationIDKey   contextKey = "causation_id"
 )
 
 // WithRequestID adds a request ID to the context
@@ -39,6 +42,34 @@ func GetUserID(ctx context.Context) string {
 	return ""
 }
 
+// WithCorrelationID adds the ID shared by every request and message of one
+// business flow to the context
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, correlationID)
+}
+
+// GetCorrelationID retrieves the correlation ID from context
+func GetCorrelationID(ctx context.Context) string {
+	if correlationID, ok := ctx.Value(correlationIDKey).(string); ok {
+		return correlationID
+	}
+	return ""
+}
+
+// WithCausationID adds the ID of the message that triggered the current work
+// to the context
+func WithCausationID(ctx context.Context, causationID string) context.Context {
+	return context.WithValue(ctx, causationIDKey, causationID)
+}
+
+// GetCausationID retrieves the causation ID from context
+func GetCausationID(ctx context.Context) string {
+	if causationID, ok := ctx.Value(causationIDKey).(string); ok {
+		return causationID
+	}
+	return ""
+}
+
 // GenerateRequestID creates a new unique request ID
 func GenerateRequestID() string {
 	return uuid.New().String()
@@ -6,7 +6,10 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const RequestIDHeader = "X-Request-ID"
+const (
+	RequestIDHeader     = "X-Request-ID"
+	CorrelationIDHeader = "X-Correlation-ID"
+)
 
 // GinMiddleware returns a Gin middleware that adds request_id to context and logs HTTP requests
 // Requires a Logger instance to be injected
@@ -24,8 +27,16 @@ func GinMiddleware(logger Logger) gin.HandlerFunc {
 		// Add request ID to Gin context
 		c.Set("request_id", requestID)
 
-		// Add request ID to request context
+		// A request without a correlation ID starts a new flow named after it
+		correlationID := c.GetHeader(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = requestID
+		}
+		c.Header(CorrelationIDHeader, correlationID)
+
+		// Add request and correlation IDs to request context
 		ctx := WithRequestID(c.Request.Context(), requestID)
+		ctx = WithCorrelationID(ctx, correlationID)
 		c.Request = c.Request.WithContext(ctx)
 
 		// Log request start
@@ -44,7 +55,9 @@ func GinMiddleware(logger Logger) gin.HandlerFunc {
 		// Process request
 		c.Next()
 
-		// Log request completion
+		// Log request completion with the context as left by later middleware,
+		// e.g. carrying the authenticated user id
+		ctx = c.Request.Context()
 		duration := time.Since(start)
 		statusCode := c.Writer.Status()
 
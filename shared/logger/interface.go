@@ -2,6 +2,8 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -40,6 +42,8 @@ type Config struct {
 	ServiceName string
 	Environment string
 	Level       Level
+	// Encoding is json or console; empty uses the default of Environment.
+	Encoding string
 }
 
 // Level represents log level
@@ -71,6 +75,16 @@ func (l Level) String() string {
 	}
 }
 
+// ParseLevel parses a level name as returned by Level.String.
+func ParseLevel(s string) (Level, error) {
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel} {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q, expected debug, info, warn, error or fatal", s)
+}
+
 // zapField wraps zap.Field to implement our Field interface
 type zapField struct {
 	field zap.Field
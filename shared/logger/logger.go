@@ -3,6 +3,8 @@ package logger
 import (
 	"context"
 
+	sharedconfig "observability-system/shared/config"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -24,14 +26,12 @@ func NewZapLogger(config Config) (Logger, error) {
 	zapConfig.EncoderConfig.LevelKey = "level"
 	zapConfig.EncoderConfig.CallerKey = "caller"
 
-	// Set log level based on environment
-	if config.Environment == "development" {
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-		zapConfig.Development = true
-		zapConfig.Encoding = "console"
-	} else {
-		zapConfig.Level = zap.NewAtomicLevelAt(toZapLevel(config.Level))
-		zapConfig.Encoding = "json"
+	env := sharedconfig.Environment(config.Environment)
+	zapConfig.Level = zap.NewAtomicLevelAt(toZapLevel(config.Level))
+	zapConfig.Development = env.IsDevelopment()
+	zapConfig.Encoding = config.Encoding
+	if zapConfig.Encoding == "" {
+		zapConfig.Encoding = env.Profile().LogEncoding
 	}
 
 	logger, err := zapConfig.Build(
@@ -59,10 +59,19 @@ func NewDefaultLogger(serviceName, environment string) (Logger, error) {
 	return NewZapLogger(Config{
 		ServiceName: serviceName,
 		Environment: environment,
-		Level:       InfoLevel,
+		Level:       DefaultLevel(environment),
 	})
 }
 
+// DefaultLevel is the level logged at in environment: debug in
+// development, info elsewhere.
+func DefaultLevel(environment string) Level {
+	if sharedconfig.Environment(environment).IsDevelopment() {
+		return DebugLevel
+	}
+	return InfoLevel
+}
+
 func toZapLevel(level Level) zapcore.Level {
 	switch level {
 	case DebugLevel:
@@ -117,6 +126,10 @@ func (l *zapLogger) WithContext(ctx context.Context) Logger {
 		logger = logger.With(zap.String("user_id", userID))
 	}
 
+	if correlationID := GetCorrelationID(ctx); correlationID != "" {
+		logger = logger.With(zap.String("correlation_id", correlationID))
+	}
+
 	return &zapLogger{
 		logger: logger,
 		config: l.config,
@@ -1,9 +1,16 @@
 package rabbitmq
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"observability-system/shared/messaging"
@@ -11,56 +18,265 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Delays between attempts to reconnect after the connection is lost,
+// doubling from the minimum to the maximum.
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// replySuccess is the AMQP reply code of a channel closed normally.
+const replySuccess = "200"
+
+// resolveTimeout bounds resolving the broker URL before dialing.
+const resolveTimeout = 10 * time.Second
+
+// Client publishes and consumes over one AMQP channel. When the broker
+// closes the channel it opens a new one, and when the connection is lost it
+// reconnects with backoff, resubscribing the queues it consumed.
 type Client struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	url     string
+	url func(context.Context) (string, error)
+	// compressThreshold, when positive, gzips message bodies of at least
+	// this many bytes.
+	compressThreshold int
+
+	mu            sync.RWMutex
+	conn          *amqp.Connection
+	channel       *amqp.Channel
+	subscriptions []subscription
+	closing       bool
+	done          chan struct{}
+}
+
+type subscription struct {
+	queue   string
+	handler messaging.MessageHandler
 }
 
 // NewClient creates a new RabbitMQ client
 func NewClient(url string) (*Client, error) {
+	return NewClientWithURL(func(context.Context) (string, error) {
+		return url, nil
+	})
+}
+
+// NewClientWithURL creates a client that gets the broker URL from url
+// every time it connects, so reconnects use rotated credentials.
+func NewClientWithURL(url func(context.Context) (string, error)) (*Client, error) {
+	client := &Client{
+		url:  url,
+		done: make(chan struct{}),
+	}
+	if err := client.connect(); err != nil {
+		return nil, err
+	}
+
+	log.Println("Successfully connected to RabbitMQ")
+	return client, nil
+}
+
+// connect dials the broker, opens the channel and consumes the subscribed
+// queues on it, then watches the connection until it is lost.
+func (c *Client) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	url, err := c.url(ctx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to resolve RabbitMQ URL: %w", err)
+	}
+
 	conn, err := amqp.Dial(url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
 	channel, err := conn.Channel()
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return fmt.Errorf("failed to open channel: %w", err)
 	}
+	ChannelsOpenedTotal.Inc()
 
-	client := &Client{
-		conn:    conn,
-		channel: channel,
-		url:     url,
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		conn.Close()
+		return errors.New("client closed")
 	}
+	c.conn, c.channel = conn, channel
+	subscriptions := append([]subscription(nil), c.subscriptions...)
+	c.mu.Unlock()
+	ConnectionUp.Set(1)
 
-	log.Println("Successfully connected to RabbitMQ")
-	return client, nil
+	resubscribe(channel, subscriptions)
+	go c.watch(conn, channel)
+	return nil
+}
+
+// watch replaces channels the broker closes while the connection stays up,
+// and reconnects once the connection is lost.
+func (c *Client) watch(conn *amqp.Connection, channel *amqp.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case err := <-channelClosed:
+			channelClosed = nil
+			recordChannelClose(err)
+			if err == nil {
+				return
+			}
+			log.Printf("RabbitMQ channel closed: %v", err)
+
+			// Fails when the connection went with the channel, which
+			// connClosed then reports.
+			if channel, err := c.reopenChannel(conn); err == nil {
+				channelClosed = channel.NotifyClose(make(chan *amqp.Error, 1))
+			}
+
+		case err := <-connClosed:
+			ConnectionUp.Set(0)
+			if channelClosed != nil {
+				// Closing the connection closes its channel too.
+				recordChannelClose(<-channelClosed)
+			}
+			if err == nil {
+				return
+			}
+			log.Printf("RabbitMQ connection lost: %v", err)
+			c.reconnect()
+			return
+		}
+	}
+}
+
+// recordChannelClose counts a channel closed with err, nil when the client
+// closed it.
+func recordChannelClose(err *amqp.Error) {
+	code := replySuccess
+	if err != nil {
+		code = strconv.Itoa(err.Code)
+	}
+	ChannelsClosedTotal.WithLabelValues(code).Inc()
+}
+
+// reopenChannel replaces the channel on conn and consumes the subscribed
+// queues on the new one.
+func (c *Client) reopenChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	ChannelsOpenedTotal.Inc()
+
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		channel.Close()
+		return nil, errors.New("client closed")
+	}
+	c.channel = channel
+	subscriptions := append([]subscription(nil), c.subscriptions...)
+	c.mu.Unlock()
+
+	log.Println("Reopened RabbitMQ channel")
+	resubscribe(channel, subscriptions)
+	return channel, nil
+}
+
+// reconnect retries connect with backoff until it succeeds or the client
+// is closed.
+func (c *Client) reconnect() {
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := c.connect(); err != nil {
+			ReconnectAttemptsTotal.WithLabelValues("failure").Inc()
+			delay = min(delay*2, reconnectMaxDelay)
+			log.Printf("Failed to reconnect to RabbitMQ, retrying in %s: %v", delay, err)
+			continue
+		}
+		ReconnectAttemptsTotal.WithLabelValues("success").Inc()
+		log.Println("Reconnected to RabbitMQ")
+		return
+	}
+}
+
+func resubscribe(channel *amqp.Channel, subscriptions []subscription) {
+	for _, sub := range subscriptions {
+		if err := consume(channel, sub); err != nil {
+			log.Printf("Failed to resubscribe to queue %s: %v", sub.queue, err)
+		}
+	}
+}
+
+// currentChannel returns the channel in use, which is replaced after the
+// broker closes it.
+func (c *Client) currentChannel() *amqp.Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.channel
+}
+
+// SetCompressionThreshold gzips published bodies of at least n bytes and marks
+// them with the gzip content encoding. Zero disables compression. Subscribers
+// decompress such deliveries regardless of their own threshold.
+func (c *Client) SetCompressionThreshold(n int) {
+	c.compressThreshold = n
 }
 
 // Publish publishes a message to an exchange
 func (c *Client) Publish(exchange, routingKey string, msg messaging.Message) error {
 	body, err := json.Marshal(msg)
 	if err != nil {
+		PublishFailuresTotal.WithLabelValues(PublishFailureMarshal).Inc()
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	err = c.channel.Publish(
+	var contentEncoding string
+	if c.compressThreshold > 0 && len(body) >= c.compressThreshold {
+		if body, err = gzipBody(body); err != nil {
+			PublishFailuresTotal.WithLabelValues(PublishFailureCompress).Inc()
+			return fmt.Errorf("failed to compress message: %w", err)
+		}
+		contentEncoding = "gzip"
+	}
+
+	var headers amqp.Table
+	if len(msg.Headers) > 0 {
+		headers = make(amqp.Table, len(msg.Headers))
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+	}
+
+	err = c.currentChannel().Publish(
 		exchange,   // exchange
 		routingKey, // routing key
 		false,      // mandatory
 		false,      // immediate
 		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-			Timestamp:    time.Now(),
+			ContentType:     "application/json",
+			ContentEncoding: contentEncoding,
+			Headers:         headers,
+			Body:            body,
+			DeliveryMode:    amqp.Persistent,
+			Timestamp:       time.Now(),
 		},
 	)
 
 	if err != nil {
+		reason := PublishFailureBroker
+		if errors.Is(err, amqp.ErrClosed) {
+			reason = PublishFailureClosed
+		}
+		PublishFailuresTotal.WithLabelValues(reason).Inc()
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
@@ -68,33 +284,64 @@ func (c *Client) Publish(exchange, routingKey string, msg messaging.Message) err
 	return nil
 }
 
-// Subscribe subscribes to a queue and processes messages
+// Subscribe subscribes to a queue and processes messages. The queue is
+// consumed again on every new channel after a channel or connection loss.
 func (c *Client) Subscribe(queue string, handler messaging.MessageHandler) error {
-	msgs, err := c.channel.Consume(
-		queue, // queue
-		"",    // consumer
-		false, // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // args
+	sub := subscription{queue: queue, handler: handler}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := consume(c.channel, sub); err != nil {
+		return err
+	}
+	c.subscriptions = append(c.subscriptions, sub)
+
+	log.Printf("Subscribed to queue: %s", queue)
+	return nil
+}
+
+// consume delivers the queue's messages on channel to the subscription's
+// handler until the channel closes.
+func consume(channel *amqp.Channel, sub subscription) error {
+	msgs, err := channel.Consume(
+		sub.queue, // queue
+		"",        // consumer
+		false,     // auto-ack
+		false,     // exclusive
+		false,     // no-local
+		false,     // no-wait
+		nil,       // args
 	)
 	if err != nil {
 		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	log.Printf("Subscribed to queue: %s", queue)
-
+	handler := sub.handler
 	go func() {
 		for d := range msgs {
+			body := d.Body
+			if d.ContentEncoding == "gzip" {
+				var err error
+				if body, err = gunzipBody(body); err != nil {
+					log.Printf("Failed to decompress message: %v", err)
+					d.Nack(false, false) // Reject message
+					continue
+				}
+			}
+
 			var msg messaging.Message
-			if err := json.Unmarshal(d.Body, &msg); err != nil {
+			if err := json.Unmarshal(body, &msg); err != nil {
 				log.Printf("Failed to unmarshal message: %v", err)
 				d.Nack(false, false) // Reject message
 				continue
 			}
 
 			if err := handler(msg); err != nil {
+				if errors.Is(err, messaging.ErrReject) {
+					log.Printf("Rejected message: %v", err)
+					d.Nack(false, false) // Reject message
+					continue
+				}
 				log.Printf("Failed to handle message: %v", err)
 				d.Nack(false, true) // Requeue message
 				continue
@@ -109,7 +356,7 @@ func (c *Client) Subscribe(queue string, handler messaging.MessageHandler) error
 
 // DeclareExchange declares an exchange
 func (c *Client) DeclareExchange(name, kind string) error {
-	return c.channel.ExchangeDeclare(
+	return c.currentChannel().ExchangeDeclare(
 		name,  // name
 		kind,  // type
 		true,  // durable
@@ -122,7 +369,7 @@ func (c *Client) DeclareExchange(name, kind string) error {
 
 // DeclareQueue declares a queue
 func (c *Client) DeclareQueue(name string) error {
-	_, err := c.channel.QueueDeclare(
+	_, err := c.currentChannel().QueueDeclare(
 		name,  // name
 		true,  // durable
 		false, // delete when unused
@@ -135,7 +382,7 @@ func (c *Client) DeclareQueue(name string) error {
 
 // BindQueue binds a queue to an exchange
 func (c *Client) BindQueue(queue, exchange, routingKey string) error {
-	return c.channel.QueueBind(
+	return c.currentChannel().QueueBind(
 		queue,      // queue name
 		routingKey, // routing key
 		exchange,   // exchange
@@ -144,15 +391,56 @@ func (c *Client) BindQueue(queue, exchange, routingKey string) error {
 	)
 }
 
-// Close closes the RabbitMQ connection
+// IsClosed reports whether the connection or its channel is closed, by
+// Close or by the broker, including while the client reconnects.
+func (c *Client) IsClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn == nil || c.conn.IsClosed() || c.channel == nil || c.channel.IsClosed()
+}
+
+// Close closes the RabbitMQ connection and stops reconnecting. Closing
+// again does nothing.
 func (c *Client) Close() error {
-	if c.channel != nil {
-		if err := c.channel.Close(); err != nil {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closing = true
+	close(c.done)
+	conn, channel := c.conn, c.channel
+	c.mu.Unlock()
+	ConnectionUp.Set(0)
+
+	if channel != nil && !channel.IsClosed() {
+		if err := channel.Close(); err != nil {
 			return err
 		}
 	}
-	if c.conn != nil {
-		return c.conn.Close()
+	if conn != nil && !conn.IsClosed() {
+		return conn.Close()
 	}
 	return nil
 }
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBody(body []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,66 @@
+package rabbitmq
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	ConnectionUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rabbitmq_connection_up",
+			Help: "Whether the client is connected to RabbitMQ (1) or not (0)",
+		},
+	)
+
+	ReconnectAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_reconnect_attempts_total",
+			Help: "Total number of attempts to reconnect to RabbitMQ after losing the connection, by result",
+		},
+		[]string{"result"},
+	)
+
+	ChannelsOpenedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_channels_opened_total",
+			Help: "Total number of AMQP channels opened",
+		},
+	)
+
+	ChannelsClosedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_channels_closed_total",
+			Help: "Total number of AMQP channels closed, by AMQP reply code; 200 is a close by the client",
+		},
+		[]string{"code"},
+	)
+
+	PublishFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_publish_failures_total",
+			Help: "Total number of messages that failed to publish, by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+// Reasons PublishFailuresTotal labels failed publishes with. Marshal and
+// compress failures are bugs in the message; the others are the broker's.
+const (
+	PublishFailureMarshal  = "marshal"
+	PublishFailureCompress = "compress"
+	// PublishFailureClosed labels publishes while disconnected.
+	PublishFailureClosed = "connection_closed"
+	// PublishFailureBroker labels publishes the broker refused.
+	PublishFailureBroker = "broker"
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ConnectionUp,
+		ReconnectAttemptsTotal,
+		ChannelsOpenedTotal,
+		ChannelsClosedTotal,
+		PublishFailuresTotal,
+	}
+}
@@ -10,6 +10,7 @@ func SetupExchangesAndQueues(client *Client) error {
 		{"orders", "topic"},
 		{"inventory", "topic"},
 		{"warehouse", "topic"},
+		{"payments", "topic"},
 	}
 
 	for _, ex := range exchanges {
@@ -26,7 +27,15 @@ func SetupExchangesAndQueues(client *Client) error {
 		"inventory.reserved",
 		"inventory.released",
 		"inventory.updated",
+		"inventory.low_stock",
+		"inventory.discrepancy",
+		"inventory.rejected",
 		"warehouse.test",
+		"warehouse.order.created",
+		"payment.authorized",
+		"payment.declined",
+		"payment.captured",
+		"payment.refunded",
 	}
 
 	for _, queue := range queues {
@@ -47,7 +56,17 @@ func SetupExchangesAndQueues(client *Client) error {
 		{"inventory.reserved", "inventory", "inventory.reserved"},
 		{"inventory.released", "inventory", "inventory.released"},
 		{"inventory.updated", "inventory", "inventory.updated"},
+		{"inventory.low_stock", "inventory", "inventory.low_stock"},
+		{"inventory.discrepancy", "inventory", "inventory.discrepancy"},
+		{"inventory.rejected", "inventory", "inventory.rejected"},
 		{"warehouse.test", "warehouse", "warehouse.test"},
+		// warehouse-service's own copy of order.created, so it does not
+		// compete with order-service for the order.created queue.
+		{"warehouse.order.created", "orders", "order.created"},
+		{"payment.authorized", "payments", "payment.authorized"},
+		{"payment.declined", "payments", "payment.declined"},
+		{"payment.captured", "payments", "payment.captured"},
+		{"payment.refunded", "payments", "payment.refunded"},
 	}
 
 	for _, binding := range bindings {
@@ -1,6 +1,13 @@
 package messaging
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrReject can be wrapped by a MessageHandler to have the delivery dropped
+// instead of requeued, for messages that can never be handled.
+var ErrReject = errors.New("message rejected")
 
 // Message represents a generic message structure
 type Message struct {
@@ -8,6 +15,11 @@ type Message struct {
 	Type      string                 `json:"type"`
 	Payload   map[string]interface{} `json:"payload"`
 	Timestamp time.Time              `json:"timestamp"`
+	Headers   map[string]string      `json:"headers,omitempty"`
+	// CorrelationID is shared by every message of one business flow and
+	// CausationID is the ID of the request or message that caused this one.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	CausationID   string `json:"causation_id,omitempty"`
 }
 
 // MessageHandler is a function that processes incoming messages
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthConfig protects the metrics endpoint. A scrape must present the
+// bearer token or the basic-auth credentials, whichever are set, and come
+// from an allowed network when AllowedNetworks is set.
+type AuthConfig struct {
+	BearerToken string
+	Username    string
+	Password    string
+	// AllowedNetworks are matched against the connection's peer address,
+	// not X-Forwarded-For, so a scrape through a proxy is allowed by the
+	// proxy's address.
+	AllowedNetworks []*net.IPNet
+}
+
+func (cfg AuthConfig) credentials() bool {
+	return cfg.BearerToken != "" || cfg.Username != ""
+}
+
+// ParseNetworks parses a comma-separated list of IP addresses and CIDR
+// ranges, e.g. "10.0.0.0/8,127.0.0.1".
+func ParseNetworks(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// AuthMiddleware rejects scrapes not allowed by cfg: 403 from a network
+// not allowed, 401 without valid credentials. It returns nil when cfg
+// protects nothing, leaving the endpoint open.
+func AuthMiddleware(cfg AuthConfig, log logger.Logger) gin.HandlerFunc {
+	if !cfg.credentials() && len(cfg.AllowedNetworks) == 0 {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		if len(cfg.AllowedNetworks) > 0 && !allowedIP(c.RemoteIP(), cfg.AllowedNetworks) {
+			log.WarnCtx(ctx, "Rejected metrics scrape from a network not allowed",
+				logger.String("remote_ip", c.RemoteIP()))
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.CodeForbidden, "Metrics are not served to this address"))
+			return
+		}
+
+		if cfg.credentials() && !authorized(c, cfg) {
+			log.WarnCtx(ctx, "Rejected metrics scrape without valid credentials",
+				logger.String("remote_ip", c.RemoteIP()))
+			if cfg.BearerToken != "" {
+				c.Header("WWW-Authenticate", "Bearer")
+			} else {
+				c.Header("WWW-Authenticate", `Basic realm="metrics"`)
+			}
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Metrics require valid credentials"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func authorized(c *gin.Context, cfg AuthConfig) bool {
+	if cfg.BearerToken != "" {
+		header := c.GetHeader("Authorization")
+		const prefix = "bearer "
+		if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) &&
+			equal(strings.TrimSpace(header[len(prefix):]), cfg.BearerToken) {
+			return true
+		}
+	}
+	if cfg.Username != "" {
+		if user, password, ok := c.Request.BasicAuth(); ok &&
+			equal(user, cfg.Username) && equal(password, cfg.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares secrets in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func allowedIP(remote string, networks []*net.IPNet) bool {
+	ip := net.ParseIP(remote)
+	if ip == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
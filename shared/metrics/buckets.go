@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseBuckets parses histogram bucket overrides written as
+// semicolon-separated "metric=bound,bound,..." entries, e.g.
+// "http_request_duration_seconds=0.001,0.01,0.1,1,30". Bounds must be
+// increasing, and only the registry's own histograms can be overridden.
+// An empty string overrides nothing.
+func ParseBuckets(s string) (map[string][]float64, error) {
+	buckets, err := parseMetricValues(s, "bucket bound")
+	if err != nil {
+		return nil, err
+	}
+
+	known := DefaultBuckets()
+	for name := range buckets {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown histogram %q", name)
+		}
+	}
+	return buckets, nil
+}
+
+// parseMetricValues parses semicolon-separated "metric=value,value,..."
+// entries whose values must be increasing numbers, naming them what in
+// errors.
+func parseMetricValues(s, what string) (map[string][]float64, error) {
+	values := map[string][]float64{}
+
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s entry %q is not metric=values", what, entry)
+		}
+		if _, ok := values[name]; ok {
+			return nil, fmt.Errorf("%ss for %q given twice", what, name)
+		}
+
+		var parsed []float64
+		for _, item := range strings.Split(list, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q for %s", what, item, name)
+			}
+			if len(parsed) > 0 && v <= parsed[len(parsed)-1] {
+				return nil, fmt.Errorf("%ss for %s must be increasing", what, name)
+			}
+			parsed = append(parsed, v)
+		}
+		values[name] = parsed
+	}
+	return values, nil
+}
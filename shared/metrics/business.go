@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Counter registers a counter of a service's business metrics, labelled
+// with the registry's service besides labels. Registering a counter again
+// with the same name, help and labels returns the one registered first, so
+// handlers and event processors can each register the metrics they use at
+// startup. Like MustRegister it panics on a conflicting definition.
+func (r *Registry) Counter(name, help string, labels ...string) *prometheus.CounterVec {
+	return mustRegisterOnce(r, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        name,
+			Help:        help,
+			ConstLabels: r.serviceLabel(),
+		},
+		labels,
+	))
+}
+
+// Gauge registers a gauge of a service's business metrics, like Counter.
+func (r *Registry) Gauge(name, help string, labels ...string) *prometheus.GaugeVec {
+	return mustRegisterOnce(r, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        name,
+			Help:        help,
+			ConstLabels: r.serviceLabel(),
+		},
+		labels,
+	))
+}
+
+// Histogram registers a histogram of a service's business metrics, like
+// Counter. Nil buckets use prometheus.DefBuckets. Config can give it native
+// buckets or make it a summary instead. Observe through Observe to attach
+// exemplars.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) prometheus.ObserverVec {
+	return mustRegisterOnce(r, r.observerVec(
+		prometheus.HistogramOpts{
+			Name:        name,
+			Help:        help,
+			Buckets:     buckets,
+			ConstLabels: r.serviceLabel(),
+		},
+		labels,
+	))
+}
+
+func (r *Registry) serviceLabel() prometheus.Labels {
+	return prometheus.Labels{"service": r.service}
+}
+
+// mustRegisterOnce registers c, or returns the collector of the same type
+// already registered under the same descriptor.
+func mustRegisterOnce[C prometheus.Collector](r *Registry, c C) C {
+	err := r.registry.Register(c)
+	if err == nil {
+		return c
+	}
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(C); ok {
+			return existing
+		}
+		panic(fmt.Errorf("metric already registered as a different type: %w", err))
+	}
+	panic(err)
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var exemplarsEnabled atomic.Bool
+
+// EnableExemplars makes Observe attach the trace ID of the observation's
+// span to histogram buckets, and registries created afterwards serve
+// /metrics in the OpenMetrics format that carries them. Prometheus only
+// stores exemplars with --enable-feature=exemplar-storage.
+func EnableExemplars(enabled bool) {
+	exemplarsEnabled.Store(enabled)
+}
+
+// ExemplarsEnabled reports whether EnableExemplars turned exemplars on.
+func ExemplarsEnabled() bool {
+	return exemplarsEnabled.Load()
+}
+
+// Observe records value on o. With exemplars enabled and a sampled span in
+// ctx, the span's trace ID is attached as the trace_id exemplar, so a
+// dashboard can jump from a latency bucket to a trace that landed in it.
+func Observe(ctx context.Context, o prometheus.Observer, value float64) {
+	if ExemplarsEnabled() {
+		sc := trace.SpanContextFromContext(ctx)
+		if eo, ok := o.(prometheus.ExemplarObserver); ok && sc.IsValid() && sc.IsSampled() {
+			eo.ObserveWithExemplar(value, prometheus.Labels{"trace_id": sc.TraceID().String()})
+			return
+		}
+	}
+	o.Observe(value)
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Native histogram settings: buckets about 10% wide, at most 160 of them,
+// widened no more than once an hour when the limit is hit.
+const (
+	nativeBucketFactor   = 1.1
+	nativeMaxBuckets     = 160
+	nativeMinResetPeriod = time.Hour
+)
+
+// ParseNativeHistograms parses a comma-separated list of histogram names
+// to expose with native buckets, e.g.
+// "http_request_duration_seconds,inbox_handler_duration_seconds".
+func ParseNativeHistograms(s string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("native histogram %q given twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ParseSummaries parses the histograms to replace with summaries as
+// semicolon-separated "metric=quantile,quantile,..." entries, e.g.
+// "http_request_duration_seconds=0.5,0.9,0.99". Quantiles must be
+// increasing and between 0 and 1.
+func ParseSummaries(s string) (map[string][]float64, error) {
+	summaries, err := parseMetricValues(s, "quantile")
+	if err != nil {
+		return nil, err
+	}
+	for name, quantiles := range summaries {
+		for _, q := range quantiles {
+			if q <= 0 || q >= 1 {
+				return nil, fmt.Errorf("quantile %g for %s must be between 0 and 1", q, name)
+			}
+		}
+	}
+	return summaries, nil
+}
+
+// observerVec builds the histogram opts describes, as Config selects for
+// it: a summary with client-side quantiles, or a histogram with native
+// buckets besides its classic ones.
+func (r *Registry) observerVec(opts prometheus.HistogramOpts, labels []string) prometheus.ObserverVec {
+	if quantiles, ok := r.summaries[opts.Name]; ok {
+		return prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name:        opts.Name,
+				Help:        opts.Help,
+				ConstLabels: opts.ConstLabels,
+				Objectives:  objectives(quantiles),
+			},
+			labels,
+		)
+	}
+
+	if b, ok := r.buckets[opts.Name]; ok {
+		opts.Buckets = b
+	}
+	if r.native[opts.Name] {
+		// Without explicit buckets a native histogram has no classic ones.
+		if opts.Buckets == nil {
+			opts.Buckets = prometheus.DefBuckets
+		}
+		opts.NativeHistogramBucketFactor = nativeBucketFactor
+		opts.NativeHistogramMaxBucketNumber = nativeMaxBuckets
+		opts.NativeHistogramMinResetDuration = nativeMinResetPeriod
+	}
+	return prometheus.NewHistogramVec(opts, labels)
+}
+
+// objectives allows each quantile an absolute error of a tenth of its
+// distance from 1, e.g. 0.99 ± 0.001.
+func objectives(quantiles []float64) map[float64]float64 {
+	o := make(map[float64]float64, len(quantiles))
+	for _, q := range quantiles {
+		o[q] = (1 - q) / 10
+	}
+	return o
+}
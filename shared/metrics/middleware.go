@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path labels of requests that have no route template of their own.
+const (
+	// UnmatchedPath labels requests matching no route, so 404s for
+	// arbitrary URLs do not each create a series.
+	UnmatchedPath = "unmatched"
+	// OverflowPath labels requests to routes first seen after the path
+	// label cap was reached.
+	OverflowPath = "overflow"
+	// OtherMethod labels requests with a non-standard method.
+	OtherMethod = "OTHER"
+)
+
+// Reasons http_requests_rejected_total labels requests turned away because
+// the service was saturated.
+const (
+	// RejectedTimeout labels requests that ran out of time.
+	RejectedTimeout = "timeout"
+	// RejectedRateLimited labels requests over a client's rate limit.
+	RejectedRateLimited = "rate_limited"
+	// RejectedOverloaded labels requests that found no free slot under a
+	// concurrency limit.
+	RejectedOverloaded = "overloaded"
+)
+
+// rejectionReasons maps the problem codes of saturation rejections to
+// their reason label.
+var rejectionReasons = map[problem.Code]string{
+	problem.CodeRequestTimeout: RejectedTimeout,
+	problem.CodeRateLimited:    RejectedRateLimited,
+	problem.CodeOverloaded:     RejectedOverloaded,
+}
+
+// Middleware counts and times every request by method, route and status,
+// and records the response size. Requests are labelled with their route
+// template, never their URL, and counted against the SLOs covering their
+// route. While a request runs it is counted in http_requests_in_flight, and
+// one rejected with a timeout, rate limit or overloaded problem is counted
+// in http_requests_rejected_total. Durations carry the request's trace ID
+// as an exemplar when exemplars are enabled.
+func (r *Registry) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		method := methodLabel(c.Request.Method)
+		route := c.FullPath()
+
+		path := route
+		if path == "" {
+			path = UnmatchedPath
+		} else if !r.paths.allow(path) {
+			path = OverflowPath
+			r.pathOverflow.Inc()
+		}
+
+		inFlight := r.inFlight.WithLabelValues(r.service, method, path)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		c.Next()
+
+		elapsed := time.Since(start)
+		status := strconv.Itoa(c.Writer.Status())
+
+		if route != "" {
+			r.recordSLOs(method, route, c.Writer.Status(), elapsed)
+		}
+		if code, ok := problem.CodeOf(c); ok {
+			if reason, ok := rejectionReasons[code]; ok {
+				r.rejected.WithLabelValues(r.service, method, path, reason).Inc()
+			}
+		}
+
+		r.requestsTotal.WithLabelValues(r.service, method, path, status).Inc()
+		Observe(c.Request.Context(), r.requestDuration.WithLabelValues(r.service, method, path), elapsed.Seconds())
+		r.responseSize.WithLabelValues(r.service, method, path).Observe(float64(c.Writer.Size()))
+	}
+}
+
+// methodLabel keeps the standard HTTP methods and folds any other into
+// OtherMethod, since unmatched requests can carry arbitrary methods.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return OtherMethod
+}
+
+// pathLimiter admits up to max distinct path labels, after which only the
+// paths already seen keep their own label.
+type pathLimiter struct {
+	max  int
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func newPathLimiter(max int) *pathLimiter {
+	return &pathLimiter{max: max, seen: map[string]struct{}{}}
+}
+
+func (l *pathLimiter) allow(path string) bool {
+	if l.max <= 0 {
+		return true
+	}
+
+	l.mu.RLock()
+	_, ok := l.seen[path]
+	l.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[path]; ok {
+		return true
+	}
+	if len(l.seen) >= l.max {
+		return false
+	}
+	l.seen[path] = struct{}{}
+	return true
+}
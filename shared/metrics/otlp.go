@@ -0,0 +1,298 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"observability-system/shared/logger"
+
+	dto "github.com/prometheus/client_model/go"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metrics backends a service can be configured with.
+const (
+	// BackendPrometheus serves the registry on /metrics for Prometheus to
+	// scrape.
+	BackendPrometheus = "prometheus"
+	// BackendOTLP also exports the registry over OTLP, to the collector
+	// that receives the traces.
+	BackendOTLP = "otlp"
+)
+
+// OTLPConfig points an OTLPExporter at an OTLP/HTTP collector.
+type OTLPConfig struct {
+	// Endpoint is the collector's host:port, as for the trace exporter.
+	Endpoint string
+	// Insecure sends over plain HTTP instead of HTTPS.
+	Insecure       bool
+	ServiceVersion string
+	Environment    string
+	// Interval between exports.
+	Interval time.Duration
+	// Timeout bounds each export.
+	Timeout time.Duration
+}
+
+func DefaultOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Insecure: true,
+		Interval: 15 * time.Second,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// OTLPExporter periodically exports a registry's metrics to an OTLP
+// collector, so deployments that route all telemetry through the collector
+// need no Prometheus scrape of the service. Counters become monotonic
+// cumulative sums, gauges gauges, and histograms and summaries keep their
+// buckets and quantiles. Prometheus labels become data point attributes.
+type OTLPExporter struct {
+	registry *Registry
+	client   *http.Client
+	url      string
+	resource *resourcepb.Resource
+	start    uint64
+	logger   logger.Logger
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewOTLPExporter exports the registry's metrics to cfg.Endpoint.
+func (r *Registry) NewOTLPExporter(cfg OTLPConfig, log logger.Logger) (*OTLPExporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("OTLP endpoint is required")
+	}
+	defaults := DefaultOTLPConfig()
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+
+	scheme := "https"
+	if cfg.Insecure {
+		scheme = "http"
+	}
+
+	return &OTLPExporter{
+		registry: r,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		url:      fmt.Sprintf("%s://%s/v1/metrics", scheme, cfg.Endpoint),
+		resource: &resourcepb.Resource{
+			Attributes: []*commonpb.KeyValue{
+				stringAttribute("service.name", r.service),
+				stringAttribute("service.version", cfg.ServiceVersion),
+				stringAttribute("environment", cfg.Environment),
+			},
+		},
+		start:    uint64(time.Now().UnixNano()),
+		logger:   log,
+		interval: cfg.Interval,
+		stopCh:   make(chan struct{}),
+	}, nil
+}
+
+// Start exports every Interval until ctx is done or Stop is called. Failed
+// exports are logged; the next one sends the cumulative values again.
+func (e *OTLPExporter) Start(ctx context.Context) {
+	e.logger.Info("Starting OTLP metrics exporter",
+		logger.String("url", e.url),
+		logger.String("interval", e.interval.String()))
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx); err != nil {
+				e.logger.Warn("Failed to export metrics", logger.Err(err))
+			}
+		}
+	}
+}
+
+// Stop ends Start and exports the final values.
+func (e *OTLPExporter) Stop(ctx context.Context) error {
+	select {
+	case <-e.stopCh:
+	default:
+		close(e.stopCh)
+	}
+	return e.Export(ctx)
+}
+
+// Export sends the current metrics once.
+func (e *OTLPExporter) Export(ctx context.Context) error {
+	families, err := e.registry.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	now := uint64(time.Now().UnixNano())
+	metrics := make([]*metricspb.Metric, 0, len(families))
+	for _, mf := range families {
+		if m := e.convert(mf, now); m != nil {
+			metrics = append(metrics, m)
+		}
+	}
+
+	body, err := proto.Marshal(&collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: e.resource,
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "observability-system/shared/metrics"},
+				Metrics: metrics,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export metrics: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// convert maps a Prometheus metric family to its OTLP equivalent, or nil
+// for types OTLP has no counterpart for.
+func (e *OTLPExporter) convert(mf *dto.MetricFamily, now uint64) *metricspb.Metric {
+	m := &metricspb.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}
+		for _, metric := range mf.GetMetric() {
+			sum.DataPoints = append(sum.DataPoints, e.numberPoint(metric, metric.GetCounter().GetValue(), now))
+		}
+		m.Data = &metricspb.Metric_Sum{Sum: sum}
+
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		gauge := &metricspb.Gauge{}
+		for _, metric := range mf.GetMetric() {
+			value := metric.GetGauge().GetValue()
+			if mf.GetType() == dto.MetricType_UNTYPED {
+				value = metric.GetUntyped().GetValue()
+			}
+			gauge.DataPoints = append(gauge.DataPoints, e.numberPoint(metric, value, now))
+		}
+		m.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		histogram := &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}
+		for _, metric := range mf.GetMetric() {
+			histogram.DataPoints = append(histogram.DataPoints, e.histogramPoint(metric, now))
+		}
+		m.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+
+	case dto.MetricType_SUMMARY:
+		summary := &metricspb.Summary{}
+		for _, metric := range mf.GetMetric() {
+			s := metric.GetSummary()
+			point := &metricspb.SummaryDataPoint{
+				Attributes:        attributes(metric),
+				StartTimeUnixNano: e.start,
+				TimeUnixNano:      now,
+				Count:             s.GetSampleCount(),
+				Sum:               s.GetSampleSum(),
+			}
+			for _, q := range s.GetQuantile() {
+				point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+					Quantile: q.GetQuantile(),
+					Value:    q.GetValue(),
+				})
+			}
+			summary.DataPoints = append(summary.DataPoints, point)
+		}
+		m.Data = &metricspb.Metric_Summary{Summary: summary}
+
+	default:
+		return nil
+	}
+	return m
+}
+
+func (e *OTLPExporter) numberPoint(metric *dto.Metric, value float64, now uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        attributes(metric),
+		StartTimeUnixNano: e.start,
+		TimeUnixNano:      now,
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramPoint turns Prometheus' cumulative buckets into OTLP's
+// per-bucket counts, with the +Inf bucket implied by the sample count.
+func (e *OTLPExporter) histogramPoint(metric *dto.Metric, now uint64) *metricspb.HistogramDataPoint {
+	h := metric.GetHistogram()
+	sum := h.GetSampleSum()
+	point := &metricspb.HistogramDataPoint{
+		Attributes:        attributes(metric),
+		StartTimeUnixNano: e.start,
+		TimeUnixNano:      now,
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+	}
+
+	var below uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, b.GetCumulativeCount()-below)
+		below = b.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, h.GetSampleCount()-below)
+	return point
+}
+
+func attributes(metric *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(metric.GetLabel()))
+	for _, l := range metric.GetLabel() {
+		attrs = append(attrs, stringAttribute(l.GetName(), l.GetValue()))
+	}
+	return attrs
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
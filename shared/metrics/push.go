@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"observability-system/shared/logger"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushConfig points a Pusher at a Prometheus Pushgateway.
+type PushConfig struct {
+	// URL of the Pushgateway, e.g. http://pushgateway:9091.
+	URL string
+	// Job groups the pushed metrics; defaults to the registry's service.
+	Job string
+	// Instance tells apart runs of the same job pushing at once; defaults
+	// to the hostname.
+	Instance string
+	// Interval pushes periodically while Start runs, for jobs that live
+	// long enough to want intermediate values. Zero pushes only on Stop.
+	Interval time.Duration
+	// Timeout bounds each push.
+	Timeout time.Duration
+}
+
+func DefaultPushConfig() PushConfig {
+	return PushConfig{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Pusher pushes a registry's metrics to a Pushgateway, for short-lived jobs
+// that exit before Prometheus would scrape them. Every push replaces the
+// metrics last pushed under the same job and instance.
+type Pusher struct {
+	pusher   *push.Pusher
+	logger   logger.Logger
+	job      string
+	instance string
+	interval time.Duration
+	timeout  time.Duration
+	stopCh   chan struct{}
+}
+
+// NewPusher pushes the registry's metrics, grouped by job and instance.
+func (r *Registry) NewPusher(cfg PushConfig, log logger.Logger) (*Pusher, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("pushgateway URL is required")
+	}
+	if cfg.Job == "" {
+		cfg.Job = r.service
+	}
+	if cfg.Instance == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve instance name: %w", err)
+		}
+		cfg.Instance = host
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultPushConfig().Timeout
+	}
+
+	return &Pusher{
+		pusher: push.New(cfg.URL, cfg.Job).
+			Gatherer(r.registry).
+			Grouping("instance", cfg.Instance).
+			Client(&http.Client{Timeout: cfg.Timeout}),
+		logger:   log,
+		job:      cfg.Job,
+		instance: cfg.Instance,
+		interval: cfg.Interval,
+		timeout:  cfg.Timeout,
+		stopCh:   make(chan struct{}),
+	}, nil
+}
+
+// Push sends the current metrics once.
+func (p *Pusher) Push(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if err := p.pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push metrics for job %s: %w", p.job, err)
+	}
+	return nil
+}
+
+// Start pushes every Interval until ctx is done or Stop is called. Failed
+// pushes are logged and retried on the next tick. With no Interval it only
+// waits for Stop.
+func (p *Pusher) Start(ctx context.Context) {
+	if p.interval <= 0 {
+		select {
+		case <-ctx.Done():
+		case <-p.stopCh:
+		}
+		return
+	}
+
+	p.logger.Info("Starting metrics pusher",
+		logger.String("job", p.job),
+		logger.String("instance", p.instance),
+		logger.String("interval", p.interval.String()))
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil {
+				p.logger.Warn("Failed to push metrics", logger.Err(err))
+			}
+		}
+	}
+}
+
+// Stop ends Start, if running, and pushes the final metrics, so a job
+// calls it just before exiting.
+func (p *Pusher) Stop(ctx context.Context) error {
+	select {
+	case <-p.stopCh:
+	default:
+		close(p.stopCh)
+	}
+	return p.Push(ctx)
+}
+
+// Delete removes the job's metrics from the Pushgateway, for jobs whose
+// last values should not outlive them.
+func (p *Pusher) Delete() error {
+	if err := p.pusher.Delete(); err != nil {
+		return fmt.Errorf("failed to delete metrics for job %s: %w", p.job, err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package metrics builds each service's Prometheus registry: the HTTP
+// request metrics every service exports, the Go runtime and process
+// metrics, and the business metrics the service registers on top. Every
+// Registry owns its collectors, so several services can run in one binary
+// or test without registering the same metric twice.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the metrics of one service.
+type Registry struct {
+	service   string
+	registry  *prometheus.Registry
+	exemplars bool
+
+	requestsTotal   *prometheus.CounterVec
+	requestDuration prometheus.ObserverVec
+	responseSize    prometheus.ObserverVec
+	inFlight        *prometheus.GaugeVec
+	rejected        *prometheus.CounterVec
+	pathOverflow    prometheus.Counter
+	paths           *pathLimiter
+
+	buckets   map[string][]float64
+	native    map[string]bool
+	summaries map[string][]float64
+
+	slos       []SLO
+	sloMetrics sloMetrics
+}
+
+// Names of the histograms every registry owns, whose buckets Config can
+// override.
+const (
+	HTTPRequestDuration = "http_request_duration_seconds"
+	HTTPResponseSize    = "http_response_size_bytes"
+)
+
+// Config describes a service's registry.
+type Config struct {
+	// Service labels the HTTP metrics.
+	Service string
+	// Buckets overrides the buckets of the registry's histograms by metric
+	// name. Metrics it leaves out keep DefaultBuckets.
+	Buckets map[string][]float64
+	// MaxPaths caps the distinct path labels of the HTTP metrics; requests
+	// to further routes are labelled OverflowPath. Zero leaves it uncapped.
+	MaxPaths int
+	// NativeHistograms names the histograms, the registry's own or those
+	// of Histogram, that also expose native histogram buckets for
+	// high-resolution quantiles. Prometheus only scrapes them with
+	// --enable-feature=native-histograms; the classic buckets stay.
+	NativeHistograms []string
+	// Summaries replaces the histograms it names with summaries computing
+	// the given quantiles client-side. Summaries can't be aggregated across
+	// instances or carry exemplars. They take precedence over
+	// NativeHistograms.
+	Summaries map[string][]float64
+}
+
+// DefaultBuckets returns the buckets the registry's histograms use unless
+// configured otherwise.
+func DefaultBuckets() map[string][]float64 {
+	return map[string][]float64{
+		HTTPRequestDuration: prometheus.DefBuckets,
+		HTTPResponseSize:    prometheus.ExponentialBuckets(100, 10, 8),
+	}
+}
+
+// NewRegistry builds a registry with the HTTP, Go runtime and process
+// metrics, labelling the HTTP metrics with the service.
+func NewRegistry(cfg Config) *Registry {
+	buckets := DefaultBuckets()
+	for name, b := range cfg.Buckets {
+		buckets[name] = b
+	}
+	native := make(map[string]bool, len(cfg.NativeHistograms))
+	for _, name := range cfg.NativeHistograms {
+		native[name] = true
+	}
+
+	r := &Registry{
+		service:   cfg.Service,
+		registry:  prometheus.NewRegistry(),
+		exemplars: ExemplarsEnabled(),
+		buckets:   buckets,
+		native:    native,
+		summaries: cfg.Summaries,
+
+		requestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests",
+			},
+			[]string{"service", "method", "path", "status"},
+		),
+		inFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "Number of HTTP requests currently being served",
+			},
+			[]string{"service", "method", "path"},
+		),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_rejected_total",
+				Help: "Total number of HTTP requests rejected because the service was saturated, by timeout, rate limit or concurrency limit",
+			},
+			[]string{"service", "method", "path", "reason"},
+		),
+		pathOverflow: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name:        "http_metrics_path_overflow_total",
+				Help:        "Total number of HTTP requests labelled with the overflow path because the path label cap was reached",
+				ConstLabels: prometheus.Labels{"service": cfg.Service},
+			},
+		),
+		paths:      newPathLimiter(cfg.MaxPaths),
+		sloMetrics: newSLOMetrics(),
+	}
+	r.requestDuration = r.observerVec(
+		prometheus.HistogramOpts{
+			Name: HTTPRequestDuration,
+			Help: "HTTP request duration in seconds",
+		},
+		[]string{"service", "method", "path"},
+	)
+	r.responseSize = r.observerVec(
+		prometheus.HistogramOpts{
+			Name: HTTPResponseSize,
+			Help: "HTTP response size in bytes",
+		},
+		[]string{"service", "method", "path"},
+	)
+
+	r.registry.MustRegister(
+		// Besides goroutines and memstats, export the runtime's GC pause
+		// and scheduler latency histograms (go_gc_pauses_seconds,
+		// go_sched_latencies_seconds), which the GC summary alone can't
+		// aggregate across instances.
+		collectors.NewGoCollector(
+			collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsScheduler),
+		),
+		// CPU time, resident memory and open file descriptors against their
+		// limit; only reported on Linux and Windows.
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.requestsTotal,
+		r.requestDuration,
+		r.responseSize,
+		r.inFlight,
+		r.rejected,
+		r.pathOverflow,
+		r.sloMetrics.requests,
+		r.sloMetrics.bad,
+		r.sloMetrics.objective,
+	)
+	return r
+}
+
+// Service returns the service the registry belongs to.
+func (r *Registry) Service() string {
+	return r.service
+}
+
+// MustRegister adds collectors built elsewhere, such as those of the shared
+// packages, and returns the registry. Like prometheus.MustRegister it
+// panics on a metric registered twice; Counter, Gauge and Histogram build
+// business metrics that may be registered more than once.
+func (r *Registry) MustRegister(cs ...prometheus.Collector) *Registry {
+	r.registry.MustRegister(cs...)
+	return r
+}
+
+// Gatherer reads the registered metrics, e.g. to assert on them in tests.
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.registry
+}
+
+// Handler serves the registered metrics for Prometheus to scrape, counting
+// its own scrapes as promhttp.Handler does. With exemplars enabled it
+// offers the OpenMetrics format, the only one that carries them.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(r.registry, promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: r.exemplars,
+	}))
+}
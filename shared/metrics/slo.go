@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SLO is a service level objective over some of a service's routes. Every
+// request it covers is counted in slo_requests_total, and the bad ones also
+// in slo_bad_requests_total, which is all SLORules needs for burn-rate
+// alerts.
+type SLO struct {
+	// Name identifies the SLO in the slo label and in alerts, e.g.
+	// "create-order-availability".
+	Name string
+	// Method and Routes select the requests covered by method and route
+	// template, e.g. "/api/v1/orders/:order_id". Empty covers every method
+	// or every matched route.
+	Method string
+	Routes []string
+	// Objective is the fraction of requests that must be good, e.g. 0.999.
+	Objective float64
+	// Latency makes this a latency SLO, where requests slower than it are
+	// bad. Without it requests answered with a 5xx status are bad.
+	Latency time.Duration
+}
+
+// ErrorBudget is the fraction of requests allowed to be bad.
+func (s SLO) ErrorBudget() float64 {
+	// Rounded so 1-0.999 reads as 0.001 in the generated rules.
+	return math.Round((1-s.Objective)*1e9) / 1e9
+}
+
+func (s SLO) covers(method, route string) bool {
+	if s.Method != "" && s.Method != method {
+		return false
+	}
+	if len(s.Routes) == 0 {
+		return true
+	}
+	for _, r := range s.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SLO) bad(status int, duration time.Duration) bool {
+	if s.Latency > 0 {
+		return duration > s.Latency
+	}
+	return status >= 500
+}
+
+func (s SLO) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("SLO name is required")
+	}
+	if s.Objective <= 0 || s.Objective >= 1 {
+		return fmt.Errorf("SLO %s: objective %s must be between 0 and 1", s.Name, strconv.FormatFloat(s.Objective, 'g', -1, 64))
+	}
+	if s.Latency < 0 {
+		return fmt.Errorf("SLO %s: latency must not be negative", s.Name)
+	}
+	return nil
+}
+
+// sloMetrics are the series every registry exports for its SLOs.
+type sloMetrics struct {
+	requests  *prometheus.CounterVec
+	bad       *prometheus.CounterVec
+	objective *prometheus.GaugeVec
+}
+
+func newSLOMetrics() sloMetrics {
+	return sloMetrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_requests_total",
+				Help: "Total number of requests covered by an SLO",
+			},
+			[]string{"service", "slo"},
+		),
+		bad: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_bad_requests_total",
+				Help: "Total number of requests covered by an SLO that failed it, by erroring or being too slow",
+			},
+			[]string{"service", "slo"},
+		),
+		objective: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "slo_objective",
+				Help: "Fraction of requests an SLO requires to be good",
+			},
+			[]string{"service", "slo"},
+		),
+	}
+}
+
+// MustAddSLOs has the middleware track slos. Like MustRegister it panics,
+// on an invalid SLO or a name used twice, and it must be called before the
+// registry's middleware serves requests.
+func (r *Registry) MustAddSLOs(slos ...SLO) *Registry {
+	for _, s := range slos {
+		if err := s.validate(); err != nil {
+			panic(err)
+		}
+		for _, existing := range r.slos {
+			if existing.Name == s.Name {
+				panic(fmt.Errorf("SLO %s defined twice", s.Name))
+			}
+		}
+		r.slos = append(r.slos, s)
+
+		r.sloMetrics.objective.WithLabelValues(r.service, s.Name).Set(s.Objective)
+		// Zero both counters so rates exist before the first bad request.
+		r.sloMetrics.requests.WithLabelValues(r.service, s.Name)
+		r.sloMetrics.bad.WithLabelValues(r.service, s.Name)
+	}
+	return r
+}
+
+// SLOs returns the SLOs added to the registry.
+func (r *Registry) SLOs() []SLO {
+	return r.slos
+}
+
+// recordSLOs counts a request matched to route against the SLOs covering it.
+func (r *Registry) recordSLOs(method, route string, status int, duration time.Duration) {
+	for _, s := range r.slos {
+		if !s.covers(method, route) {
+			continue
+		}
+		r.sloMetrics.requests.WithLabelValues(r.service, s.Name).Inc()
+		if s.bad(status, duration) {
+			r.sloMetrics.bad.WithLabelValues(r.service, s.Name).Inc()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// sloWindows are the windows the bad-request ratio is recorded over.
+var sloWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+// burnRateAlerts pair a long window, which makes an alert significant,
+// with a short one, which lets it resolve soon after the burn stops. Each
+// fires once the budget of a 30-day SLO window burns factor times faster
+// than sustainable.
+var burnRateAlerts = []struct {
+	severity    string
+	long, short string
+	factor      float64
+	forDuration string
+}{
+	{severity: "page", long: "1h", short: "5m", factor: 14.4, forDuration: "2m"},
+	{severity: "page", long: "6h", short: "30m", factor: 6, forDuration: "15m"},
+	{severity: "ticket", long: "1d", short: "2h", factor: 3, forDuration: "1h"},
+	{severity: "ticket", long: "3d", short: "6h", factor: 1, forDuration: "3h"},
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// SLORules generates the Prometheus rule file for a service's SLOs: the
+// bad-request ratio recorded as slo:bad_ratio:rate<window>, and the
+// multi-window burn-rate alerts of the Google SRE workbook, labelled with
+// severity page or ticket for Alertmanager to route.
+func SLORules(service string, slos []SLO) ([]byte, error) {
+	if service == "" {
+		return nil, fmt.Errorf("service is required")
+	}
+	if len(slos) == 0 {
+		return nil, fmt.Errorf("no SLOs defined for %s", service)
+	}
+
+	recording := ruleGroup{Name: service + "-slo-recording"}
+	for _, w := range sloWindows {
+		recording.Rules = append(recording.Rules, rule{
+			Record: "slo:bad_ratio:rate" + w,
+			Expr: fmt.Sprintf(`sum by (service, slo) (rate(slo_bad_requests_total{service=%q}[%s]))
+/
+sum by (service, slo) (rate(slo_requests_total{service=%q}[%s]))`, service, w, service, w),
+		})
+	}
+
+	alerts := ruleGroup{Name: service + "-slo-alerts"}
+	seen := map[string]bool{}
+	for _, s := range slos {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("SLO %s defined twice", s.Name)
+		}
+		seen[s.Name] = true
+
+		selector := fmt.Sprintf(`{service=%q, slo=%q}`, service, s.Name)
+		budget := s.ErrorBudget()
+		for _, a := range burnRateAlerts {
+			threshold := formatFloat(a.factor * budget)
+			alerts.Rules = append(alerts.Rules, rule{
+				Alert: "SLOErrorBudgetBurn",
+				Expr: fmt.Sprintf("slo:bad_ratio:rate%s%s > %s\nand\nslo:bad_ratio:rate%s%s > %s",
+					a.long, selector, threshold, a.short, selector, threshold),
+				For: a.forDuration,
+				Labels: map[string]string{
+					"severity":    a.severity,
+					"service":     service,
+					"slo":         s.Name,
+					"long_window": a.long,
+				},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("%s is burning the %s error budget %sx too fast", service, s.Name, formatFloat(a.factor)),
+					"description": fmt.Sprintf("Over the last %s and %s more than %s%% of the requests covered by %s were bad (objective %s%%). At this rate the 30-day error budget is spent in %.0fh.",
+						a.long, a.short, formatFloat(a.factor*budget*100), s.Name, formatFloat(s.Objective*100), 720/a.factor),
+				},
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by cmd/slo-rules from the SLOs of %s. Do not edit.\n", service)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(ruleFile{Groups: []ruleGroup{recording, alerts}}); err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formatFloat prints f without float noise, e.g. 14.4*0.001 as 0.0144.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
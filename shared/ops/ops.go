@@ -0,0 +1,259 @@
+// Package ops serves a service's operational snapshot: the backlog, failed
+// messages and workers of its inbox and outbox tables, and its most recent
+// records. The admin service collects the snapshots of every service into
+// one view, so an incident can be looked into without a database session.
+package ops
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/outboxinbox"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultLimit is how many failed messages per table, and records per
+	// list, a snapshot holds unless the request asks for another limit.
+	DefaultLimit = 10
+	// MaxLimit caps the limit a request may ask for.
+	MaxLimit = 100
+)
+
+// MessageStore is the part of an inbox or outbox store a snapshot reads.
+// Both SQL stores implement it.
+type MessageStore interface {
+	outboxinbox.StatsReader
+	ListDeadLetters(ctx context.Context, limit, offset int) ([]outboxinbox.DeadLetterMessage, error)
+	ListQuarantined(ctx context.Context, limit, offset int) ([]outboxinbox.QuarantinedMessage, error)
+}
+
+// FailedMessage is a dead-lettered or quarantined message, without its
+// payload; the admin routes of the service return the whole message.
+type FailedMessage struct {
+	ID         int64     `json:"id"`
+	MessageID  string    `json:"message_id"`
+	EventType  string    `json:"event_type"`
+	RetryCount int       `json:"retry_count"`
+	Error      string    `json:"error,omitempty"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// Table is the state of an inbox or outbox table and its workers.
+type Table struct {
+	Table string `json:"table"`
+	// Pool names the worker pool draining the table; Running is false when
+	// this instance runs none, e.g. the outbox without a broker.
+	Pool    string                     `json:"pool,omitempty"`
+	Running bool                       `json:"running"`
+	Workers []outboxinbox.WorkerStatus `json:"workers"`
+	// Counts holds the rows by status, as stored. Pending, Processing,
+	// Failed and Quarantined pick out the counts that need attention,
+	// whatever the table calls their statuses.
+	Counts                  map[string]int64 `json:"counts"`
+	Pending                 int64            `json:"pending"`
+	Processing              int64            `json:"processing"`
+	Failed                  int64            `json:"failed"`
+	Quarantined             int64            `json:"quarantined"`
+	OldestPendingAgeSeconds float64          `json:"oldest_pending_age_seconds"`
+	// DeadLetters and QuarantinedMessages are the latest failed messages,
+	// newest first.
+	DeadLetters         []FailedMessage `json:"dead_letters"`
+	QuarantinedMessages []FailedMessage `json:"quarantined_messages"`
+	// Errors lists the parts of the table that could not be read.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Record is a recent business record, e.g. an order, in a shape every
+// service shares, so the admin service can list them side by side.
+type Record struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Summary describes the record in a line, e.g. the product and
+	// quantity of an order.
+	Summary   string     `json:"summary"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// RecordList is a named list of recent records, newest first.
+type RecordList struct {
+	Name    string   `json:"name"`
+	Records []Record `json:"records"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// RecentFunc returns up to limit of the newest records of a kind.
+type RecentFunc func(ctx context.Context, limit int) ([]Record, error)
+
+// Snapshot is the body of a service's /internal/ops response.
+type Snapshot struct {
+	Service     string       `json:"service"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Tables      []Table      `json:"tables"`
+	Recent      []RecordList `json:"recent"`
+}
+
+type table struct {
+	pool  *outboxinbox.WorkerPool
+	store MessageStore
+}
+
+type recentList struct {
+	name string
+	fn   RecentFunc
+}
+
+// Reporter builds the snapshots of a service from the tables and record
+// lists registered with it.
+type Reporter struct {
+	service string
+	logger  logger.Logger
+	tables  []table
+	recent  []recentList
+}
+
+func NewReporter(service string, log logger.Logger) *Reporter {
+	return &Reporter{
+		service: service,
+		logger:  log,
+	}
+}
+
+// AddTable reports the table of store, drained by pool; a nil pool reports
+// the table without workers.
+func (r *Reporter) AddTable(pool *outboxinbox.WorkerPool, store MessageStore) {
+	r.tables = append(r.tables, table{pool: pool, store: store})
+}
+
+// AddRecent reports the records fn returns under name, e.g. "orders".
+func (r *Reporter) AddRecent(name string, fn RecentFunc) {
+	r.recent = append(r.recent, recentList{name: name, fn: fn})
+}
+
+// Snapshot reads every table and record list. Parts that fail are reported
+// in the snapshot rather than failing it, so one broken query still leaves
+// the rest to look at.
+func (r *Reporter) Snapshot(ctx context.Context, limit int) Snapshot {
+	snap := Snapshot{
+		Service:     r.service,
+		GeneratedAt: time.Now().UTC(),
+		Tables:      make([]Table, 0, len(r.tables)),
+		Recent:      make([]RecordList, 0, len(r.recent)),
+	}
+	for _, t := range r.tables {
+		snap.Tables = append(snap.Tables, r.table(ctx, t, limit))
+	}
+	for _, l := range r.recent {
+		list := RecordList{Name: l.name, Records: []Record{}}
+		records, err := l.fn(ctx, limit)
+		if err != nil {
+			r.logger.ErrorCtx(ctx, "Failed to read recent records",
+				logger.Err(err),
+				logger.String("list", l.name))
+			list.Error = "Failed to read " + l.name
+		} else if records != nil {
+			list.Records = records
+		}
+		snap.Recent = append(snap.Recent, list)
+	}
+	return snap
+}
+
+func (r *Reporter) table(ctx context.Context, t table, limit int) Table {
+	cfg := t.store.Config()
+	res := Table{
+		Table:               cfg.TableName,
+		Running:             t.pool != nil,
+		Workers:             []outboxinbox.WorkerStatus{},
+		Counts:              map[string]int64{},
+		DeadLetters:         []FailedMessage{},
+		QuarantinedMessages: []FailedMessage{},
+	}
+	if t.pool != nil {
+		res.Pool = t.pool.Name()
+		res.Workers = t.pool.Status()
+	}
+
+	fail := func(part string, err error) {
+		r.logger.ErrorCtx(ctx, "Failed to read message table",
+			logger.Err(err),
+			logger.String("table", cfg.TableName),
+			logger.String("part", part))
+		res.Errors = append(res.Errors, "Failed to read "+part)
+	}
+
+	if stats, err := t.store.Stats(ctx); err != nil {
+		fail("backlog", err)
+	} else {
+		for status, count := range stats.Counts {
+			res.Counts[string(status)] = count
+		}
+		res.Pending = stats.Counts[cfg.Statuses.Pending]
+		res.Processing = stats.Counts[cfg.Statuses.Processing]
+		res.Failed = stats.Counts[cfg.Statuses.Failed]
+		res.Quarantined = stats.Counts[cfg.Statuses.Quarantined]
+		res.OldestPendingAgeSeconds = stats.OldestPendingAge.Seconds()
+	}
+
+	if cfg.DeadLetterTableName != "" {
+		if dead, err := t.store.ListDeadLetters(ctx, limit, 0); err != nil {
+			fail("dead letters", err)
+		} else {
+			for _, m := range dead {
+				res.DeadLetters = append(res.DeadLetters, FailedMessage{
+					ID:         m.ID,
+					MessageID:  m.MessageID,
+					EventType:  m.EventType,
+					RetryCount: m.RetryCount,
+					Error:      deref(m.LastError),
+					FailedAt:   m.DeadAt,
+				})
+			}
+		}
+	}
+
+	if quarantined, err := t.store.ListQuarantined(ctx, limit, 0); err != nil {
+		fail("quarantined messages", err)
+	} else {
+		for _, m := range quarantined {
+			res.QuarantinedMessages = append(res.QuarantinedMessages, FailedMessage{
+				ID:         m.ID,
+				MessageID:  m.MessageID,
+				EventType:  m.EventType,
+				RetryCount: m.RetryCount,
+				Error:      deref(m.Error),
+				FailedAt:   m.UpdatedAt,
+			})
+		}
+	}
+	return res
+}
+
+// Handler serves the snapshot as JSON, with up to the limit query parameter
+// of failed messages per table and records per list. Guard it like
+// /metrics: error messages and records reveal customer and order details.
+func (r *Reporter) Handler(c *gin.Context) {
+	limit := DefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "limit must be a positive integer"))
+			return
+		}
+		limit = min(v, MaxLimit)
+	}
+	c.JSON(http.StatusOK, r.Snapshot(c.Request.Context(), limit))
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,53 @@
+package outboxinbox
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes the delay before a failed message becomes eligible
+// for another attempt: Base * Multiplier^retry, capped at Max, with up to
+// Jitter (a fraction of the delay) added or subtracted at random.
+type BackoffPolicy struct {
+	Base       time.Duration
+	Multiplier float64
+	Max        time.Duration
+	Jitter     float64
+}
+
+func DefaultBackoffPolicy() BackoffPolicy {
+	return BackoffPolicy{
+		Base:       time.Second,
+		Multiplier: 2,
+		Max:        5 * time.Minute,
+		Jitter:     0.2,
+	}
+}
+
+// Next returns the delay before attempt retryCount+1, where retryCount is the
+// number of attempts that have already failed.
+func (p BackoffPolicy) Next(retryCount int) time.Duration {
+	if p.Base <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.Base) * math.Pow(multiplier, float64(retryCount))
+	if p.Max > 0 && delay > float64(p.Max) {
+		delay = float64(p.Max)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
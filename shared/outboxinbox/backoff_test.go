@@ -0,0 +1,53 @@
+package outboxinbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffPolicyNext(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy BackoffPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{"first retry", BackoffPolicy{Base: time.Second, Multiplier: 2, Max: time.Minute}, 0, time.Second},
+		{"doubles", BackoffPolicy{Base: time.Second, Multiplier: 2, Max: time.Minute}, 3, 8 * time.Second},
+		{"capped", BackoffPolicy{Base: time.Second, Multiplier: 2, Max: time.Minute}, 10, time.Minute},
+		{"no cap", BackoffPolicy{Base: time.Second, Multiplier: 2}, 10, 1024 * time.Second},
+		{"huge retry count", BackoffPolicy{Base: time.Second, Multiplier: 2, Max: time.Minute}, 5000, time.Minute},
+		{"multiplier below one", BackoffPolicy{Base: time.Second, Multiplier: 0.5, Max: time.Minute}, 4, time.Second},
+		{"no base", BackoffPolicy{Multiplier: 2, Max: time.Minute}, 3, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Next(tc.retry); got != tc.want {
+				t.Errorf("Next(%d) = %s, want %s", tc.retry, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackoffPolicyJitter(t *testing.T) {
+	policy := BackoffPolicy{Base: time.Second, Multiplier: 2, Max: time.Minute, Jitter: 0.2}
+	for _, tc := range []struct {
+		retry int
+		base  time.Duration
+	}{{0, time.Second}, {2, 4 * time.Second}, {10, time.Minute}} {
+		retry, base := tc.retry, tc.base
+		low, high := base*8/10, base*12/10
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			got := policy.Next(retry)
+			if got < low || got > high {
+				t.Fatalf("Next(%d) = %s, want within [%s, %s]", retry, got, low, high)
+			}
+			seen[got] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("Next(%d) always returned %s, want jittered delays", retry, base)
+		}
+	}
+}
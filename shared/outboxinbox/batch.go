@@ -0,0 +1,86 @@
+package outboxinbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RetryUpdate schedules one message for another attempt in a batched retry
+// update.
+type RetryUpdate struct {
+	ID    int64
+	Error string
+	Delay time.Duration
+}
+
+func markBatchCompleted(ctx context.Context, db *sqlx.DB, qb queryBuilder, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := qb.build(`
+		UPDATE {table}
+		SET status = {completed},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL
+		WHERE id = ANY($1)
+	`)
+	_, err := db.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
+
+// incrementRetryBatch applies the same update as IncrementRetryAndMarkPending
+// to many rows in one statement.
+func incrementRetryBatch(ctx context.Context, db *sqlx.DB, qb queryBuilder, updates []RetryUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(updates))
+	errs := make([]string, len(updates))
+	delays := make([]int64, len(updates))
+	for i, u := range updates {
+		ids[i] = u.ID
+		errs[i] = u.Error
+		delays[i] = u.Delay.Milliseconds()
+	}
+
+	query := qb.build(`
+		UPDATE {table}
+		SET status = {pending},
+			retry_count = retry_count + 1,
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = u.error_msg,
+			error_history = COALESCE(error_history, '[]'::jsonb) || jsonb_build_array(jsonb_build_object(
+				'attempt', retry_count + 1, 'error', u.error_msg, 'failed_at', NOW())),
+			next_retry_at = NOW() + INTERVAL '1 millisecond' * u.delay_ms
+		FROM unnest($1::bigint[], $2::text[], $3::bigint[]) AS u(row_id, error_msg, delay_ms)
+		WHERE {table}.id = u.row_id
+	`)
+	_, err := db.ExecContext(ctx, query, pq.Array(ids), pq.Array(errs), pq.Array(delays))
+	return err
+}
+
+// deferBatch unlocks messages without counting an attempt, making them
+// eligible again after delay.
+func deferBatch(ctx context.Context, db *sqlx.DB, qb queryBuilder, ids []int64, delay time.Duration) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := qb.build(`
+		UPDATE {table}
+		SET status = {pending},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			next_retry_at = NOW() + INTERVAL '1 millisecond' * $2
+		WHERE id = ANY($1) AND status = {processing}
+	`)
+	_, err := db.ExecContext(ctx, query, pq.Array(ids), delay.Milliseconds())
+	return err
+}
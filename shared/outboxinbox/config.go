@@ -0,0 +1,126 @@
+// Package outboxinbox implements the transactional inbox and outbox patterns
+// on PostgreSQL. Services configure table names and status values and get a
+// shared store and worker implementation.
+package outboxinbox
+
+import (
+	"strings"
+	"time"
+)
+
+// Status is the value stored in the status column of an inbox/outbox row.
+type Status string
+
+// Statuses holds the status values written by the stores. Completed is the
+// terminal success state (PROCESSED for the inbox, PUBLISHED for the outbox).
+type Statuses struct {
+	Pending    Status
+	Processing Status
+	Completed  Status
+	Failed     Status
+	// Quarantined holds poison messages that are not retried.
+	Quarantined Status
+}
+
+// Config describes the table a store operates on.
+type Config struct {
+	// TableName must be a plain SQL identifier; it is interpolated into queries.
+	TableName       string
+	Statuses        Statuses
+	DefaultExchange string
+	// LockTimeout is how long a PROCESSING row may stay locked before it is
+	// considered abandoned and becomes eligible again.
+	LockTimeout time.Duration
+	// ArchiveTableName, when set, makes the retention janitor copy purged rows
+	// into this table instead of only deleting them.
+	ArchiveTableName string
+	// DeadLetterTableName, when set, receives messages that exhausted their
+	// retries instead of leaving them FAILED in the live table.
+	DeadLetterTableName string
+	// NotifyChannel, when set, installs an insert trigger that publishes new
+	// row ids on this Postgres channel so workers can wake without polling.
+	NotifyChannel string
+	// PartitionKeyField names a top-level payload field copied into the
+	// partition_key column on Save. Messages sharing a partition key are
+	// handed to workers strictly one at a time in insertion order.
+	PartitionKeyField string
+	// CompressThreshold, when positive, gzips payloads of at least this many
+	// bytes before they are stored. Readers decompress them transparently.
+	CompressThreshold int
+	// MaxPayloadSize, when positive, rejects payloads larger than this many
+	// bytes (before compression) with ErrPayloadTooLarge.
+	MaxPayloadSize int
+	// PartitionPeriod, when set, creates the outbox table partitioned by
+	// created_at in ranges of this period. Expired partitions are dropped by
+	// the janitor instead of deleting rows. Not supported for the inbox,
+	// whose deduplication needs message_id to be unique on its own.
+	PartitionPeriod PartitionPeriod
+}
+
+const DefaultLockTimeout = 5 * time.Minute
+
+func DefaultInboxConfig() Config {
+	return Config{
+		TableName: "inbox",
+		Statuses: Statuses{
+			Pending:     "PENDING",
+			Processing:  "PROCESSING",
+			Completed:   "PROCESSED",
+			Failed:      "FAILED",
+			Quarantined: "QUARANTINED",
+		},
+		DefaultExchange:     "orders",
+		LockTimeout:         DefaultLockTimeout,
+		DeadLetterTableName: "inbox_dead",
+		NotifyChannel:       "inbox_inserted",
+	}
+}
+
+func DefaultOutboxConfig() Config {
+	return Config{
+		TableName: "outbox",
+		Statuses: Statuses{
+			Pending:     "PENDING",
+			Processing:  "PROCESSING",
+			Completed:   "PUBLISHED",
+			Failed:      "FAILED",
+			Quarantined: "QUARANTINED",
+		},
+		DefaultExchange:     "orders",
+		LockTimeout:         DefaultLockTimeout,
+		DeadLetterTableName: "outbox_dead",
+		NotifyChannel:       "outbox_inserted",
+	}
+}
+
+// queryBuilder expands {table}, {archive}, {dead}, {channel}, {pending},
+// {processing}, {completed}, {failed}, {quarantined} and {exchange}
+// placeholders in SQL templates.
+type queryBuilder struct {
+	replacer *strings.Replacer
+}
+
+func newQueryBuilder(cfg Config) queryBuilder {
+	return queryBuilder{
+		replacer: strings.NewReplacer(
+			"{table}", cfg.TableName,
+			"{archive}", cfg.ArchiveTableName,
+			"{dead}", cfg.DeadLetterTableName,
+			"{channel}", cfg.NotifyChannel,
+			"{pending}", quote(cfg.Statuses.Pending),
+			"{processing}", quote(cfg.Statuses.Processing),
+			"{completed}", quote(cfg.Statuses.Completed),
+			"{failed}", quote(cfg.Statuses.Failed),
+			"{quarantined}", quote(cfg.Statuses.Quarantined),
+			"{exchange}", quote(Status(cfg.DefaultExchange)),
+		),
+	}
+}
+
+func (b queryBuilder) build(template string) string {
+	return b.replacer.Replace(template)
+}
+
+func quote(s Status) string {
+	return "'" + strings.ReplaceAll(string(s), "'", "''") + "'"
+}
@@ -0,0 +1,34 @@
+package outboxinbox
+
+import "testing"
+
+func TestQueryBuilder(t *testing.T) {
+	cfg := DefaultOutboxConfig()
+	cfg.ArchiveTableName = "outbox_archive"
+	cfg.Statuses.Failed = "FAILED'); DROP TABLE outbox; --"
+	qb := newQueryBuilder(cfg)
+
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{"SELECT * FROM {table}", "SELECT * FROM outbox"},
+		{"INSERT INTO {archive} SELECT * FROM {table}", "INSERT INTO outbox_archive SELECT * FROM outbox"},
+		{"INSERT INTO {dead}", "INSERT INTO outbox_dead"},
+		{"NOTIFY {channel}", "NOTIFY outbox_inserted"},
+		{
+			"status IN ({pending}, {processing}, {completed}, {quarantined})",
+			"status IN ('PENDING', 'PROCESSING', 'PUBLISHED', 'QUARANTINED')",
+		},
+		{"exchange = {exchange}", "exchange = 'orders'"},
+		// Status values are quoted as SQL literals.
+		{"status = {failed}", "status = 'FAILED''); DROP TABLE outbox; --'"},
+		{"{table} {table}.id {unknown}", "outbox outbox.id {unknown}"},
+	}
+
+	for _, tc := range cases {
+		if got := qb.build(tc.template); got != tc.want {
+			t.Errorf("build(%q) = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+}
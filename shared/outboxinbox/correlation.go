@@ -0,0 +1,100 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/messaging"
+	"observability-system/shared/tracing"
+)
+
+// flowIDs returns the correlation and causation IDs to store with a message
+// written in ctx. Both fall back to the request ID, so a message written while
+// serving an HTTP request starts from that request. Missing IDs are NULL.
+func flowIDs(ctx context.Context) (correlationID, causationID *string) {
+	requestID := logger.GetRequestID(ctx)
+	return nullIfEmpty(firstNonEmpty(logger.GetCorrelationID(ctx), requestID)),
+		nullIfEmpty(firstNonEmpty(logger.GetCausationID(ctx), requestID))
+}
+
+// messageContext returns ctx carrying msg's flow, so messages its handler
+// writes to the outbox share its correlation ID and name it as their cause.
+// A message that arrived without a correlation ID starts a flow of its own.
+func messageContext(ctx context.Context, msg InboxMessage) context.Context {
+	correlationID := msg.MessageID
+	if msg.CorrelationID != nil {
+		correlationID = *msg.CorrelationID
+	}
+	ctx = logger.WithCorrelationID(ctx, correlationID)
+	ctx = logger.WithCausationID(ctx, msg.MessageID)
+
+	// A malformed trace context only costs the link to the publisher's trace.
+	var headers map[string]string
+	if len(msg.TraceContext) > 0 && json.Unmarshal(msg.TraceContext, &headers) == nil {
+		ctx = tracing.ExtractTraceHeaders(ctx, headers)
+	}
+	return ctx
+}
+
+// deliveryContext carries the flow IDs and trace context of a broker delivery
+// into the inbox.
+func deliveryContext(ctx context.Context, msg messaging.Message) context.Context {
+	ctx = tracing.ExtractTraceHeaders(ctx, msg.Headers)
+	if msg.CorrelationID != "" {
+		ctx = logger.WithCorrelationID(ctx, msg.CorrelationID)
+	}
+	if msg.CausationID != "" {
+		ctx = logger.WithCausationID(ctx, msg.CausationID)
+	}
+	return ctx
+}
+
+// outboxTraceContext returns ctx continuing the trace that wrote msg, as
+// recorded in its headers.
+func outboxTraceContext(ctx context.Context, msg OutboxMessage) context.Context {
+	var headers map[string]string
+	if len(msg.Headers) > 0 && json.Unmarshal(msg.Headers, &headers) == nil {
+		ctx = tracing.ExtractTraceHeaders(ctx, headers)
+	}
+	return ctx
+}
+
+// traceHeaders returns the trace context of ctx to store with an inbox
+// message, or nil to store NULL when ctx is not traced.
+func traceHeaders(ctx context.Context) (interface{}, error) {
+	headers := map[string]string{}
+	tracing.InjectTraceHeaders(ctx, headers)
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trace context: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
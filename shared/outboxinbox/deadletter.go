@@ -0,0 +1,148 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const deadLetterSchema = `
+	CREATE TABLE IF NOT EXISTS {dead} (
+		id BIGSERIAL PRIMARY KEY,
+		source_id BIGINT NOT NULL,
+		message_id VARCHAR(255) UNIQUE NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		retry_count INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		error_history JSONB NOT NULL DEFAULT '[]',
+		row_data JSONB NOT NULL,
+		created_at TIMESTAMP,
+		dead_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_{dead}_event_type ON {dead}(event_type);
+	CREATE INDEX IF NOT EXISTS idx_{dead}_dead_at ON {dead}(dead_at);
+`
+
+// DeadLetterMessage is a message that exhausted its retries. RowData holds the
+// original row so it can be restored on requeue.
+type DeadLetterMessage struct {
+	ID           int64           `db:"id" json:"id"`
+	SourceID     int64           `db:"source_id" json:"source_id"`
+	MessageID    string          `db:"message_id" json:"message_id"`
+	EventType    string          `db:"event_type" json:"event_type"`
+	RetryCount   int             `db:"retry_count" json:"retry_count"`
+	LastError    *string         `db:"last_error" json:"last_error,omitempty"`
+	ErrorHistory json.RawMessage `db:"error_history" json:"error_history"`
+	Payload      Payload         `db:"payload" json:"payload"`
+	RowData      json.RawMessage `db:"row_data" json:"row_data,omitempty"`
+	CreatedAt    *time.Time      `db:"created_at" json:"created_at,omitempty"`
+	DeadAt       time.Time       `db:"dead_at" json:"dead_at"`
+}
+
+const deadLetterColumns = `id, source_id, message_id, event_type, retry_count, last_error,
+	error_history, row_data->'payload' AS payload, row_data, created_at, dead_at`
+
+// DeadLetterStore gives operators access to dead-lettered messages. Both
+// stores implement it for their own dead-letter table.
+type DeadLetterStore interface {
+	Config() Config
+	ListDeadLetters(ctx context.Context, limit, offset int) ([]DeadLetterMessage, error)
+	GetDeadLetter(ctx context.Context, id int64) (*DeadLetterMessage, error)
+	RequeueDeadLetter(ctx context.Context, id int64) (string, error)
+}
+
+// errorHistoryEntry appends the failure in $2 to a row's error_history.
+const errorHistoryEntry = `COALESCE(error_history, '[]'::jsonb) || jsonb_build_array(jsonb_build_object(
+	'attempt', retry_count + 1, 'error', $2::text, 'failed_at', NOW()))`
+
+func initDeadLetterSchema(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder) error {
+	if cfg.DeadLetterTableName == "" {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, qb.build(deadLetterSchema)); err != nil {
+		return fmt.Errorf("failed to initialize %s schema: %w", cfg.DeadLetterTableName, err)
+	}
+	return nil
+}
+
+// moveToDeadLetter removes the row from the live table and records it in the
+// dead-letter table together with the final error.
+func moveToDeadLetter(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, id int64, errorMsg string) error {
+	if cfg.DeadLetterTableName == "" {
+		return fmt.Errorf("no dead-letter table configured for %s", cfg.TableName)
+	}
+
+	query := qb.build(`
+		WITH dead AS (
+			DELETE FROM {table} WHERE id = $1
+			RETURNING *
+		)
+		INSERT INTO {dead} (source_id, message_id, event_type, retry_count, last_error, error_history, row_data, created_at)
+		SELECT id, message_id, event_type, retry_count + 1, $2, ` + errorHistoryEntry + `, to_jsonb(dead), created_at
+		FROM dead
+	`)
+	if _, err := db.ExecContext(ctx, query, id, errorMsg); err != nil {
+		return fmt.Errorf("failed to move message to %s: %w", cfg.DeadLetterTableName, err)
+	}
+	return nil
+}
+
+func listDeadLetters(ctx context.Context, db *sqlx.DB, qb queryBuilder, limit, offset int) ([]DeadLetterMessage, error) {
+	messages := []DeadLetterMessage{}
+	query := qb.build(`SELECT ` + deadLetterColumns + ` FROM {dead} ORDER BY dead_at DESC LIMIT $1 OFFSET $2`)
+	if err := db.SelectContext(ctx, &messages, query, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return messages, nil
+}
+
+func getDeadLetter(ctx context.Context, db *sqlx.DB, qb queryBuilder, id int64) (*DeadLetterMessage, error) {
+	var msg DeadLetterMessage
+	query := qb.build(`SELECT ` + deadLetterColumns + ` FROM {dead} WHERE id = $1`)
+	if err := db.GetContext(ctx, &msg, query, id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// requeueDeadLetter restores the original row as PENDING with a fresh retry
+// budget and removes it from the dead-letter table. It returns the message ID,
+// or sql.ErrNoRows when the dead letter does not exist. On a time-partitioned
+// table the row gets a new created_at, as its original partition may already
+// have been dropped.
+func requeueDeadLetter(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, id int64) (string, error) {
+	createdAt := ""
+	if cfg.PartitionPeriod != "" {
+		createdAt = `
+			'created_at', NOW(),`
+	}
+
+	query := qb.build(`
+		WITH requeued AS (
+			DELETE FROM {dead} WHERE id = $1
+			RETURNING row_data, error_history
+		)
+		INSERT INTO {table}
+		SELECT (jsonb_populate_record(NULL::{table}, row_data || jsonb_build_object(
+			'status', {pending},
+			'retry_count', 0,
+			'error', NULL,
+			'error_history', error_history,
+			'locked_at', NULL,
+			'locked_by', NULL,
+			'next_retry_at', NULL,` + createdAt + `
+			'updated_at', NOW()
+		))).*
+		FROM requeued
+		RETURNING message_id
+	`)
+
+	var messageID string
+	if err := db.GetContext(ctx, &messageID, query, id); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
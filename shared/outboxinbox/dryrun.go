@@ -0,0 +1,151 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"observability-system/shared/messaging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run. Handlers should skip side effects that
+// are not covered by a rolled-back transaction, such as HTTP calls.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx belongs to a simulated replay.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// Simulation outcomes, mirroring what a worker would do with the result.
+const (
+	OutcomeSuccess    = "success"
+	OutcomeRetry      = "retry"
+	OutcomeQuarantine = "quarantine"
+)
+
+// SimulationResult describes what processing a message would have done.
+type SimulationResult struct {
+	ID        int64  `json:"id"`
+	MessageID string `json:"message_id"`
+	EventType string `json:"event_type"`
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+	// Exchange, RoutingKey and Message are set for outbox simulations that
+	// would have been published.
+	Exchange   string             `json:"exchange,omitempty"`
+	RoutingKey string             `json:"routing_key,omitempty"`
+	Message    *messaging.Message `json:"message,omitempty"`
+}
+
+// Simulator runs a stored message through its processing path without any
+// lasting effect. A non-empty payload replaces the stored one for the
+// simulation only. It returns sql.ErrNoRows when the message does not exist.
+type Simulator interface {
+	Simulate(ctx context.Context, id int64, payload json.RawMessage) (*SimulationResult, error)
+}
+
+type inboxSimulator struct {
+	store     InboxStore
+	handler   MessageHandler
+	txHandler TxMessageHandler
+}
+
+// NewInboxSimulator simulates inbox messages with the handler the workers
+// use. The handler sees a dry-run context.
+func NewInboxSimulator(store InboxStore, handler MessageHandler) Simulator {
+	return &inboxSimulator{store: store, handler: handler}
+}
+
+// NewTxInboxSimulator is like NewInboxSimulator for transactional handlers,
+// whose transaction is always rolled back.
+func NewTxInboxSimulator(store InboxStore, handler TxMessageHandler) Simulator {
+	return &inboxSimulator{store: store, txHandler: handler}
+}
+
+func (s *inboxSimulator) Simulate(ctx context.Context, id int64, payload json.RawMessage) (*SimulationResult, error) {
+	msg, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		msg.Payload = Payload(payload)
+	}
+
+	ctx = WithDryRun(messageContext(ctx, *msg))
+	if s.txHandler != nil {
+		err = s.store.DryRunInTx(ctx, func(tx *sqlx.Tx) error {
+			return s.txHandler(ctx, tx, *msg)
+		})
+	} else {
+		err = s.handler(ctx, *msg)
+	}
+
+	return simulationResult(msg.ID, msg.MessageID, msg.EventType, err), nil
+}
+
+type outboxSimulator struct {
+	store OutboxStore
+}
+
+// NewOutboxSimulator reports the broker message an outbox row would be
+// published as, without publishing it.
+func NewOutboxSimulator(store OutboxStore) Simulator {
+	return &outboxSimulator{store: store}
+}
+
+func (s *outboxSimulator) Simulate(ctx context.Context, id int64, payload json.RawMessage) (*SimulationResult, error) {
+	msg, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		msg.Payload = Payload(payload)
+	}
+
+	pub, err := buildPublication(*msg, s.store.Config())
+	result := simulationResult(msg.ID, msg.MessageID, msg.EventType, err)
+	if err == nil {
+		result.Exchange = pub.Exchange
+		result.RoutingKey = pub.RoutingKey
+		result.Message = &pub.Message
+	}
+	return result, nil
+}
+
+func simulationResult(id int64, messageID, eventType string, err error) *SimulationResult {
+	result := &SimulationResult{
+		ID:        id,
+		MessageID: messageID,
+		EventType: eventType,
+		Outcome:   OutcomeSuccess,
+	}
+	switch {
+	case errors.Is(err, ErrPoisonMessage):
+		result.Outcome = OutcomeQuarantine
+		result.Error = err.Error()
+	case err != nil:
+		result.Outcome = OutcomeRetry
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// dryRunInTx runs fn in a transaction that is always rolled back.
+func dryRunInTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
@@ -0,0 +1,94 @@
+package outboxinbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/messaging"
+)
+
+// senderHeader lets publishers identify themselves; deliveries without it are
+// stored with the bridge's default sender.
+const senderHeader = "sender_id"
+
+// NewInboxConsumer returns a broker handler that stores each delivery in the
+// inbox for the workers to process. Redeliveries of a message that is already
+// stored are acknowledged rather than nacked. The broker only acks after the
+// handler returns, i.e. after the insert has committed.
+func NewInboxConsumer(store InboxStore, senderID string) messaging.MessageHandler {
+	return func(msg messaging.Message) error {
+		sender := senderID
+		if s := msg.Headers[senderHeader]; s != "" {
+			sender = s
+		}
+
+		messageID, err := deliveryMessageID(msg)
+		if err != nil {
+			return err
+		}
+
+		err = store.Save(deliveryContext(context.Background(), msg), sender, messageID, msg.Type, msg.Payload)
+		if errors.Is(err, ErrDuplicateMessage) {
+			return nil
+		}
+		if errors.Is(err, ErrPayloadTooLarge) {
+			return fmt.Errorf("%w: %w", messaging.ErrReject, err)
+		}
+		return err
+	}
+}
+
+// deliveryMessageID returns the message's own id, or a content hash for
+// publishers that don't set one so redeliveries still deduplicate.
+func deliveryMessageID(msg messaging.Message) (string, error) {
+	if msg.ID != "" {
+		return msg.ID, nil
+	}
+
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(msg.Type+":"), payload...))
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// InboxBridge subscribes to broker queues and persists every delivery into the
+// inbox, so the inbox workers are the only place handlers run.
+type InboxBridge struct {
+	consumer messaging.Consumer
+	store    InboxStore
+	logger   logger.Logger
+	senderID string
+}
+
+func NewInboxBridge(consumer messaging.Consumer, store InboxStore, log logger.Logger, senderID string) *InboxBridge {
+	return &InboxBridge{
+		consumer: consumer,
+		store:    store,
+		logger:   log,
+		senderID: senderID,
+	}
+}
+
+// Start subscribes to each queue. Deliveries are consumed in the background
+// until the consumer is closed.
+func (b *InboxBridge) Start(queues ...string) error {
+	handler := NewInboxConsumer(b.store, b.senderID)
+
+	for _, queue := range queues {
+		if err := b.consumer.Subscribe(queue, handler); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", queue, err)
+		}
+
+		b.logger.Info("Inbox bridge subscribed",
+			logger.String("queue", queue),
+			logger.String("table", b.store.Config().TableName))
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrDuplicateMessage is returned by InboxStore.Save when a message with the
+// same message_id was already stored. Consumers should treat it as success:
+// deduplication is what the inbox is for.
+var ErrDuplicateMessage = errors.New("message already exists")
+
+// InboxStore persists incoming messages and hands them out to workers.
+type InboxStore interface {
+	Config() Config
+	InitSchema(ctx context.Context) error
+	Save(ctx context.Context, senderID, messageID, eventType string, payload interface{}) error
+	GetByID(ctx context.Context, id int64) (*InboxMessage, error)
+	GetByMessageID(ctx context.Context, messageID string) (*InboxMessage, error)
+	GetAll(ctx context.Context) ([]InboxMessage, error)
+	List(ctx context.Context, filter ListFilter) ([]InboxMessage, error)
+	MessageExists(ctx context.Context, messageID string) (bool, error)
+	GetPendingMessagesForProcessing(ctx context.Context, workerID string, batchSize int, maxRetries int) ([]InboxMessage, error)
+	MarkAsProcessed(ctx context.Context, id int64) error
+	MarkBatchAsProcessed(ctx context.Context, ids []int64) error
+	ProcessInTx(ctx context.Context, id int64, workerID string, fn func(tx *sqlx.Tx) error) error
+	DryRunInTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error
+	IncrementRetryAndMarkPending(ctx context.Context, id int64, errorMsg string, delay time.Duration) error
+	IncrementRetryBatch(ctx context.Context, updates []RetryUpdate) error
+	DeferBatch(ctx context.Context, ids []int64, delay time.Duration) error
+	MarkAsFailed(ctx context.Context, id int64, errorMsg string) error
+	Quarantine(ctx context.Context, id int64, reason string) error
+	ResetStuckMessages(ctx context.Context) (int64, error)
+	ReapStuckMessages(ctx context.Context, lockTimeout time.Duration) ([]StuckMessage, error)
+	PurgeCompleted(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+	MoveToDeadLetter(ctx context.Context, id int64, errorMsg string) error
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+// SQLInboxStore is the PostgreSQL implementation of InboxStore.
+type SQLInboxStore struct {
+	db  *sqlx.DB
+	cfg Config
+	qb  queryBuilder
+}
+
+func NewInboxStore(db *sqlx.DB, cfg Config) *SQLInboxStore {
+	return &SQLInboxStore{
+		db:  db,
+		cfg: cfg,
+		qb:  newQueryBuilder(cfg),
+	}
+}
+
+func (s *SQLInboxStore) Config() Config {
+	return s.cfg
+}
+
+func (s *SQLInboxStore) InitSchema(ctx context.Context) error {
+	if s.cfg.PartitionPeriod != "" {
+		return fmt.Errorf("time partitioning is not supported for inbox table %s", s.cfg.TableName)
+	}
+	if _, err := s.db.ExecContext(ctx, s.qb.build(inboxSchema+statusNormalization)); err != nil {
+		return fmt.Errorf("failed to initialize %s schema: %w", s.cfg.TableName, err)
+	}
+	if err := initArchiveSchema(ctx, s.db, s.cfg, s.qb); err != nil {
+		return err
+	}
+	if err := initNotifyTrigger(ctx, s.db, s.cfg, s.qb); err != nil {
+		return err
+	}
+	return initDeadLetterSchema(ctx, s.db, s.cfg, s.qb)
+}
+
+func (s *SQLInboxStore) PurgeCompleted(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	return purgeCompleted(ctx, s.db, s.cfg, s.qb, olderThan, limit)
+}
+
+func (s *SQLInboxStore) Stats(ctx context.Context) (*Stats, error) {
+	return readStats(ctx, s.db, s.qb)
+}
+
+func (s *SQLInboxStore) Save(ctx context.Context, senderID, messageID, eventType string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	partitionKey := partitionKeyFromPayload(payloadJSON, s.cfg.PartitionKeyField)
+	payloadJSON, err = encodePayload(payloadJSON, s.cfg)
+	if err != nil {
+		return err
+	}
+
+	if senderID == "" {
+		senderID = "unknown"
+	}
+	correlationID, causationID := flowIDs(ctx)
+	traceContext, err := traceHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := s.qb.build(`
+		INSERT INTO {table} (sender_id, message_id, event_type, payload, status, partition_key, correlation_id, causation_id, trace_context)
+		VALUES ($1, $2, $3, $4, {pending}, $5, $6, $7, $8)
+		ON CONFLICT (message_id) DO NOTHING
+	`)
+	result, err := s.db.ExecContext(ctx, query, senderID, messageID, eventType, payloadJSON, partitionKey, correlationID, causationID, traceContext)
+	if err != nil {
+		return fmt.Errorf("failed to save inbox message: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateMessage, messageID)
+	}
+
+	return nil
+}
+
+func (s *SQLInboxStore) GetByID(ctx context.Context, id int64) (*InboxMessage, error) {
+	var msg InboxMessage
+	query := s.qb.build(`SELECT ` + inboxColumns + ` FROM {table} WHERE id = $1`)
+	if err := s.db.GetContext(ctx, &msg, query, id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *SQLInboxStore) GetByMessageID(ctx context.Context, messageID string) (*InboxMessage, error) {
+	var msg InboxMessage
+	query := s.qb.build(`SELECT ` + inboxColumns + ` FROM {table} WHERE message_id = $1`)
+	if err := s.db.GetContext(ctx, &msg, query, messageID); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *SQLInboxStore) GetAll(ctx context.Context) ([]InboxMessage, error) {
+	messages := []InboxMessage{}
+	query := s.qb.build(`SELECT ` + inboxColumns + ` FROM {table} ORDER BY created_at DESC LIMIT 100`)
+	if err := s.db.SelectContext(ctx, &messages, query); err != nil {
+		return nil, fmt.Errorf("failed to list inbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *SQLInboxStore) List(ctx context.Context, filter ListFilter) ([]InboxMessage, error) {
+	messages := []InboxMessage{}
+	if err := listMessages(ctx, s.db, s.qb, inboxColumns, filter, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *SQLInboxStore) MessageExists(ctx context.Context, messageID string) (bool, error) {
+	var exists bool
+	query := s.qb.build(`SELECT EXISTS(SELECT 1 FROM {table} WHERE message_id = $1)`)
+	err := s.db.GetContext(ctx, &exists, query, messageID)
+	return exists, err
+}
+
+func (s *SQLInboxStore) GetPendingMessagesForProcessing(ctx context.Context, workerID string, batchSize int, maxRetries int) ([]InboxMessage, error) {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET
+			status = {processing},
+			locked_at = NOW(),
+			locked_by = $1,
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM {table}
+			WHERE (status = {pending} OR (status = {failed} AND retry_count < $3))
+			  AND (locked_at IS NULL OR locked_at < NOW() - INTERVAL '1 second' * $4)
+			  AND (next_retry_at IS NULL OR next_retry_at <= NOW())
+			  AND ` + partitionReadyClause + `
+			ORDER BY created_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + inboxColumns)
+
+	var messages []InboxMessage
+	err := s.db.SelectContext(ctx, &messages, query, workerID, batchSize, maxRetries, s.cfg.LockTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (s *SQLInboxStore) MarkAsProcessed(ctx context.Context, id int64) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {completed},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *SQLInboxStore) MarkBatchAsProcessed(ctx context.Context, ids []int64) error {
+	return markBatchCompleted(ctx, s.db, s.qb, ids)
+}
+
+// ProcessInTx runs fn and marks the message processed in the same
+// transaction. It returns ErrLockLost if workerID no longer holds the lock.
+func (s *SQLInboxStore) ProcessInTx(ctx context.Context, id int64, workerID string, fn func(tx *sqlx.Tx) error) error {
+	return processInTx(ctx, s.db, s.qb, id, workerID, fn)
+}
+
+// DryRunInTx runs fn in a transaction that is always rolled back.
+func (s *SQLInboxStore) DryRunInTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	return dryRunInTx(ctx, s.db, fn)
+}
+
+func (s *SQLInboxStore) IncrementRetryBatch(ctx context.Context, updates []RetryUpdate) error {
+	return incrementRetryBatch(ctx, s.db, s.qb, updates)
+}
+
+// DeferBatch returns claimed messages to PENDING without counting an attempt,
+// e.g. when an event type is over its processing limit.
+func (s *SQLInboxStore) DeferBatch(ctx context.Context, ids []int64, delay time.Duration) error {
+	return deferBatch(ctx, s.db, s.qb, ids, delay)
+}
+
+// IncrementRetryAndMarkPending returns a failed message to PENDING and hides
+// it from workers until delay has elapsed.
+func (s *SQLInboxStore) IncrementRetryAndMarkPending(ctx context.Context, id int64, errorMsg string, delay time.Duration) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {pending},
+			retry_count = retry_count + 1,
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = $2,
+			error_history = ` + errorHistoryEntry + `,
+			next_retry_at = NOW() + INTERVAL '1 millisecond' * $3
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id, errorMsg, delay.Milliseconds())
+	return err
+}
+
+// Quarantine parks a poison message so it is neither retried nor
+// dead-lettered until an operator resubmits it.
+func (s *SQLInboxStore) Quarantine(ctx context.Context, id int64, reason string) error {
+	return quarantine(ctx, s.db, s.qb, id, reason)
+}
+
+func (s *SQLInboxStore) ListQuarantined(ctx context.Context, limit, offset int) ([]QuarantinedMessage, error) {
+	return listQuarantined(ctx, s.db, s.qb, `'{}'::jsonb`, limit, offset)
+}
+
+func (s *SQLInboxStore) GetQuarantined(ctx context.Context, id int64) (*QuarantinedMessage, error) {
+	return getQuarantined(ctx, s.db, s.qb, `'{}'::jsonb`, id)
+}
+
+func (s *SQLInboxStore) MarkAsFailed(ctx context.Context, id int64, errorMsg string) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {failed},
+			retry_count = retry_count + 1,
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = $2,
+			error_history = ` + errorHistoryEntry + `
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id, errorMsg)
+	return err
+}
+
+// ResetStuckMessages returns rows that have been PROCESSING for longer than
+// the configured lock timeout to PENDING.
+func (s *SQLInboxStore) ResetStuckMessages(ctx context.Context) (int64, error) {
+	messages, err := s.ReapStuckMessages(ctx, s.cfg.LockTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(messages)), nil
+}
+
+// ReapStuckMessages is like ResetStuckMessages with an explicit lock timeout,
+// and reports the worker that held each stale lock.
+func (s *SQLInboxStore) ReapStuckMessages(ctx context.Context, lockTimeout time.Duration) ([]StuckMessage, error) {
+	return reapStuckMessages(ctx, s.db, s.qb, lockTimeout)
+}
+
+func (s *SQLInboxStore) MoveToDeadLetter(ctx context.Context, id int64, errorMsg string) error {
+	return moveToDeadLetter(ctx, s.db, s.cfg, s.qb, id, errorMsg)
+}
+
+func (s *SQLInboxStore) ListDeadLetters(ctx context.Context, limit, offset int) ([]DeadLetterMessage, error) {
+	return listDeadLetters(ctx, s.db, s.qb, limit, offset)
+}
+
+func (s *SQLInboxStore) GetDeadLetter(ctx context.Context, id int64) (*DeadLetterMessage, error) {
+	return getDeadLetter(ctx, s.db, s.qb, id)
+}
+
+func (s *SQLInboxStore) RequeueDeadLetter(ctx context.Context, id int64) (string, error) {
+	return requeueDeadLetter(ctx, s.db, s.cfg, s.qb, id)
+}
+
+func (s *SQLInboxStore) Replay(ctx context.Context, id int64, payload json.RawMessage) (string, error) {
+	return replay(ctx, s.db, s.cfg, s.qb, id, payload)
+}
+
+func (s *SQLInboxStore) ReplayByFilter(ctx context.Context, filter ReplayFilter) (int64, error) {
+	return replayByFilter(ctx, s.db, s.cfg, s.qb, filter)
+}
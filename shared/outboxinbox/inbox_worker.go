@@ -0,0 +1,368 @@
+package outboxinbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/metrics"
+	"observability-system/shared/tracing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MessageHandler processes a single inbox message. Returning an error schedules
+// a retry until the worker's max retries are exhausted.
+type MessageHandler func(ctx context.Context, msg InboxMessage) error
+
+// throttleDelay is how long a message deferred by the event limiter waits
+// before it becomes eligible again.
+const throttleDelay = time.Second
+
+type InboxWorker struct {
+	store      InboxStore
+	logger     logger.Logger
+	workerID   string
+	batchSize  int
+	interval   time.Duration
+	poll       pollSchedule
+	maxRetries int
+	backoff    BackoffPolicy
+	stopCh     chan struct{}
+	activity   workerActivity
+	wakeCh     <-chan struct{}
+	limiter    *EventLimiter
+	handler    MessageHandler
+	txHandler  TxMessageHandler
+}
+
+func NewInboxWorker(
+	store InboxStore,
+	handler MessageHandler,
+	log logger.Logger,
+	batchSize int,
+	interval time.Duration,
+	maxRetries int,
+	backoff BackoffPolicy,
+) *InboxWorker {
+	return &InboxWorker{
+		store:      store,
+		logger:     log,
+		workerID:   fmt.Sprintf("inbox-worker-%s", uuid.New().String()[:8]),
+		batchSize:  batchSize,
+		interval:   interval,
+		poll:       newPollSchedule(interval, defaultMaxIdleMultiple*interval),
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		stopCh:     make(chan struct{}),
+		handler:    handler,
+	}
+}
+
+// NewTxInboxWorker is like NewInboxWorker, but runs handler inside the
+// transaction that marks each message processed.
+func NewTxInboxWorker(
+	store InboxStore,
+	handler TxMessageHandler,
+	log logger.Logger,
+	batchSize int,
+	interval time.Duration,
+	maxRetries int,
+	backoff BackoffPolicy,
+) *InboxWorker {
+	w := NewInboxWorker(store, nil, log, batchSize, interval, maxRetries, backoff)
+	w.txHandler = handler
+	return w
+}
+
+func (w *InboxWorker) ID() string {
+	return w.workerID
+}
+
+func (w *InboxWorker) Status() WorkerStatus {
+	return w.activity.status(w.workerID)
+}
+
+// SetMaxIdleInterval caps how far the poll interval grows while polls keep
+// returning nothing. A value at or below the base interval disables the
+// adaptive backoff. Call it before Start.
+func (w *InboxWorker) SetMaxIdleInterval(max time.Duration) {
+	w.poll = newPollSchedule(w.interval, max)
+}
+
+// SetWakeup makes the worker poll immediately whenever ch fires, in addition
+// to its regular interval. Call it before Start.
+func (w *InboxWorker) SetWakeup(ch <-chan struct{}) {
+	w.wakeCh = ch
+}
+
+// SetLimiter applies per-event-type concurrency and rate limits. Messages over
+// their limit are deferred without counting an attempt. Share one limiter
+// between the workers of a pool. Call it before Start.
+func (w *InboxWorker) SetLimiter(l *EventLimiter) {
+	w.limiter = l
+}
+
+func (w *InboxWorker) Start(ctx context.Context) {
+	w.logger.Info("Starting inbox worker",
+		logger.String("worker_id", w.workerID),
+		logger.Int("batch_size", w.batchSize),
+		logger.Int("max_retries", w.maxRetries),
+		logger.String("interval", w.interval.String()),
+		logger.String("max_idle_interval", w.poll.policy.Max.String()))
+
+	timer := time.NewTimer(w.poll.first())
+	defer timer.Stop()
+
+	// Reset stuck messages on startup
+	if count, err := w.store.ResetStuckMessages(ctx); err != nil {
+		w.logger.Error("Failed to reset stuck messages", logger.Err(err))
+	} else if count > 0 {
+		w.logger.Info("Reset stuck messages", logger.Int64("count", count))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Stopping inbox worker due to context cancellation",
+				logger.String("worker_id", w.workerID))
+			return
+		case <-w.stopCh:
+			w.logger.Info("Inbox worker stopped",
+				logger.String("worker_id", w.workerID))
+			return
+		case <-timer.C:
+			timer.Reset(w.poll.next(w.processMessages(ctx), w.batchSize))
+		case <-w.wakeCh:
+			timer.Reset(w.poll.next(w.processMessages(ctx), w.batchSize))
+		}
+	}
+}
+
+func (w *InboxWorker) Stop() {
+	close(w.stopCh)
+}
+
+// processMessages handles one batch and returns how many messages it fetched.
+func (w *InboxWorker) processMessages(ctx context.Context) int {
+	messages, err := w.store.GetPendingMessagesForProcessing(ctx, w.workerID, w.batchSize, w.maxRetries)
+	if err != nil {
+		w.activity.failed(err)
+		w.logger.Error("Failed to fetch pending messages",
+			logger.Err(err),
+			logger.String("worker_id", w.workerID))
+		return 0
+	}
+	w.activity.polled(len(messages))
+
+	if len(messages) == 0 {
+		return 0
+	}
+
+	w.logger.Info("Processing inbox messages",
+		logger.Int("count", len(messages)),
+		logger.String("worker_id", w.workerID))
+
+	var completed, committed []InboxMessage
+	var retries []RetryUpdate
+	var deferred []int64
+
+	for _, msg := range messages {
+		release, ok := w.acquire(msg.EventType)
+		if !ok {
+			ThrottledTotal.WithLabelValues(w.store.Config().TableName, msg.EventType).Inc()
+			deferred = append(deferred, msg.ID)
+			continue
+		}
+
+		err := w.handle(ctx, msg)
+		release()
+		if err != nil {
+			w.activity.failed(err)
+			w.logger.Error("Failed to process message",
+				logger.Err(err),
+				logger.Int64("id", msg.ID),
+				logger.String("message_id", msg.MessageID),
+				logger.String("event_type", msg.EventType),
+				logger.Int("retry_count", msg.RetryCount),
+				logger.String("worker_id", w.workerID))
+
+			table := w.store.Config().TableName
+			if errors.Is(err, ErrPoisonMessage) {
+				QuarantinedTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "quarantined").Inc()
+				w.quarantine(ctx, msg.ID, msg.MessageID, err)
+			} else if msg.RetryCount+1 >= w.maxRetries {
+				FailuresTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "failed").Inc()
+				w.markAsFailed(ctx, msg.ID, msg.MessageID, msg.RetryCount, err)
+			} else {
+				RetriesTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "retried").Inc()
+				delay := w.backoff.Next(msg.RetryCount)
+
+				w.logger.Info("Marking message for retry",
+					logger.Int64("id", msg.ID),
+					logger.String("message_id", msg.MessageID),
+					logger.Int("retry_count", msg.RetryCount+1),
+					logger.Int("max_retries", w.maxRetries),
+					logger.String("retry_in", delay.String()))
+
+				retries = append(retries, RetryUpdate{ID: msg.ID, Error: err.Error(), Delay: delay})
+			}
+			continue
+		}
+
+		w.activity.succeeded()
+		if w.txHandler != nil {
+			committed = append(committed, msg)
+		} else {
+			completed = append(completed, msg)
+		}
+	}
+
+	if err := w.store.IncrementRetryBatch(ctx, retries); err != nil {
+		w.logger.Error("Failed to mark messages for retry",
+			logger.Err(err),
+			logger.Int("count", len(retries)))
+	}
+
+	if err := w.store.DeferBatch(ctx, deferred, throttleDelay); err != nil {
+		w.logger.Error("Failed to defer throttled messages",
+			logger.Err(err),
+			logger.Int("count", len(deferred)))
+	} else if len(deferred) > 0 {
+		w.logger.Info("Deferred messages over their event type limit",
+			logger.Int("count", len(deferred)),
+			logger.String("worker_id", w.workerID))
+	}
+
+	w.markCompleted(ctx, completed)
+	w.recordCompleted(ctx, committed)
+
+	return len(messages)
+}
+
+func (w *InboxWorker) acquire(eventType string) (release func(), ok bool) {
+	if w.limiter == nil {
+		return func() {}, true
+	}
+	return w.limiter.TryAcquire(eventType)
+}
+
+// handle runs the configured handler in a consumer span that continues the
+// trace the message was published in. Transactional handlers have already
+// marked the message processed when they return nil.
+func (w *InboxWorker) handle(ctx context.Context, msg InboxMessage) (err error) {
+	ctx, span := tracing.StartSpan(messageContext(ctx, msg), "inbox "+msg.EventType,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.message.id", msg.MessageID),
+			attribute.String("messaging.event_type", msg.EventType),
+			attribute.String("messaging.sender_id", msg.SenderID),
+			attribute.Int("messaging.retry_count", msg.RetryCount),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if w.txHandler == nil {
+		return w.handler(ctx, msg)
+	}
+	return w.store.ProcessInTx(ctx, msg.ID, w.workerID, func(tx *sqlx.Tx) error {
+		return w.txHandler(ctx, tx, msg)
+	})
+}
+
+// markCompleted marks every successfully handled message in a single update.
+func (w *InboxWorker) markCompleted(ctx context.Context, messages []InboxMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	if err := w.store.MarkBatchAsProcessed(ctx, ids); err != nil {
+		w.logger.Error("Failed to mark messages as processed",
+			logger.Err(err),
+			logger.Int("count", len(ids)),
+			logger.String("worker_id", w.workerID))
+		return
+	}
+
+	w.recordCompleted(ctx, messages)
+}
+
+func (w *InboxWorker) recordCompleted(ctx context.Context, messages []InboxMessage) {
+	table := w.store.Config().TableName
+	for _, msg := range messages {
+		metrics.Observe(messageContext(ctx, msg), EndToEndLatency.WithLabelValues(table, msg.EventType), time.Since(msg.CreatedAt).Seconds())
+		WorkerMessagesTotal.WithLabelValues(table, w.workerID, "processed").Inc()
+
+		w.logger.Info("Message processed successfully",
+			logger.Int64("id", msg.ID),
+			logger.String("message_id", msg.MessageID),
+			logger.String("event_type", msg.EventType),
+			logger.String("worker_id", w.workerID))
+	}
+}
+
+// quarantine parks a poison message without retrying it.
+func (w *InboxWorker) quarantine(ctx context.Context, id int64, messageID string, cause error) {
+	w.logger.Warn("Poison message, quarantining",
+		logger.Int64("id", id),
+		logger.String("message_id", messageID),
+		logger.String("worker_id", w.workerID))
+
+	if err := w.store.Quarantine(ctx, id, cause.Error()); err != nil {
+		w.logger.Error("Failed to quarantine message",
+			logger.Err(err),
+			logger.Int64("id", id))
+	}
+}
+
+// markAsFailed moves a message that exhausted its retries to the dead-letter
+// table when one is configured, or marks it FAILED in place otherwise.
+func (w *InboxWorker) markAsFailed(ctx context.Context, id int64, messageID string, retryCount int, cause error) {
+	deadLetter := w.store.Config().DeadLetterTableName
+	if deadLetter == "" {
+		w.logger.Warn("Max retries exceeded, marking as FAILED",
+			logger.Int64("id", id),
+			logger.String("message_id", messageID),
+			logger.Int("retry_count", retryCount+1),
+			logger.Int("max_retries", w.maxRetries))
+
+		if err := w.store.MarkAsFailed(ctx, id, cause.Error()); err != nil {
+			w.logger.Error("Failed to mark message as failed",
+				logger.Err(err),
+				logger.Int64("id", id))
+		}
+		return
+	}
+
+	w.logger.Warn("Max retries exceeded, moving to dead-letter table",
+		logger.Int64("id", id),
+		logger.String("message_id", messageID),
+		logger.String("dead_letter_table", deadLetter),
+		logger.Int("retry_count", retryCount+1),
+		logger.Int("max_retries", w.maxRetries))
+
+	if err := w.store.MoveToDeadLetter(ctx, id, cause.Error()); err != nil {
+		w.logger.Error("Failed to move message to dead-letter table",
+			logger.Err(err),
+			logger.Int64("id", id))
+	}
+}
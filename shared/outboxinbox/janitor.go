@@ -0,0 +1,122 @@
+package outboxinbox
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+)
+
+// JanitorLockName is the advisory lock the replicas of a service share for
+// the janitor.
+const JanitorLockName = "outboxinbox.janitor"
+
+// Janitor periodically purges completed rows from inbox/outbox tables so they
+// don't grow without bound.
+type Janitor struct {
+	purgers   []Purger
+	logger    logger.Logger
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+	locker    *lock.Locker
+	stopCh    chan struct{}
+}
+
+func NewJanitor(
+	log logger.Logger,
+	interval time.Duration,
+	retention time.Duration,
+	batchSize int,
+	purgers ...Purger,
+) *Janitor {
+	return &Janitor{
+		purgers:   purgers,
+		logger:    log,
+		interval:  interval,
+		retention: retention,
+		batchSize: batchSize,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// SetLocker makes each run take JanitorLockName first, so only one replica
+// purges at a time. Without a locker every replica runs.
+func (j *Janitor) SetLocker(locker *lock.Locker) {
+	j.locker = locker
+}
+
+func (j *Janitor) Start(ctx context.Context) {
+	j.logger.Info("Starting retention janitor",
+		logger.String("interval", j.interval.String()),
+		logger.String("retention", j.retention.String()),
+		logger.Int("batch_size", j.batchSize))
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	j.run(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Stopping retention janitor due to context cancellation")
+			return
+		case <-j.stopCh:
+			j.logger.Info("Retention janitor stopped")
+			return
+		case <-ticker.C:
+			j.run(ctx)
+		}
+	}
+}
+
+func (j *Janitor) Stop() {
+	close(j.stopCh)
+}
+
+func (j *Janitor) run(ctx context.Context) {
+	ran, err := j.locker.RunExclusive(ctx, JanitorLockName, j.RunOnce)
+	if err != nil {
+		j.logger.Error("Failed to run retention janitor", logger.Err(err))
+	} else if !ran {
+		j.logger.Debug("Retention janitor is running on another replica")
+	}
+}
+
+// RunOnce purges every table in batches until no expired rows remain.
+func (j *Janitor) RunOnce(ctx context.Context) {
+	for _, p := range j.purgers {
+		cfg := p.Config()
+		mode := "deleted"
+		if cfg.ArchiveTableName != "" {
+			mode = "archived"
+		}
+
+		var total int64
+		for {
+			count, err := p.PurgeCompleted(ctx, j.retention, j.batchSize)
+			if err != nil {
+				j.logger.Error("Failed to purge completed messages",
+					logger.Err(err),
+					logger.String("table", cfg.TableName))
+				break
+			}
+
+			total += count
+			PurgedRowsTotal.WithLabelValues(cfg.TableName, mode).Add(float64(count))
+
+			if count < int64(j.batchSize) || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if total > 0 {
+			j.logger.Info("Purged completed messages",
+				logger.String("table", cfg.TableName),
+				logger.String("mode", mode),
+				logger.Int64("count", total))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package outboxinbox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventLimit caps how a single event type is processed. Zero values mean
+// unlimited.
+type EventLimit struct {
+	// MaxInFlight is the maximum number of messages of this type handled at
+	// the same time.
+	MaxInFlight int
+	// PerSecond is the sustained number of messages of this type started per
+	// second.
+	PerSecond float64
+}
+
+// EventLimiter enforces per-event-type limits. Share one limiter between all
+// workers of a pool so the limits apply to the pool as a whole.
+type EventLimiter struct {
+	mu       sync.Mutex
+	limits   map[string]EventLimit
+	inFlight map[string]int
+	buckets  map[string]*tokenBucket
+}
+
+func NewEventLimiter(limits map[string]EventLimit) *EventLimiter {
+	l := &EventLimiter{
+		limits:   limits,
+		inFlight: make(map[string]int),
+		buckets:  make(map[string]*tokenBucket),
+	}
+	for eventType, limit := range limits {
+		if limit.PerSecond > 0 {
+			l.buckets[eventType] = newTokenBucket(limit.PerSecond)
+		}
+	}
+	return l
+}
+
+// TryAcquire reserves a slot for eventType without blocking. When ok is true
+// the caller must call release once the message has been handled.
+func (l *EventLimiter) TryAcquire(eventType string) (release func(), ok bool) {
+	limit, limited := l.limits[eventType]
+	if !limited {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit.MaxInFlight > 0 && l.inFlight[eventType] >= limit.MaxInFlight {
+		return nil, false
+	}
+	if bucket := l.buckets[eventType]; bucket != nil && !bucket.take(time.Now()) {
+		return nil, false
+	}
+
+	l.inFlight[eventType]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight[eventType]--
+			l.mu.Unlock()
+		})
+	}, true
+}
+
+// tokenBucket allows bursts of up to one second's worth of tokens.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(perSecond float64) *tokenBucket {
+	burst := math.Max(1, perSecond)
+	return &tokenBucket{
+		rate:   perSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ParseEventLimits parses a comma-separated list of
+// "event_type=max_in_flight/per_second" entries, e.g.
+// "order.created=10/50,order.cancelled=2". Either number may be 0 for
+// unlimited and the rate may be omitted.
+func ParseEventLimits(s string) (map[string]EventLimit, error) {
+	limits := make(map[string]EventLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		eventType, spec, found := strings.Cut(entry, "=")
+		eventType = strings.TrimSpace(eventType)
+		if !found || eventType == "" {
+			return nil, fmt.Errorf("invalid event limit %q: expected event_type=max_in_flight/per_second", entry)
+		}
+
+		inFlight, rate, _ := strings.Cut(spec, "/")
+
+		var limit EventLimit
+		var err error
+		if limit.MaxInFlight, err = strconv.Atoi(strings.TrimSpace(inFlight)); err != nil || limit.MaxInFlight < 0 {
+			return nil, fmt.Errorf("invalid max in flight for %s: %q", eventType, inFlight)
+		}
+		if rate = strings.TrimSpace(rate); rate != "" {
+			if limit.PerSecond, err = strconv.ParseFloat(rate, 64); err != nil || limit.PerSecond < 0 {
+				return nil, fmt.Errorf("invalid rate for %s: %q", eventType, rate)
+			}
+		}
+
+		limits[eventType] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,55 @@
+package outboxinbox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEventLimits(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		want    map[string]EventLimit
+		wantErr string
+	}{
+		{name: "empty", in: "", want: map[string]EventLimit{}},
+		{
+			name: "in flight and rate",
+			in:   "order.created=10/50,order.cancelled=2",
+			want: map[string]EventLimit{
+				"order.created":   {MaxInFlight: 10, PerSecond: 50},
+				"order.cancelled": {MaxInFlight: 2},
+			},
+		},
+		{
+			name: "whitespace, fractions and unlimited in flight",
+			in:   " inventory.reserved = 0 / 0.5 , ,payment.failed=3/ ",
+			want: map[string]EventLimit{
+				"inventory.reserved": {PerSecond: 0.5},
+				"payment.failed":     {MaxInFlight: 3},
+			},
+		},
+		{name: "missing spec", in: "order.created", wantErr: `invalid event limit "order.created"`},
+		{name: "missing event type", in: "=10", wantErr: `invalid event limit "=10"`},
+		{name: "empty in flight", in: "order.created=/5", wantErr: `invalid max in flight for order.created: ""`},
+		{name: "negative in flight", in: "order.created=-1", wantErr: "invalid max in flight for order.created"},
+		{name: "invalid rate", in: "order.created=1/fast", wantErr: `invalid rate for order.created: "fast"`},
+		{name: "negative rate", in: "order.created=1/-2", wantErr: "invalid rate for order.created"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseEventLimits(tc.in)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("ParseEventLimits(%q) = %v, %v, want an error containing %q", tc.in, got, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseEventLimits(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package outboxinbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ListFilter selects a page of inbox/outbox rows. Rows are ordered by id;
+// Cursor is the id of the last row of the previous page and zero starts from
+// the newest (or oldest, when Ascending) row.
+type ListFilter struct {
+	Status        Status
+	EventType     string
+	MessageID     string
+	CorrelationID string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Cursor        int64
+	Limit         int
+	Ascending     bool
+}
+
+func listMessages(ctx context.Context, db *sqlx.DB, qb queryBuilder, columns string, filter ListFilter, dest interface{}) error {
+	var conditions []string
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", string(filter.Status))
+	}
+	if filter.EventType != "" {
+		add("event_type = $%d", filter.EventType)
+	}
+	if filter.MessageID != "" {
+		add("message_id = $%d", filter.MessageID)
+	}
+	if filter.CorrelationID != "" {
+		add("correlation_id = $%d", filter.CorrelationID)
+	}
+	if filter.CreatedAfter != nil {
+		add("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add("created_at < $%d", *filter.CreatedBefore)
+	}
+
+	order := "DESC"
+	if filter.Ascending {
+		order = "ASC"
+		if filter.Cursor > 0 {
+			add("id > $%d", filter.Cursor)
+		}
+	} else if filter.Cursor > 0 {
+		add("id < $%d", filter.Cursor)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query := qb.build(fmt.Sprintf(`SELECT %s FROM {table} %s ORDER BY id %s LIMIT $%d`,
+		columns, where, order, len(args)))
+
+	if err := db.SelectContext(ctx, dest, query, args...); err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	return nil
+}
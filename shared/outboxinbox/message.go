@@ -0,0 +1,72 @@
+package outboxinbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type InboxMessage struct {
+	ID            int64           `db:"id" json:"id"`
+	SenderID      string          `db:"sender_id" json:"sender_id"`
+	MessageID     string          `db:"message_id" json:"message_id"`
+	EventType     string          `db:"event_type" json:"event_type"`
+	Payload       Payload         `db:"payload" json:"payload"`
+	Status        string          `db:"status" json:"status"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at" json:"updated_at"`
+	RetryCount    int             `db:"retry_count" json:"retry_count"`
+	LockedAt      *time.Time      `db:"locked_at" json:"locked_at,omitempty"`
+	LockedBy      *string         `db:"locked_by" json:"locked_by,omitempty"`
+	Error         *string         `db:"error" json:"error,omitempty"`
+	Exchange      string          `db:"exchange" json:"exchange"`
+	RoutingKey    string          `db:"routing_key" json:"routing_key"`
+	NextRetryAt   *time.Time      `db:"next_retry_at" json:"next_retry_at,omitempty"`
+	ErrorHistory  json.RawMessage `db:"error_history" json:"error_history,omitempty"`
+	PartitionKey  *string         `db:"partition_key" json:"partition_key,omitempty"`
+	CorrelationID *string         `db:"correlation_id" json:"correlation_id,omitempty"`
+	CausationID   *string         `db:"causation_id" json:"causation_id,omitempty"`
+	// TraceContext holds the W3C trace headers of the delivery, so handling
+	// the message continues the publisher's trace.
+	TraceContext json.RawMessage `db:"trace_context" json:"trace_context,omitempty"`
+}
+
+type OutboxMessage struct {
+	ID            int64           `db:"id" json:"id"`
+	MessageID     string          `db:"message_id" json:"message_id"`
+	EventType     string          `db:"event_type" json:"event_type"`
+	Payload       Payload         `db:"payload" json:"payload"`
+	Status        string          `db:"status" json:"status"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at" json:"updated_at"`
+	RetryCount    int             `db:"retry_count" json:"retry_count"`
+	LockedAt      *time.Time      `db:"locked_at" json:"locked_at,omitempty"`
+	LockedBy      *string         `db:"locked_by" json:"locked_by,omitempty"`
+	Error         *string         `db:"error" json:"error,omitempty"`
+	Exchange      string          `db:"exchange" json:"exchange"`
+	RoutingKey    string          `db:"routing_key" json:"routing_key"`
+	NextRetryAt   *time.Time      `db:"next_retry_at" json:"next_retry_at,omitempty"`
+	ErrorHistory  json.RawMessage `db:"error_history" json:"error_history,omitempty"`
+	PartitionKey  *string         `db:"partition_key" json:"partition_key,omitempty"`
+	Headers       json.RawMessage `db:"headers" json:"headers,omitempty"`
+	DeliverAfter  *time.Time      `db:"deliver_after" json:"deliver_after,omitempty"`
+	Priority      int             `db:"priority" json:"priority"`
+	CorrelationID *string         `db:"correlation_id" json:"correlation_id,omitempty"`
+	CausationID   *string         `db:"causation_id" json:"causation_id,omitempty"`
+}
+
+// Column lists used in SELECT and RETURNING clauses. Nullable text columns
+// are coalesced so they scan into plain strings.
+const (
+	inboxColumns = `id, COALESCE(sender_id, 'unknown') AS sender_id, message_id, event_type, payload, status,
+		created_at, updated_at, retry_count, locked_at, locked_by, error,
+		COALESCE(exchange, '') AS exchange, COALESCE(routing_key, '') AS routing_key, next_retry_at,
+		COALESCE(error_history, '[]'::jsonb) AS error_history, partition_key,
+		correlation_id, causation_id, COALESCE(trace_context, '{}'::jsonb) AS trace_context`
+
+	outboxColumns = `id, message_id, event_type, payload, status,
+		created_at, updated_at, retry_count, locked_at, locked_by, error,
+		COALESCE(exchange, '') AS exchange, COALESCE(routing_key, '') AS routing_key, next_retry_at,
+		COALESCE(error_history, '[]'::jsonb) AS error_history, partition_key,
+		COALESCE(headers, '{}'::jsonb) AS headers, deliver_after,
+		COALESCE(priority, 0) AS priority, correlation_id, causation_id`
+)
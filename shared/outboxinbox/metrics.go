@@ -0,0 +1,103 @@
+package outboxinbox
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	PurgedRowsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_purged_rows_total",
+			Help: "Total number of completed inbox/outbox rows removed by the retention janitor",
+		},
+		[]string{"table", "mode"},
+	)
+
+	MessagesByStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outboxinbox_messages",
+			Help: "Current number of inbox/outbox rows by status",
+		},
+		[]string{"table", "status"},
+	)
+
+	OldestPendingAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outboxinbox_oldest_pending_age_seconds",
+			Help: "Age of the oldest pending inbox/outbox row, zero when nothing is pending",
+		},
+		[]string{"table"},
+	)
+
+	EndToEndLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "outboxinbox_end_to_end_latency_seconds",
+			Help:    "Time from a message being stored to it being processed or published",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+		},
+		[]string{"table", "event_type"},
+	)
+
+	RetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_retries_total",
+			Help: "Total number of inbox/outbox messages scheduled for retry",
+		},
+		[]string{"table", "event_type"},
+	)
+
+	FailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_failures_total",
+			Help: "Total number of inbox/outbox messages that exhausted their retries",
+		},
+		[]string{"table", "event_type"},
+	)
+
+	ThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_throttled_total",
+			Help: "Total number of messages deferred because their event type was over its concurrency or rate limit",
+		},
+		[]string{"table", "event_type"},
+	)
+
+	ReapedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_reaped_total",
+			Help: "Total number of stuck PROCESSING messages released by the reaper",
+		},
+		[]string{"table", "event_type"},
+	)
+
+	QuarantinedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_quarantined_total",
+			Help: "Total number of inbox/outbox messages quarantined as poison",
+		},
+		[]string{"table", "event_type"},
+	)
+
+	WorkerMessagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outboxinbox_worker_messages_total",
+			Help: "Total number of messages handled per worker by result",
+		},
+		[]string{"table", "worker_id", "result"},
+	)
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		PurgedRowsTotal,
+		MessagesByStatus,
+		OldestPendingAge,
+		EndToEndLatency,
+		RetriesTotal,
+		FailuresTotal,
+		QuarantinedTotal,
+		ReapedTotal,
+		ThrottledTotal,
+		WorkerMessagesTotal,
+	}
+}
@@ -0,0 +1,124 @@
+package outboxinbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const notifyTriggerSchema = `
+	CREATE OR REPLACE FUNCTION {table}_notify() RETURNS trigger AS $$
+	BEGIN
+		PERFORM pg_notify('{channel}', NEW.id::text);
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS {table}_notify ON {table};
+	CREATE TRIGGER {table}_notify
+		AFTER INSERT ON {table}
+		FOR EACH ROW EXECUTE FUNCTION {table}_notify();
+`
+
+const (
+	listenerMinReconnect = 10 * time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+func initNotifyTrigger(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder) error {
+	if cfg.NotifyChannel == "" {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, qb.build(notifyTriggerSchema)); err != nil {
+		return fmt.Errorf("failed to initialize %s notify trigger: %w", cfg.TableName, err)
+	}
+	return nil
+}
+
+// Notifier LISTENs on a Postgres channel and wakes subscribed workers when a
+// notification arrives. Notifications are coalesced: a worker that is busy
+// sees at most one pending wakeup.
+type Notifier struct {
+	dsn     string
+	channel string
+	logger  logger.Logger
+
+	mu          sync.Mutex
+	subscribers []chan struct{}
+}
+
+func NewNotifier(dsn, channel string, log logger.Logger) *Notifier {
+	return &Notifier{
+		dsn:     dsn,
+		channel: channel,
+		logger:  log,
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever the notifier
+// fires. Pass it to a worker with SetWakeup.
+func (n *Notifier) Subscribe() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	n.subscribers = append(n.subscribers, ch)
+	n.mu.Unlock()
+
+	return ch
+}
+
+// Start listens until ctx is cancelled. The listener reconnects on its own;
+// subscribers are woken after a reconnect since notifications may have been
+// missed while disconnected.
+func (n *Notifier) Start(ctx context.Context) error {
+	listener := pq.NewListener(n.dsn, listenerMinReconnect, listenerMaxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			n.logger.Warn("Postgres listener event",
+				logger.Err(err),
+				logger.String("channel", n.channel),
+				logger.Int("event", int(ev)))
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(n.channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", n.channel, err)
+	}
+
+	n.logger.Info("Listening for message notifications",
+		logger.String("channel", n.channel))
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-listener.Notify:
+			// A nil notification signals a reconnect; wake workers either way.
+			n.broadcast()
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (n *Notifier) broadcast() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, ch := range n.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
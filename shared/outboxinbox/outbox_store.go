@@ -0,0 +1,369 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"observability-system/shared/tracing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxStore persists outgoing events and hands them out to workers.
+type OutboxStore interface {
+	Config() Config
+	InitSchema(ctx context.Context) error
+	Save(ctx context.Context, eventType string, payload interface{}, exchange, routingKey string) (string, error)
+	SaveWithOptions(ctx context.Context, eventType string, payload interface{}, opts SaveOptions) (string, error)
+	SaveTx(ctx context.Context, tx *sqlx.Tx, eventType string, payload interface{}, opts SaveOptions) (string, error)
+	List(ctx context.Context, filter ListFilter) ([]OutboxMessage, error)
+	GetByID(ctx context.Context, id int64) (*OutboxMessage, error)
+	GetPendingMessagesForProcessing(ctx context.Context, workerID string, batchSize int, maxRetries int) ([]OutboxMessage, error)
+	MarkAsPublished(ctx context.Context, id int64) error
+	MarkBatchAsPublished(ctx context.Context, ids []int64) error
+	IncrementRetryAndMarkPending(ctx context.Context, id int64, errorMsg string, delay time.Duration) error
+	IncrementRetryBatch(ctx context.Context, updates []RetryUpdate) error
+	MarkAsFailed(ctx context.Context, id int64, errorMsg string) error
+	Quarantine(ctx context.Context, id int64, reason string) error
+	ResetStuckMessages(ctx context.Context) (int64, error)
+	ReapStuckMessages(ctx context.Context, lockTimeout time.Duration) ([]StuckMessage, error)
+	PurgeCompleted(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+	MoveToDeadLetter(ctx context.Context, id int64, errorMsg string) error
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+// Outbox priorities. Workers publish higher priorities first; any int is
+// accepted, these are the conventional lanes.
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// SaveOptions controls how an outbox message is routed when it is published.
+type SaveOptions struct {
+	Exchange   string
+	RoutingKey string
+	Headers    map[string]string
+	// PartitionKey overrides the key taken from Config.PartitionKeyField.
+	PartitionKey string
+	// DeliverAfter delays publication until the given time. The zero value
+	// publishes as soon as a worker picks the message up.
+	DeliverAfter time.Time
+	// Priority orders pending messages when the outbox backs up, e.g.
+	// PriorityHigh for compensating events and cancellations.
+	Priority int
+	// CorrelationID and CausationID override the IDs taken from ctx: the
+	// flow and triggering message of an inbox handler, or the HTTP request.
+	CorrelationID string
+	CausationID   string
+}
+
+// SQLOutboxStore is the PostgreSQL implementation of OutboxStore.
+type SQLOutboxStore struct {
+	db  *sqlx.DB
+	cfg Config
+	qb  queryBuilder
+}
+
+func NewOutboxStore(db *sqlx.DB, cfg Config) *SQLOutboxStore {
+	return &SQLOutboxStore{
+		db:  db,
+		cfg: cfg,
+		qb:  newQueryBuilder(cfg),
+	}
+}
+
+func (s *SQLOutboxStore) Config() Config {
+	return s.cfg
+}
+
+func (s *SQLOutboxStore) InitSchema(ctx context.Context) error {
+	if s.cfg.PartitionPeriod != "" {
+		if err := initPartitionedTable(ctx, s.db, s.cfg, s.qb, partitionedOutboxTable, outboxSchema+statusNormalization); err != nil {
+			return err
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, s.qb.build(outboxSchema+statusNormalization)); err != nil {
+		return fmt.Errorf("failed to initialize %s schema: %w", s.cfg.TableName, err)
+	}
+	if err := initArchiveSchema(ctx, s.db, s.cfg, s.qb); err != nil {
+		return err
+	}
+	if err := initNotifyTrigger(ctx, s.db, s.cfg, s.qb); err != nil {
+		return err
+	}
+	return initDeadLetterSchema(ctx, s.db, s.cfg, s.qb)
+}
+
+// PurgeCompleted removes completed rows older than olderThan. A partitioned
+// table drops whole expired partitions instead, ignoring limit.
+func (s *SQLOutboxStore) PurgeCompleted(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	if s.cfg.PartitionPeriod != "" {
+		return dropExpiredPartitions(ctx, s.db, s.cfg, s.qb, olderThan)
+	}
+	return purgeCompleted(ctx, s.db, s.cfg, s.qb, olderThan, limit)
+}
+
+// MaintainPartitions creates upcoming partitions of a partitioned table. It
+// is a no-op otherwise.
+func (s *SQLOutboxStore) MaintainPartitions(ctx context.Context) error {
+	if s.cfg.PartitionPeriod == "" {
+		return nil
+	}
+	return createPartitions(ctx, s.db, s.cfg, time.Time{})
+}
+
+func (s *SQLOutboxStore) Stats(ctx context.Context) (*Stats, error) {
+	return readStats(ctx, s.db, s.qb)
+}
+
+// Save saves a message to the outbox. An empty exchange falls back to the
+// configured default exchange and an empty routing key to the event type.
+func (s *SQLOutboxStore) Save(ctx context.Context, eventType string, payload interface{}, exchange, routingKey string) (string, error) {
+	return s.SaveWithOptions(ctx, eventType, payload, SaveOptions{
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+	})
+}
+
+// SaveWithOptions saves a message to the outbox with explicit routing,
+// headers and partition key. Defaults match Save.
+func (s *SQLOutboxStore) SaveWithOptions(ctx context.Context, eventType string, payload interface{}, opts SaveOptions) (string, error) {
+	return s.save(ctx, s.db, eventType, payload, opts)
+}
+
+// SaveTx is like SaveWithOptions, but writes the message inside tx so it is
+// only published if the caller's business writes commit.
+func (s *SQLOutboxStore) SaveTx(ctx context.Context, tx *sqlx.Tx, eventType string, payload interface{}, opts SaveOptions) (string, error) {
+	return s.save(ctx, tx, eventType, payload, opts)
+}
+
+func (s *SQLOutboxStore) save(ctx context.Context, db sqlx.ExecerContext, eventType string, payload interface{}, opts SaveOptions) (string, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	// The trace context of ctx travels with the message so consumers continue
+	// the trace that wrote it.
+	headers := make(map[string]string, len(opts.Headers))
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+	tracing.InjectTraceHeaders(ctx, headers)
+
+	// Left as a nil interface so the column is stored as NULL without headers.
+	var headersJSON interface{}
+	if len(headers) > 0 {
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal headers: %w", err)
+		}
+		headersJSON = string(encoded)
+	}
+
+	exchange := opts.Exchange
+	if exchange == "" {
+		exchange = s.cfg.DefaultExchange
+	}
+	routingKey := opts.RoutingKey
+	if routingKey == "" {
+		routingKey = eventType
+	}
+
+	partitionKey := partitionKeyFromPayload(payloadJSON, s.cfg.PartitionKeyField)
+	if opts.PartitionKey != "" {
+		partitionKey = &opts.PartitionKey
+	}
+
+	payloadJSON, err = encodePayload(payloadJSON, s.cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var deliverAfter *time.Time
+	if !opts.DeliverAfter.IsZero() {
+		deliverAfter = &opts.DeliverAfter
+	}
+
+	correlationID, causationID := flowIDs(ctx)
+	if opts.CorrelationID != "" {
+		correlationID = &opts.CorrelationID
+	}
+	if opts.CausationID != "" {
+		causationID = &opts.CausationID
+	}
+
+	messageID := uuid.New().String()
+	query := s.qb.build(`
+		INSERT INTO {table} (message_id, event_type, payload, status, exchange, routing_key, partition_key, headers, deliver_after, priority, correlation_id, causation_id)
+		VALUES ($1, $2, $3, {pending}, $4, $5, $6, $7, $8, $9, $10, $11)
+	`)
+	_, err = db.ExecContext(ctx, query, messageID, eventType, payloadJSON, exchange, routingKey, partitionKey, headersJSON, deliverAfter, opts.Priority, correlationID, causationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to save outbox message: %w", err)
+	}
+
+	return messageID, nil
+}
+
+func (s *SQLOutboxStore) GetByID(ctx context.Context, id int64) (*OutboxMessage, error) {
+	var msg OutboxMessage
+	query := s.qb.build(`SELECT ` + outboxColumns + ` FROM {table} WHERE id = $1`)
+	if err := s.db.GetContext(ctx, &msg, query, id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *SQLOutboxStore) List(ctx context.Context, filter ListFilter) ([]OutboxMessage, error) {
+	messages := []OutboxMessage{}
+	if err := listMessages(ctx, s.db, s.qb, outboxColumns, filter, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *SQLOutboxStore) GetPendingMessagesForProcessing(ctx context.Context, workerID string, batchSize int, maxRetries int) ([]OutboxMessage, error) {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET
+			status = {processing},
+			locked_at = NOW(),
+			locked_by = $1,
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM {table}
+			WHERE (status = {pending} OR (status = {failed} AND retry_count < $3))
+			  AND (locked_at IS NULL OR locked_at < NOW() - INTERVAL '1 second' * $4)
+			  AND (next_retry_at IS NULL OR next_retry_at <= NOW())
+			  AND (deliver_after IS NULL OR deliver_after <= NOW())
+			  AND ` + partitionReadyClause + `
+			ORDER BY priority DESC, created_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns)
+
+	var messages []OutboxMessage
+	err := s.db.SelectContext(ctx, &messages, query, workerID, batchSize, maxRetries, s.cfg.LockTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (s *SQLOutboxStore) MarkAsPublished(ctx context.Context, id int64) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {completed},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *SQLOutboxStore) MarkBatchAsPublished(ctx context.Context, ids []int64) error {
+	return markBatchCompleted(ctx, s.db, s.qb, ids)
+}
+
+func (s *SQLOutboxStore) IncrementRetryBatch(ctx context.Context, updates []RetryUpdate) error {
+	return incrementRetryBatch(ctx, s.db, s.qb, updates)
+}
+
+// IncrementRetryAndMarkPending returns a message whose publish failed to
+// PENDING and hides it from workers until delay has elapsed.
+func (s *SQLOutboxStore) IncrementRetryAndMarkPending(ctx context.Context, id int64, errorMsg string, delay time.Duration) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {pending},
+			retry_count = retry_count + 1,
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = $2,
+			error_history = ` + errorHistoryEntry + `,
+			next_retry_at = NOW() + INTERVAL '1 millisecond' * $3
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id, errorMsg, delay.Milliseconds())
+	return err
+}
+
+// Quarantine parks a poison message so it is neither retried nor
+// dead-lettered until an operator resubmits it.
+func (s *SQLOutboxStore) Quarantine(ctx context.Context, id int64, reason string) error {
+	return quarantine(ctx, s.db, s.qb, id, reason)
+}
+
+func (s *SQLOutboxStore) ListQuarantined(ctx context.Context, limit, offset int) ([]QuarantinedMessage, error) {
+	return listQuarantined(ctx, s.db, s.qb, `COALESCE(headers, '{}'::jsonb)`, limit, offset)
+}
+
+func (s *SQLOutboxStore) GetQuarantined(ctx context.Context, id int64) (*QuarantinedMessage, error) {
+	return getQuarantined(ctx, s.db, s.qb, `COALESCE(headers, '{}'::jsonb)`, id)
+}
+
+func (s *SQLOutboxStore) MarkAsFailed(ctx context.Context, id int64, errorMsg string) error {
+	query := s.qb.build(`
+		UPDATE {table}
+		SET status = {failed},
+			retry_count = retry_count + 1,
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = $2,
+			error_history = ` + errorHistoryEntry + `
+		WHERE id = $1
+	`)
+	_, err := s.db.ExecContext(ctx, query, id, errorMsg)
+	return err
+}
+
+// ResetStuckMessages returns rows that have been PROCESSING for longer than
+// the configured lock timeout to PENDING.
+func (s *SQLOutboxStore) ResetStuckMessages(ctx context.Context) (int64, error) {
+	messages, err := s.ReapStuckMessages(ctx, s.cfg.LockTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(messages)), nil
+}
+
+// ReapStuckMessages is like ResetStuckMessages with an explicit lock timeout,
+// and reports the worker that held each stale lock.
+func (s *SQLOutboxStore) ReapStuckMessages(ctx context.Context, lockTimeout time.Duration) ([]StuckMessage, error) {
+	return reapStuckMessages(ctx, s.db, s.qb, lockTimeout)
+}
+
+func (s *SQLOutboxStore) MoveToDeadLetter(ctx context.Context, id int64, errorMsg string) error {
+	return moveToDeadLetter(ctx, s.db, s.cfg, s.qb, id, errorMsg)
+}
+
+func (s *SQLOutboxStore) ListDeadLetters(ctx context.Context, limit, offset int) ([]DeadLetterMessage, error) {
+	return listDeadLetters(ctx, s.db, s.qb, limit, offset)
+}
+
+func (s *SQLOutboxStore) GetDeadLetter(ctx context.Context, id int64) (*DeadLetterMessage, error) {
+	return getDeadLetter(ctx, s.db, s.qb, id)
+}
+
+func (s *SQLOutboxStore) RequeueDeadLetter(ctx context.Context, id int64) (string, error) {
+	return requeueDeadLetter(ctx, s.db, s.cfg, s.qb, id)
+}
+
+func (s *SQLOutboxStore) Replay(ctx context.Context, id int64, payload json.RawMessage) (string, error) {
+	return replay(ctx, s.db, s.cfg, s.qb, id, payload)
+}
+
+func (s *SQLOutboxStore) ReplayByFilter(ctx context.Context, filter ReplayFilter) (int64, error) {
+	return replayByFilter(ctx, s.db, s.cfg, s.qb, filter)
+}
@@ -0,0 +1,334 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/messaging"
+	"observability-system/shared/metrics"
+
+	"github.com/google/uuid"
+)
+
+// DispatcherElection names the leader election deciding which replica of a
+// service runs its outbox workers.
+const DispatcherElection = "outboxinbox.dispatcher"
+
+type OutboxWorker struct {
+	store      OutboxStore
+	logger     logger.Logger
+	workerID   string
+	batchSize  int
+	interval   time.Duration
+	poll       pollSchedule
+	maxRetries int
+	backoff    BackoffPolicy
+	stopCh     chan struct{}
+	activity   workerActivity
+	wakeCh     <-chan struct{}
+	publisher  messaging.Publisher
+}
+
+func NewOutboxWorker(
+	store OutboxStore,
+	publisher messaging.Publisher,
+	log logger.Logger,
+	batchSize int,
+	interval time.Duration,
+	maxRetries int,
+	backoff BackoffPolicy,
+) *OutboxWorker {
+	return &OutboxWorker{
+		store:      store,
+		logger:     log,
+		workerID:   fmt.Sprintf("outbox-worker-%s", uuid.New().String()[:8]),
+		batchSize:  batchSize,
+		interval:   interval,
+		poll:       newPollSchedule(interval, defaultMaxIdleMultiple*interval),
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		stopCh:     make(chan struct{}),
+		publisher:  publisher,
+	}
+}
+
+func (w *OutboxWorker) ID() string {
+	return w.workerID
+}
+
+func (w *OutboxWorker) Status() WorkerStatus {
+	return w.activity.status(w.workerID)
+}
+
+// SetMaxIdleInterval caps how far the poll interval grows while polls keep
+// returning nothing. A value at or below the base interval disables the
+// adaptive backoff. Call it before Start.
+func (w *OutboxWorker) SetMaxIdleInterval(max time.Duration) {
+	w.poll = newPollSchedule(w.interval, max)
+}
+
+// SetWakeup makes the worker poll immediately whenever ch fires, in addition
+// to its regular interval. Call it before Start.
+func (w *OutboxWorker) SetWakeup(ch <-chan struct{}) {
+	w.wakeCh = ch
+}
+
+func (w *OutboxWorker) Start(ctx context.Context) {
+	w.logger.Info("Starting outbox worker",
+		logger.String("worker_id", w.workerID),
+		logger.Int("batch_size", w.batchSize),
+		logger.Int("max_retries", w.maxRetries),
+		logger.String("interval", w.interval.String()),
+		logger.String("max_idle_interval", w.poll.policy.Max.String()))
+
+	timer := time.NewTimer(w.poll.first())
+	defer timer.Stop()
+
+	if count, err := w.store.ResetStuckMessages(ctx); err != nil {
+		w.logger.Error("Failed to reset stuck messages", logger.Err(err))
+	} else if count > 0 {
+		w.logger.Info("Reset stuck messages", logger.Int64("count", count))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Stopping outbox worker due to context cancellation",
+				logger.String("worker_id", w.workerID))
+			return
+		case <-w.stopCh:
+			w.logger.Info("Outbox worker stopped",
+				logger.String("worker_id", w.workerID))
+			return
+		case <-timer.C:
+			timer.Reset(w.poll.next(w.processMessages(ctx), w.batchSize))
+		case <-w.wakeCh:
+			timer.Reset(w.poll.next(w.processMessages(ctx), w.batchSize))
+		}
+	}
+}
+
+func (w *OutboxWorker) Stop() {
+	close(w.stopCh)
+}
+
+// processMessages handles one batch and returns how many messages it fetched.
+func (w *OutboxWorker) processMessages(ctx context.Context) int {
+	messages, err := w.store.GetPendingMessagesForProcessing(ctx, w.workerID, w.batchSize, w.maxRetries)
+	if err != nil {
+		w.activity.failed(err)
+		w.logger.Error("Failed to fetch pending messages",
+			logger.Err(err),
+			logger.String("worker_id", w.workerID))
+		return 0
+	}
+	w.activity.polled(len(messages))
+
+	if len(messages) == 0 {
+		return 0
+	}
+
+	w.logger.Info("Processing outbox messages",
+		logger.Int("count", len(messages)),
+		logger.String("worker_id", w.workerID))
+
+	var completed []OutboxMessage
+	var retries []RetryUpdate
+
+	for _, msg := range messages {
+		if err := w.processMessage(msg); err != nil {
+			w.activity.failed(err)
+			w.logger.Error("Failed to process message",
+				logger.Err(err),
+				logger.Int64("id", msg.ID),
+				logger.String("message_id", msg.MessageID),
+				logger.String("event_type", msg.EventType),
+				logger.Int("retry_count", msg.RetryCount),
+				logger.String("worker_id", w.workerID))
+
+			table := w.store.Config().TableName
+			if errors.Is(err, ErrPoisonMessage) {
+				QuarantinedTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "quarantined").Inc()
+				w.quarantine(ctx, msg.ID, msg.MessageID, err)
+			} else if msg.RetryCount+1 >= w.maxRetries {
+				FailuresTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "failed").Inc()
+				w.markAsFailed(ctx, msg.ID, msg.MessageID, msg.RetryCount, err)
+			} else {
+				RetriesTotal.WithLabelValues(table, msg.EventType).Inc()
+				WorkerMessagesTotal.WithLabelValues(table, w.workerID, "retried").Inc()
+				delay := w.backoff.Next(msg.RetryCount)
+
+				w.logger.Info("Marking message for retry",
+					logger.Int64("id", msg.ID),
+					logger.String("message_id", msg.MessageID),
+					logger.Int("retry_count", msg.RetryCount+1),
+					logger.Int("max_retries", w.maxRetries),
+					logger.String("retry_in", delay.String()))
+
+				retries = append(retries, RetryUpdate{ID: msg.ID, Error: err.Error(), Delay: delay})
+			}
+			continue
+		}
+
+		w.activity.succeeded()
+		completed = append(completed, msg)
+	}
+
+	if err := w.store.IncrementRetryBatch(ctx, retries); err != nil {
+		w.logger.Error("Failed to mark messages for retry",
+			logger.Err(err),
+			logger.Int("count", len(retries)))
+	}
+
+	w.markCompleted(ctx, completed)
+
+	return len(messages)
+}
+
+func (w *OutboxWorker) processMessage(msg OutboxMessage) error {
+	pub, err := buildPublication(msg, w.store.Config())
+	if err != nil {
+		return err
+	}
+
+	if err := w.publisher.Publish(pub.Exchange, pub.RoutingKey, pub.Message); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	return nil
+}
+
+// publication is a broker message together with where it is published.
+type publication struct {
+	Exchange   string
+	RoutingKey string
+	Message    messaging.Message
+}
+
+// buildPublication turns an outbox row into the message the worker publishes.
+// Rows whose payload or headers cannot be decoded are poison.
+func buildPublication(msg OutboxMessage, cfg Config) (*publication, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return nil, Poison(fmt.Errorf("failed to unmarshal payload: %w", err))
+	}
+
+	var headers map[string]string
+	if len(msg.Headers) > 0 {
+		if err := json.Unmarshal(msg.Headers, &headers); err != nil {
+			return nil, Poison(fmt.Errorf("failed to unmarshal headers: %w", err))
+		}
+	}
+
+	pub := &publication{
+		Exchange:   msg.Exchange,
+		RoutingKey: msg.RoutingKey,
+		Message: messaging.Message{
+			ID:        msg.MessageID,
+			Type:      msg.EventType,
+			Payload:   payload,
+			Timestamp: msg.CreatedAt,
+			Headers:   headers,
+
+			CorrelationID: derefString(msg.CorrelationID),
+			CausationID:   derefString(msg.CausationID),
+		},
+	}
+	if pub.Exchange == "" {
+		pub.Exchange = cfg.DefaultExchange
+	}
+	if pub.RoutingKey == "" {
+		pub.RoutingKey = msg.EventType
+	}
+	return pub, nil
+}
+
+// markCompleted marks every successfully handled message in a single update.
+func (w *OutboxWorker) markCompleted(ctx context.Context, messages []OutboxMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	if err := w.store.MarkBatchAsPublished(ctx, ids); err != nil {
+		w.logger.Error("Failed to mark messages as published",
+			logger.Err(err),
+			logger.Int("count", len(ids)),
+			logger.String("worker_id", w.workerID))
+		return
+	}
+
+	table := w.store.Config().TableName
+	for _, msg := range messages {
+		// Scheduled messages are measured from when they became due.
+		start := msg.CreatedAt
+		if msg.DeliverAfter != nil && msg.DeliverAfter.After(start) {
+			start = *msg.DeliverAfter
+		}
+		metrics.Observe(outboxTraceContext(ctx, msg), EndToEndLatency.WithLabelValues(table, msg.EventType), time.Since(start).Seconds())
+		WorkerMessagesTotal.WithLabelValues(table, w.workerID, "published").Inc()
+
+		w.logger.Info("Message published successfully",
+			logger.Int64("id", msg.ID),
+			logger.String("message_id", msg.MessageID),
+			logger.String("event_type", msg.EventType),
+			logger.String("worker_id", w.workerID))
+	}
+}
+
+// quarantine parks a poison message without retrying it.
+func (w *OutboxWorker) quarantine(ctx context.Context, id int64, messageID string, cause error) {
+	w.logger.Warn("Poison message, quarantining",
+		logger.Int64("id", id),
+		logger.String("message_id", messageID),
+		logger.String("worker_id", w.workerID))
+
+	if err := w.store.Quarantine(ctx, id, cause.Error()); err != nil {
+		w.logger.Error("Failed to quarantine message",
+			logger.Err(err),
+			logger.Int64("id", id))
+	}
+}
+
+// markAsFailed moves a message that exhausted its retries to the dead-letter
+// table when one is configured, or marks it FAILED in place otherwise.
+func (w *OutboxWorker) markAsFailed(ctx context.Context, id int64, messageID string, retryCount int, cause error) {
+	deadLetter := w.store.Config().DeadLetterTableName
+	if deadLetter == "" {
+		w.logger.Warn("Max retries exceeded, marking as FAILED",
+			logger.Int64("id", id),
+			logger.String("message_id", messageID),
+			logger.Int("retry_count", retryCount+1),
+			logger.Int("max_retries", w.maxRetries))
+
+		if err := w.store.MarkAsFailed(ctx, id, cause.Error()); err != nil {
+			w.logger.Error("Failed to mark message as failed",
+				logger.Err(err),
+				logger.Int64("id", id))
+		}
+		return
+	}
+
+	w.logger.Warn("Max retries exceeded, moving to dead-letter table",
+		logger.Int64("id", id),
+		logger.String("message_id", messageID),
+		logger.String("dead_letter_table", deadLetter),
+		logger.Int("retry_count", retryCount+1),
+		logger.Int("max_retries", w.maxRetries))
+
+	if err := w.store.MoveToDeadLetter(ctx, id, cause.Error()); err != nil {
+		w.logger.Error("Failed to move message to dead-letter table",
+			logger.Err(err),
+			logger.Int64("id", id))
+	}
+}
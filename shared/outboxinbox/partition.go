@@ -0,0 +1,44 @@
+package outboxinbox
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// partitionReadyClause keeps a row out of a batch while an earlier row with the
+// same partition key is still pending, in flight or failed. Rows moved to the
+// dead-letter table no longer hold back their partition.
+const partitionReadyClause = `(partition_key IS NULL OR NOT EXISTS (
+				SELECT 1 FROM {table} earlier
+				WHERE earlier.partition_key = {table}.partition_key
+				  AND earlier.id < {table}.id
+				  AND earlier.status <> {completed}
+			))`
+
+// partitionKeyFromPayload returns the value of field in a JSON object payload,
+// or nil when the field is unset, missing or empty.
+func partitionKeyFromPayload(payload []byte, field string) *string {
+	if field == "" {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil
+	}
+
+	raw, ok := fields[field]
+	if !ok {
+		return nil
+	}
+
+	var key string
+	if err := json.Unmarshal(raw, &key); err != nil {
+		// Non-string values such as numeric ids are used verbatim.
+		key = strings.TrimSpace(string(raw))
+	}
+	if key == "" || key == "null" {
+		return nil
+	}
+	return &key
+}
@@ -0,0 +1,99 @@
+package outboxinbox
+
+import (
+	"context"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+)
+
+// PartitionKeeperLockName is the advisory lock the replicas of a service
+// share for the partition keeper.
+const PartitionKeeperLockName = "outboxinbox.partitions"
+
+// DefaultPartitionInterval is how often partitions are maintained when no
+// interval is given. It stays well inside the daily lookahead.
+const DefaultPartitionInterval = time.Hour
+
+// PartitionKeeper periodically creates upcoming partitions of time-partitioned
+// tables. It runs independently of the janitor, since inserts fail once the
+// created partitions run out whether or not old rows are purged.
+type PartitionKeeper struct {
+	maintainers []PartitionMaintainer
+	logger      logger.Logger
+	interval    time.Duration
+	locker      *lock.Locker
+	stopCh      chan struct{}
+}
+
+// NewPartitionKeeper creates a partition keeper. A zero interval uses
+// DefaultPartitionInterval.
+func NewPartitionKeeper(
+	log logger.Logger,
+	interval time.Duration,
+	maintainers ...PartitionMaintainer,
+) *PartitionKeeper {
+	if interval <= 0 {
+		interval = DefaultPartitionInterval
+	}
+	return &PartitionKeeper{
+		maintainers: maintainers,
+		logger:      log,
+		interval:    interval,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// SetLocker makes each run take PartitionKeeperLockName first, so only one
+// replica creates partitions at a time. Without a locker every replica runs.
+func (k *PartitionKeeper) SetLocker(locker *lock.Locker) {
+	k.locker = locker
+}
+
+func (k *PartitionKeeper) Start(ctx context.Context) {
+	k.logger.Info("Starting partition keeper",
+		logger.String("interval", k.interval.String()))
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	k.run(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			k.logger.Info("Stopping partition keeper due to context cancellation")
+			return
+		case <-k.stopCh:
+			k.logger.Info("Partition keeper stopped")
+			return
+		case <-ticker.C:
+			k.run(ctx)
+		}
+	}
+}
+
+func (k *PartitionKeeper) Stop() {
+	close(k.stopCh)
+}
+
+func (k *PartitionKeeper) run(ctx context.Context) {
+	ran, err := k.locker.RunExclusive(ctx, PartitionKeeperLockName, k.RunOnce)
+	if err != nil {
+		k.logger.Error("Failed to run partition keeper", logger.Err(err))
+	} else if !ran {
+		k.logger.Debug("Partition keeper is running on another replica")
+	}
+}
+
+// RunOnce creates the upcoming partitions of every table.
+func (k *PartitionKeeper) RunOnce(ctx context.Context) {
+	for _, m := range k.maintainers {
+		if err := m.MaintainPartitions(ctx); err != nil {
+			k.logger.Error("Failed to create upcoming partitions",
+				logger.Err(err),
+				logger.String("table", m.Config().TableName))
+		}
+	}
+}
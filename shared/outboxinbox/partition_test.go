@@ -0,0 +1,35 @@
+package outboxinbox
+
+import "testing"
+
+func TestPartitionKeyFromPayload(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		field   string
+		want    string
+		wantNil bool
+	}{
+		{name: "string field", payload: `{"order_id":"ORD-1","quantity":2}`, field: "order_id", want: "ORD-1"},
+		{name: "numeric field", payload: `{"customer_id": 42 }`, field: "customer_id", want: "42"},
+		{name: "object field", payload: `{"key":{"a":1}}`, field: "key", want: `{"a":1}`},
+		{name: "no field configured", payload: `{"order_id":"ORD-1"}`, field: "", wantNil: true},
+		{name: "missing field", payload: `{"product_id":"PROD-1"}`, field: "order_id", wantNil: true},
+		{name: "empty string", payload: `{"order_id":""}`, field: "order_id", wantNil: true},
+		{name: "null", payload: `{"order_id":null}`, field: "order_id", wantNil: true},
+		{name: "not an object", payload: `["ORD-1"]`, field: "order_id", wantNil: true},
+		{name: "invalid JSON", payload: `{"order_id":`, field: "order_id", wantNil: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := partitionKeyFromPayload([]byte(tc.payload), tc.field)
+			switch {
+			case tc.wantNil && got != nil:
+				t.Errorf("partitionKeyFromPayload = %q, want nil", *got)
+			case !tc.wantNil && (got == nil || *got != tc.want):
+				t.Errorf("partitionKeyFromPayload = %v, want %q", got, tc.want)
+			}
+		})
+	}
+}
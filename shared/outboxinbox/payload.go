@@ -0,0 +1,117 @@
+package outboxinbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrPayloadTooLarge is returned when a payload exceeds Config.MaxPayloadSize.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// gzipEnvelopeKey marks a payload stored as base64-encoded gzip inside the
+// JSONB column.
+const gzipEnvelopeKey = "$gzip"
+
+// Payload is a JSON message payload. Payloads that were compressed on save
+// are decompressed transparently when scanned, so readers always see the
+// original JSON.
+type Payload json.RawMessage
+
+// Scan implements sql.Scanner.
+func (p *Payload) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Payload", src)
+	}
+
+	decoded, err := decodePayload(raw)
+	if err != nil {
+		return err
+	}
+	*p = decoded
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (p Payload) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return []byte(p), nil
+}
+
+// MarshalJSON returns the payload as raw JSON.
+func (p Payload) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(p).MarshalJSON()
+}
+
+// UnmarshalJSON stores a copy of data.
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	return (*json.RawMessage)(p).UnmarshalJSON(data)
+}
+
+// encodePayload enforces the configured size limit on the uncompressed
+// payload and compresses it when it is over the compression threshold.
+func encodePayload(payload []byte, cfg Config) ([]byte, error) {
+	if cfg.MaxPayloadSize > 0 && len(payload) > cfg.MaxPayloadSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the %d byte limit of %s",
+			ErrPayloadTooLarge, len(payload), cfg.MaxPayloadSize, cfg.TableName)
+	}
+	if cfg.CompressThreshold <= 0 || len(payload) < cfg.CompressThreshold {
+		return payload, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+
+	return json.Marshal(map[string]string{
+		gzipEnvelopeKey: base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
+}
+
+// decodePayload reverses encodePayload. Uncompressed payloads are returned
+// as a copy of raw.
+func decodePayload(raw []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if bytes.Contains(raw, []byte(gzipEnvelopeKey)) && json.Unmarshal(raw, &envelope) == nil && len(envelope) == 1 {
+		var encoded string
+		if data, ok := envelope[gzipEnvelopeKey]; ok && json.Unmarshal(data, &encoded) == nil {
+			compressed, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode compressed payload: %w", err)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress payload: %w", err)
+			}
+			defer zr.Close()
+			decompressed, err := io.ReadAll(zr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress payload: %w", err)
+			}
+			return decompressed, nil
+		}
+	}
+
+	return append([]byte(nil), raw...), nil
+}
@@ -0,0 +1,99 @@
+package outboxinbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	large := []byte(`{"order_id":"ORD-1","note":"` + strings.Repeat("x", 4096) + `"}`)
+	small := []byte(`{"order_id":"ORD-1"}`)
+
+	cases := []struct {
+		name           string
+		payload        []byte
+		cfg            Config
+		wantCompressed bool
+	}{
+		{"compression disabled", large, Config{}, false},
+		{"below the threshold", small, Config{CompressThreshold: 1024}, false},
+		{"at the threshold", large, Config{CompressThreshold: len(large)}, true},
+		{"within the size limit", large, Config{CompressThreshold: 1024, MaxPayloadSize: len(large)}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stored, err := encodePayload(tc.payload, tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var envelope map[string]string
+			compressed := json.Unmarshal(stored, &envelope) == nil && envelope[gzipEnvelopeKey] != ""
+			if compressed != tc.wantCompressed {
+				t.Fatalf("stored %s, compressed = %v, want %v", stored, compressed, tc.wantCompressed)
+			}
+			if compressed && len(stored) >= len(tc.payload) {
+				t.Errorf("compressed payload has %d bytes, the original %d", len(stored), len(tc.payload))
+			}
+
+			var p Payload
+			if err := p.Scan(stored); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(p, tc.payload) {
+				t.Errorf("scanned %s, want %s", p, tc.payload)
+			}
+		})
+	}
+}
+
+func TestEncodePayloadRejectsLargePayloads(t *testing.T) {
+	cfg := Config{TableName: "outbox", MaxPayloadSize: 16, CompressThreshold: 8}
+	_, err := encodePayload([]byte(`{"order_id":"ORD-12345"}`), cfg)
+	if !errors.Is(err, ErrPayloadTooLarge) || !strings.Contains(err.Error(), "24 bytes exceeds the 16 byte limit of outbox") {
+		t.Errorf("encodePayload = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{name: "plain JSON", raw: `{"order_id":"ORD-1"}`, want: `{"order_id":"ORD-1"}`},
+		{name: "envelope key among other fields", raw: `{"$gzip":"x","order_id":"ORD-1"}`, want: `{"$gzip":"x","order_id":"ORD-1"}`},
+		{name: "non-string envelope", raw: `{"$gzip":42}`, want: `{"$gzip":42}`},
+		{name: "key in a value", raw: `{"note":"$gzip"}`, want: `{"note":"$gzip"}`},
+		{name: "invalid base64", raw: `{"$gzip":"not base64!"}`, wantErr: "failed to decode compressed payload"},
+		{name: "not gzip", raw: `{"$gzip":"aGVsbG8="}`, wantErr: "failed to decompress payload"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodePayload([]byte(tc.raw))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("decodePayload = %s, %v, want an error containing %q", got, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || string(got) != tc.want {
+				t.Errorf("decodePayload = %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+
+	// Uncompressed payloads are copied, as drivers may reuse scan buffers.
+	raw := []byte(`{"order_id":"ORD-1"}`)
+	got, _ := decodePayload(raw)
+	raw[2] = 'X'
+	if string(got) != `{"order_id":"ORD-1"}` {
+		t.Errorf("decodePayload shares its input: %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+package outboxinbox
+
+import "time"
+
+// defaultMaxIdleMultiple caps idle polling at this many base intervals unless
+// a worker is configured otherwise.
+const defaultMaxIdleMultiple = 6
+
+// pollJitter spreads the poll times of workers that started together.
+const pollJitter = 0.2
+
+// pollSchedule decides how long a worker waits before its next poll: right
+// away after a full batch, the base interval after a partial one, and
+// doubling up to a maximum while polls keep coming back empty. Every wait is
+// jittered so workers don't query in lockstep.
+type pollSchedule struct {
+	policy     BackoffPolicy
+	emptyPolls int
+}
+
+func newPollSchedule(interval, maxIdle time.Duration) pollSchedule {
+	if maxIdle < interval {
+		maxIdle = interval
+	}
+	return pollSchedule{
+		policy: BackoffPolicy{
+			Base:       interval,
+			Multiplier: 2,
+			Max:        maxIdle,
+			Jitter:     pollJitter,
+		},
+	}
+}
+
+// first returns the wait before a worker's first poll.
+func (s *pollSchedule) first() time.Duration {
+	return s.policy.Next(0)
+}
+
+// next returns the wait after a poll that fetched fetched of at most
+// batchSize messages.
+func (s *pollSchedule) next(fetched, batchSize int) time.Duration {
+	switch {
+	case fetched >= batchSize:
+		s.emptyPolls = 0
+		return 0
+	case fetched > 0:
+		s.emptyPolls = 0
+		return s.policy.Next(0)
+	default:
+		delay := s.policy.Next(s.emptyPolls)
+		s.emptyPolls++
+		return delay
+	}
+}
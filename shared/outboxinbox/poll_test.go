@@ -0,0 +1,70 @@
+package outboxinbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollScheduleNext(t *testing.T) {
+	const batchSize = 10
+
+	cases := []struct {
+		name     string
+		interval time.Duration
+		maxIdle  time.Duration
+		// polls are the fetched counts of successive polls, and waits the
+		// unjittered delays expected after each of them.
+		polls []int
+		waits []time.Duration
+	}{
+		{
+			name:     "full batches poll again right away",
+			interval: time.Second, maxIdle: 8 * time.Second,
+			polls: []int{10, 12, 10},
+			waits: []time.Duration{0, 0, 0},
+		},
+		{
+			name:     "partial batches wait the interval",
+			interval: time.Second, maxIdle: 8 * time.Second,
+			polls: []int{3, 9, 1},
+			waits: []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:     "empty polls back off up to the maximum",
+			interval: time.Second, maxIdle: 8 * time.Second,
+			polls: []int{0, 0, 0, 0, 0, 0},
+			waits: []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second},
+		},
+		{
+			name:     "messages reset the backoff",
+			interval: time.Second, maxIdle: 8 * time.Second,
+			polls: []int{0, 0, 0, 2, 0, 0, 10, 0},
+			waits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Second, time.Second, 2 * time.Second, 0, time.Second},
+		},
+		{
+			name:     "maximum below the interval",
+			interval: 2 * time.Second, maxIdle: time.Second,
+			polls: []int{0, 0, 0},
+			waits: []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPollSchedule(tc.interval, tc.maxIdle)
+			if first := s.first(); !withinJitter(first, tc.interval) {
+				t.Errorf("first() = %s, want about %s", first, tc.interval)
+			}
+			for i, fetched := range tc.polls {
+				if got := s.next(fetched, batchSize); !withinJitter(got, tc.waits[i]) {
+					t.Errorf("poll %d fetched %d: next() = %s, want about %s", i, fetched, got, tc.waits[i])
+				}
+			}
+		})
+	}
+}
+
+func withinJitter(got, want time.Duration) bool {
+	jitter := time.Duration(float64(want) * pollJitter)
+	return got >= want-jitter && got <= want+jitter
+}
@@ -0,0 +1,141 @@
+package outboxinbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// Worker is implemented by InboxWorker and OutboxWorker.
+type Worker interface {
+	ID() string
+	Start(ctx context.Context)
+	Stop()
+	Status() WorkerStatus
+}
+
+// WorkerPool runs a fixed number of workers and waits for them on shutdown.
+type WorkerPool struct {
+	name    string
+	logger  logger.Logger
+	workers []Worker
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	startedAt time.Time
+}
+
+// NewWorkerPool builds size workers using newWorker.
+func NewWorkerPool(name string, log logger.Logger, size int, newWorker func() Worker) *WorkerPool {
+	workers := make([]Worker, size)
+	for i := range workers {
+		workers[i] = newWorker()
+	}
+	return &WorkerPool{
+		name:    name,
+		logger:  log,
+		workers: workers,
+	}
+}
+
+func (p *WorkerPool) Name() string {
+	return p.name
+}
+
+func (p *WorkerPool) Size() int {
+	return len(p.workers)
+}
+
+// Status returns a snapshot of every worker in the pool.
+func (p *WorkerPool) Status() []WorkerStatus {
+	statuses := make([]WorkerStatus, len(p.workers))
+	for i, w := range p.workers {
+		statuses[i] = w.Status()
+	}
+	return statuses
+}
+
+// Stalled returns the ids of the workers that have not polled for longer
+// than after, counting from the pool's start for workers yet to poll. A
+// worker busy with a batch does not poll either, so after should exceed the
+// longest expected batch as well as the maximum idle poll interval.
+func (p *WorkerPool) Stalled(after time.Duration) []string {
+	p.mu.Lock()
+	startedAt := p.startedAt
+	p.mu.Unlock()
+	if startedAt.IsZero() {
+		return nil
+	}
+
+	var stalled []string
+	for _, w := range p.workers {
+		status := w.Status()
+		last := startedAt
+		if status.LastPollAt != nil && status.LastPollAt.After(last) {
+			last = *status.LastPollAt
+		}
+		if time.Since(last) > after {
+			stalled = append(stalled, status.ID)
+		}
+	}
+	return stalled
+}
+
+// Unstarted returns the ids of the workers that have not polled yet, all of
+// them before the pool is started.
+func (p *WorkerPool) Unstarted() []string {
+	var unstarted []string
+	for _, w := range p.workers {
+		if status := w.Status(); status.LastPollAt == nil {
+			unstarted = append(unstarted, status.ID)
+		}
+	}
+	return unstarted
+}
+
+func (p *WorkerPool) Start(ctx context.Context) {
+	p.mu.Lock()
+	p.startedAt = time.Now()
+	p.mu.Unlock()
+
+	p.logger.Info("Starting worker pool",
+		logger.String("pool", p.name),
+		logger.Int("size", len(p.workers)))
+
+	for _, w := range p.workers {
+		p.wg.Add(1)
+		go func(w Worker) {
+			defer p.wg.Done()
+			w.Start(ctx)
+		}(w)
+	}
+}
+
+// Run starts the workers and blocks until ctx is done and they have
+// returned. The pool then counts as not started again, so its idle workers
+// are not reported stalled and it can run again later, e.g. each time its
+// instance is elected to dispatch the outbox.
+func (p *WorkerPool) Run(ctx context.Context) {
+	p.Start(ctx)
+	<-ctx.Done()
+	p.wg.Wait()
+
+	p.mu.Lock()
+	p.startedAt = time.Time{}
+	p.mu.Unlock()
+}
+
+// Stop signals every worker to stop and blocks until their current batch
+// has finished.
+func (p *WorkerPool) Stop() {
+	for _, w := range p.workers {
+		w.Stop()
+	}
+	p.wg.Wait()
+
+	p.logger.Info("Worker pool stopped",
+		logger.String("pool", p.name),
+		logger.Int("size", len(p.workers)))
+}
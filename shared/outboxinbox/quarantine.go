@@ -0,0 +1,97 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrPoisonMessage marks a failure that retrying cannot fix, such as a
+// payload that doesn't unmarshal or fails validation. Workers quarantine
+// these messages immediately instead of retrying them.
+var ErrPoisonMessage = errors.New("poison message")
+
+// Poison wraps err so workers quarantine the message instead of retrying it.
+func Poison(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPoisonMessage, err)
+}
+
+// QuarantinedMessage is a quarantined row with the details needed to debug
+// and correct it.
+type QuarantinedMessage struct {
+	ID           int64           `db:"id" json:"id"`
+	MessageID    string          `db:"message_id" json:"message_id"`
+	EventType    string          `db:"event_type" json:"event_type"`
+	Payload      Payload         `db:"payload" json:"payload"`
+	Headers      json.RawMessage `db:"headers" json:"headers"`
+	Error        *string         `db:"error" json:"error,omitempty"`
+	ErrorHistory json.RawMessage `db:"error_history" json:"error_history"`
+	RetryCount   int             `db:"retry_count" json:"retry_count"`
+	CreatedAt    time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// QuarantineStore exposes quarantined messages for inspection. Corrected
+// messages are resubmitted through Replayer.Replay.
+type QuarantineStore interface {
+	Config() Config
+	ListQuarantined(ctx context.Context, limit, offset int) ([]QuarantinedMessage, error)
+	GetQuarantined(ctx context.Context, id int64) (*QuarantinedMessage, error)
+}
+
+func quarantine(ctx context.Context, db *sqlx.DB, qb queryBuilder, id int64, reason string) error {
+	query := qb.build(`
+		UPDATE {table}
+		SET status = {quarantined},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL,
+			error = $2,
+			error_history = ` + errorHistoryEntry + `
+		WHERE id = $1
+	`)
+	_, err := db.ExecContext(ctx, query, id, reason)
+	return err
+}
+
+// quarantinedColumns takes the headers expression separately because only
+// the outbox table stores headers.
+func quarantinedColumns(headers string) string {
+	return `id, message_id, event_type, payload, ` + headers + ` AS headers, error,
+		COALESCE(error_history, '[]'::jsonb) AS error_history, retry_count, created_at, updated_at`
+}
+
+func listQuarantined(ctx context.Context, db *sqlx.DB, qb queryBuilder, headers string, limit, offset int) ([]QuarantinedMessage, error) {
+	messages := []QuarantinedMessage{}
+	query := qb.build(`
+		SELECT ` + quarantinedColumns(headers) + `
+		FROM {table}
+		WHERE status = {quarantined}
+		ORDER BY updated_at DESC
+		LIMIT $1 OFFSET $2
+	`)
+	if err := db.SelectContext(ctx, &messages, query, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to list quarantined messages: %w", err)
+	}
+	return messages, nil
+}
+
+func getQuarantined(ctx context.Context, db *sqlx.DB, qb queryBuilder, headers string, id int64) (*QuarantinedMessage, error) {
+	var msg QuarantinedMessage
+	query := qb.build(`
+		SELECT ` + quarantinedColumns(headers) + `
+		FROM {table}
+		WHERE id = $1 AND status = {quarantined}
+	`)
+	if err := db.GetContext(ctx, &msg, query, id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,163 @@
+package outboxinbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"observability-system/shared/lock"
+	"observability-system/shared/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StuckMessage is a PROCESSING row whose lock expired and was released back
+// to PENDING. LockedBy is the worker that held the stale lock.
+type StuckMessage struct {
+	ID        int64     `db:"id"`
+	MessageID string    `db:"message_id"`
+	EventType string    `db:"event_type"`
+	LockedBy  string    `db:"locked_by"`
+	LockedAt  time.Time `db:"locked_at"`
+}
+
+// StuckMessageReaper is implemented by stores whose abandoned locks can be
+// released.
+type StuckMessageReaper interface {
+	Config() Config
+	ReapStuckMessages(ctx context.Context, lockTimeout time.Duration) ([]StuckMessage, error)
+}
+
+// reapStuckMessages returns rows locked for longer than lockTimeout to
+// PENDING and reports the previous lock holder of each.
+func reapStuckMessages(ctx context.Context, db *sqlx.DB, qb queryBuilder, lockTimeout time.Duration) ([]StuckMessage, error) {
+	query := qb.build(`
+		WITH stuck AS (
+			SELECT id, locked_by, locked_at
+			FROM {table}
+			WHERE status = {processing}
+			  AND locked_at < NOW() - INTERVAL '1 second' * $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE {table} t
+		SET status = {pending},
+			locked_at = NULL,
+			locked_by = NULL,
+			updated_at = NOW()
+		FROM stuck
+		WHERE t.id = stuck.id
+		RETURNING t.id, t.message_id, t.event_type,
+			COALESCE(stuck.locked_by, '') AS locked_by, stuck.locked_at
+	`)
+
+	messages := []StuckMessage{}
+	if err := db.SelectContext(ctx, &messages, query, lockTimeout.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to reset stuck messages: %w", err)
+	}
+	return messages, nil
+}
+
+// ReaperLockName is the advisory lock the replicas of a service share for
+// the reaper.
+const ReaperLockName = "outboxinbox.reaper"
+
+// Reaper periodically releases messages stuck in PROCESSING, e.g. after a
+// worker crashed while holding their lock, so they don't wait for the next
+// worker restart.
+type Reaper struct {
+	reapers     []StuckMessageReaper
+	logger      logger.Logger
+	interval    time.Duration
+	lockTimeout time.Duration
+	locker      *lock.Locker
+	stopCh      chan struct{}
+}
+
+// NewReaper creates a reaper. A zero lockTimeout uses each store's
+// Config.LockTimeout.
+func NewReaper(
+	log logger.Logger,
+	interval time.Duration,
+	lockTimeout time.Duration,
+	reapers ...StuckMessageReaper,
+) *Reaper {
+	return &Reaper{
+		reapers:     reapers,
+		logger:      log,
+		interval:    interval,
+		lockTimeout: lockTimeout,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// SetLocker makes each run take ReaperLockName first, so only one replica
+// reaps at a time. Without a locker every replica runs.
+func (r *Reaper) SetLocker(locker *lock.Locker) {
+	r.locker = locker
+}
+
+func (r *Reaper) Start(ctx context.Context) {
+	r.logger.Info("Starting stuck-message reaper",
+		logger.String("interval", r.interval.String()),
+		logger.String("lock_timeout", r.lockTimeout.String()))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Stopping stuck-message reaper due to context cancellation")
+			return
+		case <-r.stopCh:
+			r.logger.Info("Stuck-message reaper stopped")
+			return
+		case <-ticker.C:
+			r.run(ctx)
+		}
+	}
+}
+
+func (r *Reaper) Stop() {
+	close(r.stopCh)
+}
+
+func (r *Reaper) run(ctx context.Context) {
+	ran, err := r.locker.RunExclusive(ctx, ReaperLockName, r.RunOnce)
+	if err != nil {
+		r.logger.Error("Failed to run stuck-message reaper", logger.Err(err))
+	} else if !ran {
+		r.logger.Debug("Stuck-message reaper is running on another replica")
+	}
+}
+
+// RunOnce releases expired locks in every table.
+func (r *Reaper) RunOnce(ctx context.Context) {
+	for _, s := range r.reapers {
+		cfg := s.Config()
+		lockTimeout := r.lockTimeout
+		if lockTimeout <= 0 {
+			lockTimeout = cfg.LockTimeout
+		}
+
+		messages, err := s.ReapStuckMessages(ctx, lockTimeout)
+		if err != nil {
+			r.logger.Error("Failed to reap stuck messages",
+				logger.Err(err),
+				logger.String("table", cfg.TableName))
+			continue
+		}
+
+		for _, msg := range messages {
+			ReapedTotal.WithLabelValues(cfg.TableName, msg.EventType).Inc()
+
+			r.logger.Warn("Released stuck message with expired lock",
+				logger.String("table", cfg.TableName),
+				logger.Int64("id", msg.ID),
+				logger.String("message_id", msg.MessageID),
+				logger.String("event_type", msg.EventType),
+				logger.String("worker_id", msg.LockedBy),
+				logger.String("locked_for", time.Since(msg.LockedAt).Round(time.Second).String()))
+		}
+	}
+}
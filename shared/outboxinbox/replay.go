@@ -0,0 +1,118 @@
+package outboxinbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplayFilter selects messages for a bulk replay. Zero values are ignored,
+// except Status which defaults to the configured Failed status.
+type ReplayFilter struct {
+	Status        Status
+	EventType     string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+}
+
+// Replayer resets processed or failed messages so workers pick them up again.
+type Replayer interface {
+	Config() Config
+	Replay(ctx context.Context, id int64, payload json.RawMessage) (string, error)
+	ReplayByFilter(ctx context.Context, filter ReplayFilter) (int64, error)
+}
+
+const replaySet = `
+	status = {pending},
+	retry_count = 0,
+	error = NULL,
+	next_retry_at = NULL,
+	locked_at = NULL,
+	locked_by = NULL,
+	updated_at = NOW()
+`
+
+// replay resets a single message to PENDING, optionally replacing its payload.
+// Messages currently being processed are not touched; sql.ErrNoRows is
+// returned when no eligible message matches.
+func replay(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, id int64, payload json.RawMessage) (string, error) {
+	var newPayload interface{}
+	if len(payload) > 0 {
+		encoded, err := encodePayload(payload, cfg)
+		if err != nil {
+			return "", err
+		}
+		newPayload = encoded
+	}
+
+	query := qb.build(`
+		UPDATE {table}
+		SET ` + replaySet + `,
+			payload = COALESCE($2::jsonb, payload)
+		WHERE id = $1 AND status <> {processing}
+		RETURNING message_id
+	`)
+
+	var messageID string
+	if err := db.GetContext(ctx, &messageID, query, id, newPayload); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+func replayByFilter(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, filter ReplayFilter) (int64, error) {
+	status := filter.Status
+	if status == "" {
+		status = cfg.Statuses.Failed
+	}
+	if status == cfg.Statuses.Processing {
+		return 0, fmt.Errorf("cannot replay messages in status %s", status)
+	}
+
+	conditions := []string{"status = $1"}
+	args := []interface{}{string(status)}
+
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		conditions = append(conditions, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	limit := ""
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
+	}
+
+	query := qb.build(`
+		UPDATE {table}
+		SET ` + replaySet + `
+		WHERE id IN (
+			SELECT id FROM {table}
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			ORDER BY created_at ASC
+			` + limit + `
+			FOR UPDATE SKIP LOCKED
+		)
+	`)
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay %s messages: %w", cfg.TableName, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
@@ -0,0 +1,76 @@
+package outboxinbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const archiveSchema = `
+	CREATE TABLE IF NOT EXISTS {archive} (
+		id BIGSERIAL PRIMARY KEY,
+		source_id BIGINT NOT NULL,
+		message_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		status VARCHAR(50),
+		row_data JSONB NOT NULL,
+		created_at TIMESTAMP,
+		archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_{archive}_message_id ON {archive}(message_id);
+	CREATE INDEX IF NOT EXISTS idx_{archive}_archived_at ON {archive}(archived_at);
+`
+
+// Purger removes completed rows older than a retention period. Both stores
+// implement it.
+type Purger interface {
+	Config() Config
+	PurgeCompleted(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+}
+
+func initArchiveSchema(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder) error {
+	if cfg.ArchiveTableName == "" {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, qb.build(archiveSchema)); err != nil {
+		return fmt.Errorf("failed to initialize %s schema: %w", cfg.ArchiveTableName, err)
+	}
+	return nil
+}
+
+// purgeCompleted deletes up to limit completed rows last updated before
+// olderThan. When an archive table is configured the deleted rows are copied
+// into it in the same statement.
+func purgeCompleted(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, olderThan time.Duration, limit int) (int64, error) {
+	selectIDs := `
+		SELECT id FROM {table}
+		WHERE status = {completed}
+		  AND updated_at < NOW() - INTERVAL '1 second' * $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	var query string
+	if cfg.ArchiveTableName == "" {
+		query = qb.build(`DELETE FROM {table} WHERE id IN (` + selectIDs + `)`)
+	} else {
+		query = qb.build(`
+			WITH purged AS (
+				DELETE FROM {table} WHERE id IN (` + selectIDs + `)
+				RETURNING *
+			)
+			INSERT INTO {archive} (source_id, message_id, event_type, status, row_data, created_at)
+			SELECT id, message_id, event_type, status, to_jsonb(purged), created_at FROM purged
+		`)
+	}
+
+	result, err := db.ExecContext(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge %s: %w", cfg.TableName, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
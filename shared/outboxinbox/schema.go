@@ -0,0 +1,121 @@
+package outboxinbox
+
+const inboxSchema = `
+	CREATE TABLE IF NOT EXISTS {table} (
+		id SERIAL PRIMARY KEY,
+		sender_id VARCHAR(255) NOT NULL DEFAULT 'unknown',
+		message_id VARCHAR(255) UNIQUE NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(50) DEFAULT {pending},
+		retry_count INT DEFAULT 0,
+		exchange VARCHAR(255) DEFAULT {exchange},
+		routing_key VARCHAR(255),
+		error TEXT,
+		locked_at TIMESTAMP,
+		locked_by VARCHAR(255),
+		next_retry_at TIMESTAMP,
+		error_history JSONB DEFAULT '[]',
+		partition_key VARCHAR(255),
+		correlation_id VARCHAR(255),
+		causation_id VARCHAR(255),
+		trace_context JSONB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_{table}_status ON {table}(status);
+	CREATE INDEX IF NOT EXISTS idx_{table}_message_id ON {table}(message_id);
+	CREATE INDEX IF NOT EXISTS idx_{table}_locked_at ON {table}(locked_at);
+
+	-- Migration for tables created by older service-local implementations
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS sender_id VARCHAR(255) DEFAULT 'unknown';
+	ALTER TABLE {table} ALTER COLUMN sender_id SET DEFAULT 'unknown';
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS exchange VARCHAR(255) DEFAULT {exchange};
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS routing_key VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS error TEXT;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS locked_by VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_{table}_next_retry_at ON {table}(next_retry_at);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS error_history JSONB DEFAULT '[]';
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS partition_key VARCHAR(255);
+	CREATE INDEX IF NOT EXISTS idx_{table}_partition_key ON {table}(partition_key, id) WHERE partition_key IS NOT NULL;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS causation_id VARCHAR(255);
+	CREATE INDEX IF NOT EXISTS idx_{table}_correlation_id ON {table}(correlation_id) WHERE correlation_id IS NOT NULL;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS trace_context JSONB;
+
+	-- Partial indexes stay small regardless of how many completed rows churn
+	-- through the table.
+	CREATE INDEX IF NOT EXISTS idx_{table}_pending ON {table}(created_at) WHERE status = {pending};
+	CREATE INDEX IF NOT EXISTS idx_{table}_processing ON {table}(locked_at) WHERE status = {processing};
+`
+
+const outboxSchema = `
+	CREATE TABLE IF NOT EXISTS {table} (
+		id SERIAL PRIMARY KEY,
+		message_id VARCHAR(255) UNIQUE NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(50) DEFAULT {pending},
+		retry_count INT DEFAULT 0,
+		exchange VARCHAR(255) DEFAULT {exchange},
+		routing_key VARCHAR(255),
+		error TEXT,
+		locked_at TIMESTAMP,
+		locked_by VARCHAR(255),
+		next_retry_at TIMESTAMP,
+		error_history JSONB DEFAULT '[]',
+		partition_key VARCHAR(255),
+		headers JSONB,
+		deliver_after TIMESTAMP,
+		priority INT NOT NULL DEFAULT 0,
+		correlation_id VARCHAR(255),
+		causation_id VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_{table}_status ON {table}(status);
+	CREATE INDEX IF NOT EXISTS idx_{table}_locked_at ON {table}(locked_at);
+	CREATE INDEX IF NOT EXISTS idx_{table}_message_id ON {table}(message_id);
+
+	-- Migration for tables created by older service-local implementations
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS message_id VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS exchange VARCHAR(255) DEFAULT {exchange};
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS routing_key VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS error TEXT;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS locked_by VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_{table}_next_retry_at ON {table}(next_retry_at);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS error_history JSONB DEFAULT '[]';
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS partition_key VARCHAR(255);
+	CREATE INDEX IF NOT EXISTS idx_{table}_partition_key ON {table}(partition_key, id) WHERE partition_key IS NOT NULL;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS headers JSONB;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS deliver_after TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_{table}_deliver_after ON {table}(deliver_after) WHERE deliver_after IS NOT NULL;
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_{table}_priority ON {table}(priority DESC, created_at ASC);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255);
+	ALTER TABLE {table} ADD COLUMN IF NOT EXISTS causation_id VARCHAR(255);
+	CREATE INDEX IF NOT EXISTS idx_{table}_correlation_id ON {table}(correlation_id) WHERE correlation_id IS NOT NULL;
+
+	-- Partial indexes stay small regardless of how many published rows churn
+	-- through the table.
+	CREATE INDEX IF NOT EXISTS idx_{table}_pending ON {table}(priority DESC, created_at ASC) WHERE status = {pending};
+	CREATE INDEX IF NOT EXISTS idx_{table}_processing ON {table}(locked_at) WHERE status = {processing};
+`
+
+// statusNormalization rewrites case variants of the configured statuses, such
+// as the lowercase values written by older service-local implementations.
+const statusNormalization = `
+	UPDATE {table}
+	SET status = CASE LOWER(status)
+			WHEN LOWER({pending}) THEN {pending}
+			WHEN LOWER({processing}) THEN {processing}
+			WHEN LOWER({completed}) THEN {completed}
+			WHEN LOWER({failed}) THEN {failed}
+		END
+	WHERE status NOT IN ({pending}, {processing}, {completed}, {failed})
+	  AND LOWER(status) IN (LOWER({pending}), LOWER({processing}), LOWER({completed}), LOWER({failed}));
+`
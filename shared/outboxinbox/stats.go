@@ -0,0 +1,114 @@
+package outboxinbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"observability-system/shared/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Stats is a point-in-time summary of an inbox/outbox table.
+type Stats struct {
+	Counts           map[Status]int64
+	OldestPendingAge time.Duration
+}
+
+// StatsReader reports row counts for a table. Both stores implement it.
+type StatsReader interface {
+	Config() Config
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+func readStats(ctx context.Context, db *sqlx.DB, qb queryBuilder) (*Stats, error) {
+	var rows []struct {
+		Status string `db:"status"`
+		Count  int64  `db:"count"`
+	}
+	if err := db.SelectContext(ctx, &rows, qb.build(`
+		SELECT status, COUNT(*) AS count FROM {table} GROUP BY status
+	`)); err != nil {
+		return nil, fmt.Errorf("failed to count messages by status: %w", err)
+	}
+
+	var oldestPendingSeconds float64
+	if err := db.GetContext(ctx, &oldestPendingSeconds, qb.build(`
+		SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)::float8
+		FROM {table}
+		WHERE status = {pending}
+	`)); err != nil {
+		return nil, fmt.Errorf("failed to read oldest pending message: %w", err)
+	}
+
+	stats := &Stats{
+		Counts:           make(map[Status]int64, len(rows)),
+		OldestPendingAge: time.Duration(oldestPendingSeconds * float64(time.Second)),
+	}
+	for _, r := range rows {
+		stats.Counts[Status(r.Status)] = r.Count
+	}
+	return stats, nil
+}
+
+// StatsCollector periodically refreshes the row-count gauges so lag can be
+// alerted on from Prometheus.
+type StatsCollector struct {
+	readers  []StatsReader
+	logger   logger.Logger
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+func NewStatsCollector(log logger.Logger, interval time.Duration, readers ...StatsReader) *StatsCollector {
+	return &StatsCollector{
+		readers:  readers,
+		logger:   log,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (c *StatsCollector) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.RunOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			c.RunOnce(ctx)
+		}
+	}
+}
+
+func (c *StatsCollector) Stop() {
+	close(c.stopCh)
+}
+
+// RunOnce reads stats for every table and updates the gauges.
+func (c *StatsCollector) RunOnce(ctx context.Context) {
+	for _, r := range c.readers {
+		cfg := r.Config()
+
+		stats, err := r.Stats(ctx)
+		if err != nil {
+			c.logger.Error("Failed to collect message stats",
+				logger.Err(err),
+				logger.String("table", cfg.TableName))
+			continue
+		}
+
+		// Always report the known statuses so gauges drop back to zero.
+		for _, status := range []Status{cfg.Statuses.Pending, cfg.Statuses.Processing, cfg.Statuses.Completed, cfg.Statuses.Failed, cfg.Statuses.Quarantined} {
+			MessagesByStatus.WithLabelValues(cfg.TableName, string(status)).Set(float64(stats.Counts[status]))
+		}
+		OldestPendingAge.WithLabelValues(cfg.TableName).Set(stats.OldestPendingAge.Seconds())
+	}
+}
@@ -0,0 +1,406 @@
+package outboxinbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PartitionPeriod is the range covered by each partition of a time-partitioned
+// table.
+type PartitionPeriod string
+
+const (
+	PartitionDaily   PartitionPeriod = "day"
+	PartitionMonthly PartitionPeriod = "month"
+)
+
+// ParsePartitionPeriod validates a configured period. The empty string
+// disables partitioning.
+func ParsePartitionPeriod(s string) (PartitionPeriod, error) {
+	switch p := PartitionPeriod(strings.ToLower(strings.TrimSpace(s))); p {
+	case "", PartitionDaily, PartitionMonthly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid partition period %q: expected %q or %q", s, PartitionDaily, PartitionMonthly)
+	}
+}
+
+// partitionsAhead is how many future partitions are created ahead of time
+// so inserts never arrive before their partition exists.
+const partitionsAhead = 3
+
+// Lookahead is how far ahead of the current time partitions are created.
+// Partitions must be maintained more often than this, or inserts fall into
+// the default partition.
+func (p PartitionPeriod) Lookahead() time.Duration {
+	if p == PartitionMonthly {
+		// The shortest months bound the window.
+		return partitionsAhead * 28 * 24 * time.Hour
+	}
+	return partitionsAhead * 24 * time.Hour
+}
+
+// partitionedOutboxTable is the partitioned variant of the outbox table.
+// Postgres requires the partition column in every unique constraint, so the
+// primary key and message_id uniqueness include created_at. The remaining
+// columns and indexes come from outboxSchema.
+const partitionedOutboxTable = `
+	CREATE TABLE IF NOT EXISTS {table} (
+		id BIGSERIAL,
+		message_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(50) DEFAULT {pending},
+		retry_count INT DEFAULT 0,
+		exchange VARCHAR(255) DEFAULT {exchange},
+		routing_key VARCHAR(255),
+		error TEXT,
+		locked_at TIMESTAMP,
+		locked_by VARCHAR(255),
+		next_retry_at TIMESTAMP,
+		error_history JSONB DEFAULT '[]',
+		partition_key VARCHAR(255),
+		headers JSONB,
+		deliver_after TIMESTAMP,
+		priority INT NOT NULL DEFAULT 0,
+		correlation_id VARCHAR(255),
+		causation_id VARCHAR(255),
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id, created_at),
+		UNIQUE (message_id, created_at)
+	) PARTITION BY RANGE (created_at);
+`
+
+// PartitionMaintainer is implemented by stores whose table may be
+// partitioned by time. A PartitionKeeper calls MaintainPartitions on every
+// run.
+type PartitionMaintainer interface {
+	MaintainPartitions(ctx context.Context) error
+	Config() Config
+}
+
+// initPartitionedTable creates the partitioned parent table and its upcoming
+// partitions. An existing unpartitioned table is migrated first.
+func initPartitionedTable(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, ddl, schema string) error {
+	var relkind string
+	err := db.GetContext(ctx, &relkind, `SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)`, cfg.TableName)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if _, err := db.ExecContext(ctx, qb.build(ddl)); err != nil {
+			return fmt.Errorf("failed to create partitioned %s table: %w", cfg.TableName, err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to inspect %s table: %w", cfg.TableName, err)
+	case relkind != "p":
+		return migrateToPartitioned(ctx, db, cfg, qb, ddl, schema)
+	}
+
+	return createPartitions(ctx, db, cfg, time.Time{})
+}
+
+// migrateToPartitioned renames an unpartitioned table to {table}_legacy and
+// moves its unfinished rows into a new partitioned table, all in one
+// transaction. Completed rows stay behind in the legacy table, which is left
+// for the operator to archive or drop.
+func migrateToPartitioned(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, ddl, schema string) error {
+	legacy := cfg.TableName + "_legacy"
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Bring the old table up to date so every column exists on both sides.
+	if _, err := tx.ExecContext(ctx, qb.build(schema)); err != nil {
+		return fmt.Errorf("failed to update %s schema before migration: %w", cfg.TableName, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, cfg.TableName, legacy)); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", cfg.TableName, err)
+	}
+
+	// Index names are schema-wide; free them for the new table.
+	var indexes []string
+	if err := tx.SelectContext(ctx, &indexes, `SELECT indexname FROM pg_indexes WHERE tablename = $1`, legacy); err != nil {
+		return fmt.Errorf("failed to list %s indexes: %w", legacy, err)
+	}
+	for _, index := range indexes {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER INDEX %s RENAME TO %s_legacy`, index, index)); err != nil {
+			return fmt.Errorf("failed to rename index %s: %w", index, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, qb.build(ddl)); err != nil {
+		return fmt.Errorf("failed to create partitioned %s table: %w", cfg.TableName, err)
+	}
+
+	var oldest sql.NullTime
+	if err := tx.GetContext(ctx, &oldest, qb.build(`SELECT MIN(created_at) FROM `+legacy+` WHERE status <> {completed}`)); err != nil {
+		return fmt.Errorf("failed to find oldest unfinished %s row: %w", legacy, err)
+	}
+	if err := createPartitions(ctx, tx, cfg, oldest.Time); err != nil {
+		return err
+	}
+
+	columns := `id, message_id, event_type, payload, status, retry_count, exchange, routing_key, error,
+		locked_at, locked_by, next_retry_at, error_history, partition_key, headers, deliver_after,
+		priority, correlation_id, causation_id, created_at, updated_at`
+	if _, err := tx.ExecContext(ctx, qb.build(`
+		INSERT INTO {table} (`+columns+`)
+		SELECT `+columns+` FROM `+legacy+` WHERE status <> {completed}
+	`)); err != nil {
+		return fmt.Errorf("failed to copy unfinished rows from %s: %w", legacy, err)
+	}
+
+	// New ids must keep increasing past the copied ones, since partition key
+	// ordering compares ids.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		`SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM %s), false)`,
+		cfg.TableName, legacy)); err != nil {
+		return fmt.Errorf("failed to advance %s id sequence: %w", cfg.TableName, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s partitioning migration: %w", cfg.TableName, err)
+	}
+	return nil
+}
+
+// createPartitions makes sure partitions exist from the period containing
+// since (or the current period when since is zero or later) through the next
+// partitionsAhead periods. Bounds follow the database clock, which is what
+// the created_at default uses.
+//
+// A default partition catches rows inserted when maintenance fell behind.
+// Their periods get partitions too, and the rows are moved into them, so
+// retention drops them like any other.
+func createPartitions(ctx context.Context, db sqlx.ExtContext, cfg Config, since time.Time) error {
+	defaultPartition := cfg.TableName + "_default"
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT`,
+		defaultPartition, cfg.TableName)); err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", defaultPartition, err)
+	}
+
+	var current time.Time
+	if err := sqlx.GetContext(ctx, db, &current, `SELECT date_trunc($1, LOCALTIMESTAMP)`, string(cfg.PartitionPeriod)); err != nil {
+		return fmt.Errorf("failed to read partition period start: %w", err)
+	}
+
+	var stray sql.NullTime
+	if err := sqlx.GetContext(ctx, db, &stray, `SELECT MIN(created_at) FROM `+defaultPartition); err != nil {
+		return fmt.Errorf("failed to inspect partition %s: %w", defaultPartition, err)
+	}
+	if stray.Valid && (since.IsZero() || stray.Time.Before(since)) {
+		since = stray.Time
+	}
+
+	from := current
+	if !since.IsZero() && since.Before(current) {
+		if err := sqlx.GetContext(ctx, db, &from, `SELECT date_trunc($1, $2::timestamp)`, string(cfg.PartitionPeriod), since); err != nil {
+			return fmt.Errorf("failed to read partition period start: %w", err)
+		}
+	}
+
+	last := cfg.PartitionPeriod.add(current, partitionsAhead)
+	for ; !from.After(last); from = cfg.PartitionPeriod.add(from, 1) {
+		if err := createPartition(ctx, db, cfg, from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPartition creates the partition of the period starting at from,
+// unless it exists. Rows of the period held by the default partition are
+// moved into it, which Postgres requires before the range can be attached.
+func createPartition(ctx context.Context, db sqlx.ExtContext, cfg Config, from time.Time) error {
+	name := partitionName(cfg, from)
+	to := cfg.PartitionPeriod.add(from, 1)
+	bounds := fmt.Sprintf(`FROM ('%s') TO ('%s')`, from.Format(time.DateTime), to.Format(time.DateTime))
+
+	var exists, stray bool
+	if err := sqlx.GetContext(ctx, db, &exists, `SELECT to_regclass($1) IS NOT NULL`, name); err != nil {
+		return fmt.Errorf("failed to inspect partition %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+	if err := sqlx.GetContext(ctx, db, &stray, fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s_default WHERE created_at >= $1 AND created_at < $2)`, cfg.TableName),
+		from, to); err != nil {
+		return fmt.Errorf("failed to inspect partition %s_default: %w", cfg.TableName, err)
+	}
+	if !stray {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES %s`,
+			name, cfg.TableName, bounds)); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+		return nil
+	}
+
+	return inTx(ctx, db, func(tx sqlx.ExtContext) error {
+		statements := []string{
+			fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, name, cfg.TableName),
+			fmt.Sprintf(`WITH moved AS (
+				DELETE FROM %s_default WHERE created_at >= '%s' AND created_at < '%s' RETURNING *
+			) INSERT INTO %s SELECT * FROM moved`,
+				cfg.TableName, from.Format(time.DateTime), to.Format(time.DateTime), name),
+			fmt.Sprintf(`ALTER TABLE %s ATTACH PARTITION %s FOR VALUES %s`, cfg.TableName, name, bounds),
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to move default partition rows into %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// inTx runs fn in a transaction, or in db's when it already is one.
+func inTx(ctx context.Context, db sqlx.ExtContext, fn func(tx sqlx.ExtContext) error) error {
+	conn, ok := db.(*sqlx.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// dropExpiredPartitions drops partitions whose whole range is older than
+// olderThan and that contain only completed rows. Rows are copied into the
+// archive table first when one is configured. It returns the number of rows
+// removed.
+func dropExpiredPartitions(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, olderThan time.Duration) (int64, error) {
+	var partitions []string
+	err := db.SelectContext(ctx, &partitions, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+		ORDER BY c.relname
+	`, cfg.TableName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s partitions: %w", cfg.TableName, err)
+	}
+
+	var cutoff time.Time
+	if err := db.GetContext(ctx, &cutoff, `SELECT LOCALTIMESTAMP - INTERVAL '1 second' * $1`, olderThan.Seconds()); err != nil {
+		return 0, fmt.Errorf("failed to read partition cutoff: %w", err)
+	}
+
+	var total int64
+	for _, name := range partitions {
+		from, ok := partitionStart(cfg, name)
+		if !ok || cfg.PartitionPeriod.add(from, 1).After(cutoff) {
+			continue
+		}
+
+		count, err := dropPartition(ctx, db, cfg, qb, name)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// dropPartition drops a single partition unless it still holds unfinished
+// rows.
+func dropPartition(ctx context.Context, db *sqlx.DB, cfg Config, qb queryBuilder, name string) (int64, error) {
+	unfinishedQuery := qb.build(`SELECT EXISTS (SELECT 1 FROM ` + name + ` WHERE status <> {completed})`)
+
+	// Checked before taking any lock so partitions held back by a stuck or
+	// failed row don't block the parent table on every run.
+	var unfinished bool
+	if err := db.GetContext(ctx, &unfinished, unfinishedQuery); err != nil {
+		return 0, fmt.Errorf("failed to inspect partition %s: %w", name, err)
+	}
+	if unfinished {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Detaching first takes the lock that keeps workers from changing rows
+	// while the partition is checked and archived.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, cfg.TableName, name)); err != nil {
+		return 0, fmt.Errorf("failed to detach partition %s: %w", name, err)
+	}
+
+	if err := tx.GetContext(ctx, &unfinished, unfinishedQuery); err != nil {
+		return 0, fmt.Errorf("failed to inspect partition %s: %w", name, err)
+	}
+	if unfinished {
+		return 0, nil
+	}
+
+	var count int64
+	if cfg.ArchiveTableName != "" {
+		result, err := tx.ExecContext(ctx, qb.build(`
+			INSERT INTO {archive} (source_id, message_id, event_type, status, row_data, created_at)
+			SELECT id, message_id, event_type, status, to_jsonb(p), created_at FROM `+name+` p
+		`))
+		if err != nil {
+			return 0, fmt.Errorf("failed to archive partition %s: %w", name, err)
+		}
+		count, _ = result.RowsAffected()
+	} else if err := tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM `+name); err != nil {
+		return 0, fmt.Errorf("failed to count partition %s: %w", name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP TABLE `+name); err != nil {
+		return 0, fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	return count, tx.Commit()
+}
+
+func (p PartitionPeriod) layout() string {
+	if p == PartitionMonthly {
+		return "200601"
+	}
+	return "20060102"
+}
+
+func (p PartitionPeriod) add(t time.Time, n int) time.Time {
+	if p == PartitionMonthly {
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+func partitionName(cfg Config, from time.Time) string {
+	return cfg.TableName + "_p" + from.Format(cfg.PartitionPeriod.layout())
+}
+
+// partitionStart parses the period start back out of a partition name created
+// by partitionName.
+func partitionStart(cfg Config, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, cfg.TableName+"_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	from, err := time.Parse(cfg.PartitionPeriod.layout(), suffix)
+	return from, err == nil
+}
@@ -0,0 +1,61 @@
+package outboxinbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TxMessageHandler processes an inbox message inside the transaction that
+// also marks it processed, so business writes and the status change commit or
+// roll back together.
+type TxMessageHandler func(ctx context.Context, tx *sqlx.Tx, msg InboxMessage) error
+
+// ErrLockLost is returned by ProcessInTx when the message is no longer locked
+// by the calling worker, e.g. because its lock expired and another worker took
+// it over. The handler's writes are rolled back.
+var ErrLockLost = errors.New("message lock lost")
+
+// processInTx runs fn and marks the row completed in one transaction. The
+// update only succeeds while workerID still holds the row's lock.
+func processInTx(ctx context.Context, db *sqlx.DB, qb queryBuilder, id int64, workerID string, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, qb.build(`
+		UPDATE {table}
+		SET status = {completed},
+			updated_at = NOW(),
+			locked_at = NULL,
+			locked_by = NULL
+		WHERE id = $1
+		  AND status = {processing}
+		  AND locked_by = $2
+	`), id, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to mark message as processed: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		err = ErrLockLost
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package outboxinbox
+
+import (
+	"sync"
+	"time"
+)
+
+// WorkerStatus is a snapshot of a worker's recent activity.
+type WorkerStatus struct {
+	ID            string     `json:"id"`
+	LastPollAt    *time.Time `json:"last_poll_at,omitempty"`
+	LastBatchSize int        `json:"last_batch_size"`
+	// ErrorStreak counts consecutive failed polls and message attempts; any
+	// successfully handled message resets it.
+	ErrorStreak int    `json:"error_streak"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// workerActivity records what a worker has been doing for its Status.
+type workerActivity struct {
+	mu            sync.Mutex
+	lastPollAt    time.Time
+	lastBatchSize int
+	errorStreak   int
+	lastError     string
+}
+
+func (a *workerActivity) polled(batchSize int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastPollAt = time.Now()
+	a.lastBatchSize = batchSize
+}
+
+func (a *workerActivity) failed(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errorStreak++
+	a.lastError = err.Error()
+}
+
+func (a *workerActivity) succeeded() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errorStreak = 0
+}
+
+func (a *workerActivity) status(id string) WorkerStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := WorkerStatus{
+		ID:            id,
+		LastBatchSize: a.lastBatchSize,
+		ErrorStreak:   a.errorStreak,
+		LastError:     a.lastError,
+	}
+	if !a.lastPollAt.IsZero() {
+		lastPollAt := a.lastPollAt
+		status.LastPollAt = &lastPollAt
+	}
+	return status
+}
@@ -0,0 +1,244 @@
+// Package problem implements RFC 7807 problem details, the error response
+// format shared by the services. Every problem carries a machine-readable
+// code; clients should branch on the code rather than on the status or the
+// human-readable title and detail.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"observability-system/shared/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// typePrefix namespaces the problem type URIs; the code completes them.
+const typePrefix = "urn:observability-system:problem:"
+
+// Code identifies the kind of error independently of its message.
+type Code string
+
+const (
+	CodeValidationFailed      Code = "validation_failed"
+	CodeUnauthorized          Code = "unauthorized"
+	CodeForbidden             Code = "forbidden"
+	CodeNotFound              Code = "not_found"
+	CodeOrderNotFound         Code = "order_not_found"
+	CodeProductNotFound       Code = "product_not_found"
+	CodeMessageNotFound       Code = "message_not_found"
+	CodePaymentNotFound       Code = "payment_not_found"
+	CodeInsufficientStock     Code = "insufficient_stock"
+	CodeConflict              Code = "conflict"
+	CodePaymentFailed         Code = "payment_failed"
+	CodePayloadTooLarge       Code = "payload_too_large"
+	CodeRateLimited           Code = "rate_limited"
+	CodeRequestTimeout        Code = "request_timeout"
+	CodeDependencyUnavailable Code = "dependency_unavailable"
+	CodeOverloaded            Code = "overloaded"
+	CodeInternal              Code = "internal_error"
+)
+
+var titles = map[Code]string{
+	CodeValidationFailed:      "Validation failed",
+	CodeUnauthorized:          "Unauthorized",
+	CodeForbidden:             "Forbidden",
+	CodeNotFound:              "Resource not found",
+	CodeOrderNotFound:         "Order not found",
+	CodeProductNotFound:       "Product not found",
+	CodeMessageNotFound:       "Message not found",
+	CodePaymentNotFound:       "Payment not found",
+	CodeInsufficientStock:     "Insufficient stock",
+	CodeConflict:              "Conflict",
+	CodePaymentFailed:         "Payment failed",
+	CodePayloadTooLarge:       "Payload too large",
+	CodeRateLimited:           "Too many requests",
+	CodeRequestTimeout:        "Request timeout",
+	CodeDependencyUnavailable: "Dependency unavailable",
+	CodeOverloaded:            "Service overloaded",
+	CodeInternal:              "Internal server error",
+}
+
+// Problem is an RFC 7807 problem details object. Extension members, such as
+// the product id of a product_not_found problem, are serialized alongside the
+// standard members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// New creates a problem. The title is derived from the code.
+func New(status int, code Code, detail string) *Problem {
+	title, ok := titles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// ValidationFailed reports a request that failed binding or validation. Field
+// validation errors are listed in the errors extension member.
+func ValidationFailed(err error) *Problem {
+	// A body cut off by http.MaxBytesReader is too large, not malformed.
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)).
+			With("max_bytes", maxBytesErr.Limit)
+	}
+
+	p := New(http.StatusBadRequest, CodeValidationFailed, err.Error())
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		fields := make([]map[string]string, len(fieldErrs))
+		for i, fe := range fieldErrs {
+			fields[i] = map[string]string{
+				"field": fe.Field(),
+				"rule":  fe.Tag(),
+			}
+		}
+		p.Detail = "Request body failed validation"
+		p.With("errors", fields)
+	}
+	return p
+}
+
+// With sets an extension member.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%s (%s): %s", p.Title, p.Code, p.Detail)
+	}
+	return fmt.Sprintf("%s (%s)", p.Title, p.Code)
+}
+
+var standardMembers = []string{"type", "title", "status", "detail", "instance", "code", "request_id", "trace_id"}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	std, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return std, err
+	}
+
+	members := map[string]json.RawMessage{}
+	for k, v := range p.Extensions {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		members[k] = raw
+	}
+	// Standard members take precedence over extensions of the same name.
+	if err := json.Unmarshal(std, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	type plain Problem
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, k := range standardMembers {
+		delete(members, k)
+	}
+	if len(members) > 0 {
+		p.Extensions = members
+	} else {
+		p.Extensions = nil
+	}
+	return nil
+}
+
+// codeKey is the gin context key Write stores the problem's code under.
+const codeKey = "problem_code"
+
+// Write sends p as the response, filling in the instance, request id and
+// trace id of the current request.
+func Write(c *gin.Context, p *Problem) {
+	c.Set(codeKey, p.Code)
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = logger.GetRequestIDFromGin(c)
+	}
+	if p.TraceID == "" {
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			p.TraceID = sc.TraceID().String()
+		}
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		c.Status(p.Status)
+		return
+	}
+	c.Data(p.Status, ContentType, body)
+}
+
+// CodeOf returns the code of the problem written as the request's response,
+// so middleware further out can tell why a request failed.
+func CodeOf(c *gin.Context) (Code, bool) {
+	v, ok := c.Get(codeKey)
+	if !ok {
+		return "", false
+	}
+	code, ok := v.(Code)
+	return code, ok
+}
+
+// Abort writes p and stops the remaining handlers of the request.
+func Abort(c *gin.Context, p *Problem) {
+	Write(c, p)
+	c.Abort()
+}
+
+// Recovery recovers panics in handlers and reports them as internal_error
+// problems.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		Abort(c, New(http.StatusInternalServerError, CodeInternal, ""))
+	})
+}
+
+// NoRoute reports requests for unknown paths as not_found problems.
+func NoRoute(c *gin.Context) {
+	Write(c, New(http.StatusNotFound, CodeNotFound, "No route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Concurrency lets at most max requests of the route called name run at
+// once, across all clients. A request arriving while every slot is taken
+// waits up to wait for one to free up, then gets a 503 overloaded problem
+// with a Retry-After header. Unlike Middleware it protects the handler's
+// shared state rather than sharing it fairly between clients, so the two
+// are usually combined.
+func Concurrency(name string, max int, wait time.Duration, log logger.Logger) gin.HandlerFunc {
+	slots := make(chan struct{}, max)
+	ConcurrencyLimit.WithLabelValues(name).Set(float64(max))
+	inFlight := ConcurrencyInFlight.WithLabelValues(name)
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		start := time.Now()
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(wait)
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+			case <-timer.C:
+				ConcurrencyRejectedTotal.WithLabelValues(name).Inc()
+				log.WarnCtx(ctx, "Concurrency limit reached, rejecting request",
+					logger.String("route", name),
+					logger.Int("limit", max),
+					logger.String("waited", wait.String()),
+				)
+
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+				problem.Abort(c, problem.New(http.StatusServiceUnavailable, problem.CodeOverloaded,
+					fmt.Sprintf("Too many concurrent requests, retry in %d seconds", retryAfter)).
+					With("retry_after_seconds", retryAfter))
+				return
+			case <-ctx.Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+		ConcurrencyWaitSeconds.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			<-slots
+		}()
+		c.Next()
+	}
+}
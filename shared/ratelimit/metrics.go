@@ -0,0 +1,67 @@
+package ratelimit
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	ThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ratelimit_throttled_total",
+			Help: "Total number of requests rejected with 429 by the rate limiter",
+		},
+		[]string{"route", "key_type"},
+	)
+
+	StoreErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ratelimit_store_errors_total",
+			Help: "Total number of requests let through because the rate limit store failed",
+		},
+		[]string{"route"},
+	)
+
+	ConcurrencyInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "concurrency_in_flight",
+			Help: "Number of requests currently running under a concurrency limit",
+		},
+		[]string{"route"},
+	)
+
+	ConcurrencyLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "concurrency_limit",
+			Help: "Maximum number of requests allowed to run at once under a concurrency limit",
+		},
+		[]string{"route"},
+	)
+
+	ConcurrencyRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "concurrency_rejected_total",
+			Help: "Total number of requests rejected with 503 because the concurrency limit was reached",
+		},
+		[]string{"route"},
+	)
+
+	ConcurrencyWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "concurrency_wait_seconds",
+			Help:    "Time requests waited for a slot under a concurrency limit",
+			Buckets: []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"route"},
+	)
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ThrottledTotal,
+		StoreErrorsTotal,
+		ConcurrencyInFlight,
+		ConcurrencyLimit,
+		ConcurrencyRejectedTotal,
+		ConcurrencyWaitSeconds,
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"observability-system/shared/logger"
+	"observability-system/shared/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultAPIKeyHeader identifies clients that send an API key.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// Middleware limits each client of the route called name to limit. Clients
+// sending apiKeyHeader are limited per key, the rest per client IP. Throttled
+// requests get a 429 problem with a Retry-After header. When the store fails
+// the request is let through: an unavailable Redis must not take the API down
+// with it.
+func Middleware(name string, store Store, limit Limit, log logger.Logger, apiKeyHeader string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		keyType, client := clientKey(c, apiKeyHeader)
+		result, err := store.Take(ctx, "ratelimit:"+name+":"+client, limit)
+		if err != nil {
+			StoreErrorsTotal.WithLabelValues(name).Inc()
+			log.WarnCtx(ctx, "Rate limit store failed, allowing request",
+				logger.String("route", name),
+				logger.Err(err),
+			)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+
+			ThrottledTotal.WithLabelValues(name, keyType).Inc()
+			log.WarnCtx(ctx, "Request rate limited",
+				logger.String("route", name),
+				logger.String("key_type", keyType),
+				logger.Int("retry_after_seconds", retryAfter),
+			)
+
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			problem.Abort(c, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited,
+				fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter)).
+				With("retry_after_seconds", retryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller. API keys are hashed so they never end up
+// in Redis or in memory dumps in clear text.
+func clientKey(c *gin.Context, apiKeyHeader string) (keyType, key string) {
+	if apiKeyHeader != "" {
+		if apiKey := c.GetHeader(apiKeyHeader); apiKey != "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			return "api_key", "key:" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return "ip", "ip:" + c.ClientIP()
+}
@@ -0,0 +1,79 @@
+// Package ratelimit throttles HTTP clients with per-client token buckets,
+// kept in memory or, to share the limits between instances, in Redis, and
+// caps how many requests of a route run at once.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: clients may make Burst requests at once and
+// Rate requests per second on average.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next token is available; zero when
+	// the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. Keys identify one client of one limited route.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ParseLimit parses "rate/burst", e.g. "5/10" for 5 requests per second with
+// bursts of 10. The burst may be omitted and defaults to the rate rounded up.
+// An empty string returns a zero Limit, which disables limiting.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+
+	rate, burst, hasBurst := strings.Cut(s, "/")
+
+	var limit Limit
+	var err error
+	if limit.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil || limit.Rate <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: rate must be a positive number", s)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	} else {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return limit, nil
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// take applies the token bucket algorithm to a bucket holding tokens as of
+// last. It returns the bucket's new state and the result.
+func (l Limit) take(tokens float64, last, now time.Time) (float64, Result) {
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(l.Burst), tokens+elapsed*l.Rate)
+	}
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+		return tokens, Result{RetryAfter: wait}
+	}
+	tokens--
+	return tokens, Result{Allowed: true, Remaining: int(tokens)}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"observability-system/shared/redisclient"
+)
+
+// takeScript refills and takes from a bucket stored as a hash of tokens and
+// last refill time, atomically and using the Redis server's clock so every
+// instance agrees on the time. Buckets expire once they would be full again.
+const takeScript = `
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local allowed = 0
+local retry_ms = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry_ms = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, math.floor(tokens), retry_ms}
+`
+
+var takeScriptSHA = func() string {
+	sum := sha1.Sum([]byte(takeScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// RedisConfig configures a RedisStore.
+type RedisConfig = redisclient.Config
+
+// RedisStore keeps buckets in Redis, so all instances of a service share
+// their limits. It needs Redis 4 or later.
+type RedisStore struct {
+	client *redisclient.Client
+}
+
+func NewRedisStore(cfg RedisConfig) *RedisStore {
+	return &RedisStore{client: redisclient.New(cfg)}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	args := []string{"1", key,
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+		strconv.Itoa(limit.Burst),
+	}
+
+	reply, err := s.client.Do(ctx, append([]string{"EVALSHA", takeScriptSHA}, args...)...)
+	var redisErr redisclient.Error
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		reply, err = s.client.Do(ctx, append([]string{"EVAL", takeScript}, args...)...)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{}, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retryMs, _ := values[2].(int64)
+
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retryMs) * time.Millisecond,
+	}, nil
+}
+
+// Close closes the idle connections.
+func (s *RedisStore) Close() {
+	s.client.Close()
+}
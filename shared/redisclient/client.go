@@ -0,0 +1,205 @@
+// Package redisclient is a minimal Redis client speaking the protocol
+// directly over a small connection pool, for the shared packages keeping
+// state in Redis.
+package redisclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config configures a Client.
+type Config struct {
+	Addr     string
+	Password string
+	DB       int
+	// PoolSize caps the idle connections kept open; defaults to 10.
+	PoolSize int
+	// Timeout bounds dialing and each command; defaults to 500ms so a slow
+	// Redis cannot stall the requests using it.
+	Timeout time.Duration
+}
+
+// Error is an error reply. The connection stays usable after one.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Client runs commands on Redis, reusing idle connections.
+type Client struct {
+	cfg  Config
+	idle chan *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func New(cfg Config) *Client {
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 500 * time.Millisecond
+	}
+	return &Client{
+		cfg:  cfg,
+		idle: make(chan *conn, cfg.PoolSize),
+	}
+}
+
+// Addr returns the address of the Redis server.
+func (c *Client) Addr() string {
+	return c.cfg.Addr
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Do runs one command and returns its reply: a string, an int64, nil for a
+// nil reply, or a []interface{} of those for arrays. Error replies are
+// returned as Error.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = cn.SetDeadline(deadline)
+
+	reply, err := cn.command(args...)
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection may hold a partial reply; never reuse it.
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.cfg.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", c.cfg.Addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	_ = cn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+
+	if c.cfg.Password != "" {
+		if _, err := cn.command("AUTH", c.cfg.Password); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := cn.command("SELECT", strconv.Itoa(c.cfg.DB)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (c *conn) command(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: write: %w", err)
+	}
+	return c.readReply()
+}
+
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: read: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, fmt.Errorf("redis: read: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.readReply()
+			var redisErr Error
+			if errors.As(err, &redisErr) {
+				// Keep reading so the connection stays in sync.
+				item = redisErr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,20 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileSource reads secrets from files, such as Docker and Kubernetes secrets
+// mounted into the container. Surrounding whitespace is trimmed.
+type FileSource struct{}
+
+func (FileSource) Fetch(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
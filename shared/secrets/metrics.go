@@ -0,0 +1,30 @@
+package secrets
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	FetchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "secrets_fetches_total",
+			Help: "Total number of secrets fetched from a secrets backend, by backend and result",
+		},
+		[]string{"backend", "result"},
+	)
+
+	RotationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "secrets_rotations_total",
+			Help: "Total number of secrets found changed when fetched again, by backend",
+		},
+		[]string{"backend"},
+	)
+)
+
+// Collectors returns the package's Prometheus collectors so services can
+// register them alongside their own metrics.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		FetchesTotal,
+		RotationsTotal,
+	}
+}
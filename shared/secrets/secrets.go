@@ -0,0 +1,278 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"observability-system/shared/logger"
+)
+
+// Schemes of secret references.
+const (
+	SchemeVault = "vault"
+	SchemeAWS   = "awssm"
+	SchemeFile  = "file"
+)
+
+// DefaultCacheTTL is how long a fetched secret is used before it is
+// fetched again.
+const DefaultCacheTTL = 5 * time.Minute
+
+// fetchTimeout bounds a single fetch from a backend.
+const fetchTimeout = 10 * time.Second
+
+// Reference names a secret held by a backend instead of in the setting
+// itself, written as "scheme://path#key":
+//
+//	vault://secret/data/order-service#database_url
+//	awssm://prod/order-service#rabbitmq_url
+//	file:///run/secrets/database_url
+//
+// Key selects a field of a secret holding a JSON object. A Vault secret
+// always needs one; a file or AWS secret without one is used whole.
+type Reference struct {
+	Scheme string
+	Path   string
+	Key    string
+}
+
+// ParseReference parses value as a secret reference. It reports false for
+// values of any other scheme, which are used as they are.
+func ParseReference(value string) (Reference, bool) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return Reference{}, false
+	}
+	switch scheme {
+	case SchemeVault, SchemeAWS, SchemeFile:
+	default:
+		return Reference{}, false
+	}
+	ref := Reference{Scheme: scheme, Path: rest}
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		ref.Path, ref.Key = rest[:i], rest[i+1:]
+	}
+	return ref, true
+}
+
+func (r Reference) String() string {
+	s := r.Scheme + "://" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// IsReference reports whether value is a secret reference, or a URL whose
+// password is one.
+func IsReference(value string) bool {
+	if _, ok := ParseReference(value); ok {
+		return true
+	}
+	_, ok := passwordReference(value)
+	return ok
+}
+
+// passwordReference returns the reference in the password of URL value,
+// e.g. a DATABASE_URL assembled from a DB_PASSWORD reference.
+func passwordReference(value string) (Reference, bool) {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return Reference{}, false
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		return Reference{}, false
+	}
+	return ParseReference(password)
+}
+
+// Source fetches the raw value of a secret from one backend.
+type Source interface {
+	Fetch(ctx context.Context, path string) (string, error)
+}
+
+// Config selects the backends a Resolver can fetch from. Files can always
+// be read; Vault needs VaultAddr and AWS Secrets Manager needs AWSRegion.
+type Config struct {
+	// CacheTTL is how long fetched secrets are reused; zero uses
+	// DefaultCacheTTL.
+	CacheTTL time.Duration
+	// VaultAddr is the address of the Vault server. VaultToken
+	// authenticates to it and may itself be a file:// reference, e.g. a
+	// token sink kept fresh by the Vault agent.
+	VaultAddr  string
+	VaultToken string
+	// AWSRegion is the region of AWS Secrets Manager. Credentials are
+	// taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN. AWSEndpoint overrides the regional endpoint.
+	AWSRegion   string
+	AWSEndpoint string
+}
+
+// Resolver resolves secret references and caches the values for CacheTTL.
+// Once a value expires it is fetched again, so a rotated secret is picked
+// up within CacheTTL. A backend that is unreachable at that point is
+// tolerated by serving the last value until it is back.
+type Resolver struct {
+	sources map[string]Source
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[Reference]cachedSecret
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+func NewResolver(cfg Config) *Resolver {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	r := &Resolver{
+		sources: map[string]Source{SchemeFile: FileSource{}},
+		ttl:     cfg.CacheTTL,
+		now:     time.Now,
+		cache:   map[Reference]cachedSecret{},
+	}
+	if cfg.VaultAddr != "" {
+		r.sources[SchemeVault] = NewVaultSource(cfg.VaultAddr, cfg.VaultToken)
+	}
+	if cfg.AWSRegion != "" {
+		r.sources[SchemeAWS] = NewAWSSource(cfg.AWSRegion, cfg.AWSEndpoint)
+	}
+	return r
+}
+
+// Register adds or replaces the source of scheme.
+func (r *Resolver) Register(scheme string, source Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[scheme] = source
+}
+
+// Resolve returns value with its secret reference replaced by the secret.
+// A URL whose password is a reference gets the secret as its password.
+// Other values are returned unchanged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if ref, ok := ParseReference(value); ok {
+		return r.secret(ctx, ref)
+	}
+	if ref, ok := passwordReference(value); ok {
+		password, err := r.secret(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		u, _ := url.Parse(value)
+		u.User = url.UserPassword(u.User.Username(), password)
+		return u.String(), nil
+	}
+	return value, nil
+}
+
+// Func returns a function resolving value on every call, for clients that
+// pick up rotated credentials whenever they connect.
+func (r *Resolver) Func(value string) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return r.Resolve(ctx, value)
+	}
+}
+
+// Watch resolves value every CacheTTL until ctx is done, calling onChange
+// with the new secret whenever it differs from the last one. Failures are
+// logged and retried on the next tick.
+func (r *Resolver) Watch(ctx context.Context, value string, log logger.Logger, onChange func(string)) {
+	last, _ := r.Resolve(ctx, value)
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := r.Resolve(ctx, value)
+		if err != nil {
+			log.Warn("Failed to refresh secret", logger.Err(err))
+			continue
+		}
+		if current != last {
+			last = current
+			onChange(current)
+		}
+	}
+}
+
+func (r *Resolver) secret(ctx context.Context, ref Reference) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, found := r.cache[ref]
+	if found && r.now().Sub(cached.fetchedAt) < r.ttl {
+		return cached.value, nil
+	}
+
+	value, err := r.fetch(ctx, ref)
+	if err != nil {
+		FetchesTotal.WithLabelValues(ref.Scheme, "error").Inc()
+		if found {
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	FetchesTotal.WithLabelValues(ref.Scheme, "success").Inc()
+	if found && value != cached.value {
+		RotationsTotal.WithLabelValues(ref.Scheme).Inc()
+	}
+	r.cache[ref] = cachedSecret{value: value, fetchedAt: r.now()}
+	return value, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, ref Reference) (string, error) {
+	source, ok := r.sources[ref.Scheme]
+	if !ok {
+		return "", fmt.Errorf("no %s secrets backend configured", ref.Scheme)
+	}
+	if ref.Scheme == SchemeVault && ref.Key == "" {
+		return "", errors.New("vault references must name a key")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	raw, err := source.Fetch(ctx, ref.Path)
+	if err != nil {
+		return "", err
+	}
+	if ref.Key == "" {
+		return raw, nil
+	}
+	return field(raw, ref.Key)
+}
+
+// field returns the string field key of the JSON object raw.
+func field(raw, key string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %q", key)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q of the secret is not a string", key)
+	}
+	return s, nil
+}
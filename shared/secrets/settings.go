@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"observability-system/shared/config"
+)
+
+// Settings resolves the settings of a service that may be given as secret
+// references, and keeps the references so they can be resolved again once
+// the secrets rotate. The zero value is ready to use.
+type Settings struct {
+	references map[string]string
+}
+
+// Resolve replaces the secret references among settings, which point at
+// the setting values by name, with the secrets they name. It returns the
+// resolver built from cfg, which keeps the secrets cached and can fetch
+// them again after a rotation. Settings that could not be resolved are
+// reported together as a *config.ValidationError.
+func (s *Settings) Resolve(ctx context.Context, cfg Config, settings map[string]*string) (*Resolver, error) {
+	resolver := NewResolver(cfg)
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.references = map[string]string{}
+	v := config.NewValidator(nil)
+	for _, name := range names {
+		field := settings[name]
+		if !IsReference(*field) {
+			continue
+		}
+		value, err := resolver.Resolve(ctx, *field)
+		if err != nil {
+			v.Addf("%s: %v", name, err)
+			continue
+		}
+		s.references[name] = *field
+		*field = value
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return resolver, nil
+}
+
+// Reference returns setting as configured, before Resolve replaced its
+// secret reference, for resolving it again once the secret rotates. It
+// panics for settings that are not among settings.
+func (s *Settings) Reference(settings map[string]*string, setting string) string {
+	if ref, ok := s.references[setting]; ok {
+		return ref
+	}
+	field, ok := settings[setting]
+	if !ok {
+		panic(fmt.Sprintf("config: %s cannot be a secret reference", setting))
+	}
+	return *field
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultSource reads secrets from HashiCorp Vault over its HTTP API. The
+// path is the API path below /v1, e.g. "secret/data/order-service" for the
+// KV version 2 engine mounted at secret/. The fields of the secret are
+// returned as a JSON object.
+type VaultSource struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// NewVaultSource creates a source reading from the Vault server at addr. A
+// file:// token is read again on every fetch, so a renewed token is used
+// as soon as it is written.
+func NewVaultSource(addr, token string) *VaultSource {
+	return &VaultSource{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *VaultSource) Fetch(ctx context.Context, path string) (string, error) {
+	token := v.token
+	if ref, ok := ParseReference(token); ok {
+		if ref.Scheme != SchemeFile {
+			return "", fmt.Errorf("vault token must be given directly or as a file:// reference")
+		}
+		var err error
+		if token, err = (FileSource{}).Fetch(ctx, ref.Path); err != nil {
+			return "", fmt.Errorf("read vault token: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return "", fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault response: %w", err)
+	}
+	// KV version 2 nests the fields under data.data, next to data.metadata.
+	if inner, ok := body.Data["data"]; ok {
+		if _, versioned := body.Data["metadata"]; versioned {
+			return string(inner), nil
+		}
+	}
+	fields, err := json.Marshal(body.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(fields), nil
+}
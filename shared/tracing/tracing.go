@@ -21,6 +21,10 @@ type Config struct {
 	ServiceVersion string
 	Environment    string
 	JaegerEndpoint string
+	// SampleRatio is the fraction of new traces sampled. Traces continued
+	// from a caller follow the caller's decision, so they are never broken
+	// up between services.
+	SampleRatio float64
 }
 
 func InitTracer(cfg Config) error {
@@ -48,7 +52,7 @@ func InitTracer(cfg Config) error {
 	tracerProvider = sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
 	)
 
 	otel.SetTracerProvider(tracerProvider)
@@ -92,3 +96,14 @@ func InjectTraceContext(ctx context.Context, req *http.Request) {
 func ExtractTraceContext(ctx context.Context, req *http.Request) context.Context {
 	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(req.Header))
 }
+
+// InjectTraceHeaders writes ctx's trace context into headers, e.g. the
+// headers of a broker message.
+func InjectTraceHeaders(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// ExtractTraceHeaders returns ctx continuing the trace context in headers.
+func ExtractTraceHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}